@@ -49,6 +49,7 @@ import (
 	prommetrics "github.com/kedacore/keda/v2/pkg/metrics"
 	kedaprovider "github.com/kedacore/keda/v2/pkg/provider"
 	"github.com/kedacore/keda/v2/pkg/scaling"
+	"github.com/kedacore/keda/v2/pkg/scaling/cache"
 	kedautil "github.com/kedacore/keda/v2/pkg/util"
 	"github.com/kedacore/keda/v2/version"
 )
@@ -68,6 +69,10 @@ var (
 	prometheusMetricsPath     string
 	adapterClientRequestQPS   float32
 	adapterClientRequestBurst int
+	clusterName               string
+	scalerCloseTimeout        time.Duration
+	metricMinValue            string
+	metricMaxValue            string
 )
 
 func (a *Adapter) makeProvider(ctx context.Context, globalHTTPTimeout time.Duration, maxConcurrentReconciles int) (provider.MetricsProvider, <-chan struct{}, error) {
@@ -103,7 +108,12 @@ func (a *Adapter) makeProvider(ctx context.Context, globalHTTPTimeout time.Durat
 
 	broadcaster := record.NewBroadcaster()
 	recorder := broadcaster.NewRecorder(scheme, corev1.EventSource{Component: "keda-metrics-adapter"})
-	handler := scaling.NewScaleHandler(kubeclient, nil, scheme, globalHTTPTimeout, recorder)
+	globalValueBounds, err := scaling.ParseValueBounds(metricMinValue, metricMaxValue)
+	if err != nil {
+		logger.Error(err, "invalid metric-min-value or metric-max-value")
+		return nil, nil, fmt.Errorf("invalid metric-min-value or metric-max-value (%s)", err)
+	}
+	handler := scaling.NewScaleHandler(kubeclient, nil, scheme, globalHTTPTimeout, scalerCloseTimeout, globalValueBounds, recorder)
 	externalMetricsInfo := &[]provider.ExternalMetricInfo{}
 	externalMetricsInfoLock := &sync.RWMutex{}
 
@@ -194,11 +204,16 @@ func main() {
 	cmd.Flags().StringVar(&prometheusMetricsPath, "metrics-path", "/metrics", "Set the path for the prometheus metrics endpoint")
 	cmd.Flags().Float32Var(&adapterClientRequestQPS, "kube-api-qps", 20.0, "Set the QPS rate for throttling requests sent to the apiserver")
 	cmd.Flags().IntVar(&adapterClientRequestBurst, "kube-api-burst", 30, "Set the burst for throttling requests sent to the apiserver")
+	cmd.Flags().StringVar(&clusterName, "cluster-name", "", "Set the name of the cluster, added to the User-Agent of the requests sent by the scalers")
+	cmd.Flags().DurationVar(&scalerCloseTimeout, "scaler-close-timeout", cache.DefaultScalerCloseTimeout, "Set how long closing a scaler may take when its ScaledObject is removed or the adapter shuts down")
+	cmd.Flags().StringVar(&metricMinValue, "metric-min-value", "", "Set the value the metric values of the triggers without a minValue, but predictkube, are raised to when below it, unbounded by default")
+	cmd.Flags().StringVar(&metricMaxValue, "metric-max-value", "", "Set the value the metric values of the triggers without a maxValue, but predictkube, are lowered to when above it, unbounded by default")
 	if err := cmd.Flags().Parse(os.Args); err != nil {
 		return
 	}
 
 	ctrl.SetLogger(logger)
+	kedautil.SetClusterName(clusterName)
 
 	// default to 3 seconds if they don't pass the env var
 	globalHTTPTimeoutMS, err := kedautil.ResolveOsEnvInt("KEDA_HTTP_DEFAULT_TIMEOUT", 3000)
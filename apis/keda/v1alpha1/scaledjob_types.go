@@ -58,7 +58,12 @@ type ScaledJobSpec struct {
 	MaxReplicaCount *int32 `json:"maxReplicaCount,omitempty"`
 	// +optional
 	ScalingStrategy ScalingStrategy `json:"scalingStrategy,omitempty"`
-	Triggers        []ScaleTriggers `json:"triggers"`
+	// ScalingMode ReportOnly evaluates the triggers and reports the number of Jobs that would be created
+	// in the status, without creating them
+	// +kubebuilder:validation:Enum=Active;ReportOnly
+	// +optional
+	ScalingMode ScalingMode     `json:"scalingMode,omitempty"`
+	Triggers    []ScaleTriggers `json:"triggers"`
 }
 
 // ScaledJobStatus defines the observed state of ScaledJob
@@ -68,6 +73,9 @@ type ScaledJobStatus struct {
 	LastActiveTime *metav1.Time `json:"lastActiveTime,omitempty"`
 	// +optional
 	Conditions Conditions `json:"conditions,omitempty"`
+	// WouldScaleTo is the number of Jobs that would be created on the last poll, it's only set in ReportOnly scalingMode
+	// +optional
+	WouldScaleTo *int32 `json:"wouldScaleTo,omitempty"`
 }
 
 // ScaledJobList contains a list of ScaledJob
@@ -105,3 +113,8 @@ func (s ScaledJob) MaxReplicaCount() int64 {
 
 	return 100
 }
+
+// IsReportOnly returns true if the ScaledJob only reports the number of Jobs it would create
+func (s *ScaledJob) IsReportOnly() bool {
+	return s.Spec.ScalingMode == ScalingModeReportOnly
+}
@@ -17,8 +17,11 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"time"
+
 	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // +genclient
@@ -65,13 +68,50 @@ const (
 	HealthStatusFailing HealthStatusType = "Failing"
 )
 
+// TriggerEvaluationStatus records when a trigger of a ScaledObject was last evaluated and how long it took
+type TriggerEvaluationStatus struct {
+	// +optional
+	LastEvaluationTime *metav1.Time `json:"lastEvaluationTime,omitempty"`
+	// +optional
+	LastEvaluationDurationMs int64 `json:"lastEvaluationDurationMs,omitempty"`
+	// CircuitState is the state of the circuit breaker of the trigger, the trigger is unhealthy while it isn't Closed
+	// +optional
+	CircuitState CircuitState `json:"circuitState,omitempty"`
+	// CircuitOpenUntil is when the trigger is probed again while its circuit is open
+	// +optional
+	CircuitOpenUntil *metav1.Time `json:"circuitOpenUntil,omitempty"`
+}
+
+// CircuitState is the state of the circuit breaker of a trigger
+type CircuitState string
+
+const (
+	// CircuitStateClosed means the trigger is evaluated on every poll
+	CircuitStateClosed CircuitState = "Closed"
+
+	// CircuitStateOpen means the trigger kept failing and isn't evaluated until its backoff expires
+	CircuitStateOpen CircuitState = "Open"
+
+	// CircuitStateHalfOpen means the backoff of the trigger expired and the next evaluation probes it
+	CircuitStateHalfOpen CircuitState = "HalfOpen"
+)
+
 // ScaledObjectSpec is the spec for a ScaledObject resource
 type ScaledObjectSpec struct {
 	ScaleTargetRef *ScaleTarget `json:"scaleTargetRef"`
+	// MinReplicasWhenPVCBound keeps a StatefulSet from being scaled down below the replicas whose PVCs are Bound.
+	// "auto" keeps every replica up to the highest ordinal with a Bound PVC, a number caps that floor
+	// +optional
+	MinReplicasWhenPVCBound *intstr.IntOrString `json:"minReplicasWhenPVCBound,omitempty"`
 	// +optional
 	PollingInterval *int32 `json:"pollingInterval,omitempty"`
 	// +optional
 	CooldownPeriod *int32 `json:"cooldownPeriod,omitempty"`
+	// IdlePollingInterval is the polling interval once every trigger has been inactive for longer than the cooldownPeriod,
+	// the pollingInterval is used again as soon as a trigger is active
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	IdlePollingInterval *int32 `json:"idlePollingInterval,omitempty"`
 	// +optional
 	IdleReplicaCount *int32 `json:"idleReplicaCount,omitempty"`
 	// +optional
@@ -98,8 +138,44 @@ type AdvancedConfig struct {
 	HorizontalPodAutoscalerConfig *HorizontalPodAutoscalerConfig `json:"horizontalPodAutoscalerConfig,omitempty"`
 	// +optional
 	RestoreToOriginalReplicaCount bool `json:"restoreToOriginalReplicaCount,omitempty"`
+	// ScalingMode ReportOnly evaluates the triggers and reports the replica count the scale target would be scaled to
+	// in the status, without creating the HPA or scaling the target
+	// +kubebuilder:validation:Enum=Active;ReportOnly
+	// +optional
+	ScalingMode ScalingMode `json:"scalingMode,omitempty"`
+	// CircuitBreaker stops evaluating a trigger that keeps failing and reading its metrics, the trigger is probed again once its backoff expires
+	// +optional
+	CircuitBreaker *CircuitBreaker `json:"circuitBreaker,omitempty"`
+}
+
+// CircuitBreaker specifies when the circuit of a failing trigger opens and for how long
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failed evaluations of a trigger opening its circuit, 5 by default
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+	// InitialBackoffSeconds is how long the circuit stays open the first time, 30 by default.
+	// It doubles every time the probe evaluation fails
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	InitialBackoffSeconds *int32 `json:"initialBackoffSeconds,omitempty"`
+	// MaxBackoffSeconds is the longest the circuit stays open, 600 by default
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxBackoffSeconds *int32 `json:"maxBackoffSeconds,omitempty"`
 }
 
+// ScalingMode tells whether KEDA scales the scale target or only reports what it would do
+type ScalingMode string
+
+const (
+	// ScalingModeActive scales the scale target, it's the default
+	ScalingModeActive ScalingMode = "Active"
+
+	// ScalingModeReportOnly only reports the replica count the scale target would be scaled to
+	ScalingModeReportOnly ScalingMode = "ReportOnly"
+)
+
 // HorizontalPodAutoscalerConfig specifies horizontal scale config
 type HorizontalPodAutoscalerConfig struct {
 	// +optional
@@ -127,6 +203,11 @@ type ScaleTriggers struct {
 	AuthenticationRef *ScaledObjectAuthRef `json:"authenticationRef,omitempty"`
 	// +optional
 	MetricType autoscalingv2beta2.MetricTargetType `json:"metricType,omitempty"`
+	// ValueExpression transforms the value of the trigger before it is used for activation and reported to the HPA,
+	// e.g. "value / 1024" or "min(value, 500)". The trigger is then active when the transformed value is above 0,
+	// instead of the activation threshold of the scaler, e.g. "value - 100" activates it above 100
+	// +optional
+	ValueExpression string `json:"valueExpression,omitempty"`
 }
 
 // +k8s:openapi-gen=true
@@ -152,6 +233,17 @@ type ScaledObjectStatus struct {
 	Health map[string]HealthStatus `json:"health,omitempty"`
 	// +optional
 	PausedReplicaCount *int32 `json:"pausedReplicaCount,omitempty"`
+	// +optional
+	TriggerEvaluations map[string]TriggerEvaluationStatus `json:"triggerEvaluations,omitempty"`
+	// PVCBoundReplicaFloor is the replica count the scale target was held at on the last scale down because of Bound PVCs
+	// +optional
+	PVCBoundReplicaFloor *int32 `json:"pvcBoundReplicaFloor,omitempty"`
+	// WouldScaleTo is the replica count the scale target would be scaled to, it's only set in ReportOnly scalingMode
+	// +optional
+	WouldScaleTo *int32 `json:"wouldScaleTo,omitempty"`
+	// EffectivePollingInterval is the interval in seconds the triggers are currently polled at, it's only set with an idlePollingInterval
+	// +optional
+	EffectivePollingInterval *int32 `json:"effectivePollingInterval,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -172,6 +264,25 @@ type ScaledObjectAuthRef struct {
 	Kind string `json:"kind,omitempty"`
 }
 
+const (
+	// Default cooldown period for a ScaleTarget if no cooldownPeriod is defined on the scaledObject
+	defaultCooldownPeriod = 5 * 60 // 5 minutes
+)
+
 func init() {
 	SchemeBuilder.Register(&ScaledObject{}, &ScaledObjectList{})
 }
+
+// GetCooldownPeriod returns the cooldownPeriod of the ScaledObject, the default one when it isn't set
+func (so *ScaledObject) GetCooldownPeriod() time.Duration {
+	if so.Spec.CooldownPeriod != nil {
+		return time.Second * time.Duration(*so.Spec.CooldownPeriod)
+	}
+
+	return time.Second * time.Duration(defaultCooldownPeriod)
+}
+
+// IsReportOnly returns true if the ScaledObject only reports the replica count it would scale the scale target to
+func (so *ScaledObject) IsReportOnly() bool {
+	return so.Spec.Advanced != nil && so.Spec.Advanced.ScalingMode == ScalingModeReportOnly
+}
@@ -25,6 +25,7 @@ import (
 	"k8s.io/api/autoscaling/v2beta2"
 	"k8s.io/api/batch/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -35,6 +36,11 @@ func (in *AdvancedConfig) DeepCopyInto(out *AdvancedConfig) {
 		*out = new(HorizontalPodAutoscalerConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.CircuitBreaker != nil {
+		in, out := &in.CircuitBreaker, &out.CircuitBreaker
+		*out = new(CircuitBreaker)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdvancedConfig.
@@ -188,6 +194,36 @@ func (in *AzureKeyVaultSecret) DeepCopy() *AzureKeyVaultSecret {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CircuitBreaker) DeepCopyInto(out *CircuitBreaker) {
+	*out = *in
+	if in.FailureThreshold != nil {
+		in, out := &in.FailureThreshold, &out.FailureThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	if in.InitialBackoffSeconds != nil {
+		in, out := &in.InitialBackoffSeconds, &out.InitialBackoffSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxBackoffSeconds != nil {
+		in, out := &in.MaxBackoffSeconds, &out.MaxBackoffSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CircuitBreaker.
+func (in *CircuitBreaker) DeepCopy() *CircuitBreaker {
+	if in == nil {
+		return nil
+	}
+	out := new(CircuitBreaker)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterTriggerAuthentication) DeepCopyInto(out *ClusterTriggerAuthentication) {
 	*out = *in
@@ -551,6 +587,11 @@ func (in *ScaledJobStatus) DeepCopyInto(out *ScaledJobStatus) {
 		*out = make(Conditions, len(*in))
 		copy(*out, *in)
 	}
+	if in.WouldScaleTo != nil {
+		in, out := &in.WouldScaleTo, &out.WouldScaleTo
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaledJobStatus.
@@ -645,6 +686,11 @@ func (in *ScaledObjectSpec) DeepCopyInto(out *ScaledObjectSpec) {
 		*out = new(ScaleTarget)
 		**out = **in
 	}
+	if in.MinReplicasWhenPVCBound != nil {
+		in, out := &in.MinReplicasWhenPVCBound, &out.MinReplicasWhenPVCBound
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
 	if in.PollingInterval != nil {
 		in, out := &in.PollingInterval, &out.PollingInterval
 		*out = new(int32)
@@ -655,6 +701,11 @@ func (in *ScaledObjectSpec) DeepCopyInto(out *ScaledObjectSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.IdlePollingInterval != nil {
+		in, out := &in.IdlePollingInterval, &out.IdlePollingInterval
+		*out = new(int32)
+		**out = **in
+	}
 	if in.IdleReplicaCount != nil {
 		in, out := &in.IdleReplicaCount, &out.IdleReplicaCount
 		*out = new(int32)
@@ -743,6 +794,28 @@ func (in *ScaledObjectStatus) DeepCopyInto(out *ScaledObjectStatus) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.TriggerEvaluations != nil {
+		in, out := &in.TriggerEvaluations, &out.TriggerEvaluations
+		*out = make(map[string]TriggerEvaluationStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.PVCBoundReplicaFloor != nil {
+		in, out := &in.PVCBoundReplicaFloor, &out.PVCBoundReplicaFloor
+		*out = new(int32)
+		**out = **in
+	}
+	if in.WouldScaleTo != nil {
+		in, out := &in.WouldScaleTo, &out.WouldScaleTo
+		*out = new(int32)
+		**out = **in
+	}
+	if in.EffectivePollingInterval != nil {
+		in, out := &in.EffectivePollingInterval, &out.EffectivePollingInterval
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaledObjectStatus.
@@ -893,6 +966,29 @@ func (in *TriggerAuthenticationSpec) DeepCopy() *TriggerAuthenticationSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TriggerEvaluationStatus) DeepCopyInto(out *TriggerEvaluationStatus) {
+	*out = *in
+	if in.LastEvaluationTime != nil {
+		in, out := &in.LastEvaluationTime, &out.LastEvaluationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CircuitOpenUntil != nil {
+		in, out := &in.CircuitOpenUntil, &out.CircuitOpenUntil
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TriggerEvaluationStatus.
+func (in *TriggerEvaluationStatus) DeepCopy() *TriggerEvaluationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TriggerEvaluationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ValueFromSecret) DeepCopyInto(out *ValueFromSecret) {
 	*out = *in
@@ -26,7 +26,9 @@ import (
 	"github.com/go-logr/logr"
 	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
@@ -59,6 +61,27 @@ func (r *ScaledObjectReconciler) createAndDeployNewHPA(ctx context.Context, logg
 	return nil
 }
 
+// deleteHPAIfExists deletes the HPA of the ScaledObject, e.g. when the ScaledObject was switched to ReportOnly scalingMode
+func (r *ScaledObjectReconciler) deleteHPAIfExists(ctx context.Context, logger logr.Logger, scaledObject *kedav1alpha1.ScaledObject) error {
+	hpaName := getHPAName(scaledObject)
+	hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: hpaName, Namespace: scaledObject.Namespace}, hpa)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		logger.Error(err, "Failed to get HPA from cluster")
+		return err
+	}
+
+	logger.Info("Deleting the HPA of the ScaledObject in ReportOnly mode", "HPA.Namespace", scaledObject.Namespace, "HPA.Name", hpaName)
+	err = r.Client.Delete(ctx, hpa)
+	if err != nil && !errors.IsNotFound(err) {
+		logger.Error(err, "Failed to delete HPA in cluster", "HPA.Namespace", scaledObject.Namespace, "HPA.Name", hpaName)
+		return err
+	}
+	return nil
+}
+
 // newHPAForScaledObject returns HPA as it is specified in ScaledObject
 func (r *ScaledObjectReconciler) newHPAForScaledObject(ctx context.Context, logger logr.Logger, scaledObject *kedav1alpha1.ScaledObject, gvkr *kedav1alpha1.GroupVersionKindResource) (*autoscalingv2beta2.HorizontalPodAutoscaler, error) {
 	scaledObjectMetricSpecs, err := r.getScaledObjectMetricSpecs(ctx, logger, scaledObject)
@@ -50,13 +50,17 @@ type ScaledJobReconciler struct {
 	Scheme            *runtime.Scheme
 	GlobalHTTPTimeout time.Duration
 	Recorder          record.EventRecorder
+	// ScalerCloseTimeout bounds how long closing a scaler may take
+	ScalerCloseTimeout time.Duration
+	// GlobalValueBounds clamp the metric values of the triggers without their own minValue or maxValue
+	GlobalValueBounds scaling.ValueBounds
 
 	scaleHandler scaling.ScaleHandler
 }
 
 // SetupWithManager initializes the ScaledJobReconciler instance and starts a new controller managed by the passed Manager instance.
 func (r *ScaledJobReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
-	r.scaleHandler = scaling.NewScaleHandler(mgr.GetClient(), nil, mgr.GetScheme(), r.GlobalHTTPTimeout, mgr.GetEventRecorderFor("scale-handler"))
+	r.scaleHandler = scaling.NewScaleHandler(mgr.GetClient(), nil, mgr.GetScheme(), r.GlobalHTTPTimeout, r.ScalerCloseTimeout, r.GlobalValueBounds, mgr.GetEventRecorderFor("scale-handler"))
 
 	return ctrl.NewControllerManagedBy(mgr).
 		WithOptions(options).
@@ -142,6 +146,16 @@ func (r *ScaledJobReconciler) reconcileScaledJob(ctx context.Context, logger log
 		return msg, err
 	}
 
+	err = scaling.ValidateValueExpressions(scaledJob.Spec.Triggers)
+	if err != nil {
+		return "ScaledJob has an invalid valueExpression", err
+	}
+
+	err = scaling.ValidateValueBounds(scaledJob.Spec.Triggers, r.GlobalValueBounds)
+	if err != nil {
+		return "ScaledJob has invalid value bounds", err
+	}
+
 	// Check ScaledJob is Ready or not
 	_, err = r.scaleHandler.GetScalersCache(ctx, scaledJob)
 	if err != nil {
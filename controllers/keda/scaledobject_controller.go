@@ -19,6 +19,7 @@ package keda
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -49,13 +50,14 @@ import (
 	kedacontrollerutil "github.com/kedacore/keda/v2/controllers/keda/util"
 	"github.com/kedacore/keda/v2/pkg/eventreason"
 	"github.com/kedacore/keda/v2/pkg/scaling"
+	"github.com/kedacore/keda/v2/pkg/scaling/executor"
 	kedautil "github.com/kedacore/keda/v2/pkg/util"
 )
 
 // +kubebuilder:rbac:groups=keda.sh,resources=scaledobjects;scaledobjects/finalizers;scaledobjects/status,verbs="*"
 // +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs="*"
 // +kubebuilder:rbac:groups="",resources=configmaps;configmaps/status;events,verbs="*"
-// +kubebuilder:rbac:groups="",resources=pods;services;services;secrets;external,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods;services;services;secrets;external;persistentvolumeclaims,verbs=get;list;watch
 // +kubebuilder:rbac:groups="*",resources="*/scale",verbs="*"
 // +kubebuilder:rbac:groups="",resources="serviceaccounts",verbs=list;watch
 // +kubebuilder:rbac:groups="*",resources="*",verbs=get
@@ -68,12 +70,20 @@ type ScaledObjectReconciler struct {
 	Scheme            *runtime.Scheme
 	GlobalHTTPTimeout time.Duration
 	Recorder          record.EventRecorder
+	// DuplicateTriggersPolicy is what to do with identical triggers, they are deduplicated by default
+	DuplicateTriggersPolicy scaling.DuplicateTriggersPolicy
+	// ScalerCloseTimeout bounds how long closing a scaler may take
+	ScalerCloseTimeout time.Duration
+	// GlobalValueBounds clamp the metric values of the triggers without their own minValue or maxValue
+	GlobalValueBounds scaling.ValueBounds
 
 	scaleClient              scale.ScalesGetter
 	restMapper               meta.RESTMapper
 	scaledObjectsGenerations *sync.Map
 	scaleHandler             scaling.ScaleHandler
 	kubeVersion              kedautil.K8sVersion
+	// scaledObjectsDuplicateTriggers stores the duplicate triggers last reported for each ScaledObject
+	scaledObjectsDuplicateTriggers *sync.Map
 }
 
 // A cache mapping "resource.group" to true or false if we know if this resource is scalable.
@@ -114,7 +124,8 @@ func (r *ScaledObjectReconciler) SetupWithManager(mgr ctrl.Manager, options cont
 	// Init the rest of ScaledObjectReconciler
 	r.restMapper = mgr.GetRESTMapper()
 	r.scaledObjectsGenerations = &sync.Map{}
-	r.scaleHandler = scaling.NewScaleHandler(mgr.GetClient(), r.scaleClient, mgr.GetScheme(), r.GlobalHTTPTimeout, r.Recorder)
+	r.scaledObjectsDuplicateTriggers = &sync.Map{}
+	r.scaleHandler = scaling.NewScaleHandler(mgr.GetClient(), r.scaleClient, mgr.GetScheme(), r.GlobalHTTPTimeout, r.ScalerCloseTimeout, r.GlobalValueBounds, r.Recorder)
 
 	// Start controller
 	return ctrl.NewControllerManagedBy(mgr).
@@ -227,10 +238,39 @@ func (r *ScaledObjectReconciler) reconcileScaledObject(ctx context.Context, logg
 		return "ScaledObject doesn't have correct Idle/Min/Max Replica Counts specification", err
 	}
 
-	// Create a new HPA or update existing one according to ScaledObject
-	newHPACreated, err := r.ensureHPAForScaledObjectExists(ctx, logger, scaledObject, &gvkr)
+	err = checkMinReplicasWhenPVCBoundIsValid(scaledObject, gvkr)
 	if err != nil {
-		return "Failed to ensure HPA is correctly created for ScaledObject", err
+		return "ScaledObject doesn't have correct minReplicasWhenPVCBound specification", err
+	}
+
+	err = r.checkDuplicateTriggers(logger, scaledObject)
+	if err != nil {
+		return "ScaledObject has duplicate triggers", err
+	}
+
+	err = scaling.ValidateValueExpressions(scaledObject.Spec.Triggers)
+	if err != nil {
+		return "ScaledObject has an invalid valueExpression", err
+	}
+
+	err = scaling.ValidateValueBounds(scaledObject.Spec.Triggers, r.GlobalValueBounds)
+	if err != nil {
+		return "ScaledObject has invalid value bounds", err
+	}
+
+	newHPACreated := false
+	if scaledObject.IsReportOnly() {
+		// in ReportOnly mode the scale target is left alone, the HPA created in Active mode is removed
+		err = r.deleteHPAIfExists(ctx, logger, scaledObject)
+		if err != nil {
+			return "Failed to delete HPA of ScaledObject in ReportOnly mode", err
+		}
+	} else {
+		// Create a new HPA or update existing one according to ScaledObject
+		newHPACreated, err = r.ensureHPAForScaledObjectExists(ctx, logger, scaledObject, &gvkr)
+		if err != nil {
+			return "Failed to ensure HPA is correctly created for ScaledObject", err
+		}
 	}
 	scaleObjectSpecChanged := false
 	if !newHPACreated {
@@ -358,6 +398,55 @@ func (r *ScaledObjectReconciler) checkReplicaCountBoundsAreValid(scaledObject *k
 	return nil
 }
 
+// checkMinReplicasWhenPVCBoundIsValid checks that minReplicasWhenPVCBound is only set for a StatefulSet and is "auto" or a number
+func checkMinReplicasWhenPVCBoundIsValid(scaledObject *kedav1alpha1.ScaledObject, gvkr kedav1alpha1.GroupVersionKindResource) error {
+	if scaledObject.Spec.MinReplicasWhenPVCBound == nil {
+		return nil
+	}
+	if gvkr.Group != "apps" || gvkr.Kind != "StatefulSet" {
+		return fmt.Errorf("minReplicasWhenPVCBound can only be set when scaling a StatefulSet, the scaleTarget is a %s", gvkr.Kind)
+	}
+	return executor.ValidateMinReplicasWhenPVCBound(scaledObject.Spec.MinReplicasWhenPVCBound)
+}
+
+// checkDuplicateTriggers checks that no trigger of the ScaledObject is identical to another one, they would double the metric.
+// Depending on the policy the ScaledObject is rejected, or the duplicates are reported and dropped by the scale handler
+func (r *ScaledObjectReconciler) checkDuplicateTriggers(logger logr.Logger, scaledObject *kedav1alpha1.ScaledObject) error {
+	key, err := cache.MetaNamespaceKeyFunc(scaledObject)
+	if err != nil {
+		logger.Error(err, "Error getting key for scaledObject")
+		return err
+	}
+
+	duplicates := scaling.FindDuplicateTriggers(scaledObject.Spec.Triggers)
+	if len(duplicates) == 0 {
+		r.scaledObjectsDuplicateTriggers.Delete(key)
+		return nil
+	}
+
+	// report the duplicates in the order of the triggers
+	msgs := make([]string, 0, len(duplicates))
+	for i := range scaledObject.Spec.Triggers {
+		if first, ok := duplicates[i]; ok {
+			msgs = append(msgs, fmt.Sprintf("trigger %d (%s) is identical to trigger %d", i, scaledObject.Spec.Triggers[i].Type, first))
+		}
+	}
+	msg := strings.Join(msgs, ", ")
+
+	if r.DuplicateTriggersPolicy == scaling.DuplicateTriggersReject {
+		return fmt.Errorf("%s, give them different names if they are meant to be duplicated", msg)
+	}
+
+	// the warning is reported when the duplicates change, not on every reconcile
+	if previous, loaded := r.scaledObjectsDuplicateTriggers.Load(key); loaded && previous.(string) == msg {
+		return nil
+	}
+	r.scaledObjectsDuplicateTriggers.Store(key, msg)
+	logger.Info("Ignoring duplicate triggers", "duplicates", msg)
+	r.Recorder.Event(scaledObject, corev1.EventTypeWarning, eventreason.ScaledObjectDuplicateTriggers, fmt.Sprintf("Ignoring duplicate triggers: %s", msg))
+	return nil
+}
+
 // ensureHPAForScaledObjectExists ensures that in cluster exist up-to-date HPA for specified ScaledObject, returns true if a new HPA was created
 func (r *ScaledObjectReconciler) ensureHPAForScaledObjectExists(ctx context.Context, logger logr.Logger, scaledObject *kedav1alpha1.ScaledObject, gvkr *kedav1alpha1.GroupVersionKindResource) (bool, error) {
 	hpaName := getHPAName(scaledObject)
@@ -424,6 +513,7 @@ func (r *ScaledObjectReconciler) stopScaleLoop(ctx context.Context, logger logr.
 	}
 	// delete ScaledObject's current Generation
 	r.scaledObjectsGenerations.Delete(key)
+	r.scaledObjectsDuplicateTriggers.Delete(key)
 	return nil
 }
 
@@ -19,6 +19,7 @@ package keda
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/golang/mock/gomock"
@@ -29,6 +30,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
@@ -225,6 +227,34 @@ var _ = Describe("ScaledObjectController", func() {
 				Ω(err).ShouldNot(BeNil())
 			})
 		})
+
+		Context("With Duplicate Triggers", func() {
+			It("should report the duplicates only when they change", func() {
+				recorder := record.NewFakeRecorder(10)
+				reconciler := ScaledObjectReconciler{
+					Recorder:                       recorder,
+					scaledObjectsDuplicateTriggers: &sync.Map{},
+				}
+				trigger := kedav1alpha1.ScaleTriggers{Type: "prometheus", Metadata: triggerMeta[1]}
+				so := &kedav1alpha1.ScaledObject{
+					ObjectMeta: metav1.ObjectMeta{Name: "duplicate-triggers", Namespace: "default"},
+					Spec: kedav1alpha1.ScaledObjectSpec{
+						Triggers: []kedav1alpha1.ScaleTriggers{trigger, trigger},
+					},
+				}
+
+				// the same duplicates are reported once over the reconciles
+				for i := 0; i < 3; i++ {
+					Ω(reconciler.checkDuplicateTriggers(testLogger, so)).Should(Succeed())
+				}
+				Ω(recorder.Events).Should(HaveLen(1))
+
+				// a new duplicate is reported again
+				so.Spec.Triggers = append(so.Spec.Triggers, trigger)
+				Ω(reconciler.checkDuplicateTriggers(testLogger, so)).Should(Succeed())
+				Ω(recorder.Events).Should(HaveLen(2))
+			})
+		})
 	})
 
 	Describe("functional tests", func() {
@@ -43,8 +43,9 @@ func (r *ScaledObjectReconciler) finalizeScaledObject(ctx context.Context, logge
 			return err
 		}
 
-		// if enabled, scale scaleTarget back to the original replica count (to the state it was before scaling with KEDA)
-		if scaledObject.Spec.Advanced != nil && scaledObject.Spec.Advanced.RestoreToOriginalReplicaCount {
+		// if enabled, scale scaleTarget back to the original replica count (to the state it was before scaling with KEDA),
+		// a ScaledObject in ReportOnly mode didn't scale the scaleTarget
+		if scaledObject.Spec.Advanced != nil && scaledObject.Spec.Advanced.RestoreToOriginalReplicaCount && !scaledObject.IsReportOnly() {
 			// If the scaling hasn't been yet initialized (for example due to the missing scaleTarget), we don't have the GVKR information about the scaleTarget.
 			// Thus we don't have enough information needed to properly set the number of replicas on the scaleTarget.
 			// Let's skip in this case.
@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keda
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"github.com/kedacore/keda/v2/pkg/scaling/executor"
+)
+
+var _ = Describe("StatefulSet PVC bound replica floor", func() {
+	It("counts the replicas up to the highest ordinal with a Bound PVC", func() {
+		statefulSet := generateStatefulSet("pvcfloor")
+		err := k8sClient.Create(context.Background(), statefulSet)
+		Expect(err).ToNot(HaveOccurred())
+
+		createPVC("data-pvcfloor-0", corev1.ClaimBound)
+		createPVC("data-pvcfloor-1", corev1.ClaimBound)
+		createPVC("data-pvcfloor-2", corev1.ClaimLost)
+		createPVC("data-pvcfloor-3", corev1.ClaimPending)
+		// PVCs of other StatefulSets don't hold this one
+		createPVC("data-pvcfloor-other-5", corev1.ClaimBound)
+
+		floor, err := executor.GetPVCBoundReplicaFloor(context.Background(), k8sClient, statefulSet)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(floor).To(Equal(int32(2)))
+
+		// the volume of the highest ordinal was lost, the floor drops
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = k8sClient.Get(context.Background(), types.NamespacedName{Name: "data-pvcfloor-1", Namespace: "default"}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		pvc.Status.Phase = corev1.ClaimLost
+		err = k8sClient.Status().Update(context.Background(), pvc)
+		Expect(err).ToNot(HaveOccurred())
+
+		floor, err = executor.GetPVCBoundReplicaFloor(context.Background(), k8sClient, statefulSet)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(floor).To(Equal(int32(1)))
+	})
+
+	It("doesn't allow minReplicasWhenPVCBound when scaling a Deployment", func() {
+		deploymentName := "pvcfloor-deployment"
+		soName := "so-" + deploymentName
+
+		err := k8sClient.Create(context.Background(), generateDeployment(deploymentName))
+		Expect(err).ToNot(HaveOccurred())
+
+		auto := intstr.FromString(executor.MinReplicasWhenPVCBoundAuto)
+		so := &kedav1alpha1.ScaledObject{
+			ObjectMeta: metav1.ObjectMeta{Name: soName, Namespace: "default"},
+			Spec: kedav1alpha1.ScaledObjectSpec{
+				ScaleTargetRef: &kedav1alpha1.ScaleTarget{
+					Name: deploymentName,
+				},
+				MinReplicasWhenPVCBound: &auto,
+				Triggers: []kedav1alpha1.ScaleTriggers{
+					{
+						Type: "cron",
+						Metadata: map[string]string{
+							"timezone":        "UTC",
+							"start":           "0 * * * *",
+							"end":             "1 * * * *",
+							"desiredReplicas": "1",
+						},
+					},
+				},
+			},
+		}
+		err = k8sClient.Create(context.Background(), so)
+		Ω(err).ToNot(HaveOccurred())
+
+		Eventually(func() metav1.ConditionStatus {
+			err = k8sClient.Get(context.Background(), types.NamespacedName{Name: soName, Namespace: "default"}, so)
+			Ω(err).ToNot(HaveOccurred())
+			return so.Status.Conditions.GetReadyCondition().Status
+		}, 20*time.Second).Should(Equal(metav1.ConditionFalse))
+	})
+})
+
+func createPVC(name string, phase corev1.PersistentVolumeClaimPhase) {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	err := k8sClient.Create(context.Background(), pvc)
+	Expect(err).ToNot(HaveOccurred())
+
+	pvc.Status.Phase = phase
+	err = k8sClient.Status().Update(context.Background(), pvc)
+	Expect(err).ToNot(HaveOccurred())
+}
+
+func generateStatefulSet(name string) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: appsv1.StatefulSetSpec{
+			ServiceName: name,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": name,
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": name,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  name,
+							Image: name,
+						},
+					},
+				},
+			},
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "data"},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -7,6 +7,9 @@ import (
 
 const PausedReplicasAnnotation = "autoscaling.keda.sh/paused-replicas"
 
+// ForceScaleBelowPVCFloorAnnotation set to "true" lets a StatefulSet be scaled down below the floor kept by minReplicasWhenPVCBound
+const ForceScaleBelowPVCFloorAnnotation = "autoscaling.keda.sh/force-scale-below-pvc-floor"
+
 type PausedReplicasPredicate struct {
 	predicate.Funcs
 }
@@ -34,6 +34,8 @@ import (
 
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
 	kedacontrollers "github.com/kedacore/keda/v2/controllers/keda"
+	"github.com/kedacore/keda/v2/pkg/scaling"
+	"github.com/kedacore/keda/v2/pkg/scaling/cache"
 	kedautil "github.com/kedacore/keda/v2/pkg/util"
 	"github.com/kedacore/keda/v2/version"
 	//+kubebuilder:scaffold:imports
@@ -65,17 +67,42 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var clusterName string
+	var duplicateTriggers string
+	var scalerCloseTimeout time.Duration
+	var metricMinValue, metricMaxValue string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&clusterName, "cluster-name", "", "The name of the cluster, added to the User-Agent of the requests sent by the scalers.")
+	flag.StringVar(&duplicateTriggers, "duplicate-triggers", string(scaling.DuplicateTriggersDedupe),
+		"What to do with identical triggers of a ScaledObject: \"dedupe\" ignores all but the first one with a warning event, "+
+			"\"reject\" fails the validation of the ScaledObject.")
+	flag.DurationVar(&scalerCloseTimeout, "scaler-close-timeout", cache.DefaultScalerCloseTimeout,
+		"How long closing a scaler may take when its ScaledObject or ScaledJob is removed or the operator shuts down.")
+	flag.StringVar(&metricMinValue, "metric-min-value", "", "The value the metric values of the triggers without a minValue, but predictkube, are raised to when below it, unbounded by default.")
+	flag.StringVar(&metricMaxValue, "metric-max-value", "", "The value the metric values of the triggers without a maxValue, but predictkube, are lowered to when above it, unbounded by default.")
 	opts := zap.Options{}
 	opts.BindFlags(flag.CommandLine)
 
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	kedautil.SetClusterName(clusterName)
+
+	duplicateTriggersPolicy, err := scaling.ParseDuplicateTriggersPolicy(duplicateTriggers)
+	if err != nil {
+		setupLog.Error(err, "invalid duplicate-triggers")
+		os.Exit(1)
+	}
+
+	globalValueBounds, err := scaling.ParseValueBounds(metricMinValue, metricMaxValue)
+	if err != nil {
+		setupLog.Error(err, "invalid metric-min-value or metric-max-value")
+		os.Exit(1)
+	}
 
 	namespace, err := getWatchNamespace()
 	if err != nil {
@@ -120,19 +147,24 @@ func main() {
 	eventRecorder := mgr.GetEventRecorderFor("keda-operator")
 
 	if err = (&kedacontrollers.ScaledObjectReconciler{
-		Client:            mgr.GetClient(),
-		Scheme:            mgr.GetScheme(),
-		GlobalHTTPTimeout: globalHTTPTimeout,
-		Recorder:          eventRecorder,
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		GlobalHTTPTimeout:       globalHTTPTimeout,
+		Recorder:                eventRecorder,
+		DuplicateTriggersPolicy: duplicateTriggersPolicy,
+		ScalerCloseTimeout:      scalerCloseTimeout,
+		GlobalValueBounds:       globalValueBounds,
 	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: scaledObjectMaxReconciles}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ScaledObject")
 		os.Exit(1)
 	}
 	if err = (&kedacontrollers.ScaledJobReconciler{
-		Client:            mgr.GetClient(),
-		Scheme:            mgr.GetScheme(),
-		GlobalHTTPTimeout: globalHTTPTimeout,
-		Recorder:          eventRecorder,
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		GlobalHTTPTimeout:  globalHTTPTimeout,
+		Recorder:           eventRecorder,
+		ScalerCloseTimeout: scalerCloseTimeout,
+		GlobalValueBounds:  globalValueBounds,
 	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: scaledJobMaxReconciles}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ScaledJob")
 		os.Exit(1)
@@ -29,6 +29,9 @@ const (
 	// ScaledJobCheckFailed is for event when ScaledJob validation check fails
 	ScaledJobCheckFailed = "ScaledJobCheckFailed"
 
+	// ScaledObjectDuplicateTriggers is for event when identical triggers of a ScaledObject are deduplicated
+	ScaledObjectDuplicateTriggers = "ScaledObjectDuplicateTriggers"
+
 	// ScaledObjectDeleted is for event when ScaledObject is deleted
 	ScaledObjectDeleted = "ScaledObjectDeleted"
 
@@ -44,6 +47,15 @@ const (
 	// KEDAScalerFailed is for event when a scaler fails for a ScaledJob or a ScaledObject
 	KEDAScalerFailed = "KEDAScalerFailed"
 
+	// KEDAScalerCircuitOpened is for event when a scaler of a ScaledObject kept failing and isn't evaluated until its backoff expires
+	KEDAScalerCircuitOpened = "KEDAScalerCircuitOpened"
+
+	// KEDAScalerCircuitClosed is for event when a scaler of a ScaledObject whose circuit was open succeeded again
+	KEDAScalerCircuitClosed = "KEDAScalerCircuitClosed"
+
+	// KEDAScalerValueClamped is for event when the metric values of a trigger keep being clamped to its minValue and maxValue
+	KEDAScalerValueClamped = "KEDAScalerValueClamped"
+
 	// KEDAScaleTargetActivated is for event when the scale target of ScaledObject was activated
 	KEDAScaleTargetActivated = "KEDAScaleTargetActivated"
 
@@ -56,9 +68,18 @@ const (
 	// KEDAScaleTargetDeactivationFailed is for event when the deactivation of the scale target for ScaledObject fails
 	KEDAScaleTargetDeactivationFailed = "KEDAScaleTargetDeactivationFailed"
 
+	// KEDAScaleTargetHeldByPVCs is for event when the scale down of the scale target for ScaledObject is held by Bound PVCs
+	KEDAScaleTargetHeldByPVCs = "KEDAScaleTargetHeldByPVCs"
+
+	// KEDAScaleTargetReportOnly is for event when a ScaledObject in ReportOnly mode would scale the scale target to another replica count
+	KEDAScaleTargetReportOnly = "KEDAScaleTargetReportOnly"
+
 	// KEDAJobsCreated is for event when jobs for ScaledJob are created
 	KEDAJobsCreated = "KEDAJobsCreated"
 
+	// KEDAJobsReportOnly is for event when a ScaledJob in ReportOnly mode would create another number of jobs
+	KEDAJobsReportOnly = "KEDAJobsReportOnly"
+
 	// TriggerAuthenticationDeleted is for event when a TriggerAuthentication is deleted
 	TriggerAuthenticationDeleted = "TriggerAuthenticationDeleted"
 
@@ -18,6 +18,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -33,7 +34,9 @@ import (
 
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
 	prommetrics "github.com/kedacore/keda/v2/pkg/metrics"
+	"github.com/kedacore/keda/v2/pkg/scalers"
 	"github.com/kedacore/keda/v2/pkg/scaling"
+	"github.com/kedacore/keda/v2/pkg/scaling/cache"
 )
 
 // KedaProvider implements External Metrics Provider
@@ -97,7 +100,7 @@ func (p *KedaProvider) GetExternalMetric(ctx context.Context, namespace string,
 	scaledObject := &scaledObjects.Items[0]
 	var matchingMetrics []external_metrics.ExternalMetricValue
 
-	cache, err := p.scaleHandler.GetScalersCache(ctx, scaledObject)
+	scalersCache, err := p.scaleHandler.GetScalersCache(ctx, scaledObject)
 	metricsServer.RecordScalerObjectError(scaledObject.Namespace, scaledObject.Name, err)
 	if err != nil {
 		return nil, fmt.Errorf("error when getting scalers %s", err)
@@ -105,9 +108,9 @@ func (p *KedaProvider) GetExternalMetric(ctx context.Context, namespace string,
 
 	scalerError := false
 
-	for scalerIndex, scaler := range cache.GetScalers() {
+	for scalerIndex, scaler := range scalersCache.GetScalers() {
 		metricSpecs := scaler.GetMetricSpecForScaling(ctx)
-		scalerName := strings.Replace(fmt.Sprintf("%T", scaler), "*scalers.", "", 1)
+		scalerName := getScalerName(scaler)
 
 		for _, metricSpec := range metricSpecs {
 			// skip cpu/memory resource scaler
@@ -116,11 +119,13 @@ func (p *KedaProvider) GetExternalMetric(ctx context.Context, namespace string,
 			}
 			// Filter only the desired metric
 			if strings.EqualFold(metricSpec.External.Metric.Name, info.Metric) {
-				metrics, err := cache.GetMetricsForScaler(ctx, scalerIndex, info.Metric, metricSelector)
+				metrics, err := scalersCache.GetMetricsForScaler(ctx, scaledObject, scalerIndex, info.Metric, metricSelector)
+				// the scalers of an open circuit aren't rebuilt, clearing the cache would also reset their circuit
+				circuitOpen := errors.Is(err, cache.ErrCircuitOpen)
 				metrics, err = p.getMetricsWithFallback(ctx, metrics, err, info.Metric, scaledObject, metricSpec)
 
 				if err != nil {
-					scalerError = true
+					scalerError = scalerError || !circuitOpen
 					logger.Error(err, "error getting metric for scaler", "scaledObject.Namespace", scaledObject.Namespace, "scaledObject.Name", scaledObject.Name, "scaler", scaler)
 				} else {
 					for _, metric := range metrics {
@@ -153,6 +158,12 @@ func (p *KedaProvider) GetExternalMetric(ctx context.Context, namespace string,
 	}, nil
 }
 
+// getScalerName returns the scaler label of the metrics of a scaler, the type of the scaler without the wrappers
+// of the scale handler, e.g. prometheusScaler
+func getScalerName(scaler scalers.Scaler) string {
+	return strings.Replace(fmt.Sprintf("%T", scalers.UnwrapScaler(scaler)), "*scalers.", "", 1)
+}
+
 // ListAllExternalMetrics returns the supported external metrics for this provider
 func (p *KedaProvider) ListAllExternalMetrics() []provider.ExternalMetricInfo {
 	logger.V(1).Info("KEDA Metrics Server received request for list of all provided external metrics names")
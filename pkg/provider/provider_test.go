@@ -0,0 +1,46 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/kedacore/keda/v2/pkg/scalers"
+)
+
+// wrappedScaler stands for the wrappers the scale handler puts around the scalers, e.g. for the valueExpression
+type wrappedScaler struct {
+	scalers.Scaler
+}
+
+func (s *wrappedScaler) Unwrap() scalers.Scaler {
+	return s.Scaler
+}
+
+var _ = Describe("scaler name", func() {
+	It("should name the scaler label after the type of the scaler", func() {
+		scaler, err := scalers.NewCronScaler(&scalers.ScalerConfig{
+			TriggerMetadata: map[string]string{"timezone": "Etc/UTC", "start": "0 0 * * Thu", "end": "59 23 * * Thu", "desiredReplicas": "10"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(getScalerName(scaler)).To(Equal("cronScaler"))
+		// the label of the existing series doesn't change when the scaler is wrapped
+		Expect(getScalerName(&wrappedScaler{Scaler: &wrappedScaler{Scaler: scaler}})).To(Equal("cronScaler"))
+	})
+})
@@ -65,7 +65,7 @@ func NewActiveMQScaler(config *ScalerConfig) (Scaler, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error parsing ActiveMQ metadata: %s", err)
 	}
-	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false)
+	httpClient := WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config)
 
 	return &activeMQScaler{
 		metricType: metricType,
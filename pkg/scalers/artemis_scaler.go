@@ -62,7 +62,7 @@ func NewArtemisQueueScaler(config *ScalerConfig) (Scaler, error) {
 	// do we need to guarantee this timeout for a specific
 	// reason? if not, we can have buildScaler pass in
 	// the global client
-	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false)
+	httpClient := WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config)
 
 	metricType, err := GetMetricTargetType(config)
 	if err != nil {
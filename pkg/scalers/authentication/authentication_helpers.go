@@ -14,19 +14,39 @@ import (
 	libs "github.com/dysnix/predictkube-libs/external/configs"
 	"github.com/dysnix/predictkube-libs/external/http_transport"
 
+	"github.com/kedacore/keda/v2/pkg/scalers/azure"
 	kedautil "github.com/kedacore/keda/v2/pkg/util"
 )
 
 const (
-	authModesKey = "authModes"
+	authModesKey     = "authModes"
+	azureResourceKey = "azureResource"
+	httpVersionKey   = "httpVersion"
+
+	// defaultAzureResource is the resource of Azure Monitor managed Prometheus
+	defaultAzureResource = "https://prometheus.monitor.azure.com"
+
+	azureTokenRequestTimeout = 30 * time.Second
 )
 
 func GetAuthConfigs(triggerMetadata, authParams map[string]string) (out *AuthMeta, err error) {
 	out = &AuthMeta{}
 
+	if val, ok := triggerMetadata[httpVersionKey]; ok && val != "" {
+		switch val {
+		case HTTPVersion11, HTTPVersion2:
+			out.HTTPVersion = val
+		default:
+			return nil, fmt.Errorf("err incorrect value for httpVersion is given: %s", val)
+		}
+	}
+
 	authModes, ok := triggerMetadata[authModesKey]
 	// no authMode specified
 	if !ok {
+		if out.HTTPVersion != "" {
+			return out, nil
+		}
 		return nil, nil
 	}
 
@@ -42,6 +62,9 @@ func GetAuthConfigs(triggerMetadata, authParams map[string]string) (out *AuthMet
 			if out.EnableBasicAuth {
 				return nil, errors.New("beare and basic authentication can not be set both")
 			}
+			if out.EnableAzureWorkloadIdentity {
+				return nil, errors.New("bearer and azure workload identity authentication can not be set both")
+			}
 
 			out.BearerToken = authParams["bearerToken"]
 			out.EnableBearerAuth = true
@@ -52,12 +75,25 @@ func GetAuthConfigs(triggerMetadata, authParams map[string]string) (out *AuthMet
 			if out.EnableBearerAuth {
 				return nil, errors.New("beare and basic authentication can not be set both")
 			}
+			if out.EnableAzureWorkloadIdentity {
+				return nil, errors.New("basic and azure workload identity authentication can not be set both")
+			}
 
 			out.Username = authParams["username"]
 			// password is optional. For convenience, many application implement basic auth with
 			// username as apikey and password as empty
 			out.Password = authParams["password"]
 			out.EnableBasicAuth = true
+		case AzureWorkloadIdentityAuthType:
+			if out.EnableBearerAuth || out.EnableBasicAuth {
+				return nil, errors.New("azure workload identity can not be set together with bearer or basic authentication")
+			}
+
+			out.AzureResource = defaultAzureResource
+			if val, ok := triggerMetadata[azureResourceKey]; ok && val != "" {
+				out.AzureResource = val
+			}
+			out.EnableAzureWorkloadIdentity = true
 		case TLSAuthType:
 			if len(authParams["cert"]) == 0 {
 				return nil, errors.New("no cert given")
@@ -83,7 +119,7 @@ func GetAuthConfigs(triggerMetadata, authParams map[string]string) (out *AuthMet
 }
 
 func CreateHTTPRoundTripper(roundTripperType TransportType, auth *AuthMeta, conf ...*HTTPTransport) (rt http.RoundTripper, err error) {
-	tlsConfig := &tls.Config{InsecureSkipVerify: false}
+	tlsConfig := &tls.Config{InsecureSkipVerify: auth != nil && auth.UnsafeSsl}
 	if auth != nil && (auth.CA != "" || auth.EnableTLS) {
 		tlsConfig, err = kedautil.NewTLSConfig(
 			auth.Cert,
@@ -97,20 +133,17 @@ func CreateHTTPRoundTripper(roundTripperType TransportType, auth *AuthMeta, conf
 
 	switch roundTripperType {
 	case NetHTTP:
-		// from official github.com/prometheus/client_golang/api package
-		return &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			TLSHandshakeTimeout: 10 * time.Second,
-			TLSClientConfig:     tlsConfig,
-		}, nil
+		rt = newNetHTTPTransport(auth, tlsConfig)
+
+		if auth != nil && auth.EnableAzureWorkloadIdentity {
+			rt = newAzureWorkloadIdentityRoundTripper(auth, rt)
+		}
+
+		return rt, nil
 	case FastHTTP:
-		// default configs
+		// default configs, the idle connections are closed by a cleaner sleeping for MaxIdleConnDuration
 		httpConf := &libs.HTTPTransport{
-			MaxIdleConnDuration: 10,
+			MaxIdleConnDuration: 10 * time.Second,
 			ReadTimeout:         time.Second * 15,
 			WriteTimeout:        time.Second * 15,
 		}
@@ -124,13 +157,17 @@ func CreateHTTPRoundTripper(roundTripperType TransportType, auth *AuthMeta, conf
 		}
 
 		var roundTripper http.RoundTripper
-		if roundTripper, err = http_transport.NewHttpTransport(
+		if auth != nil && auth.HTTPVersion == HTTPVersion2 {
+			// fasthttp only speaks HTTP/1.1, fall back to net/http when HTTP/2 is required
+			roundTripper = newNetHTTPTransport(auth, tlsConfig)
+		} else if roundTripper, err = http_transport.NewHttpTransport(
 			libs.SetTransportConfigs(httpConf),
 			libs.SetTLS(tlsConfig),
 		); err != nil {
 			return nil, fmt.Errorf("error creating fast http round tripper: %s", err)
 		}
 
+		rt = roundTripper
 		if auth != nil {
 			if auth.EnableBasicAuth {
 				rt = pConfig.NewBasicAuthRoundTripper(
@@ -147,8 +184,10 @@ func CreateHTTPRoundTripper(roundTripperType TransportType, auth *AuthMeta, conf
 					roundTripper,
 				)
 			}
-		} else {
-			rt = roundTripper
+
+			if auth.EnableAzureWorkloadIdentity {
+				rt = newAzureWorkloadIdentityRoundTripper(auth, roundTripper)
+			}
 		}
 
 		return rt, nil
@@ -156,3 +195,35 @@ func CreateHTTPRoundTripper(roundTripperType TransportType, auth *AuthMeta, conf
 
 	return rt, nil
 }
+
+// newNetHTTPTransport creates a net/http transport negotiating the HTTP version requested in auth
+func newNetHTTPTransport(auth *AuthMeta, tlsConfig *tls.Config) *http.Transport {
+	// from official github.com/prometheus/client_golang/api package
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsConfig,
+	}
+
+	if auth != nil {
+		switch auth.HTTPVersion {
+		case HTTPVersion11:
+			// a non-nil empty map disables HTTP/2 even if it's offered by the server
+			transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		case HTTPVersion2:
+			transport.ForceAttemptHTTP2 = true
+		}
+	}
+
+	return transport
+}
+
+// newAzureWorkloadIdentityRoundTripper wraps next with a round tripper authenticating with an AAD token for auth.AzureResource.
+// The token is requested with its own http client, so the token requests don't go through next
+func newAzureWorkloadIdentityRoundTripper(auth *AuthMeta, next http.RoundTripper) http.RoundTripper {
+	return azure.NewADWorkloadIdentityRoundTripper(auth.AzureResource, kedautil.CreateHTTPClient(azureTokenRequestTimeout, false), next)
+}
@@ -0,0 +1,130 @@
+package authentication
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetAuthConfigsHTTPVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		metadata    map[string]string
+		authParams  map[string]string
+		httpVersion string
+		isNil       bool
+		isError     bool
+	}{
+		{"nothing set", map[string]string{}, map[string]string{}, "", true, false},
+		{"http/1.1 without authModes", map[string]string{"httpVersion": "1.1"}, map[string]string{}, HTTPVersion11, false, false},
+		{"http/2 with bearer", map[string]string{"httpVersion": "2", "authModes": "bearer"}, map[string]string{"bearerToken": "token"}, HTTPVersion2, false, false},
+		{"invalid version", map[string]string{"httpVersion": "3"}, map[string]string{}, "", false, true},
+	}
+
+	for _, test := range tests {
+		auth, err := GetAuthConfigs(test.metadata, test.authParams)
+		if test.isError {
+			if err == nil {
+				t.Errorf("%s: expected error but got success", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected success but got error %s", test.name, err)
+			continue
+		}
+		if test.isNil {
+			if auth != nil {
+				t.Errorf("%s: expected no auth configs", test.name)
+			}
+			continue
+		}
+		if auth.HTTPVersion != test.httpVersion {
+			t.Errorf("%s: expected httpVersion %q, got %q", test.name, test.httpVersion, auth.HTTPVersion)
+		}
+	}
+}
+
+func TestCreateHTTPRoundTripperHTTPVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	tests := []struct {
+		name          string
+		transportType TransportType
+		httpVersion   string
+		expectedProto string
+	}{
+		{"net/http forced http/1.1", NetHTTP, HTTPVersion11, "HTTP/1.1"},
+		{"net/http forced http/2", NetHTTP, HTTPVersion2, "HTTP/2.0"},
+		{"fasthttp forced http/1.1", FastHTTP, HTTPVersion11, "HTTP/1.1"},
+		{"fasthttp forced http/2", FastHTTP, HTTPVersion2, "HTTP/2.0"},
+	}
+
+	for _, test := range tests {
+		rt, err := CreateHTTPRoundTripper(test.transportType, &AuthMeta{CA: ca, HTTPVersion: test.httpVersion})
+		if err != nil {
+			t.Errorf("%s: expected success but got error %s", test.name, err)
+			continue
+		}
+
+		client := &http.Client{Transport: rt}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Errorf("%s: expected success but got error %s", test.name, err)
+			continue
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Errorf("%s: expected success but got error %s", test.name, err)
+			continue
+		}
+
+		if string(body) != test.expectedProto {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expectedProto, string(body))
+		}
+	}
+}
+
+func TestCreateHTTPRoundTripperFastHTTPKeepsIdleConnections(t *testing.T) {
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	rt, err := CreateHTTPRoundTripper(FastHTTP, nil)
+	if err != nil {
+		t.Fatal("Could not create the round tripper:", err)
+	}
+	client := &http.Client{Transport: rt}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal("Expected success but got error", err)
+		}
+		resp.Body.Close()
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// the idle connection is reused rather than expired right away
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Errorf("Expected a single connection, got %d", n)
+	}
+}
@@ -14,6 +14,15 @@ const (
 	TLSAuthType Type = "tls"
 	// BearerAuthType is a auth type using a bearer token
 	BearerAuthType Type = "bearer"
+	// AzureWorkloadIdentityAuthType is a auth type using an AAD token acquired through azure workload identity
+	AzureWorkloadIdentityAuthType Type = "azureWorkloadIdentity"
+)
+
+const (
+	// HTTPVersion11 forces HTTP/1.1
+	HTTPVersion11 = "1.1"
+	// HTTPVersion2 forces HTTP/2
+	HTTPVersion2 = "2"
 )
 
 // TransportType is type of http transport
@@ -34,11 +43,21 @@ type AuthMeta struct {
 	Username        string
 	Password        string // +optional
 
+	// azure workload identity
+	EnableAzureWorkloadIdentity bool
+	AzureResource               string
+
 	// client certification
 	EnableTLS bool
 	Cert      string
 	Key       string
 	CA        string
+
+	// HTTPVersion is the HTTP version used with the server, empty negotiates it
+	HTTPVersion string
+
+	// UnsafeSsl skips the verification of the server certificate when no CA or client certificate is given
+	UnsafeSsl bool
 }
 
 type HTTPTransport struct {
@@ -0,0 +1,452 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	// awsAlbMetricRequestCountPerTarget scales on the number of requests per target of the target group
+	awsAlbMetricRequestCountPerTarget = "requestCountPerTarget"
+	// awsAlbMetricActiveConnectionCount scales on the active connections, or flows of a network load balancer,
+	// of the load balancer
+	awsAlbMetricActiveConnectionCount = "activeConnectionCount"
+	// awsAlbMetricTargetResponseTime scales on the p95 of the response time of the targets, in milliseconds
+	awsAlbMetricTargetResponseTime = "targetResponseTime"
+
+	awsAlbTypeApplication = "app"
+	awsAlbTypeNetwork     = "net"
+
+	defaultAwsAlbMetricStatPeriod     = 60
+	defaultAwsAlbMetricCollectionTime = 300
+)
+
+type awsAlbScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *awsAlbMetadata
+	cloudwatch *awsCloudwatchScaler
+}
+
+type awsAlbMetadata struct {
+	// the dimension values of CloudWatch, e.g. targetgroup/my-targets/73e2d6bc24d8a067 and app/my-lb/50dc6c495c0c9188
+	targetGroup      string
+	targetGroupARN   string
+	targetGroupName  string
+	loadBalancer     string
+	loadBalancerName string
+	loadBalancerType string
+
+	metric                string
+	targetValue           int64
+	activationTargetValue float64
+
+	metricCollectionTime int64
+	metricStatPeriod     int64
+	metricEndTimeOffset  int64
+
+	awsRegion        string
+	awsAuthorization awsAuthorizationMetadata
+
+	scalerIndex int
+}
+
+var awsAlbLog = logf.Log.WithName("aws_alb_scaler")
+
+// NewAwsAlbScaler creates a new awsAlbScaler, querying CloudWatch like the aws-cloudwatch scaler
+func NewAwsAlbScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseAwsAlbMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing aws alb metadata: %s", err)
+	}
+
+	if awsAlbNeedsLookup(meta) {
+		if err := lookupAwsAlbDimensions(meta, createAwsAlbElbv2Client(meta)); err != nil {
+			return nil, err
+		}
+	}
+
+	cwMeta, err := expandAwsAlbMetric(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return &awsAlbScaler{
+		metricType: metricType,
+		metadata:   meta,
+		cloudwatch: &awsCloudwatchScaler{metricType: metricType, metadata: cwMeta, cwClient: createCloudwatchClient(cwMeta)},
+	}, nil
+}
+
+func createAwsAlbElbv2Client(metadata *awsAlbMetadata) *elbv2.ELBV2 {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(metadata.awsRegion),
+	}))
+
+	if metadata.awsAuthorization.podIdentityOwner {
+		creds := credentials.NewStaticCredentials(metadata.awsAuthorization.awsAccessKeyID, metadata.awsAuthorization.awsSecretAccessKey, metadata.awsAuthorization.awsSessionToken)
+
+		if metadata.awsAuthorization.awsRoleArn != "" {
+			creds = stscreds.NewCredentials(sess, metadata.awsAuthorization.awsRoleArn)
+		}
+
+		return elbv2.New(sess, &aws.Config{
+			Region:      aws.String(metadata.awsRegion),
+			Credentials: creds,
+		})
+	}
+
+	return elbv2.New(sess, &aws.Config{
+		Region: aws.String(metadata.awsRegion),
+	})
+}
+
+func parseAwsAlbMetadata(config *ScalerConfig) (*awsAlbMetadata, error) {
+	var err error
+	meta := awsAlbMetadata{}
+
+	if val, ok := config.TriggerMetadata["targetGroupARN"]; ok && val != "" {
+		meta.targetGroup, meta.awsRegion, err = parseAwsAlbARN(val, "targetgroup/")
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetGroupARN: %s", err)
+		}
+		meta.targetGroupARN = val
+	}
+	meta.targetGroupName = config.TriggerMetadata["targetGroupName"]
+	if meta.targetGroup != "" && meta.targetGroupName != "" {
+		return nil, fmt.Errorf("targetGroupARN and targetGroupName can't both be set")
+	}
+
+	if val, ok := config.TriggerMetadata["loadBalancerARN"]; ok && val != "" {
+		var region string
+		meta.loadBalancer, region, err = parseAwsAlbARN(val, "loadbalancer/")
+		if err != nil {
+			return nil, fmt.Errorf("error parsing loadBalancerARN: %s", err)
+		}
+		if meta.awsRegion == "" {
+			meta.awsRegion = region
+		}
+		meta.loadBalancerType = strings.SplitN(meta.loadBalancer, "/", 2)[0]
+	}
+	meta.loadBalancerName = config.TriggerMetadata["loadBalancerName"]
+	if meta.loadBalancer != "" && meta.loadBalancerName != "" {
+		return nil, fmt.Errorf("loadBalancerARN and loadBalancerName can't both be set")
+	}
+
+	if meta.targetGroup == "" && meta.targetGroupName == "" && meta.loadBalancer == "" && meta.loadBalancerName == "" {
+		return nil, fmt.Errorf("no targetGroupARN, targetGroupName, loadBalancerARN or loadBalancerName given")
+	}
+
+	meta.metric = awsAlbMetricRequestCountPerTarget
+	if val, ok := config.TriggerMetadata["metric"]; ok && val != "" {
+		switch val {
+		case awsAlbMetricRequestCountPerTarget, awsAlbMetricActiveConnectionCount, awsAlbMetricTargetResponseTime:
+			meta.metric = val
+		default:
+			return nil, fmt.Errorf("metric must be %s, %s or %s, got %s", awsAlbMetricRequestCountPerTarget, awsAlbMetricActiveConnectionCount, awsAlbMetricTargetResponseTime, val)
+		}
+	}
+
+	// the target groups are the point of requestCountPerTarget, the load balancers the one of activeConnectionCount
+	switch meta.metric {
+	case awsAlbMetricRequestCountPerTarget:
+		if meta.targetGroup == "" && meta.targetGroupName == "" {
+			return nil, fmt.Errorf("%s requires targetGroupARN or targetGroupName", meta.metric)
+		}
+	case awsAlbMetricActiveConnectionCount:
+		if meta.loadBalancer == "" && meta.loadBalancerName == "" && meta.targetGroup == "" && meta.targetGroupName == "" {
+			return nil, fmt.Errorf("%s requires loadBalancerARN or loadBalancerName", meta.metric)
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["targetValue"]; ok && val != "" {
+		meta.targetValue, err = strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetValue: %s", err)
+		}
+		if meta.targetValue <= 0 {
+			return nil, fmt.Errorf("targetValue must be greater than 0")
+		}
+	} else {
+		return nil, fmt.Errorf("no targetValue given")
+	}
+
+	meta.activationTargetValue, err = getFloatMetadataValue(config.TriggerMetadata, "activationTargetValue", false, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	meta.metricStatPeriod, err = getIntMetadataValue(config.TriggerMetadata, "metricStatPeriod", false, defaultAwsAlbMetricStatPeriod)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkMetricStatPeriod(meta.metricStatPeriod); err != nil {
+		return nil, err
+	}
+
+	meta.metricCollectionTime, err = getIntMetadataValue(config.TriggerMetadata, "metricCollectionTime", false, defaultAwsAlbMetricCollectionTime)
+	if err != nil {
+		return nil, err
+	}
+	if meta.metricCollectionTime < 0 || meta.metricCollectionTime%meta.metricStatPeriod != 0 {
+		return nil, fmt.Errorf("metricCollectionTime must be greater than 0 and a multiple of metricStatPeriod(%d), %d is given", meta.metricStatPeriod, meta.metricCollectionTime)
+	}
+
+	meta.metricEndTimeOffset, err = getIntMetadataValue(config.TriggerMetadata, "metricEndTimeOffset", false, defaultMetricEndTimeOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	// the region of the ARNs by default
+	if val, ok := config.TriggerMetadata["awsRegion"]; ok && val != "" {
+		meta.awsRegion = val
+	}
+	if meta.awsRegion == "" {
+		return nil, fmt.Errorf("no awsRegion given")
+	}
+
+	meta.awsAuthorization, err = getAwsAuthorization(config.AuthParams, config.TriggerMetadata, config.ResolvedEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// parseAwsAlbARN returns the CloudWatch dimension value of the elasticloadbalancing ARN, its resource without
+// the prefix, and its region
+func parseAwsAlbARN(val, prefix string) (string, string, error) {
+	parsed, err := arn.Parse(val)
+	if err != nil {
+		return "", "", err
+	}
+	if parsed.Service != "elasticloadbalancing" || !strings.HasPrefix(parsed.Resource, prefix) {
+		return "", "", fmt.Errorf("%s isn't the ARN of an elasticloadbalancing %s", val, strings.TrimSuffix(prefix, "/"))
+	}
+	if prefix == "loadbalancer/" {
+		// the target groups have no type
+		return strings.TrimPrefix(parsed.Resource, prefix), parsed.Region, nil
+	}
+	return parsed.Resource, parsed.Region, nil
+}
+
+// awsAlbNeedsLookup returns whether the ELBv2 API has to resolve the names, or the load balancer of the target group
+// when the metric needs one
+func awsAlbNeedsLookup(meta *awsAlbMetadata) bool {
+	if meta.targetGroupName != "" || meta.loadBalancerName != "" {
+		return true
+	}
+	return meta.loadBalancer == "" && meta.metric != awsAlbMetricRequestCountPerTarget
+}
+
+// lookupAwsAlbDimensions resolves the target group and load balancer names into their dimension values, and the
+// load balancer of the target group when none is given but the metric needs one
+func lookupAwsAlbDimensions(meta *awsAlbMetadata, client elbv2iface.ELBV2API) error {
+	var targetGroupLoadBalancers []*string
+	if meta.targetGroupName != "" || (meta.targetGroup != "" && meta.loadBalancer == "" && meta.loadBalancerName == "") {
+		input := &elbv2.DescribeTargetGroupsInput{}
+		if meta.targetGroupName != "" {
+			input.Names = []*string{aws.String(meta.targetGroupName)}
+		} else {
+			input.TargetGroupArns = []*string{aws.String(meta.targetGroupARN)}
+		}
+		output, err := client.DescribeTargetGroups(input)
+		if err != nil {
+			return fmt.Errorf("error describing target group: %s", err)
+		}
+		if len(output.TargetGroups) != 1 {
+			return fmt.Errorf("expected a single target group, got %d", len(output.TargetGroups))
+		}
+		if meta.targetGroupName != "" {
+			meta.targetGroup, _, err = parseAwsAlbARN(aws.StringValue(output.TargetGroups[0].TargetGroupArn), "targetgroup/")
+			if err != nil {
+				return err
+			}
+		}
+		targetGroupLoadBalancers = output.TargetGroups[0].LoadBalancerArns
+	}
+
+	if meta.loadBalancerName != "" {
+		output, err := client.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{Names: []*string{aws.String(meta.loadBalancerName)}})
+		if err != nil {
+			return fmt.Errorf("error describing load balancer: %s", err)
+		}
+		if len(output.LoadBalancers) != 1 {
+			return fmt.Errorf("expected a single load balancer, got %d", len(output.LoadBalancers))
+		}
+		meta.loadBalancer, _, err = parseAwsAlbARN(aws.StringValue(output.LoadBalancers[0].LoadBalancerArn), "loadbalancer/")
+		if err != nil {
+			return err
+		}
+		meta.loadBalancerType = strings.SplitN(meta.loadBalancer, "/", 2)[0]
+	}
+
+	if meta.loadBalancer == "" && meta.metric != awsAlbMetricRequestCountPerTarget {
+		// a target group can be shared by several load balancers, their metrics aren't merged
+		if len(targetGroupLoadBalancers) != 1 {
+			return fmt.Errorf("%s requires a load balancer, the target group has %d, set loadBalancerARN or loadBalancerName", meta.metric, len(targetGroupLoadBalancers))
+		}
+		var err error
+		meta.loadBalancer, _, err = parseAwsAlbARN(aws.StringValue(targetGroupLoadBalancers[0]), "loadbalancer/")
+		if err != nil {
+			return err
+		}
+		meta.loadBalancerType = strings.SplitN(meta.loadBalancer, "/", 2)[0]
+	}
+
+	awsAlbLog.V(1).Info("Resolved the CloudWatch dimensions", "targetGroup", meta.targetGroup, "loadBalancer", meta.loadBalancer)
+	return nil
+}
+
+// expandAwsAlbMetric returns the CloudWatch query of the metric: its namespace, name, dimensions and statistic
+func expandAwsAlbMetric(meta *awsAlbMetadata) (*awsCloudwatchMetadata, error) {
+	cwMeta := &awsCloudwatchMetadata{
+		namespace:            "AWS/ApplicationELB",
+		metricCollectionTime: meta.metricCollectionTime,
+		metricStatPeriod:     meta.metricStatPeriod,
+		metricEndTimeOffset:  meta.metricEndTimeOffset,
+		awsRegion:            meta.awsRegion,
+		awsAuthorization:     meta.awsAuthorization,
+		scalerIndex:          meta.scalerIndex,
+	}
+	switch meta.loadBalancerType {
+	case "", awsAlbTypeApplication:
+	case awsAlbTypeNetwork:
+		cwMeta.namespace = "AWS/NetworkELB"
+	default:
+		return nil, fmt.Errorf("only application and network load balancers are supported, got %s", meta.loadBalancer)
+	}
+
+	switch meta.metric {
+	case awsAlbMetricRequestCountPerTarget:
+		if meta.loadBalancerType == awsAlbTypeNetwork {
+			return nil, fmt.Errorf("%s isn't a metric of network load balancers", meta.metric)
+		}
+		cwMeta.metricsName = "RequestCountPerTarget"
+		cwMeta.metricStat = cloudwatch.StatisticSum
+		cwMeta.dimensionName = []string{"TargetGroup"}
+		cwMeta.dimensionValue = []string{meta.targetGroup}
+		if meta.loadBalancer != "" {
+			cwMeta.dimensionName = append(cwMeta.dimensionName, "LoadBalancer")
+			cwMeta.dimensionValue = append(cwMeta.dimensionValue, meta.loadBalancer)
+		}
+	case awsAlbMetricActiveConnectionCount:
+		// the connections are counted by the load balancers, not by target group
+		cwMeta.dimensionName = []string{"LoadBalancer"}
+		cwMeta.dimensionValue = []string{meta.loadBalancer}
+		if meta.loadBalancerType == awsAlbTypeNetwork {
+			cwMeta.metricsName = "ActiveFlowCount"
+			cwMeta.metricStat = cloudwatch.StatisticAverage
+		} else {
+			cwMeta.metricsName = "ActiveConnectionCount"
+			cwMeta.metricStat = cloudwatch.StatisticSum
+		}
+	case awsAlbMetricTargetResponseTime:
+		if meta.loadBalancerType == awsAlbTypeNetwork {
+			return nil, fmt.Errorf("%s isn't a metric of network load balancers", meta.metric)
+		}
+		cwMeta.metricsName = "TargetResponseTime"
+		cwMeta.metricStat = "p95"
+		cwMeta.dimensionName = []string{"LoadBalancer"}
+		cwMeta.dimensionValue = []string{meta.loadBalancer}
+		if meta.targetGroup != "" {
+			cwMeta.dimensionName = append(cwMeta.dimensionName, "TargetGroup")
+			cwMeta.dimensionValue = append(cwMeta.dimensionValue, meta.targetGroup)
+		}
+	}
+
+	if len(cwMeta.dimensionValue) == 0 || cwMeta.dimensionValue[0] == "" {
+		return nil, fmt.Errorf("%s requires a %s", meta.metric, cwMeta.dimensionName[0])
+	}
+	return cwMeta, nil
+}
+
+// IsActive checks if the value of the metric is above the activation target
+func (s *awsAlbScaler) IsActive(ctx context.Context) (bool, error) {
+	value, err := s.getMetricValue()
+	if err != nil {
+		return false, err
+	}
+
+	return value > s.metadata.activationTargetValue, nil
+}
+
+func (s *awsAlbScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *awsAlbScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	name := s.metadata.targetGroup
+	if name == "" || s.metadata.metric == awsAlbMetricActiveConnectionCount {
+		name = s.metadata.loadBalancer
+	}
+	// the resources are named <type>/<name>/<id>
+	if parts := strings.Split(name, "/"); len(parts) == 3 {
+		name = parts[1]
+	}
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("aws-alb-%s-%s", s.metadata.metric, name))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the value of the metric
+func (s *awsAlbScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	value, err := s.getMetricValue()
+	if err != nil {
+		awsAlbLog.Error(err, "Error getting metric value")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getMetricValue returns the last value of the metric, 0 without data, the response time in milliseconds
+func (s *awsAlbScaler) getMetricValue() (float64, error) {
+	value, err := s.cloudwatch.GetCloudwatchMetrics()
+	if err != nil {
+		return 0, err
+	}
+	if s.metadata.metric == awsAlbMetricTargetResponseTime {
+		// CloudWatch has it in seconds
+		value *= 1000
+	}
+	return value, nil
+}
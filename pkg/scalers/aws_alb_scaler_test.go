@@ -0,0 +1,398 @@
+package scalers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testAWSAlbTargetGroupARN  = "arn:aws:elasticloadbalancing:eu-west-1:123456789012:targetgroup/my-targets/73e2d6bc24d8a067"
+	testAWSAlbLoadBalancerARN = "arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/app/my-lb/50dc6c495c0c9188"
+	testAWSNlbLoadBalancerARN = "arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/net/my-nlb/2c9e4ad5e5a0ef1b"
+)
+
+type parseAWSAlbMetadataTestData struct {
+	metadata map[string]string
+	isError  bool
+	comment  string
+}
+
+var testAWSAlbMetadata = []parseAWSAlbMetadataTestData{
+	{map[string]string{}, true, "empty metadata"},
+	{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "targetValue": "100"}, false, "target group ARN"},
+	{map[string]string{"targetGroupName": "my-targets", "awsRegion": "eu-west-1", "targetValue": "100"}, false, "target group name"},
+	{map[string]string{"targetGroupName": "my-targets", "targetValue": "100"}, true, "target group name without awsRegion"},
+	{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "targetGroupName": "my-targets", "targetValue": "100"}, true, "both target group ARN and name"},
+	{map[string]string{"targetGroupARN": testAWSAlbLoadBalancerARN, "targetValue": "100"}, true, "load balancer ARN as targetGroupARN"},
+	{map[string]string{"targetGroupARN": "my-targets", "targetValue": "100"}, true, "invalid targetGroupARN"},
+	{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN}, true, "missing targetValue"},
+	{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "targetValue": "0"}, true, "zero targetValue"},
+	{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "targetValue": "a"}, true, "invalid targetValue"},
+	{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "targetValue": "100", "activationTargetValue": "1.5"}, false, "activationTargetValue"},
+	{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "targetValue": "100", "activationTargetValue": "a"}, true, "invalid activationTargetValue"},
+	{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "targetValue": "100", "metric": "latency"}, true, "unknown metric"},
+	{map[string]string{"loadBalancerARN": testAWSAlbLoadBalancerARN, "targetValue": "100"}, true, "requestCountPerTarget without target group"},
+	{map[string]string{"loadBalancerARN": testAWSAlbLoadBalancerARN, "targetValue": "100", "metric": "activeConnectionCount"}, false, "activeConnectionCount of a load balancer ARN"},
+	{map[string]string{"loadBalancerARN": testAWSAlbLoadBalancerARN, "loadBalancerName": "my-lb", "targetValue": "100", "metric": "activeConnectionCount"}, true, "both load balancer ARN and name"},
+	{map[string]string{"loadBalancerARN": testAWSAlbTargetGroupARN, "targetValue": "100", "metric": "activeConnectionCount"}, true, "target group ARN as loadBalancerARN"},
+	{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "loadBalancerARN": testAWSAlbLoadBalancerARN, "targetValue": "100", "metric": "targetResponseTime"}, false, "targetResponseTime"},
+	{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "targetValue": "100", "metricStatPeriod": "300", "metricCollectionTime": "600"}, false, "metricStatPeriod and metricCollectionTime"},
+	{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "targetValue": "100", "metricStatPeriod": "60", "metricCollectionTime": "90"}, true, "metricCollectionTime not a multiple of metricStatPeriod"},
+}
+
+func TestAWSAlbParseMetadata(t *testing.T) {
+	for _, testData := range testAWSAlbMetadata {
+		_, err := parseAwsAlbMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, ResolvedEnv: testAWSCloudwatchResolvedEnv, AuthParams: testAWSAuthentication})
+		if err != nil && !testData.isError {
+			t.Errorf("%s: Expected success but got error %s", testData.comment, err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("%s: Expected error but got success", testData.comment)
+		}
+	}
+}
+
+func TestAWSAlbParseMetadataRegion(t *testing.T) {
+	meta, err := parseAwsAlbMetadata(&ScalerConfig{TriggerMetadata: map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "targetValue": "100"}, ResolvedEnv: testAWSCloudwatchResolvedEnv, AuthParams: testAWSAuthentication})
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west-1", meta.awsRegion)
+	assert.Equal(t, "targetgroup/my-targets/73e2d6bc24d8a067", meta.targetGroup)
+
+	meta, err = parseAwsAlbMetadata(&ScalerConfig{TriggerMetadata: map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "targetValue": "100", "awsRegion": "us-east-1"}, ResolvedEnv: testAWSCloudwatchResolvedEnv, AuthParams: testAWSAuthentication})
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1", meta.awsRegion)
+}
+
+type awsAlbExpansionTestData struct {
+	comment        string
+	metadata       map[string]string
+	isError        bool
+	namespace      string
+	metricName     string
+	dimensionName  []string
+	dimensionValue []string
+	stat           string
+}
+
+var testAWSAlbExpansions = []awsAlbExpansionTestData{
+	{
+		comment:        "requestCountPerTarget of a target group",
+		metadata:       map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN},
+		namespace:      "AWS/ApplicationELB",
+		metricName:     "RequestCountPerTarget",
+		dimensionName:  []string{"TargetGroup"},
+		dimensionValue: []string{"targetgroup/my-targets/73e2d6bc24d8a067"},
+		stat:           "Sum",
+	},
+	{
+		comment:        "requestCountPerTarget of a target group behind a load balancer",
+		metadata:       map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "loadBalancerARN": testAWSAlbLoadBalancerARN},
+		namespace:      "AWS/ApplicationELB",
+		metricName:     "RequestCountPerTarget",
+		dimensionName:  []string{"TargetGroup", "LoadBalancer"},
+		dimensionValue: []string{"targetgroup/my-targets/73e2d6bc24d8a067", "app/my-lb/50dc6c495c0c9188"},
+		stat:           "Sum",
+	},
+	{
+		comment:  "requestCountPerTarget of a network load balancer",
+		metadata: map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "loadBalancerARN": testAWSNlbLoadBalancerARN},
+		isError:  true,
+	},
+	{
+		comment:        "activeConnectionCount of an application load balancer",
+		metadata:       map[string]string{"metric": "activeConnectionCount", "loadBalancerARN": testAWSAlbLoadBalancerARN},
+		namespace:      "AWS/ApplicationELB",
+		metricName:     "ActiveConnectionCount",
+		dimensionName:  []string{"LoadBalancer"},
+		dimensionValue: []string{"app/my-lb/50dc6c495c0c9188"},
+		stat:           "Sum",
+	},
+	{
+		comment:        "activeConnectionCount of a network load balancer",
+		metadata:       map[string]string{"metric": "activeConnectionCount", "loadBalancerARN": testAWSNlbLoadBalancerARN},
+		namespace:      "AWS/NetworkELB",
+		metricName:     "ActiveFlowCount",
+		dimensionName:  []string{"LoadBalancer"},
+		dimensionValue: []string{"net/my-nlb/2c9e4ad5e5a0ef1b"},
+		stat:           "Average",
+	},
+	{
+		comment:        "targetResponseTime of a target group",
+		metadata:       map[string]string{"metric": "targetResponseTime", "targetGroupARN": testAWSAlbTargetGroupARN, "loadBalancerARN": testAWSAlbLoadBalancerARN},
+		namespace:      "AWS/ApplicationELB",
+		metricName:     "TargetResponseTime",
+		dimensionName:  []string{"LoadBalancer", "TargetGroup"},
+		dimensionValue: []string{"app/my-lb/50dc6c495c0c9188", "targetgroup/my-targets/73e2d6bc24d8a067"},
+		stat:           "p95",
+	},
+	{
+		comment:        "targetResponseTime of a load balancer",
+		metadata:       map[string]string{"metric": "targetResponseTime", "loadBalancerARN": testAWSAlbLoadBalancerARN},
+		namespace:      "AWS/ApplicationELB",
+		metricName:     "TargetResponseTime",
+		dimensionName:  []string{"LoadBalancer"},
+		dimensionValue: []string{"app/my-lb/50dc6c495c0c9188"},
+		stat:           "p95",
+	},
+	{
+		comment:  "targetResponseTime of a network load balancer",
+		metadata: map[string]string{"metric": "targetResponseTime", "loadBalancerARN": testAWSNlbLoadBalancerARN},
+		isError:  true,
+	},
+	{
+		comment:  "gateway load balancer",
+		metadata: map[string]string{"metric": "activeConnectionCount", "loadBalancerARN": "arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/gwy/my-gwy/3a2b1c"},
+		isError:  true,
+	},
+}
+
+func TestAWSAlbExpandMetric(t *testing.T) {
+	for _, testData := range testAWSAlbExpansions {
+		metadata := map[string]string{"targetValue": "100"}
+		for k, v := range testData.metadata {
+			metadata[k] = v
+		}
+		meta, err := parseAwsAlbMetadata(&ScalerConfig{TriggerMetadata: metadata, ResolvedEnv: testAWSCloudwatchResolvedEnv, AuthParams: testAWSAuthentication})
+		if err != nil {
+			t.Fatalf("%s: Could not parse metadata: %s", testData.comment, err)
+		}
+
+		cwMeta, err := expandAwsAlbMetric(meta)
+		if testData.isError {
+			assert.Error(t, err, testData.comment)
+			continue
+		}
+		assert.NoError(t, err, testData.comment)
+		assert.Equal(t, testData.namespace, cwMeta.namespace, testData.comment)
+		assert.Equal(t, testData.metricName, cwMeta.metricsName, testData.comment)
+		assert.Equal(t, testData.dimensionName, cwMeta.dimensionName, testData.comment)
+		assert.Equal(t, testData.dimensionValue, cwMeta.dimensionValue, testData.comment)
+		assert.Equal(t, testData.stat, cwMeta.metricStat, testData.comment)
+		assert.Equal(t, "eu-west-1", cwMeta.awsRegion, testData.comment)
+		assert.Equal(t, int64(defaultAwsAlbMetricStatPeriod), cwMeta.metricStatPeriod, testData.comment)
+	}
+}
+
+type mockAlbElbv2 struct {
+	elbv2iface.ELBV2API
+	loadBalancerArns []*string
+}
+
+func (m *mockAlbElbv2) DescribeTargetGroups(input *elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error) {
+	if len(input.Names) > 0 && *input.Names[0] != "my-targets" {
+		return nil, errors.New("TargetGroupNotFound")
+	}
+	if len(input.TargetGroupArns) > 0 && *input.TargetGroupArns[0] != testAWSAlbTargetGroupARN {
+		return nil, errors.New("TargetGroupNotFound")
+	}
+	return &elbv2.DescribeTargetGroupsOutput{
+		TargetGroups: []*elbv2.TargetGroup{
+			{
+				TargetGroupArn:   aws.String(testAWSAlbTargetGroupARN),
+				LoadBalancerArns: m.loadBalancerArns,
+			},
+		},
+	}, nil
+}
+
+func (m *mockAlbElbv2) DescribeLoadBalancers(input *elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error) {
+	switch *input.Names[0] {
+	case "my-lb":
+		return &elbv2.DescribeLoadBalancersOutput{LoadBalancers: []*elbv2.LoadBalancer{{LoadBalancerArn: aws.String(testAWSAlbLoadBalancerARN)}}}, nil
+	case "my-nlb":
+		return &elbv2.DescribeLoadBalancersOutput{LoadBalancers: []*elbv2.LoadBalancer{{LoadBalancerArn: aws.String(testAWSNlbLoadBalancerARN)}}}, nil
+	}
+	return nil, errors.New("LoadBalancerNotFound")
+}
+
+func TestAWSAlbLookupDimensions(t *testing.T) {
+	tests := []struct {
+		comment          string
+		metadata         map[string]string
+		loadBalancerArns []*string
+		isError          bool
+		targetGroup      string
+		loadBalancer     string
+	}{
+		{
+			comment:     "target group name",
+			metadata:    map[string]string{"targetGroupName": "my-targets"},
+			targetGroup: "targetgroup/my-targets/73e2d6bc24d8a067",
+		},
+		{
+			comment:  "unknown target group name",
+			metadata: map[string]string{"targetGroupName": "other"},
+			isError:  true,
+		},
+		{
+			comment:      "load balancer name",
+			metadata:     map[string]string{"metric": "activeConnectionCount", "loadBalancerName": "my-nlb"},
+			loadBalancer: "net/my-nlb/2c9e4ad5e5a0ef1b",
+		},
+		{
+			comment:          "load balancer of the target group",
+			metadata:         map[string]string{"metric": "targetResponseTime", "targetGroupARN": testAWSAlbTargetGroupARN},
+			loadBalancerArns: []*string{aws.String(testAWSAlbLoadBalancerARN)},
+			targetGroup:      "targetgroup/my-targets/73e2d6bc24d8a067",
+			loadBalancer:     "app/my-lb/50dc6c495c0c9188",
+		},
+		{
+			comment:          "target group behind several load balancers",
+			metadata:         map[string]string{"metric": "activeConnectionCount", "targetGroupName": "my-targets"},
+			loadBalancerArns: []*string{aws.String(testAWSAlbLoadBalancerARN), aws.String(testAWSNlbLoadBalancerARN)},
+			isError:          true,
+		},
+		{
+			comment:          "load balancer name with a target group name",
+			metadata:         map[string]string{"metric": "targetResponseTime", "targetGroupName": "my-targets", "loadBalancerName": "my-lb"},
+			loadBalancerArns: []*string{aws.String(testAWSAlbLoadBalancerARN), aws.String(testAWSNlbLoadBalancerARN)},
+			targetGroup:      "targetgroup/my-targets/73e2d6bc24d8a067",
+			loadBalancer:     "app/my-lb/50dc6c495c0c9188",
+		},
+	}
+
+	for _, test := range tests {
+		metadata := map[string]string{"targetValue": "100", "awsRegion": "eu-west-1"}
+		for k, v := range test.metadata {
+			metadata[k] = v
+		}
+		meta, err := parseAwsAlbMetadata(&ScalerConfig{TriggerMetadata: metadata, ResolvedEnv: testAWSCloudwatchResolvedEnv, AuthParams: testAWSAuthentication})
+		if err != nil {
+			t.Fatalf("%s: Could not parse metadata: %s", test.comment, err)
+		}
+		assert.True(t, awsAlbNeedsLookup(meta), test.comment)
+
+		err = lookupAwsAlbDimensions(meta, &mockAlbElbv2{loadBalancerArns: test.loadBalancerArns})
+		if test.isError {
+			assert.Error(t, err, test.comment)
+			continue
+		}
+		assert.NoError(t, err, test.comment)
+		assert.Equal(t, test.targetGroup, meta.targetGroup, test.comment)
+		assert.Equal(t, test.loadBalancer, meta.loadBalancer, test.comment)
+	}
+}
+
+type mockAlbCloudwatch struct {
+	cloudwatchiface.CloudWatchAPI
+	input *cloudwatch.GetMetricDataInput
+	value *float64
+}
+
+func (m *mockAlbCloudwatch) GetMetricData(input *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error) {
+	m.input = input
+	if m.value == nil {
+		return &cloudwatch.GetMetricDataOutput{MetricDataResults: []*cloudwatch.MetricDataResult{}}, nil
+	}
+	return &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []*cloudwatch.MetricDataResult{{Values: []*float64{m.value}}},
+	}, nil
+}
+
+func TestAWSAlbScalerGetMetrics(t *testing.T) {
+	tests := []struct {
+		comment  string
+		metadata map[string]string
+		value    *float64
+		expected int64
+		isActive bool
+	}{
+		{
+			comment:  "requestCountPerTarget",
+			metadata: map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN},
+			value:    aws.Float64(250),
+			expected: 250000,
+			isActive: true,
+		},
+		{
+			comment:  "no data",
+			metadata: map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN},
+			expected: 0,
+			isActive: false,
+		},
+		{
+			comment:  "below the activation target",
+			metadata: map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN, "activationTargetValue": "5"},
+			value:    aws.Float64(5),
+			expected: 5000,
+			isActive: false,
+		},
+		{
+			comment:  "targetResponseTime in milliseconds",
+			metadata: map[string]string{"metric": "targetResponseTime", "loadBalancerARN": testAWSAlbLoadBalancerARN, "activationTargetValue": "100"},
+			value:    aws.Float64(0.1234),
+			expected: 123400,
+			isActive: true,
+		},
+	}
+
+	for _, test := range tests {
+		metadata := map[string]string{"targetValue": "100"}
+		for k, v := range test.metadata {
+			metadata[k] = v
+		}
+		meta, err := parseAwsAlbMetadata(&ScalerConfig{TriggerMetadata: metadata, ResolvedEnv: testAWSCloudwatchResolvedEnv, AuthParams: testAWSAuthentication})
+		if err != nil {
+			t.Fatalf("%s: Could not parse metadata: %s", test.comment, err)
+		}
+		cwMeta, err := expandAwsAlbMetric(meta)
+		if err != nil {
+			t.Fatalf("%s: Could not expand metric: %s", test.comment, err)
+		}
+		client := &mockAlbCloudwatch{value: test.value}
+		scaler := awsAlbScaler{"", meta, &awsCloudwatchScaler{"", cwMeta, client}}
+
+		metrics, err := scaler.GetMetrics(context.Background(), "s0-aws-alb", nil)
+		assert.NoError(t, err, test.comment)
+		assert.Equal(t, test.expected, metrics[0].Value.MilliValue(), test.comment)
+
+		query := client.input.MetricDataQueries[0].MetricStat
+		assert.Equal(t, cwMeta.namespace, *query.Metric.Namespace, test.comment)
+		assert.Equal(t, cwMeta.metricsName, *query.Metric.MetricName, test.comment)
+		assert.Equal(t, cwMeta.metricStat, *query.Stat, test.comment)
+		assert.Len(t, query.Metric.Dimensions, len(cwMeta.dimensionName), test.comment)
+		for i, dimension := range query.Metric.Dimensions {
+			assert.Equal(t, cwMeta.dimensionName[i], *dimension.Name, test.comment)
+			assert.Equal(t, cwMeta.dimensionValue[i], *dimension.Value, test.comment)
+		}
+
+		isActive, err := scaler.IsActive(context.Background())
+		assert.NoError(t, err, test.comment)
+		assert.Equal(t, test.isActive, isActive, test.comment)
+	}
+}
+
+func TestAWSAlbGetMetricSpecForScaling(t *testing.T) {
+	tests := []struct {
+		metadata    map[string]string
+		scalerIndex int
+		name        string
+	}{
+		{map[string]string{"targetGroupARN": testAWSAlbTargetGroupARN}, 0, "s0-aws-alb-requestCountPerTarget-my-targets"},
+		{map[string]string{"metric": "activeConnectionCount", "targetGroupARN": testAWSAlbTargetGroupARN, "loadBalancerARN": testAWSNlbLoadBalancerARN}, 1, "s1-aws-alb-activeConnectionCount-my-nlb"},
+		{map[string]string{"metric": "targetResponseTime", "loadBalancerARN": testAWSAlbLoadBalancerARN}, 2, "s2-aws-alb-targetResponseTime-my-lb"},
+	}
+
+	for _, test := range tests {
+		metadata := map[string]string{"targetValue": "100"}
+		for k, v := range test.metadata {
+			metadata[k] = v
+		}
+		meta, err := parseAwsAlbMetadata(&ScalerConfig{TriggerMetadata: metadata, ResolvedEnv: testAWSCloudwatchResolvedEnv, AuthParams: testAWSAuthentication, ScalerIndex: test.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		scaler := awsAlbScaler{"", meta, nil}
+
+		metricSpec := scaler.GetMetricSpecForScaling(context.Background())
+		assert.Equal(t, test.name, metricSpec[0].External.Metric.Name)
+	}
+}
@@ -0,0 +1,169 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	// Environment variables injected into the pod by the Azure AD workload identity webhook
+	azureClientIDEnv           = "AZURE_CLIENT_ID"
+	azureTenantIDEnv           = "AZURE_TENANT_ID"
+	azureFederatedTokenFileEnv = "AZURE_FEDERATED_TOKEN_FILE"
+	azureAuthorityHostEnv      = "AZURE_AUTHORITY_HOST"
+
+	defaultAzureAuthorityHost = "https://login.microsoftonline.com/"
+
+	// workloadIdentityTokenRefreshMargin is how long before its expiry a token is refreshed
+	workloadIdentityTokenRefreshMargin = 5 * time.Minute
+)
+
+// GetAzureADWorkloadIdentityToken exchanges the federated token of the pod for an AADToken for resource
+func GetAzureADWorkloadIdentityToken(ctx context.Context, httpClient util.HTTPDoer, resource string) (AADToken, error) {
+	var token AADToken
+
+	clientID := os.Getenv(azureClientIDEnv)
+	tenantID := os.Getenv(azureTenantIDEnv)
+	tokenFile := os.Getenv(azureFederatedTokenFileEnv)
+	if clientID == "" || tenantID == "" || tokenFile == "" {
+		return token, fmt.Errorf("%s, %s and %s must be set to use azure workload identity", azureClientIDEnv, azureTenantIDEnv, azureFederatedTokenFileEnv)
+	}
+
+	authorityHost := os.Getenv(azureAuthorityHostEnv)
+	if authorityHost == "" {
+		authorityHost = defaultAzureAuthorityHost
+	}
+
+	// the federated token is rotated by the kubelet, so it is read again for every exchange
+	assertion, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return token, fmt.Errorf("error reading federated token: %s", err)
+	}
+
+	data := url.Values{}
+	data.Set("client_id", clientID)
+	data.Set("grant_type", "client_credentials")
+	data.Set("scope", strings.TrimSuffix(resource, "/")+"/.default")
+	data.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	data.Set("client_assertion", strings.TrimSpace(string(assertion)))
+
+	urlStr := fmt.Sprintf("%s%s/oauth2/v2.0/token", strings.TrimSuffix(authorityHost, "/")+"/", tenantID)
+	req, err := http.NewRequestWithContext(ctx, "POST", urlStr, strings.NewReader(data.Encode()))
+	if err != nil {
+		return token, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return token, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return token, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return token, fmt.Errorf("azure AD returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	// unlike the MSI endpoint, the v2 token endpoint returns expires_in as a number
+	var response struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return token, fmt.Errorf("error parsing azure AD token response: %s", err)
+	}
+
+	token.AccessToken = response.AccessToken
+	token.TokenType = response.TokenType
+	token.Resource = resource
+	token.ExpiresIn = strconv.FormatInt(response.ExpiresIn, 10)
+	token.ExpiresOn = strconv.FormatInt(time.Now().Add(time.Duration(response.ExpiresIn)*time.Second).Unix(), 10)
+
+	return token, nil
+}
+
+// ADWorkloadIdentityTokenError is returned by the workload identity round tripper when no token could be
+// acquired, so it can be told apart from a failure of the request itself
+type ADWorkloadIdentityTokenError struct {
+	Err error
+}
+
+func (e *ADWorkloadIdentityTokenError) Error() string {
+	return fmt.Sprintf("error acquiring azure workload identity token: %s", e.Err)
+}
+
+func (e *ADWorkloadIdentityTokenError) Unwrap() error {
+	return e.Err
+}
+
+type workloadIdentityRoundTripper struct {
+	next       http.RoundTripper
+	httpClient util.HTTPDoer
+	resource   string
+
+	lock      sync.Mutex
+	token     string
+	expiresOn time.Time
+}
+
+// NewADWorkloadIdentityRoundTripper returns a round tripper which authenticates the requests with an AAD token
+// for resource. The token is acquired on the first request and refreshed shortly before it expires
+func NewADWorkloadIdentityRoundTripper(resource string, httpClient util.HTTPDoer, next http.RoundTripper) http.RoundTripper {
+	return &workloadIdentityRoundTripper{
+		next:       next,
+		httpClient: httpClient,
+		resource:   resource,
+	}
+}
+
+func (rt *workloadIdentityRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := rt.getToken(req.Context())
+	if err != nil {
+		return nil, &ADWorkloadIdentityTokenError{Err: err}
+	}
+
+	// a RoundTripper must not modify the given request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return rt.next.RoundTrip(req)
+}
+
+func (rt *workloadIdentityRoundTripper) getToken(ctx context.Context) (string, error) {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+
+	if rt.token != "" && time.Until(rt.expiresOn) > workloadIdentityTokenRefreshMargin {
+		return rt.token, nil
+	}
+
+	token, err := GetAzureADWorkloadIdentityToken(ctx, rt.httpClient, rt.resource)
+	if err != nil {
+		return "", err
+	}
+
+	expiresOn, err := strconv.ParseInt(token.ExpiresOn, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("error parsing token expiry: %s", err)
+	}
+
+	rt.token = token.AccessToken
+	rt.expiresOn = time.Unix(expiresOn, 0)
+	return rt.token, nil
+}
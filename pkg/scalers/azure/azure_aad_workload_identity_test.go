@@ -0,0 +1,109 @@
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func setWorkloadIdentityEnv(t *testing.T, authorityHost string) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("federated-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(azureClientIDEnv, "client-id")
+	t.Setenv(azureTenantIDEnv, "tenant-id")
+	t.Setenv(azureFederatedTokenFileEnv, tokenFile)
+	t.Setenv(azureAuthorityHostEnv, authorityHost)
+}
+
+func TestWorkloadIdentityRoundTripper(t *testing.T) {
+	var tokenRequests int
+	expiresIn := 3600
+	aad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		if r.URL.Path != "/tenant-id/oauth2/v2.0/token" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.Form.Get("client_assertion") != "federated-token" {
+			t.Errorf("unexpected client_assertion %s", r.Form.Get("client_assertion"))
+		}
+		if r.Form.Get("scope") != "https://prometheus.monitor.azure.com/.default" {
+			t.Errorf("unexpected scope %s", r.Form.Get("scope"))
+		}
+		_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": %d, "token_type": "Bearer"}`, tokenRequests, expiresIn)
+	}))
+	defer aad.Close()
+	setWorkloadIdentityEnv(t, aad.URL)
+
+	var authorization string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer backend.Close()
+
+	client := &http.Client{Transport: NewADWorkloadIdentityRoundTripper("https://prometheus.monitor.azure.com", http.DefaultClient, http.DefaultTransport)}
+	if tokenRequests != 0 {
+		t.Fatal("token must not be acquired before the first request")
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(backend.URL)
+		if err != nil {
+			t.Fatal("Expected success but got error", err)
+		}
+		resp.Body.Close()
+	}
+	if tokenRequests != 1 {
+		t.Errorf("Expected the token to be reused, got %d token requests", tokenRequests)
+	}
+	if authorization != "Bearer token-1" {
+		t.Errorf("Unexpected authorization header %s", authorization)
+	}
+
+	// tokens close to their expiry are refreshed
+	expiresIn = 60
+	client = &http.Client{Transport: NewADWorkloadIdentityRoundTripper("https://prometheus.monitor.azure.com", http.DefaultClient, http.DefaultTransport)}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(backend.URL)
+		if err != nil {
+			t.Fatal("Expected success but got error", err)
+		}
+		resp.Body.Close()
+	}
+	if tokenRequests != 3 {
+		t.Errorf("Expected the token to be refreshed, got %d token requests", tokenRequests)
+	}
+	if authorization != "Bearer token-3" {
+		t.Errorf("Unexpected authorization header %s", authorization)
+	}
+}
+
+func TestWorkloadIdentityRoundTripperTokenError(t *testing.T) {
+	aad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": "invalid_client"}`))
+	}))
+	defer aad.Close()
+	setWorkloadIdentityEnv(t, aad.URL)
+
+	rt := NewADWorkloadIdentityRoundTripper("https://prometheus.monitor.azure.com", http.DefaultClient, http.DefaultTransport)
+	req, err := http.NewRequest("GET", "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = rt.RoundTrip(req)
+	var tokenErr *ADWorkloadIdentityTokenError
+	if !errors.As(err, &tokenErr) {
+		t.Errorf("Expected a token error, got %v", err)
+	}
+}
@@ -67,7 +67,7 @@ func NewAzureBlobScaler(config *ScalerConfig) (Scaler, error) {
 		metricType:  metricType,
 		metadata:    meta,
 		podIdentity: podIdentity,
-		httpClient:  kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false),
+		httpClient:  WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config),
 	}, nil
 }
 
@@ -144,11 +144,11 @@ func parseAzureBlobMetadata(config *ScalerConfig) (*azure.BlobMetadata, kedav1al
 	case "", kedav1alpha1.PodIdentityProviderNone:
 		// Azure Blob Scaler expects a "connection" parameter in the metadata
 		// of the scaler or in a TriggerAuthentication object
-		if config.AuthParams["connection"] != "" {
-			meta.Connection = config.AuthParams["connection"]
-		} else if config.TriggerMetadata["connectionFromEnv"] != "" {
-			meta.Connection = config.ResolvedEnv[config.TriggerMetadata["connectionFromEnv"]]
+		connection, err := getFromAuthOrEnv(config, "connection")
+		if err != nil {
+			return nil, "", err
 		}
+		meta.Connection = connection
 
 		if len(meta.Connection) == 0 {
 			return nil, "", fmt.Errorf("no connection setting given")
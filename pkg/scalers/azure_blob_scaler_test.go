@@ -124,3 +124,14 @@ func TestAzBlobGetMetricSpecForScaling(t *testing.T) {
 		}
 	}
 }
+
+func TestParseAzBlobMetadataConnectionSources(t *testing.T) {
+	metadata := map[string]string{"blobContainerName": "sample", "blobCount": "5"}
+	testParseSecretFromEnvSources(t, "connection", metadata, nil, [3]string{"AccountName=inline", "AccountName=env", "AccountName=auth"}, func(config *ScalerConfig) (string, error) {
+		meta, _, err := parseAzureBlobMetadata(config)
+		if err != nil {
+			return "", err
+		}
+		return meta.Connection, nil
+	})
+}
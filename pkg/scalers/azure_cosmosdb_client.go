@@ -0,0 +1,340 @@
+package scalers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/azure"
+)
+
+const (
+	cosmosDBAPIVersion = "2018-12-31"
+
+	// cosmosDBTokenRefreshMargin is how long before its expiry an AAD token is refreshed
+	cosmosDBTokenRefreshMargin = 5 * time.Minute
+)
+
+// errCosmosDBPartitionGone is returned when reading the change feed of a partition key range that was split
+var errCosmosDBPartitionGone = errors.New("partition key range is gone")
+
+// cosmosDBClient reads what the change feed estimator needs from Cosmos DB
+type cosmosDBClient interface {
+	// getLeases returns the leases of the change feed processor
+	getLeases(ctx context.Context) ([]cosmosDBLease, error)
+	// getPartitionKeyRanges returns the current partition key ranges of the monitored container
+	getPartitionKeyRanges(ctx context.Context) ([]cosmosDBPartitionKeyRange, error)
+	// getRemainingWork returns the number of changes of the partition key range after the continuation token
+	getRemainingWork(ctx context.Context, partitionKeyRangeID, continuationToken string) (int64, error)
+}
+
+// cosmosDBLease is a lease document of a change feed processor, the older processors name the
+// partition key range PartitionId rather than LeaseToken
+type cosmosDBLease struct {
+	ID                string `json:"id"`
+	LeaseToken        string `json:"LeaseToken"`
+	PartitionID       string `json:"PartitionId"`
+	ContinuationToken string `json:"ContinuationToken"`
+	Owner             string `json:"Owner"`
+}
+
+// token returns the partition key range of the lease, empty for the other documents of the processor
+func (l cosmosDBLease) token() string {
+	if l.LeaseToken != "" {
+		return l.LeaseToken
+	}
+	return l.PartitionID
+}
+
+// cosmosDBPartitionKeyRange is a partition key range, Parents lists the ranges it was split from
+type cosmosDBPartitionKeyRange struct {
+	ID      string   `json:"id"`
+	Parents []string `json:"parents"`
+}
+
+// cosmosDBRESTClient implements cosmosDBClient with the REST API of Cosmos DB, authenticated with the
+// account key or an AAD token
+type cosmosDBRESTClient struct {
+	httpClient       *http.Client
+	endpoint         string
+	databaseID       string
+	containerID      string
+	leaseDatabaseID  string
+	leaseContainerID string
+	processorName    string
+	key              []byte
+
+	// the AAD token, unused with the account key
+	getAADToken    func(ctx context.Context) (azure.AADToken, error)
+	tokenLock      sync.Mutex
+	token          string
+	tokenExpiresOn time.Time
+}
+
+func newCosmosDBRESTClient(meta *azureCosmosDBMetadata, httpClient *http.Client) (*cosmosDBRESTClient, error) {
+	client := &cosmosDBRESTClient{
+		httpClient:       httpClient,
+		endpoint:         meta.endpoint,
+		databaseID:       meta.databaseID,
+		containerID:      meta.containerID,
+		leaseDatabaseID:  meta.leaseDatabaseID,
+		leaseContainerID: meta.leaseContainerID,
+		processorName:    meta.processorName,
+	}
+
+	// the data plane tokens are issued for the account
+	endpoint, err := url.Parse(meta.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing endpoint: %s", err)
+	}
+	resource := fmt.Sprintf("%s://%s", endpoint.Scheme, endpoint.Hostname())
+	switch {
+	case meta.workloadIdentity:
+		client.getAADToken = func(ctx context.Context) (azure.AADToken, error) {
+			return azure.GetAzureADWorkloadIdentityToken(ctx, httpClient, resource)
+		}
+	case meta.podIdentity:
+		client.getAADToken = func(ctx context.Context) (azure.AADToken, error) {
+			return azure.GetAzureADPodIdentityToken(ctx, httpClient, resource)
+		}
+	default:
+		key, err := base64.StdEncoding.DecodeString(meta.key)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding the account key: %s", err)
+		}
+		client.key = key
+	}
+	return client, nil
+}
+
+// cosmosDBLeasesResponse is the response of a query of the lease container
+type cosmosDBLeasesResponse struct {
+	Documents []cosmosDBLease `json:"Documents"`
+}
+
+// getLeases queries the documents of the lease container whose id starts with the name of the processor
+func (c *cosmosDBRESTClient) getLeases(ctx context.Context) ([]cosmosDBLease, error) {
+	query, err := json.Marshal(map[string]interface{}{
+		"query":      "SELECT * FROM c WHERE STARTSWITH(c.id, @prefix)",
+		"parameters": []map[string]string{{"name": "@prefix", "value": c.processorName}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var leases []cosmosDBLease
+	continuation := ""
+	for {
+		headers := map[string]string{
+			"Content-Type":                               "application/query+json",
+			"x-ms-documentdb-isquery":                    "True",
+			"x-ms-documentdb-query-enablecrosspartition": "True",
+		}
+		if continuation != "" {
+			headers["x-ms-continuation"] = continuation
+		}
+		res, body, err := c.do(ctx, "POST", "docs", c.leaseDatabaseID, c.leaseContainerID, headers, query)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error querying the leases of processor %s: cosmos db returned %d: %s", c.processorName, res.StatusCode, strings.TrimSpace(string(body)))
+		}
+
+		var response cosmosDBLeasesResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, fmt.Errorf("error parsing the leases: %s", err)
+		}
+		leases = append(leases, response.Documents...)
+
+		continuation = res.Header.Get("x-ms-continuation")
+		if continuation == "" {
+			return leases, nil
+		}
+	}
+}
+
+// cosmosDBPartitionKeyRangesResponse is the response of /dbs/{db}/colls/{coll}/pkranges
+type cosmosDBPartitionKeyRangesResponse struct {
+	PartitionKeyRanges []cosmosDBPartitionKeyRange `json:"PartitionKeyRanges"`
+}
+
+func (c *cosmosDBRESTClient) getPartitionKeyRanges(ctx context.Context) ([]cosmosDBPartitionKeyRange, error) {
+	var ranges []cosmosDBPartitionKeyRange
+	continuation := ""
+	for {
+		headers := map[string]string{}
+		if continuation != "" {
+			headers["x-ms-continuation"] = continuation
+		}
+		res, body, err := c.do(ctx, "GET", "pkranges", c.databaseID, c.containerID, headers, nil)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error getting the partition key ranges of container %s: cosmos db returned %d: %s", c.containerID, res.StatusCode, strings.TrimSpace(string(body)))
+		}
+
+		var response cosmosDBPartitionKeyRangesResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, fmt.Errorf("error parsing the partition key ranges: %s", err)
+		}
+		ranges = append(ranges, response.PartitionKeyRanges...)
+
+		continuation = res.Header.Get("x-ms-continuation")
+		if continuation == "" {
+			return ranges, nil
+		}
+	}
+}
+
+// cosmosDBChangeFeedResponse is a page of the change feed of a partition key range
+type cosmosDBChangeFeedResponse struct {
+	Documents []struct {
+		LSN int64 `json:"_lsn"`
+	} `json:"Documents"`
+}
+
+// getRemainingWork reads the first change after the continuation token, the remaining work is the difference
+// between the latest LSN of the partition key range, from the session token, and the LSN of this change
+func (c *cosmosDBRESTClient) getRemainingWork(ctx context.Context, partitionKeyRangeID, continuationToken string) (int64, error) {
+	headers := map[string]string{
+		"A-IM":                                "Incremental feed",
+		"x-ms-documentdb-partitionkeyrangeid": partitionKeyRangeID,
+		"x-ms-max-item-count":                 "1",
+	}
+	if continuationToken != "" {
+		headers["If-None-Match"] = continuationToken
+	}
+	res, body, err := c.do(ctx, "GET", "docs", c.databaseID, c.containerID, headers, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		return 0, nil
+	case http.StatusGone:
+		return 0, errCosmosDBPartitionGone
+	case http.StatusOK:
+	default:
+		return 0, fmt.Errorf("error reading the change feed of partition key range %s: cosmos db returned %d: %s", partitionKeyRangeID, res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var response cosmosDBChangeFeedResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return 0, fmt.Errorf("error parsing the change feed: %s", err)
+	}
+	if len(response.Documents) == 0 {
+		return 0, nil
+	}
+
+	latestLSN, err := parseCosmosDBSessionTokenLSN(res.Header.Get("x-ms-session-token"))
+	if err != nil {
+		return 0, err
+	}
+	return latestLSN - response.Documents[0].LSN + 1, nil
+}
+
+// parseCosmosDBSessionTokenLSN returns the global LSN of a session token, <range>:<lsn> or
+// <range>:<version>#<global lsn>#<region>=<local lsn>...
+func parseCosmosDBSessionTokenLSN(sessionToken string) (int64, error) {
+	i := strings.Index(sessionToken, ":")
+	if i < 0 {
+		return 0, fmt.Errorf("invalid session token %q", sessionToken)
+	}
+	parts := strings.Split(sessionToken[i+1:], "#")
+	lsn := parts[0]
+	if len(parts) > 1 {
+		lsn = parts[1]
+	}
+	value, err := strconv.ParseInt(lsn, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid session token %q: %s", sessionToken, err)
+	}
+	return value, nil
+}
+
+// do sends a request for the resources of resourceType of the container, like docs or pkranges
+func (c *cosmosDBRESTClient) do(ctx context.Context, method, resourceType, databaseID, containerID string, headers map[string]string, body []byte) (*http.Response, []byte, error) {
+	resourceLink := fmt.Sprintf("dbs/%s/colls/%s", databaseID, containerID)
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/%s/%s", c.endpoint, resourceLink, resourceType), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	date := time.Now().UTC().Format(http.TimeFormat)
+	authorization, err := c.authorization(ctx, method, resourceType, resourceLink, date)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("x-ms-date", date)
+	req.Header.Set("x-ms-version", cosmosDBAPIVersion)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, responseBody, nil
+}
+
+// authorization returns the Authorization header of a request, signed with the account key or carrying the AAD token
+func (c *cosmosDBRESTClient) authorization(ctx context.Context, method, resourceType, resourceLink, date string) (string, error) {
+	if c.getAADToken != nil {
+		token, err := c.getToken(ctx)
+		if err != nil {
+			return "", err
+		}
+		return url.QueryEscape("type=aad&ver=1.0&sig=" + token), nil
+	}
+
+	payload := fmt.Sprintf("%s\n%s\n%s\n%s\n\n", strings.ToLower(method), strings.ToLower(resourceType), resourceLink, strings.ToLower(date))
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(payload))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return url.QueryEscape("type=master&ver=1.0&sig=" + signature), nil
+}
+
+func (c *cosmosDBRESTClient) getToken(ctx context.Context) (string, error) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+
+	if c.token != "" && time.Until(c.tokenExpiresOn) > cosmosDBTokenRefreshMargin {
+		return c.token, nil
+	}
+
+	token, err := c.getAADToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error getting the AAD token: %s", err)
+	}
+	expiresOn, err := strconv.ParseInt(token.ExpiresOn, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("error parsing token expiry: %s", err)
+	}
+
+	c.token = token.AccessToken
+	c.tokenExpiresOn = time.Unix(expiresOn, 0)
+	return c.token, nil
+}
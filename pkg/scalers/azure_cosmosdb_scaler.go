@@ -0,0 +1,342 @@
+package scalers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"github.com/kedacore/keda/v2/pkg/scalers/authentication"
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	defaultCosmosDBLeaseContainerID = "leases"
+	defaultCosmosDBTargetLag        = 100
+)
+
+type azureCosmosDBScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *azureCosmosDBMetadata
+	client     cosmosDBClient
+}
+
+type azureCosmosDBMetadata struct {
+	endpoint            string
+	key                 string
+	databaseID          string
+	containerID         string
+	leaseDatabaseID     string
+	leaseContainerID    string
+	processorName       string
+	targetLag           int64
+	activationTargetLag int64
+	podIdentity         bool
+	workloadIdentity    bool
+	scalerIndex         int
+}
+
+var azureCosmosDBLog = logf.Log.WithName("azure_cosmosdb_scaler")
+
+// NewAzureCosmosDBScaler creates a new azureCosmosDBScaler
+func NewAzureCosmosDBScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseAzureCosmosDBMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing azure cosmos db metadata: %s", err)
+	}
+
+	httpClient := WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config)
+	client, err := newCosmosDBRESTClient(meta, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &azureCosmosDBScaler{
+		metricType: metricType,
+		metadata:   meta,
+		client:     client,
+	}, nil
+}
+
+func parseAzureCosmosDBMetadata(config *ScalerConfig) (*azureCosmosDBMetadata, error) {
+	meta := azureCosmosDBMetadata{}
+	meta.leaseContainerID = defaultCosmosDBLeaseContainerID
+	meta.targetLag = defaultCosmosDBTargetLag
+
+	// a connection string holds both the endpoint and the key of the account
+	connection, err := getFromAuthOrEnv(config, "connection")
+	if err != nil {
+		return nil, err
+	}
+	if connection != "" {
+		meta.endpoint, meta.key = parseCosmosDBConnectionString(connection)
+	}
+	if val, ok := config.TriggerMetadata["endpoint"]; ok && val != "" {
+		meta.endpoint = val
+	}
+	if meta.endpoint == "" {
+		return nil, fmt.Errorf("no endpoint given")
+	}
+	if _, err := url.ParseRequestURI(meta.endpoint); err != nil {
+		return nil, fmt.Errorf("error parsing endpoint: %s", err)
+	}
+	meta.endpoint = strings.TrimSuffix(meta.endpoint, "/")
+
+	if val, ok := config.TriggerMetadata["databaseID"]; ok && val != "" {
+		meta.databaseID = val
+	} else {
+		return nil, fmt.Errorf("no databaseID given")
+	}
+
+	if val, ok := config.TriggerMetadata["containerID"]; ok && val != "" {
+		meta.containerID = val
+	} else {
+		return nil, fmt.Errorf("no containerID given")
+	}
+
+	meta.leaseDatabaseID = meta.databaseID
+	if val, ok := config.TriggerMetadata["leaseDatabaseID"]; ok && val != "" {
+		meta.leaseDatabaseID = val
+	}
+
+	if val, ok := config.TriggerMetadata["leaseContainerID"]; ok && val != "" {
+		meta.leaseContainerID = val
+	}
+
+	// the leases of the processor are the documents of the lease container whose id starts with its name
+	if val, ok := config.TriggerMetadata["processorName"]; ok && val != "" {
+		meta.processorName = val
+	} else {
+		return nil, fmt.Errorf("no processorName given")
+	}
+
+	if val, ok := config.TriggerMetadata["targetLag"]; ok {
+		targetLag, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetLag: %s", err)
+		}
+		if targetLag <= 0 {
+			return nil, fmt.Errorf("targetLag must be greater than 0")
+		}
+		meta.targetLag = targetLag
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetLag"]; ok {
+		activationTargetLag, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetLag: %s", err)
+		}
+		if activationTargetLag < 0 {
+			return nil, fmt.Errorf("activationTargetLag must not be negative")
+		}
+		meta.activationTargetLag = activationTargetLag
+	}
+
+	auth, err := authentication.GetAuthConfigs(config.TriggerMetadata, config.AuthParams)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case auth != nil:
+		if !auth.EnableAzureWorkloadIdentity || auth.EnableBearerAuth || auth.EnableBasicAuth || auth.EnableTLS {
+			return nil, fmt.Errorf("only the %s auth mode is supported", authentication.AzureWorkloadIdentityAuthType)
+		}
+		meta.workloadIdentity = true
+	case config.PodIdentity == kedav1alpha1.PodIdentityProviderAzure:
+		meta.podIdentity = true
+	default:
+		if meta.key == "" {
+			key, err := getFromAuthOrEnv(config, "cosmosDBKey")
+			if err != nil {
+				return nil, err
+			}
+			meta.key = key
+		}
+		if meta.key == "" {
+			return nil, fmt.Errorf("no cosmosDBKey given")
+		}
+	}
+
+	meta.scalerIndex = config.ScalerIndex
+	return &meta, nil
+}
+
+// parseCosmosDBConnectionString returns the endpoint and the key of AccountEndpoint=...;AccountKey=...;
+func parseCosmosDBConnectionString(connection string) (string, string) {
+	var endpoint, key string
+	for _, part := range strings.Split(connection, ";") {
+		i := strings.Index(part, "=")
+		if i < 0 {
+			continue
+		}
+		switch strings.TrimSpace(part[:i]) {
+		case "AccountEndpoint":
+			endpoint = part[i+1:]
+		case "AccountKey":
+			key = part[i+1:]
+		}
+	}
+	return endpoint, key
+}
+
+// IsActive checks if the estimated lag is above the activation target
+func (s *azureCosmosDBScaler) IsActive(ctx context.Context) (bool, error) {
+	lag, err := s.estimateLag(ctx)
+	if err != nil {
+		azureCosmosDBLog.Error(err, "error estimating the lag")
+		return false, err
+	}
+
+	return lag > s.metadata.activationTargetLag, nil
+}
+
+func (s *azureCosmosDBScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *azureCosmosDBScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	metricName := kedautil.NormalizeString(fmt.Sprintf("azure-cosmosdb-%s-%s-%s", s.metadata.databaseID, s.metadata.containerID, s.metadata.processorName))
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, metricName),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetLag),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the estimated lag of the change feed processor
+func (s *azureCosmosDBScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	lag, err := s.estimateLag(ctx)
+	if err != nil {
+		azureCosmosDBLog.Error(err, "error estimating the lag")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(lag, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// estimateLag sums the remaining work of the leases of the processor, like the change feed estimator.
+// The lease of a partition key range that was split is stale until the processor replaces it with the
+// leases of the child ranges, its remaining work is estimated on the child ranges not leased yet, from
+// its continuation token. The ranges not leased at all, before the processor starts, aren't estimated
+func (s *azureCosmosDBScaler) estimateLag(ctx context.Context) (int64, error) {
+	leases, err := s.client.getLeases(ctx)
+	if err != nil {
+		return 0, err
+	}
+	ranges, err := s.client.getPartitionKeyRanges(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	current := map[string]bool{}
+	for _, r := range ranges {
+		current[r.ID] = true
+	}
+	leased := map[string]bool{}
+	for _, lease := range leases {
+		if lease.token() != "" {
+			leased[lease.token()] = true
+		}
+	}
+
+	var lag int64
+	for _, lease := range leases {
+		// the processor also stores its .info and .lock documents with the leases
+		token := lease.token()
+		if token == "" {
+			continue
+		}
+
+		targets := []string{token}
+		if !current[token] {
+			targets = cosmosDBChildRanges(ranges, token, leased)
+			azureCosmosDBLog.V(1).Info("Estimating the stale lease of a split partition key range on its children", "processorName", s.metadata.processorName, "lease", lease.ID, "partitionKeyRange", token, "children", targets)
+		}
+		for _, target := range targets {
+			remaining, err := s.getRemainingWork(ctx, ranges, target, lease.ContinuationToken, leased)
+			if err != nil {
+				return 0, err
+			}
+			lag += remaining
+		}
+	}
+
+	azureCosmosDBLog.V(1).Info(fmt.Sprintf("Estimated a lag of %d for processor %s over %d leases", lag, s.metadata.processorName, len(leased)))
+	return lag, nil
+}
+
+// getRemainingWork returns the remaining work of a partition key range, never negative. A range split since the
+// ranges were read is estimated on its children
+func (s *azureCosmosDBScaler) getRemainingWork(ctx context.Context, ranges []cosmosDBPartitionKeyRange, partitionKeyRangeID, continuationToken string, leased map[string]bool) (int64, error) {
+	remaining, err := s.client.getRemainingWork(ctx, partitionKeyRangeID, continuationToken)
+	if errors.Is(err, errCosmosDBPartitionGone) {
+		ranges, err = s.client.getPartitionKeyRanges(ctx)
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		for _, child := range cosmosDBChildRanges(ranges, partitionKeyRangeID, leased) {
+			remaining, err := s.client.getRemainingWork(ctx, child, continuationToken)
+			if errors.Is(err, errCosmosDBPartitionGone) {
+				// split again, it is estimated on the next poll
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			if remaining > 0 {
+				total += remaining
+			}
+		}
+		return total, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if remaining < 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// cosmosDBChildRanges returns the ranges split from parent that have no lease of their own yet
+func cosmosDBChildRanges(ranges []cosmosDBPartitionKeyRange, parent string, leased map[string]bool) []string {
+	var children []string
+	for _, r := range ranges {
+		if leased[r.ID] {
+			continue
+		}
+		for _, p := range r.Parents {
+			if p == parent {
+				children = append(children, r.ID)
+				break
+			}
+		}
+	}
+	return children
+}
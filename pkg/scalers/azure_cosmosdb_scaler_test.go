@@ -0,0 +1,352 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+)
+
+// testCosmosDBKey is the well known key of the Cosmos DB emulator
+const testCosmosDBKey = "C2y6yDjf5/R+ob0N8A7Cgv30VRDJIWEHLM+4QDU5DE2nQ9nDuVTqobD4b8mGGyPMbIZnqyMsEcaGQy67XIw/Jw=="
+
+type parseAzureCosmosDBMetadataTestData struct {
+	metadata    map[string]string
+	isError     bool
+	resolvedEnv map[string]string
+	authParams  map[string]string
+	podIdentity kedav1alpha1.PodIdentityProvider
+}
+
+type azureCosmosDBMetricIdentifier struct {
+	metadataTestData *parseAzureCosmosDBMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testAzureCosmosDBResolvedEnv = map[string]string{
+	"COSMOSDB_CONNECTION": "AccountEndpoint=https://myaccount.documents.azure.com:443/;AccountKey=" + testCosmosDBKey + ";",
+}
+
+var testAzureCosmosDBMetadata = []parseAzureCosmosDBMetadataTestData{
+	// nothing passed
+	{map[string]string{}, true, nil, nil, ""},
+	// properly formed with the key in the auth params
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "databaseID": "orders", "containerID": "events", "processorName": "projector"}, false, nil, map[string]string{"cosmosDBKey": testCosmosDBKey}, ""},
+	// properly formed with a connection string from the env
+	{map[string]string{"connectionFromEnv": "COSMOSDB_CONNECTION", "databaseID": "orders", "containerID": "events", "processorName": "projector", "leaseDatabaseID": "leases-db", "leaseContainerID": "projector-leases", "targetLag": "500", "activationTargetLag": "10"}, false, testAzureCosmosDBResolvedEnv, nil, ""},
+	// connection string from a missing env var
+	{map[string]string{"connectionFromEnv": "MISSING", "databaseID": "orders", "containerID": "events", "processorName": "projector"}, true, testAzureCosmosDBResolvedEnv, nil, ""},
+	// connection string inline
+	{map[string]string{"connection": "AccountEndpoint=https://myaccount.documents.azure.com:443/;AccountKey=" + testCosmosDBKey + ";", "databaseID": "orders", "containerID": "events", "processorName": "projector"}, true, nil, nil, ""},
+	// key inline
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "cosmosDBKey": testCosmosDBKey, "databaseID": "orders", "containerID": "events", "processorName": "projector"}, true, nil, nil, ""},
+	// no endpoint
+	{map[string]string{"databaseID": "orders", "containerID": "events", "processorName": "projector"}, true, nil, map[string]string{"cosmosDBKey": testCosmosDBKey}, ""},
+	// malformed endpoint
+	{map[string]string{"endpoint": "myaccount.documents.azure.com", "databaseID": "orders", "containerID": "events", "processorName": "projector"}, true, nil, map[string]string{"cosmosDBKey": testCosmosDBKey}, ""},
+	// no databaseID
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "containerID": "events", "processorName": "projector"}, true, nil, map[string]string{"cosmosDBKey": testCosmosDBKey}, ""},
+	// no containerID
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "databaseID": "orders", "processorName": "projector"}, true, nil, map[string]string{"cosmosDBKey": testCosmosDBKey}, ""},
+	// no processorName
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "databaseID": "orders", "containerID": "events"}, true, nil, map[string]string{"cosmosDBKey": testCosmosDBKey}, ""},
+	// no key
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "databaseID": "orders", "containerID": "events", "processorName": "projector"}, true, nil, nil, ""},
+	// malformed targetLag
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "databaseID": "orders", "containerID": "events", "processorName": "projector", "targetLag": "AA"}, true, nil, map[string]string{"cosmosDBKey": testCosmosDBKey}, ""},
+	// targetLag of 0
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "databaseID": "orders", "containerID": "events", "processorName": "projector", "targetLag": "0"}, true, nil, map[string]string{"cosmosDBKey": testCosmosDBKey}, ""},
+	// negative activationTargetLag
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "databaseID": "orders", "containerID": "events", "processorName": "projector", "activationTargetLag": "-1"}, true, nil, map[string]string{"cosmosDBKey": testCosmosDBKey}, ""},
+	// azure pod identity
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "databaseID": "orders", "containerID": "events", "processorName": "projector"}, false, nil, nil, kedav1alpha1.PodIdentityProviderAzure},
+	// azure workload identity
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "databaseID": "orders", "containerID": "events", "processorName": "projector", "authModes": "azureWorkloadIdentity"}, false, nil, nil, ""},
+	// unsupported auth mode
+	{map[string]string{"endpoint": "https://myaccount.documents.azure.com:443/", "databaseID": "orders", "containerID": "events", "processorName": "projector", "authModes": "bearer"}, true, nil, map[string]string{"bearerToken": "token"}, ""},
+}
+
+var azureCosmosDBMetricIdentifiers = []azureCosmosDBMetricIdentifier{
+	{&testAzureCosmosDBMetadata[1], 0, "s0-azure-cosmosdb-orders-events-projector"},
+	{&testAzureCosmosDBMetadata[2], 1, "s1-azure-cosmosdb-orders-events-projector"},
+}
+
+func TestAzureCosmosDBParseMetadata(t *testing.T) {
+	for i, testData := range testAzureCosmosDBMetadata {
+		_, err := parseAzureCosmosDBMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, ResolvedEnv: testData.resolvedEnv, AuthParams: testData.authParams, PodIdentity: testData.podIdentity})
+		if err != nil && !testData.isError {
+			t.Errorf("Expected success but got error for unit test #%d: %s", i, err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("Expected error but got success for unit test #%d", i)
+		}
+	}
+}
+
+func TestAzureCosmosDBParseMetadataDefaults(t *testing.T) {
+	meta, err := parseAzureCosmosDBMetadata(&ScalerConfig{TriggerMetadata: testAzureCosmosDBMetadata[1].metadata, AuthParams: testAzureCosmosDBMetadata[1].authParams})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	if meta.endpoint != "https://myaccount.documents.azure.com:443" || meta.leaseDatabaseID != "orders" || meta.leaseContainerID != "leases" || meta.targetLag != 100 || meta.activationTargetLag != 0 {
+		t.Errorf("Unexpected defaults %+v", meta)
+	}
+
+	meta, err = parseAzureCosmosDBMetadata(&ScalerConfig{TriggerMetadata: testAzureCosmosDBMetadata[2].metadata, ResolvedEnv: testAzureCosmosDBMetadata[2].resolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	if meta.endpoint != "https://myaccount.documents.azure.com:443" || meta.key != testCosmosDBKey {
+		t.Errorf("Unexpected endpoint %s or key %s from the connection string", meta.endpoint, meta.key)
+	}
+}
+
+func TestAzureCosmosDBGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range azureCosmosDBMetricIdentifiers {
+		meta, err := parseAzureCosmosDBMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, ResolvedEnv: testData.metadataTestData.resolvedEnv, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockAzureCosmosDBScaler := azureCosmosDBScaler{
+			metadata: meta,
+		}
+
+		metricSpec := mockAzureCosmosDBScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+// mockCosmosDBClient serves the remaining work of each partition key range by continuation token, the ranges
+// listed in gone were split after the ranges were first read
+type mockCosmosDBClient struct {
+	leases    []cosmosDBLease
+	ranges    []cosmosDBPartitionKeyRange
+	remaining map[string]int64
+	gone      map[string]bool
+	requested []string
+}
+
+func (c *mockCosmosDBClient) getLeases(context.Context) ([]cosmosDBLease, error) {
+	return c.leases, nil
+}
+
+func (c *mockCosmosDBClient) getPartitionKeyRanges(context.Context) ([]cosmosDBPartitionKeyRange, error) {
+	var ranges []cosmosDBPartitionKeyRange
+	for _, r := range c.ranges {
+		if !c.gone[r.ID] {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges, nil
+}
+
+func (c *mockCosmosDBClient) getRemainingWork(_ context.Context, partitionKeyRangeID, continuationToken string) (int64, error) {
+	c.requested = append(c.requested, partitionKeyRangeID+"@"+continuationToken)
+	if c.gone[partitionKeyRangeID] {
+		return 0, errCosmosDBPartitionGone
+	}
+	remaining, ok := c.remaining[partitionKeyRangeID+"@"+continuationToken]
+	if !ok {
+		return 0, fmt.Errorf("unexpected request for %s from %s", partitionKeyRangeID, continuationToken)
+	}
+	return remaining, nil
+}
+
+var testCosmosDBLeases = []cosmosDBLease{
+	{ID: "projector.info"},
+	{ID: "projector..0", LeaseToken: "0", ContinuationToken: `"100"`, Owner: "host-a"},
+	{ID: "projector..1", LeaseToken: "1", ContinuationToken: `"200"`, Owner: "host-a"},
+	{ID: "projector..2", LeaseToken: "2", ContinuationToken: `"300"`, Owner: "host-b"},
+}
+
+func TestAzureCosmosDBEstimateLag(t *testing.T) {
+	for _, testData := range []struct {
+		name      string
+		leases    []cosmosDBLease
+		ranges    []cosmosDBPartitionKeyRange
+		remaining map[string]int64
+		gone      map[string]bool
+		lag       int64
+	}{
+		{
+			name:      "several partitions",
+			leases:    testCosmosDBLeases,
+			ranges:    []cosmosDBPartitionKeyRange{{ID: "0"}, {ID: "1"}, {ID: "2"}},
+			remaining: map[string]int64{`0@"100"`: 40, `1@"200"`: 0, `2@"300"`: 25},
+			lag:       65,
+		},
+		{
+			name:      "the older leases",
+			leases:    []cosmosDBLease{{ID: "projector..0", PartitionID: "0", ContinuationToken: `"100"`}, {ID: "projector..1", PartitionID: "1", ContinuationToken: `"200"`}},
+			ranges:    []cosmosDBPartitionKeyRange{{ID: "0"}, {ID: "1"}},
+			remaining: map[string]int64{`0@"100"`: 3, `1@"200"`: 4},
+			lag:       7,
+		},
+		{
+			name:      "a lease not processed yet",
+			leases:    []cosmosDBLease{{ID: "projector..0", LeaseToken: "0"}},
+			ranges:    []cosmosDBPartitionKeyRange{{ID: "0"}},
+			remaining: map[string]int64{"0@": 12},
+			lag:       12,
+		},
+		{
+			// the lease of 2 is stale, its children 3 and 4 are estimated from its continuation token
+			name:      "a split partition",
+			leases:    testCosmosDBLeases,
+			ranges:    []cosmosDBPartitionKeyRange{{ID: "0"}, {ID: "1"}, {ID: "3", Parents: []string{"2"}}, {ID: "4", Parents: []string{"2"}}},
+			remaining: map[string]int64{`0@"100"`: 40, `1@"200"`: 0, `3@"300"`: 10, `4@"300"`: 5},
+			lag:       55,
+		},
+		{
+			// the child 3 already has its lease, only 4 is estimated from the stale lease
+			name:      "a split partition partly leased",
+			leases:    append(append([]cosmosDBLease{}, testCosmosDBLeases...), cosmosDBLease{ID: "projector..3", LeaseToken: "3", ContinuationToken: `"350"`}),
+			ranges:    []cosmosDBPartitionKeyRange{{ID: "0"}, {ID: "1"}, {ID: "3", Parents: []string{"2"}}, {ID: "4", Parents: []string{"2"}}},
+			remaining: map[string]int64{`0@"100"`: 40, `1@"200"`: 0, `3@"350"`: 2, `4@"300"`: 5},
+			lag:       47,
+		},
+		{
+			name:      "a partition split while estimating",
+			leases:    testCosmosDBLeases,
+			ranges:    []cosmosDBPartitionKeyRange{{ID: "0"}, {ID: "1"}, {ID: "2"}, {ID: "3", Parents: []string{"2"}}, {ID: "4", Parents: []string{"2"}}},
+			gone:      map[string]bool{"2": true},
+			remaining: map[string]int64{`0@"100"`: 40, `1@"200"`: 0, `3@"300"`: 10, `4@"300"`: 5},
+			lag:       55,
+		},
+		{
+			// the continuation token of a stale lease can be ahead of the LSN of a child
+			name:      "a negative estimate",
+			leases:    testCosmosDBLeases,
+			ranges:    []cosmosDBPartitionKeyRange{{ID: "0"}, {ID: "1"}, {ID: "3", Parents: []string{"2"}}, {ID: "4", Parents: []string{"2"}}},
+			remaining: map[string]int64{`0@"100"`: 40, `1@"200"`: -3, `3@"300"`: -20, `4@"300"`: 5},
+			lag:       45,
+		},
+		{
+			name:   "no leases",
+			leases: []cosmosDBLease{{ID: "projector.info"}, {ID: "projector.lock"}},
+			ranges: []cosmosDBPartitionKeyRange{{ID: "0"}},
+			lag:    0,
+		},
+	} {
+		client := &mockCosmosDBClient{leases: testData.leases, ranges: testData.ranges, remaining: testData.remaining, gone: testData.gone}
+		s := azureCosmosDBScaler{metadata: &azureCosmosDBMetadata{processorName: "projector", activationTargetLag: 50}, client: client}
+
+		lag, err := s.estimateLag(context.Background())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", testData.name, err)
+		}
+		if lag != testData.lag {
+			t.Errorf("%s: expected a lag of %d, got %d after reading %v", testData.name, testData.lag, lag, client.requested)
+		}
+
+		isActive, err := s.IsActive(context.Background())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", testData.name, err)
+		}
+		if isActive != (testData.lag > 50) {
+			t.Errorf("%s: expected active %t, got %t", testData.name, testData.lag > 50, isActive)
+		}
+	}
+}
+
+func TestParseCosmosDBSessionTokenLSN(t *testing.T) {
+	for _, testData := range []struct {
+		sessionToken string
+		lsn          int64
+		isError      bool
+	}{
+		{"0:1234", 1234, false},
+		{"3:-1#1234", 1234, false},
+		{"3:-1#1234#1=1230#2=1234", 1234, false},
+		{"1234", 0, true},
+		{"0:abc", 0, true},
+		{"", 0, true},
+	} {
+		lsn, err := parseCosmosDBSessionTokenLSN(testData.sessionToken)
+		if testData.isError {
+			if err == nil {
+				t.Errorf("Expected an error parsing %q", testData.sessionToken)
+			}
+			continue
+		}
+		if err != nil || lsn != testData.lsn {
+			t.Errorf("Expected %d from %q, got %d and %v", testData.lsn, testData.sessionToken, lsn, err)
+		}
+	}
+}
+
+func TestAzureCosmosDBRESTClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization, err := url.QueryUnescape(r.Header.Get("Authorization"))
+		if err != nil || !strings.HasPrefix(authorization, "type=master&ver=1.0&sig=") || r.Header.Get("x-ms-date") == "" || r.Header.Get("x-ms-version") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/dbs/orders/colls/leases/docs":
+			if r.Header.Get("x-ms-documentdb-isquery") != "True" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			// the leases are served on two pages
+			if r.Header.Get("x-ms-continuation") == "" {
+				w.Header().Set("x-ms-continuation", "page-2")
+				_, _ = w.Write([]byte(`{"_rid":"abc","Documents":[{"id":"projector.info"},{"id":"projector..0","LeaseToken":"0","ContinuationToken":"\"100\"","Owner":"host-a"}],"_count":2}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"_rid":"abc","Documents":[{"id":"projector..1","LeaseToken":"1","ContinuationToken":"\"200\"","Owner":"host-b"}],"_count":1}`))
+		case r.Method == "GET" && r.URL.Path == "/dbs/orders/colls/events/pkranges":
+			_, _ = w.Write([]byte(`{"_rid":"def","PartitionKeyRanges":[{"id":"0","minInclusive":"","maxExclusive":"7F","parents":[]},{"id":"1","minInclusive":"7F","maxExclusive":"FF","parents":[]}],"_count":2}`))
+		case r.Method == "GET" && r.URL.Path == "/dbs/orders/colls/events/docs":
+			if r.Header.Get("A-IM") != "Incremental feed" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			switch r.Header.Get("x-ms-documentdb-partitionkeyrangeid") + "@" + r.Header.Get("If-None-Match") {
+			case `0@"100"`:
+				// the first change after the lease is at LSN 101, the latest one at 150
+				w.Header().Set("x-ms-session-token", "0:-1#150")
+				_, _ = w.Write([]byte(`{"_rid":"def","Documents":[{"id":"order-1","_lsn":101}],"_count":1}`))
+			case `1@"200"`:
+				w.WriteHeader(http.StatusNotModified)
+			default:
+				w.WriteHeader(http.StatusGone)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	meta, err := parseAzureCosmosDBMetadata(&ScalerConfig{TriggerMetadata: map[string]string{"endpoint": server.URL, "databaseID": "orders", "containerID": "events", "processorName": "projector"}, AuthParams: map[string]string{"cosmosDBKey": testCosmosDBKey}})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	client, err := newCosmosDBRESTClient(meta, http.DefaultClient)
+	if err != nil {
+		t.Fatal("Could not create the client:", err)
+	}
+	s := azureCosmosDBScaler{metadata: meta, client: client}
+
+	metrics, err := s.GetMetrics(context.Background(), "s0-azure-cosmosdb-orders-events-projector", nil)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if value := metrics[0].Value.Value(); value != 50 {
+		t.Errorf("Expected a lag of 50, got %d", value)
+	}
+
+	if _, err := client.getRemainingWork(context.Background(), "2", `"300"`); err != errCosmosDBPartitionGone {
+		t.Errorf("Expected the partition to be gone, got %v", err)
+	}
+}
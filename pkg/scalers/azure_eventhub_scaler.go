@@ -84,7 +84,7 @@ func NewAzureEventHubScaler(config *ScalerConfig) (Scaler, error) {
 		metricType: metricType,
 		metadata:   parsedMetadata,
 		client:     hub,
-		httpClient: kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false),
+		httpClient: WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config),
 	}, nil
 }
 
@@ -104,11 +104,11 @@ func parseAzureEventHubMetadata(config *ScalerConfig) (*eventHubMetadata, error)
 		meta.threshold = threshold
 	}
 
-	if config.AuthParams["storageConnection"] != "" {
-		meta.eventHubInfo.StorageConnection = config.AuthParams["storageConnection"]
-	} else if config.TriggerMetadata["storageConnectionFromEnv"] != "" {
-		meta.eventHubInfo.StorageConnection = config.ResolvedEnv[config.TriggerMetadata["storageConnectionFromEnv"]]
+	storageConnection, err := getFromAuthOrEnv(config, "storageConnection")
+	if err != nil {
+		return nil, err
 	}
+	meta.eventHubInfo.StorageConnection = storageConnection
 
 	if len(meta.eventHubInfo.StorageConnection) == 0 {
 		return nil, fmt.Errorf("no storage connection string given")
@@ -150,11 +150,11 @@ func parseAzureEventHubMetadata(config *ScalerConfig) (*eventHubMetadata, error)
 	meta.eventHubInfo.ActiveDirectoryEndpoint = activeDirectoryEndpoint
 
 	if config.PodIdentity == "" || config.PodIdentity == v1alpha1.PodIdentityProviderNone {
-		if config.AuthParams["connection"] != "" {
-			meta.eventHubInfo.EventHubConnection = config.AuthParams["connection"]
-		} else if config.TriggerMetadata["connectionFromEnv"] != "" {
-			meta.eventHubInfo.EventHubConnection = config.ResolvedEnv[config.TriggerMetadata["connectionFromEnv"]]
+		connection, err := getFromAuthOrEnv(config, "connection")
+		if err != nil {
+			return nil, err
 		}
+		meta.eventHubInfo.EventHubConnection = connection
 
 		if len(meta.eventHubInfo.EventHubConnection) == 0 {
 			return nil, fmt.Errorf("no event hub connection string given")
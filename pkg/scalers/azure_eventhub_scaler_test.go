@@ -472,3 +472,25 @@ func TestEventHubGetMetricSpecForScaling(t *testing.T) {
 		}
 	}
 }
+
+func TestParseEventHubMetadataConnectionSources(t *testing.T) {
+	metadata := map[string]string{"consumerGroup": "$Default", "unprocessedEventThreshold": "15", "storageConnectionFromEnv": storageConnectionSetting}
+	testParseSecretFromEnvSources(t, "connection", metadata, nil, [3]string{"Endpoint=sb://inline", "Endpoint=sb://env", "Endpoint=sb://auth"}, func(config *ScalerConfig) (string, error) {
+		config.ResolvedEnv[storageConnectionSetting] = "DefaultEndpointsProtocol=https"
+		meta, err := parseAzureEventHubMetadata(config)
+		if err != nil {
+			return "", err
+		}
+		return meta.eventHubInfo.EventHubConnection, nil
+	})
+
+	metadata = map[string]string{"consumerGroup": "$Default", "unprocessedEventThreshold": "15", "connectionFromEnv": eventHubConnectionSetting}
+	testParseSecretFromEnvSources(t, "storageConnection", metadata, nil, [3]string{"DefaultEndpointsProtocol=inline", "DefaultEndpointsProtocol=env", "DefaultEndpointsProtocol=auth"}, func(config *ScalerConfig) (string, error) {
+		config.ResolvedEnv[eventHubConnectionSetting] = "Endpoint=sb://env"
+		meta, err := parseAzureEventHubMetadata(config)
+		if err != nil {
+			return "", err
+		}
+		return meta.eventHubInfo.StorageConnection, nil
+	})
+}
@@ -51,7 +51,7 @@ var azurePipelinesLog = logf.Log.WithName("azure_pipelines_scaler")
 
 // NewAzurePipelinesScaler creates a new AzurePipelinesScaler
 func NewAzurePipelinesScaler(ctx context.Context, config *ScalerConfig) (Scaler, error) {
-	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false)
+	httpClient := WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config)
 
 	metricType, err := GetMetricTargetType(config)
 	if err != nil {
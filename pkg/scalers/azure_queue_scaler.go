@@ -75,7 +75,7 @@ func NewAzureQueueScaler(config *ScalerConfig) (Scaler, error) {
 		metricType:  metricType,
 		metadata:    meta,
 		podIdentity: podIdentity,
-		httpClient:  kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false),
+		httpClient:  WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config),
 	}, nil
 }
 
@@ -119,12 +119,11 @@ func parseAzureQueueMetadata(config *ScalerConfig) (*azureQueueMetadata, kedav1a
 	case "", kedav1alpha1.PodIdentityProviderNone:
 		// Azure Queue Scaler expects a "connection" parameter in the metadata
 		// of the scaler or in a TriggerAuthentication object
-		if config.AuthParams["connection"] != "" {
-			// Found the connection in a parameter from TriggerAuthentication
-			meta.connection = config.AuthParams["connection"]
-		} else if config.TriggerMetadata["connectionFromEnv"] != "" {
-			meta.connection = config.ResolvedEnv[config.TriggerMetadata["connectionFromEnv"]]
+		connection, err := getFromAuthOrEnv(config, "connection")
+		if err != nil {
+			return nil, "", err
 		}
+		meta.connection = connection
 
 		if len(meta.connection) == 0 {
 			return nil, "", fmt.Errorf("no connection setting given")
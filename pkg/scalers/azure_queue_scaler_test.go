@@ -116,3 +116,14 @@ func TestAzQueueGetMetricSpecForScaling(t *testing.T) {
 		}
 	}
 }
+
+func TestParseAzQueueMetadataConnectionSources(t *testing.T) {
+	metadata := map[string]string{"queueName": "sample", "queueLength": "5"}
+	testParseSecretFromEnvSources(t, "connection", metadata, nil, [3]string{"AccountName=inline", "AccountName=env", "AccountName=auth"}, func(config *ScalerConfig) (string, error) {
+		meta, _, err := parseAzureQueueMetadata(config)
+		if err != nil {
+			return "", err
+		}
+		return meta.connection, nil
+	})
+}
@@ -86,7 +86,7 @@ func NewAzureServiceBusScaler(ctx context.Context, config *ScalerConfig) (Scaler
 		metricType:  metricType,
 		metadata:    meta,
 		podIdentity: config.PodIdentity,
-		httpClient:  kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false),
+		httpClient:  WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config),
 	}, nil
 }
 
@@ -146,11 +146,11 @@ func parseAzureServiceBusMetadata(config *ScalerConfig) (*azureServiceBusMetadat
 	switch config.PodIdentity {
 	case "", kedav1alpha1.PodIdentityProviderNone:
 		// get servicebus connection string
-		if config.AuthParams["connection"] != "" {
-			meta.connection = config.AuthParams["connection"]
-		} else if config.TriggerMetadata["connectionFromEnv"] != "" {
-			meta.connection = config.ResolvedEnv[config.TriggerMetadata["connectionFromEnv"]]
+		connection, err := getFromAuthOrEnv(config, "connection")
+		if err != nil {
+			return nil, err
 		}
+		meta.connection = connection
 
 		if len(meta.connection) == 0 {
 			return nil, fmt.Errorf("no connection setting given")
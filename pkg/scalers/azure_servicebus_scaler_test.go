@@ -207,3 +207,14 @@ func TestAzServiceBusGetMetricSpecForScaling(t *testing.T) {
 		}
 	}
 }
+
+func TestParseServiceBusMetadataConnectionSources(t *testing.T) {
+	metadata := map[string]string{"queueName": "testqueue"}
+	testParseSecretFromEnvSources(t, "connection", metadata, nil, [3]string{"Endpoint=sb://inline", "Endpoint=sb://env", "Endpoint=sb://auth"}, func(config *ScalerConfig) (string, error) {
+		meta, err := parseAzureServiceBusMetadata(config)
+		if err != nil {
+			return "", err
+		}
+		return meta.connection, nil
+	})
+}
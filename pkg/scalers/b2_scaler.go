@@ -0,0 +1,389 @@
+package scalers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	defaultB2Endpoint        = "https://api.backblazeb2.com"
+	defaultB2TargetFileCount = 100
+	defaultB2MaxFilesToScan  = 1000
+	// b2MaxFileCountPerRequest is the most file names b2_list_file_names returns in a single (class C) call
+	b2MaxFileCountPerRequest = 1000
+)
+
+type b2Scaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *b2Metadata
+	httpClient *http.Client
+
+	// the account authorization is valid for 24 hours and reused until B2 reports it expired
+	authLock      sync.Mutex
+	authorization *b2Authorization
+	bucketID      string
+}
+
+type b2Metadata struct {
+	endpoint                  string
+	applicationKeyID          string
+	applicationKey            string
+	bucketName                string
+	prefix                    string
+	targetFileCount           int64
+	activationTargetFileCount int64
+	maxFilesToScan            int64
+	scalerIndex               int
+}
+
+type b2Authorization struct {
+	AccountID          string `json:"accountId"`
+	AuthorizationToken string `json:"authorizationToken"`
+	APIURL             string `json:"apiUrl"`
+}
+
+type b2ListBucketsResponse struct {
+	Buckets []struct {
+		BucketID   string `json:"bucketId"`
+		BucketName string `json:"bucketName"`
+	} `json:"buckets"`
+}
+
+type b2ListFileNamesResponse struct {
+	Files []struct {
+		FileName string `json:"fileName"`
+		Action   string `json:"action"`
+	} `json:"files"`
+	NextFileName *string `json:"nextFileName"`
+}
+
+// b2Error is the error body returned by the B2 native API
+type b2Error struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *b2Error) Error() string {
+	return fmt.Sprintf("b2 API returned %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// isAuthExpired reports whether the request has to be retried with a new account authorization
+func (e *b2Error) isAuthExpired() bool {
+	return e.Status == http.StatusUnauthorized && (e.Code == "expired_auth_token" || e.Code == "bad_auth_token")
+}
+
+// isTransient reports whether the request may succeed when retried later, as opposed to an error in the
+// scaler configuration such as wrong credentials or a missing bucket
+func (e *b2Error) isTransient() bool {
+	return e.Status >= http.StatusInternalServerError || e.Status == http.StatusTooManyRequests || e.Status == http.StatusRequestTimeout
+}
+
+var b2Log = logf.Log.WithName("b2_scaler")
+
+// NewB2Scaler creates a new b2Scaler
+func NewB2Scaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseB2Metadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing b2 metadata: %s", err)
+	}
+
+	return &b2Scaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config),
+	}, nil
+}
+
+func parseB2Metadata(config *ScalerConfig) (*b2Metadata, error) {
+	meta := b2Metadata{}
+	meta.endpoint = defaultB2Endpoint
+	meta.targetFileCount = defaultB2TargetFileCount
+	meta.maxFilesToScan = defaultB2MaxFilesToScan
+
+	if val, ok := config.TriggerMetadata["bucketName"]; ok && val != "" {
+		meta.bucketName = val
+	} else {
+		return nil, fmt.Errorf("no bucketName given")
+	}
+
+	if val, ok := config.TriggerMetadata["prefix"]; ok {
+		meta.prefix = val
+	}
+
+	if val, ok := config.TriggerMetadata["endpoint"]; ok && val != "" {
+		meta.endpoint = strings.TrimSuffix(val, "/")
+	}
+
+	if val, ok := config.TriggerMetadata["targetFileCount"]; ok {
+		targetFileCount, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetFileCount: %s", err)
+		}
+		if targetFileCount <= 0 {
+			return nil, fmt.Errorf("targetFileCount must be greater than 0")
+		}
+		meta.targetFileCount = targetFileCount
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetFileCount"]; ok {
+		activationTargetFileCount, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetFileCount: %s", err)
+		}
+		if activationTargetFileCount < 0 {
+			return nil, fmt.Errorf("activationTargetFileCount must not be negative")
+		}
+		meta.activationTargetFileCount = activationTargetFileCount
+	}
+
+	if val, ok := config.TriggerMetadata["maxFilesToScan"]; ok {
+		maxFilesToScan, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing maxFilesToScan: %s", err)
+		}
+		if maxFilesToScan <= 0 {
+			return nil, fmt.Errorf("maxFilesToScan must be greater than 0")
+		}
+		meta.maxFilesToScan = maxFilesToScan
+	}
+
+	if val, ok := config.AuthParams["applicationKeyId"]; ok && val != "" {
+		meta.applicationKeyID = val
+	} else {
+		return nil, fmt.Errorf("no applicationKeyId given")
+	}
+
+	if val, ok := config.AuthParams["applicationKey"]; ok && val != "" {
+		meta.applicationKey = val
+	} else {
+		return nil, fmt.Errorf("no applicationKey given")
+	}
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// IsActive checks if there are more files than the activation target
+func (s *b2Scaler) IsActive(ctx context.Context) (bool, error) {
+	fileCount, err := s.getFileCount(ctx)
+	if err != nil {
+		logB2Error(err)
+		return false, err
+	}
+
+	return fileCount > s.metadata.activationTargetFileCount, nil
+}
+
+func (s *b2Scaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *b2Scaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("b2-%s", s.metadata.bucketName))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetFileCount),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the number of files in the bucket under the prefix
+func (s *b2Scaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	fileCount, err := s.getFileCount(ctx)
+	if err != nil {
+		logB2Error(err)
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(fileCount, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+func logB2Error(err error) {
+	if b2Err, ok := err.(*b2Error); ok && !b2Err.isTransient() {
+		b2Log.Error(err, "error counting files, check the bucket and credentials of the trigger")
+		return
+	}
+	b2Log.Error(err, "error counting files")
+}
+
+// getFileCount pages through the file names under the prefix, counting at most maxFilesToScan files
+func (s *b2Scaler) getFileCount(ctx context.Context) (int64, error) {
+	var count int64
+	var startFileName string
+
+	for count < s.metadata.maxFilesToScan {
+		maxFileCount := s.metadata.maxFilesToScan - count
+		if maxFileCount > b2MaxFileCountPerRequest {
+			maxFileCount = b2MaxFileCountPerRequest
+		}
+
+		var page b2ListFileNamesResponse
+		err := s.callAuthorized(ctx, func(auth *b2Authorization, bucketID string) error {
+			return s.callAPI(ctx, auth, "b2_list_file_names", map[string]interface{}{
+				"bucketId":      bucketID,
+				"prefix":        s.metadata.prefix,
+				"startFileName": startFileName,
+				"maxFileCount":  maxFileCount,
+			}, &page)
+		})
+		if err != nil {
+			return -1, err
+		}
+
+		for _, file := range page.Files {
+			// unfinished large files are listed as well, but are not available yet
+			if file.Action == "upload" {
+				count++
+			}
+		}
+
+		if page.NextFileName == nil {
+			break
+		}
+		startFileName = *page.NextFileName
+	}
+
+	return count, nil
+}
+
+// callAuthorized runs call with the cached account authorization, authorizing again once if B2 reports it expired
+func (s *b2Scaler) callAuthorized(ctx context.Context, call func(auth *b2Authorization, bucketID string) error) error {
+	auth, bucketID, err := s.getAuthorization(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	err = call(auth, bucketID)
+	if b2Err, ok := err.(*b2Error); ok && b2Err.isAuthExpired() {
+		b2Log.V(1).Info("b2 authorization expired, authorizing again")
+		if auth, bucketID, err = s.getAuthorization(ctx, true); err != nil {
+			return err
+		}
+		err = call(auth, bucketID)
+	}
+	return err
+}
+
+func (s *b2Scaler) getAuthorization(ctx context.Context, renew bool) (*b2Authorization, string, error) {
+	s.authLock.Lock()
+	defer s.authLock.Unlock()
+
+	if s.authorization != nil && !renew {
+		return s.authorization, s.bucketID, nil
+	}
+
+	auth, err := s.authorizeAccount(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// the bucket id doesn't change, only look it up once
+	if s.bucketID == "" {
+		var buckets b2ListBucketsResponse
+		err = s.callAPI(ctx, auth, "b2_list_buckets", map[string]interface{}{
+			"accountId":  auth.AccountID,
+			"bucketName": s.metadata.bucketName,
+		}, &buckets)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(buckets.Buckets) == 0 {
+			return nil, "", fmt.Errorf("bucket %s not found", s.metadata.bucketName)
+		}
+		s.bucketID = buckets.Buckets[0].BucketID
+	}
+
+	s.authorization = auth
+	return s.authorization, s.bucketID, nil
+}
+
+func (s *b2Scaler) authorizeAccount(ctx context.Context) (*b2Authorization, error) {
+	url := fmt.Sprintf("%s/b2api/v2/b2_authorize_account", s.metadata.endpoint)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(s.metadata.applicationKeyID, s.metadata.applicationKey)
+
+	var auth b2Authorization
+	if err := s.doRequest(req, &auth); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+func (s *b2Scaler) callAPI(ctx context.Context, auth *b2Authorization, operation string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/b2api/v2/%s", auth.APIURL, operation)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth.AuthorizationToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	return s.doRequest(req, response)
+}
+
+func (s *b2Scaler) doRequest(req *http.Request, response interface{}) error {
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		b2Err := &b2Error{Status: res.StatusCode}
+		if err := json.Unmarshal(b, b2Err); err != nil || b2Err.Code == "" {
+			b2Err.Code = http.StatusText(res.StatusCode)
+			b2Err.Message = string(b)
+		}
+		return b2Err
+	}
+
+	if err := json.Unmarshal(b, response); err != nil {
+		return fmt.Errorf("error parsing b2 response: %s", err)
+	}
+	return nil
+}
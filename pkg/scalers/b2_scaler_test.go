@@ -0,0 +1,276 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type parseB2MetadataTestData struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}
+
+type b2MetricIdentifier struct {
+	metadataTestData *parseB2MetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testB2AuthParams = map[string]string{"applicationKeyId": "keyid", "applicationKey": "key"}
+
+var testB2Metadata = []parseB2MetadataTestData{
+	// nothing passed
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"bucketName": "intake", "prefix": "incoming/", "targetFileCount": "10", "activationTargetFileCount": "2", "maxFilesToScan": "5000"}, testB2AuthParams, false},
+	// using defaults
+	{map[string]string{"bucketName": "intake"}, testB2AuthParams, false},
+	// missing bucketName
+	{map[string]string{"prefix": "incoming/"}, testB2AuthParams, true},
+	// missing applicationKeyId
+	{map[string]string{"bucketName": "intake"}, map[string]string{"applicationKey": "key"}, true},
+	// missing applicationKey
+	{map[string]string{"bucketName": "intake"}, map[string]string{"applicationKeyId": "keyid"}, true},
+	// malformed targetFileCount
+	{map[string]string{"bucketName": "intake", "targetFileCount": "a"}, testB2AuthParams, true},
+	// negative activationTargetFileCount
+	{map[string]string{"bucketName": "intake", "activationTargetFileCount": "-1"}, testB2AuthParams, true},
+	// zero maxFilesToScan
+	{map[string]string{"bucketName": "intake", "maxFilesToScan": "0"}, testB2AuthParams, true},
+}
+
+var b2MetricIdentifiers = []b2MetricIdentifier{
+	{&testB2Metadata[1], 0, "s0-b2-intake"},
+	{&testB2Metadata[1], 1, "s1-b2-intake"},
+}
+
+func TestB2ParseMetadata(t *testing.T) {
+	for _, testData := range testB2Metadata {
+		_, err := parseB2Metadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+	}
+}
+
+func TestB2GetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range b2MetricIdentifiers {
+		meta, err := parseB2Metadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockB2Scaler := b2Scaler{metadata: meta}
+
+		metricSpec := mockB2Scaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+// fakeB2Server serves the B2 native API for a single bucket holding fileCount files
+type fakeB2Server struct {
+	t           *testing.T
+	fileCount   int
+	expireToken bool
+
+	authorizations int
+	listCalls      int
+	token          string
+}
+
+func (f *fakeB2Server) writeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `{"status": %d, "code": %q, "message": "fake error"}`, status, code)
+}
+
+func (f *fakeB2Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/b2api/v2/b2_authorize_account":
+		if user, password, ok := r.BasicAuth(); !ok || user != "keyid" || password != "key" {
+			f.writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		f.authorizations++
+		f.token = fmt.Sprintf("token-%d", f.authorizations)
+		_, _ = fmt.Fprintf(w, `{"accountId": "account", "authorizationToken": %q, "apiUrl": %q}`, f.token, "http://"+r.Host)
+	case "/b2api/v2/b2_list_buckets":
+		if r.Header.Get("Authorization") != f.token {
+			f.writeError(w, http.StatusUnauthorized, "bad_auth_token")
+			return
+		}
+		_, _ = w.Write([]byte(`{"buckets": [{"bucketId": "bucket-id", "bucketName": "intake"}]}`))
+	case "/b2api/v2/b2_list_file_names":
+		if f.expireToken {
+			f.expireToken = false
+			f.writeError(w, http.StatusUnauthorized, "expired_auth_token")
+			return
+		}
+		if r.Header.Get("Authorization") != f.token {
+			f.writeError(w, http.StatusUnauthorized, "bad_auth_token")
+			return
+		}
+		f.listCalls++
+
+		var body struct {
+			BucketID      string `json:"bucketId"`
+			StartFileName string `json:"startFileName"`
+			MaxFileCount  int    `json:"maxFileCount"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Fatal(err)
+		}
+		if body.BucketID != "bucket-id" {
+			f.t.Errorf("unexpected bucketId %s", body.BucketID)
+		}
+
+		start := 0
+		if body.StartFileName != "" {
+			_, _ = fmt.Sscanf(body.StartFileName, "file-%04d", &start)
+		}
+		end := start + body.MaxFileCount
+		if end > f.fileCount {
+			end = f.fileCount
+		}
+
+		files := make([]string, 0, end-start)
+		for i := start; i < end; i++ {
+			files = append(files, fmt.Sprintf(`{"fileName": "file-%04d", "action": "upload"}`, i))
+		}
+		next := "null"
+		if end < f.fileCount {
+			next = fmt.Sprintf(`"file-%04d"`, end)
+		}
+		_, _ = fmt.Fprintf(w, `{"files": [%s], "nextFileName": %s}`, strings.Join(files, ","), next)
+	default:
+		f.t.Errorf("unexpected path %s", r.URL.Path)
+	}
+}
+
+func newTestB2Scaler(t *testing.T, endpoint string, metadata map[string]string) *b2Scaler {
+	metadata["endpoint"] = endpoint
+	meta, err := parseB2Metadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: testB2AuthParams})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	return &b2Scaler{metadata: meta, httpClient: http.DefaultClient}
+}
+
+func TestB2GetFileCount(t *testing.T) {
+	fake := &fakeB2Server{t: t, fileCount: 2500}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	scaler := newTestB2Scaler(t, server.URL, map[string]string{"bucketName": "intake", "maxFilesToScan": "10000"})
+
+	count, err := scaler.getFileCount(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if count != 2500 {
+		t.Errorf("Expected 2500 files, got %d", count)
+	}
+	if fake.listCalls != 3 {
+		t.Errorf("Expected 3 pages, got %d", fake.listCalls)
+	}
+
+	// the authorization is reused
+	if _, err := scaler.getFileCount(context.Background()); err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if fake.authorizations != 1 {
+		t.Errorf("Expected 1 authorization, got %d", fake.authorizations)
+	}
+}
+
+func TestB2GetFileCountMaxFilesToScan(t *testing.T) {
+	fake := &fakeB2Server{t: t, fileCount: 2500}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	scaler := newTestB2Scaler(t, server.URL, map[string]string{"bucketName": "intake", "maxFilesToScan": "1200"})
+
+	count, err := scaler.getFileCount(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if count != 1200 {
+		t.Errorf("Expected 1200 files, got %d", count)
+	}
+	if fake.listCalls != 2 {
+		t.Errorf("Expected 2 pages, got %d", fake.listCalls)
+	}
+}
+
+func TestB2ReauthorizesOnExpiredToken(t *testing.T) {
+	fake := &fakeB2Server{t: t, fileCount: 5}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	scaler := newTestB2Scaler(t, server.URL, map[string]string{"bucketName": "intake", "activationTargetFileCount": "4"})
+	if _, err := scaler.getFileCount(context.Background()); err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+
+	fake.expireToken = true
+	active, err := scaler.IsActive(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if !active {
+		t.Error("Expected scaler to be active")
+	}
+	if fake.authorizations != 2 {
+		t.Errorf("Expected 2 authorizations, got %d", fake.authorizations)
+	}
+}
+
+func TestB2ErrorClassification(t *testing.T) {
+	fake := &fakeB2Server{t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	meta, err := parseB2Metadata(&ScalerConfig{
+		TriggerMetadata: map[string]string{"bucketName": "intake", "endpoint": server.URL},
+		AuthParams:      map[string]string{"applicationKeyId": "wrong", "applicationKey": "key"},
+	})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	scaler := &b2Scaler{metadata: meta, httpClient: http.DefaultClient}
+
+	_, err = scaler.getFileCount(context.Background())
+	b2Err, ok := err.(*b2Error)
+	if !ok {
+		t.Fatalf("Expected a b2 error, got %v", err)
+	}
+	if b2Err.isTransient() {
+		t.Error("Expected wrong credentials to be a configuration error")
+	}
+
+	tests := []struct {
+		err       b2Error
+		transient bool
+	}{
+		{b2Error{Status: http.StatusServiceUnavailable, Code: "service_unavailable"}, true},
+		{b2Error{Status: http.StatusTooManyRequests, Code: "too_many_requests"}, true},
+		{b2Error{Status: http.StatusInternalServerError, Code: "internal_error"}, true},
+		{b2Error{Status: http.StatusBadRequest, Code: "bad_request"}, false},
+		{b2Error{Status: http.StatusUnauthorized, Code: "unauthorized"}, false},
+	}
+	for _, test := range tests {
+		if test.err.isTransient() != test.transient {
+			t.Errorf("%s: expected transient to be %v", test.err.Code, test.transient)
+		}
+	}
+}
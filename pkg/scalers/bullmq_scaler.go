@@ -0,0 +1,261 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	defaultBullMQKeyPrefix         = "bull"
+	defaultBullMQTargetQueueLength = 5
+)
+
+// bullMQStateKeyTypes are the states of the jobs of a queue that can be counted, each of them is stored in
+// the <keyPrefix>:<queueName>:<state> key, a list or a sorted set depending on the state
+var bullMQStateKeyTypes = map[string]string{
+	"wait":             "list",
+	"paused":           "list",
+	"active":           "list",
+	"delayed":          "zset",
+	"prioritized":      "zset",
+	"waiting-children": "zset",
+}
+
+var defaultBullMQStates = []string{"wait", "delayed", "prioritized"}
+
+type bullMQScaler struct {
+	metricType       v2beta2.MetricTargetType
+	metadata         *bullMQMetadata
+	closeFn          func() error
+	getQueueLengthFn func(context.Context) (int64, error)
+}
+
+type bullMQMetadata struct {
+	queueName                   string
+	keyPrefix                   string
+	states                      []string
+	targetQueueLength           int64
+	activationTargetQueueLength int64
+	databaseIndex               int
+	connectionInfo              redisConnectionInfo
+	scalerIndex                 int
+}
+
+var bullMQLog = logf.Log.WithName("bullmq_scaler")
+
+// NewBullMQScaler creates a new bullMQScaler
+func NewBullMQScaler(ctx context.Context, isClustered, isSentinel bool, config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	switch {
+	case isClustered:
+		meta, err := parseBullMQMetadata(config, parseRedisClusterAddress)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing bullmq metadata: %s", err)
+		}
+		client, err := getRedisClusterClient(ctx, meta.connectionInfo)
+		if err != nil {
+			return nil, fmt.Errorf("connection to redis cluster failed: %s", err)
+		}
+		return createBullMQScalerWithClient(client, meta, metricType), nil
+	case isSentinel:
+		meta, err := parseBullMQMetadata(config, parseRedisSentinelAddress)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing bullmq metadata: %s", err)
+		}
+		client, err := getRedisSentinelClient(ctx, meta.connectionInfo, meta.databaseIndex)
+		if err != nil {
+			return nil, fmt.Errorf("connection to redis sentinel failed: %s", err)
+		}
+		return createBullMQScalerWithClient(client, meta, metricType), nil
+	default:
+		meta, err := parseBullMQMetadata(config, parseRedisAddress)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing bullmq metadata: %s", err)
+		}
+		client, err := getRedisClient(ctx, meta.connectionInfo, meta.databaseIndex)
+		if err != nil {
+			return nil, fmt.Errorf("connection to redis failed: %s", err)
+		}
+		return createBullMQScalerWithClient(client, meta, metricType), nil
+	}
+}
+
+func createBullMQScalerWithClient(client redis.UniversalClient, meta *bullMQMetadata, metricType v2beta2.MetricTargetType) Scaler {
+	closeFn := func() error {
+		if err := client.Close(); err != nil {
+			bullMQLog.Error(err, "error closing redis client")
+			return err
+		}
+		return nil
+	}
+
+	queueLengthFn := func(ctx context.Context) (int64, error) {
+		return getBullMQQueueLength(ctx, client, meta)
+	}
+
+	return &bullMQScaler{
+		metricType:       metricType,
+		metadata:         meta,
+		closeFn:          closeFn,
+		getQueueLengthFn: queueLengthFn,
+	}
+}
+
+func parseBullMQMetadata(config *ScalerConfig, parserFn redisAddressParser) (*bullMQMetadata, error) {
+	connInfo, err := parserFn(config.TriggerMetadata, config.ResolvedEnv, config.AuthParams)
+	if err != nil {
+		return nil, err
+	}
+	meta := bullMQMetadata{
+		connectionInfo: connInfo,
+	}
+	meta.keyPrefix = defaultBullMQKeyPrefix
+	meta.states = defaultBullMQStates
+	meta.targetQueueLength = defaultBullMQTargetQueueLength
+
+	if val, ok := config.TriggerMetadata["queueName"]; ok && val != "" {
+		meta.queueName = val
+	} else {
+		return nil, fmt.Errorf("no queueName given")
+	}
+
+	// with redis cluster, BullMQ needs a hash tag like {bull} as prefix to keep the keys of a queue in the same slot
+	if val, ok := config.TriggerMetadata["keyPrefix"]; ok && val != "" {
+		meta.keyPrefix = val
+	}
+
+	if val, ok := config.TriggerMetadata["states"]; ok && val != "" {
+		meta.states = nil
+		for _, state := range splitAndTrim(val) {
+			if _, ok := bullMQStateKeyTypes[state]; !ok {
+				return nil, fmt.Errorf("unknown state %s in states, must be one of wait, paused, active, delayed, prioritized or waiting-children", state)
+			}
+			meta.states = append(meta.states, state)
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["targetQueueLength"]; ok {
+		targetQueueLength, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("targetQueueLength parsing error %s", err.Error())
+		}
+		if targetQueueLength <= 0 {
+			return nil, fmt.Errorf("targetQueueLength must be greater than 0")
+		}
+		meta.targetQueueLength = targetQueueLength
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetQueueLength"]; ok {
+		activationTargetQueueLength, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("activationTargetQueueLength parsing error %s", err.Error())
+		}
+		if activationTargetQueueLength < 0 {
+			return nil, fmt.Errorf("activationTargetQueueLength must not be negative")
+		}
+		meta.activationTargetQueueLength = activationTargetQueueLength
+	}
+
+	meta.databaseIndex = defaultDBIdx
+	if val, ok := config.TriggerMetadata["databaseIndex"]; ok {
+		dbIndex, err := strconv.ParseInt(val, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("databaseIndex: parsing error %s", err.Error())
+		}
+		meta.databaseIndex = int(dbIndex)
+	}
+	meta.scalerIndex = config.ScalerIndex
+	return &meta, nil
+}
+
+// getBullMQQueueLength sums the lengths of the keys of the states of the queue, in a single pipeline
+func getBullMQQueueLength(ctx context.Context, client redis.Cmdable, meta *bullMQMetadata) (int64, error) {
+	cmds := make([]*redis.IntCmd, len(meta.states))
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, state := range meta.states {
+			key := strings.Join([]string{meta.keyPrefix, meta.queueName, state}, ":")
+			if bullMQStateKeyTypes[state] == "zset" {
+				cmds[i] = pipe.ZCard(ctx, key)
+			} else {
+				cmds[i] = pipe.LLen(ctx, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	length := int64(0)
+	for i, cmd := range cmds {
+		val, err := cmd.Result()
+		if err != nil {
+			return -1, fmt.Errorf("error getting the %s jobs of queue %s: %s", meta.states[i], meta.queueName, err)
+		}
+		length += val
+	}
+	return length, nil
+}
+
+// IsActive checks if there are more jobs in the queue than the activation target
+func (s *bullMQScaler) IsActive(ctx context.Context) (bool, error) {
+	length, err := s.getQueueLengthFn(ctx)
+	if err != nil {
+		bullMQLog.Error(err, "error getting queue length")
+		return false, err
+	}
+
+	return length > s.metadata.activationTargetQueueLength, nil
+}
+
+func (s *bullMQScaler) Close(context.Context) error {
+	return s.closeFn()
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *bullMQScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	metricName := kedautil.NormalizeString(fmt.Sprintf("bullmq-%s", s.metadata.queueName))
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, metricName),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetQueueLength),
+	}
+	metricSpec := v2beta2.MetricSpec{
+		External: externalMetric, Type: externalMetricType,
+	}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics connects to Redis and finds the number of jobs of the queue in the states
+func (s *bullMQScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	length, err := s.getQueueLengthFn(ctx)
+	if err != nil {
+		bullMQLog.Error(err, "error getting queue length")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(length, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
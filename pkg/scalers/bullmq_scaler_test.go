@@ -0,0 +1,301 @@
+package scalers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type parseBullMQMetadataTestData struct {
+	metadata   map[string]string
+	isError    bool
+	authParams map[string]string
+}
+
+type bullMQMetricIdentifier struct {
+	metadataTestData *parseBullMQMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testBullMQMetadata = []parseBullMQMetadataTestData{
+	// nothing passed
+	{map[string]string{}, true, map[string]string{}},
+	// properly formed queueName
+	{map[string]string{"queueName": "emails", "addressFromEnv": "REDIS_HOST"}, false, map[string]string{}},
+	// missing queueName
+	{map[string]string{"addressFromEnv": "REDIS_HOST"}, true, map[string]string{}},
+	// address does not resolve
+	{map[string]string{"queueName": "emails", "addressFromEnv": "REDIS_WRONG"}, true, map[string]string{}},
+	// custom keyPrefix and states
+	{map[string]string{"queueName": "emails", "keyPrefix": "{bull}", "states": "wait, paused, active, delayed, prioritized, waiting-children"}, false, map[string]string{"address": "localhost:6379"}},
+	// unknown state
+	{map[string]string{"queueName": "emails", "states": "wait,completed"}, true, map[string]string{"address": "localhost:6379"}},
+	// properly formed targets
+	{map[string]string{"queueName": "emails", "targetQueueLength": "20", "activationTargetQueueLength": "3"}, false, map[string]string{"address": "localhost:6379"}},
+	// improperly formed targetQueueLength
+	{map[string]string{"queueName": "emails", "targetQueueLength": "AA"}, true, map[string]string{"address": "localhost:6379"}},
+	// targetQueueLength of 0
+	{map[string]string{"queueName": "emails", "targetQueueLength": "0"}, true, map[string]string{"address": "localhost:6379"}},
+	// negative activationTargetQueueLength
+	{map[string]string{"queueName": "emails", "activationTargetQueueLength": "-1"}, true, map[string]string{"address": "localhost:6379"}},
+	// improperly formed databaseIndex
+	{map[string]string{"queueName": "emails", "databaseIndex": "AA"}, true, map[string]string{"address": "localhost:6379"}},
+}
+
+var bullMQMetricIdentifiers = []bullMQMetricIdentifier{
+	{&testBullMQMetadata[1], 0, "s0-bullmq-emails"},
+	{&testBullMQMetadata[1], 1, "s1-bullmq-emails"},
+}
+
+func TestBullMQParseMetadata(t *testing.T) {
+	testCaseNum := 1
+	for _, testData := range testBullMQMetadata {
+		_, err := parseBullMQMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, ResolvedEnv: testRedisResolvedEnv, AuthParams: testData.authParams}, parseRedisAddress)
+		if err != nil && !testData.isError {
+			t.Errorf("Expected success but got error for unit test # %v: %s", testCaseNum, err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("Expected error but got success for unit test #%v", testCaseNum)
+		}
+		testCaseNum++
+	}
+}
+
+func TestBullMQParseMetadataDefaults(t *testing.T) {
+	meta, err := parseBullMQMetadata(&ScalerConfig{TriggerMetadata: map[string]string{"queueName": "emails"}, AuthParams: map[string]string{"address": "localhost:6379"}}, parseRedisAddress)
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	if meta.keyPrefix != "bull" {
+		t.Errorf("Expected keyPrefix bull, got %s", meta.keyPrefix)
+	}
+	if strings.Join(meta.states, ",") != "wait,delayed,prioritized" {
+		t.Errorf("Expected states wait,delayed,prioritized, got %v", meta.states)
+	}
+	if meta.targetQueueLength != 5 || meta.activationTargetQueueLength != 0 {
+		t.Errorf("Expected targets 5 and 0, got %d and %d", meta.targetQueueLength, meta.activationTargetQueueLength)
+	}
+}
+
+func TestBullMQParseClusterAndSentinelMetadata(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		parserFn redisAddressParser
+		metadata map[string]string
+	}{
+		{"cluster", parseRedisClusterAddress, map[string]string{"queueName": "emails", "keyPrefix": "{bull}", "addresses": "a:1, b:2, c:3"}},
+		{"sentinel", parseRedisSentinelAddress, map[string]string{"queueName": "emails", "addresses": "a:1, b:2, c:3", "sentinelMaster": "mymaster"}},
+	} {
+		meta, err := parseBullMQMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: map[string]string{}}, testData.parserFn)
+		if err != nil {
+			t.Fatalf("%s: could not parse metadata: %s", testData.name, err)
+		}
+		if len(meta.connectionInfo.addresses) != 3 {
+			t.Errorf("%s: expected 3 addresses, got %v", testData.name, meta.connectionInfo.addresses)
+		}
+	}
+}
+
+func TestBullMQGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range bullMQMetricIdentifiers {
+		meta, err := parseBullMQMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, ResolvedEnv: testRedisResolvedEnv, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex}, parseRedisAddress)
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockBullMQScaler := bullMQScaler{
+			metadata: meta,
+		}
+
+		metricSpec := mockBullMQScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+// testBullMQKeys is the layout of the keys of BullMQ queues, with the jobs hashes and the meta, events and
+// finished keys next to the keys of the states
+var testBullMQKeys = map[string][]string{
+	"bull:emails:id":               {"string", "12"},
+	"bull:emails:meta":             {"hash", "opts.maxLenEvents", "10000"},
+	"bull:emails:events":           {"stream"},
+	"bull:emails:1":                {"hash", "name", "welcome", "data", "{}"},
+	"bull:emails:wait":             {"list", "9", "8", "7"},
+	"bull:emails:paused":           {"list"},
+	"bull:emails:active":           {"list", "6", "5"},
+	"bull:emails:delayed":          {"zset", "4", "3"},
+	"bull:emails:prioritized":      {"zset", "2"},
+	"bull:emails:waiting-children": {"zset", "10"},
+	"bull:emails:completed":        {"zset", "1", "11", "12"},
+	"bull:emails:failed":           {"zset", "13"},
+	"bull:reports:wait":            {"list", "1", "2", "3", "4"},
+	"bull:mistyped:wait":           {"list", "1"},
+	"bull:mistyped:delayed":        {"list", "2"},
+	"{bull}:emails:wait":           {"list", "1"},
+	"{bull}:emails:delayed":        {"zset", "2"},
+}
+
+// newBullMQTestServer starts a single node answering the commands used to count the jobs, COMMAND and CLUSTER SLOTS
+// with all the slots on itself so the cluster client can be used against it too
+func newBullMQTestServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveBullMQTestConn(conn, host, port)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveBullMQTestConn(conn net.Conn, host, port string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+
+		var reply string
+		switch strings.ToLower(args[0]) {
+		case "ping":
+			reply = "+PONG\r\n"
+		case "command":
+			reply = "*3\r\n" +
+				"*6\r\n$4\r\nping\r\n:-1\r\n*0\r\n:0\r\n:0\r\n:0\r\n" +
+				"*6\r\n$4\r\nllen\r\n:2\r\n*0\r\n:1\r\n:1\r\n:1\r\n" +
+				"*6\r\n$5\r\nzcard\r\n:2\r\n*0\r\n:1\r\n:1\r\n:1\r\n"
+		case "cluster":
+			reply = fmt.Sprintf("*1\r\n*3\r\n:0\r\n:16383\r\n*3\r\n$%d\r\n%s\r\n:%s\r\n$2\r\nid\r\n", len(host), host, port)
+		case "llen", "zcard":
+			keyType := "list"
+			if strings.ToLower(args[0]) == "zcard" {
+				keyType = "zset"
+			}
+			key, ok := testBullMQKeys[args[1]]
+			switch {
+			case !ok:
+				reply = ":0\r\n"
+			case key[0] != keyType:
+				reply = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+			default:
+				reply = fmt.Sprintf(":%d\r\n", len(key)-1)
+			}
+		default:
+			reply = fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestBullMQGetQueueLength(t *testing.T) {
+	address := newBullMQTestServer(t)
+
+	for _, testData := range []struct {
+		name     string
+		metadata map[string]string
+		length   int64
+		isActive bool
+	}{
+		{"default states", map[string]string{"queueName": "emails"}, 6, true},
+		{"all the states", map[string]string{"queueName": "emails", "states": "wait,paused,active,delayed,prioritized,waiting-children"}, 9, true},
+		{"a single state", map[string]string{"queueName": "reports", "states": "wait"}, 4, true},
+		{"under the activation target", map[string]string{"queueName": "emails", "activationTargetQueueLength": "6"}, 6, false},
+		{"a queue without jobs", map[string]string{"queueName": "sms"}, 0, false},
+		{"a custom keyPrefix", map[string]string{"queueName": "emails", "keyPrefix": "{bull}"}, 2, true},
+	} {
+		meta, err := parseBullMQMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: map[string]string{"address": address, "addresses": address}}, parseRedisAddress)
+		if err != nil {
+			t.Fatalf("%s: could not parse metadata: %s", testData.name, err)
+		}
+
+		clients := map[string]redis.UniversalClient{}
+		clients["redis"], err = getRedisClient(context.Background(), meta.connectionInfo, meta.databaseIndex)
+		if err != nil {
+			t.Fatalf("%s: could not connect: %s", testData.name, err)
+		}
+		clients["redis-cluster"], err = getRedisClusterClient(context.Background(), redisConnectionInfo{addresses: []string{address}})
+		if err != nil {
+			t.Fatalf("%s: could not connect to the cluster: %s", testData.name, err)
+		}
+
+		for clientName, client := range clients {
+			s := createBullMQScalerWithClient(client, meta, "")
+			metrics, err := s.GetMetrics(context.Background(), "s0-bullmq-emails", nil)
+			if err != nil {
+				t.Fatalf("%s with %s: unexpected error: %s", testData.name, clientName, err)
+			}
+			if value := metrics[0].Value.Value(); value != testData.length {
+				t.Errorf("%s with %s: expected %d, got %d", testData.name, clientName, testData.length, value)
+			}
+
+			isActive, err := s.IsActive(context.Background())
+			if err != nil {
+				t.Fatalf("%s with %s: unexpected error: %s", testData.name, clientName, err)
+			}
+			if isActive != testData.isActive {
+				t.Errorf("%s with %s: expected active %t, got %t", testData.name, clientName, testData.isActive, isActive)
+			}
+
+			if err := s.Close(context.Background()); err != nil {
+				t.Errorf("%s with %s: unexpected error closing the scaler: %s", testData.name, clientName, err)
+			}
+		}
+	}
+}
+
+func TestBullMQGetQueueLengthWrongType(t *testing.T) {
+	address := newBullMQTestServer(t)
+	client := redis.NewClient(&redis.Options{Addr: address})
+	defer client.Close()
+
+	// a key of another type than the state is stored in, is reported rather than counted as empty
+	meta := &bullMQMetadata{queueName: "mistyped", keyPrefix: "bull", states: []string{"wait", "delayed"}}
+	if _, err := getBullMQQueueLength(context.Background(), client, meta); err == nil {
+		t.Error("Expected an error counting a list as a sorted set")
+	}
+}
@@ -0,0 +1,243 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	custommetrics "k8s.io/metrics/pkg/client/custom_metrics"
+	ctrlconfig "sigs.k8s.io/controller-runtime/pkg/client/config"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+type customMetricsAPIScaler struct {
+	metricType    v2beta2.MetricTargetType
+	metadata      *customMetricsAPIMetadata
+	client        custommetrics.CustomMetricsClient
+	availableAPIs custommetrics.AvailableAPIsGetter
+}
+
+type customMetricsAPIMetadata struct {
+	metricName            string
+	metricSelector        labels.Selector
+	describedObject       schema.GroupKind
+	describedObjectName   string
+	namespace             string
+	targetValue           int64
+	activationTargetValue float64
+	scalerIndex           int
+}
+
+var customMetricsAPILog = logf.Log.WithName("custom_metrics_api_scaler")
+
+// the custom metrics client of the operator is shared by all the scalers,
+// the discovery of the custom.metrics.k8s.io versions and the REST mapping are cached
+var (
+	customMetricsClientOnce    sync.Once
+	customMetricsClient        custommetrics.CustomMetricsClient
+	customMetricsAvailableAPIs custommetrics.AvailableAPIsGetter
+	customMetricsClientErr     error
+)
+
+func getCustomMetricsClient() (custommetrics.CustomMetricsClient, custommetrics.AvailableAPIsGetter, error) {
+	customMetricsClientOnce.Do(func() {
+		restConfig, err := ctrlconfig.GetConfig()
+		if err != nil {
+			customMetricsClientErr = fmt.Errorf("error getting the in-cluster config: %s", err)
+			return
+		}
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+		if err != nil {
+			customMetricsClientErr = fmt.Errorf("error creating the discovery client: %s", err)
+			return
+		}
+		cachedDiscoveryClient := memory.NewMemCacheClient(discoveryClient)
+		restMapper := restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscoveryClient)
+		customMetricsAvailableAPIs = custommetrics.NewAvailableAPIsGetter(discoveryClient)
+		customMetricsClient = custommetrics.NewForConfig(restConfig, restMapper, customMetricsAvailableAPIs)
+	})
+	return customMetricsClient, customMetricsAvailableAPIs, customMetricsClientErr
+}
+
+// NewCustomMetricsAPIScaler creates a new customMetricsAPIScaler
+func NewCustomMetricsAPIScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseCustomMetricsAPIMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing custom metrics API metadata: %s", err)
+	}
+
+	client, availableAPIs, err := getCustomMetricsClient()
+	if err != nil {
+		return nil, fmt.Errorf("error creating custom metrics API client: %s", err)
+	}
+
+	return &customMetricsAPIScaler{
+		metricType:    metricType,
+		metadata:      meta,
+		client:        client,
+		availableAPIs: availableAPIs,
+	}, nil
+}
+
+func parseCustomMetricsAPIMetadata(config *ScalerConfig) (*customMetricsAPIMetadata, error) {
+	meta := customMetricsAPIMetadata{}
+	meta.namespace = config.Namespace
+
+	if val, ok := config.TriggerMetadata["metricName"]; ok && val != "" {
+		meta.metricName = val
+	} else {
+		return nil, fmt.Errorf("no metricName given")
+	}
+
+	meta.metricSelector = labels.Everything()
+	if val, ok := config.TriggerMetadata["metricSelector"]; ok && val != "" {
+		selector, err := labels.Parse(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing metricSelector: %s", err)
+		}
+		meta.metricSelector = selector
+	}
+
+	if val, ok := config.TriggerMetadata["describedObjectKind"]; ok && val != "" {
+		meta.describedObject.Kind = val
+	} else {
+		return nil, fmt.Errorf("no describedObjectKind given")
+	}
+
+	if val, ok := config.TriggerMetadata["describedObjectAPIVersion"]; ok && val != "" {
+		gv, err := schema.ParseGroupVersion(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing describedObjectAPIVersion: %s", err)
+		}
+		meta.describedObject.Group = gv.Group
+	}
+
+	if val, ok := config.TriggerMetadata["describedObjectName"]; ok && val != "" {
+		meta.describedObjectName = val
+	} else if meta.isNamespaceMetric() {
+		// metrics describing the namespace of the ScaledObject
+		meta.describedObjectName = meta.namespace
+	} else {
+		return nil, fmt.Errorf("no describedObjectName given")
+	}
+
+	if val, ok := config.TriggerMetadata["targetValue"]; ok {
+		targetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetValue: %s", err)
+		}
+		if targetValue <= 0 {
+			return nil, fmt.Errorf("targetValue must be greater than 0")
+		}
+		meta.targetValue = targetValue
+	} else {
+		return nil, fmt.Errorf("no targetValue given")
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetValue"]; ok {
+		activationTargetValue, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetValue: %s", err)
+		}
+		meta.activationTargetValue = activationTargetValue
+	}
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// isNamespaceMetric checks if the metric describes a namespace, a special case of the root-scoped metrics
+func (m *customMetricsAPIMetadata) isNamespaceMetric() bool {
+	return m.describedObject.Group == "" && m.describedObject.Kind == "Namespace"
+}
+
+// IsActive checks if the metric is above the activation target
+func (s *customMetricsAPIScaler) IsActive(ctx context.Context) (bool, error) {
+	value, err := s.getMetricValue()
+	if err != nil {
+		customMetricsAPILog.Error(err, "error getting metric from the custom metrics API")
+		return false, err
+	}
+
+	return value.AsApproximateFloat64() > s.metadata.activationTargetValue, nil
+}
+
+func (s *customMetricsAPIScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *customMetricsAPIScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	metricName := fmt.Sprintf("custom-metrics-api-%s-%s-%s", strings.ToLower(s.metadata.describedObject.Kind), s.metadata.describedObjectName, s.metadata.metricName)
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(metricName)),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the value of the metric describing the object from the custom metrics API
+func (s *customMetricsAPIScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	value, err := s.getMetricValue()
+	if err != nil {
+		customMetricsAPILog.Error(err, "error getting metric from the custom metrics API")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewMilliQuantity(value.MilliValue(), resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+func (s *customMetricsAPIScaler) getMetricValue() (resource.Quantity, error) {
+	// the versions served by the adapter are cached, this fails fast when no adapter is installed
+	if _, err := s.availableAPIs.PreferredVersion(); err != nil {
+		return resource.Quantity{}, fmt.Errorf("the custom.metrics.k8s.io API is not available, check that a custom metrics adapter is installed: %s", err)
+	}
+
+	var metrics custommetrics.MetricsInterface
+	if s.metadata.isNamespaceMetric() {
+		metrics = s.client.RootScopedMetrics()
+	} else {
+		metrics = s.client.NamespacedMetrics(s.metadata.namespace)
+	}
+
+	value, err := metrics.GetForObject(s.metadata.describedObject, s.metadata.describedObjectName, s.metadata.metricName, s.metadata.metricSelector)
+	if err != nil {
+		// the adapter may have been upgraded to serve another version
+		s.availableAPIs.Invalidate()
+		if apierrors.IsNotFound(err) {
+			return resource.Quantity{}, fmt.Errorf("metric %s describing %s %s not found, check metricName and describedObject: %s",
+				s.metadata.metricName, s.metadata.describedObject.String(), s.metadata.describedObjectName, err)
+		}
+		return resource.Quantity{}, err
+	}
+	return value.Value, nil
+}
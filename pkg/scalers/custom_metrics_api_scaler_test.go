@@ -0,0 +1,184 @@
+package scalers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/metrics/pkg/apis/custom_metrics/v1beta2"
+	"k8s.io/metrics/pkg/client/custom_metrics/fake"
+)
+
+type parseCustomMetricsAPIMetadataTestData struct {
+	metadata map[string]string
+	isError  bool
+}
+
+type customMetricsAPIMetricIdentifier struct {
+	metadataTestData *parseCustomMetricsAPIMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testCustomMetricsAPIMetadata = []parseCustomMetricsAPIMetadataTestData{
+	// nothing passed
+	{map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"metricName": "requests_per_second", "describedObjectKind": "Service", "describedObjectName": "frontend", "targetValue": "100", "activationTargetValue": "0.5"}, false},
+	// object of an API group
+	{map[string]string{"metricName": "queue_length", "describedObjectKind": "Deployment", "describedObjectAPIVersion": "apps/v1", "describedObjectName": "worker", "targetValue": "10", "metricSelector": "queue=orders"}, false},
+	// namespace metric defaults to the namespace of the ScaledObject
+	{map[string]string{"metricName": "pending_jobs", "describedObjectKind": "Namespace", "targetValue": "10"}, false},
+	// missing metricName
+	{map[string]string{"describedObjectKind": "Service", "describedObjectName": "frontend", "targetValue": "100"}, true},
+	// missing describedObjectKind
+	{map[string]string{"metricName": "requests_per_second", "describedObjectName": "frontend", "targetValue": "100"}, true},
+	// missing describedObjectName
+	{map[string]string{"metricName": "requests_per_second", "describedObjectKind": "Service", "targetValue": "100"}, true},
+	// missing targetValue
+	{map[string]string{"metricName": "requests_per_second", "describedObjectKind": "Service", "describedObjectName": "frontend"}, true},
+	// malformed activationTargetValue
+	{map[string]string{"metricName": "requests_per_second", "describedObjectKind": "Service", "describedObjectName": "frontend", "targetValue": "100", "activationTargetValue": "a"}, true},
+	// malformed metricSelector
+	{map[string]string{"metricName": "requests_per_second", "describedObjectKind": "Service", "describedObjectName": "frontend", "targetValue": "100", "metricSelector": "a=(b"}, true},
+	// malformed describedObjectAPIVersion
+	{map[string]string{"metricName": "requests_per_second", "describedObjectKind": "Deployment", "describedObjectAPIVersion": "apps/v1/x", "describedObjectName": "worker", "targetValue": "100"}, true},
+}
+
+var customMetricsAPIMetricIdentifiers = []customMetricsAPIMetricIdentifier{
+	{&testCustomMetricsAPIMetadata[1], 0, "s0-custom-metrics-api-service-frontend-requests_per_second"},
+	{&testCustomMetricsAPIMetadata[3], 1, "s1-custom-metrics-api-namespace-default-pending_jobs"},
+}
+
+// fakeAvailableAPIs is the discovery of the custom.metrics.k8s.io versions
+type fakeAvailableAPIs struct {
+	err         error
+	invalidated int
+}
+
+func (f *fakeAvailableAPIs) PreferredVersion() (schema.GroupVersion, error) {
+	return v1beta2.SchemeGroupVersion, f.err
+}
+
+func (f *fakeAvailableAPIs) Invalidate() {
+	f.invalidated++
+}
+
+func TestCustomMetricsAPIParseMetadata(t *testing.T) {
+	for _, testData := range testCustomMetricsAPIMetadata {
+		_, err := parseCustomMetricsAPIMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, Namespace: "default"})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+	}
+}
+
+func TestCustomMetricsAPIGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range customMetricsAPIMetricIdentifiers {
+		meta, err := parseCustomMetricsAPIMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, Namespace: "default", ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockCustomMetricsAPIScaler := customMetricsAPIScaler{metadata: meta}
+
+		metricSpec := mockCustomMetricsAPIScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+func newTestCustomMetricsAPIScaler(t *testing.T, metadata map[string]string, reaction k8stesting.ReactionFunc) (*customMetricsAPIScaler, *fake.FakeCustomMetricsClient, *fakeAvailableAPIs) {
+	meta, err := parseCustomMetricsAPIMetadata(&ScalerConfig{TriggerMetadata: metadata, Namespace: "default"})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	client := &fake.FakeCustomMetricsClient{}
+	client.AddReactor("get", "*", reaction)
+	availableAPIs := &fakeAvailableAPIs{}
+	return &customMetricsAPIScaler{metadata: meta, client: client, availableAPIs: availableAPIs}, client, availableAPIs
+}
+
+func metricValueReaction(value string) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &v1beta2.MetricValueList{Items: []v1beta2.MetricValue{{Value: resource.MustParse(value)}}}, nil
+	}
+}
+
+func TestCustomMetricsAPIGetMetrics(t *testing.T) {
+	metadata := map[string]string{"metricName": "requests_per_second", "describedObjectKind": "Service", "describedObjectName": "frontend", "targetValue": "100", "activationTargetValue": "1"}
+	s, client, _ := newTestCustomMetricsAPIScaler(t, metadata, metricValueReaction("1500m"))
+
+	metrics, err := s.GetMetrics(context.Background(), "custom-metrics-api", nil)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if metrics[0].Value.MilliValue() != 1500 {
+		t.Errorf("Expected 1500m, got %s", metrics[0].Value.String())
+	}
+
+	active, err := s.IsActive(context.Background())
+	if err != nil || !active {
+		t.Errorf("Expected the scaler to be active, got %t, %v", active, err)
+	}
+
+	action := client.Actions()[0].(fake.GetForActionImpl)
+	if action.GetNamespace() != "default" || action.GetName() != "frontend" || action.GetMetricName() != "requests_per_second" {
+		t.Errorf("Unexpected request of the metric %s of %s/%s", action.GetMetricName(), action.GetNamespace(), action.GetName())
+	}
+}
+
+func TestCustomMetricsAPINamespaceMetric(t *testing.T) {
+	metadata := map[string]string{"metricName": "pending_jobs", "describedObjectKind": "Namespace", "targetValue": "10"}
+	s, client, _ := newTestCustomMetricsAPIScaler(t, metadata, metricValueReaction("0"))
+
+	active, err := s.IsActive(context.Background())
+	if err != nil || active {
+		t.Errorf("Expected the scaler to be inactive, got %t, %v", active, err)
+	}
+
+	// namespace metrics are root-scoped
+	action := client.Actions()[0].(fake.GetForActionImpl)
+	if action.GetNamespace() != "" || action.GetName() != "default" {
+		t.Errorf("Unexpected request of the metric of %s/%s", action.GetNamespace(), action.GetName())
+	}
+}
+
+func TestCustomMetricsAPIMissingMetric(t *testing.T) {
+	metadata := map[string]string{"metricName": "missing", "describedObjectKind": "Service", "describedObjectName": "frontend", "targetValue": "100"}
+	s, _, availableAPIs := newTestCustomMetricsAPIScaler(t, metadata, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "custom.metrics.k8s.io", Resource: "services"}, "frontend")
+	})
+
+	_, err := s.GetMetrics(context.Background(), "custom-metrics-api", nil)
+	if err == nil || !strings.Contains(err.Error(), "metric missing describing Service frontend not found") {
+		t.Error("Expected a configuration error for the missing metric, got", err)
+	}
+	if availableAPIs.invalidated != 1 {
+		t.Error("Expected the available API versions to be invalidated")
+	}
+}
+
+func TestCustomMetricsAPIDiscoveryFailure(t *testing.T) {
+	metadata := map[string]string{"metricName": "requests_per_second", "describedObjectKind": "Service", "describedObjectName": "frontend", "targetValue": "100"}
+	s, client, availableAPIs := newTestCustomMetricsAPIScaler(t, metadata, metricValueReaction("1"))
+	availableAPIs.err = errors.New("the server could not find the requested resource")
+
+	_, err := s.GetMetrics(context.Background(), "custom-metrics-api", nil)
+	if err == nil || !strings.Contains(err.Error(), "custom.metrics.k8s.io API is not available") {
+		t.Error("Expected a configuration error for the missing API, got", err)
+	}
+	if len(client.Actions()) != 0 {
+		t.Error("Expected no metric request without the API")
+	}
+}
@@ -0,0 +1,292 @@
+package scalers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	defaultDagsterTargetQueuedRuns = 1
+	defaultDagsterQueueTagKey      = "dagster/queue"
+	dagsterRunsPageSize            = 100
+
+	// dagsterQueuedRunsQuery lists the queued runs tagged with a queue name, a page at a time
+	dagsterQueuedRunsQuery = `query QueuedRuns($filter: RunsFilter, $cursor: String, $limit: Int) {
+  runsOrError(filter: $filter, cursor: $cursor, limit: $limit) {
+    __typename
+    ... on Runs { results { runId } }
+    ... on InvalidPipelineRunsFilterError { message }
+    ... on PythonError { message }
+  }
+}`
+)
+
+type dagsterScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *dagsterMetadata
+	httpClient *http.Client
+}
+
+type dagsterMetadata struct {
+	apiURL                     string
+	apiToken                   string
+	queueName                  string
+	queueTagKey                string
+	targetQueuedRuns           int64
+	activationTargetQueuedRuns int64
+	unsafeSsl                  bool
+	scalerIndex                int
+}
+
+type dagsterGraphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type dagsterGraphQLResponse struct {
+	Data struct {
+		RunsOrError struct {
+			Typename string `json:"__typename"`
+			Message  string `json:"message"`
+			Results  []struct {
+				RunID string `json:"runId"`
+			} `json:"results"`
+		} `json:"runsOrError"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+var dagsterLog = logf.Log.WithName("dagster_scaler")
+
+// NewDagsterScaler creates a new dagsterScaler
+func NewDagsterScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseDagsterMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing dagster metadata: %s", err)
+	}
+
+	return &dagsterScaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, meta.unsafeSsl), config),
+	}, nil
+}
+
+func parseDagsterMetadata(config *ScalerConfig) (*dagsterMetadata, error) {
+	meta := dagsterMetadata{}
+	meta.targetQueuedRuns = defaultDagsterTargetQueuedRuns
+	meta.queueTagKey = defaultDagsterQueueTagKey
+
+	if val, ok := config.TriggerMetadata["apiURL"]; ok && val != "" {
+		meta.apiURL = strings.TrimSuffix(val, "/")
+	} else {
+		return nil, fmt.Errorf("no apiURL given")
+	}
+
+	if val, ok := config.TriggerMetadata["queueName"]; ok && val != "" {
+		meta.queueName = val
+	} else {
+		return nil, fmt.Errorf("no queueName given")
+	}
+
+	if val, ok := config.TriggerMetadata["queueTagKey"]; ok && val != "" {
+		meta.queueTagKey = val
+	}
+
+	if val, ok := config.TriggerMetadata["targetQueuedRuns"]; ok {
+		targetQueuedRuns, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetQueuedRuns: %s", err)
+		}
+		if targetQueuedRuns <= 0 {
+			return nil, fmt.Errorf("targetQueuedRuns must be greater than 0")
+		}
+		meta.targetQueuedRuns = targetQueuedRuns
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetQueuedRuns"]; ok {
+		activationTargetQueuedRuns, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetQueuedRuns: %s", err)
+		}
+		if activationTargetQueuedRuns < 0 {
+			return nil, fmt.Errorf("activationTargetQueuedRuns must not be negative")
+		}
+		meta.activationTargetQueuedRuns = activationTargetQueuedRuns
+	}
+
+	if val, ok := config.TriggerMetadata["unsafeSsl"]; ok {
+		unsafeSsl, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing unsafeSsl: %s", err)
+		}
+		meta.unsafeSsl = unsafeSsl
+	}
+
+	// the token is optional, open source Dagster deployments don't require one
+	if val, ok := config.AuthParams["apiToken"]; ok {
+		meta.apiToken = val
+	}
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// IsActive checks if there are more queued runs than the activation target
+func (s *dagsterScaler) IsActive(ctx context.Context) (bool, error) {
+	queuedRuns, err := s.getQueuedRuns(ctx)
+	if err != nil {
+		dagsterLog.Error(err, "error getting queued runs")
+		return false, err
+	}
+
+	return queuedRuns > s.metadata.activationTargetQueuedRuns, nil
+}
+
+func (s *dagsterScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *dagsterScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("dagster-%s", s.metadata.queueName))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetQueuedRuns),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the number of queued runs for the queue
+func (s *dagsterScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	queuedRuns, err := s.getQueuedRuns(ctx)
+	if err != nil {
+		dagsterLog.Error(err, "error getting queued runs")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(queuedRuns, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getQueuedRuns pages through the queued runs of the queue and counts them
+func (s *dagsterScaler) getQueuedRuns(ctx context.Context) (int64, error) {
+	var count int64
+	var cursor interface{}
+
+	for {
+		page, err := s.getQueuedRunsPage(ctx, cursor)
+		if err != nil {
+			return -1, err
+		}
+
+		count += int64(len(page))
+		if len(page) < dagsterRunsPageSize {
+			break
+		}
+		cursor = page[len(page)-1]
+	}
+
+	return count, nil
+}
+
+// getQueuedRunsPage returns the run ids of a single page of queued runs, starting after cursor
+func (s *dagsterScaler) getQueuedRunsPage(ctx context.Context, cursor interface{}) ([]string, error) {
+	body, err := json.Marshal(dagsterGraphQLRequest{
+		Query: dagsterQueuedRunsQuery,
+		Variables: map[string]interface{}{
+			"filter": map[string]interface{}{
+				"statuses": []string{"QUEUED"},
+				"tags":     []map[string]string{{"key": s.metadata.queueTagKey, "value": s.metadata.queueName}},
+			},
+			"cursor": cursor,
+			"limit":  dagsterRunsPageSize,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/graphql", s.metadata.apiURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.metadata.apiToken != "" {
+		req.Header.Set("Dagster-Cloud-Api-Token", s.metadata.apiToken)
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dagster GraphQL API returned %d: %s", res.StatusCode, string(b))
+	}
+
+	return parseDagsterQueuedRuns(b)
+}
+
+func parseDagsterQueuedRuns(b []byte) ([]string, error) {
+	var response dagsterGraphQLResponse
+	if err := json.Unmarshal(b, &response); err != nil {
+		return nil, fmt.Errorf("error parsing dagster GraphQL response: %s", err)
+	}
+
+	if len(response.Errors) > 0 {
+		messages := make([]string, 0, len(response.Errors))
+		for _, e := range response.Errors {
+			messages = append(messages, e.Message)
+		}
+		return nil, fmt.Errorf("dagster GraphQL API returned errors: %s", strings.Join(messages, "; "))
+	}
+
+	runsOrError := response.Data.RunsOrError
+	if runsOrError.Typename != "Runs" {
+		return nil, fmt.Errorf("dagster GraphQL API returned %s: %s", runsOrError.Typename, runsOrError.Message)
+	}
+
+	runIDs := make([]string, 0, len(runsOrError.Results))
+	for _, run := range runsOrError.Results {
+		runIDs = append(runIDs, run.RunID)
+	}
+	return runIDs, nil
+}
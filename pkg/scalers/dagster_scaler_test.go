@@ -0,0 +1,162 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type parseDagsterMetadataTestData struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}
+
+type dagsterMetricIdentifier struct {
+	metadataTestData *parseDagsterMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testDagsterMetadata = []parseDagsterMetadataTestData{
+	// nothing passed
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"apiURL": "http://dagster:3000", "queueName": "etl", "targetQueuedRuns": "5", "activationTargetQueuedRuns": "2"}, map[string]string{"apiToken": "token"}, false},
+	// using defaults
+	{map[string]string{"apiURL": "http://dagster:3000", "queueName": "etl"}, map[string]string{}, false},
+	// missing apiURL
+	{map[string]string{"queueName": "etl"}, map[string]string{}, true},
+	// missing queueName
+	{map[string]string{"apiURL": "http://dagster:3000", "queueName": ""}, map[string]string{}, true},
+	// malformed targetQueuedRuns
+	{map[string]string{"apiURL": "http://dagster:3000", "queueName": "etl", "targetQueuedRuns": "a"}, map[string]string{}, true},
+	// zero targetQueuedRuns
+	{map[string]string{"apiURL": "http://dagster:3000", "queueName": "etl", "targetQueuedRuns": "0"}, map[string]string{}, true},
+	// negative activationTargetQueuedRuns
+	{map[string]string{"apiURL": "http://dagster:3000", "queueName": "etl", "activationTargetQueuedRuns": "-1"}, map[string]string{}, true},
+}
+
+var dagsterMetricIdentifiers = []dagsterMetricIdentifier{
+	{&testDagsterMetadata[1], 0, "s0-dagster-etl"},
+	{&testDagsterMetadata[1], 1, "s1-dagster-etl"},
+}
+
+func TestDagsterParseMetadata(t *testing.T) {
+	for _, testData := range testDagsterMetadata {
+		_, err := parseDagsterMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+	}
+}
+
+func TestDagsterGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range dagsterMetricIdentifiers {
+		meta, err := parseDagsterMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockDagsterScaler := dagsterScaler{metadata: meta}
+
+		metricSpec := mockDagsterScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+func dagsterRunsFixture(from, to int) string {
+	runs := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		runs = append(runs, fmt.Sprintf(`{"runId": "run-%d"}`, i))
+	}
+	return fmt.Sprintf(`{"data": {"runsOrError": {"__typename": "Runs", "results": [%s]}}}`, strings.Join(runs, ","))
+}
+
+func TestDagsterGetQueuedRuns(t *testing.T) {
+	// 150 queued runs are returned in two pages
+	pages := map[string]string{
+		"":       dagsterRunsFixture(0, 100),
+		"run-99": dagsterRunsFixture(100, 150),
+	}
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/graphql" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Dagster-Cloud-Api-Token") != "token" {
+			t.Error("api token header not set")
+		}
+
+		var body dagsterGraphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		cursor, _ := body.Variables["cursor"].(string)
+		_, _ = w.Write([]byte(pages[cursor]))
+	}))
+	defer server.Close()
+
+	meta, err := parseDagsterMetadata(&ScalerConfig{
+		TriggerMetadata: map[string]string{"apiURL": server.URL, "queueName": "etl", "activationTargetQueuedRuns": "149"},
+		AuthParams:      map[string]string{"apiToken": "token"},
+	})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	scaler := dagsterScaler{metadata: meta, httpClient: http.DefaultClient}
+
+	count, err := scaler.getQueuedRuns(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if count != 150 {
+		t.Errorf("Expected 150 queued runs, got %d", count)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+
+	active, err := scaler.IsActive(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if !active {
+		t.Error("Expected scaler to be active")
+	}
+}
+
+func TestDagsterParseQueuedRunsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		errorMsg string
+	}{
+		{"graphql errors", `{"errors": [{"message": "Cannot query field \"runsOrError\""}]}`, "Cannot query field"},
+		{"invalid filter", `{"data": {"runsOrError": {"__typename": "InvalidPipelineRunsFilterError", "message": "bad filter"}}}`, "bad filter"},
+		{"python error", `{"data": {"runsOrError": {"__typename": "PythonError", "message": "boom"}}}`, "boom"},
+		{"malformed", `{"data": `, "error parsing"},
+	}
+
+	for _, test := range tests {
+		_, err := parseDagsterQueuedRuns([]byte(test.response))
+		if err == nil {
+			t.Errorf("%s: expected error but got success", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.errorMsg) {
+			t.Errorf("%s: expected error to contain %q, got %q", test.name, test.errorMsg, err)
+		}
+	}
+}
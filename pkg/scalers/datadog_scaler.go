@@ -189,7 +189,7 @@ func newDatadogConnection(ctx context.Context, meta *datadogMetadata, config *Sc
 		})
 
 	configuration := datadog.NewConfiguration()
-	configuration.HTTPClient = kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false)
+	configuration.HTTPClient = WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config)
 	apiClient := datadog.NewAPIClient(configuration)
 
 	_, _, err := apiClient.AuthenticationApi.Validate(ctx) //nolint:bodyclose
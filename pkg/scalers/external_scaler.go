@@ -19,6 +19,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	pb "github.com/kedacore/keda/v2/pkg/scalers/externalscaler"
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
 )
 
 type externalScaler struct {
@@ -299,15 +300,17 @@ func getClientForConnectionPool(metadata externalScalerMetadata) (pb.ExternalSca
 	defer connectionPoolMutex.Unlock()
 
 	buildGRPCConnection := func(metadata externalScalerMetadata) (*grpc.ClientConn, error) {
+		// the connections are shared by the external and external-push triggers, so both are reported as external
+		userAgent := grpc.WithUserAgent(kedautil.UserAgent("external"))
 		if metadata.tlsCertFile != "" {
 			creds, err := credentials.NewClientTLSFromFile(metadata.tlsCertFile, "")
 			if err != nil {
 				return nil, err
 			}
-			return grpc.Dial(metadata.scalerAddress, grpc.WithTransportCredentials(creds))
+			return grpc.Dial(metadata.scalerAddress, grpc.WithTransportCredentials(creds), userAgent)
 		}
 
-		return grpc.Dial(metadata.scalerAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		return grpc.Dial(metadata.scalerAddress, grpc.WithTransportCredentials(insecure.NewCredentials()), userAgent)
 	}
 
 	// create a unique key per-metadata. If scaledObjects share the same connection properties
@@ -8,10 +8,13 @@ import (
 	"testing"
 	"time"
 
-	pb "github.com/kedacore/keda/v2/pkg/scalers/externalscaler"
+	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+
+	pb "github.com/kedacore/keda/v2/pkg/scalers/externalscaler"
+	"github.com/kedacore/keda/v2/pkg/scalers/internal/testutil"
 )
 
 type parseExternalScalerMetadataTestData struct {
@@ -169,3 +172,53 @@ func (e *testExternalScaler) GetMetricSpec(context.Context, *pb.ScaledObjectRef)
 func (e *testExternalScaler) GetMetrics(context.Context, *pb.GetMetricsRequest) (*pb.GetMetricsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetMetrics not implemented")
 }
+
+// soakExternalScaler is an external scaler with a constant metric
+type soakExternalScaler struct {
+	pb.UnimplementedExternalScalerServer
+}
+
+func (e *soakExternalScaler) IsActive(context.Context, *pb.ScaledObjectRef) (*pb.IsActiveResponse, error) {
+	return &pb.IsActiveResponse{Result: true}, nil
+}
+
+func (e *soakExternalScaler) GetMetrics(_ context.Context, request *pb.GetMetricsRequest) (*pb.GetMetricsResponse, error) {
+	return &pb.GetMetricsResponse{MetricValues: []*pb.MetricValue{{MetricName: request.MetricName, MetricValue: 5}}}, nil
+}
+
+func newExternalSoakLifecycle(t *testing.T, faults testutil.Faults) (*testutil.FaultyGRPCServer, testutil.Lifecycle) {
+	server := testutil.NewFaultyGRPCServer(t, func(s *grpc.Server) {
+		pb.RegisterExternalScalerServer(s, &soakExternalScaler{})
+	}, faults)
+
+	return server, testutil.Lifecycle{
+		Build: func() (testutil.LifecycleScaler, error) {
+			return NewExternalScaler(&ScalerConfig{Name: "app", Namespace: "namespace", TriggerMetadata: map[string]string{"scalerAddress": server.Addr}, ResolvedEnv: map[string]string{}})
+		},
+		Poll: func(ctx context.Context, scaler testutil.LifecycleScaler) error {
+			if _, err := scaler.IsActive(ctx); err != nil {
+				return err
+			}
+			_, err := scaler.(Scaler).GetMetrics(ctx, "s0-metric", nil)
+			return err
+		},
+	}
+}
+
+func TestExternalScalerSoak(t *testing.T) {
+	server, lifecycle := newExternalSoakLifecycle(t, testutil.Faults{ResetEvery: 13, MalformedEvery: 11, ErrorEvery: 7})
+
+	stats := testutil.StressLifecycle(t, lifecycle)
+	assert.NotZero(t, stats.PollErrors)
+	assert.Less(t, stats.PollErrors, stats.Polls)
+	assert.NotZero(t, server.Injected(testutil.Reset))
+}
+
+func TestExternalScalerSlowBackend(t *testing.T) {
+	_, lifecycle := newExternalSoakLifecycle(t, testutil.Faults{Latency: time.Hour})
+	lifecycle.Iterations = 3
+	lifecycle.PollTimeout = 100 * time.Millisecond
+
+	stats := testutil.StressLifecycle(t, lifecycle)
+	assert.Equal(t, 3, stats.PollErrors)
+}
@@ -0,0 +1,299 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+	bigquery "google.golang.org/api/bigquery/v2"
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	defaultBigQueryQueryTimeout = 30 * time.Second
+	// bigQueryCancelTimeout bounds the cancellation of a query that didn't complete within queryTimeout
+	bigQueryCancelTimeout = 10 * time.Second
+)
+
+// bigQueryNumericTypes are the types of the column of the query result that can be the value of the metric
+var bigQueryNumericTypes = map[string]bool{
+	"INTEGER":    true,
+	"INT64":      true,
+	"FLOAT":      true,
+	"FLOAT64":    true,
+	"NUMERIC":    true,
+	"BIGNUMERIC": true,
+}
+
+type bigQueryScaler struct {
+	service    *bigquery.Service
+	metricType v2beta2.MetricTargetType
+	metadata   *bigQueryMetadata
+}
+
+type bigQueryMetadata struct {
+	projectID             string
+	query                 string
+	targetValue           int64
+	activationTargetValue float64
+	queryTimeout          time.Duration
+	// maximumBytesBilled fails the queries that would bill more bytes, 0 is the default limit of the project
+	maximumBytesBilled int64
+
+	gcpAuthorization *gcpAuthorizationMetadata
+	scalerIndex      int
+}
+
+func (m bigQueryMetadata) String() string {
+	gcpAuthorization := ""
+	if m.gcpAuthorization != nil {
+		gcpAuthorization = m.gcpAuthorization.String()
+	}
+	return fmt.Sprintf("{projectID:%s query:%q targetValue:%d activationTargetValue:%g queryTimeout:%s maximumBytesBilled:%d gcpAuthorization:%s scalerIndex:%d}",
+		m.projectID, m.query, m.targetValue, m.activationTargetValue, m.queryTimeout, m.maximumBytesBilled, gcpAuthorization, m.scalerIndex)
+}
+
+var gcpBigQueryLog = logf.Log.WithName("gcp_bigquery_scaler")
+
+// NewBigQueryScaler creates a new bigQueryScaler
+func NewBigQueryScaler(ctx context.Context, config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseBigQueryMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing BigQuery metadata: %s", err)
+	}
+
+	// the tokens are shared with the other GCP scalers using the same credentials
+	tokenSource, err := gcpTokenSources.get(ctx, meta.gcpAuthorization)
+	if err != nil {
+		return nil, err
+	}
+	service, err := bigquery.NewService(ctx, meta.gcpAuthorization.clientOptions(tokenSource, gcpBigQueryAPI)...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the BigQuery client: %s", err)
+	}
+
+	gcpBigQueryLog.Info(fmt.Sprintf("Metadata %s", meta))
+
+	return &bigQueryScaler{
+		service:    service,
+		metricType: metricType,
+		metadata:   meta,
+	}, nil
+}
+
+func parseBigQueryMetadata(config *ScalerConfig) (*bigQueryMetadata, error) {
+	meta := bigQueryMetadata{}
+	meta.queryTimeout = defaultBigQueryQueryTimeout
+
+	if val, ok := config.TriggerMetadata["projectID"]; ok && val != "" {
+		meta.projectID = val
+	} else {
+		return nil, fmt.Errorf("no project ID given")
+	}
+
+	if val, ok := config.TriggerMetadata["query"]; ok && val != "" {
+		meta.query = val
+	} else {
+		return nil, fmt.Errorf("no query given")
+	}
+
+	if val, ok := config.TriggerMetadata["targetValue"]; ok && val != "" {
+		targetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpBigQueryLog.Error(err, "Error parsing targetValue")
+			return nil, fmt.Errorf("error parsing targetValue: %s", err.Error())
+		}
+		if targetValue <= 0 {
+			return nil, fmt.Errorf("targetValue must be greater than 0")
+		}
+		meta.targetValue = targetValue
+	} else {
+		return nil, fmt.Errorf("no targetValue given")
+	}
+
+	activationTargetValue, err := getFloatMetadataValue(config.TriggerMetadata, "activationTargetValue", false, 0)
+	if err != nil {
+		return nil, err
+	}
+	meta.activationTargetValue = activationTargetValue
+
+	if val, ok := config.TriggerMetadata["queryTimeout"]; ok && val != "" {
+		queryTimeout, err := str2duration.ParseDuration(val)
+		if err != nil {
+			gcpBigQueryLog.Error(err, "Error parsing queryTimeout")
+			return nil, fmt.Errorf("error parsing queryTimeout: %s", err.Error())
+		}
+		if queryTimeout <= 0 {
+			return nil, fmt.Errorf("queryTimeout must be positive")
+		}
+		meta.queryTimeout = queryTimeout
+	}
+
+	if val, ok := config.TriggerMetadata["maximumBytesBilled"]; ok && val != "" {
+		maximumBytesBilled, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpBigQueryLog.Error(err, "Error parsing maximumBytesBilled")
+			return nil, fmt.Errorf("error parsing maximumBytesBilled: %s", err.Error())
+		}
+		if maximumBytesBilled <= 0 {
+			return nil, fmt.Errorf("maximumBytesBilled must be positive")
+		}
+		meta.maximumBytesBilled = maximumBytesBilled
+	}
+
+	auth, err := getGcpAuthorization(config, config.ResolvedEnv)
+	if err != nil {
+		return nil, err
+	}
+	meta.gcpAuthorization = auth
+	meta.scalerIndex = config.ScalerIndex
+	return &meta, nil
+}
+
+// IsActive checks if the result of the query is above the activation target value
+func (s *bigQueryScaler) IsActive(ctx context.Context) (bool, error) {
+	value, err := s.getQueryResult(ctx)
+	if err != nil {
+		gcpBigQueryLog.Error(err, "error getting Active Status")
+		return false, err
+	}
+	return value > s.metadata.activationTargetValue, nil
+}
+
+func (s *bigQueryScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *bigQueryScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("gcp-bigquery-%s", s.metadata.projectID))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+
+	// Create the metric spec for the HPA
+	metricSpec := v2beta2.MetricSpec{
+		External: externalMetric,
+		Type:     externalMetricType,
+	}
+
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics runs the query and returns its result
+func (s *bigQueryScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	value, err := s.getQueryResult(ctx)
+	if err != nil {
+		gcpBigQueryLog.Error(err, "error getting query result")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getQueryResult runs the query, waiting for at most queryTimeout, and returns the value of its single row and column
+func (s *bigQueryScaler) getQueryResult(ctx context.Context) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.metadata.queryTimeout)
+	defer cancel()
+
+	useLegacySQL := false
+	resp, err := s.service.Jobs.Query(s.metadata.projectID, &bigquery.QueryRequest{
+		Query:              s.metadata.query,
+		UseLegacySql:       &useLegacySQL,
+		MaximumBytesBilled: s.metadata.maximumBytesBilled,
+		TimeoutMs:          s.metadata.queryTimeout.Milliseconds(),
+		// a second row is only fetched to tell that there's more than one
+		MaxResults: 2,
+	}).Context(ctx).Do()
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("the query didn't complete within queryTimeout %s", s.metadata.queryTimeout)
+		}
+		return 0, fmt.Errorf("error running the query: %s", err)
+	}
+
+	schema, rows, totalRows := resp.Schema, resp.Rows, resp.TotalRows
+	for complete := resp.JobComplete; !complete; {
+		results, err := s.service.Jobs.GetQueryResults(s.metadata.projectID, resp.JobReference.JobId).Location(resp.JobReference.Location).
+			MaxResults(2).TimeoutMs(remainingBigQueryTimeout(ctx).Milliseconds()).Context(ctx).Do()
+		if err != nil {
+			if ctx.Err() != nil {
+				s.cancelQuery(resp.JobReference)
+				return 0, fmt.Errorf("the query didn't complete within queryTimeout %s", s.metadata.queryTimeout)
+			}
+			return 0, fmt.Errorf("error getting the query results: %s", err)
+		}
+		schema, rows, totalRows, complete = results.Schema, results.Rows, results.TotalRows, results.JobComplete
+	}
+
+	return getBigQueryValue(schema, rows, totalRows)
+}
+
+// cancelQuery cancels the query that didn't complete in time, so that it doesn't keep running, and billing, while
+// the next polls start new ones
+func (s *bigQueryScaler) cancelQuery(job *bigquery.JobReference) {
+	ctx, cancel := context.WithTimeout(context.Background(), bigQueryCancelTimeout)
+	defer cancel()
+	if _, err := s.service.Jobs.Cancel(s.metadata.projectID, job.JobId).Location(job.Location).Context(ctx).Do(); err != nil {
+		gcpBigQueryLog.Error(err, "error cancelling the query", "jobID", job.JobId)
+	}
+}
+
+func remainingBigQueryTimeout(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return defaultBigQueryQueryTimeout
+	}
+	return time.Until(deadline)
+}
+
+// getBigQueryValue returns the value of the result of the query, which must have exactly one row of one numeric column
+func getBigQueryValue(schema *bigquery.TableSchema, rows []*bigquery.TableRow, totalRows uint64) (float64, error) {
+	if schema == nil || len(schema.Fields) != 1 {
+		columns := 0
+		if schema != nil {
+			columns = len(schema.Fields)
+		}
+		return 0, fmt.Errorf("the query must return exactly one column, got %d", columns)
+	}
+	if field := schema.Fields[0]; !bigQueryNumericTypes[field.Type] || field.Mode == "REPEATED" {
+		return 0, fmt.Errorf("the column %s of the query result must be numeric, got %s", field.Name, field.Type)
+	}
+	if totalRows != 1 || len(rows) != 1 || len(rows[0].F) != 1 {
+		return 0, fmt.Errorf("the query must return exactly one row, got %d", totalRows)
+	}
+
+	// the values are encoded as strings in the JSON of the results
+	value, ok := rows[0].F[0].V.(string)
+	if !ok {
+		return 0, fmt.Errorf("the query returned NULL, e.g. use COALESCE to return 0 instead")
+	}
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing the query result %q: %s", value, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,255 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	bigquery "google.golang.org/api/bigquery/v2"
+	option "google.golang.org/api/option"
+)
+
+var testBigQueryResolvedEnv = map[string]string{
+	"SAMPLE_CREDS": "{}",
+}
+
+const testBigQueryQuery = "SELECT COUNT(*) FROM staging.pending_rows"
+
+type parseBigQueryMetadataTestData struct {
+	authParams map[string]string
+	metadata   map[string]string
+	isError    bool
+}
+
+type gcpBigQueryMetricIdentifier struct {
+	metadataTestData *parseBigQueryMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testBigQueryMetadata = []parseBigQueryMetadataTestData{
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{nil, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// with activationTargetValue, queryTimeout and maximumBytesBilled
+	{nil, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "10", "activationTargetValue": "0.5", "queryTimeout": "1m", "maximumBytesBilled": "10000000", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// missing projectID
+	{nil, map[string]string{"query": testBigQueryQuery, "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing query
+	{nil, map[string]string{"projectID": "myproject", "query": "", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing targetValue
+	{nil, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed targetValue
+	{nil, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero targetValue
+	{nil, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "0", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed activationTargetValue
+	{nil, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "10", "activationTargetValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed queryTimeout
+	{nil, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "10", "queryTimeout": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero queryTimeout
+	{nil, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "10", "queryTimeout": "0s", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed maximumBytesBilled
+	{nil, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "10", "maximumBytesBilled": "10GB", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// negative maximumBytesBilled
+	{nil, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "10", "maximumBytesBilled": "-1", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing credentials
+	{nil, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "10", "credentialsFromEnv": ""}, true},
+	// Credentials from AuthParams
+	{map[string]string{"GoogleApplicationCredentials": "Creds"}, map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "10"}, false},
+}
+
+var gcpBigQueryMetricIdentifiers = []gcpBigQueryMetricIdentifier{
+	{&testBigQueryMetadata[1], 0, "s0-gcp-bigquery-myproject"},
+	{&testBigQueryMetadata[1], 1, "s1-gcp-bigquery-myproject"},
+}
+
+func TestBigQueryParseMetadata(t *testing.T) {
+	for _, testData := range testBigQueryMetadata {
+		_, err := parseBigQueryMetadata(&ScalerConfig{AuthParams: testData.authParams, TriggerMetadata: testData.metadata, ResolvedEnv: testBigQueryResolvedEnv})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("Expected error but got success for %v", testData.metadata)
+		}
+	}
+}
+
+func TestBigQueryParseMetadataDefaults(t *testing.T) {
+	meta, err := parseBigQueryMetadata(&ScalerConfig{TriggerMetadata: testBigQueryMetadata[1].metadata, ResolvedEnv: testBigQueryResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Equal(t, defaultBigQueryQueryTimeout, meta.queryTimeout)
+	assert.Equal(t, int64(0), meta.maximumBytesBilled)
+	assert.Equal(t, float64(0), meta.activationTargetValue)
+
+	meta, err = parseBigQueryMetadata(&ScalerConfig{TriggerMetadata: testBigQueryMetadata[2].metadata, ResolvedEnv: testBigQueryResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Equal(t, time.Minute, meta.queryTimeout)
+	assert.Equal(t, int64(10000000), meta.maximumBytesBilled)
+	assert.Equal(t, 0.5, meta.activationTargetValue)
+}
+
+func TestGcpBigQueryGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range gcpBigQueryMetricIdentifiers {
+		meta, err := parseBigQueryMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, ResolvedEnv: testBigQueryResolvedEnv, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockGcpBigQueryScaler := bigQueryScaler{nil, "", meta}
+
+		metricSpec := mockGcpBigQueryScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+// fakeBigQueryServer answers the queries with the result, after the getQueryResults calls of pending, and records
+// the query requests and the cancelled jobs
+type fakeBigQueryServer struct {
+	result    string
+	pending   int32
+	blocked   chan struct{}
+	requests  chan bigquery.QueryRequest
+	cancelled int32
+}
+
+func newFakeBigQueryScaler(t *testing.T, fake *fakeBigQueryServer, metadata map[string]string) *bigQueryScaler {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pending := `{"jobComplete": false, "jobReference": {"projectId": "myproject", "jobId": "job-1", "location": "EU"}}`
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/bigquery/v2/projects/myproject/queries":
+			var req bigquery.QueryRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error("Could not decode the query request:", err)
+			}
+			fake.requests <- req
+			if atomic.LoadInt32(&fake.pending) > 0 {
+				_, _ = w.Write([]byte(pending))
+				return
+			}
+			_, _ = w.Write([]byte(fake.result))
+		case r.Method == http.MethodGet && r.URL.Path == "/bigquery/v2/projects/myproject/queries/job-1":
+			assert.Equal(t, "EU", r.URL.Query().Get("location"))
+			if fake.blocked != nil {
+				select {
+				case <-fake.blocked:
+				case <-r.Context().Done():
+				}
+				return
+			}
+			if atomic.AddInt32(&fake.pending, -1) > 0 {
+				_, _ = w.Write([]byte(pending))
+				return
+			}
+			_, _ = w.Write([]byte(fake.result))
+		case r.Method == http.MethodPost && r.URL.Path == "/bigquery/v2/projects/myproject/jobs/job-1/cancel":
+			atomic.AddInt32(&fake.cancelled, 1)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	service, err := bigquery.NewService(context.Background(), option.WithEndpoint(server.URL+"/bigquery/v2/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal("Could not create the client:", err)
+	}
+	config := map[string]string{"projectID": "myproject", "query": testBigQueryQuery, "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}
+	for key, value := range metadata {
+		config[key] = value
+	}
+	meta, err := parseBigQueryMetadata(&ScalerConfig{TriggerMetadata: config, ResolvedEnv: testBigQueryResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	return &bigQueryScaler{service: service, metadata: meta}
+}
+
+func newBigQueryResult(columnType string, values ...string) string {
+	rows := make([]string, 0, len(values))
+	for _, value := range values {
+		rows = append(rows, `{"f": [{"v": `+value+`}]}`)
+	}
+	return `{"jobComplete": true, "jobReference": {"projectId": "myproject", "jobId": "job-1", "location": "EU"}, ` +
+		`"schema": {"fields": [{"name": "f0_", "type": "` + columnType + `", "mode": "NULLABLE"}]}, ` +
+		`"totalRows": "` + strconv.Itoa(len(values)) + `", "rows": [` + strings.Join(rows, ", ") + `]}`
+}
+
+func TestGcpBigQueryGetMetrics(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		result   string
+		pending  int32
+		value    int64
+		isActive bool
+		isError  bool
+	}{
+		{"integer", newBigQueryResult("INTEGER", `"42"`), 0, 42000, true, false},
+		{"float below activation", newBigQueryResult("FLOAT", `"0.25"`), 0, 250, false, false},
+		{"numeric", newBigQueryResult("NUMERIC", `"12.5"`), 0, 12500, true, false},
+		{"pending query", newBigQueryResult("INTEGER", `"7"`), 2, 7000, true, false},
+		{"no row", newBigQueryResult("INTEGER"), 0, 0, false, true},
+		{"two rows", newBigQueryResult("INTEGER", `"1"`, `"2"`), 0, 0, false, true},
+		{"string column", newBigQueryResult("STRING", `"1"`), 0, 0, false, true},
+		{"null", newBigQueryResult("INTEGER", `null`), 0, 0, false, true},
+		{"two columns", `{"jobComplete": true, "schema": {"fields": [{"name": "a", "type": "INTEGER"}, {"name": "b", "type": "INTEGER"}]}, ` +
+			`"totalRows": "1", "rows": [{"f": [{"v": "1"}, {"v": "2"}]}]}`, 0, 0, false, true},
+	} {
+		fake := &fakeBigQueryServer{result: testData.result, requests: make(chan bigquery.QueryRequest, 2)}
+		s := newFakeBigQueryScaler(t, fake, map[string]string{"activationTargetValue": "0.5", "maximumBytesBilled": "10000000"})
+
+		atomic.StoreInt32(&fake.pending, testData.pending)
+		metrics, err := s.GetMetrics(context.Background(), "s0-gcp-bigquery-myproject", nil)
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if err == nil && metrics[0].Value.MilliValue() != testData.value {
+			t.Errorf("%s: expected the value %dm, got %dm", testData.name, testData.value, metrics[0].Value.MilliValue())
+		}
+
+		atomic.StoreInt32(&fake.pending, testData.pending)
+		isActive, err := s.IsActive(context.Background())
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if isActive != testData.isActive {
+			t.Errorf("%s: expected active %t, got %t", testData.name, testData.isActive, isActive)
+		}
+
+		req := <-fake.requests
+		if req.Query != testBigQueryQuery || req.UseLegacySql == nil || *req.UseLegacySql || req.MaximumBytesBilled != 10000000 {
+			t.Errorf("%s: expected a standard SQL query with maximumBytesBilled, got %+v", testData.name, req)
+		}
+		if req.TimeoutMs != defaultBigQueryQueryTimeout.Milliseconds() {
+			t.Errorf("%s: expected the queryTimeout to be requested, got %dms", testData.name, req.TimeoutMs)
+		}
+	}
+}
+
+func TestGcpBigQueryCancelsTimedOutQueries(t *testing.T) {
+	fake := &fakeBigQueryServer{pending: 1, blocked: make(chan struct{}), requests: make(chan bigquery.QueryRequest, 1)}
+	defer close(fake.blocked)
+	s := newFakeBigQueryScaler(t, fake, map[string]string{"queryTimeout": "100ms"})
+
+	_, err := s.GetMetrics(context.Background(), "s0-gcp-bigquery-myproject", nil)
+	if err == nil || !strings.Contains(err.Error(), "queryTimeout") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fake.cancelled))
+}
@@ -0,0 +1,304 @@
+package scalers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+	logging "google.golang.org/api/logging/v2"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	defaultCloudLoggingTimeWindow = 5 * time.Minute
+	// A limit on the log entries counted in the time window, they are listed 1000 per request
+	defaultCloudLoggingMaxEntriesToCount = 1000
+	cloudLoggingPageSize                 = 1000
+	cloudLoggingEntryCountMetricType     = "logging.googleapis.com/log_entry_count"
+)
+
+type cloudLoggingScaler struct {
+	entries    *logging.Service
+	client     *StackDriverClient
+	metricType v2beta2.MetricTargetType
+	metadata   *cloudLoggingMetadata
+}
+
+type cloudLoggingMetadata struct {
+	projectID             string
+	filter                string
+	timeWindow            time.Duration
+	useMonitoring         bool
+	maxEntriesToCount     int64
+	targetValue           int64
+	activationTargetValue int64
+
+	gcpAuthorization *gcpAuthorizationMetadata
+	scalerIndex      int
+}
+
+func (m cloudLoggingMetadata) String() string {
+	gcpAuthorization := ""
+	if m.gcpAuthorization != nil {
+		gcpAuthorization = m.gcpAuthorization.String()
+	}
+	return fmt.Sprintf("{projectID:%s filter:%s timeWindow:%s useMonitoring:%t maxEntriesToCount:%d targetValue:%d activationTargetValue:%d gcpAuthorization:%s scalerIndex:%d}",
+		m.projectID, m.filter, m.timeWindow, m.useMonitoring, m.maxEntriesToCount, m.targetValue, m.activationTargetValue, gcpAuthorization, m.scalerIndex)
+}
+
+var gcpCloudLoggingLog = logf.Log.WithName("gcp_cloudlogging_scaler")
+
+// NewCloudLoggingScaler creates a new cloudLoggingScaler
+func NewCloudLoggingScaler(ctx context.Context, config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseCloudLoggingMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Cloud Logging metadata: %s", err)
+	}
+
+	s := &cloudLoggingScaler{
+		metricType: metricType,
+		metadata:   meta,
+	}
+	if meta.useMonitoring {
+		client, err := stackDriverClients.acquire(ctx, meta.gcpAuthorization)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the Cloud Monitoring client: %s", err)
+		}
+		s.client = client
+	} else {
+		// the tokens are shared with the other GCP scalers using the same credentials
+		tokenSource, err := gcpTokenSources.get(ctx, meta.gcpAuthorization)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := logging.NewService(ctx, meta.gcpAuthorization.clientOptions(tokenSource, gcpLoggingAPI)...)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the Cloud Logging client: %s", err)
+		}
+		s.entries = entries
+	}
+
+	gcpCloudLoggingLog.Info(fmt.Sprintf("Metadata %s", meta))
+	return s, nil
+}
+
+func parseCloudLoggingMetadata(config *ScalerConfig) (*cloudLoggingMetadata, error) {
+	meta := cloudLoggingMetadata{}
+	meta.timeWindow = defaultCloudLoggingTimeWindow
+	meta.maxEntriesToCount = defaultCloudLoggingMaxEntriesToCount
+
+	if val, ok := config.TriggerMetadata["projectID"]; ok && val != "" {
+		meta.projectID = val
+	} else {
+		return nil, fmt.Errorf("no project ID given")
+	}
+
+	// the filter is checked by the API, the ones it rejects fail the first poll with its message
+	if val, ok := config.TriggerMetadata["filter"]; ok && val != "" {
+		meta.filter = val
+	} else {
+		return nil, fmt.Errorf("no filter given")
+	}
+
+	if val, ok := config.TriggerMetadata["useMonitoring"]; ok && val != "" {
+		useMonitoring, err := strconv.ParseBool(val)
+		if err != nil {
+			gcpCloudLoggingLog.Error(err, "Error parsing useMonitoring")
+			return nil, fmt.Errorf("error parsing useMonitoring: %s", err.Error())
+		}
+		meta.useMonitoring = useMonitoring
+	}
+
+	if val, ok := config.TriggerMetadata["timeWindow"]; ok && val != "" {
+		timeWindow, err := str2duration.ParseDuration(val)
+		if err != nil {
+			gcpCloudLoggingLog.Error(err, "Error parsing timeWindow")
+			return nil, fmt.Errorf("error parsing timeWindow: %s", err.Error())
+		}
+		if timeWindow <= 0 {
+			return nil, fmt.Errorf("timeWindow must be greater than 0")
+		}
+		meta.timeWindow = timeWindow
+	}
+	// the log entry counts are aligned on periods of whole minutes
+	if meta.useMonitoring && (meta.timeWindow < time.Minute || meta.timeWindow%time.Minute != 0) {
+		return nil, fmt.Errorf("timeWindow must be a whole number of minutes with useMonitoring")
+	}
+
+	if val, ok := config.TriggerMetadata["maxEntriesToCount"]; ok && val != "" {
+		if meta.useMonitoring {
+			return nil, fmt.Errorf("maxEntriesToCount can't be used with useMonitoring")
+		}
+		maxEntriesToCount, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpCloudLoggingLog.Error(err, "Error parsing maxEntriesToCount")
+			return nil, fmt.Errorf("error parsing maxEntriesToCount: %s", err.Error())
+		}
+		if maxEntriesToCount <= 0 {
+			return nil, fmt.Errorf("maxEntriesToCount must be greater than 0")
+		}
+		meta.maxEntriesToCount = maxEntriesToCount
+	}
+
+	if val, ok := config.TriggerMetadata["targetValue"]; ok && val != "" {
+		targetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpCloudLoggingLog.Error(err, "Error parsing targetValue")
+			return nil, fmt.Errorf("error parsing targetValue: %s", err.Error())
+		}
+		if targetValue <= 0 {
+			return nil, fmt.Errorf("targetValue must be greater than 0")
+		}
+		meta.targetValue = targetValue
+	} else {
+		return nil, fmt.Errorf("no targetValue given")
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetValue"]; ok && val != "" {
+		activationTargetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpCloudLoggingLog.Error(err, "Error parsing activationTargetValue")
+			return nil, fmt.Errorf("error parsing activationTargetValue: %s", err.Error())
+		}
+		meta.activationTargetValue = activationTargetValue
+	}
+
+	auth, err := getGcpAuthorization(config, config.ResolvedEnv)
+	if err != nil {
+		return nil, err
+	}
+	meta.gcpAuthorization = auth
+	meta.scalerIndex = config.ScalerIndex
+	return &meta, nil
+}
+
+// IsActive checks if the number of matching log entries in the time window is above the activation target value
+func (s *cloudLoggingScaler) IsActive(ctx context.Context) (bool, error) {
+	count, err := s.getEntryCount(ctx)
+	if err != nil {
+		gcpCloudLoggingLog.Error(err, "error getting Active Status")
+		return false, err
+	}
+	return count > s.metadata.activationTargetValue, nil
+}
+
+func (s *cloudLoggingScaler) Close(context.Context) error {
+	if s.client != nil {
+		err := stackDriverClients.release(s.client)
+		s.client = nil
+		if err != nil {
+			gcpCloudLoggingLog.Error(err, "error closing StackDriver client")
+		}
+	}
+
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *cloudLoggingScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("gcp-cloudlogging-%s", s.metadata.projectID))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+
+	// Create the metric spec for the HPA
+	metricSpec := v2beta2.MetricSpec{
+		External: externalMetric,
+		Type:     externalMetricType,
+	}
+
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the number of the log entries matching the filter in the time window
+func (s *cloudLoggingScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	count, err := s.getEntryCount(ctx)
+	if err != nil {
+		gcpCloudLoggingLog.Error(err, "error getting log entry count")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(count, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+func (s *cloudLoggingScaler) getEntryCount(ctx context.Context) (int64, error) {
+	if s.metadata.useMonitoring {
+		return s.getMonitoredEntryCount(ctx)
+	}
+	return s.getListedEntryCount(ctx)
+}
+
+// getListedEntryCount counts the log entries matching the filter in the time window, up to maxEntriesToCount. Only
+// their insert IDs are listed
+func (s *cloudLoggingScaler) getListedEntryCount(ctx context.Context) (int64, error) {
+	since := time.Now().UTC().Add(-s.metadata.timeWindow)
+	req := &logging.ListLogEntriesRequest{
+		ResourceNames: []string{"projects/" + s.metadata.projectID},
+		Filter:        fmt.Sprintf("(%s) AND timestamp>=%q", s.metadata.filter, since.Format(time.RFC3339Nano)),
+		PageSize:      cloudLoggingPageSize,
+	}
+
+	var count int64
+	for {
+		resp, err := s.entries.Entries.List(req).Fields("entries/insertId", "nextPageToken").Context(ctx).Do()
+		if err != nil {
+			return 0, fmt.Errorf("error listing the log entries: %s", err)
+		}
+		count += int64(len(resp.Entries))
+		if count >= s.metadata.maxEntriesToCount {
+			gcpCloudLoggingLog.V(1).Info("Reached maxEntriesToCount, the number of log entries may be too low",
+				"projectID", s.metadata.projectID, "maxEntriesToCount", s.metadata.maxEntriesToCount)
+			return s.metadata.maxEntriesToCount, nil
+		}
+		if resp.NextPageToken == "" {
+			return count, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// getMonitoredEntryCount reads the number of the log entries in the time window from the log_entry_count metric,
+// the filter selects its time series, e.g. on the severity and log labels
+func (s *cloudLoggingScaler) getMonitoredEntryCount(ctx context.Context) (int64, error) {
+	filter := `metric.type="` + cloudLoggingEntryCountMetricType + `" AND (` + s.metadata.filter + `)`
+	aggregation := &monitoringpb.Aggregation{
+		AlignmentPeriod:    durationpb.New(s.metadata.timeWindow),
+		PerSeriesAligner:   monitoringpb.Aggregation_ALIGN_SUM,
+		CrossSeriesReducer: monitoringpb.Aggregation_REDUCE_SUM,
+	}
+	value, err := s.client.GetMetricsWithAggregation(ctx, filter, s.metadata.projectID, aggregation, s.metadata.timeWindow)
+	// no time series means that no entry matched
+	if errors.Is(err, errStackDriverMetricNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading %s: %s", cloudLoggingEntryCountMetricType, err)
+	}
+	return int64(math.Round(value)), nil
+}
@@ -0,0 +1,250 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	logging "google.golang.org/api/logging/v2"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/grpc"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/internal/testutil"
+)
+
+var testCloudLoggingResolvedEnv = map[string]string{
+	"SAMPLE_CREDS": "{}",
+}
+
+type parseCloudLoggingMetadataTestData struct {
+	authParams map[string]string
+	metadata   map[string]string
+	isError    bool
+}
+
+type gcpCloudLoggingMetricIdentifier struct {
+	metadataTestData *parseCloudLoggingMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+const testCloudLoggingFilter = `severity>=ERROR AND resource.type="k8s_container"`
+
+var testCloudLoggingMetadata = []parseCloudLoggingMetadataTestData{
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// with timeWindow, maxEntriesToCount and activationTargetValue
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "timeWindow": "90s", "maxEntriesToCount": "3", "targetValue": "10",
+		"activationTargetValue": "1", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// with useMonitoring
+	{nil, map[string]string{"projectID": "myproject", "filter": `metric.labels.severity="ERROR"`, "useMonitoring": "true", "timeWindow": "10m", "targetValue": "10",
+		"activationTargetValue": "1", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// missing projectID
+	{nil, map[string]string{"filter": testCloudLoggingFilter, "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing filter
+	{nil, map[string]string{"projectID": "myproject", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed timeWindow
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "timeWindow": "AA", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// negative timeWindow
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "timeWindow": "-5m", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// useMonitoring with a timeWindow of seconds
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "useMonitoring": "true", "timeWindow": "90s", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed useMonitoring
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "useMonitoring": "AA", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// maxEntriesToCount with useMonitoring
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "useMonitoring": "true", "maxEntriesToCount": "100", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero maxEntriesToCount
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "maxEntriesToCount": "0", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing targetValue
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero targetValue
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "targetValue": "0", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed activationTargetValue
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "targetValue": "10", "activationTargetValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing credentials
+	{nil, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "targetValue": "10", "credentialsFromEnv": ""}, true},
+	// Credentials from AuthParams
+	{map[string]string{"GoogleApplicationCredentials": "Creds"}, map[string]string{"projectID": "myproject", "filter": testCloudLoggingFilter, "targetValue": "10"}, false},
+}
+
+var gcpCloudLoggingMetricIdentifiers = []gcpCloudLoggingMetricIdentifier{
+	{&testCloudLoggingMetadata[1], 0, "s0-gcp-cloudlogging-myproject"},
+	{&testCloudLoggingMetadata[1], 1, "s1-gcp-cloudlogging-myproject"},
+}
+
+func TestCloudLoggingParseMetadata(t *testing.T) {
+	for _, testData := range testCloudLoggingMetadata {
+		_, err := parseCloudLoggingMetadata(&ScalerConfig{AuthParams: testData.authParams, TriggerMetadata: testData.metadata, ResolvedEnv: testCloudLoggingResolvedEnv})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("Expected error but got success for %v", testData.metadata)
+		}
+	}
+}
+
+func TestCloudLoggingParseMetadataDefaults(t *testing.T) {
+	meta, err := parseCloudLoggingMetadata(&ScalerConfig{TriggerMetadata: testCloudLoggingMetadata[1].metadata, ResolvedEnv: testCloudLoggingResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Equal(t, defaultCloudLoggingTimeWindow, meta.timeWindow)
+	assert.Equal(t, int64(defaultCloudLoggingMaxEntriesToCount), meta.maxEntriesToCount)
+	assert.False(t, meta.useMonitoring)
+}
+
+func TestGcpCloudLoggingGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range gcpCloudLoggingMetricIdentifiers {
+		meta, err := parseCloudLoggingMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, ResolvedEnv: testCloudLoggingResolvedEnv, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockGcpCloudLoggingScaler := cloudLoggingScaler{metadata: meta}
+
+		metricSpec := mockGcpCloudLoggingScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+// newFakeLoggingServer returns a fake Cloud Logging API listing the entries per page, or rejecting the filters with
+// the message, and recording the list requests
+func newFakeLoggingServer(t *testing.T, pages []int, rejected string, requests chan<- logging.ListLogEntriesRequest) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v2/entries:list" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req logging.ListLogEntriesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error("Could not decode the list request:", err)
+		}
+		requests <- req
+		w.Header().Set("Content-Type", "application/json")
+		if rejected != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, `{"error": {"code": 400, "message": %q, "status": "INVALID_ARGUMENT"}}`, rejected)
+			return
+		}
+
+		page := 0
+		if req.PageToken != "" {
+			_, _ = fmt.Sscanf(req.PageToken, "page-%d", &page)
+		}
+		resp := logging.ListLogEntriesResponse{}
+		for i := 0; i < pages[page]; i++ {
+			resp.Entries = append(resp.Entries, &logging.LogEntry{InsertId: fmt.Sprintf("%d-%d", page, i)})
+		}
+		if page+1 < len(pages) {
+			resp.NextPageToken = fmt.Sprintf("page-%d", page+1)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGcpCloudLoggingGetMetrics(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		metadata map[string]string
+		pages    []int
+		rejected string
+		value    int64
+		requests int
+		isActive bool
+	}{
+		{"entries of several pages", testCloudLoggingMetadata[1].metadata, []int{2, 1}, "", 3, 4, true},
+		{"no entry", testCloudLoggingMetadata[1].metadata, []int{0}, "", 0, 2, false},
+		{"below activation", testCloudLoggingMetadata[2].metadata, []int{1}, "", 1, 2, false},
+		{"up to maxEntriesToCount", testCloudLoggingMetadata[2].metadata, []int{2, 2, 2}, "", 3, 4, true},
+		{"rejected filter", testCloudLoggingMetadata[1].metadata, nil, "Unparseable filter: syntax error at line 1", 0, 2, false},
+	} {
+		requests := make(chan logging.ListLogEntriesRequest, 10)
+		server := newFakeLoggingServer(t, testData.pages, testData.rejected, requests)
+
+		// the emulator is reached without credentials
+		metadata := map[string]string{"apiEndpoint": server.URL}
+		for key, value := range testData.metadata {
+			metadata[key] = value
+		}
+		s, err := NewCloudLoggingScaler(context.Background(), &ScalerConfig{TriggerMetadata: metadata, ResolvedEnv: map[string]string{"SAMPLE_CREDS": testStackDriverCredentials}})
+		if err != nil {
+			t.Fatal("Could not create the scaler:", err)
+		}
+
+		metrics, err := s.GetMetrics(context.Background(), "s0-gcp-cloudlogging-myproject", nil)
+		if testData.rejected != "" {
+			if assert.Error(t, err, testData.name) {
+				assert.Contains(t, err.Error(), testData.rejected, testData.name)
+			}
+		} else if assert.NoError(t, err, testData.name) {
+			assert.Equal(t, testData.value, metrics[0].Value.Value(), testData.name)
+		}
+
+		isActive, err := s.IsActive(context.Background())
+		assert.Equal(t, testData.rejected != "", err != nil, testData.name)
+		assert.Equal(t, testData.isActive, isActive, testData.name)
+
+		close(requests)
+		assert.Len(t, requests, testData.requests, testData.name)
+		for req := range requests {
+			assert.Equal(t, []string{"projects/myproject"}, req.ResourceNames, testData.name)
+			assert.True(t, strings.HasPrefix(req.Filter, "("+testData.metadata["filter"]+`) AND timestamp>="`), testData.name, req.Filter)
+		}
+		assert.NoError(t, s.Close(context.Background()))
+	}
+}
+
+func TestGcpCloudLoggingGetMetricsWithMonitoring(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		series   []*monitoringpb.TimeSeries
+		value    int64
+		isActive bool
+	}{
+		{"entries", []*monitoringpb.TimeSeries{{Points: []*monitoringpb.Point{{Value: &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: 42}}}}}}, 42, true},
+		{"no time series", nil, 0, false},
+	} {
+		fake := &fakeMonitoringServer{series: testData.series, requests: make(chan *monitoringpb.ListTimeSeriesRequest, 2)}
+		server := testutil.NewFaultyGRPCServer(t, func(s *grpc.Server) {
+			monitoringpb.RegisterMetricServiceServer(s, fake)
+		}, testutil.Faults{})
+
+		metadata := map[string]string{"apiEndpoint": "http://" + server.Addr}
+		for key, value := range testCloudLoggingMetadata[3].metadata {
+			metadata[key] = value
+		}
+		s, err := NewCloudLoggingScaler(context.Background(), &ScalerConfig{TriggerMetadata: metadata, ResolvedEnv: map[string]string{"SAMPLE_CREDS": testStackDriverCredentials}})
+		if err != nil {
+			t.Fatal("Could not create the scaler:", err)
+		}
+
+		metrics, err := s.GetMetrics(context.Background(), "s0-gcp-cloudlogging-myproject", nil)
+		if assert.NoError(t, err, testData.name) {
+			assert.Equal(t, testData.value, metrics[0].Value.Value(), testData.name)
+		}
+		isActive, err := s.IsActive(context.Background())
+		assert.NoError(t, err, testData.name)
+		assert.Equal(t, testData.isActive, isActive, testData.name)
+
+		req := <-fake.requests
+		assert.Equal(t, `metric.type="logging.googleapis.com/log_entry_count" AND (metric.labels.severity="ERROR")`, req.Filter, testData.name)
+		assert.Equal(t, "projects/myproject", req.Name, testData.name)
+		assert.Equal(t, 10*time.Minute, req.Aggregation.AlignmentPeriod.AsDuration(), testData.name)
+		assert.Equal(t, monitoringpb.Aggregation_ALIGN_SUM, req.Aggregation.PerSeriesAligner, testData.name)
+		assert.Equal(t, monitoringpb.Aggregation_REDUCE_SUM, req.Aggregation.CrossSeriesReducer, testData.name)
+		assert.NoError(t, s.Close(context.Background()))
+	}
+}
@@ -0,0 +1,223 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	defaultCloudSQLMetricName = "database/network/connections"
+	// cloudSQLMetricsLookback is the window of the latest point of the metrics of the instance, they are sampled
+	// every minute and published with a delay of up to a few minutes
+	cloudSQLMetricsLookback = 5 * time.Minute
+)
+
+var (
+	// regexpCloudSQLDatabaseID matches the IDs of the instances, project:region:instance, the project may be scoped
+	// to a domain. They are quoted in the filter of the metric
+	regexpCloudSQLDatabaseID = regexp.MustCompile(`^([a-z0-9.-]+:)?[a-z][a-z0-9-]*:[a-z0-9-]+:[a-z][a-z0-9-]*$`)
+	// regexpCloudSQLMetricName matches the metrics of the cloudsql_database resource
+	regexpCloudSQLMetricName = regexp.MustCompile(`^database/[a-z0-9_/]+$`)
+)
+
+type cloudSQLScaler struct {
+	client     *StackDriverClient
+	metricType v2beta2.MetricTargetType
+	metadata   *cloudSQLMetadata
+}
+
+type cloudSQLMetadata struct {
+	projectID             string
+	databaseID            string
+	metricName            string
+	targetValue           int64
+	activationTargetValue float64
+	// aggregation aligns, and reduces, the time series of the metric as in the stackdriver scaler, it is nil without
+	// an aligner
+	aggregation *monitoringpb.Aggregation
+
+	gcpAuthorization *gcpAuthorizationMetadata
+	scalerIndex      int
+}
+
+func (m cloudSQLMetadata) String() string {
+	gcpAuthorization := ""
+	if m.gcpAuthorization != nil {
+		gcpAuthorization = m.gcpAuthorization.String()
+	}
+	return fmt.Sprintf("{projectID:%s databaseID:%s metricName:%s targetValue:%d activationTargetValue:%g aggregation:%s gcpAuthorization:%s scalerIndex:%d}",
+		m.projectID, m.databaseID, m.metricName, m.targetValue, m.activationTargetValue, m.aggregation, gcpAuthorization, m.scalerIndex)
+}
+
+var gcpCloudSQLLog = logf.Log.WithName("gcp_cloudsql_scaler")
+
+// NewCloudSQLScaler creates a new cloudSQLScaler
+func NewCloudSQLScaler(ctx context.Context, config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseCloudSQLMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Cloud SQL metadata: %s", err)
+	}
+
+	client, err := stackDriverClients.acquire(ctx, meta.gcpAuthorization)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the Cloud Monitoring client: %s", err)
+	}
+
+	gcpCloudSQLLog.Info(fmt.Sprintf("Metadata %s", meta))
+
+	return &cloudSQLScaler{
+		client:     client,
+		metricType: metricType,
+		metadata:   meta,
+	}, nil
+}
+
+func parseCloudSQLMetadata(config *ScalerConfig) (*cloudSQLMetadata, error) {
+	meta := cloudSQLMetadata{}
+	meta.metricName = defaultCloudSQLMetricName
+
+	if val, ok := config.TriggerMetadata["databaseID"]; ok && val != "" {
+		if !regexpCloudSQLDatabaseID.MatchString(val) {
+			return nil, fmt.Errorf("databaseID %q must be the ID of an instance, project:region:instance", val)
+		}
+		meta.databaseID = val
+	} else {
+		return nil, fmt.Errorf("no database ID given")
+	}
+
+	// the metrics are in the project of the instance by default
+	meta.projectID = getCloudSQLDatabaseProject(meta.databaseID)
+	if val, ok := config.TriggerMetadata["projectID"]; ok && val != "" {
+		meta.projectID = val
+	}
+
+	if val, ok := config.TriggerMetadata["metricName"]; ok && val != "" {
+		if !regexpCloudSQLMetricName.MatchString(val) {
+			return nil, fmt.Errorf("metricName %q must be a metric of the Cloud SQL databases, e.g. %s", val, defaultCloudSQLMetricName)
+		}
+		meta.metricName = val
+	}
+
+	if val, ok := config.TriggerMetadata["targetValue"]; ok && val != "" {
+		targetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpCloudSQLLog.Error(err, "Error parsing targetValue")
+			return nil, fmt.Errorf("error parsing targetValue: %s", err.Error())
+		}
+		if targetValue <= 0 {
+			return nil, fmt.Errorf("targetValue must be greater than 0")
+		}
+		meta.targetValue = targetValue
+	} else {
+		return nil, fmt.Errorf("no targetValue given")
+	}
+
+	activationTargetValue, err := getFloatMetadataValue(config.TriggerMetadata, "activationTargetValue", false, 0)
+	if err != nil {
+		return nil, err
+	}
+	meta.activationTargetValue = activationTargetValue
+
+	aggregation, err := parseStackdriverAggregation(config.TriggerMetadata)
+	if err != nil {
+		return nil, err
+	}
+	meta.aggregation = aggregation
+
+	auth, err := getGcpAuthorization(config, config.ResolvedEnv)
+	if err != nil {
+		return nil, err
+	}
+	meta.gcpAuthorization = auth
+	meta.scalerIndex = config.ScalerIndex
+	return &meta, nil
+}
+
+// getCloudSQLDatabaseProject returns the project of the ID of an instance, without its region and name
+func getCloudSQLDatabaseProject(databaseID string) string {
+	parts := strings.Split(databaseID, ":")
+	return strings.Join(parts[:len(parts)-2], ":")
+}
+
+// IsActive checks if the metric of the instance is above the activation target value
+func (s *cloudSQLScaler) IsActive(ctx context.Context) (bool, error) {
+	value, err := s.getMetric(ctx)
+	if err != nil {
+		gcpCloudSQLLog.Error(err, "error getting Active Status")
+		return false, err
+	}
+	return value > s.metadata.activationTargetValue, nil
+}
+
+func (s *cloudSQLScaler) Close(context.Context) error {
+	if s.client != nil {
+		err := stackDriverClients.release(s.client)
+		s.client = nil
+		if err != nil {
+			gcpCloudSQLLog.Error(err, "error closing StackDriver client")
+		}
+	}
+
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *cloudSQLScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("gcp-cloudsql-%s", s.metadata.databaseID))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+
+	// Create the metric spec for the HPA
+	metricSpec := v2beta2.MetricSpec{
+		External: externalMetric,
+		Type:     externalMetricType,
+	}
+
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics connects to Stack Driver and finds the metric of the instance
+func (s *cloudSQLScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	value, err := s.getMetric(ctx)
+	if err != nil {
+		gcpCloudSQLLog.Error(err, "error getting Cloud SQL metric")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getMetric reads the latest point of the metric of the instance in Cloud Monitoring
+func (s *cloudSQLScaler) getMetric(ctx context.Context) (float64, error) {
+	filter := `metric.type="cloudsql.googleapis.com/` + s.metadata.metricName + `" AND resource.type="cloudsql_database" AND resource.labels.database_id="` + s.metadata.databaseID + `"`
+	return s.client.GetMetricsWithAggregation(ctx, filter, s.metadata.projectID, s.metadata.aggregation, cloudSQLMetricsLookback)
+}
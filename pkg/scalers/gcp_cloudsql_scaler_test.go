@@ -0,0 +1,209 @@
+package scalers
+
+import (
+	"context"
+	"testing"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"github.com/stretchr/testify/assert"
+	option "google.golang.org/api/option"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/internal/testutil"
+)
+
+var testCloudSQLResolvedEnv = map[string]string{
+	"SAMPLE_CREDS": "{}",
+}
+
+type parseCloudSQLMetadataTestData struct {
+	authParams map[string]string
+	metadata   map[string]string
+	isError    bool
+}
+
+type gcpCloudSQLMetricIdentifier struct {
+	metadataTestData *parseCloudSQLMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testCloudSQLMetadata = []parseCloudSQLMetadataTestData{
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{nil, map[string]string{"databaseID": "myproject:europe-west1:mydb", "targetValue": "50", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// with projectID, metricName, activationTargetValue and aggregation
+	{nil, map[string]string{"projectID": "monitoring-project", "databaseID": "myproject:europe-west1:mydb", "metricName": "database/postgresql/num_backends", "targetValue": "50",
+		"activationTargetValue": "0.5", "alignmentPeriodSeconds": "120", "alignmentAligner": "max", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// domain scoped project
+	{nil, map[string]string{"databaseID": "example.com:myproject:europe-west1:mydb", "targetValue": "50", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// missing databaseID
+	{nil, map[string]string{"targetValue": "50", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// databaseID without region
+	{nil, map[string]string{"databaseID": "myproject:mydb", "targetValue": "50", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// databaseID with a quote
+	{nil, map[string]string{"databaseID": `myproject:europe-west1:mydb" OR "`, "targetValue": "50", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// metricName of another resource
+	{nil, map[string]string{"databaseID": "myproject:europe-west1:mydb", "metricName": "pubsub.googleapis.com/subscription/num_undelivered_messages", "targetValue": "50", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing targetValue
+	{nil, map[string]string{"databaseID": "myproject:europe-west1:mydb", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed targetValue
+	{nil, map[string]string{"databaseID": "myproject:europe-west1:mydb", "targetValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero targetValue
+	{nil, map[string]string{"databaseID": "myproject:europe-west1:mydb", "targetValue": "0", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed activationTargetValue
+	{nil, map[string]string{"databaseID": "myproject:europe-west1:mydb", "targetValue": "50", "activationTargetValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// unknown aligner
+	{nil, map[string]string{"databaseID": "myproject:europe-west1:mydb", "targetValue": "50", "alignmentAligner": "median", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// reducer without aligner
+	{nil, map[string]string{"databaseID": "myproject:europe-west1:mydb", "targetValue": "50", "alignmentReducer": "sum", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing credentials
+	{nil, map[string]string{"databaseID": "myproject:europe-west1:mydb", "targetValue": "50", "credentialsFromEnv": ""}, true},
+	// Credentials from AuthParams
+	{map[string]string{"GoogleApplicationCredentials": "Creds"}, map[string]string{"databaseID": "myproject:europe-west1:mydb", "targetValue": "50"}, false},
+}
+
+var gcpCloudSQLMetricIdentifiers = []gcpCloudSQLMetricIdentifier{
+	{&testCloudSQLMetadata[1], 0, "s0-gcp-cloudsql-myproject-europe-west1-mydb"},
+	{&testCloudSQLMetadata[1], 1, "s1-gcp-cloudsql-myproject-europe-west1-mydb"},
+}
+
+func TestCloudSQLParseMetadata(t *testing.T) {
+	for _, testData := range testCloudSQLMetadata {
+		_, err := parseCloudSQLMetadata(&ScalerConfig{AuthParams: testData.authParams, TriggerMetadata: testData.metadata, ResolvedEnv: testCloudSQLResolvedEnv})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("Expected error but got success for %v", testData.metadata)
+		}
+	}
+}
+
+func TestCloudSQLParseMetadataDefaults(t *testing.T) {
+	for _, testData := range []struct {
+		metadata   *parseCloudSQLMetadataTestData
+		projectID  string
+		metricName string
+	}{
+		{&testCloudSQLMetadata[1], "myproject", defaultCloudSQLMetricName},
+		{&testCloudSQLMetadata[2], "monitoring-project", "database/postgresql/num_backends"},
+		{&testCloudSQLMetadata[3], "example.com:myproject", defaultCloudSQLMetricName},
+	} {
+		meta, err := parseCloudSQLMetadata(&ScalerConfig{TriggerMetadata: testData.metadata.metadata, ResolvedEnv: testCloudSQLResolvedEnv})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		assert.Equal(t, testData.projectID, meta.projectID)
+		assert.Equal(t, testData.metricName, meta.metricName)
+	}
+}
+
+func TestGcpCloudSQLGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range gcpCloudSQLMetricIdentifiers {
+		meta, err := parseCloudSQLMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, ResolvedEnv: testCloudSQLResolvedEnv, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockGcpCloudSQLScaler := cloudSQLScaler{nil, "", meta}
+
+		metricSpec := mockGcpCloudSQLScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+func TestGcpCloudSQLGetMetrics(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		metadata map[string]string
+		series   []*monitoringpb.TimeSeries
+		filter   string
+		project  string
+		aligner  monitoringpb.Aggregation_Aligner
+		value    int64
+		isActive bool
+		isError  bool
+	}{
+		// the latest point comes first
+		{"connections", testCloudSQLMetadata[1].metadata, []*monitoringpb.TimeSeries{{Points: []*monitoringpb.Point{
+			{Value: &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: 42}}}, {Value: newStackdriverDoubleValue(3)}}}},
+			`metric.type="cloudsql.googleapis.com/database/network/connections" AND resource.type="cloudsql_database" AND resource.labels.database_id="myproject:europe-west1:mydb"`,
+			"projects/myproject", monitoringpb.Aggregation_ALIGN_NONE, 42000, true, false},
+		{"aligned backends below activation", testCloudSQLMetadata[2].metadata, []*monitoringpb.TimeSeries{{Points: []*monitoringpb.Point{{Value: newStackdriverDoubleValue(0.5)}}}},
+			`metric.type="cloudsql.googleapis.com/database/postgresql/num_backends" AND resource.type="cloudsql_database" AND resource.labels.database_id="myproject:europe-west1:mydb"`,
+			"projects/monitoring-project", monitoringpb.Aggregation_ALIGN_MAX, 500, false, false},
+		{"no point", testCloudSQLMetadata[1].metadata, nil,
+			`metric.type="cloudsql.googleapis.com/database/network/connections" AND resource.type="cloudsql_database" AND resource.labels.database_id="myproject:europe-west1:mydb"`,
+			"projects/myproject", monitoringpb.Aggregation_ALIGN_NONE, 0, false, true},
+	} {
+		fake := &fakeMonitoringServer{series: testData.series, requests: make(chan *monitoringpb.ListTimeSeriesRequest, 2)}
+		server := testutil.NewFaultyGRPCServer(t, func(s *grpc.Server) {
+			monitoringpb.RegisterMetricServiceServer(s, fake)
+		}, testutil.Faults{})
+		metricsClient, err := monitoring.NewMetricClient(context.Background(), option.WithEndpoint(server.Addr), option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+		if err != nil {
+			t.Fatal("Could not create the client:", err)
+		}
+
+		meta, err := parseCloudSQLMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, ResolvedEnv: testCloudSQLResolvedEnv})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		s := cloudSQLScaler{client: &StackDriverClient{metricsClient: metricsClient}, metadata: meta}
+
+		metrics, err := s.GetMetrics(context.Background(), "s0-gcp-cloudsql-myproject-europe-west1-mydb", nil)
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if err == nil && metrics[0].Value.MilliValue() != testData.value {
+			t.Errorf("%s: expected the value %dm, got %dm", testData.name, testData.value, metrics[0].Value.MilliValue())
+		}
+
+		isActive, err := s.IsActive(context.Background())
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if isActive != testData.isActive {
+			t.Errorf("%s: expected active %t, got %t", testData.name, testData.isActive, isActive)
+		}
+
+		req := <-fake.requests
+		assert.Equal(t, testData.filter, req.Filter, testData.name)
+		assert.Equal(t, testData.project, req.Name, testData.name)
+		assert.Equal(t, testData.aligner, req.Aggregation.GetPerSeriesAligner(), testData.name)
+		if lookback := req.Interval.EndTime.Seconds - req.Interval.StartTime.Seconds; lookback != int64(cloudSQLMetricsLookback.Seconds()) {
+			t.Errorf("%s: expected the lookback as interval, got %ds", testData.name, lookback)
+		}
+		if err := s.Close(context.Background()); err != nil {
+			t.Errorf("%s: unexpected error closing the scaler: %s", testData.name, err)
+		}
+	}
+}
+
+func TestGcpCloudSQLAPIEndpoint(t *testing.T) {
+	fake := &fakeMonitoringServer{series: []*monitoringpb.TimeSeries{{Points: []*monitoringpb.Point{{Value: newStackdriverDoubleValue(7)}}}},
+		requests: make(chan *monitoringpb.ListTimeSeriesRequest, 1)}
+	server := testutil.NewFaultyGRPCServer(t, func(s *grpc.Server) {
+		monitoringpb.RegisterMetricServiceServer(s, fake)
+	}, testutil.Faults{})
+
+	// the emulator is reached without credentials
+	s, err := NewCloudSQLScaler(context.Background(), &ScalerConfig{
+		AuthParams:      map[string]string{"GoogleApplicationCredentials": testStackDriverCredentials},
+		TriggerMetadata: map[string]string{"databaseID": "myproject:europe-west1:mydb", "targetValue": "50", "apiEndpoint": "http://" + server.Addr},
+	})
+	if err != nil {
+		t.Fatal("Could not create the scaler:", err)
+	}
+	metrics, err := s.GetMetrics(context.Background(), "s0-gcp-cloudsql-myproject-europe-west1-mydb", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7000), metrics[0].Value.MilliValue())
+	assert.Equal(t, "projects/myproject", (<-fake.requests).Name)
+	assert.NoError(t, s.Close(context.Background()))
+}
@@ -0,0 +1,212 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	cloudTasksStackDriverQueueDepthMetricName = "cloudtasks.googleapis.com/queue/depth"
+	defaultCloudTasksTargetValue              = 100
+	// defaultCloudTasksFilterDuration is the window of the latest queue depth, the metric is sampled every minute
+	defaultCloudTasksFilterDuration = 2 * time.Minute
+)
+
+// regexpCloudTasksQueueName matches the IDs of the Cloud Tasks queues, they are quoted in the filter of the metric
+var regexpCloudTasksQueueName = regexp.MustCompile(`^[a-zA-Z0-9-]{1,100}$`)
+
+type cloudTasksScaler struct {
+	client     *StackDriverClient
+	metricType v2beta2.MetricTargetType
+	metadata   *cloudTasksMetadata
+}
+
+type cloudTasksMetadata struct {
+	value           int64
+	activationValue int64
+	filterDuration  time.Duration
+
+	projectID        string
+	queueName        string
+	gcpAuthorization *gcpAuthorizationMetadata
+	scalerIndex      int
+}
+
+func (m cloudTasksMetadata) String() string {
+	gcpAuthorization := ""
+	if m.gcpAuthorization != nil {
+		gcpAuthorization = m.gcpAuthorization.String()
+	}
+	return fmt.Sprintf("{value:%d activationValue:%d filterDuration:%s projectID:%s queueName:%s gcpAuthorization:%s scalerIndex:%d}",
+		m.value, m.activationValue, m.filterDuration, m.projectID, m.queueName, gcpAuthorization, m.scalerIndex)
+}
+
+var gcpCloudTasksLog = logf.Log.WithName("gcp_cloudtasks_scaler")
+
+// NewCloudTasksScaler creates a new cloudTasksScaler
+func NewCloudTasksScaler(ctx context.Context, config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseCloudTasksMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Cloud Tasks metadata: %s", err)
+	}
+
+	client, err := stackDriverClients.acquire(ctx, meta.gcpAuthorization)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the Cloud Monitoring client: %s", err)
+	}
+
+	gcpCloudTasksLog.Info(fmt.Sprintf("Metadata %s", meta))
+
+	return &cloudTasksScaler{
+		client:     client,
+		metricType: metricType,
+		metadata:   meta,
+	}, nil
+}
+
+func parseCloudTasksMetadata(config *ScalerConfig) (*cloudTasksMetadata, error) {
+	meta := cloudTasksMetadata{}
+	meta.value = defaultCloudTasksTargetValue
+	meta.filterDuration = defaultCloudTasksFilterDuration
+
+	if val, ok := config.TriggerMetadata["queueName"]; ok && val != "" {
+		if !regexpCloudTasksQueueName.MatchString(val) {
+			return nil, fmt.Errorf("queueName %q must be the ID of a queue, of letters, numbers and hyphens", val)
+		}
+		meta.queueName = val
+	} else {
+		return nil, fmt.Errorf("no queue name given")
+	}
+
+	if val, ok := config.TriggerMetadata["projectID"]; ok && val != "" {
+		meta.projectID = val
+	} else {
+		return nil, fmt.Errorf("no project ID given")
+	}
+
+	if val, ok := config.TriggerMetadata["value"]; ok && val != "" {
+		value, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpCloudTasksLog.Error(err, "Error parsing value")
+			return nil, fmt.Errorf("error parsing value: %s", err.Error())
+		}
+		if value <= 0 {
+			return nil, fmt.Errorf("value must be positive")
+		}
+		meta.value = value
+	}
+
+	if val, ok := config.TriggerMetadata["activationValue"]; ok && val != "" {
+		activationValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpCloudTasksLog.Error(err, "Error parsing activationValue")
+			return nil, fmt.Errorf("error parsing activationValue: %s", err.Error())
+		}
+		if activationValue < 0 {
+			return nil, fmt.Errorf("activationValue must not be negative")
+		}
+		meta.activationValue = activationValue
+	}
+
+	if val, ok := config.TriggerMetadata["filterDuration"]; ok && val != "" {
+		filterDuration, err := str2duration.ParseDuration(val)
+		if err != nil {
+			gcpCloudTasksLog.Error(err, "Error parsing filterDuration")
+			return nil, fmt.Errorf("error parsing filterDuration: %s", err.Error())
+		}
+		if filterDuration <= 0 {
+			return nil, fmt.Errorf("filterDuration must be positive")
+		}
+		meta.filterDuration = filterDuration
+	}
+
+	auth, err := getGcpAuthorization(config, config.ResolvedEnv)
+	if err != nil {
+		return nil, err
+	}
+	meta.gcpAuthorization = auth
+	meta.scalerIndex = config.ScalerIndex
+	return &meta, nil
+}
+
+// IsActive checks if the queue holds more tasks than the activation value
+func (s *cloudTasksScaler) IsActive(ctx context.Context) (bool, error) {
+	depth, err := s.getQueueDepth(ctx)
+	if err != nil {
+		gcpCloudTasksLog.Error(err, "error getting Active Status")
+		return false, err
+	}
+	return depth > s.metadata.activationValue, nil
+}
+
+func (s *cloudTasksScaler) Close(context.Context) error {
+	if s.client != nil {
+		err := stackDriverClients.release(s.client)
+		s.client = nil
+		if err != nil {
+			gcpCloudTasksLog.Error(err, "error closing StackDriver client")
+		}
+	}
+
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *cloudTasksScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("gcp-ct-%s", s.metadata.queueName))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.value),
+	}
+
+	// Create the metric spec for the HPA
+	metricSpec := v2beta2.MetricSpec{
+		External: externalMetric,
+		Type:     externalMetricType,
+	}
+
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics connects to Stack Driver and finds the depth of the queue
+func (s *cloudTasksScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	depth, err := s.getQueueDepth(ctx)
+	if err != nil {
+		gcpCloudTasksLog.Error(err, "error getting queue depth")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(depth, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getQueueDepth reads the latest depth of the queue in Cloud Monitoring, the queues of the same name in several
+// locations of the project are summed
+func (s *cloudTasksScaler) getQueueDepth(ctx context.Context) (int64, error) {
+	filter := `metric.type="` + cloudTasksStackDriverQueueDepthMetricName + `" AND resource.labels.queue_id="` + s.metadata.queueName + `"`
+	return s.client.GetLatestMetricsSum(ctx, filter, s.metadata.projectID, s.metadata.filterDuration)
+}
@@ -0,0 +1,186 @@
+package scalers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"github.com/stretchr/testify/assert"
+	option "google.golang.org/api/option"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/internal/testutil"
+)
+
+var testCloudTasksResolvedEnv = map[string]string{
+	"SAMPLE_CREDS": "{}",
+}
+
+type parseCloudTasksMetadataTestData struct {
+	authParams map[string]string
+	metadata   map[string]string
+	isError    bool
+}
+
+type gcpCloudTasksMetricIdentifier struct {
+	metadataTestData *parseCloudTasksMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testCloudTasksMetadata = []parseCloudTasksMetadataTestData{
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{nil, map[string]string{"queueName": "myqueue", "projectID": "myproject", "value": "7", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// default value
+	{nil, map[string]string{"queueName": "myqueue", "projectID": "myproject", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// with activationValue and filterDuration
+	{nil, map[string]string{"queueName": "myqueue", "projectID": "myproject", "value": "7", "activationValue": "2", "filterDuration": "5m", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// missing queueName
+	{nil, map[string]string{"queueName": "", "projectID": "myproject", "value": "7", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// queueName with a quote
+	{nil, map[string]string{"queueName": `myqueue" OR "`, "projectID": "myproject", "value": "7", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing projectID
+	{nil, map[string]string{"queueName": "myqueue", "value": "7", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing credentials
+	{nil, map[string]string{"queueName": "myqueue", "projectID": "myproject", "value": "7", "credentialsFromEnv": ""}, true},
+	// malformed value
+	{nil, map[string]string{"queueName": "myqueue", "projectID": "myproject", "value": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero value
+	{nil, map[string]string{"queueName": "myqueue", "projectID": "myproject", "value": "0", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed activationValue
+	{nil, map[string]string{"queueName": "myqueue", "projectID": "myproject", "activationValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// negative activationValue
+	{nil, map[string]string{"queueName": "myqueue", "projectID": "myproject", "activationValue": "-1", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed filterDuration
+	{nil, map[string]string{"queueName": "myqueue", "projectID": "myproject", "filterDuration": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero filterDuration
+	{nil, map[string]string{"queueName": "myqueue", "projectID": "myproject", "filterDuration": "0s", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// Credentials from AuthParams
+	{map[string]string{"GoogleApplicationCredentials": "Creds"}, map[string]string{"queueName": "myqueue", "projectID": "myproject", "value": "7"}, false},
+	// Credentials from AuthParams with empty creds
+	{map[string]string{"GoogleApplicationCredentials": ""}, map[string]string{"queueName": "myqueue", "projectID": "myproject", "value": "7"}, true},
+}
+
+var gcpCloudTasksMetricIdentifiers = []gcpCloudTasksMetricIdentifier{
+	{&testCloudTasksMetadata[1], 0, "s0-gcp-ct-myqueue"},
+	{&testCloudTasksMetadata[1], 1, "s1-gcp-ct-myqueue"},
+}
+
+func TestCloudTasksParseMetadata(t *testing.T) {
+	for _, testData := range testCloudTasksMetadata {
+		_, err := parseCloudTasksMetadata(&ScalerConfig{AuthParams: testData.authParams, TriggerMetadata: testData.metadata, ResolvedEnv: testCloudTasksResolvedEnv})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("Expected error but got success for %v", testData.metadata)
+		}
+	}
+}
+
+func TestCloudTasksParseMetadataDefaults(t *testing.T) {
+	meta, err := parseCloudTasksMetadata(&ScalerConfig{TriggerMetadata: testCloudTasksMetadata[2].metadata, ResolvedEnv: testCloudTasksResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Equal(t, int64(defaultCloudTasksTargetValue), meta.value)
+	assert.Equal(t, int64(0), meta.activationValue)
+	assert.Equal(t, defaultCloudTasksFilterDuration, meta.filterDuration)
+
+	meta, err = parseCloudTasksMetadata(&ScalerConfig{TriggerMetadata: testCloudTasksMetadata[3].metadata, ResolvedEnv: testCloudTasksResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Equal(t, int64(7), meta.value)
+	assert.Equal(t, int64(2), meta.activationValue)
+	assert.Equal(t, 5*time.Minute, meta.filterDuration)
+}
+
+func TestGcpCloudTasksGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range gcpCloudTasksMetricIdentifiers {
+		meta, err := parseCloudTasksMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, ResolvedEnv: testCloudTasksResolvedEnv, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockGcpCloudTasksScaler := cloudTasksScaler{nil, "", meta}
+
+		metricSpec := mockGcpCloudTasksScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+func newCloudTasksQueueDepthSeries(depths ...int64) *monitoringpb.TimeSeries {
+	series := &monitoringpb.TimeSeries{Metric: &metricpb.Metric{Type: cloudTasksStackDriverQueueDepthMetricName}}
+	for _, depth := range depths {
+		series.Points = append(series.Points, &monitoringpb.Point{Value: &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: depth}}})
+	}
+	return series
+}
+
+func TestGcpCloudTasksGetMetrics(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		series   []*monitoringpb.TimeSeries
+		depth    int64
+		isActive bool
+		isError  bool
+	}{
+		// the latest point comes first
+		{"above activation", []*monitoringpb.TimeSeries{newCloudTasksQueueDepthSeries(5, 1)}, 5, true, false},
+		{"at activation", []*monitoringpb.TimeSeries{newCloudTasksQueueDepthSeries(2, 5)}, 2, false, false},
+		{"several locations", []*monitoringpb.TimeSeries{newCloudTasksQueueDepthSeries(1), newCloudTasksQueueDepthSeries(3)}, 4, true, false},
+		{"no recent point", nil, 0, false, true},
+	} {
+		fake := &fakeMonitoringServer{series: testData.series, requests: make(chan *monitoringpb.ListTimeSeriesRequest, 2)}
+		server := testutil.NewFaultyGRPCServer(t, func(s *grpc.Server) {
+			monitoringpb.RegisterMetricServiceServer(s, fake)
+		}, testutil.Faults{})
+		metricsClient, err := monitoring.NewMetricClient(context.Background(), option.WithEndpoint(server.Addr), option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+		if err != nil {
+			t.Fatal("Could not create the client:", err)
+		}
+
+		meta, err := parseCloudTasksMetadata(&ScalerConfig{TriggerMetadata: testCloudTasksMetadata[3].metadata, ResolvedEnv: testCloudTasksResolvedEnv})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		s := cloudTasksScaler{client: &StackDriverClient{metricsClient: metricsClient}, metadata: meta}
+
+		metrics, err := s.GetMetrics(context.Background(), "s0-gcp-ct-myqueue", nil)
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if err == nil && metrics[0].Value.Value() != testData.depth {
+			t.Errorf("%s: expected the depth %d, got %d", testData.name, testData.depth, metrics[0].Value.Value())
+		}
+
+		isActive, err := s.IsActive(context.Background())
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if isActive != testData.isActive {
+			t.Errorf("%s: expected active %t, got %t", testData.name, testData.isActive, isActive)
+		}
+
+		req := <-fake.requests
+		if req.Name != "projects/myproject" || !strings.Contains(req.Filter, `resource.labels.queue_id="myqueue"`) || !strings.Contains(req.Filter, cloudTasksStackDriverQueueDepthMetricName) {
+			t.Errorf("%s: expected the depth of the queue to be requested, got %s %s", testData.name, req.Name, req.Filter)
+		}
+		if filterDuration := req.Interval.EndTime.Seconds - req.Interval.StartTime.Seconds; filterDuration != int64((5 * time.Minute).Seconds()) {
+			t.Errorf("%s: expected the filterDuration as interval, got %ds", testData.name, filterDuration)
+		}
+		if err := s.Close(context.Background()); err != nil {
+			t.Errorf("%s: unexpected error closing the scaler: %s", testData.name, err)
+		}
+	}
+}
@@ -1,18 +1,236 @@
 package scalers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/impersonate"
+	option "google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
 )
 
+const (
+	// gcpExternalAccountType is the type of the credential configurations of Workload Identity Federation
+	gcpExternalAccountType = "external_account"
+	// gcpCloudPlatformScope is the scope of the tokens of the credential configurations, they are shared by the clients
+	// of all the GCP APIs and impersonate the target service accounts
+	gcpCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
 type gcpAuthorizationMetadata struct {
 	GoogleApplicationCredentials     string
 	GoogleApplicationCredentialsFile string
-	podIdentityOwner                 bool
-	podIdentityProviderEnabled       bool
+	// credentialsConfig is the external_account credential configuration of Workload Identity Federation
+	credentialsConfig string
+	// targetServiceAccount is the service account impersonated with the credentials, or with the identity of the pod
+	// or of the KEDA operator when none are given
+	targetServiceAccount string
+	// quotaProject is the project the requests to the GCP APIs are billed and rate limited against, instead of the
+	// quota project of the credentials
+	quotaProject string
+	// apiEndpoint is the endpoint of the GCP APIs the scaler reads, e.g. restricted.googleapis.com or a Private Service
+	// Connect one, instead of their googleapis.com one. An http endpoint is the one of an emulator
+	apiEndpoint                string
+	podIdentityOwner           bool
+	podIdentityProviderEnabled bool
+}
+
+// String renders the authorization for the logs, the inline credentials are redacted as they hold a private key
+func (m gcpAuthorizationMetadata) String() string {
+	return fmt.Sprintf("{GoogleApplicationCredentials:%s GoogleApplicationCredentialsFile:%s credentialsConfig:%s targetServiceAccount:%s quotaProject:%s apiEndpoint:%s podIdentityOwner:%t podIdentityProviderEnabled:%t}",
+		redactGcpCredentials(m.GoogleApplicationCredentials), m.GoogleApplicationCredentialsFile, redactGcpCredentials(m.credentialsConfig),
+		m.targetServiceAccount, m.quotaProject, m.apiEndpoint, m.podIdentityOwner, m.podIdentityProviderEnabled)
+}
+
+// gcpAPI describes how the clients of a GCP API reach it on an overridden endpoint
+type gcpAPI struct {
+	// basePath is the path of a REST API on its endpoint
+	basePath string
+	grpc     bool
+}
+
+var (
+	gcpBigQueryAPI   = gcpAPI{basePath: "bigquery/v2/"}
+	gcpDataflowAPI   = gcpAPI{}
+	gcpFirestoreAPI  = gcpAPI{}
+	gcpLoggingAPI    = gcpAPI{}
+	gcpMonitoringAPI = gcpAPI{grpc: true}
+	gcpPubSubAPI     = gcpAPI{}
+	gcpStorageAPI    = gcpAPI{basePath: "storage/v1/"}
+)
+
+// clientOptions returns the options of the clients of the GCP API authorized with the token source of the
+// authorization, on the endpoint of the authorization if it's overridden. No credentials are sent to an emulator
+func (m *gcpAuthorizationMetadata) clientOptions(tokenSource oauth2.TokenSource, api gcpAPI) []option.ClientOption {
+	var options []option.ClientOption
+	if m.apiEndpoint != "" {
+		endpoint, _ := url.Parse(m.apiEndpoint)
+		switch {
+		case !api.grpc:
+			options = append(options, option.WithEndpoint(m.apiEndpoint+"/"+api.basePath))
+		case endpoint.Port() != "":
+			options = append(options, option.WithEndpoint(endpoint.Host))
+		default:
+			options = append(options, option.WithEndpoint(endpoint.Host+":443"))
+		}
+		if endpoint.Scheme == "http" {
+			options = append(options, option.WithoutAuthentication())
+			if api.grpc {
+				options = append(options, option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+			}
+			return options
+		}
+	}
+
+	options = append(options, option.WithTokenSource(tokenSource))
+	if m.quotaProject != "" {
+		options = append(options, option.WithQuotaProject(m.quotaProject))
+	}
+	return options
+}
+
+func redactGcpCredentials(credentials string) string {
+	if credentials == "" {
+		return ""
+	}
+	return "<redacted>"
+}
+
+// gcpStsHostPatterns match the hosts of the Google Security Token Service the subject tokens of Workload Identity
+// Federation may be sent to. The Google client libraries stopped checking the token_url, a credential configuration
+// with another one would send the subject tokens elsewhere
+var gcpStsHostPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^[^\.\s\/\\]+\.sts\.googleapis\.com$`),
+	regexp.MustCompile(`(?i)^sts\.googleapis\.com$`),
+	regexp.MustCompile(`(?i)^sts\.[^\.\s\/\\]+\.googleapis\.com$`),
+	regexp.MustCompile(`(?i)^[^\.\s\/\\]+-sts\.googleapis\.com$`),
+}
+
+// isGcpStsTokenURL checks if the token_url of a credential configuration is an https URL of the Google STS
+func isGcpStsTokenURL(tokenURL string) bool {
+	parsed, err := url.Parse(tokenURL)
+	if err != nil || !strings.EqualFold(parsed.Scheme, "https") {
+		return false
+	}
+	for _, pattern := range gcpStsHostPatterns {
+		if pattern.MatchString(parsed.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// gcpExternalAccountConfig holds the fields of an external_account credential configuration that are checked before
+// it is used, the Google client libraries read the others
+type gcpExternalAccountConfig struct {
+	Type             string          `json:"type"`
+	Audience         string          `json:"audience"`
+	SubjectTokenType string          `json:"subject_token_type"`
+	TokenURL         string          `json:"token_url"`
+	CredentialSource json.RawMessage `json:"credential_source"`
+	QuotaProjectID   string          `json:"quota_project_id"`
+}
+
+// validateGcpExternalAccountConfig checks that the credential configuration of Workload Identity Federation is an
+// external_account one, e.g. not a service account key given by mistake, with the fields the token exchange needs
+func validateGcpExternalAccountConfig(credentialsConfig string) error {
+	var config gcpExternalAccountConfig
+	if err := json.Unmarshal([]byte(credentialsConfig), &config); err != nil {
+		return fmt.Errorf("error parsing credentialsConfig: %s", err)
+	}
+
+	switch {
+	case config.Type != gcpExternalAccountType:
+		return fmt.Errorf("credentialsConfig must be of type %s, got %q", gcpExternalAccountType, config.Type)
+	case config.Audience == "":
+		return fmt.Errorf("no audience given in credentialsConfig")
+	case config.SubjectTokenType == "":
+		return fmt.Errorf("no subject_token_type given in credentialsConfig")
+	case config.TokenURL == "":
+		return fmt.Errorf("no token_url given in credentialsConfig")
+	case !isGcpStsTokenURL(config.TokenURL):
+		return fmt.Errorf("token_url of credentialsConfig must be an https URL of sts.googleapis.com, got %s", config.TokenURL)
+	case len(config.CredentialSource) == 0 || string(config.CredentialSource) == "null":
+		return fmt.Errorf("no credential_source given in credentialsConfig")
+	}
+	return nil
+}
+
+// getGcpExternalAccountQuotaProject returns the quota project of a credential configuration of Workload Identity
+// Federation, if any
+func getGcpExternalAccountQuotaProject(credentialsConfig string) string {
+	var config gcpExternalAccountConfig
+	if err := json.Unmarshal([]byte(credentialsConfig), &config); err != nil {
+		return ""
+	}
+	return config.QuotaProjectID
+}
+
+// getGcpCredentialsFileProject returns the project of the credentials in a file, the one of a service account key or
+// the quota project of a credential configuration, or an empty string if it has none
+func getGcpCredentialsFileProject(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading the credentials file: %s", err)
+	}
+	var gcpCredentials GoogleApplicationCredentials
+	if err := json.Unmarshal(content, &gcpCredentials); err != nil {
+		return "", fmt.Errorf("error parsing the credentials file: %s", err)
+	}
+	if gcpCredentials.ProjectID != "" {
+		return gcpCredentials.ProjectID, nil
+	}
+	return getGcpExternalAccountQuotaProject(string(content)), nil
+}
+
+// getGcpCredentialsType returns the type of JSON credentials, e.g. service_account or external_account, or an empty
+// string if they can't be parsed
+func getGcpCredentialsType(credentials string) string {
+	var config struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(credentials), &config); err != nil {
+		return ""
+	}
+	return config.Type
+}
+
+// setCredentialsConfig validates the external_account credential configuration of Workload Identity Federation and
+// creates its token source, which the clients created for it share so that the STS token exchange isn't redone for
+// each of them
+func (m *gcpAuthorizationMetadata) setCredentialsConfig(credentialsConfig string) error {
+	if err := validateGcpExternalAccountConfig(credentialsConfig); err != nil {
+		return err
+	}
+	if _, err := gcpTokenSources.get(context.Background(), &gcpAuthorizationMetadata{credentialsConfig: credentialsConfig}); err != nil {
+		return fmt.Errorf("error parsing credentialsConfig: %s", err)
+	}
+	m.credentialsConfig = credentialsConfig
+	return nil
+}
+
+// hasNoCredentials is true when the default credentials of the KEDA operator are used, e.g. to impersonate the
+// target service account
+func (m *gcpAuthorizationMetadata) hasNoCredentials() bool {
+	return m.GoogleApplicationCredentials == "" && m.GoogleApplicationCredentialsFile == "" && m.credentialsConfig == ""
 }
 
+// getGcpAuthorization reads the credentials of the GCP scalers. With the pod as identity owner, the first source set
+// is used: the GoogleApplicationCredentials auth param, then the GoogleApplicationCredentialsFile one, then the env
+// var named by credentialsFromEnv or credentialsFromEnvFile, then the GoogleApplicationCredentialsFile trigger
+// metadata, then the GCP pod identity. The TriggerAuthentication wins over the trigger, and the inline credentials
+// over the files. The inline credentials are either a service account key or an external_account credential
+// configuration of Workload Identity Federation. The files, e.g. mounted from a Secret, must be readable when the
+// scaler is created, they are read again when they change.
 func getGcpAuthorization(config *ScalerConfig, resolvedEnv map[string]string) (*gcpAuthorizationMetadata, error) {
 	metadata := config.TriggerMetadata
 	authParams := config.AuthParams
@@ -22,21 +240,167 @@ func getGcpAuthorization(config *ScalerConfig, resolvedEnv map[string]string) (*
 	} else if metadata["identityOwner"] == "" || metadata["identityOwner"] == "pod" {
 		meta.podIdentityOwner = true
 		switch {
+		case authParams["GoogleApplicationCredentials"] != "":
+			meta.GoogleApplicationCredentials = authParams["GoogleApplicationCredentials"]
+		case authParams["GoogleApplicationCredentialsFile"] != "":
+			meta.GoogleApplicationCredentialsFile = authParams["GoogleApplicationCredentialsFile"]
+		case metadata["credentialsFromEnv"] != "":
+			meta.GoogleApplicationCredentials = resolvedEnv[metadata["credentialsFromEnv"]]
+			if meta.GoogleApplicationCredentials == "" {
+				return nil, fmt.Errorf("no credentials found in the env var %s of credentialsFromEnv", metadata["credentialsFromEnv"])
+			}
+		case metadata["credentialsFromEnvFile"] != "":
+			meta.GoogleApplicationCredentialsFile = resolvedEnv[metadata["credentialsFromEnvFile"]]
+			if meta.GoogleApplicationCredentialsFile == "" {
+				return nil, fmt.Errorf("no credentials file found in the env var %s of credentialsFromEnvFile", metadata["credentialsFromEnvFile"])
+			}
+		case metadata["GoogleApplicationCredentialsFile"] != "":
+			meta.GoogleApplicationCredentialsFile = metadata["GoogleApplicationCredentialsFile"]
 		case config.PodIdentity == kedav1alpha1.PodIdentityProviderGCP:
 			// do nothing, rely on underneath metadata google
 			meta.podIdentityProviderEnabled = true
-		case authParams["GoogleApplicationCredentials"] != "":
-			meta.GoogleApplicationCredentials = authParams["GoogleApplicationCredentials"]
 		default:
-			switch {
-			case metadata["credentialsFromEnv"] != "":
-				meta.GoogleApplicationCredentials = resolvedEnv[metadata["credentialsFromEnv"]]
-			case metadata["credentialsFromEnvFile"] != "":
-				meta.GoogleApplicationCredentialsFile = resolvedEnv[metadata["credentialsFromEnvFile"]]
-			default:
-				return nil, fmt.Errorf("GoogleApplicationCredentials not found")
+			return nil, fmt.Errorf("GoogleApplicationCredentials not found")
+		}
+
+		if meta.GoogleApplicationCredentialsFile != "" {
+			if err := validateGcpCredentialsFile(meta.GoogleApplicationCredentialsFile); err != nil {
+				return nil, err
 			}
 		}
+
+		// the credential configurations of Workload Identity Federation, e.g. on EKS or AKS, are given as credentials too
+		if getGcpCredentialsType(meta.GoogleApplicationCredentials) == gcpExternalAccountType {
+			if err := meta.setCredentialsConfig(meta.GoogleApplicationCredentials); err != nil {
+				return nil, err
+			}
+			meta.GoogleApplicationCredentials = ""
+		}
+	}
+
+	if err := meta.parseClientMetadata(config); err != nil {
+		return nil, err
 	}
 	return &meta, nil
 }
+
+// parseClientMetadata sets the impersonated service account, the quota project and the endpoint of the clients of
+// the authorization
+func (m *gcpAuthorizationMetadata) parseClientMetadata(config *ScalerConfig) error {
+	targetServiceAccount, err := getGcpTargetServiceAccount(config)
+	if err != nil {
+		return err
+	}
+	m.targetServiceAccount = targetServiceAccount
+
+	quotaProject, err := getGcpQuotaProject(config)
+	if err != nil {
+		return err
+	}
+	m.quotaProject = quotaProject
+
+	apiEndpoint, err := parseGcpAPIEndpoint(config.TriggerMetadata)
+	if err != nil {
+		return err
+	}
+	m.apiEndpoint = apiEndpoint
+	return nil
+}
+
+// parseGcpAPIEndpoint returns the apiEndpoint of the trigger as scheme://host[:port], the clients add the path of
+// their API
+func parseGcpAPIEndpoint(metadata map[string]string) (string, error) {
+	val, ok := metadata["apiEndpoint"]
+	if !ok || val == "" {
+		return "", nil
+	}
+	endpoint, err := url.Parse(val)
+	switch {
+	case err != nil:
+		return "", fmt.Errorf("error parsing apiEndpoint: %s", err)
+	case endpoint.Scheme != "https" && endpoint.Scheme != "http":
+		return "", fmt.Errorf("apiEndpoint %s must be an https URL, or an http one of an emulator", val)
+	case endpoint.Host == "" || endpoint.Hostname() == "":
+		return "", fmt.Errorf("apiEndpoint %s must have a host", val)
+	case endpoint.User != nil || (endpoint.Path != "" && endpoint.Path != "/") || endpoint.RawQuery != "" || endpoint.Fragment != "":
+		return "", fmt.Errorf("apiEndpoint %s must only be the scheme and the host of the endpoint", val)
+	}
+	return endpoint.Scheme + "://" + endpoint.Host, nil
+}
+
+// validateGcpCredentialsFile checks that the credentials file is readable and holds JSON credentials
+func validateGcpCredentialsFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading the credentials file: %s", err)
+	}
+	if getGcpCredentialsType(string(content)) == "" {
+		return fmt.Errorf("the credentials file %s doesn't hold JSON credentials", path)
+	}
+	return nil
+}
+
+// getGcpTargetServiceAccount reads the service account to impersonate, from the TriggerAuthentication or the trigger
+// metadata
+func getGcpTargetServiceAccount(config *ScalerConfig) (string, error) {
+	targetServiceAccount := config.AuthParams["targetServiceAccount"]
+	if targetServiceAccount == "" {
+		targetServiceAccount = config.TriggerMetadata["targetServiceAccount"]
+	}
+	if targetServiceAccount != "" && !strings.Contains(targetServiceAccount, "@") {
+		return "", fmt.Errorf("targetServiceAccount must be the email of a service account, got %q", targetServiceAccount)
+	}
+	return targetServiceAccount, nil
+}
+
+// getGcpQuotaProject returns the quota project of the authParams, or else of the trigger metadata, if any
+func getGcpQuotaProject(config *ScalerConfig) (string, error) {
+	for _, params := range []map[string]string{config.AuthParams, config.TriggerMetadata} {
+		if val, ok := params["quotaProject"]; ok {
+			if strings.TrimSpace(val) == "" {
+				return "", fmt.Errorf("quotaProject must be the ID of a project")
+			}
+			return val, nil
+		}
+	}
+	return "", nil
+}
+
+// newGcpImpersonatedTokenSource returns the token source of the access tokens of the target service account, minted
+// with the base credentials of the options. A first token is fetched so that a missing
+// iam.serviceAccountTokenCreator role on the target fails the creation of the scaler rather than its polls
+func newGcpImpersonatedTokenSource(ctx context.Context, targetServiceAccount string, scopes []string, options []option.ClientOption) (oauth2.TokenSource, error) {
+	// the token source refreshes the tokens for the life of the client, it mustn't use ctx
+	tokenSource, err := impersonate.CredentialsTokenSource(context.Background(), impersonate.CredentialsConfig{
+		TargetPrincipal: targetServiceAccount,
+		Scopes:          scopes,
+	}, options...)
+	if err != nil {
+		return nil, fmt.Errorf("error impersonating the service account %s: %s", targetServiceAccount, err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := tokenSource.Token()
+		errs <- err
+	}()
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error impersonating the service account %s: %s", targetServiceAccount, err)
+	}
+	return tokenSource, nil
+}
+
+// gcpServiceAccountProject returns the project of a user-managed service account, e.g. team-a for
+// keda@team-a.iam.gserviceaccount.com, or an empty string for the other accounts
+func gcpServiceAccountProject(serviceAccount string) string {
+	at := strings.LastIndex(serviceAccount, "@")
+	if at < 0 || !strings.HasSuffix(serviceAccount, ".iam.gserviceaccount.com") {
+		return ""
+	}
+	return strings.TrimSuffix(serviceAccount[at+1:], ".iam.gserviceaccount.com")
+}
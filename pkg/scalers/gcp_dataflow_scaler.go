@@ -0,0 +1,305 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	dataflow "google.golang.org/api/dataflow/v1b3"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	dataflowMetricBacklogElements  = "backlog_elements"
+	dataflowMetricBacklogBytes     = "backlog_bytes"
+	dataflowMetricSystemLag        = "system_lag"
+	dataflowMetricDataWatermarkAge = "data_watermark_age"
+
+	// dataflowMetricsLookback is the window of the latest point of the metrics of the job, they are published with
+	// a delay of a few minutes
+	dataflowMetricsLookback = 5 * time.Minute
+)
+
+// dataflowMetricReducers reduce the time series of a job for each metric, e.g. of the backlogs of its sources, which
+// add up, while the lags are the one of the most late source
+var dataflowMetricReducers = map[string]monitoringpb.Aggregation_Reducer{
+	dataflowMetricBacklogElements:  monitoringpb.Aggregation_REDUCE_SUM,
+	dataflowMetricBacklogBytes:     monitoringpb.Aggregation_REDUCE_SUM,
+	dataflowMetricSystemLag:        monitoringpb.Aggregation_REDUCE_MAX,
+	dataflowMetricDataWatermarkAge: monitoringpb.Aggregation_REDUCE_MAX,
+}
+
+// dataflowTerminalJobStates are the states of the jobs that won't run anymore
+var dataflowTerminalJobStates = map[string]bool{
+	"JOB_STATE_DONE":      true,
+	"JOB_STATE_FAILED":    true,
+	"JOB_STATE_CANCELLED": true,
+	"JOB_STATE_UPDATED":   true,
+	"JOB_STATE_DRAINED":   true,
+}
+
+var (
+	// regexpDataflowJobName and regexpDataflowJobID match the names and the IDs of the jobs, they are quoted in the
+	// filter of the metrics
+	regexpDataflowJobName = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+	regexpDataflowJobID   = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+type dataflowScaler struct {
+	client     *StackDriverClient
+	jobs       *dataflow.Service
+	metricType v2beta2.MetricTargetType
+	metadata   *dataflowMetadata
+}
+
+type dataflowMetadata struct {
+	projectID             string
+	jobName               string
+	jobID                 string
+	metricName            string
+	targetValue           int64
+	activationTargetValue float64
+
+	gcpAuthorization *gcpAuthorizationMetadata
+	scalerIndex      int
+}
+
+func (m dataflowMetadata) String() string {
+	gcpAuthorization := ""
+	if m.gcpAuthorization != nil {
+		gcpAuthorization = m.gcpAuthorization.String()
+	}
+	return fmt.Sprintf("{projectID:%s jobName:%s jobID:%s metricName:%s targetValue:%d activationTargetValue:%g gcpAuthorization:%s scalerIndex:%d}",
+		m.projectID, m.jobName, m.jobID, m.metricName, m.targetValue, m.activationTargetValue, gcpAuthorization, m.scalerIndex)
+}
+
+var gcpDataflowLog = logf.Log.WithName("gcp_dataflow_scaler")
+
+// NewDataflowScaler creates a new dataflowScaler
+func NewDataflowScaler(ctx context.Context, config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseDataflowMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Dataflow metadata: %s", err)
+	}
+
+	// the tokens are shared with the Cloud Monitoring client, and the other GCP scalers using the same credentials
+	tokenSource, err := gcpTokenSources.get(ctx, meta.gcpAuthorization)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := dataflow.NewService(ctx, meta.gcpAuthorization.clientOptions(tokenSource, gcpDataflowAPI)...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the Dataflow client: %s", err)
+	}
+
+	client, err := stackDriverClients.acquire(ctx, meta.gcpAuthorization)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the Cloud Monitoring client: %s", err)
+	}
+
+	gcpDataflowLog.Info(fmt.Sprintf("Metadata %s", meta))
+
+	return &dataflowScaler{
+		client:     client,
+		jobs:       jobs,
+		metricType: metricType,
+		metadata:   meta,
+	}, nil
+}
+
+func parseDataflowMetadata(config *ScalerConfig) (*dataflowMetadata, error) {
+	meta := dataflowMetadata{}
+	meta.metricName = dataflowMetricBacklogElements
+
+	if val, ok := config.TriggerMetadata["projectID"]; ok && val != "" {
+		meta.projectID = val
+	} else {
+		return nil, fmt.Errorf("no project ID given")
+	}
+
+	if val, ok := config.TriggerMetadata["jobName"]; ok && val != "" {
+		if !regexpDataflowJobName.MatchString(val) {
+			return nil, fmt.Errorf("jobName %q must be the name of a job, of lowercase letters, numbers and hyphens", val)
+		}
+		meta.jobName = val
+	}
+
+	if val, ok := config.TriggerMetadata["jobID"]; ok && val != "" {
+		if meta.jobName != "" {
+			return nil, fmt.Errorf("jobName and jobID can't be both given")
+		}
+		if !regexpDataflowJobID.MatchString(val) {
+			return nil, fmt.Errorf("jobID %q must be the ID of a job", val)
+		}
+		meta.jobID = val
+	}
+
+	if meta.jobName == "" && meta.jobID == "" {
+		return nil, fmt.Errorf("no jobName or jobID given")
+	}
+
+	if val, ok := config.TriggerMetadata["metricName"]; ok && val != "" {
+		if _, ok := dataflowMetricReducers[val]; !ok {
+			return nil, fmt.Errorf("metricName %s must be one of %s, %s, %s, %s", val,
+				dataflowMetricBacklogElements, dataflowMetricBacklogBytes, dataflowMetricSystemLag, dataflowMetricDataWatermarkAge)
+		}
+		meta.metricName = val
+	}
+
+	if val, ok := config.TriggerMetadata["targetValue"]; ok && val != "" {
+		targetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpDataflowLog.Error(err, "Error parsing targetValue")
+			return nil, fmt.Errorf("error parsing targetValue: %s", err.Error())
+		}
+		if targetValue <= 0 {
+			return nil, fmt.Errorf("targetValue must be greater than 0")
+		}
+		meta.targetValue = targetValue
+	} else {
+		return nil, fmt.Errorf("no targetValue given")
+	}
+
+	activationTargetValue, err := getFloatMetadataValue(config.TriggerMetadata, "activationTargetValue", false, 0)
+	if err != nil {
+		return nil, err
+	}
+	meta.activationTargetValue = activationTargetValue
+
+	auth, err := getGcpAuthorization(config, config.ResolvedEnv)
+	if err != nil {
+		return nil, err
+	}
+	meta.gcpAuthorization = auth
+	meta.scalerIndex = config.ScalerIndex
+	return &meta, nil
+}
+
+// IsActive checks if the job is running with a metric above the activation target value
+func (s *dataflowScaler) IsActive(ctx context.Context) (bool, error) {
+	value, running, err := s.getJobMetric(ctx)
+	if err != nil {
+		gcpDataflowLog.Error(err, "error getting Active Status")
+		return false, err
+	}
+	return running && value > s.metadata.activationTargetValue, nil
+}
+
+func (s *dataflowScaler) Close(context.Context) error {
+	if s.client != nil {
+		err := stackDriverClients.release(s.client)
+		s.client = nil
+		if err != nil {
+			gcpDataflowLog.Error(err, "error closing StackDriver client")
+		}
+	}
+
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *dataflowScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	job := s.metadata.jobName
+	if job == "" {
+		job = s.metadata.jobID
+	}
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("gcp-dataflow-%s", job))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+
+	// Create the metric spec for the HPA
+	metricSpec := v2beta2.MetricSpec{
+		External: externalMetric,
+		Type:     externalMetricType,
+	}
+
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the metric of the job, 0 when it isn't running
+func (s *dataflowScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	value, _, err := s.getJobMetric(ctx)
+	if err != nil {
+		gcpDataflowLog.Error(err, "error getting job metric")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getJobMetric returns the latest value of the metric of the job in Cloud Monitoring, and whether the job is running.
+// The metric of a job in a terminal state, or of a missing one, is 0
+func (s *dataflowScaler) getJobMetric(ctx context.Context) (float64, bool, error) {
+	job, err := s.getActiveJob(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	if job == nil || dataflowTerminalJobStates[job.CurrentState] {
+		gcpDataflowLog.V(1).Info("Dataflow job isn't running", "jobName", s.metadata.jobName, "jobID", s.metadata.jobID)
+		return 0, false, nil
+	}
+
+	filter := `metric.type="dataflow.googleapis.com/job/` + s.metadata.metricName + `" AND resource.type="dataflow_job" AND metric.labels.job_id="` + job.Id + `"`
+	aggregation := &monitoringpb.Aggregation{
+		AlignmentPeriod:    durationpb.New(time.Minute),
+		PerSeriesAligner:   monitoringpb.Aggregation_ALIGN_MEAN,
+		CrossSeriesReducer: dataflowMetricReducers[s.metadata.metricName],
+	}
+	value, err := s.client.GetMetricsWithAggregation(ctx, filter, s.metadata.projectID, aggregation, dataflowMetricsLookback)
+	if err != nil {
+		return 0, false, err
+	}
+	return value, true, nil
+}
+
+// getActiveJob returns the job of the name, or of the ID, among the ones of the project that aren't in a terminal
+// state, in all the regions. It returns nil if there's none
+func (s *dataflowScaler) getActiveJob(ctx context.Context) (*dataflow.Job, error) {
+	var found []*dataflow.Job
+	err := s.jobs.Projects.Jobs.Aggregated(s.metadata.projectID).Filter("ACTIVE").Pages(ctx, func(resp *dataflow.ListJobsResponse) error {
+		for _, job := range resp.Jobs {
+			if (s.metadata.jobID != "" && job.Id == s.metadata.jobID) || (s.metadata.jobName != "" && job.Name == s.metadata.jobName) {
+				found = append(found, job)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing the Dataflow jobs: %s", err)
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, nil
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("%d Dataflow jobs named %s are running, give the jobID instead", len(found), s.metadata.jobName)
+	}
+}
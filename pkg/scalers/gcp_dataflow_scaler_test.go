@@ -0,0 +1,204 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"github.com/stretchr/testify/assert"
+	dataflow "google.golang.org/api/dataflow/v1b3"
+	option "google.golang.org/api/option"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/internal/testutil"
+)
+
+var testDataflowResolvedEnv = map[string]string{
+	"SAMPLE_CREDS": "{}",
+}
+
+type parseDataflowMetadataTestData struct {
+	authParams map[string]string
+	metadata   map[string]string
+	isError    bool
+}
+
+type gcpDataflowMetricIdentifier struct {
+	metadataTestData *parseDataflowMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testDataflowMetadata = []parseDataflowMetadataTestData{
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed with jobName
+	{nil, map[string]string{"projectID": "myproject", "jobName": "preprocessing", "targetValue": "100", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// all properly formed with jobID, metricName and activationTargetValue
+	{nil, map[string]string{"projectID": "myproject", "jobID": "2022-05-01_01_02_03-123456789", "metricName": "system_lag", "targetValue": "60", "activationTargetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// missing projectID
+	{nil, map[string]string{"jobName": "preprocessing", "targetValue": "100", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing jobName and jobID
+	{nil, map[string]string{"projectID": "myproject", "targetValue": "100", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// jobName and jobID
+	{nil, map[string]string{"projectID": "myproject", "jobName": "preprocessing", "jobID": "2022-05-01_01_02_03-123456789", "targetValue": "100", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// jobName with a quote
+	{nil, map[string]string{"projectID": "myproject", "jobName": `preprocessing" OR "`, "targetValue": "100", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// jobID with a quote
+	{nil, map[string]string{"projectID": "myproject", "jobID": `123" OR "`, "targetValue": "100", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// unknown metricName
+	{nil, map[string]string{"projectID": "myproject", "jobName": "preprocessing", "metricName": "elements_produced_count", "targetValue": "100", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing targetValue
+	{nil, map[string]string{"projectID": "myproject", "jobName": "preprocessing", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed targetValue
+	{nil, map[string]string{"projectID": "myproject", "jobName": "preprocessing", "targetValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero targetValue
+	{nil, map[string]string{"projectID": "myproject", "jobName": "preprocessing", "targetValue": "0", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed activationTargetValue
+	{nil, map[string]string{"projectID": "myproject", "jobName": "preprocessing", "targetValue": "100", "activationTargetValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing credentials
+	{nil, map[string]string{"projectID": "myproject", "jobName": "preprocessing", "targetValue": "100", "credentialsFromEnv": ""}, true},
+	// Credentials from AuthParams
+	{map[string]string{"GoogleApplicationCredentials": "Creds"}, map[string]string{"projectID": "myproject", "jobName": "preprocessing", "targetValue": "100"}, false},
+}
+
+var gcpDataflowMetricIdentifiers = []gcpDataflowMetricIdentifier{
+	{&testDataflowMetadata[1], 0, "s0-gcp-dataflow-preprocessing"},
+	{&testDataflowMetadata[1], 1, "s1-gcp-dataflow-preprocessing"},
+	{&testDataflowMetadata[2], 0, "s0-gcp-dataflow-2022-05-01_01_02_03-123456789"},
+}
+
+func TestDataflowParseMetadata(t *testing.T) {
+	for _, testData := range testDataflowMetadata {
+		_, err := parseDataflowMetadata(&ScalerConfig{AuthParams: testData.authParams, TriggerMetadata: testData.metadata, ResolvedEnv: testDataflowResolvedEnv})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("Expected error but got success for %v", testData.metadata)
+		}
+	}
+}
+
+func TestDataflowParseMetadataDefaults(t *testing.T) {
+	meta, err := parseDataflowMetadata(&ScalerConfig{TriggerMetadata: testDataflowMetadata[1].metadata, ResolvedEnv: testDataflowResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Equal(t, dataflowMetricBacklogElements, meta.metricName)
+	assert.Equal(t, float64(0), meta.activationTargetValue)
+}
+
+func TestGcpDataflowGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range gcpDataflowMetricIdentifiers {
+		meta, err := parseDataflowMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, ResolvedEnv: testDataflowResolvedEnv, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockGcpDataflowScaler := dataflowScaler{metadata: meta}
+
+		metricSpec := mockGcpDataflowScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+// newFakeDataflowJobs returns a client of a fake Dataflow API listing the jobs as the active ones of the project
+func newFakeDataflowJobs(t *testing.T, jobs []*dataflow.Job) *dataflow.Service {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1b3/projects/myproject/jobs:aggregated" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.Equal(t, "ACTIVE", r.URL.Query().Get("filter"))
+		_ = json.NewEncoder(w).Encode(dataflow.ListJobsResponse{Jobs: jobs})
+	}))
+	t.Cleanup(server.Close)
+
+	service, err := dataflow.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal("Could not create the client:", err)
+	}
+	return service
+}
+
+func TestGcpDataflowGetMetrics(t *testing.T) {
+	preprocessing := &dataflow.Job{Id: "2022-05-01_01_02_03-123456789", Name: "preprocessing", CurrentState: "JOB_STATE_RUNNING"}
+	for _, testData := range []struct {
+		name     string
+		metadata map[string]string
+		jobs     []*dataflow.Job
+		value    float64
+		reducer  monitoringpb.Aggregation_Reducer
+		isActive bool
+		isError  bool
+	}{
+		{"backlog of job name", testDataflowMetadata[1].metadata, []*dataflow.Job{{Id: "other", Name: "other"}, preprocessing}, 12, monitoringpb.Aggregation_REDUCE_SUM, true, false},
+		{"system lag of job ID", testDataflowMetadata[2].metadata, []*dataflow.Job{preprocessing}, 45, monitoringpb.Aggregation_REDUCE_MAX, true, false},
+		{"system lag below activation", testDataflowMetadata[2].metadata, []*dataflow.Job{preprocessing}, 5, monitoringpb.Aggregation_REDUCE_MAX, false, false},
+		{"terminal job", testDataflowMetadata[1].metadata, nil, 0, monitoringpb.Aggregation_REDUCE_NONE, false, false},
+		{"draining to a terminal state", testDataflowMetadata[1].metadata, []*dataflow.Job{{Id: preprocessing.Id, Name: "preprocessing", CurrentState: "JOB_STATE_DRAINED"}}, 0, monitoringpb.Aggregation_REDUCE_NONE, false, false},
+		{"several jobs of the name", testDataflowMetadata[1].metadata, []*dataflow.Job{preprocessing, {Id: "other", Name: "preprocessing"}}, 0, monitoringpb.Aggregation_REDUCE_NONE, false, true},
+	} {
+		series := &monitoringpb.TimeSeries{Points: []*monitoringpb.Point{{Value: newStackdriverDoubleValue(testData.value)}}}
+		fake := &fakeMonitoringServer{series: []*monitoringpb.TimeSeries{series}, requests: make(chan *monitoringpb.ListTimeSeriesRequest, 2)}
+		server := testutil.NewFaultyGRPCServer(t, func(s *grpc.Server) {
+			monitoringpb.RegisterMetricServiceServer(s, fake)
+		}, testutil.Faults{})
+		metricsClient, err := monitoring.NewMetricClient(context.Background(), option.WithEndpoint(server.Addr), option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+		if err != nil {
+			t.Fatal("Could not create the client:", err)
+		}
+
+		meta, err := parseDataflowMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, ResolvedEnv: testDataflowResolvedEnv})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		s := dataflowScaler{client: &StackDriverClient{metricsClient: metricsClient}, jobs: newFakeDataflowJobs(t, testData.jobs), metadata: meta}
+
+		metrics, err := s.GetMetrics(context.Background(), "s0-gcp-dataflow-preprocessing", nil)
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if err == nil && metrics[0].Value.MilliValue() != int64(testData.value*1000) {
+			t.Errorf("%s: expected the value %g, got %dm", testData.name, testData.value, metrics[0].Value.MilliValue())
+		}
+
+		isActive, err := s.IsActive(context.Background())
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if isActive != testData.isActive {
+			t.Errorf("%s: expected active %t, got %t", testData.name, testData.isActive, isActive)
+		}
+
+		// the metrics of the jobs that aren't running aren't requested
+		close(fake.requests)
+		requests := 0
+		for req := range fake.requests {
+			requests++
+			if req.Name != "projects/myproject" || !strings.Contains(req.Filter, `metric.type="dataflow.googleapis.com/job/`+meta.metricName+`"`) ||
+				!strings.Contains(req.Filter, `metric.labels.job_id="`+preprocessing.Id+`"`) {
+				t.Errorf("%s: expected the metric of the job to be requested, got %s %s", testData.name, req.Name, req.Filter)
+			}
+			assert.Equal(t, testData.reducer, req.Aggregation.GetCrossSeriesReducer(), testData.name)
+		}
+		if testData.reducer == monitoringpb.Aggregation_REDUCE_NONE {
+			assert.Equal(t, 0, requests, testData.name)
+		} else {
+			assert.Equal(t, 2, requests, testData.name)
+		}
+		if err := s.Close(context.Background()); err != nil {
+			t.Errorf("%s: unexpected error closing the scaler: %s", testData.name, err)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package scalers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	firestore "google.golang.org/api/firestore/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/option/internaloption"
+	htransport "google.golang.org/api/transport/http"
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	// A limit on the documents counted by a query, the count is billed one document read per batch of up to 1000
+	// matching documents
+	defaultFirestoreCountUpTo = 1000
+	defaultFirestoreDatabase  = "(default)"
+	firestoreEndpoint         = "https://firestore.googleapis.com/"
+	firestoreCountAlias       = "count"
+)
+
+// firestoreOperators are the operators of the where clauses, and the ones of the field filters of the API
+var firestoreOperators = map[string]string{
+	"<":                  "LESS_THAN",
+	"<=":                 "LESS_THAN_OR_EQUAL",
+	"==":                 "EQUAL",
+	"!=":                 "NOT_EQUAL",
+	">=":                 "GREATER_THAN_OR_EQUAL",
+	">":                  "GREATER_THAN",
+	"array-contains":     "ARRAY_CONTAINS",
+	"in":                 "IN",
+	"not-in":             "NOT_IN",
+	"array-contains-any": "ARRAY_CONTAINS_ANY",
+}
+
+// firestoreArrayOperators compare the field to the values of an array
+var firestoreArrayOperators = map[string]bool{"in": true, "not-in": true, "array-contains-any": true}
+
+// regexpFirestoreCollection matches the paths of the collections, the ID of a root collection or the path of a
+// subcollection of a document, e.g. users/alice/orders. They are put in the path of the requests
+var regexpFirestoreCollection = regexp.MustCompile(`^[^/.]+(/[^/.]+/[^/.]+)*$`)
+
+type firestoreScaler struct {
+	client     *http.Client
+	endpoint   string
+	metricType v2beta2.MetricTargetType
+	metadata   *firestoreMetadata
+}
+
+type firestoreMetadata struct {
+	projectID             string
+	database              string
+	collection            string
+	where                 *firestore.Filter
+	countUpTo             int64
+	targetValue           int64
+	activationTargetValue int64
+
+	gcpAuthorization *gcpAuthorizationMetadata
+	scalerIndex      int
+}
+
+func (m firestoreMetadata) String() string {
+	gcpAuthorization := ""
+	if m.gcpAuthorization != nil {
+		gcpAuthorization = m.gcpAuthorization.String()
+	}
+	where := ""
+	if m.where != nil {
+		filter, _ := json.Marshal(m.where)
+		where = string(filter)
+	}
+	return fmt.Sprintf("{projectID:%s database:%s collection:%s where:%s countUpTo:%d targetValue:%d activationTargetValue:%d gcpAuthorization:%s scalerIndex:%d}",
+		m.projectID, m.database, m.collection, where, m.countUpTo, m.targetValue, m.activationTargetValue, gcpAuthorization, m.scalerIndex)
+}
+
+// firestoreAggregationQueryRequest is the body of the runAggregationQuery requests, with a count of the documents
+// matching the structured query. The method is missing in the generated client of the API
+type firestoreAggregationQueryRequest struct {
+	StructuredAggregationQuery *firestoreStructuredAggregationQuery `json:"structuredAggregationQuery"`
+}
+
+type firestoreStructuredAggregationQuery struct {
+	StructuredQuery *firestore.StructuredQuery `json:"structuredQuery"`
+	Aggregations    []*firestoreAggregation    `json:"aggregations"`
+}
+
+type firestoreAggregation struct {
+	Alias string          `json:"alias"`
+	Count *firestoreCount `json:"count"`
+}
+
+type firestoreCount struct {
+	UpTo int64 `json:"upTo,string,omitempty"`
+}
+
+// firestoreAggregationQueryResponse is one of the responses streamed back by runAggregationQuery
+type firestoreAggregationQueryResponse struct {
+	Result *struct {
+		AggregateFields map[string]firestore.Value `json:"aggregateFields"`
+	} `json:"result"`
+}
+
+var gcpFirestoreLog = logf.Log.WithName("gcp_firestore_scaler")
+
+// NewFirestoreScaler creates a new firestoreScaler
+func NewFirestoreScaler(ctx context.Context, config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseFirestoreMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Firestore metadata: %s", err)
+	}
+
+	// the tokens are shared with the other GCP scalers using the same credentials
+	tokenSource, err := gcpTokenSources.get(ctx, meta.gcpAuthorization)
+	if err != nil {
+		return nil, err
+	}
+	options := append([]option.ClientOption{internaloption.WithDefaultEndpoint(firestoreEndpoint)}, meta.gcpAuthorization.clientOptions(tokenSource, gcpFirestoreAPI)...)
+	client, endpoint, err := htransport.NewClient(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the Firestore client: %s", err)
+	}
+
+	gcpFirestoreLog.Info(fmt.Sprintf("Metadata %s", meta))
+
+	return &firestoreScaler{
+		client:     client,
+		endpoint:   endpoint,
+		metricType: metricType,
+		metadata:   meta,
+	}, nil
+}
+
+func parseFirestoreMetadata(config *ScalerConfig) (*firestoreMetadata, error) {
+	meta := firestoreMetadata{}
+	meta.database = defaultFirestoreDatabase
+	meta.countUpTo = defaultFirestoreCountUpTo
+
+	if val, ok := config.TriggerMetadata["projectID"]; ok && val != "" {
+		meta.projectID = val
+	} else {
+		return nil, fmt.Errorf("no project ID given")
+	}
+
+	if val, ok := config.TriggerMetadata["database"]; ok && val != "" {
+		meta.database = val
+	}
+
+	if val, ok := config.TriggerMetadata["collection"]; ok && val != "" {
+		collection := strings.Trim(val, "/")
+		if !regexpFirestoreCollection.MatchString(collection) {
+			return nil, fmt.Errorf("collection %q must be the ID of a collection, or the path of a subcollection of a document", val)
+		}
+		meta.collection = collection
+	} else {
+		return nil, fmt.Errorf("no collection given")
+	}
+
+	if val, ok := config.TriggerMetadata["where"]; ok && val != "" {
+		where, err := parseFirestoreWhere(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing where: %s", err)
+		}
+		meta.where = where
+	}
+
+	if val, ok := config.TriggerMetadata["countUpTo"]; ok && val != "" {
+		countUpTo, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpFirestoreLog.Error(err, "Error parsing countUpTo")
+			return nil, fmt.Errorf("error parsing countUpTo: %s", err.Error())
+		}
+		if countUpTo <= 0 {
+			return nil, fmt.Errorf("countUpTo must be greater than 0")
+		}
+		meta.countUpTo = countUpTo
+	}
+
+	if val, ok := config.TriggerMetadata["targetValue"]; ok && val != "" {
+		targetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpFirestoreLog.Error(err, "Error parsing targetValue")
+			return nil, fmt.Errorf("error parsing targetValue: %s", err.Error())
+		}
+		if targetValue <= 0 {
+			return nil, fmt.Errorf("targetValue must be greater than 0")
+		}
+		meta.targetValue = targetValue
+	} else {
+		return nil, fmt.Errorf("no targetValue given")
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetValue"]; ok && val != "" {
+		activationTargetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpFirestoreLog.Error(err, "Error parsing activationTargetValue")
+			return nil, fmt.Errorf("error parsing activationTargetValue: %s", err.Error())
+		}
+		meta.activationTargetValue = activationTargetValue
+	}
+
+	auth, err := getGcpAuthorization(config, config.ResolvedEnv)
+	if err != nil {
+		return nil, err
+	}
+	meta.gcpAuthorization = auth
+	meta.scalerIndex = config.ScalerIndex
+	return &meta, nil
+}
+
+// parseFirestoreWhere parses the where clauses, a JSON array of [field, operator, value] triples the documents
+// match all of, e.g. [["status", "==", "pending"], ["attempts", "<", 3]]
+func parseFirestoreWhere(where string) (*firestore.Filter, error) {
+	decoder := json.NewDecoder(strings.NewReader(where))
+	decoder.UseNumber()
+	var clauses [][]interface{}
+	if err := decoder.Decode(&clauses); err != nil {
+		return nil, fmt.Errorf("must be a JSON array of [field, operator, value] triples: %s", err)
+	}
+	if len(clauses) == 0 {
+		return nil, fmt.Errorf("no clause given")
+	}
+
+	var filters []*firestore.Filter
+	for _, clause := range clauses {
+		filter, err := parseFirestoreWhereClause(clause)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return &firestore.Filter{CompositeFilter: &firestore.CompositeFilter{Op: "AND", Filters: filters}}, nil
+}
+
+func parseFirestoreWhereClause(clause []interface{}) (*firestore.Filter, error) {
+	if len(clause) != 3 {
+		return nil, fmt.Errorf("the clause %v must be a [field, operator, value] triple", clause)
+	}
+	field, ok := clause[0].(string)
+	if !ok || field == "" {
+		return nil, fmt.Errorf("the field of the clause %v must be a field path", clause)
+	}
+	operator, ok := clause[1].(string)
+	if !ok || firestoreOperators[operator] == "" {
+		return nil, fmt.Errorf("the operator of the clause %v must be one of <, <=, ==, !=, >=, >, array-contains, in, not-in, array-contains-any", clause)
+	}
+	fieldReference := &firestore.FieldReference{FieldPath: field}
+
+	// the comparisons to null are unary filters
+	if clause[2] == nil {
+		switch operator {
+		case "==":
+			return &firestore.Filter{UnaryFilter: &firestore.UnaryFilter{Field: fieldReference, Op: "IS_NULL"}}, nil
+		case "!=":
+			return &firestore.Filter{UnaryFilter: &firestore.UnaryFilter{Field: fieldReference, Op: "IS_NOT_NULL"}}, nil
+		default:
+			return nil, fmt.Errorf("null can only be compared with == or != in the clause %v", clause)
+		}
+	}
+
+	if _, isArray := clause[2].([]interface{}); isArray != firestoreArrayOperators[operator] {
+		if isArray {
+			return nil, fmt.Errorf("the value of the clause %v can't be an array", clause)
+		}
+		return nil, fmt.Errorf("the value of the clause %v must be an array", clause)
+	}
+	value, err := getFirestoreValue(clause[2])
+	if err != nil {
+		return nil, fmt.Errorf("the value of the clause %v %s", clause, err)
+	}
+	return &firestore.Filter{FieldFilter: &firestore.FieldFilter{Field: fieldReference, Op: firestoreOperators[operator], Value: value}}, nil
+}
+
+// getFirestoreValue returns the Firestore value of a decoded JSON value, the zero values are sent too
+func getFirestoreValue(value interface{}) (*firestore.Value, error) {
+	switch value := value.(type) {
+	case nil:
+		return &firestore.Value{NullValue: "NULL_VALUE"}, nil
+	case bool:
+		return &firestore.Value{BooleanValue: value, ForceSendFields: []string{"BooleanValue"}}, nil
+	case string:
+		return &firestore.Value{StringValue: value, ForceSendFields: []string{"StringValue"}}, nil
+	case json.Number:
+		if integer, err := value.Int64(); err == nil {
+			return &firestore.Value{IntegerValue: integer, ForceSendFields: []string{"IntegerValue"}}, nil
+		}
+		double, err := value.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("must be a number: %s", err)
+		}
+		return &firestore.Value{DoubleValue: double, ForceSendFields: []string{"DoubleValue"}}, nil
+	case []interface{}:
+		array := &firestore.ArrayValue{}
+		for _, element := range value {
+			if _, ok := element.([]interface{}); ok {
+				return nil, fmt.Errorf("can't hold nested arrays")
+			}
+			elementValue, err := getFirestoreValue(element)
+			if err != nil {
+				return nil, err
+			}
+			array.Values = append(array.Values, elementValue)
+		}
+		return &firestore.Value{ArrayValue: array}, nil
+	default:
+		return nil, fmt.Errorf("must be a string, a number, a boolean, null or an array of them")
+	}
+}
+
+// IsActive checks if the count of the matching documents is above the activation target value
+func (s *firestoreScaler) IsActive(ctx context.Context) (bool, error) {
+	count, err := s.getCount(ctx)
+	if err != nil {
+		gcpFirestoreLog.Error(err, "error getting Active Status")
+		return false, err
+	}
+	return count > s.metadata.activationTargetValue, nil
+}
+
+func (s *firestoreScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *firestoreScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("gcp-firestore-%s", s.metadata.collection))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+
+	// Create the metric spec for the HPA
+	metricSpec := v2beta2.MetricSpec{
+		External: externalMetric,
+		Type:     externalMetricType,
+	}
+
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the count of the documents matching the where clauses
+func (s *firestoreScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	count, err := s.getCount(ctx)
+	if err != nil {
+		gcpFirestoreLog.Error(err, "error getting document count")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(count, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getCount counts the documents of the collection matching the where clauses with an aggregation query, which reads
+// the index entries rather than the documents, up to countUpTo
+func (s *firestoreScaler) getCount(ctx context.Context) (int64, error) {
+	// the documents of a subcollection are under the document of its parent
+	parent := "projects/" + s.metadata.projectID + "/databases/" + s.metadata.database + "/documents"
+	collectionID := s.metadata.collection
+	if i := strings.LastIndex(collectionID, "/"); i >= 0 {
+		parent += "/" + collectionID[:i]
+		collectionID = collectionID[i+1:]
+	}
+
+	body, err := json.Marshal(firestoreAggregationQueryRequest{StructuredAggregationQuery: &firestoreStructuredAggregationQuery{
+		StructuredQuery: &firestore.StructuredQuery{
+			From:  []*firestore.CollectionSelector{{CollectionId: collectionID}},
+			Where: s.metadata.where,
+		},
+		Aggregations: []*firestoreAggregation{{Alias: firestoreCountAlias, Count: &firestoreCount{UpTo: s.metadata.countUpTo}}},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	requestURL := s.endpoint + "v1/" + (&url.URL{Path: parent}).EscapedPath() + ":runAggregationQuery"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error running the Firestore count query: %s", err)
+	}
+	defer resp.Body.Close()
+	// the rejected queries, e.g. missing an index, fail with the message of the API
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return 0, fmt.Errorf("error running the Firestore count query: %s", err)
+	}
+
+	var results []firestoreAggregationQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return 0, fmt.Errorf("error decoding the Firestore count query results: %s", err)
+	}
+	// no document matches without a result
+	var count int64
+	for _, result := range results {
+		if result.Result != nil {
+			if value, ok := result.Result.AggregateFields[firestoreCountAlias]; ok {
+				count = value.IntegerValue
+			}
+		}
+	}
+
+	if count >= s.metadata.countUpTo {
+		gcpFirestoreLog.V(1).Info("Reached countUpTo, the count of the documents may be too low",
+			"collection", s.metadata.collection, "countUpTo", s.metadata.countUpTo)
+	}
+	return count, nil
+}
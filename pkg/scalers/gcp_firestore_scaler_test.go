@@ -0,0 +1,240 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testFirestoreResolvedEnv = map[string]string{
+	"SAMPLE_CREDS": "{}",
+}
+
+type parseFirestoreMetadataTestData struct {
+	authParams map[string]string
+	metadata   map[string]string
+	isError    bool
+}
+
+type gcpFirestoreMetricIdentifier struct {
+	metadataTestData *parseFirestoreMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testFirestoreMetadata = []parseFirestoreMetadataTestData{
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{nil, map[string]string{"projectID": "myproject", "collection": "tasks", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// with where, database, countUpTo and activationTargetValue
+	{nil, map[string]string{"projectID": "myproject", "database": "jobs", "collection": "tasks", "where": `[["status", "==", "pending"], ["attempts", "<", 3]]`,
+		"countUpTo": "500", "targetValue": "10", "activationTargetValue": "2", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// subcollection
+	{nil, map[string]string{"projectID": "myproject", "collection": "users/alice/tasks", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// missing projectID
+	{nil, map[string]string{"collection": "tasks", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing collection
+	{nil, map[string]string{"projectID": "myproject", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// path of a document
+	{nil, map[string]string{"projectID": "myproject", "collection": "users/alice", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// collection with dots
+	{nil, map[string]string{"projectID": "myproject", "collection": "../tasks", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed where
+	{nil, map[string]string{"projectID": "myproject", "collection": "tasks", "where": `status == "pending"`, "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// unknown operator
+	{nil, map[string]string{"projectID": "myproject", "collection": "tasks", "where": `[["status", "=", "pending"]]`, "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed countUpTo
+	{nil, map[string]string{"projectID": "myproject", "collection": "tasks", "countUpTo": "AA", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero countUpTo
+	{nil, map[string]string{"projectID": "myproject", "collection": "tasks", "countUpTo": "0", "targetValue": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing targetValue
+	{nil, map[string]string{"projectID": "myproject", "collection": "tasks", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero targetValue
+	{nil, map[string]string{"projectID": "myproject", "collection": "tasks", "targetValue": "0", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed activationTargetValue
+	{nil, map[string]string{"projectID": "myproject", "collection": "tasks", "targetValue": "10", "activationTargetValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing credentials
+	{nil, map[string]string{"projectID": "myproject", "collection": "tasks", "targetValue": "10", "credentialsFromEnv": ""}, true},
+	// Credentials from AuthParams
+	{map[string]string{"GoogleApplicationCredentials": "Creds"}, map[string]string{"projectID": "myproject", "collection": "tasks", "targetValue": "10"}, false},
+}
+
+var gcpFirestoreMetricIdentifiers = []gcpFirestoreMetricIdentifier{
+	{&testFirestoreMetadata[1], 0, "s0-gcp-firestore-tasks"},
+	{&testFirestoreMetadata[1], 1, "s1-gcp-firestore-tasks"},
+	{&testFirestoreMetadata[3], 0, "s0-gcp-firestore-users-alice-tasks"},
+}
+
+func TestFirestoreParseMetadata(t *testing.T) {
+	for _, testData := range testFirestoreMetadata {
+		_, err := parseFirestoreMetadata(&ScalerConfig{AuthParams: testData.authParams, TriggerMetadata: testData.metadata, ResolvedEnv: testFirestoreResolvedEnv})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("Expected error but got success for %v", testData.metadata)
+		}
+	}
+}
+
+func TestFirestoreParseMetadataDefaults(t *testing.T) {
+	meta, err := parseFirestoreMetadata(&ScalerConfig{TriggerMetadata: testFirestoreMetadata[1].metadata, ResolvedEnv: testFirestoreResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Equal(t, defaultFirestoreDatabase, meta.database)
+	assert.Equal(t, int64(defaultFirestoreCountUpTo), meta.countUpTo)
+	assert.Equal(t, int64(0), meta.activationTargetValue)
+	assert.Nil(t, meta.where)
+}
+
+func TestFirestoreParseWhere(t *testing.T) {
+	for _, testData := range []struct {
+		where    string
+		expected string
+		isError  bool
+	}{
+		{`[["status", "==", "pending"]]`, `{"fieldFilter":{"field":{"fieldPath":"status"},"op":"EQUAL","value":{"stringValue":"pending"}}}`, false},
+		// the zero values are sent
+		{`[["attempts", ">=", 0]]`, `{"fieldFilter":{"field":{"fieldPath":"attempts"},"op":"GREATER_THAN_OR_EQUAL","value":{"integerValue":"0"}}}`, false},
+		{`[["done", "==", false]]`, `{"fieldFilter":{"field":{"fieldPath":"done"},"op":"EQUAL","value":{"booleanValue":false}}}`, false},
+		{`[["score", "<", 0.5]]`, `{"fieldFilter":{"field":{"fieldPath":"score"},"op":"LESS_THAN","value":{"doubleValue":0.5}}}`, false},
+		{`[["status", "in", ["pending", "retrying"]]]`, `{"fieldFilter":{"field":{"fieldPath":"status"},"op":"IN","value":{"arrayValue":{"values":[{"stringValue":"pending"},{"stringValue":"retrying"}]}}}}`, false},
+		{`[["owner", "==", null]]`, `{"unaryFilter":{"field":{"fieldPath":"owner"},"op":"IS_NULL"}}`, false},
+		{`[["owner", "!=", null]]`, `{"unaryFilter":{"field":{"fieldPath":"owner"},"op":"IS_NOT_NULL"}}`, false},
+		{`[["status", "==", "pending"], ["attempts", "<", 3]]`, `{"compositeFilter":{"filters":[` +
+			`{"fieldFilter":{"field":{"fieldPath":"status"},"op":"EQUAL","value":{"stringValue":"pending"}}},` +
+			`{"fieldFilter":{"field":{"fieldPath":"attempts"},"op":"LESS_THAN","value":{"integerValue":"3"}}}],"op":"AND"}}`, false},
+		{`[]`, "", true},
+		{`[["status", "=="]]`, "", true},
+		{`[["", "==", "pending"]]`, "", true},
+		{`[["status", "in", "pending"]]`, "", true},
+		{`[["status", "==", ["pending"]]]`, "", true},
+		{`[["status", "in", [["pending"]]]]`, "", true},
+		{`[["owner", "<", null]]`, "", true},
+		{`[["status", "==", {"value": "pending"}]]`, "", true},
+	} {
+		filter, err := parseFirestoreWhere(testData.where)
+		if testData.isError {
+			assert.Error(t, err, testData.where)
+			continue
+		}
+		if !assert.NoError(t, err, testData.where) {
+			continue
+		}
+		encoded, err := json.Marshal(filter)
+		assert.NoError(t, err)
+		assert.JSONEq(t, testData.expected, string(encoded), testData.where)
+	}
+}
+
+func TestGcpFirestoreGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range gcpFirestoreMetricIdentifiers {
+		meta, err := parseFirestoreMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, ResolvedEnv: testFirestoreResolvedEnv, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockGcpFirestoreScaler := firestoreScaler{metadata: meta}
+
+		metricSpec := mockGcpFirestoreScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+// newFakeFirestoreServer returns a fake Firestore API answering the aggregation queries with the response, and
+// recording their paths and bodies
+func newFakeFirestoreServer(t *testing.T, status int, response string, requests chan<- *http.Request, bodies chan<- firestoreAggregationQueryRequest) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, ":runAggregationQuery") {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var body firestoreAggregationQueryRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error("Could not decode the aggregation query:", err)
+		}
+		requests <- r
+		bodies <- body
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGcpFirestoreGetMetrics(t *testing.T) {
+	for _, testData := range []struct {
+		name       string
+		metadata   map[string]string
+		status     int
+		response   string
+		path       string
+		collection string
+		value      int64
+		isActive   bool
+		isError    string
+	}{
+		{"count", testFirestoreMetadata[2].metadata, http.StatusOK,
+			`[{"result": {"aggregateFields": {"count": {"integerValue": "12"}}}, "readTime": "2022-05-01T00:00:00Z"}]`,
+			"/v1/projects/myproject/databases/jobs/documents:runAggregationQuery", "tasks", 12, true, ""},
+		{"below activation", testFirestoreMetadata[2].metadata, http.StatusOK, `[{"result": {"aggregateFields": {"count": {"integerValue": "2"}}}}]`,
+			"/v1/projects/myproject/databases/jobs/documents:runAggregationQuery", "tasks", 2, false, ""},
+		{"subcollection", testFirestoreMetadata[3].metadata, http.StatusOK, `[{"result": {"aggregateFields": {"count": {"integerValue": "1"}}}}]`,
+			"/v1/projects/myproject/databases/(default)/documents/users/alice:runAggregationQuery", "tasks", 1, true, ""},
+		{"empty result", testFirestoreMetadata[1].metadata, http.StatusOK, `[{"readTime": "2022-05-01T00:00:00Z"}]`,
+			"/v1/projects/myproject/databases/(default)/documents:runAggregationQuery", "tasks", 0, false, ""},
+		{"rejected query", testFirestoreMetadata[2].metadata, http.StatusBadRequest,
+			`{"error": {"code": 400, "message": "The query requires an index.", "status": "FAILED_PRECONDITION"}}`,
+			"/v1/projects/myproject/databases/jobs/documents:runAggregationQuery", "tasks", 0, false, "The query requires an index."},
+	} {
+		requests := make(chan *http.Request, 2)
+		bodies := make(chan firestoreAggregationQueryRequest, 2)
+		server := newFakeFirestoreServer(t, testData.status, testData.response, requests, bodies)
+
+		// the emulator is reached without credentials
+		metadata := map[string]string{"apiEndpoint": server.URL}
+		for key, value := range testData.metadata {
+			metadata[key] = value
+		}
+		s, err := NewFirestoreScaler(context.Background(), &ScalerConfig{TriggerMetadata: metadata, ResolvedEnv: map[string]string{"SAMPLE_CREDS": testStackDriverCredentials}})
+		if err != nil {
+			t.Fatal("Could not create the scaler:", err)
+		}
+
+		metrics, err := s.GetMetrics(context.Background(), "s0-gcp-firestore-tasks", nil)
+		if testData.isError != "" {
+			if assert.Error(t, err, testData.name) {
+				assert.Contains(t, err.Error(), testData.isError, testData.name)
+			}
+		} else if assert.NoError(t, err, testData.name) {
+			assert.Equal(t, testData.value, metrics[0].Value.Value(), testData.name)
+		}
+
+		isActive, err := s.IsActive(context.Background())
+		if testData.isError != "" {
+			assert.Error(t, err, testData.name)
+		} else {
+			assert.NoError(t, err, testData.name)
+		}
+		assert.Equal(t, testData.isActive, isActive, testData.name)
+
+		req := <-requests
+		assert.Equal(t, testData.path, req.URL.Path, testData.name)
+		body := <-bodies
+		query := body.StructuredAggregationQuery
+		assert.Equal(t, testData.collection, query.StructuredQuery.From[0].CollectionId, testData.name)
+		assert.Equal(t, s.(*firestoreScaler).metadata.countUpTo, query.Aggregations[0].Count.UpTo, testData.name)
+		assert.Equal(t, s.(*firestoreScaler).metadata.where != nil, query.StructuredQuery.Where != nil, testData.name)
+		assert.NoError(t, s.Close(context.Background()))
+	}
+}
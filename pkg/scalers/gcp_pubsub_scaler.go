@@ -2,12 +2,16 @@ package scalers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
 
+	"cloud.google.com/go/compute/metadata"
+	pubsub "google.golang.org/api/pubsub/v1"
 	"k8s.io/api/autoscaling/v2beta2"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,21 +31,39 @@ const (
 
 	pubsubModeSubscriptionSize        = "SubscriptionSize"
 	pubsubModeOldestUnackedMessageAge = "OldestUnackedMessageAge"
+
+	// pubsubPullMaxMessages is the largest backlog counted without Cloud Monitoring, the most messages of a pull
+	pubsubPullMaxMessages = 1000
 )
 
-var regexpCompositeSubscriptionIDPrefix = regexp.MustCompile(compositeSubscriptionIDPrefix)
+var regexpCompositeSubscriptionIDPrefix = regexp.MustCompile("^" + compositeSubscriptionIDPrefix + "$")
 
 type pubsubScaler struct {
 	client     *StackDriverClient
 	metricType v2beta2.MetricTargetType
 	metadata   *pubsubMetadata
+
+	// subscriber pulls the messages of the subscription without Cloud Monitoring, from subscriptionPath
+	subscriber       *pubsub.Service
+	subscriptionPath string
 }
 
 type pubsubMetadata struct {
-	mode  string
-	value int64
-
+	mode string
+	// value and activationValue are numbers of messages in the SubscriptionSize mode, and seconds in the
+	// OldestUnackedMessageAge one
+	value           int64
+	activationValue int64
+	// useMonitoring reads the size of the subscription in Cloud Monitoring, which publishes it with a delay of 2 to
+	// 3 minutes. Otherwise the messages are pulled, and nacked at once, from the subscription: the backlog is up to
+	// date but only estimated, a pull returns some of the messages only and at most pubsubPullMaxMessages, and the
+	// delivery attempts of the messages grow, which counts towards the dead lettering of the subscription
+	useMonitoring bool
+
+	// subscriptionName is the ID of the subscription, or its full resource name projects/<project>/subscriptions/<id>
+	// with its project. projectID is the project of an ID, the one of the credentials otherwise
 	subscriptionName string
+	projectID        string
 	gcpAuthorization *gcpAuthorizationMetadata
 	scalerIndex      int
 }
@@ -49,7 +71,7 @@ type pubsubMetadata struct {
 var gcpPubSubLog = logf.Log.WithName("gcp_pub_sub_scaler")
 
 // NewPubSubScaler creates a new pubsubScaler
-func NewPubSubScaler(config *ScalerConfig) (Scaler, error) {
+func NewPubSubScaler(ctx context.Context, config *ScalerConfig) (Scaler, error) {
 	metricType, err := GetMetricTargetType(config)
 	if err != nil {
 		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
@@ -60,10 +82,23 @@ func NewPubSubScaler(config *ScalerConfig) (Scaler, error) {
 		return nil, fmt.Errorf("error parsing PubSub metadata: %s", err)
 	}
 
-	return &pubsubScaler{
+	s := &pubsubScaler{
 		metricType: metricType,
 		metadata:   meta,
-	}, nil
+	}
+	if !meta.useMonitoring {
+		if err := s.setSubscriber(ctx); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	// the client is otherwise created on the first poll, the errors of the impersonation are reported at once
+	if meta.gcpAuthorization.targetServiceAccount != "" {
+		if err := s.setStackdriverClient(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
 func parsePubSubMetadata(config *ScalerConfig) (*pubsubMetadata, error) {
@@ -107,6 +142,33 @@ func parsePubSubMetadata(config *ScalerConfig) (*pubsubMetadata, error) {
 		}
 	}
 
+	if meta.value <= 0 {
+		return nil, fmt.Errorf("value must be positive")
+	}
+
+	if val, ok := config.TriggerMetadata["activationValue"]; ok && val != "" {
+		activationValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("activationValue parsing error %s", err.Error())
+		}
+		if activationValue < 0 {
+			return nil, fmt.Errorf("activationValue must not be negative")
+		}
+		meta.activationValue = activationValue
+	}
+
+	meta.useMonitoring = true
+	if val, ok := config.TriggerMetadata["useMonitoring"]; ok && val != "" {
+		useMonitoring, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("useMonitoring parsing error %s", err.Error())
+		}
+		if !useMonitoring && meta.mode != pubsubModeSubscriptionSize {
+			return nil, fmt.Errorf("useMonitoring can only be false in the %s mode", pubsubModeSubscriptionSize)
+		}
+		meta.useMonitoring = useMonitoring
+	}
+
 	if val, ok := config.TriggerMetadata["subscriptionName"]; ok {
 		if val == "" {
 			return nil, fmt.Errorf("no subscription name given")
@@ -117,6 +179,15 @@ func parsePubSubMetadata(config *ScalerConfig) (*pubsubMetadata, error) {
 		return nil, fmt.Errorf("no subscription name given")
 	}
 
+	if val, ok := config.TriggerMetadata["projectID"]; ok && val != "" {
+		if regexpCompositeSubscriptionIDPrefix.MatchString(meta.subscriptionName) {
+			if project := strings.Split(meta.subscriptionName, "/")[1]; project != val {
+				return nil, fmt.Errorf("projectID %s isn't the project %s of the subscription %s", val, project, meta.subscriptionName)
+			}
+		}
+		meta.projectID = val
+	}
+
 	auth, err := getGcpAuthorization(config, config.ResolvedEnv)
 	if err != nil {
 		return nil, err
@@ -126,7 +197,8 @@ func parsePubSubMetadata(config *ScalerConfig) (*pubsubMetadata, error) {
 	return &meta, nil
 }
 
-// IsActive checks if there are any messages in the subscription
+// IsActive checks if there are more messages in the subscription, or if its oldest unacked message is older, than
+// the activation value
 func (s *pubsubScaler) IsActive(ctx context.Context) (bool, error) {
 	switch s.metadata.mode {
 	case pubsubModeSubscriptionSize:
@@ -135,14 +207,14 @@ func (s *pubsubScaler) IsActive(ctx context.Context) (bool, error) {
 			gcpPubSubLog.Error(err, "error getting Active Status")
 			return false, err
 		}
-		return size > 0, nil
+		return size > s.metadata.activationValue, nil
 	case pubsubModeOldestUnackedMessageAge:
-		_, err := s.getMetrics(ctx, pubSubStackDriverOldestUnackedMessageAgeMetricName)
+		age, err := s.getMetrics(ctx, pubSubStackDriverOldestUnackedMessageAgeMetricName)
 		if err != nil {
 			gcpPubSubLog.Error(err, "error getting Active Status")
 			return false, err
 		}
-		return true, nil
+		return age > s.metadata.activationValue, nil
 	default:
 		return false, errors.New("unknown mode")
 	}
@@ -150,7 +222,7 @@ func (s *pubsubScaler) IsActive(ctx context.Context) (bool, error) {
 
 func (s *pubsubScaler) Close(context.Context) error {
 	if s.client != nil {
-		err := s.client.metricsClient.Close()
+		err := stackDriverClients.release(s.client)
 		s.client = nil
 		if err != nil {
 			gcpPubSubLog.Error(err, "error closing StackDriver client")
@@ -160,11 +232,16 @@ func (s *pubsubScaler) Close(context.Context) error {
 	return nil
 }
 
-// GetMetricSpecForScaling returns the metric spec for the HPA
+// GetMetricSpecForScaling returns the metric spec for the HPA, the name of the metric includes the project of the
+// subscription when it's given, as the subscriptions of different projects may have the same ID
 func (s *pubsubScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	subscriptionName := s.metadata.subscriptionName
+	if subscriptionID, projectID := getSubscriptionData(s); projectID != "" {
+		subscriptionName = "projects/" + projectID + "/subscriptions/" + subscriptionID
+	}
 	externalMetric := &v2beta2.ExternalMetricSource{
 		Metric: v2beta2.MetricIdentifier{
-			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("gcp-ps-%s", s.metadata.subscriptionName))),
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("gcp-ps-%s", subscriptionName))),
 		},
 		Target: GetMetricTarget(s.metricType, s.metadata.value),
 	}
@@ -207,15 +284,94 @@ func (s *pubsubScaler) GetMetrics(ctx context.Context, metricName string, metric
 	return append([]external_metrics.ExternalMetricValue{}, metric), nil
 }
 
-func (s *pubsubScaler) setStackdriverClient(ctx context.Context) error {
-	var client *StackDriverClient
-	var err error
-	if s.metadata.gcpAuthorization.podIdentityProviderEnabled {
-		client, err = NewStackDriverClientPodIdentity(ctx)
-	} else {
-		client, err = NewStackDriverClient(ctx, s.metadata.gcpAuthorization.GoogleApplicationCredentials)
+// setSubscriber creates the Pub/Sub client pulling the messages of the subscription, with the token source shared
+// with the other GCP scalers using the same credentials
+func (s *pubsubScaler) setSubscriber(ctx context.Context) error {
+	subscriptionID, projectID := getSubscriptionData(s)
+	if projectID == "" {
+		var err error
+		projectID, err = getPubSubProjectID(s.metadata.gcpAuthorization)
+		if err != nil {
+			return fmt.Errorf("error getting the project of the subscription %s: %s", subscriptionID, err)
+		}
 	}
 
+	tokenSource, err := gcpTokenSources.get(ctx, s.metadata.gcpAuthorization)
+	if err != nil {
+		return err
+	}
+	subscriber, err := pubsub.NewService(ctx, s.metadata.gcpAuthorization.clientOptions(tokenSource, gcpPubSubAPI)...)
+	if err != nil {
+		return fmt.Errorf("error creating the Pub/Sub client: %s", err)
+	}
+	s.subscriber = subscriber
+	s.subscriptionPath = "projects/" + projectID + "/subscriptions/" + subscriptionID
+	return nil
+}
+
+// getPubSubProjectID returns the project of a subscription given by its ID, the one of the credentials, as in Cloud
+// Monitoring
+func getPubSubProjectID(gcpAuthorization *gcpAuthorizationMetadata) (string, error) {
+	if !gcpAuthorization.podIdentityProviderEnabled && gcpAuthorization.GoogleApplicationCredentials != "" {
+		var gcpCredentials GoogleApplicationCredentials
+		if err := json.Unmarshal([]byte(gcpAuthorization.GoogleApplicationCredentials), &gcpCredentials); err != nil {
+			return "", err
+		}
+		if gcpCredentials.ProjectID != "" {
+			return gcpCredentials.ProjectID, nil
+		}
+	}
+	if !gcpAuthorization.podIdentityProviderEnabled && gcpAuthorization.GoogleApplicationCredentialsFile != "" {
+		project, err := getGcpCredentialsFileProject(gcpAuthorization.GoogleApplicationCredentialsFile)
+		if err != nil {
+			return "", err
+		}
+		if project != "" {
+			return project, nil
+		}
+	}
+	if project := gcpServiceAccountProject(gcpAuthorization.targetServiceAccount); project != "" {
+		return project, nil
+	}
+	if project := getGcpExternalAccountQuotaProject(gcpAuthorization.credentialsConfig); project != "" {
+		return project, nil
+	}
+	return metadata.NewClient(&http.Client{}).ProjectID()
+}
+
+// getPulledBacklog estimates the size of the subscription with the messages a pull returns at once. They are nacked
+// right away, to be redelivered to the consumers
+func (s *pubsubScaler) getPulledBacklog(ctx context.Context) (int64, error) {
+	resp, err := s.subscriber.Projects.Subscriptions.Pull(s.subscriptionPath, &pubsub.PullRequest{
+		MaxMessages:       pubsubPullMaxMessages,
+		ReturnImmediately: true,
+	}).Context(ctx).Do()
+	if err != nil {
+		return -1, fmt.Errorf("error pulling the subscription %s: %s", s.subscriptionPath, err)
+	}
+	if len(resp.ReceivedMessages) == 0 {
+		return 0, nil
+	}
+
+	ackIDs := make([]string, 0, len(resp.ReceivedMessages))
+	for _, message := range resp.ReceivedMessages {
+		ackIDs = append(ackIDs, message.AckId)
+	}
+	_, err = s.subscriber.Projects.Subscriptions.ModifyAckDeadline(s.subscriptionPath, &pubsub.ModifyAckDeadlineRequest{
+		AckIds:             ackIDs,
+		AckDeadlineSeconds: 0,
+		// a deadline of 0 nacks the messages, it must be sent
+		ForceSendFields: []string{"AckDeadlineSeconds"},
+	}).Context(ctx).Do()
+	if err != nil {
+		// the messages are redelivered once their ack deadline expires anyway
+		gcpPubSubLog.Error(err, "error nacking the pulled messages", "subscription", s.subscriptionPath)
+	}
+	return int64(len(resp.ReceivedMessages)), nil
+}
+
+func (s *pubsubScaler) setStackdriverClient(ctx context.Context) error {
+	client, err := stackDriverClients.acquire(ctx, s.metadata.gcpAuthorization)
 	if err != nil {
 		return err
 	}
@@ -225,6 +381,9 @@ func (s *pubsubScaler) setStackdriverClient(ctx context.Context) error {
 
 // getMetrics gets metric type value from stackdriver api
 func (s *pubsubScaler) getMetrics(ctx context.Context, metricType string) (int64, error) {
+	if s.subscriber != nil {
+		return s.getPulledBacklog(ctx)
+	}
 	if s.client == nil {
 		err := s.setStackdriverClient(ctx)
 		if err != nil {
@@ -237,6 +396,8 @@ func (s *pubsubScaler) getMetrics(ctx context.Context, metricType string) (int64
 	return s.client.GetMetrics(ctx, filter, projectID)
 }
 
+// getSubscriptionData returns the ID and the project of the subscription, the project is empty if it's the one of the
+// credentials
 func getSubscriptionData(s *pubsubScaler) (string, string) {
 	var subscriptionID string
 	var projectID string
@@ -246,6 +407,7 @@ func getSubscriptionData(s *pubsubScaler) (string, string) {
 		projectID = strings.Split(s.metadata.subscriptionName, "/")[1]
 	} else {
 		subscriptionID = s.metadata.subscriptionName
+		projectID = s.metadata.projectID
 	}
 	return subscriptionID, projectID
 }
@@ -2,7 +2,23 @@ package scalers
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"github.com/stretchr/testify/assert"
+	option "google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/internal/testutil"
 )
 
 var testPubSubResolvedEnv = map[string]string{
@@ -52,16 +68,47 @@ var testPubSubMetadata = []parsePubSubMetadataTestData{
 	{nil, map[string]string{"subscriptionName": "projects/myproject/subscriptions/mysubscription", "subscriptionSize": "7", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
 	// with full (bad) link to subscription
 	{nil, map[string]string{"subscriptionName": "projects/myproject/mysubscription", "subscriptionSize": "7", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// with activationValue in seconds in the oldest unacked message age mode
+	{nil, map[string]string{"subscriptionName": "mysubscription", "mode": pubsubModeOldestUnackedMessageAge, "value": "60", "activationValue": "30", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// with activationValue and the deprecated field
+	{nil, map[string]string{"subscriptionName": "mysubscription", "subscriptionSize": "7", "activationValue": "2", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// deprecated field with mode
+	{nil, map[string]string{"subscriptionName": "mysubscription", "subscriptionSize": "7", "mode": pubsubModeSubscriptionSize, "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// deprecated field with value
+	{nil, map[string]string{"subscriptionName": "mysubscription", "subscriptionSize": "7", "value": "7", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed activationValue
+	{nil, map[string]string{"subscriptionName": "mysubscription", "value": "7", "activationValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// negative activationValue
+	{nil, map[string]string{"subscriptionName": "mysubscription", "value": "7", "activationValue": "-1", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero value
+	{nil, map[string]string{"subscriptionName": "mysubscription", "mode": pubsubModeOldestUnackedMessageAge, "value": "0", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// without Cloud Monitoring
+	{nil, map[string]string{"subscriptionName": "projects/myproject/subscriptions/mysubscription", "value": "7", "activationValue": "2", "useMonitoring": "false", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// malformed useMonitoring
+	{nil, map[string]string{"subscriptionName": "mysubscription", "value": "7", "useMonitoring": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// oldest unacked message age mode without Cloud Monitoring
+	{nil, map[string]string{"subscriptionName": "mysubscription", "mode": pubsubModeOldestUnackedMessageAge, "useMonitoring": "false", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// with projectID
+	{nil, map[string]string{"subscriptionName": "mysubscription", "projectID": "otherproject", "value": "7", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// with full link to subscription and its projectID
+	{nil, map[string]string{"subscriptionName": "projects/myproject/subscriptions/mysubscription", "projectID": "myproject", "value": "7", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// with full link to subscription and another projectID
+	{nil, map[string]string{"subscriptionName": "projects/myproject/subscriptions/mysubscription", "projectID": "otherproject", "value": "7", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
 }
 
 var gcpPubSubMetricIdentifiers = []gcpPubSubMetricIdentifier{
 	{&testPubSubMetadata[1], 0, "s0-gcp-ps-mysubscription"},
 	{&testPubSubMetadata[1], 1, "s1-gcp-ps-mysubscription"},
+	{&testPubSubMetadata[10], 0, "s0-gcp-ps-projects-myproject-subscriptions-mysubscription"},
+	{&testPubSubMetadata[22], 0, "s0-gcp-ps-projects-otherproject-subscriptions-mysubscription"},
+	{&testPubSubMetadata[23], 0, "s0-gcp-ps-projects-myproject-subscriptions-mysubscription"},
 }
 
 var gcpSubscriptionNameTests = []gcpPubSubSubscription{
 	{&testPubSubMetadata[10], 1, "mysubscription", "myproject"},
 	{&testPubSubMetadata[11], 1, "projects/myproject/mysubscription", ""},
+	{&testPubSubMetadata[22], 1, "mysubscription", "otherproject"},
+	{&testPubSubMetadata[23], 1, "mysubscription", "myproject"},
 }
 
 func TestPubSubParseMetadata(t *testing.T) {
@@ -82,7 +129,7 @@ func TestGcpPubSubGetMetricSpecForScaling(t *testing.T) {
 		if err != nil {
 			t.Fatal("Could not parse metadata:", err)
 		}
-		mockGcpPubSubScaler := pubsubScaler{nil, "", meta}
+		mockGcpPubSubScaler := pubsubScaler{metadata: meta}
 
 		metricSpec := mockGcpPubSubScaler.GetMetricSpecForScaling(context.Background())
 		metricName := metricSpec[0].External.Metric.Name
@@ -98,7 +145,7 @@ func TestGcpPubSubSubscriptionName(t *testing.T) {
 		if err != nil {
 			t.Fatal("Could not parse metadata:", err)
 		}
-		mockGcpPubSubScaler := pubsubScaler{nil, "", meta}
+		mockGcpPubSubScaler := pubsubScaler{metadata: meta}
 		subscriptionID, projectID := getSubscriptionData(&mockGcpPubSubScaler)
 
 		if subscriptionID != testData.name || projectID != testData.projectID {
@@ -106,3 +153,277 @@ func TestGcpPubSubSubscriptionName(t *testing.T) {
 		}
 	}
 }
+
+func TestPubSubParseMetadataModes(t *testing.T) {
+	for _, testData := range []struct {
+		metadata        *parsePubSubMetadataTestData
+		mode            string
+		value           int64
+		activationValue int64
+	}{
+		{&testPubSubMetadata[1], pubsubModeSubscriptionSize, 7, 0},
+		{&testPubSubMetadata[2], pubsubModeSubscriptionSize, 7, 0},
+		{&testPubSubMetadata[12], pubsubModeOldestUnackedMessageAge, 60, 30},
+		{&testPubSubMetadata[13], pubsubModeSubscriptionSize, 7, 2},
+		{&parsePubSubMetadataTestData{metadata: map[string]string{"subscriptionName": "mysubscription", "credentialsFromEnv": "SAMPLE_CREDS"}}, pubsubModeSubscriptionSize, defaultTargetSubscriptionSize, 0},
+		{&parsePubSubMetadataTestData{metadata: map[string]string{"subscriptionName": "mysubscription", "mode": pubsubModeOldestUnackedMessageAge, "credentialsFromEnv": "SAMPLE_CREDS"}}, pubsubModeOldestUnackedMessageAge, defaultTargetOldestUnackedMessageAge, 0},
+	} {
+		meta, err := parsePubSubMetadata(&ScalerConfig{TriggerMetadata: testData.metadata.metadata, ResolvedEnv: testPubSubResolvedEnv})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		assert.Equal(t, testData.mode, meta.mode)
+		assert.Equal(t, testData.value, meta.value)
+		assert.Equal(t, testData.activationValue, meta.activationValue)
+	}
+}
+
+func newPubSubSeries(metricType string, values ...int64) *monitoringpb.TimeSeries {
+	series := &monitoringpb.TimeSeries{Metric: &metricpb.Metric{Type: metricType}}
+	for _, value := range values {
+		series.Points = append(series.Points, &monitoringpb.Point{Value: &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: value}}})
+	}
+	return series
+}
+
+func TestGcpPubSubGetMetrics(t *testing.T) {
+	for _, testData := range []struct {
+		name       string
+		metadata   map[string]string
+		metricType string
+		value      int64
+		isActive   bool
+	}{
+		// the latest point comes first
+		{"size", testPubSubMetadata[13].metadata, pubSubStackDriverSubscriptionSizeMetricName, 3, true},
+		{"size at activation", testPubSubMetadata[13].metadata, pubSubStackDriverSubscriptionSizeMetricName, 2, false},
+		{"age", testPubSubMetadata[12].metadata, pubSubStackDriverOldestUnackedMessageAgeMetricName, 45, true},
+		{"age at activation", testPubSubMetadata[12].metadata, pubSubStackDriverOldestUnackedMessageAgeMetricName, 30, false},
+		{"age without activation", testPubSubMetadata[3].metadata, pubSubStackDriverOldestUnackedMessageAgeMetricName, 0, false},
+	} {
+		fake := &fakeMonitoringServer{series: []*monitoringpb.TimeSeries{newPubSubSeries(testData.metricType, testData.value, 1000)},
+			requests: make(chan *monitoringpb.ListTimeSeriesRequest, 2)}
+		server := testutil.NewFaultyGRPCServer(t, func(s *grpc.Server) {
+			monitoringpb.RegisterMetricServiceServer(s, fake)
+		}, testutil.Faults{})
+		metricsClient, err := monitoring.NewMetricClient(context.Background(), option.WithEndpoint(server.Addr), option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+		if err != nil {
+			t.Fatal("Could not create the client:", err)
+		}
+
+		meta, err := parsePubSubMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, ResolvedEnv: testPubSubResolvedEnv})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		s := pubsubScaler{client: &StackDriverClient{metricsClient: metricsClient}, metadata: meta}
+
+		metrics, err := s.GetMetrics(context.Background(), "s0-gcp-ps-mysubscription", nil)
+		if err != nil {
+			t.Fatalf("%s: could not get the metrics: %s", testData.name, err)
+		}
+		if metrics[0].Value.Value() != testData.value {
+			t.Errorf("%s: expected the value %d, got %d", testData.name, testData.value, metrics[0].Value.Value())
+		}
+
+		isActive, err := s.IsActive(context.Background())
+		if err != nil {
+			t.Fatalf("%s: could not get the active status: %s", testData.name, err)
+		}
+		if isActive != testData.isActive {
+			t.Errorf("%s: expected active %t, got %t", testData.name, testData.isActive, isActive)
+		}
+
+		for i := 0; i < 2; i++ {
+			req := <-fake.requests
+			if !strings.Contains(req.Filter, `metric.type="`+testData.metricType+`"`) || !strings.Contains(req.Filter, `resource.labels.subscription_id="mysubscription"`) {
+				t.Errorf("%s: expected the metric of the mode to be requested, got %s", testData.name, req.Filter)
+			}
+		}
+		metricsClient.Close()
+	}
+}
+
+func TestPubSubParseUseMonitoring(t *testing.T) {
+	meta, err := parsePubSubMetadata(&ScalerConfig{TriggerMetadata: testPubSubMetadata[2].metadata, ResolvedEnv: testPubSubResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.True(t, meta.useMonitoring)
+
+	meta, err = parsePubSubMetadata(&ScalerConfig{TriggerMetadata: testPubSubMetadata[19].metadata, ResolvedEnv: testPubSubResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.False(t, meta.useMonitoring)
+}
+
+func TestGetPubSubProjectID(t *testing.T) {
+	project, err := getPubSubProjectID(&gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverCredentials})
+	assert.NoError(t, err)
+	assert.Equal(t, "project", project)
+
+	project, err = getPubSubProjectID(&gcpAuthorizationMetadata{podIdentityProviderEnabled: true, targetServiceAccount: testGcpTargetServiceAccount})
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", project)
+}
+
+// fakePubSubServer returns the messages of the pulls and records the nacked ones
+type fakePubSubServer struct {
+	messages int
+	nacked   chan pubsub.ModifyAckDeadlineRequest
+}
+
+func newFakePubSubServer(t *testing.T, fake *fakePubSubServer) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/projects/myproject/subscriptions/mysubscription:pull":
+			var req pubsub.PullRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error("Could not decode the pull request:", err)
+			}
+			if !req.ReturnImmediately || req.MaxMessages != pubsubPullMaxMessages {
+				t.Errorf("Expected a pull returning at once, got %+v", req)
+			}
+			resp := pubsub.PullResponse{}
+			for i := 0; i < fake.messages; i++ {
+				resp.ReceivedMessages = append(resp.ReceivedMessages, &pubsub.ReceivedMessage{AckId: fmt.Sprintf("ack-%d", i)})
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/projects/myproject/subscriptions/mysubscription:modifyAckDeadline":
+			var req map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error("Could not decode the modifyAckDeadline request:", err)
+			}
+			if deadline, ok := req["ackDeadlineSeconds"]; !ok || deadline != float64(0) {
+				t.Errorf("Expected the messages to be nacked, got %v", req)
+			}
+			ackIDs := []string{}
+			for _, ackID := range req["ackIds"].([]interface{}) {
+				ackIDs = append(ackIDs, ackID.(string))
+			}
+			fake.nacked <- pubsub.ModifyAckDeadlineRequest{AckIds: ackIDs}
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newFakePubSubSubscriber(t *testing.T, fake *fakePubSubServer) *pubsub.Service {
+	server := newFakePubSubServer(t, fake)
+	subscriber, err := pubsub.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal("Could not create the client:", err)
+	}
+	return subscriber
+}
+
+func TestGcpPubSubGetMetricsWithoutMonitoring(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		messages int
+		isActive bool
+	}{
+		{"backlog", 3, true},
+		{"at activation", 2, false},
+		{"empty", 0, false},
+	} {
+		fake := &fakePubSubServer{messages: testData.messages, nacked: make(chan pubsub.ModifyAckDeadlineRequest, 2)}
+		meta, err := parsePubSubMetadata(&ScalerConfig{TriggerMetadata: testPubSubMetadata[19].metadata, ResolvedEnv: testPubSubResolvedEnv})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		s := pubsubScaler{metadata: meta, subscriber: newFakePubSubSubscriber(t, fake), subscriptionPath: "projects/myproject/subscriptions/mysubscription"}
+
+		metrics, err := s.GetMetrics(context.Background(), "s0-gcp-ps-mysubscription", nil)
+		if err != nil {
+			t.Fatalf("%s: could not get the metrics: %s", testData.name, err)
+		}
+		if metrics[0].Value.Value() != int64(testData.messages) {
+			t.Errorf("%s: expected the value %d, got %d", testData.name, testData.messages, metrics[0].Value.Value())
+		}
+
+		isActive, err := s.IsActive(context.Background())
+		if err != nil {
+			t.Fatalf("%s: could not get the active status: %s", testData.name, err)
+		}
+		if isActive != testData.isActive {
+			t.Errorf("%s: expected active %t, got %t", testData.name, testData.isActive, isActive)
+		}
+
+		// the pulled messages are nacked, there's nothing to nack in an empty subscription
+		close(fake.nacked)
+		nacked := 0
+		for req := range fake.nacked {
+			assert.Len(t, req.AckIds, testData.messages)
+			nacked++
+		}
+		if testData.messages > 0 {
+			assert.Equal(t, 2, nacked, testData.name)
+		} else {
+			assert.Equal(t, 0, nacked, testData.name)
+		}
+	}
+}
+
+func TestGcpPubSubAPIEndpoint(t *testing.T) {
+	fake := &fakePubSubServer{messages: 3, nacked: make(chan pubsub.ModifyAckDeadlineRequest, 1)}
+	server := newFakePubSubServer(t, fake)
+
+	// the emulator is reached without credentials
+	s, err := NewPubSubScaler(context.Background(), &ScalerConfig{
+		AuthParams:      map[string]string{"GoogleApplicationCredentials": `{"type": "service_account", "project_id": "myproject"}`},
+		TriggerMetadata: map[string]string{"subscriptionName": "mysubscription", "useMonitoring": "false", "apiEndpoint": server.URL},
+	})
+	if err != nil {
+		t.Fatal("Could not create the scaler:", err)
+	}
+	metrics, err := s.GetMetrics(context.Background(), "s0-gcp-ps-mysubscription", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), metrics[0].Value.Value())
+	assert.Len(t, (<-fake.nacked).AckIds, 3)
+	assert.NoError(t, s.Close(context.Background()))
+}
+
+func TestGcpPubSubSubscriptionProject(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		metadata map[string]string
+		project  string
+	}{
+		{"project of the credentials", map[string]string{"subscriptionName": "mysubscription"}, "projects/project"},
+		{"projectID", map[string]string{"subscriptionName": "mysubscription", "projectID": "otherproject"}, "projects/otherproject"},
+		{"full link to subscription", map[string]string{"subscriptionName": "projects/otherproject/subscriptions/mysubscription"}, "projects/otherproject"},
+	} {
+		fake := &fakeMonitoringServer{series: []*monitoringpb.TimeSeries{newPubSubSeries(pubSubStackDriverSubscriptionSizeMetricName, 3)},
+			requests: make(chan *monitoringpb.ListTimeSeriesRequest, 1)}
+		server := testutil.NewFaultyGRPCServer(t, func(s *grpc.Server) {
+			monitoringpb.RegisterMetricServiceServer(s, fake)
+		}, testutil.Faults{})
+
+		// the emulator is reached without credentials, their project is the default one of the subscriptions
+		metadata := map[string]string{"apiEndpoint": "http://" + server.Addr}
+		for key, value := range testData.metadata {
+			metadata[key] = value
+		}
+		s, err := NewPubSubScaler(context.Background(), &ScalerConfig{
+			AuthParams:      map[string]string{"GoogleApplicationCredentials": testStackDriverCredentials},
+			TriggerMetadata: metadata,
+		})
+		if err != nil {
+			t.Fatal("Could not create the scaler:", err)
+		}
+		metrics, err := s.GetMetrics(context.Background(), "s0-gcp-ps-mysubscription", nil)
+		if assert.NoError(t, err, testData.name) {
+			assert.Equal(t, int64(3), metrics[0].Value.Value(), testData.name)
+		}
+		req := <-fake.requests
+		assert.Equal(t, testData.project, req.Name, testData.name)
+		assert.Contains(t, req.Filter, `resource.labels.subscription_id="mysubscription"`, testData.name)
+		assert.NoError(t, s.Close(context.Background()))
+	}
+}
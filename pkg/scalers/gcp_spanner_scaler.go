@@ -0,0 +1,235 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	spannerMetricCPUUtilization = "cpu_utilization"
+	spannerMetricSessions       = "sessions"
+
+	// spannerMetricsLookback is the window of the latest point of the metrics of the instance, they are sampled
+	// every minute and published with a delay of up to a few minutes
+	spannerMetricsLookback = 5 * time.Minute
+)
+
+// spannerMetricTypes are the Cloud Monitoring metrics of the instances for each metricName, their time series, e.g.
+// of the databases and of the priorities of the CPU, add up to the load of the instance
+var spannerMetricTypes = map[string]string{
+	spannerMetricCPUUtilization: "spanner.googleapis.com/instance/cpu/utilization",
+	spannerMetricSessions:       "spanner.googleapis.com/api/sessions",
+}
+
+// spannerUtilizationMetrics are the metrics of a ratio of the provisioned capacity, their targets are percentages
+var spannerUtilizationMetrics = map[string]bool{
+	spannerMetricCPUUtilization: true,
+}
+
+// regexpSpannerInstanceID matches the IDs of the instances, they are quoted in the filter of the metrics
+var regexpSpannerInstanceID = regexp.MustCompile(`^[a-z][-a-z0-9]{0,62}[a-z0-9]$`)
+
+type spannerScaler struct {
+	client     *StackDriverClient
+	metricType v2beta2.MetricTargetType
+	metadata   *spannerMetadata
+}
+
+type spannerMetadata struct {
+	projectID  string
+	instanceID string
+	metricName string
+	// targetValue and activationTargetValue are percentages for the utilization metrics
+	targetValue           int64
+	activationTargetValue float64
+
+	gcpAuthorization *gcpAuthorizationMetadata
+	scalerIndex      int
+}
+
+func (m spannerMetadata) String() string {
+	gcpAuthorization := ""
+	if m.gcpAuthorization != nil {
+		gcpAuthorization = m.gcpAuthorization.String()
+	}
+	return fmt.Sprintf("{projectID:%s instanceID:%s metricName:%s targetValue:%d activationTargetValue:%g gcpAuthorization:%s scalerIndex:%d}",
+		m.projectID, m.instanceID, m.metricName, m.targetValue, m.activationTargetValue, gcpAuthorization, m.scalerIndex)
+}
+
+var gcpSpannerLog = logf.Log.WithName("gcp_spanner_scaler")
+
+// NewSpannerScaler creates a new spannerScaler
+func NewSpannerScaler(ctx context.Context, config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseSpannerMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Spanner metadata: %s", err)
+	}
+
+	client, err := stackDriverClients.acquire(ctx, meta.gcpAuthorization)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the Cloud Monitoring client: %s", err)
+	}
+
+	gcpSpannerLog.Info(fmt.Sprintf("Metadata %s", meta))
+
+	return &spannerScaler{
+		client:     client,
+		metricType: metricType,
+		metadata:   meta,
+	}, nil
+}
+
+func parseSpannerMetadata(config *ScalerConfig) (*spannerMetadata, error) {
+	meta := spannerMetadata{}
+	meta.metricName = spannerMetricCPUUtilization
+
+	if val, ok := config.TriggerMetadata["projectID"]; ok && val != "" {
+		meta.projectID = val
+	} else {
+		return nil, fmt.Errorf("no project ID given")
+	}
+
+	if val, ok := config.TriggerMetadata["instanceID"]; ok && val != "" {
+		if !regexpSpannerInstanceID.MatchString(val) {
+			return nil, fmt.Errorf("instanceID %q must be the ID of a Spanner instance", val)
+		}
+		meta.instanceID = val
+	} else {
+		return nil, fmt.Errorf("no instance ID given")
+	}
+
+	if val, ok := config.TriggerMetadata["metricName"]; ok && val != "" {
+		if _, ok := spannerMetricTypes[val]; !ok {
+			return nil, fmt.Errorf("metricName %s must be one of %s, %s", val, spannerMetricCPUUtilization, spannerMetricSessions)
+		}
+		meta.metricName = val
+	}
+
+	if val, ok := config.TriggerMetadata["targetValue"]; ok && val != "" {
+		targetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpSpannerLog.Error(err, "Error parsing targetValue")
+			return nil, fmt.Errorf("error parsing targetValue: %s", err.Error())
+		}
+		if targetValue <= 0 {
+			return nil, fmt.Errorf("targetValue must be greater than 0")
+		}
+		if spannerUtilizationMetrics[meta.metricName] && targetValue > 100 {
+			return nil, fmt.Errorf("targetValue of %s is a percentage, it must be at most 100", meta.metricName)
+		}
+		meta.targetValue = targetValue
+	} else {
+		return nil, fmt.Errorf("no targetValue given")
+	}
+
+	activationTargetValue, err := getFloatMetadataValue(config.TriggerMetadata, "activationTargetValue", false, 0)
+	if err != nil {
+		return nil, err
+	}
+	meta.activationTargetValue = activationTargetValue
+
+	auth, err := getGcpAuthorization(config, config.ResolvedEnv)
+	if err != nil {
+		return nil, err
+	}
+	meta.gcpAuthorization = auth
+	meta.scalerIndex = config.ScalerIndex
+	return &meta, nil
+}
+
+// IsActive checks if the metric of the instance is above the activation target value
+func (s *spannerScaler) IsActive(ctx context.Context) (bool, error) {
+	value, err := s.getMetric(ctx)
+	if err != nil {
+		gcpSpannerLog.Error(err, "error getting Active Status")
+		return false, err
+	}
+	return value > s.metadata.activationTargetValue, nil
+}
+
+func (s *spannerScaler) Close(context.Context) error {
+	if s.client != nil {
+		err := stackDriverClients.release(s.client)
+		s.client = nil
+		if err != nil {
+			gcpSpannerLog.Error(err, "error closing StackDriver client")
+		}
+	}
+
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *spannerScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("gcp-spanner-%s-%s", s.metadata.instanceID, s.metadata.metricName))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+
+	// Create the metric spec for the HPA
+	metricSpec := v2beta2.MetricSpec{
+		External: externalMetric,
+		Type:     externalMetricType,
+	}
+
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics connects to Stack Driver and finds the metric of the instance
+func (s *spannerScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	value, err := s.getMetric(ctx)
+	if err != nil {
+		gcpSpannerLog.Error(err, "error getting Spanner metric")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	// the value is in milli units, a utilization below 1% isn't rounded to 0
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getMetric reads the latest point of the metric of the instance in Cloud Monitoring, the utilizations are converted
+// from ratios to percentages as the targets
+func (s *spannerScaler) getMetric(ctx context.Context) (float64, error) {
+	filter := `metric.type="` + spannerMetricTypes[s.metadata.metricName] + `" AND resource.type="spanner_instance" AND resource.labels.instance_id="` + s.metadata.instanceID + `"`
+	aggregation := &monitoringpb.Aggregation{
+		AlignmentPeriod:    durationpb.New(time.Minute),
+		PerSeriesAligner:   monitoringpb.Aggregation_ALIGN_MEAN,
+		CrossSeriesReducer: monitoringpb.Aggregation_REDUCE_SUM,
+	}
+	value, err := s.client.GetMetricsWithAggregation(ctx, filter, s.metadata.projectID, aggregation, spannerMetricsLookback)
+	if err != nil {
+		return 0, err
+	}
+	if spannerUtilizationMetrics[s.metadata.metricName] {
+		value *= 100
+	}
+	return value, nil
+}
@@ -0,0 +1,164 @@
+package scalers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/grpc"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/internal/testutil"
+)
+
+var testSpannerResolvedEnv = map[string]string{
+	"SAMPLE_CREDS": "{}",
+}
+
+type parseSpannerMetadataTestData struct {
+	authParams map[string]string
+	metadata   map[string]string
+	isError    bool
+}
+
+type gcpSpannerMetricIdentifier struct {
+	metadataTestData *parseSpannerMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testSpannerMetadata = []parseSpannerMetadataTestData{
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{nil, map[string]string{"projectID": "myproject", "instanceID": "myinstance", "targetValue": "65", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// with metricName and activationTargetValue
+	{nil, map[string]string{"projectID": "myproject", "instanceID": "myinstance", "metricName": "sessions", "targetValue": "200", "activationTargetValue": "10",
+		"credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// cpu utilization with activationTargetValue below 1%
+	{nil, map[string]string{"projectID": "myproject", "instanceID": "myinstance", "metricName": "cpu_utilization", "targetValue": "65", "activationTargetValue": "0.5",
+		"credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// missing projectID
+	{nil, map[string]string{"instanceID": "myinstance", "targetValue": "65", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing instanceID
+	{nil, map[string]string{"projectID": "myproject", "targetValue": "65", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// instanceID with a quote
+	{nil, map[string]string{"projectID": "myproject", "instanceID": `myinstance" OR "`, "targetValue": "65", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// unknown metricName
+	{nil, map[string]string{"projectID": "myproject", "instanceID": "myinstance", "metricName": "storage", "targetValue": "65", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing targetValue
+	{nil, map[string]string{"projectID": "myproject", "instanceID": "myinstance", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed targetValue
+	{nil, map[string]string{"projectID": "myproject", "instanceID": "myinstance", "targetValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero targetValue
+	{nil, map[string]string{"projectID": "myproject", "instanceID": "myinstance", "targetValue": "0", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// targetValue of the cpu utilization above 100%
+	{nil, map[string]string{"projectID": "myproject", "instanceID": "myinstance", "targetValue": "150", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed activationTargetValue
+	{nil, map[string]string{"projectID": "myproject", "instanceID": "myinstance", "targetValue": "65", "activationTargetValue": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// missing credentials
+	{nil, map[string]string{"projectID": "myproject", "instanceID": "myinstance", "targetValue": "65", "credentialsFromEnv": ""}, true},
+	// Credentials from AuthParams
+	{map[string]string{"GoogleApplicationCredentials": "Creds"}, map[string]string{"projectID": "myproject", "instanceID": "myinstance", "targetValue": "65"}, false},
+}
+
+var gcpSpannerMetricIdentifiers = []gcpSpannerMetricIdentifier{
+	{&testSpannerMetadata[1], 0, "s0-gcp-spanner-myinstance-cpu_utilization"},
+	{&testSpannerMetadata[2], 1, "s1-gcp-spanner-myinstance-sessions"},
+}
+
+func TestSpannerParseMetadata(t *testing.T) {
+	for _, testData := range testSpannerMetadata {
+		_, err := parseSpannerMetadata(&ScalerConfig{AuthParams: testData.authParams, TriggerMetadata: testData.metadata, ResolvedEnv: testSpannerResolvedEnv})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("Expected error but got success for %v", testData.metadata)
+		}
+	}
+}
+
+func TestGcpSpannerGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range gcpSpannerMetricIdentifiers {
+		meta, err := parseSpannerMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, ResolvedEnv: testSpannerResolvedEnv, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockGcpSpannerScaler := spannerScaler{nil, "", meta}
+
+		metricSpec := mockGcpSpannerScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+func TestGcpSpannerGetMetrics(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		metadata map[string]string
+		series   []*monitoringpb.TimeSeries
+		filter   string
+		value    int64
+		isActive bool
+		isError  bool
+	}{
+		// the ratio is a percentage, as the target
+		{"cpu utilization", testSpannerMetadata[1].metadata, []*monitoringpb.TimeSeries{{Points: []*monitoringpb.Point{{Value: newStackdriverDoubleValue(0.65)}}}},
+			`metric.type="spanner.googleapis.com/instance/cpu/utilization" AND resource.type="spanner_instance" AND resource.labels.instance_id="myinstance"`,
+			65000, true, false},
+		// below 1%, the percentage isn't truncated to 0
+		{"cpu utilization below activation", testSpannerMetadata[3].metadata, []*monitoringpb.TimeSeries{{Points: []*monitoringpb.Point{{Value: newStackdriverDoubleValue(0.0042)}}}},
+			`metric.type="spanner.googleapis.com/instance/cpu/utilization" AND resource.type="spanner_instance" AND resource.labels.instance_id="myinstance"`,
+			420, false, false},
+		{"sessions", testSpannerMetadata[2].metadata, []*monitoringpb.TimeSeries{{Points: []*monitoringpb.Point{{Value: newStackdriverDoubleValue(123)}}}},
+			`metric.type="spanner.googleapis.com/api/sessions" AND resource.type="spanner_instance" AND resource.labels.instance_id="myinstance"`,
+			123000, true, false},
+		{"no point", testSpannerMetadata[1].metadata, nil,
+			`metric.type="spanner.googleapis.com/instance/cpu/utilization" AND resource.type="spanner_instance" AND resource.labels.instance_id="myinstance"`,
+			0, false, true},
+	} {
+		fake := &fakeMonitoringServer{series: testData.series, requests: make(chan *monitoringpb.ListTimeSeriesRequest, 2)}
+		server := testutil.NewFaultyGRPCServer(t, func(s *grpc.Server) {
+			monitoringpb.RegisterMetricServiceServer(s, fake)
+		}, testutil.Faults{})
+
+		// the emulator is reached without credentials
+		metadata := map[string]string{"apiEndpoint": "http://" + server.Addr}
+		for key, value := range testData.metadata {
+			metadata[key] = value
+		}
+		s, err := NewSpannerScaler(context.Background(), &ScalerConfig{TriggerMetadata: metadata, ResolvedEnv: map[string]string{"SAMPLE_CREDS": testStackDriverCredentials}})
+		if err != nil {
+			t.Fatal("Could not create the scaler:", err)
+		}
+
+		metrics, err := s.GetMetrics(context.Background(), "s0-gcp-spanner-myinstance", nil)
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if err == nil && metrics[0].Value.MilliValue() != testData.value {
+			t.Errorf("%s: expected the value %dm, got %dm", testData.name, testData.value, metrics[0].Value.MilliValue())
+		}
+
+		isActive, err := s.IsActive(context.Background())
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if isActive != testData.isActive {
+			t.Errorf("%s: expected active %t, got %t", testData.name, testData.isActive, isActive)
+		}
+
+		req := <-fake.requests
+		assert.Equal(t, testData.filter, req.Filter, testData.name)
+		assert.Equal(t, "projects/myproject", req.Name, testData.name)
+		assert.Equal(t, time.Minute, req.Aggregation.AlignmentPeriod.AsDuration(), testData.name)
+		assert.Equal(t, monitoringpb.Aggregation_ALIGN_MEAN, req.Aggregation.PerSeriesAligner, testData.name)
+		assert.Equal(t, monitoringpb.Aggregation_REDUCE_SUM, req.Aggregation.CrossSeriesReducer, testData.name)
+		if err := s.Close(context.Background()); err != nil {
+			t.Errorf("%s: unexpected error closing the scaler: %s", testData.name, err)
+		}
+	}
+}
@@ -2,8 +2,15 @@ package scalers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"time"
+
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	"k8s.io/api/autoscaling/v2beta2"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -17,6 +24,11 @@ import (
 
 const (
 	defaultStackdriverTargetValue = 5
+	// defaultStackdriverAlignmentPeriod is the alignment period of an aligner without alignmentPeriodSeconds, the
+	// shortest one of Cloud Monitoring
+	defaultStackdriverAlignmentPeriod = 60
+	// defaultStackdriverFilterDuration is the lookback of the filter in minutes
+	defaultStackdriverFilterDuration = 2
 )
 
 type stackdriverScaler struct {
@@ -31,6 +43,16 @@ type stackdriverMetadata struct {
 	targetValue int64
 	metricName  string
 
+	// query is a Monitoring Query Language query, used instead of the filter
+	query string
+	// aggregation aligns, and reduces, the time series of the filter, it is nil without an aligner
+	aggregation *monitoringpb.Aggregation
+	// filterDuration is the lookback of the filter
+	filterDuration time.Duration
+	// valueIfNull is the value of the metric when the filter or the query yields no point, e.g. of a sparse custom
+	// metric, instead of an error
+	valueIfNull *float64
+
 	gcpAuthorization *gcpAuthorizationMetadata
 }
 
@@ -75,14 +97,56 @@ func parseStackdriverMetadata(config *ScalerConfig) (*stackdriverMetadata, error
 		return nil, fmt.Errorf("no projectId name given")
 	}
 
-	if val, ok := config.TriggerMetadata["filter"]; ok {
-		if val == "" {
-			return nil, fmt.Errorf("no filter given")
+	if val, ok := config.TriggerMetadata["filter"]; ok && val != "" {
+		meta.filter = val
+	}
+
+	if val, ok := config.TriggerMetadata["query"]; ok && val != "" {
+		if meta.filter != "" {
+			return nil, fmt.Errorf("filter and query can't be both given")
 		}
+		meta.query = val
+	}
 
-		meta.filter = val
-	} else {
-		return nil, fmt.Errorf("no filter given")
+	if meta.filter == "" && meta.query == "" {
+		return nil, fmt.Errorf("no filter or query given")
+	}
+
+	aggregation, err := parseStackdriverAggregation(config.TriggerMetadata)
+	if err != nil {
+		return nil, err
+	}
+	if aggregation != nil && meta.query != "" {
+		return nil, fmt.Errorf("the alignment options can't be used with a query, align the time series in the query instead")
+	}
+	meta.aggregation = aggregation
+
+	meta.filterDuration = defaultStackdriverFilterDuration * time.Minute
+	if val, ok := config.TriggerMetadata["filterDuration"]; ok && val != "" {
+		if meta.query != "" {
+			return nil, fmt.Errorf("filterDuration can't be used with a query, set the lookback in the query instead")
+		}
+		filterDuration, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpStackdriverLog.Error(err, "Error parsing filterDuration")
+			return nil, fmt.Errorf("error parsing filterDuration: %s", err.Error())
+		}
+		if filterDuration <= 0 {
+			return nil, fmt.Errorf("filterDuration must be a positive number of minutes")
+		}
+		meta.filterDuration = time.Duration(filterDuration) * time.Minute
+	}
+
+	if val, ok := config.TriggerMetadata["valueIfNull"]; ok && val != "" {
+		valueIfNull, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			gcpStackdriverLog.Error(err, "Error parsing valueIfNull")
+			return nil, fmt.Errorf("error parsing valueIfNull: %s", err.Error())
+		}
+		if math.IsNaN(valueIfNull) || math.IsInf(valueIfNull, 0) {
+			return nil, fmt.Errorf("valueIfNull must be a finite number")
+		}
+		meta.valueIfNull = &valueIfNull
 	}
 
 	name := kedautil.NormalizeString(fmt.Sprintf("gcp-stackdriver-%s", meta.projectID))
@@ -106,15 +170,56 @@ func parseStackdriverMetadata(config *ScalerConfig) (*stackdriverMetadata, error
 	return &meta, nil
 }
 
-func initializeStackdriverClient(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (*StackDriverClient, error) {
-	var client *StackDriverClient
-	var err error
-	if gcpAuthorization.podIdentityProviderEnabled {
-		client, err = NewStackDriverClientPodIdentity(ctx)
+// parseStackdriverAggregation returns the aggregation of the alignment options, the aligner and the reducer are the
+// names of the Cloud Monitoring ones without their prefix, e.g. percentile_99 for ALIGN_PERCENTILE_99
+func parseStackdriverAggregation(metadata map[string]string) (*monitoringpb.Aggregation, error) {
+	aggregation := &monitoringpb.Aggregation{}
+
+	if val, ok := metadata["alignmentAligner"]; ok && val != "" {
+		aligner, ok := monitoringpb.Aggregation_Aligner_value["ALIGN_"+strings.ToUpper(val)]
+		if !ok || aligner == int32(monitoringpb.Aggregation_ALIGN_NONE) {
+			return nil, fmt.Errorf("alignmentAligner %q is not an aligner of Cloud Monitoring, e.g. mean, max, sum or percentile_99", val)
+		}
+		aggregation.PerSeriesAligner = monitoringpb.Aggregation_Aligner(aligner)
+	}
+
+	if val, ok := metadata["alignmentReducer"]; ok && val != "" {
+		reducer, ok := monitoringpb.Aggregation_Reducer_value["REDUCE_"+strings.ToUpper(val)]
+		if !ok || reducer == int32(monitoringpb.Aggregation_REDUCE_NONE) {
+			return nil, fmt.Errorf("alignmentReducer %q is not a reducer of Cloud Monitoring, e.g. mean, max, sum or percentile_99", val)
+		}
+		aggregation.CrossSeriesReducer = monitoringpb.Aggregation_Reducer(reducer)
+	}
+
+	alignmentPeriod := int64(defaultStackdriverAlignmentPeriod)
+	val, hasAlignmentPeriod := metadata["alignmentPeriodSeconds"]
+	if hasAlignmentPeriod && val != "" {
+		period, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcpStackdriverLog.Error(err, "Error parsing alignmentPeriodSeconds")
+			return nil, fmt.Errorf("error parsing alignmentPeriodSeconds: %s", err.Error())
+		}
+		if period < defaultStackdriverAlignmentPeriod {
+			return nil, fmt.Errorf("alignmentPeriodSeconds must be at least %d", defaultStackdriverAlignmentPeriod)
+		}
+		alignmentPeriod = period
 	} else {
-		client, err = NewStackDriverClient(ctx, gcpAuthorization.GoogleApplicationCredentials)
+		hasAlignmentPeriod = false
+	}
+
+	if aggregation.PerSeriesAligner == monitoringpb.Aggregation_ALIGN_NONE {
+		// the time series are reduced, and aligned to a period, once aligned
+		if aggregation.CrossSeriesReducer != monitoringpb.Aggregation_REDUCE_NONE || hasAlignmentPeriod {
+			return nil, fmt.Errorf("alignmentPeriodSeconds and alignmentReducer require an alignmentAligner")
+		}
+		return nil, nil
 	}
+	aggregation.AlignmentPeriod = durationpb.New(time.Duration(alignmentPeriod) * time.Second)
+	return aggregation, nil
+}
 
+func initializeStackdriverClient(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (*StackDriverClient, error) {
+	client, err := stackDriverClients.acquire(ctx, gcpAuthorization)
 	if err != nil {
 		gcpStackdriverLog.Error(err, "Failed to create stack driver client")
 		return nil, err
@@ -133,7 +238,7 @@ func (s *stackdriverScaler) IsActive(ctx context.Context) (bool, error) {
 
 func (s *stackdriverScaler) Close(context.Context) error {
 	if s.client != nil {
-		err := s.client.metricsClient.Close()
+		err := stackDriverClients.release(s.client)
 		s.client = nil
 		if err != nil {
 			gcpStackdriverLog.Error(err, "error closing StackDriver client")
@@ -171,7 +276,7 @@ func (s *stackdriverScaler) GetMetrics(ctx context.Context, metricName string, m
 
 	metric := external_metrics.ExternalMetricValue{
 		MetricName: metricName,
-		Value:      *resource.NewQuantity(value, resource.DecimalSI),
+		Value:      *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI),
 		Timestamp:  metav1.Now(),
 	}
 
@@ -179,11 +284,22 @@ func (s *stackdriverScaler) GetMetrics(ctx context.Context, metricName string, m
 }
 
 // getMetrics gets metric type value from stackdriver api
-func (s *stackdriverScaler) getMetrics(ctx context.Context) (int64, error) {
-	val, err := s.client.GetMetrics(ctx, s.metadata.filter, s.metadata.projectID)
+func (s *stackdriverScaler) getMetrics(ctx context.Context) (float64, error) {
+	var val float64
+	var err error
+	if s.metadata.query != "" {
+		val, err = s.client.QueryMetrics(ctx, s.metadata.query, s.metadata.projectID)
+	} else {
+		val, err = s.client.GetMetricsWithAggregation(ctx, s.metadata.filter, s.metadata.projectID, s.metadata.aggregation, s.metadata.filterDuration)
+	}
+
+	if errors.Is(err, errStackDriverMetricNotFound) && s.metadata.valueIfNull != nil {
+		gcpStackdriverLog.V(1).Info(fmt.Sprintf("%s, using valueIfNull %g", err, *s.metadata.valueIfNull))
+		return *s.metadata.valueIfNull, nil
+	}
 	if err == nil {
 		gcpStackdriverLog.V(1).Info(
-			fmt.Sprintf("Getting metrics for project %s and filter %s. Result: %d", s.metadata.projectID, s.metadata.filter, val))
+			fmt.Sprintf("Getting metrics for project %s, filter %s and query %s. Result: %g", s.metadata.projectID, s.metadata.filter, s.metadata.query, val))
 	}
 
 	return val, err
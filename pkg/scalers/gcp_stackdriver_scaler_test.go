@@ -3,6 +3,16 @@ package scalers
 import (
 	"context"
 	"testing"
+	"time"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"github.com/stretchr/testify/assert"
+	option "google.golang.org/api/option"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/internal/testutil"
 )
 
 var testStackdriverResolvedEnv = map[string]string{
@@ -23,6 +33,8 @@ type gcpStackdriverMetricIdentifier struct {
 
 var sdFilter = "metric.type=\"storage.googleapis.com/storage/object_count\" resource.type=\"gcs_bucket\""
 
+var sdQuery = "fetch gcs_bucket | metric 'storage.googleapis.com/storage/object_count' | every 1m"
+
 var testStackdriverMetadata = []parseStackdriverMetadataTestData{
 	{map[string]string{}, map[string]string{}, true},
 	// all properly formed
@@ -41,6 +53,44 @@ var testStackdriverMetadata = []parseStackdriverMetadataTestData{
 	{map[string]string{"GoogleApplicationCredentials": "Creds", "podIdentityOwner": ""}, map[string]string{"projectId": "myProject", "filter": sdFilter}, false},
 	// Credentials from AuthParams with empty creds
 	{map[string]string{"GoogleApplicationCredentials": "", "podIdentityOwner": ""}, map[string]string{"projectId": "myProject", "filter": sdFilter}, true},
+	// query
+	{nil, map[string]string{"projectId": "myProject", "query": sdQuery, "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// filter and query
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "query": sdQuery, "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// aggregation
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "alignmentPeriodSeconds": "300", "alignmentAligner": "percentile_99", "alignmentReducer": "max", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// aligner with the default alignment period
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "alignmentAligner": "mean", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// aggregation with a query
+	{nil, map[string]string{"projectId": "myProject", "query": sdQuery, "alignmentAligner": "mean", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// unknown aligner
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "alignmentAligner": "median", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// none aligner
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "alignmentAligner": "none", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// unknown reducer
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "alignmentAligner": "mean", "alignmentReducer": "rate", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// reducer without aligner
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "alignmentReducer": "sum", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// alignment period without aligner
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "alignmentPeriodSeconds": "120", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed alignment period
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "alignmentPeriodSeconds": "1m", "alignmentAligner": "mean", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// alignment period shorter than a minute
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "alignmentPeriodSeconds": "30", "alignmentAligner": "mean", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// filterDuration and valueIfNull
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "filterDuration": "10", "valueIfNull": "0.5", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// valueIfNull with a query
+	{nil, map[string]string{"projectId": "myProject", "query": sdQuery, "valueIfNull": "0", "credentialsFromEnv": "SAMPLE_CREDS"}, false},
+	// filterDuration with a query
+	{nil, map[string]string{"projectId": "myProject", "query": sdQuery, "filterDuration": "10", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed filterDuration
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "filterDuration": "10m", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// zero filterDuration
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "filterDuration": "0", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// malformed valueIfNull
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "valueIfNull": "AA", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
+	// infinite valueIfNull
+	{nil, map[string]string{"projectId": "myProject", "filter": sdFilter, "valueIfNull": "Inf", "credentialsFromEnv": "SAMPLE_CREDS"}, true},
 }
 
 var gcpStackdriverMetricIdentifiers = []gcpStackdriverMetricIdentifier{
@@ -75,3 +125,186 @@ func TestGcpStackdriverGetMetricSpecForScaling(t *testing.T) {
 		}
 	}
 }
+
+func TestStackdriverParseAggregation(t *testing.T) {
+	meta, err := parseStackdriverMetadata(&ScalerConfig{TriggerMetadata: testStackdriverMetadata[11].metadata, ResolvedEnv: testStackdriverResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Equal(t, monitoringpb.Aggregation_ALIGN_PERCENTILE_99, meta.aggregation.PerSeriesAligner)
+	assert.Equal(t, monitoringpb.Aggregation_REDUCE_MAX, meta.aggregation.CrossSeriesReducer)
+	assert.Equal(t, 5*time.Minute, meta.aggregation.AlignmentPeriod.AsDuration())
+
+	meta, err = parseStackdriverMetadata(&ScalerConfig{TriggerMetadata: testStackdriverMetadata[12].metadata, ResolvedEnv: testStackdriverResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Equal(t, monitoringpb.Aggregation_ALIGN_MEAN, meta.aggregation.PerSeriesAligner)
+	assert.Equal(t, monitoringpb.Aggregation_REDUCE_NONE, meta.aggregation.CrossSeriesReducer)
+	assert.Equal(t, time.Minute, meta.aggregation.AlignmentPeriod.AsDuration())
+
+	// the filter is read as before without alignment options
+	meta, err = parseStackdriverMetadata(&ScalerConfig{TriggerMetadata: testStackdriverMetadata[1].metadata, ResolvedEnv: testStackdriverResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Nil(t, meta.aggregation)
+}
+
+// fakeQueryServer returns the time series data of the queries
+type fakeQueryServer struct {
+	monitoringpb.UnimplementedQueryServiceServer
+	data     []*monitoringpb.TimeSeriesData
+	requests chan *monitoringpb.QueryTimeSeriesRequest
+}
+
+func (s *fakeQueryServer) QueryTimeSeries(_ context.Context, req *monitoringpb.QueryTimeSeriesRequest) (*monitoringpb.QueryTimeSeriesResponse, error) {
+	s.requests <- req
+	return &monitoringpb.QueryTimeSeriesResponse{TimeSeriesData: s.data}, nil
+}
+
+func newStackdriverDoubleValue(value float64) *monitoringpb.TypedValue {
+	return &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_DoubleValue{DoubleValue: value}}
+}
+
+func newFakeStackdriverScaler(t *testing.T, metadata map[string]string, metrics *fakeMonitoringServer, queries *fakeQueryServer) *stackdriverScaler {
+	server := testutil.NewFaultyGRPCServer(t, func(s *grpc.Server) {
+		monitoringpb.RegisterMetricServiceServer(s, metrics)
+		monitoringpb.RegisterQueryServiceServer(s, queries)
+	}, testutil.Faults{})
+	metricsClient, err := monitoring.NewMetricClient(context.Background(), option.WithEndpoint(server.Addr), option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal("Could not create the client:", err)
+	}
+	t.Cleanup(func() { metricsClient.Close() })
+
+	meta, err := parseStackdriverMetadata(&ScalerConfig{TriggerMetadata: metadata, ResolvedEnv: testStackdriverResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	return &stackdriverScaler{client: &StackDriverClient{metricsClient: metricsClient}, metadata: meta}
+}
+
+func TestGcpStackdriverGetMetricsWithAggregation(t *testing.T) {
+	series := &monitoringpb.TimeSeries{Points: []*monitoringpb.Point{{Value: newStackdriverDoubleValue(12.5)}, {Value: newStackdriverDoubleValue(3)}}}
+	metrics := &fakeMonitoringServer{series: []*monitoringpb.TimeSeries{series}, requests: make(chan *monitoringpb.ListTimeSeriesRequest, 1)}
+	s := newFakeStackdriverScaler(t, testStackdriverMetadata[11].metadata, metrics, &fakeQueryServer{})
+
+	values, err := s.GetMetrics(context.Background(), "s0-gcp-stackdriver-myProject", nil)
+	if err != nil {
+		t.Fatal("Could not get the metrics:", err)
+	}
+	assert.Equal(t, int64(12500), values[0].Value.MilliValue())
+
+	req := <-metrics.requests
+	assert.Equal(t, "projects/myProject", req.Name)
+	assert.Equal(t, sdFilter, req.Filter)
+	assert.Equal(t, monitoringpb.Aggregation_ALIGN_PERCENTILE_99, req.Aggregation.GetPerSeriesAligner())
+	assert.Equal(t, monitoringpb.Aggregation_REDUCE_MAX, req.Aggregation.GetCrossSeriesReducer())
+	assert.Equal(t, int64(300), req.Aggregation.GetAlignmentPeriod().GetSeconds())
+	// the interval holds a complete alignment period
+	assert.Equal(t, int64(600), req.Interval.EndTime.Seconds-req.Interval.StartTime.Seconds)
+}
+
+func TestGcpStackdriverGetMetricsWithQuery(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		data     []*monitoringpb.TimeSeriesData
+		value    int64
+		isActive bool
+		isError  bool
+	}{
+		// the latest point comes first
+		{"double", []*monitoringpb.TimeSeriesData{{PointData: []*monitoringpb.TimeSeriesData_PointData{
+			{Values: []*monitoringpb.TypedValue{newStackdriverDoubleValue(0.25)}}, {Values: []*monitoringpb.TypedValue{newStackdriverDoubleValue(4)}}}}}, 250, true, false},
+		{"int64", []*monitoringpb.TimeSeriesData{{PointData: []*monitoringpb.TimeSeriesData_PointData{
+			{Values: []*monitoringpb.TypedValue{{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: 7}}}}}}}, 7000, true, false},
+		{"zero", []*monitoringpb.TimeSeriesData{{PointData: []*monitoringpb.TimeSeriesData_PointData{
+			{Values: []*monitoringpb.TypedValue{newStackdriverDoubleValue(0)}}}}}, 0, false, false},
+		{"string", []*monitoringpb.TimeSeriesData{{PointData: []*monitoringpb.TimeSeriesData_PointData{
+			{Values: []*monitoringpb.TypedValue{{Value: &monitoringpb.TypedValue_StringValue{StringValue: "7"}}}}}}}, 0, false, true},
+		{"no point", []*monitoringpb.TimeSeriesData{{}}, 0, false, true},
+		{"no time series", nil, 0, false, true},
+	} {
+		queries := &fakeQueryServer{data: testData.data, requests: make(chan *monitoringpb.QueryTimeSeriesRequest, 2)}
+		s := newFakeStackdriverScaler(t, testStackdriverMetadata[9].metadata, &fakeMonitoringServer{}, queries)
+
+		values, err := s.GetMetrics(context.Background(), "s0-gcp-stackdriver-myProject", nil)
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if err == nil && values[0].Value.MilliValue() != testData.value {
+			t.Errorf("%s: expected the value %dm, got %dm", testData.name, testData.value, values[0].Value.MilliValue())
+		}
+
+		isActive, err := s.IsActive(context.Background())
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if isActive != testData.isActive {
+			t.Errorf("%s: expected active %t, got %t", testData.name, testData.isActive, isActive)
+		}
+
+		req := <-queries.requests
+		if req.Name != "projects/myProject" || req.Query != sdQuery {
+			t.Errorf("%s: expected the query to be run in the project, got %s %s", testData.name, req.Name, req.Query)
+		}
+	}
+}
+
+func TestStackdriverParseFilterDurationAndValueIfNull(t *testing.T) {
+	meta, err := parseStackdriverMetadata(&ScalerConfig{TriggerMetadata: testStackdriverMetadata[1].metadata, ResolvedEnv: testStackdriverResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Equal(t, 2*time.Minute, meta.filterDuration)
+	assert.Nil(t, meta.valueIfNull)
+
+	meta, err = parseStackdriverMetadata(&ScalerConfig{TriggerMetadata: testStackdriverMetadata[21].metadata, ResolvedEnv: testStackdriverResolvedEnv})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	assert.Equal(t, 10*time.Minute, meta.filterDuration)
+	if assert.NotNil(t, meta.valueIfNull) {
+		assert.Equal(t, 0.5, *meta.valueIfNull)
+	}
+}
+
+func TestGcpStackdriverGetMetricsWithoutPoints(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		metadata map[string]string
+		series   []*monitoringpb.TimeSeries
+		data     []*monitoringpb.TimeSeriesData
+		value    int64
+		isError  bool
+	}{
+		{"empty series", testStackdriverMetadata[1].metadata, nil, nil, 0, true},
+		{"empty series with valueIfNull", testStackdriverMetadata[21].metadata, nil, nil, 500, false},
+		{"series without points with valueIfNull", testStackdriverMetadata[21].metadata, []*monitoringpb.TimeSeries{{}}, nil, 500, false},
+		{"point with valueIfNull", testStackdriverMetadata[21].metadata, []*monitoringpb.TimeSeries{{Points: []*monitoringpb.Point{{Value: newStackdriverDoubleValue(3)}}}}, nil, 3000, false},
+		{"empty query", testStackdriverMetadata[9].metadata, nil, nil, 0, true},
+		{"empty query with valueIfNull", testStackdriverMetadata[22].metadata, nil, nil, 0, false},
+	} {
+		metrics := &fakeMonitoringServer{series: testData.series, requests: make(chan *monitoringpb.ListTimeSeriesRequest, 1)}
+		queries := &fakeQueryServer{data: testData.data, requests: make(chan *monitoringpb.QueryTimeSeriesRequest, 1)}
+		s := newFakeStackdriverScaler(t, testData.metadata, metrics, queries)
+
+		values, err := s.GetMetrics(context.Background(), "s0-gcp-stackdriver-myProject", nil)
+		if testData.isError != (err != nil) {
+			t.Errorf("%s: expected an error %t, got %v", testData.name, testData.isError, err)
+		}
+		if err == nil && values[0].Value.MilliValue() != testData.value {
+			t.Errorf("%s: expected the value %dm, got %dm", testData.name, testData.value, values[0].Value.MilliValue())
+		}
+
+		select {
+		case req := <-metrics.requests:
+			if lookback := req.Interval.EndTime.Seconds - req.Interval.StartTime.Seconds; lookback != int64(s.metadata.filterDuration.Seconds()) {
+				t.Errorf("%s: expected the filterDuration as interval, got %ds", testData.name, lookback)
+			}
+		case <-queries.requests:
+		}
+	}
+}
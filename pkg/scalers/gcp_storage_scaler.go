@@ -2,13 +2,26 @@ package scalers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xhit/go-str2duration/v2"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
-	option "google.golang.org/api/option"
 
 	"k8s.io/api/autoscaling/v2beta2"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -16,6 +29,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/metrics/pkg/apis/external_metrics"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	kedautil "github.com/kedacore/keda/v2/pkg/util"
 )
@@ -25,27 +39,161 @@ const (
 	defaultTargetObjectCount = 100
 	// A limit on iterating bucket objects
 	defaultMaxBucketItemsToScan = 1000
+	// A limit on how long a single listing of the bucket runs
+	defaultGcsListTimeout = 15 * time.Second
+	// gcsListPageSize is how many objects are listed per request, the most GCS returns
+	gcsListPageSize = 1000
+	// Default for how many times a page of objects is listed again after a transient error
+	defaultGcsMaxRetries = 3
+
+	// gcsValueTypeCount scales on the number of objects in the bucket
+	gcsValueTypeCount = "count"
+	// gcsValueTypeSize scales on the total size in bytes of the objects in the bucket
+	gcsValueTypeSize = "size"
+	// gcsValueTypeOldestObjectAge scales on the age in seconds of the oldest object in the bucket
+	gcsValueTypeOldestObjectAge = "oldestObjectAge"
+
+	// gcsCountModeObjects counts the objects in the bucket
+	gcsCountModeObjects = "objects"
+	// gcsCountModePrefixes counts the first-level prefixes, the "folders", holding objects in the bucket
+	gcsCountModePrefixes = "prefixes"
+	// gcsCountModeMonitoring reads the number of objects in the bucket from Cloud Monitoring instead of listing them
+	gcsCountModeMonitoring = "monitoring"
+	// defaultGcsPrefixDelimiter is the delimiter of the prefixes counted without a blobDelimiter
+	defaultGcsPrefixDelimiter = "/"
+	// gcsObjectCountMetricType is the Cloud Monitoring metric of the number of objects per bucket and storage class,
+	// measured once a day and published with a delay of up to 10 minutes
+	gcsObjectCountMetricType = "storage.googleapis.com/storage/object_count"
+	// Default for how far back the latest points of the object count metric are looked for
+	defaultGcsMonitoringLookback = 30 * time.Minute
+	// gcsCustomMetadataHeaderPrefix is the prefix of the headers of the custom metadata of the objects
+	gcsCustomMetadataHeaderPrefix = "x-goog-meta-"
+	// gcsOffsetDateLayout is the layout of the dates of the startOffset and endOffset templates
+	gcsOffsetDateLayout = "2006-01-02"
+
+	// gcsTimeWindowDroppedRatioToLog is the fraction of the scanned objects that, once dropped by the timeWindow
+	// filter, is logged to hint at narrowing the listing with prefixes
+	gcsTimeWindowDroppedRatioToLog = 0.5
+
+	// gcsCountCacheTTL is how long the value of the last full listing of the bucket is reused, long enough for IsActive
+	// and GetMetrics of the same polling cycle to share it
+	gcsCountCacheTTL = 10 * time.Second
 )
 
+// gcsRetryBackoff is the backoff between the listings of a page of objects again after a transient error, short as
+// the listing has to end within listTimeout
+var gcsRetryBackoff = gax.Backoff{Initial: 200 * time.Millisecond, Max: 2 * time.Second, Multiplier: 2}
+
+// gcsRetryableCodes are the status codes of the transient errors of GCS, https://cloud.google.com/storage/docs/retry-strategy
+var gcsRetryableCodes = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+}
+
+// gcsCountTruncated tells whether the last full count of the items of a bucket stopped at maxBucketItemsToScan, the
+// value of the metric of the scaler is then a lower bound of the one of the bucket
+var gcsCountTruncated = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "keda",
+		Subsystem: "gcs_scaler",
+		Name:      "count_truncated",
+		Help:      "1 when the last count of the objects of the bucket stopped at maxBucketItemsToScan, 0 otherwise",
+	},
+	[]string{"bucketName", "metricName"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(gcsCountTruncated)
+}
+
 type gcsScaler struct {
 	client     *storage.Client
 	bucket     *storage.BucketHandle
 	metricType v2beta2.MetricTargetType
 	metadata   *gcsMetadata
+
+	// sharedClient is the client acquired from the shared client cache, client is its storage client
+	sharedClient *gcsClient
+
+	// monitoringClient reads the object count metric with countMode monitoring, the scaler lists no object then
+	monitoringClient *StackDriverClient
+
+	// now returns the current time the startOffset and endOffset templates are rendered at, time.Now when not set
+	now func() time.Time
+
+	// logTruncation logs the first count stopped at maxBucketItemsToScan
+	logTruncation sync.Once
+
+	// the value of the last full listing, up to maxBucketItemsToScan items, and when it was listed
+	cacheLock   sync.Mutex
+	cachedValue int64
+	cachedAt    time.Time
 }
 
 type gcsMetadata struct {
-	bucketName           string
-	gcpAuthorization     *gcpAuthorizationMetadata
-	maxBucketItemsToScan int
-	metricName           string
-	targetObjectCount    int64
+	bucketName                  string
+	bucketMustExist             bool
+	userProject                 string
+	blobPrefixes                []string
+	blobDelimiter               string
+	blobNameRegex               *regexp.Regexp
+	blobGlob                    string
+	contentTypes                []string
+	metadataKey                 string
+	metadataValue               string
+	countMode                   string
+	startOffset                 *template.Template
+	endOffset                   *template.Template
+	monitoringLookback          time.Duration
+	projectID                   string
+	excludeEmptyObjects         bool
+	timeWindow                  time.Duration
+	disableCountCache           bool
+	includeVersions             bool
+	onlyNoncurrent              bool
+	listTimeout                 time.Duration
+	maxRetries                  int
+	gcpAuthorization            *gcpAuthorizationMetadata
+	endpoint                    string
+	insecure                    bool
+	maxBucketItemsToScan        int
+	errorWhenTruncated          bool
+	metricName                  string
+	valueType                   string
+	targetObjectCount           float64
+	activationTargetObjectCount int64
+	targetBytes                 int64
+	activationTargetBytes       int64
+	targetObjectAge             time.Duration
+	activationTargetObjectAge   time.Duration
+}
+
+// String renders the metadata for the logs, with the credentials of gcpAuthorization redacted
+func (m gcsMetadata) String() string {
+	var blobNameRegex string
+	if m.blobNameRegex != nil {
+		blobNameRegex = m.blobNameRegex.String()
+	}
+	var gcpAuthorization fmt.Stringer = gcpAuthorizationMetadata{}
+	if m.gcpAuthorization != nil {
+		gcpAuthorization = m.gcpAuthorization
+	}
+	return fmt.Sprintf("{bucketName:%s bucketMustExist:%t userProject:%s blobPrefixes:%q blobDelimiter:%s blobNameRegex:%s blobGlob:%s contentTypes:%q metadataKey:%s metadataValue:%s countMode:%s startOffset:%s endOffset:%s monitoringLookback:%s projectID:%s excludeEmptyObjects:%t "+
+		"timeWindow:%s disableCountCache:%t includeVersions:%t onlyNoncurrent:%t listTimeout:%s maxRetries:%d gcpAuthorization:%s endpoint:%s "+
+		"insecure:%t maxBucketItemsToScan:%d errorWhenTruncated:%t metricName:%s valueType:%s targetObjectCount:%g activationTargetObjectCount:%d "+
+		"targetBytes:%d activationTargetBytes:%d targetObjectAge:%s activationTargetObjectAge:%s}",
+		m.bucketName, m.bucketMustExist, m.userProject, m.blobPrefixes, m.blobDelimiter, blobNameRegex, m.blobGlob, m.contentTypes, m.metadataKey, m.metadataValue, m.countMode, gcsOffsetTemplateText(m.startOffset), gcsOffsetTemplateText(m.endOffset), m.monitoringLookback, m.projectID, m.excludeEmptyObjects,
+		m.timeWindow, m.disableCountCache, m.includeVersions, m.onlyNoncurrent, m.listTimeout, m.maxRetries, gcpAuthorization, m.endpoint,
+		m.insecure, m.maxBucketItemsToScan, m.errorWhenTruncated, m.metricName, m.valueType, m.targetObjectCount, m.activationTargetObjectCount,
+		m.targetBytes, m.activationTargetBytes, m.targetObjectAge, m.activationTargetObjectAge)
 }
 
 var gcsLog = logf.Log.WithName("gcp_storage_scaler")
 
 // NewGcsScaler creates a new gcsScaler
-func NewGcsScaler(config *ScalerConfig) (Scaler, error) {
+func NewGcsScaler(ctx context.Context, config *ScalerConfig) (Scaler, error) {
 	metricType, err := GetMetricTargetType(config)
 	if err != nil {
 		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
@@ -56,44 +204,87 @@ func NewGcsScaler(config *ScalerConfig) (Scaler, error) {
 		return nil, fmt.Errorf("error parsing GCP storage metadata: %s", err)
 	}
 
-	ctx := context.Background()
+	if meta.countMode == gcsCountModeMonitoring {
+		client, err := stackDriverClients.acquire(ctx, meta.gcpAuthorization)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the Cloud Monitoring client: %s", err)
+		}
 
-	var client *storage.Client
+		gcsLog.Info(fmt.Sprintf("Metadata %s", meta))
 
-	switch {
-	case meta.gcpAuthorization.podIdentityProviderEnabled:
-		client, err = storage.NewClient(ctx)
-	case meta.gcpAuthorization.GoogleApplicationCredentialsFile != "":
-		client, err = storage.NewClient(
-			ctx, option.WithCredentialsFile(meta.gcpAuthorization.GoogleApplicationCredentialsFile))
-	default:
-		client, err = storage.NewClient(
-			ctx, option.WithCredentialsJSON([]byte(meta.gcpAuthorization.GoogleApplicationCredentials)))
+		return &gcsScaler{
+			monitoringClient: client,
+			metricType:       metricType,
+			metadata:         meta,
+		}, nil
 	}
 
+	// the triggers on the same credentials and endpoint, e.g. on several prefixes of a bucket, share the client
+	client, err := gcsClients.acquire(ctx, meta)
 	if err != nil {
-		return nil, fmt.Errorf("storage.NewClient: %v", err)
+		return nil, err
 	}
 
-	bucket := client.Bucket(meta.bucketName)
+	bucket := client.client.Bucket(meta.bucketName)
 	if bucket == nil {
+		_ = gcsClients.release(client)
 		return nil, fmt.Errorf("failed to create a handle to bucket %s", meta.bucketName)
 	}
+	if meta.userProject != "" {
+		bucket = bucket.UserProject(meta.userProject)
+	}
+	// the scaler retries the listings itself, up to maxRetries times, instead of until listTimeout
+	bucket = bucket.Retryer(storage.WithPolicy(storage.RetryNever))
 
-	gcsLog.Info(fmt.Sprintf("Metadata %v", meta))
+	gcsLog.Info(fmt.Sprintf("Metadata %s", meta))
 
-	return &gcsScaler{
-		client:     client,
-		bucket:     bucket,
-		metricType: metricType,
-		metadata:   meta,
-	}, nil
+	scaler := &gcsScaler{
+		client:       client.client,
+		sharedClient: client,
+		bucket:       bucket,
+		metricType:   metricType,
+		metadata:     meta,
+	}
+	if meta.bucketMustExist {
+		if err := scaler.verifyBucketExists(ctx); err != nil {
+			_ = scaler.Close(ctx)
+			return nil, err
+		}
+	}
+	return scaler, nil
+}
+
+// verifyBucketExists fails when the bucket doesn't exist. Getting the attributes of the bucket needs the
+// storage.buckets.get permission, that e.g. roles/storage.objectViewer doesn't grant, so a 403 response doesn't
+// fail the scaler, the bucket may well exist: the listings report the missing buckets then
+func (s *gcsScaler) verifyBucketExists(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.metadata.listTimeout)
+	defer cancel()
+
+	_, err := s.bucket.Attrs(ctx)
+	var apiErr *googleapi.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, storage.ErrBucketNotExist):
+		return fmt.Errorf("bucket %s doesn't exist, bucketMustExist is set", s.metadata.bucketName)
+	case errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden:
+		gcsLog.Info("Could not verify that the bucket exists, the service account lacks the storage.buckets.get permission, the listings will report it missing",
+			"bucketName", s.metadata.bucketName)
+		return nil
+	default:
+		return fmt.Errorf("error verifying that bucket %s exists: %s", s.metadata.bucketName, err)
+	}
 }
 
 func parseGcsMetadata(config *ScalerConfig) (*gcsMetadata, error) {
 	meta := gcsMetadata{}
 	meta.targetObjectCount = defaultTargetObjectCount
 	meta.maxBucketItemsToScan = defaultMaxBucketItemsToScan
+	meta.listTimeout = defaultGcsListTimeout
+	meta.maxRetries = defaultGcsMaxRetries
+	meta.valueType = gcsValueTypeCount
+	meta.countMode = gcsCountModeObjects
 
 	if val, ok := config.TriggerMetadata["bucketName"]; ok {
 		if val == "" {
@@ -107,49 +298,496 @@ func parseGcsMetadata(config *ScalerConfig) (*gcsMetadata, error) {
 		return nil, fmt.Errorf("no bucket name given")
 	}
 
+	if val, ok := config.TriggerMetadata["bucketMustExist"]; ok && val != "" {
+		bucketMustExist, err := strconv.ParseBool(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing bucketMustExist")
+			return nil, fmt.Errorf("error parsing bucketMustExist: %s", err.Error())
+		}
+
+		meta.bucketMustExist = bucketMustExist
+	}
+
+	// the project billed for the requests to a requester-pays bucket
+	if val, ok := config.TriggerMetadata["userProject"]; ok {
+		if strings.TrimSpace(val) == "" {
+			return nil, fmt.Errorf("userProject must not be empty")
+		}
+
+		meta.userProject = strings.TrimSpace(val)
+	}
+
+	meta.blobPrefixes = []string{config.TriggerMetadata["blobPrefix"]}
+	if val, ok := config.TriggerMetadata["blobPrefixes"]; ok && val != "" {
+		if config.TriggerMetadata["blobPrefix"] != "" {
+			return nil, fmt.Errorf("blobPrefix and blobPrefixes can't both be set")
+		}
+		blobPrefixes, err := parseGcsBlobPrefixes(val)
+		if err != nil {
+			return nil, err
+		}
+
+		meta.blobPrefixes = blobPrefixes
+	}
+
+	if val, ok := config.TriggerMetadata["blobDelimiter"]; ok {
+		meta.blobDelimiter = val
+	}
+
+	if val, ok := config.TriggerMetadata["blobNameRegex"]; ok && val != "" {
+		blobNameRegex, err := regexp.Compile(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing blobNameRegex")
+			return nil, fmt.Errorf("error parsing blobNameRegex: %s", err.Error())
+		}
+
+		meta.blobNameRegex = blobNameRegex
+	}
+
+	// the glob is matched by GCS, unlike blobNameRegex the objects not matching it don't use up maxBucketItemsToScan
+	if val, ok := config.TriggerMetadata["blobGlob"]; ok && val != "" {
+		if meta.blobDelimiter != "" {
+			return nil, fmt.Errorf("blobGlob can't be used with blobDelimiter")
+		}
+
+		meta.blobGlob = val
+	}
+
+	if val, ok := config.TriggerMetadata["contentType"]; ok && val != "" {
+		for _, contentType := range strings.Split(val, ",") {
+			contentType = strings.TrimSpace(contentType)
+			if contentType == "" {
+				return nil, fmt.Errorf("contentType must not contain empty content types, got %q", val)
+			}
+			meta.contentTypes = append(meta.contentTypes, contentType)
+		}
+	}
+
+	// the listings return the whole custom metadata of every object with a metadataKey, it makes the responses larger
+	// and so the scans slower, the objects without the metadata still use up maxBucketItemsToScan
+	if val, ok := config.TriggerMetadata["metadataKey"]; ok && val != "" {
+		// the key is the one of the metadata, with or without the prefix of its header
+		meta.metadataKey = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(val)), gcsCustomMetadataHeaderPrefix)
+		if meta.metadataKey == "" {
+			return nil, fmt.Errorf("no metadataKey given after the %s prefix", gcsCustomMetadataHeaderPrefix)
+		}
+		val, ok := config.TriggerMetadata["metadataValue"]
+		if !ok {
+			return nil, fmt.Errorf("metadataKey requires a metadataValue")
+		}
+
+		meta.metadataValue = val
+	} else if config.TriggerMetadata["metadataValue"] != "" {
+		return nil, fmt.Errorf("metadataValue requires a metadataKey")
+	}
+
+	if val, ok := config.TriggerMetadata["excludeEmptyObjects"]; ok && val != "" {
+		excludeEmptyObjects, err := strconv.ParseBool(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing excludeEmptyObjects")
+			return nil, fmt.Errorf("error parsing excludeEmptyObjects: %s", err.Error())
+		}
+
+		meta.excludeEmptyObjects = excludeEmptyObjects
+	}
+
+	if val, ok := config.TriggerMetadata["timeWindow"]; ok && val != "" {
+		timeWindow, err := str2duration.ParseDuration(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing timeWindow")
+			return nil, fmt.Errorf("error parsing timeWindow: %s", err.Error())
+		}
+		if timeWindow <= 0 {
+			return nil, fmt.Errorf("timeWindow must be greater than 0")
+		}
+
+		meta.timeWindow = timeWindow
+	}
+
+	if val, ok := config.TriggerMetadata["disableCountCache"]; ok && val != "" {
+		disableCountCache, err := strconv.ParseBool(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing disableCountCache")
+			return nil, fmt.Errorf("error parsing disableCountCache: %s", err.Error())
+		}
+
+		meta.disableCountCache = disableCountCache
+	}
+
+	if val, ok := config.TriggerMetadata["includeVersions"]; ok && val != "" {
+		includeVersions, err := strconv.ParseBool(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing includeVersions")
+			return nil, fmt.Errorf("error parsing includeVersions: %s", err.Error())
+		}
+
+		meta.includeVersions = includeVersions
+	}
+
+	// only the noncurrent versions are counted, they are listed with the versions of the objects
+	if val, ok := config.TriggerMetadata["onlyNoncurrent"]; ok && val != "" {
+		onlyNoncurrent, err := strconv.ParseBool(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing onlyNoncurrent")
+			return nil, fmt.Errorf("error parsing onlyNoncurrent: %s", err.Error())
+		}
+		if onlyNoncurrent && config.TriggerMetadata["includeVersions"] != "" && !meta.includeVersions {
+			return nil, fmt.Errorf("onlyNoncurrent requires includeVersions")
+		}
+
+		meta.onlyNoncurrent = onlyNoncurrent
+		meta.includeVersions = meta.includeVersions || onlyNoncurrent
+	}
+
+	if val, ok := config.TriggerMetadata["listTimeout"]; ok && val != "" {
+		listTimeout, err := str2duration.ParseDuration(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing listTimeout")
+			return nil, fmt.Errorf("error parsing listTimeout: %s", err.Error())
+		}
+		if listTimeout <= 0 {
+			return nil, fmt.Errorf("listTimeout must be greater than 0")
+		}
+
+		meta.listTimeout = listTimeout
+	}
+
+	if val, ok := config.TriggerMetadata["maxRetries"]; ok && val != "" {
+		maxRetries, err := strconv.Atoi(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing maxRetries")
+			return nil, fmt.Errorf("error parsing maxRetries: %s", err.Error())
+		}
+		if maxRetries < 0 {
+			return nil, fmt.Errorf("maxRetries must be 0 or greater")
+		}
+
+		meta.maxRetries = maxRetries
+	}
+
+	if val, ok := config.TriggerMetadata["valueType"]; ok && val != "" {
+		switch val {
+		case gcsValueTypeCount, gcsValueTypeSize, gcsValueTypeOldestObjectAge:
+			meta.valueType = val
+		default:
+			return nil, fmt.Errorf("valueType must be %s, %s or %s, got %s", gcsValueTypeCount, gcsValueTypeSize, gcsValueTypeOldestObjectAge, val)
+		}
+	}
+
 	if val, ok := config.TriggerMetadata["targetObjectCount"]; ok {
-		targetObjectCount, err := strconv.ParseInt(val, 10, 64)
+		targetObjectCount, err := strconv.ParseFloat(val, 64)
 		if err != nil {
 			gcsLog.Error(err, "Error parsing targetObjectCount")
 			return nil, fmt.Errorf("error parsing targetObjectCount: %s", err.Error())
 		}
+		// the target is a milli quantity, e.g. 0.5 for a pod per two objects
+		if math.IsInf(targetObjectCount, 0) || math.IsNaN(targetObjectCount) || math.Round(targetObjectCount*1000) < 1 {
+			return nil, fmt.Errorf("targetObjectCount must be at least 0.001")
+		}
 
 		meta.targetObjectCount = targetObjectCount
 	}
 
+	if val, ok := config.TriggerMetadata["activationTargetObjectCount"]; ok {
+		activationTargetObjectCount, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing activationTargetObjectCount")
+			return nil, fmt.Errorf("error parsing activationTargetObjectCount: %s", err.Error())
+		}
+		if activationTargetObjectCount < 0 {
+			return nil, fmt.Errorf("activationTargetObjectCount must not be negative")
+		}
+
+		meta.activationTargetObjectCount = activationTargetObjectCount
+	}
+
+	if val, ok := config.TriggerMetadata["targetBytes"]; ok {
+		targetBytes, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing targetBytes")
+			return nil, fmt.Errorf("error parsing targetBytes: %s", err.Error())
+		}
+		if targetBytes <= 0 {
+			return nil, fmt.Errorf("targetBytes must be greater than 0")
+		}
+
+		meta.targetBytes = targetBytes
+	} else if meta.valueType == gcsValueTypeSize {
+		return nil, fmt.Errorf("no targetBytes given")
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetBytes"]; ok {
+		activationTargetBytes, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing activationTargetBytes")
+			return nil, fmt.Errorf("error parsing activationTargetBytes: %s", err.Error())
+		}
+		if activationTargetBytes < 0 {
+			return nil, fmt.Errorf("activationTargetBytes must not be negative")
+		}
+
+		meta.activationTargetBytes = activationTargetBytes
+	}
+
+	if val, ok := config.TriggerMetadata["targetObjectAge"]; ok {
+		targetObjectAge, err := str2duration.ParseDuration(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing targetObjectAge")
+			return nil, fmt.Errorf("error parsing targetObjectAge: %s", err.Error())
+		}
+		if targetObjectAge < time.Second {
+			return nil, fmt.Errorf("targetObjectAge must be at least 1s")
+		}
+
+		meta.targetObjectAge = targetObjectAge
+	} else if meta.valueType == gcsValueTypeOldestObjectAge {
+		return nil, fmt.Errorf("no targetObjectAge given")
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetObjectAge"]; ok {
+		activationTargetObjectAge, err := str2duration.ParseDuration(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing activationTargetObjectAge")
+			return nil, fmt.Errorf("error parsing activationTargetObjectAge: %s", err.Error())
+		}
+		if activationTargetObjectAge < 0 {
+			return nil, fmt.Errorf("activationTargetObjectAge must not be negative")
+		}
+
+		meta.activationTargetObjectAge = activationTargetObjectAge
+	}
+
+	// the offsets bound the names of the objects listed, they are rendered when listing so e.g. {{.Today}} rolls over
+	for _, offset := range []struct {
+		name     string
+		template **template.Template
+	}{{"startOffset", &meta.startOffset}, {"endOffset", &meta.endOffset}} {
+		if val, ok := config.TriggerMetadata[offset.name]; ok && val != "" {
+			tmpl, err := template.New(offset.name).Option("missingkey=error").Parse(val)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s: %s", offset.name, err)
+			}
+			if _, err := renderGcsOffset(tmpl, time.Now()); err != nil {
+				return nil, err
+			}
+
+			*offset.template = tmpl
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["countMode"]; ok && val != "" {
+		switch val {
+		case gcsCountModeObjects:
+		case gcsCountModePrefixes:
+			// the prefixes hold objects with any attributes, they can only be counted, and only by their name
+			switch {
+			case meta.valueType != gcsValueTypeCount:
+				return nil, fmt.Errorf("countMode %s requires valueType %s", gcsCountModePrefixes, gcsValueTypeCount)
+			case meta.excludeEmptyObjects, meta.timeWindow > 0, len(meta.contentTypes) > 0, meta.metadataKey != "", meta.includeVersions:
+				return nil, fmt.Errorf("countMode %s can't be used with excludeEmptyObjects, timeWindow, contentType, metadataKey, includeVersions or onlyNoncurrent", gcsCountModePrefixes)
+			case meta.blobGlob != "":
+				return nil, fmt.Errorf("countMode %s can't be used with blobGlob, the prefixes need a blobDelimiter", gcsCountModePrefixes)
+			}
+			if meta.blobDelimiter == "" {
+				meta.blobDelimiter = defaultGcsPrefixDelimiter
+			}
+		case gcsCountModeMonitoring:
+			// the metric has the number of all the objects in the bucket, the listing settings don't apply to it
+			switch {
+			case meta.valueType != gcsValueTypeCount:
+				return nil, fmt.Errorf("countMode %s requires valueType %s", gcsCountModeMonitoring, gcsValueTypeCount)
+			case meta.blobPrefixes[0] != "" || len(meta.blobPrefixes) > 1, meta.blobNameRegex != nil, meta.blobGlob != "", len(meta.contentTypes) > 0, meta.metadataKey != "",
+				meta.excludeEmptyObjects, meta.timeWindow > 0, meta.includeVersions, meta.bucketMustExist, meta.startOffset != nil, meta.endOffset != nil:
+				return nil, fmt.Errorf("countMode %s can't be used with blobPrefix, blobPrefixes, blobNameRegex, blobGlob, contentType, metadataKey, excludeEmptyObjects, timeWindow, includeVersions, onlyNoncurrent, bucketMustExist, startOffset or endOffset", gcsCountModeMonitoring)
+			}
+		default:
+			return nil, fmt.Errorf("countMode must be %s, %s or %s, got %s", gcsCountModeObjects, gcsCountModePrefixes, gcsCountModeMonitoring, val)
+		}
+		meta.countMode = val
+	}
+
+	meta.monitoringLookback = defaultGcsMonitoringLookback
+	if val, ok := config.TriggerMetadata["monitoringLookback"]; ok && val != "" {
+		monitoringLookback, err := str2duration.ParseDuration(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing monitoringLookback")
+			return nil, fmt.Errorf("error parsing monitoringLookback: %s", err.Error())
+		}
+		if monitoringLookback <= 0 {
+			return nil, fmt.Errorf("monitoringLookback must be greater than 0")
+		}
+
+		meta.monitoringLookback = monitoringLookback
+	}
+
+	// the project of the bucket in Cloud Monitoring, the one of the credentials by default
+	meta.projectID = config.TriggerMetadata["projectID"]
+
 	if val, ok := config.TriggerMetadata["maxBucketItemsToScan"]; ok {
 		maxBucketItemsToScan, err := strconv.Atoi(val)
 		if err != nil {
 			gcsLog.Error(err, "Error parsing maxBucketItemsToScan")
 			return nil, fmt.Errorf("error parsing maxBucketItemsToScan: %s", err.Error())
 		}
+		// nothing would be listed, the bucket would always look empty
+		if maxBucketItemsToScan <= 0 {
+			return nil, fmt.Errorf("maxBucketItemsToScan must be greater than 0")
+		}
 
 		meta.maxBucketItemsToScan = maxBucketItemsToScan
 	}
 
-	auth, err := getGcpAuthorization(config, config.ResolvedEnv)
-	if err != nil {
-		return nil, err
+	if val, ok := config.TriggerMetadata["errorWhenTruncated"]; ok && val != "" {
+		errorWhenTruncated, err := strconv.ParseBool(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing errorWhenTruncated")
+			return nil, fmt.Errorf("error parsing errorWhenTruncated: %s", err.Error())
+		}
+
+		meta.errorWhenTruncated = errorWhenTruncated
+	}
+
+	if val, ok := config.TriggerMetadata["endpoint"]; ok && val != "" {
+		endpoint, err := url.ParseRequestURI(val)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("error parsing endpoint %s: must be an absolute URL", val)
+		}
+		// the JSON API lives under /storage/v1/, like on fake-gcs-server, unless the endpoint says otherwise
+		if endpoint.Path == "" || endpoint.Path == "/" {
+			endpoint.Path = "/storage/v1/"
+		}
+
+		meta.endpoint = endpoint.String()
+	}
+
+	if val, ok := config.TriggerMetadata["insecure"]; ok && val != "" {
+		insecure, err := strconv.ParseBool(val)
+		if err != nil {
+			gcsLog.Error(err, "Error parsing insecure")
+			return nil, fmt.Errorf("error parsing insecure: %s", err.Error())
+		}
+		if insecure && meta.endpoint == "" {
+			return nil, fmt.Errorf("insecure requires an endpoint")
+		}
+
+		meta.insecure = insecure
+	}
+
+	switch {
+	case meta.insecure:
+		// no credentials are sent to an insecure endpoint
+		meta.gcpAuthorization = &gcpAuthorizationMetadata{}
+	case config.AuthParams["credentialsConfig"] != "":
+		// Workload Identity Federation, for the clusters outside of Google Cloud
+		if config.TriggerMetadata["identityOwner"] == "operator" {
+			return nil, fmt.Errorf("credentialsConfig can't be used with identityOwner operator")
+		}
+		meta.gcpAuthorization = &gcpAuthorizationMetadata{podIdentityOwner: true}
+		if err := meta.gcpAuthorization.parseClientMetadata(config); err != nil {
+			return nil, err
+		}
+		if err := meta.gcpAuthorization.setCredentialsConfig(config.AuthParams["credentialsConfig"]); err != nil {
+			return nil, err
+		}
+	default:
+		auth, err := getGcpAuthorization(config, config.ResolvedEnv)
+		if err != nil {
+			return nil, err
+		}
+		meta.gcpAuthorization = auth
+	}
+
+	// apiEndpoint is the one of the API the scaler reads, the one of Cloud Monitoring with countMode monitoring
+	switch {
+	case meta.countMode == gcsCountModeMonitoring && meta.endpoint != "":
+		return nil, fmt.Errorf("countMode %s can't be used with endpoint", gcsCountModeMonitoring)
+	case meta.endpoint != "" && meta.gcpAuthorization.apiEndpoint != "":
+		return nil, fmt.Errorf("endpoint and apiEndpoint can't be both given")
 	}
-	meta.gcpAuthorization = auth
 
 	var metricName = kedautil.NormalizeString(fmt.Sprintf("gcp-storage-%s", meta.bucketName))
+	var prefixes []string
+	for _, prefix := range meta.blobPrefixes {
+		if prefix = strings.Trim(prefix, "/"); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) > 0 {
+		metricName = kedautil.NormalizeString(fmt.Sprintf("gcp-storage-%s-%s", meta.bucketName, strings.Join(prefixes, "-")))
+	}
 	meta.metricName = GenerateMetricNameWithIndex(config.ScalerIndex, metricName)
 
 	return &meta, nil
 }
 
-// IsActive checks if there are any messages in the subscription
+// parseGcsBlobPrefixes parses the comma-separated blobPrefixes. The prefixes can't overlap, the objects
+// under both would be counted twice
+func parseGcsBlobPrefixes(val string) ([]string, error) {
+	var blobPrefixes []string
+	for _, prefix := range strings.Split(val, ",") {
+		prefix = strings.TrimSpace(prefix)
+		if prefix == "" {
+			return nil, fmt.Errorf("empty prefix in blobPrefixes %s", val)
+		}
+		for _, other := range blobPrefixes {
+			if strings.HasPrefix(prefix, other) || strings.HasPrefix(other, prefix) {
+				return nil, fmt.Errorf("blobPrefixes %s and %s overlap", other, prefix)
+			}
+		}
+		blobPrefixes = append(blobPrefixes, prefix)
+	}
+	return blobPrefixes, nil
+}
+
+// IsActive checks if there are more objects, or bytes, in the bucket than the activation target, or if
+// the oldest object is older than it. The value of a full listing younger than gcsCountCacheTTL is reused,
+// otherwise when scaling on the number of objects, they are counted only until the activation target is exceeded.
+// A listing that timed out is enough once the value of the items listed before the timeout is above the activation
+// target, the value of all the items can only be higher
 func (s *gcsScaler) IsActive(ctx context.Context) (bool, error) {
-	items, err := s.getItemCount(ctx, 1)
+	var value, activationTarget int64
+	var err error
+	switch s.metadata.valueType {
+	case gcsValueTypeSize:
+		activationTarget = s.metadata.activationTargetBytes
+		value, err = s.getFullItemCount(ctx)
+	case gcsValueTypeOldestObjectAge:
+		activationTarget = int64(s.metadata.activationTargetObjectAge.Seconds())
+		value, err = s.getFullItemCount(ctx)
+	default:
+		activationTarget = s.metadata.activationTargetObjectCount
+		if items, ok := s.getCachedItemCount(); ok {
+			return items > activationTarget, nil
+		}
+		if s.metadata.countMode == gcsCountModeMonitoring {
+			// the metric is read whole, GetMetrics can reuse it
+			value, err = s.getFullItemCount(ctx)
+			break
+		}
+		value, err = s.getItemCount(ctx, int(activationTarget)+1)
+	}
+
+	// the value of a listing that timed out or was truncated is a lower bound
+	var timeoutErr *gcsListTimeoutError
+	var truncatedErr *gcsCountTruncatedError
+	if (errors.As(err, &timeoutErr) || errors.As(err, &truncatedErr)) && value > activationTarget {
+		return true, nil
+	}
 	if err != nil {
 		return false, err
 	}
 
-	return items > 0, nil
+	return value > activationTarget, nil
 }
 
 func (s *gcsScaler) Close(context.Context) error {
+	gcsCountTruncated.DeleteLabelValues(s.metadata.bucketName, s.metadata.metricName)
+	if s.monitoringClient != nil {
+		return stackDriverClients.release(s.monitoringClient)
+	}
+	if s.sharedClient != nil {
+		return gcsClients.release(s.sharedClient)
+	}
 	if s.client != nil {
 		return s.client.Close()
 	}
@@ -158,19 +796,48 @@ func (s *gcsScaler) Close(context.Context) error {
 
 // GetMetricSpecForScaling returns the metric spec for the HPA
 func (s *gcsScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	var target v2beta2.MetricTarget
+	switch s.metadata.valueType {
+	case gcsValueTypeSize:
+		target = GetMetricTarget(s.metricType, s.metadata.targetBytes)
+	case gcsValueTypeOldestObjectAge:
+		target = GetMetricTarget(s.metricType, int64(s.metadata.targetObjectAge.Seconds()))
+	default:
+		target = gcsObjectCountTarget(s.metricType, s.metadata.targetObjectCount)
+	}
 	externalMetric := &v2beta2.ExternalMetricSource{
 		Metric: v2beta2.MetricIdentifier{
 			Name: s.metadata.metricName,
 		},
-		Target: GetMetricTarget(s.metricType, s.metadata.targetObjectCount),
+		Target: target,
 	}
 	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
 	return []v2beta2.MetricSpec{metricSpec}
 }
 
-// GetMetrics returns the number of items in the bucket (up to s.metadata.maxBucketItemsToScan)
+// gcsObjectCountTarget returns the target of targetObjectCount. A whole count is rendered as an integer like before,
+// so the existing HPAs aren't updated, a fraction of an object per pod as a milli quantity
+func gcsObjectCountTarget(metricType v2beta2.MetricTargetType, count float64) v2beta2.MetricTarget {
+	if count == math.Trunc(count) {
+		return GetMetricTarget(metricType, int64(count))
+	}
+
+	target := v2beta2.MetricTarget{
+		Type: metricType,
+	}
+	targetQty := resource.NewMilliQuantity(int64(math.Round(count*1000)), resource.DecimalSI)
+	if metricType == v2beta2.AverageValueMetricType {
+		target.AverageValue = targetQty
+	} else {
+		target.Value = targetQty
+	}
+	return target
+}
+
+// GetMetrics returns the number of items in the bucket, their total size or the age of the oldest one
+// (up to s.metadata.maxBucketItemsToScan)
 func (s *gcsScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
-	items, err := s.getItemCount(ctx, s.metadata.maxBucketItemsToScan)
+	items, err := s.getFullItemCount(ctx)
 	if err != nil {
 		return []external_metrics.ExternalMetricValue{}, err
 	}
@@ -184,34 +851,456 @@ func (s *gcsScaler) GetMetrics(ctx context.Context, metricName string, metricSel
 	return append([]external_metrics.ExternalMetricValue{}, metric), nil
 }
 
-// getItemCount gets the number of items in the bucket, up to maxCount
+// getFullItemCount gets the value of the items in the bucket up to maxBucketItemsToScan, reusing the one of the
+// last full listing while it is younger than gcsCountCacheTTL, unless disableCountCache is set. Concurrent calls
+// wait for the same listing
+func (s *gcsScaler) getFullItemCount(ctx context.Context) (int64, error) {
+	if s.metadata.disableCountCache {
+		return s.getItemCount(ctx, s.metadata.maxBucketItemsToScan)
+	}
+
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	if !s.cachedAt.IsZero() && time.Since(s.cachedAt) < gcsCountCacheTTL {
+		gcsLog.V(1).Info("Reusing the value of the last listing", "bucketName", s.metadata.bucketName, "value", s.cachedValue, "listedAt", s.cachedAt)
+		return s.cachedValue, nil
+	}
+
+	value, err := s.getItemCount(ctx, s.metadata.maxBucketItemsToScan)
+	if err != nil {
+		return value, err
+	}
+	s.cachedValue = value
+	s.cachedAt = time.Now()
+	return value, nil
+}
+
+// getCachedItemCount returns the value of the last full listing if it is younger than gcsCountCacheTTL
+func (s *gcsScaler) getCachedItemCount() (int64, bool) {
+	if s.metadata.disableCountCache {
+		return 0, false
+	}
+
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+	if s.cachedAt.IsZero() || time.Since(s.cachedAt) >= gcsCountCacheTTL {
+		return 0, false
+	}
+	return s.cachedValue, true
+}
+
+// newGcsQuery creates the query listing the objects of the bucket under prefix to count, with the startOffset and
+// endOffset rendered at now
+func newGcsQuery(meta *gcsMetadata, prefix string, now time.Time) (*storage.Query, error) {
+	query := &storage.Query{Prefix: prefix, Delimiter: meta.blobDelimiter, MatchGlob: meta.blobGlob, Versions: meta.includeVersions}
+	var err error
+	if query.StartOffset, err = renderGcsOffset(meta.startOffset, now); err != nil {
+		return nil, err
+	}
+	if query.EndOffset, err = renderGcsOffset(meta.endOffset, now); err != nil {
+		return nil, err
+	}
+	attrs := []string{"Name"}
+	if meta.valueType == gcsValueTypeSize || meta.excludeEmptyObjects {
+		attrs = append(attrs, "Size")
+	}
+	if meta.valueType == gcsValueTypeOldestObjectAge {
+		attrs = append(attrs, "Created")
+	}
+	if meta.timeWindow > 0 {
+		attrs = append(attrs, "Updated")
+	}
+	if meta.onlyNoncurrent {
+		attrs = append(attrs, "Deleted")
+	}
+	if len(meta.contentTypes) > 0 {
+		attrs = append(attrs, "ContentType")
+	}
+	if meta.metadataKey != "" {
+		attrs = append(attrs, "Metadata")
+	}
+	err = query.SetAttrSelection(attrs)
+	if err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// gcsOffsetTemplateData is the data of the startOffset and endOffset templates, the dates are in UTC
+type gcsOffsetTemplateData struct {
+	Today     string
+	Yesterday string
+}
+
+// renderGcsOffset renders the startOffset or endOffset template at now, without template the offset is empty
+func renderGcsOffset(tmpl *template.Template, now time.Time) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	now = now.UTC()
+	var offset strings.Builder
+	if err := tmpl.Execute(&offset, gcsOffsetTemplateData{
+		Today:     now.Format(gcsOffsetDateLayout),
+		Yesterday: now.AddDate(0, 0, -1).Format(gcsOffsetDateLayout),
+	}); err != nil {
+		return "", fmt.Errorf("error rendering %s: %s", tmpl.Name(), err)
+	}
+	return offset.String(), nil
+}
+
+// gcsOffsetTemplateText returns the text of the startOffset or endOffset template for the logs
+func gcsOffsetTemplateText(tmpl *template.Template) string {
+	if tmpl == nil {
+		return ""
+	}
+	return tmpl.Root.String()
+}
+
+// getItemCount gets the number of items in the bucket, up to maxCount. When scaling on the size,
+// it gets the total size in bytes of these items instead, and when scaling on the age of the oldest
+// object, the age in seconds of the oldest of these items, 0 without items.
+// Only the items matching blobNameRegex, with one of the contentType and the metadataValue of the metadataKey when set,
+// are taken into account, but at most maxBucketItemsToScan items are listed, whether they match or not. The zero-byte
+// objects ending with / that tools create to simulate folders are never taken into account, and neither are the other
+// empty objects with excludeEmptyObjects.
+// With a timeWindow, only the items updated within it are taken into account, GCS can't filter them when listing
+// so the items outside of it use up maxBucketItemsToScan too.
+// With several blobPrefixes, the items under each of them are listed in turn, maxCount and maxBucketItemsToScan
+// being shared by all of them.
+// With includeVersions, every version of the objects is an item, with onlyNoncurrent only the noncurrent ones are.
+// The listing stops after listTimeout, the value of the items listed so far is then returned with a *gcsListTimeoutError.
+// A page failing with a transient error, e.g. a 429 or 503 response, is listed again up to maxRetries times with a
+// backoff, the listing resuming from that page.
+// With countMode prefixes, the items are the prefixes up to the blobDelimiter, "/" by default, of the objects listed,
+// e.g. a folder per customer holding at least one object, and blobNameRegex matches them instead of the object names.
+// The objects are listed a page at a time, no page is fetched once the limits are reached.
+// With a blobGlob, GCS only lists the objects matching it.
+// With countMode monitoring, no object is listed, the count is the latest one of Cloud Monitoring, not limited by maxCount
 func (s *gcsScaler) getItemCount(ctx context.Context, maxCount int) (int64, error) {
-	query := &storage.Query{Prefix: ""}
-	err := query.SetAttrSelection([]string{"Name"})
+	ctx, cancel := context.WithTimeout(ctx, s.metadata.listTimeout)
+	defer cancel()
+
+	if s.metadata.countMode == gcsCountModeMonitoring {
+		return s.getMonitoredObjectCount(ctx)
+	}
+
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+	queries := make(map[string]*storage.Query, len(s.metadata.blobPrefixes))
+	for _, prefix := range s.metadata.blobPrefixes {
+		query, err := newGcsQuery(s.metadata, prefix, now)
+		if err != nil {
+			gcsLog.Error(err, "failed to create the query")
+			return 0, err
+		}
+		queries[prefix] = query
+	}
+
+	return s.countItems(ctx, func(prefix string, pageSize int, pageToken string) gcsObjectPager {
+		return iterator.NewPager(s.bucket.Objects(ctx, queries[prefix]), pageSize, pageToken)
+	}, maxCount)
+}
+
+// getMonitoredObjectCount gets the number of objects in the bucket from the latest points of the object count metric
+// within monitoringLookback, summed over the storage classes. The metric is measured once a day, the count doesn't
+// follow the objects added or deleted since
+func (s *gcsScaler) getMonitoredObjectCount(ctx context.Context) (int64, error) {
+	filter := fmt.Sprintf(`metric.type="%s" AND resource.type="gcs_bucket" AND resource.labels.bucket_name="%s"`, gcsObjectCountMetricType, s.metadata.bucketName)
+	count, err := s.monitoringClient.GetLatestMetricsSum(ctx, filter, s.metadata.projectID, s.metadata.monitoringLookback)
 	if err != nil {
-		gcsLog.Error(err, "failed to set attribute selection")
-		return 0, err
+		gcsLog.Error(err, "failed to get the object count of bucket "+s.metadata.bucketName+" from Cloud Monitoring")
+		return 0, fmt.Errorf("error getting the object count of bucket %s from Cloud Monitoring: %s", s.metadata.bucketName, err)
 	}
 
-	it := s.bucket.Objects(ctx, query)
-	var count int64
+	gcsLog.V(1).Info(fmt.Sprintf("Got the count of %d objects from Cloud Monitoring", count))
+	return count, nil
+}
 
-	for count < int64(maxCount) {
-		_, err := it.Next()
-		if err == iterator.Done {
+// gcsListTimeoutError is returned with the value of the items listed before the listing of the bucket timed out,
+// that value is a lower bound of the one of all the items
+type gcsListTimeoutError struct {
+	bucketName string
+	timeout    time.Duration
+	scanned    int
+	err        error
+}
+
+func (e *gcsListTimeoutError) Error() string {
+	return fmt.Sprintf("listing bucket %s timed out after %s with %d items scanned: %s", e.bucketName, e.timeout, e.scanned, e.err)
+}
+
+func (e *gcsListTimeoutError) Unwrap() error {
+	return e.err
+}
+
+// gcsObjectPager lists the objects of a bucket a page at a time, like an *iterator.Pager over a *storage.ObjectIterator.
+// NextPage appends the objects of the next page to the *[]*storage.ObjectAttrs and returns an empty token after the last one
+type gcsObjectPager interface {
+	NextPage(slicep interface{}) (nextPageToken string, err error)
+}
+
+// countItems counts the items returned by the pagers that list returns for each of the blobPrefixes, up to maxCount
+// in total, and returns their value for the valueType. The pages hold at most gcsListPageSize items and no more than
+// the items left to scan. The next page, or prefix, isn't listed once maxCount items are counted or maxBucketItemsToScan
+// items are listed. A missing bucket counts as empty, unless bucketMustExist is set. GCS responds 404 for a missing
+// bucket but 403 for an existing one the service account can't list, the latter is always an error. A pager can't be
+// used once it failed, after a transient error list returns a new one starting from the page token of the page that failed
+func (s *gcsScaler) countItems(ctx context.Context, list func(prefix string, pageSize int, pageToken string) gcsObjectPager, maxCount int) (int64, error) {
+	var count, size int64
+	var oldest time.Time
+	var scanned, skipped, placeholders, outsideWindow, live, otherContentType, otherMetadata int
+	var windowStart time.Time
+	if s.metadata.timeWindow > 0 {
+		windowStart = time.Now().Add(-s.metadata.timeWindow)
+	}
+
+	// truncated is set when items are left to list once maxBucketItemsToScan items are scanned
+	var truncated bool
+	for _, prefix := range s.metadata.blobPrefixes {
+		if count >= int64(maxCount) || scanned >= s.metadata.maxBucketItemsToScan {
+			gcsLog.V(1).Info("Reached the limit, the remaining prefixes aren't listed", "bucketName", s.metadata.bucketName, "nextPrefix", prefix)
+			truncated = scanned >= s.metadata.maxBucketItemsToScan
 			break
 		}
-		if err != nil {
-			if strings.Contains(err.Error(), "bucket doesn't exist") {
-				gcsLog.Info("Bucket " + s.metadata.bucketName + " doesn't exist")
-				return 0, nil
+		pageSize := s.metadata.maxBucketItemsToScan - scanned
+		if pageSize > gcsListPageSize {
+			pageSize = gcsListPageSize
+		}
+		pager := list(prefix, pageSize, "")
+		var pageToken string
+		var retries int
+		backoff := gcsRetryBackoff
+		for count < int64(maxCount) && scanned < s.metadata.maxBucketItemsToScan {
+			var page []*storage.ObjectAttrs
+			nextPageToken, err := pager.NextPage(&page)
+			if err != nil && retries < s.metadata.maxRetries && isGcsRetryableError(err) {
+				retries++
+				gcsLog.V(1).Info("Listing the page of the bucket again after a transient error", "bucketName", s.metadata.bucketName,
+					"retry", retries, "maxRetries", s.metadata.maxRetries, "error", err.Error())
+				if err = sleepGcsBackoff(ctx, backoff.Pause()); err == nil {
+					pager = list(prefix, pageSize, pageToken)
+					continue
+				}
+			}
+			if err != nil {
+				var apiErr *googleapi.Error
+				switch {
+				case errors.Is(err, storage.ErrBucketNotExist), errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound:
+					if !s.metadata.bucketMustExist {
+						gcsLog.Info("Bucket " + s.metadata.bucketName + " doesn't exist")
+						return 0, nil
+					}
+					err = fmt.Errorf("bucket %s doesn't exist, bucketMustExist is set: %w", s.metadata.bucketName, err)
+				case errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Message), "requester pays"):
+					err = fmt.Errorf("bucket %s is a requester-pays bucket, set userProject to the project billed for its listings: %s", s.metadata.bucketName, err)
+				case errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest && s.metadata.blobGlob != "":
+					err = fmt.Errorf("GCS rejected the listing of bucket %s, check that blobGlob %s is a valid glob: %s", s.metadata.bucketName, s.metadata.blobGlob, err)
+				case errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden:
+					err = fmt.Errorf("permission denied listing the objects of bucket %s, the service account needs the storage.objects.list permission, e.g. with the roles/storage.objectViewer role: %s", s.metadata.bucketName, err)
+				case errors.Is(err, context.DeadlineExceeded):
+					gcsLog.Info("Timed out listing the bucket, the value of the items scanned so far is returned with an error",
+						"bucketName", s.metadata.bucketName, "listTimeout", s.metadata.listTimeout, "scanned", scanned, "counted", count)
+					return s.itemValue(count, size, oldest), &gcsListTimeoutError{bucketName: s.metadata.bucketName, timeout: s.metadata.listTimeout, scanned: scanned, err: err}
+				}
+				gcsLog.Error(err, "failed to enumerate items in bucket "+s.metadata.bucketName)
+				return s.itemValue(count, size, oldest), err
+			}
+			for _, attrs := range page {
+				if count >= int64(maxCount) || scanned >= s.metadata.maxBucketItemsToScan {
+					truncated = scanned >= s.metadata.maxBucketItemsToScan
+					break
+				}
+				// with a delimiter, the pager also returns the prefixes of the nested objects, they aren't objects
+				isPrefix := attrs.Name == "" && attrs.Prefix != ""
+				if s.metadata.countMode == gcsCountModePrefixes {
+					// the objects beside the prefixes use up maxBucketItemsToScan too
+					scanned++
+					if !isPrefix {
+						continue
+					}
+					if s.metadata.blobNameRegex != nil && !s.metadata.blobNameRegex.MatchString(attrs.Prefix) {
+						skipped++
+						continue
+					}
+					count++
+					continue
+				}
+				if isPrefix {
+					continue
+				}
+				scanned++
+				if strings.HasSuffix(attrs.Name, "/") || (s.metadata.excludeEmptyObjects && attrs.Size == 0) {
+					placeholders++
+					gcsLog.V(1).Info("Skipping folder placeholder or empty object", "bucketName", s.metadata.bucketName, "name", attrs.Name, "skippedSoFar", placeholders)
+					continue
+				}
+				// a noncurrent version has the time it was replaced or deleted
+				if s.metadata.onlyNoncurrent && attrs.Deleted.IsZero() {
+					live++
+					continue
+				}
+				if !windowStart.IsZero() && attrs.Updated.Before(windowStart) {
+					outsideWindow++
+					continue
+				}
+				if s.metadata.blobNameRegex != nil && !s.metadata.blobNameRegex.MatchString(attrs.Name) {
+					skipped++
+					continue
+				}
+				if len(s.metadata.contentTypes) > 0 && !hasGcsContentType(attrs, s.metadata.contentTypes) {
+					otherContentType++
+					continue
+				}
+				if s.metadata.metadataKey != "" && !hasGcsMetadata(attrs, s.metadata.metadataKey, s.metadata.metadataValue) {
+					otherMetadata++
+					continue
+				}
+				count++
+				size += attrs.Size
+				if oldest.IsZero() || attrs.Created.Before(oldest) {
+					oldest = attrs.Created
+				}
+			}
+			if nextPageToken == "" {
+				break
 			}
-			gcsLog.Error(err, "failed to enumerate items in bucket "+s.metadata.bucketName)
-			return count, err
+			truncated = truncated || scanned >= s.metadata.maxBucketItemsToScan
+			pageToken = nextPageToken
+			retries = 0
+			backoff = gcsRetryBackoff
 		}
-		count++
 	}
 
-	gcsLog.V(1).Info(fmt.Sprintf("Counted %d items with a limit of %d", count, maxCount))
-	return count, nil
+	if outsideWindow > 0 && float64(outsideWindow) >= gcsTimeWindowDroppedRatioToLog*float64(scanned) {
+		gcsLog.Info("Most of the scanned items were updated before the timeWindow, narrow the listing with blobPrefix or blobPrefixes to scan fewer of them",
+			"bucketName", s.metadata.bucketName, "timeWindow", s.metadata.timeWindow, "scanned", scanned, "outsideWindow", outsideWindow)
+	}
+
+	if scanned >= s.metadata.maxBucketItemsToScan && live > 0 {
+		gcsLog.Info("Reached maxBucketItemsToScan before the end of the bucket, the live objects used up part of it so the number of noncurrent versions may be too low",
+			"bucketName", s.metadata.bucketName, "maxBucketItemsToScan", s.metadata.maxBucketItemsToScan, "live", live)
+	}
+
+	if scanned >= s.metadata.maxBucketItemsToScan && skipped > 0 {
+		gcsLog.Info("Reached maxBucketItemsToScan before the end of the bucket, the items not matching blobNameRegex used up part of it so the value may be too low",
+			"bucketName", s.metadata.bucketName, "maxBucketItemsToScan", s.metadata.maxBucketItemsToScan, "skipped", skipped)
+	}
+
+	if otherContentType > 0 {
+		gcsLog.V(1).Info("Filtered out the items of other content types, they used up part of maxBucketItemsToScan",
+			"bucketName", s.metadata.bucketName, "contentType", s.metadata.contentTypes, "scanned", scanned,
+			"filtered", otherContentType, "filteredRatio", float64(otherContentType)/float64(scanned))
+	}
+
+	if otherMetadata > 0 {
+		gcsLog.V(1).Info("Filtered out the items without the metadata, they used up part of maxBucketItemsToScan",
+			"bucketName", s.metadata.bucketName, "metadataKey", s.metadata.metadataKey, "metadataValue", s.metadata.metadataValue,
+			"scanned", scanned, "filtered", otherMetadata, "filteredRatio", float64(otherMetadata)/float64(scanned))
+	}
+
+	switch s.metadata.valueType {
+	case gcsValueTypeSize:
+		gcsLog.V(1).Info(fmt.Sprintf("Counted %d bytes in %d items with a limit of %d", size, count, maxCount))
+	case gcsValueTypeOldestObjectAge:
+		gcsLog.V(1).Info(fmt.Sprintf("Found the oldest of %d items created at %s with a limit of %d", count, oldest, maxCount))
+	default:
+		if s.metadata.countMode == gcsCountModePrefixes {
+			gcsLog.V(1).Info(fmt.Sprintf("Counted %d prefixes with a limit of %d", count, maxCount))
+			break
+		}
+		gcsLog.V(1).Info(fmt.Sprintf("Counted %d items with a limit of %d", count, maxCount))
+	}
+	// only the full counts tell if the bucket holds more items than maxBucketItemsToScan, not e.g. the ones of IsActive
+	if maxCount >= s.metadata.maxBucketItemsToScan {
+		return s.truncatedItemValue(s.itemValue(count, size, oldest), truncated)
+	}
+	return s.itemValue(count, size, oldest), nil
+}
+
+// gcsCountTruncatedError is returned with errorWhenTruncated with the value of the items listed before the listing
+// stopped at maxBucketItemsToScan, that value is a lower bound of the one of all the items
+type gcsCountTruncatedError struct {
+	bucketName           string
+	maxBucketItemsToScan int
+}
+
+func (e *gcsCountTruncatedError) Error() string {
+	return fmt.Sprintf("bucket %s holds more than the %d items of maxBucketItemsToScan, the count is truncated", e.bucketName, e.maxBucketItemsToScan)
+}
+
+// truncatedItemValue reports whether the value of a full count is truncated, in the count_truncated gauge and, the first
+// time, in the logs. With errorWhenTruncated, the value of a truncated count is returned with a *gcsCountTruncatedError
+func (s *gcsScaler) truncatedItemValue(value int64, truncated bool) (int64, error) {
+	if !truncated {
+		gcsCountTruncated.WithLabelValues(s.metadata.bucketName, s.metadata.metricName).Set(0)
+		return value, nil
+	}
+
+	gcsCountTruncated.WithLabelValues(s.metadata.bucketName, s.metadata.metricName).Set(1)
+	s.logTruncation.Do(func() {
+		gcsLog.Info("The bucket holds more items than maxBucketItemsToScan, the value is truncated and doesn't follow the backlog above it, raise maxBucketItemsToScan or narrow the listing",
+			"bucketName", s.metadata.bucketName, "maxBucketItemsToScan", s.metadata.maxBucketItemsToScan, "value", value)
+	})
+	if s.metadata.errorWhenTruncated {
+		return value, &gcsCountTruncatedError{bucketName: s.metadata.bucketName, maxBucketItemsToScan: s.metadata.maxBucketItemsToScan}
+	}
+	return value, nil
+}
+
+// isGcsRetryableError checks if the listing failed with a transient error of GCS or of the connection to it
+func isGcsRetryableError(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return gcsRetryableCodes[apiErr.Code]
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// sleepGcsBackoff waits for the backoff before a retry, it returns the error of ctx when it is done first
+func sleepGcsBackoff(ctx context.Context, backoff time.Duration) error {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// hasGcsContentType checks if the content type of the object is exactly one of contentTypes
+func hasGcsContentType(attrs *storage.ObjectAttrs, contentTypes []string) bool {
+	for _, contentType := range contentTypes {
+		if attrs.ContentType == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGcsMetadata checks if the object has the custom metadata, the keys of which GCS may keep in any case
+func hasGcsMetadata(attrs *storage.ObjectAttrs, key, value string) bool {
+	for k, v := range attrs.Metadata {
+		if strings.EqualFold(k, key) && v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// itemValue returns the value of the items listed so far for the valueType
+func (s *gcsScaler) itemValue(count, size int64, oldest time.Time) int64 {
+	switch s.metadata.valueType {
+	case gcsValueTypeSize:
+		return size
+	case gcsValueTypeOldestObjectAge:
+		if oldest.IsZero() {
+			return 0
+		}
+		return int64(time.Since(oldest).Seconds())
+	default:
+		return count
+	}
 }
@@ -0,0 +1,244 @@
+package scalers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/sync/singleflight"
+	option "google.golang.org/api/option"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// gcpTokenSourceIdleTimeout is how long the token source of credentials no scaler uses anymore, e.g. the rotated key
+// of a TriggerAuthentication, is kept in the cache
+const gcpTokenSourceIdleTimeout = time.Hour
+
+// gcpTokenSourceKey identifies the token sources that can be shared by the GCP scalers, the fingerprint of the
+// credentials changes when the credentials of a TriggerAuthentication are rotated
+type gcpTokenSourceKey struct {
+	credentialsFingerprint string
+	targetServiceAccount   string
+}
+
+// cachedGcpTokenSource records when the tokens of a cached token source were last used
+type cachedGcpTokenSource struct {
+	oauth2.TokenSource
+	lastUsed int64
+}
+
+func (s *cachedGcpTokenSource) Token() (*oauth2.Token, error) {
+	atomic.StoreInt64(&s.lastUsed, time.Now().UnixNano())
+	return s.TokenSource.Token()
+}
+
+// gcpTokenSourceCache shares the OAuth token sources between the GCP scalers using the same credentials, so that
+// their tokens are reused until they expire rather than requested again from the metadata server or the token
+// endpoint for every scaler
+type gcpTokenSourceCache struct {
+	lock    sync.Mutex
+	sources map[gcpTokenSourceKey]*cachedGcpTokenSource
+	// creating creates the token sources missing in the cache once per key
+	creating singleflight.Group
+
+	// newTokenSource creates the token source of a key missing in the cache, base is the token source of the
+	// credentials impersonating the target service account, if any
+	newTokenSource func(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata, base oauth2.TokenSource) (oauth2.TokenSource, error)
+}
+
+var gcpTokenSources = newGcpTokenSourceCache(newGcpTokenSource)
+
+var gcpTokenSourceLog = logf.Log.WithName("gcp_token_source")
+
+func newGcpTokenSourceCache(newTokenSource func(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata, base oauth2.TokenSource) (oauth2.TokenSource, error)) *gcpTokenSourceCache {
+	return &gcpTokenSourceCache{
+		sources:        map[gcpTokenSourceKey]*cachedGcpTokenSource{},
+		newTokenSource: newTokenSource,
+	}
+}
+
+// newGcpTokenSource creates the token source of the credentials of the authorization. The tokens have the
+// cloud-platform scope as they are shared by the clients of all the GCP APIs
+func newGcpTokenSource(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata, base oauth2.TokenSource) (oauth2.TokenSource, error) {
+	if base != nil {
+		return newGcpImpersonatedTokenSource(ctx, gcpAuthorization.targetServiceAccount, []string{gcpCloudPlatformScope}, []option.ClientOption{option.WithTokenSource(base)})
+	}
+
+	// the token sources request the tokens for the life of the scalers, they mustn't use ctx
+	var credentialsJSON []byte
+	switch {
+	case gcpAuthorization.podIdentityProviderEnabled, gcpAuthorization.hasNoCredentials():
+		tokenSource, err := google.DefaultTokenSource(context.Background(), gcpCloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the default credentials: %s", err)
+		}
+		return tokenSource, nil
+	case gcpAuthorization.credentialsConfig != "":
+		credentialsJSON = []byte(gcpAuthorization.credentialsConfig)
+	default:
+		credentialsJSON = []byte(gcpAuthorization.GoogleApplicationCredentials)
+	}
+
+	credentials, err := google.CredentialsFromJSON(context.Background(), credentialsJSON, gcpCloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the credentials: %s", err)
+	}
+	return oauth2.ReuseTokenSource(nil, credentials.TokenSource), nil
+}
+
+// getGcpTokenSourceKey returns the cache key of the credentials, all the scalers using the identity of the pod or of
+// the KEDA operator share the same token source
+func getGcpTokenSourceKey(gcpAuthorization *gcpAuthorizationMetadata) gcpTokenSourceKey {
+	key := gcpTokenSourceKey{targetServiceAccount: gcpAuthorization.targetServiceAccount}
+
+	var credentials []byte
+	switch {
+	case gcpAuthorization.podIdentityProviderEnabled, gcpAuthorization.hasNoCredentials():
+		key.credentialsFingerprint = "podIdentity"
+		return key
+	case gcpAuthorization.credentialsConfig != "":
+		credentials = []byte(gcpAuthorization.credentialsConfig)
+	default:
+		credentials = []byte(gcpAuthorization.GoogleApplicationCredentials)
+	}
+	fingerprint := sha256.Sum256(credentials)
+	key.credentialsFingerprint = hex.EncodeToString(fingerprint[:])
+	return key
+}
+
+// gcpCredentialsFileTokenSource uses the shared token source of the content of a credentials file, and reads the file
+// again when it changes, e.g. when the key mounted from a Secret is rotated, so that the scalers follow the rotation
+type gcpCredentialsFileTokenSource struct {
+	cache            *gcpTokenSourceCache
+	gcpAuthorization gcpAuthorizationMetadata
+
+	lock    sync.Mutex
+	modTime time.Time
+	current oauth2.TokenSource
+}
+
+func (s *gcpCredentialsFileTokenSource) Token() (*oauth2.Token, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.reloadLocked(context.Background()); err != nil {
+		// e.g. the file is being replaced, the token source of the previous content is used meanwhile
+		gcpTokenSourceLog.Error(err, "error reading the credentials file again", "file", s.gcpAuthorization.GoogleApplicationCredentialsFile)
+	}
+	return s.current.Token()
+}
+
+// reloadLocked reads the file if it changed since it was last read, and gets the token source of its content
+func (s *gcpCredentialsFileTokenSource) reloadLocked(ctx context.Context) error {
+	path := s.gcpAuthorization.GoogleApplicationCredentialsFile
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error reading the credentials file: %s", err)
+	}
+	if s.current != nil && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading the credentials file: %s", err)
+	}
+	withContent := s.gcpAuthorization
+	withContent.GoogleApplicationCredentialsFile = ""
+	if getGcpCredentialsType(string(content)) == gcpExternalAccountType {
+		withContent.credentialsConfig = string(content)
+	} else {
+		withContent.GoogleApplicationCredentials = string(content)
+	}
+	current, err := s.cache.get(ctx, &withContent)
+	if err != nil {
+		return err
+	}
+	s.current = current
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// get returns the token source shared for the credentials, creating it if needed
+func (c *gcpTokenSourceCache) get(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (oauth2.TokenSource, error) {
+	if gcpAuthorization.GoogleApplicationCredentialsFile != "" && !gcpAuthorization.podIdentityProviderEnabled {
+		source := &gcpCredentialsFileTokenSource{cache: c, gcpAuthorization: *gcpAuthorization}
+		if err := source.reloadLocked(ctx); err != nil {
+			return nil, err
+		}
+		return source, nil
+	}
+
+	c.lock.Lock()
+	idleSince := time.Now().Add(-gcpTokenSourceIdleTimeout).UnixNano()
+	for key, source := range c.sources {
+		if atomic.LoadInt64(&source.lastUsed) < idleSince {
+			delete(c.sources, key)
+		}
+	}
+	c.lock.Unlock()
+
+	return c.getOrCreate(ctx, gcpAuthorization)
+}
+
+// getOrCreate returns the cached token source of the credentials or creates it. The token source is created without
+// holding the lock of the cache, impersonating a service account calls the IAM API, and the concurrent callers
+// of a same key wait for a single creation
+func (c *gcpTokenSourceCache) getOrCreate(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (oauth2.TokenSource, error) {
+	key := getGcpTokenSourceKey(gcpAuthorization)
+	if source := c.lookup(key); source != nil {
+		return source, nil
+	}
+
+	source, err, _ := c.creating.Do(key.credentialsFingerprint+"/"+key.targetServiceAccount, func() (interface{}, error) {
+		// the token source may have been created while the caller missed it
+		if source := c.lookup(key); source != nil {
+			return source, nil
+		}
+
+		// the target service account is impersonated with the shared token source of the credentials
+		var base oauth2.TokenSource
+		if gcpAuthorization.targetServiceAccount != "" {
+			withoutTarget := *gcpAuthorization
+			withoutTarget.targetServiceAccount = ""
+			var err error
+			if base, err = c.getOrCreate(ctx, &withoutTarget); err != nil {
+				return nil, err
+			}
+		}
+
+		tokenSource, err := c.newTokenSource(ctx, gcpAuthorization, base)
+		if err != nil {
+			return nil, err
+		}
+		source := &cachedGcpTokenSource{TokenSource: tokenSource, lastUsed: time.Now().UnixNano()}
+		c.lock.Lock()
+		c.sources[key] = source
+		c.lock.Unlock()
+		return source, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return source.(*cachedGcpTokenSource), nil
+}
+
+// lookup returns the cached token source of the key and records it's used, nil when it isn't cached
+func (c *gcpTokenSourceCache) lookup(key gcpTokenSourceKey) *cachedGcpTokenSource {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	source, ok := c.sources[key]
+	if !ok {
+		return nil
+	}
+	atomic.StoreInt64(&source.lastUsed, time.Now().UnixNano())
+	return source
+}
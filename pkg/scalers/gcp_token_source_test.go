@@ -0,0 +1,223 @@
+package scalers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// countingTokenSource counts the tokens requested from the metadata server or the token endpoint
+type countingTokenSource struct {
+	tokens int32
+}
+
+func (s *countingTokenSource) Token() (*oauth2.Token, error) {
+	atomic.AddInt32(&s.tokens, 1)
+	return &oauth2.Token{AccessToken: "test-token", Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+// useTestGcpTokenSources replaces the shared token source cache for the test, the token sources it creates count
+// the tokens requested in tokens
+func useTestGcpTokenSources(t *testing.T) (cache *gcpTokenSourceCache, created *int32, tokens *countingTokenSource) {
+	previous := gcpTokenSources
+	t.Cleanup(func() { gcpTokenSources = previous })
+
+	created = new(int32)
+	tokens = &countingTokenSource{}
+	gcpTokenSources = newGcpTokenSourceCache(func(context.Context, *gcpAuthorizationMetadata, oauth2.TokenSource) (oauth2.TokenSource, error) {
+		atomic.AddInt32(created, 1)
+		return oauth2.ReuseTokenSource(nil, tokens), nil
+	})
+	return gcpTokenSources, created, tokens
+}
+
+func TestGcpTokenSourceCacheSharesTokenSources(t *testing.T) {
+	cache, created, _ := useTestGcpTokenSources(t)
+	get := func(auth *gcpAuthorizationMetadata) oauth2.TokenSource {
+		tokenSource, err := cache.get(context.Background(), auth)
+		assert.NoError(t, err)
+		return tokenSource
+	}
+
+	first := get(&gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverCredentials})
+	assert.Same(t, first, get(&gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverCredentials}))
+	assert.Equal(t, int32(1), *created)
+
+	// rotated credentials don't reuse the token source of the previous ones
+	assert.NotSame(t, first, get(&gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverRotatedCredentials}))
+	assert.Equal(t, int32(2), *created)
+
+	// the identities of the pod and of the operator are the default credentials
+	podIdentity := get(&gcpAuthorizationMetadata{podIdentityProviderEnabled: true})
+	assert.Same(t, podIdentity, get(&gcpAuthorizationMetadata{}))
+	assert.Equal(t, int32(3), *created)
+
+	// the credentials in a file use the token source of their content
+	file := filepath.Join(t.TempDir(), "credentials.json")
+	assert.NoError(t, os.WriteFile(file, []byte(testStackDriverCredentials), 0600))
+	assert.Same(t, first, get(&gcpAuthorizationMetadata{GoogleApplicationCredentialsFile: file}).(*gcpCredentialsFileTokenSource).current)
+	assert.Equal(t, int32(3), *created)
+
+	// the target service account is impersonated with the shared token source of the credentials
+	impersonated := get(&gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverCredentials, targetServiceAccount: testGcpTargetServiceAccount})
+	assert.NotSame(t, first, impersonated)
+	assert.Equal(t, int32(4), *created)
+	assert.Same(t, impersonated, get(&gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverCredentials, targetServiceAccount: testGcpTargetServiceAccount}))
+	assert.Equal(t, int32(4), *created)
+}
+
+func TestGcpTokenSourceCacheFollowsRotatedCredentialsFile(t *testing.T) {
+	cache, created, _ := useTestGcpTokenSources(t)
+	file := filepath.Join(t.TempDir(), "credentials.json")
+	assert.NoError(t, os.WriteFile(file, []byte(testStackDriverCredentials), 0600))
+
+	tokenSource, err := cache.get(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentialsFile: file})
+	assert.NoError(t, err)
+	fileTokenSource := tokenSource.(*gcpCredentialsFileTokenSource)
+	first := fileTokenSource.current
+	_, err = tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Same(t, first, fileTokenSource.current)
+	assert.Equal(t, int32(1), *created)
+
+	// the key mounted from the Secret is rotated
+	assert.NoError(t, os.WriteFile(file, []byte(testStackDriverRotatedCredentials), 0600))
+	assert.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Minute)))
+	_, err = tokenSource.Token()
+	assert.NoError(t, err)
+	assert.NotSame(t, first, fileTokenSource.current)
+	assert.Equal(t, int32(2), *created)
+	rotated, err := cache.get(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverRotatedCredentials})
+	assert.NoError(t, err)
+	assert.Same(t, rotated, fileTokenSource.current)
+
+	// the previous credentials are used while the file is replaced
+	assert.NoError(t, os.Remove(file))
+	_, err = tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Same(t, rotated, fileTokenSource.current)
+}
+
+func TestGcpTokenSourceCacheEvictsIdleTokenSources(t *testing.T) {
+	cache, created, _ := useTestGcpTokenSources(t)
+	auth := &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverCredentials}
+
+	first, err := cache.get(context.Background(), auth)
+	assert.NoError(t, err)
+	rotated, err := cache.get(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverRotatedCredentials})
+	assert.NoError(t, err)
+
+	// the token source of the previous credentials isn't used anymore
+	atomic.StoreInt64(&first.(*cachedGcpTokenSource).lastUsed, time.Now().Add(-2*gcpTokenSourceIdleTimeout).UnixNano())
+	_, err = rotated.Token()
+	assert.NoError(t, err)
+	again, err := cache.get(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverRotatedCredentials})
+	assert.NoError(t, err)
+	assert.Same(t, rotated, again)
+	assert.Len(t, cache.sources, 1)
+
+	second, err := cache.get(context.Background(), auth)
+	assert.NoError(t, err)
+	assert.NotSame(t, first, second)
+	assert.Equal(t, int32(3), *created)
+}
+
+func TestGcpTokenSourceCacheErrors(t *testing.T) {
+	cache := newGcpTokenSourceCache(func(context.Context, *gcpAuthorizationMetadata, oauth2.TokenSource) (oauth2.TokenSource, error) {
+		return nil, errors.New("invalid credentials")
+	})
+
+	_, err := cache.get(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverCredentials})
+	assert.EqualError(t, err, "invalid credentials")
+	_, err = cache.get(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentialsFile: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+	assert.Empty(t, cache.sources)
+}
+
+func TestGcpTokenSourceCacheConcurrency(t *testing.T) {
+	_, created, tokens := useTestGcpTokenSources(t)
+	server := newFakeGcsServer(t, testGcsObjects)
+	defer server.Close()
+	meta := &gcsMetadata{bucketName: "test-bucket", endpoint: server.URL + "/storage/v1/", gcpAuthorization: &gcpAuthorizationMetadata{
+		GoogleApplicationCredentials: testStackDriverCredentials,
+		podIdentityOwner:             true,
+	}}
+
+	// the storage and Cloud Monitoring clients of the scalers built in parallel share one token source, and its
+	// token until it expires
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 1 {
+				client, err := NewStackDriverClient(context.Background(), testStackDriverCredentials)
+				assert.NoError(t, err)
+				assert.NoError(t, client.close())
+				return
+			}
+			client, err := newGcsClient(context.Background(), meta)
+			assert.NoError(t, err)
+			_, err = client.client.Bucket(meta.bucketName).Objects(context.Background(), nil).Next()
+			assert.NoError(t, err)
+			assert.NoError(t, client.close())
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(created))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokens.tokens))
+}
+
+func TestGcpTokenSourceCacheCreatesOutsideOfLock(t *testing.T) {
+	impersonating := make(chan struct{})
+	release := make(chan struct{})
+	var created int32
+	cache := newGcpTokenSourceCache(func(_ context.Context, gcpAuthorization *gcpAuthorizationMetadata, _ oauth2.TokenSource) (oauth2.TokenSource, error) {
+		atomic.AddInt32(&created, 1)
+		if gcpAuthorization.targetServiceAccount != "" {
+			// the IAM API doesn't answer
+			close(impersonating)
+			<-release
+		}
+		return oauth2.ReuseTokenSource(nil, &countingTokenSource{}), nil
+	})
+
+	impersonated := &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverCredentials, targetServiceAccount: "scaler@myproject.iam.gserviceaccount.com"}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.get(context.Background(), impersonated)
+			assert.NoError(t, err)
+		}()
+	}
+	<-impersonating
+
+	// the token sources of other credentials don't wait for the impersonation
+	done := make(chan error)
+	go func() {
+		_, err := cache.get(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverRotatedCredentials})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Error("the token source waited for the impersonation of other credentials")
+	}
+
+	close(release)
+	wg.Wait()
+	// the credentials, their impersonation and the other credentials are each created once
+	assert.Equal(t, int32(3), atomic.LoadInt32(&created))
+}
@@ -0,0 +1,169 @@
+package scalers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	option "google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// gcsClient is a storage client with the transport it owns, the storage client doesn't close the idle connections of
+// its transport
+type gcsClient struct {
+	client    *storage.Client
+	transport *http.Transport
+
+	// cacheKey is the key of the client in the shared client cache, if it was acquired from it
+	cacheKey *gcsClientKey
+}
+
+// gcsClientKey identifies the storage clients that can be shared by the GCS scalers, e.g. of the triggers of a
+// ScaledObject on several prefixes of a bucket. The fingerprint of the credentials changes when the credentials of a
+// TriggerAuthentication are rotated
+type gcsClientKey struct {
+	endpoint               string
+	insecure               bool
+	credentialsFingerprint string
+}
+
+type sharedGcsClient struct {
+	client *gcsClient
+	refs   int
+}
+
+// gcsClientCache shares the storage clients, and so their connections and OAuth tokens, between the GCS scalers
+// using the same credentials and endpoint
+type gcsClientCache struct {
+	lock    sync.Mutex
+	clients map[gcsClientKey]*sharedGcsClient
+
+	// newClient creates the client of a key missing in the cache
+	newClient func(ctx context.Context, meta *gcsMetadata) (*gcsClient, error)
+}
+
+var gcsClients = newGcsClientCache(newGcsClient)
+
+func newGcsClientCache(newClient func(ctx context.Context, meta *gcsMetadata) (*gcsClient, error)) *gcsClientCache {
+	return &gcsClientCache{
+		clients:   map[gcsClientKey]*sharedGcsClient{},
+		newClient: newClient,
+	}
+}
+
+// newGcsClient creates the storage client of the credentials and endpoint of the metadata
+func newGcsClient(ctx context.Context, meta *gcsMetadata) (*gcsClient, error) {
+	var options []option.ClientOption
+	if meta.endpoint != "" {
+		options = append(options, option.WithEndpoint(meta.endpoint))
+	}
+	if meta.insecure {
+		options = append(options, option.WithoutAuthentication())
+	} else {
+		// the tokens are shared with the other GCP scalers using the same credentials
+		tokenSource, err := gcpTokenSources.get(ctx, meta.gcpAuthorization)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, meta.gcpAuthorization.clientOptions(tokenSource, gcpStorageAPI)...)
+	}
+
+	// The token source of the transport caches the access tokens for the life of the client, so e.g. the external token
+	// of Workload Identity Federation is only exchanged with STS again once the access token expires. It mustn't use
+	// ctx, which is done once the scaler is built
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	authenticated, err := htransport.NewTransport(context.Background(), transport, append([]option.ClientOption{option.WithScopes(storage.ScopeReadOnly)}, options...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the GCS transport: %s", err)
+	}
+
+	client, err := storage.NewClient(ctx, append(options, option.WithHTTPClient(&http.Client{Transport: authenticated}))...)
+	if err != nil {
+		return nil, fmt.Errorf("storage.NewClient: %v", err)
+	}
+
+	return &gcsClient{
+		client:    client,
+		transport: transport,
+	}, nil
+}
+
+// getGcsClientKey returns the cache key of the credentials and endpoint, all the scalers using the identity of the
+// pod share the same client
+func getGcsClientKey(meta *gcsMetadata) gcsClientKey {
+	auth := meta.gcpAuthorization
+	fingerprint := sha256.Sum256([]byte(strings.Join([]string{
+		auth.GoogleApplicationCredentials,
+		auth.GoogleApplicationCredentialsFile,
+		auth.credentialsConfig,
+		auth.targetServiceAccount,
+		auth.quotaProject,
+		auth.apiEndpoint,
+		strconv.FormatBool(auth.podIdentityProviderEnabled),
+	}, "\x00")))
+	return gcsClientKey{
+		endpoint:               meta.endpoint,
+		insecure:               meta.insecure,
+		credentialsFingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+}
+
+// acquire returns the client shared for the credentials and endpoint, creating it if needed.
+// Every acquired client must be released when the scaler is closed
+func (c *gcsClientCache) acquire(ctx context.Context, meta *gcsMetadata) (*gcsClient, error) {
+	key := getGcsClientKey(meta)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if shared, ok := c.clients[key]; ok {
+		shared.refs++
+		return shared.client, nil
+	}
+
+	client, err := c.newClient(ctx, meta)
+	if err != nil {
+		return nil, err
+	}
+	client.cacheKey = &key
+	c.clients[key] = &sharedGcsClient{client: client, refs: 1}
+	return client, nil
+}
+
+// release drops a reference to the client, the last one closes it
+func (c *gcsClientCache) release(client *gcsClient) error {
+	if client.cacheKey == nil {
+		return client.close()
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	shared, ok := c.clients[*client.cacheKey]
+	if !ok || shared.client != client {
+		return nil
+	}
+	shared.refs--
+	if shared.refs > 0 {
+		return nil
+	}
+	delete(c.clients, *client.cacheKey)
+	return client.close()
+}
+
+func (c *gcsClient) close() error {
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
+	if c.client == nil {
+		return nil
+	}
+	return c.client.Close()
+}
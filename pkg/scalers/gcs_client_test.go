@@ -0,0 +1,172 @@
+package scalers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestGcsClientCache() (*gcsClientCache, *int) {
+	created := 0
+	cache := newGcsClientCache(func(context.Context, *gcsMetadata) (*gcsClient, error) {
+		created++
+		return &gcsClient{}, nil
+	})
+	return cache, &created
+}
+
+func newTestGcsClientMetadata(credentials string) *gcsMetadata {
+	return &gcsMetadata{gcpAuthorization: &gcpAuthorizationMetadata{GoogleApplicationCredentials: credentials}}
+}
+
+func TestGcsClientCacheSharesClients(t *testing.T) {
+	cache, created := newTestGcsClientCache()
+
+	first, err := cache.acquire(context.Background(), newTestGcsClientMetadata(testStackDriverCredentials))
+	assert.NoError(t, err)
+	// the triggers on other prefixes or buckets share the client of the credentials
+	otherPrefix := newTestGcsClientMetadata(testStackDriverCredentials)
+	otherPrefix.blobPrefixes = []string{"incoming/"}
+	otherPrefix.bucketName = "other-bucket"
+	second, err := cache.acquire(context.Background(), otherPrefix)
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, *created)
+
+	// rotated credentials don't reuse the client of the previous ones
+	rotated, err := cache.acquire(context.Background(), newTestGcsClientMetadata(testStackDriverRotatedCredentials))
+	assert.NoError(t, err)
+	assert.NotSame(t, first, rotated)
+	assert.Equal(t, 2, *created)
+
+	// neither do other endpoints or kinds of credentials
+	otherEndpoint := newTestGcsClientMetadata(testStackDriverCredentials)
+	otherEndpoint.endpoint = "http://localhost:4443/storage/v1/"
+	for _, meta := range []*gcsMetadata{
+		otherEndpoint,
+		{gcpAuthorization: &gcpAuthorizationMetadata{GoogleApplicationCredentialsFile: testStackDriverCredentials}},
+		{gcpAuthorization: &gcpAuthorizationMetadata{podIdentityProviderEnabled: true}},
+		{gcpAuthorization: &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverCredentials, targetServiceAccount: testGcpTargetServiceAccount}},
+	} {
+		client, err := cache.acquire(context.Background(), meta)
+		assert.NoError(t, err)
+		assert.NotSame(t, first, client)
+	}
+	assert.Equal(t, 6, *created)
+
+	// the client is kept until the last scaler using it is closed
+	assert.NoError(t, cache.release(first))
+	assert.Len(t, cache.clients, 6)
+	assert.NoError(t, cache.release(second))
+	assert.Len(t, cache.clients, 5)
+
+	third, err := cache.acquire(context.Background(), newTestGcsClientMetadata(testStackDriverCredentials))
+	assert.NoError(t, err)
+	assert.NotSame(t, first, third)
+	assert.Equal(t, 7, *created)
+}
+
+func TestGcsClientCacheErrors(t *testing.T) {
+	cache := newGcsClientCache(func(context.Context, *gcsMetadata) (*gcsClient, error) {
+		return nil, errors.New("invalid credentials")
+	})
+
+	_, err := cache.acquire(context.Background(), newTestGcsClientMetadata(testStackDriverCredentials))
+	assert.EqualError(t, err, "invalid credentials")
+	assert.Empty(t, cache.clients)
+}
+
+func TestGcsClientCacheConcurrency(t *testing.T) {
+	cache, created := newTestGcsClientCache()
+	credentials := []string{testStackDriverCredentials, testStackDriverRotatedCredentials}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client, err := cache.acquire(context.Background(), newTestGcsClientMetadata(credentials[i%2]))
+			assert.NoError(t, err)
+			assert.NoError(t, cache.release(client))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Empty(t, cache.clients)
+	assert.GreaterOrEqual(t, *created, 2)
+}
+
+func TestNewGcsScalerSharesClients(t *testing.T) {
+	server := newFakeGcsServer(t, testGcsObjects)
+	defer server.Close()
+	newScaler := func(prefix string) *gcsScaler {
+		s, err := NewGcsScaler(context.Background(), &ScalerConfig{TriggerMetadata: map[string]string{"bucketName": "test-bucket", "blobPrefix": prefix, "endpoint": server.URL, "insecure": "true", "disableCountCache": "true"}})
+		if err != nil {
+			t.Fatal("Could not create the scaler:", err)
+		}
+		return s.(*gcsScaler)
+	}
+
+	scalers := []*gcsScaler{newScaler("incoming/"), newScaler("incoming/processed/"), newScaler("other/")}
+	for _, s := range scalers[1:] {
+		assert.Same(t, scalers[0].client, s.client)
+	}
+
+	// the triggers poll concurrently, and are closed while the others still poll
+	var wg sync.WaitGroup
+	for i, s := range scalers {
+		wg.Add(1)
+		go func(i int, s *gcsScaler) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, err := s.getItemCount(context.Background(), 100)
+				assert.NoError(t, err)
+			}
+			if i > 0 {
+				assert.NoError(t, s.Close(context.Background()))
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	count, err := scalers[0].getItemCount(context.Background(), 100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+	assert.NoError(t, scalers[0].Close(context.Background()))
+	assert.NotContains(t, gcsClients.clients, getGcsClientKey(scalers[0].metadata))
+}
+
+func TestGcsClientCloseClosesIdleConnections(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(newFakeGcsHandler(t, testGcsObjects, nil))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	meta := &gcsMetadata{bucketName: "test-bucket", endpoint: server.URL + "/storage/v1/", insecure: true}
+	client, err := newGcsClient(context.Background(), meta)
+	if err != nil {
+		t.Fatal("Could not create the client:", err)
+	}
+	_, err = client.client.Bucket(meta.bucketName).Objects(context.Background(), nil).Next()
+	assert.NoError(t, err)
+
+	// the keep-alive connection is closed with the client rather than left to the idle timeout of the transport
+	assert.NoError(t, client.close())
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("the idle connection of the client wasn't closed")
+	}
+}
@@ -67,7 +67,7 @@ func NewGraphiteScaler(config *ScalerConfig) (Scaler, error) {
 		return nil, fmt.Errorf("error parsing graphite metadata: %s", err)
 	}
 
-	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false)
+	httpClient := WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config)
 
 	return &graphiteScaler{
 		metricType: metricType,
@@ -79,11 +79,14 @@ func NewGraphiteScaler(config *ScalerConfig) (Scaler, error) {
 func parseGraphiteMetadata(config *ScalerConfig) (*graphiteMetadata, error) {
 	meta := graphiteMetadata{}
 
-	if val, ok := config.TriggerMetadata[grapServerAddress]; ok && val != "" {
-		meta.serverAddress = val
-	} else {
+	serverAddress, err := getFromAuthOrEnvOrMeta(config, grapServerAddress)
+	if err != nil {
+		return nil, err
+	}
+	if serverAddress == "" {
 		return nil, fmt.Errorf("no %s given", grapServerAddress)
 	}
+	meta.serverAddress = serverAddress
 
 	if val, ok := config.TriggerMetadata[grapQuery]; ok && val != "" {
 		meta.query = val
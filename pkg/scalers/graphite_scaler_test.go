@@ -102,3 +102,14 @@ func TestGraphiteScalerAuthParams(t *testing.T) {
 		}
 	}
 }
+
+func TestGraphiteParseMetadataServerAddressSources(t *testing.T) {
+	metadata := map[string]string{"metricName": "request-count", "threshold": "100", "query": "stats.counters.http.hello-world.request.count.count", "queryTime": "-30Seconds"}
+	testParseFromEnvSources(t, "serverAddress", metadata, nil, [3]string{"http://graphite-inline:81", "http://graphite-env:81", "http://graphite-auth:81"}, func(config *ScalerConfig) (string, error) {
+		meta, err := parseGraphiteMetadata(config)
+		if err != nil {
+			return "", err
+		}
+		return meta.serverAddress, nil
+	})
+}
@@ -0,0 +1,298 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/authentication"
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	// hazelcastAPITypeREST reads the queue size from the REST endpoint of a cluster member
+	hazelcastAPITypeREST = "rest"
+	// hazelcastAPITypeManagementCenter reads the queue size from the Management Center REST API
+	hazelcastAPITypeManagementCenter = "managementCenter"
+
+	defaultHazelcastTargetQueueSize = 5
+)
+
+type hazelcastScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *hazelcastMetadata
+	httpClient *http.Client
+
+	// index of the endpoint that answered last, it's tried first on the next request
+	endpointLock   sync.Mutex
+	activeEndpoint int
+}
+
+type hazelcastMetadata struct {
+	endpoints                 []string
+	apiType                   string
+	queueName                 string
+	clusterName               string
+	targetQueueSize           int64
+	activationTargetQueueSize int64
+	unsafeSsl                 bool
+	hazelcastAuth             *authentication.AuthMeta
+	scalerIndex               int
+}
+
+// hazelcastManagementCenterQueue is the queue statistics returned by the Management Center REST API
+type hazelcastManagementCenterQueue struct {
+	OwnedItemCount int64 `json:"ownedItemCount"`
+}
+
+var hazelcastLog = logf.Log.WithName("hazelcast_scaler")
+
+// NewHazelcastScaler creates a new hazelcastScaler
+func NewHazelcastScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseHazelcastMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing hazelcast metadata: %s", err)
+	}
+
+	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, meta.unsafeSsl)
+
+	if meta.hazelcastAuth != nil && (meta.hazelcastAuth.CA != "" || meta.hazelcastAuth.EnableTLS || meta.hazelcastAuth.HTTPVersion != "") {
+		// create http.RoundTripper with auth settings from ScalerConfig, keeping unsafeSsl of the default transport
+		meta.hazelcastAuth.UnsafeSsl = meta.unsafeSsl
+		if httpClient.Transport, err = authentication.CreateHTTPRoundTripper(
+			authentication.NetHTTP,
+			meta.hazelcastAuth,
+		); err != nil {
+			hazelcastLog.V(1).Error(err, "init Hazelcast client http transport")
+			return nil, err
+		}
+	}
+
+	return &hazelcastScaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(httpClient, config),
+	}, nil
+}
+
+func parseHazelcastMetadata(config *ScalerConfig) (*hazelcastMetadata, error) {
+	meta := hazelcastMetadata{}
+	meta.apiType = hazelcastAPITypeREST
+	meta.targetQueueSize = defaultHazelcastTargetQueueSize
+
+	if val, ok := config.TriggerMetadata["endpoint"]; ok && val != "" {
+		// members come and go, so several endpoints can be given and are tried in order
+		for _, endpoint := range strings.Split(val, ",") {
+			endpoint = strings.TrimSuffix(strings.TrimSpace(endpoint), "/")
+			if endpoint == "" {
+				continue
+			}
+			if _, err := url.ParseRequestURI(endpoint); err != nil {
+				return nil, fmt.Errorf("error parsing endpoint %s: %s", endpoint, err)
+			}
+			meta.endpoints = append(meta.endpoints, endpoint)
+		}
+	}
+	if len(meta.endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoint given")
+	}
+
+	if val, ok := config.TriggerMetadata["apiType"]; ok && val != "" {
+		switch val {
+		case hazelcastAPITypeREST, hazelcastAPITypeManagementCenter:
+			meta.apiType = val
+		default:
+			return nil, fmt.Errorf("apiType must be %s or %s, got %s", hazelcastAPITypeREST, hazelcastAPITypeManagementCenter, val)
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["queueName"]; ok && val != "" {
+		meta.queueName = val
+	} else {
+		return nil, fmt.Errorf("no queueName given")
+	}
+
+	if val, ok := config.TriggerMetadata["clusterName"]; ok && val != "" {
+		meta.clusterName = val
+	} else if meta.apiType == hazelcastAPITypeManagementCenter {
+		return nil, fmt.Errorf("no clusterName given, it's required by the %s apiType", hazelcastAPITypeManagementCenter)
+	}
+
+	if val, ok := config.TriggerMetadata["targetQueueSize"]; ok {
+		targetQueueSize, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetQueueSize: %s", err)
+		}
+		if targetQueueSize <= 0 {
+			return nil, fmt.Errorf("targetQueueSize must be greater than 0")
+		}
+		meta.targetQueueSize = targetQueueSize
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetQueueSize"]; ok {
+		activationTargetQueueSize, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetQueueSize: %s", err)
+		}
+		if activationTargetQueueSize < 0 {
+			return nil, fmt.Errorf("activationTargetQueueSize must not be negative")
+		}
+		meta.activationTargetQueueSize = activationTargetQueueSize
+	}
+
+	if val, ok := config.TriggerMetadata["unsafeSsl"]; ok {
+		unsafeSsl, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing unsafeSsl: %s", err)
+		}
+		meta.unsafeSsl = unsafeSsl
+	}
+
+	auth, err := authentication.GetAuthConfigs(config.TriggerMetadata, config.AuthParams)
+	if err != nil {
+		return nil, err
+	}
+	meta.hazelcastAuth = auth
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// IsActive checks if the queue holds more items than the activation target
+func (s *hazelcastScaler) IsActive(ctx context.Context) (bool, error) {
+	queueSize, err := s.getQueueSize(ctx)
+	if err != nil {
+		hazelcastLog.Error(err, "error getting queue size")
+		return false, err
+	}
+
+	return queueSize > s.metadata.activationTargetQueueSize, nil
+}
+
+func (s *hazelcastScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *hazelcastScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("hazelcast-%s", s.metadata.queueName))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetQueueSize),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the size of the queue
+func (s *hazelcastScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	queueSize, err := s.getQueueSize(ctx)
+	if err != nil {
+		hazelcastLog.Error(err, "error getting queue size")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(queueSize, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getQueueSize asks the endpoints in turn, starting with the one that answered last, until one of them returns the queue size
+func (s *hazelcastScaler) getQueueSize(ctx context.Context) (int64, error) {
+	s.endpointLock.Lock()
+	start := s.activeEndpoint
+	s.endpointLock.Unlock()
+
+	var errs []string
+	for i := 0; i < len(s.metadata.endpoints); i++ {
+		index := (start + i) % len(s.metadata.endpoints)
+		endpoint := s.metadata.endpoints[index]
+
+		queueSize, err := s.getQueueSizeFromEndpoint(ctx, endpoint)
+		if err != nil {
+			hazelcastLog.V(1).Info("hazelcast endpoint failed, trying the next one", "endpoint", endpoint, "error", err.Error())
+			errs = append(errs, fmt.Sprintf("%s: %s", endpoint, err))
+			continue
+		}
+
+		s.endpointLock.Lock()
+		s.activeEndpoint = index
+		s.endpointLock.Unlock()
+		return queueSize, nil
+	}
+
+	return -1, fmt.Errorf("no hazelcast endpoint returned the queue size: %s", strings.Join(errs, "; "))
+}
+
+func (s *hazelcastScaler) getQueueSizeFromEndpoint(ctx context.Context, endpoint string) (int64, error) {
+	var queueURL string
+	if s.metadata.apiType == hazelcastAPITypeManagementCenter {
+		queueURL = fmt.Sprintf("%s/rest/clusters/%s/queues/%s", endpoint, url.PathEscape(s.metadata.clusterName), url.PathEscape(s.metadata.queueName))
+	} else {
+		queueURL = fmt.Sprintf("%s/hazelcast/rest/queues/%s/size", endpoint, url.PathEscape(s.metadata.queueName))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", queueURL, nil)
+	if err != nil {
+		return -1, err
+	}
+
+	if s.metadata.hazelcastAuth != nil && s.metadata.hazelcastAuth.EnableBearerAuth {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.metadata.hazelcastAuth.BearerToken))
+	} else if s.metadata.hazelcastAuth != nil && s.metadata.hazelcastAuth.EnableBasicAuth {
+		req.SetBasicAuth(s.metadata.hazelcastAuth.Username, s.metadata.hazelcastAuth.Password)
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return -1, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return -1, fmt.Errorf("hazelcast returned %d: %s", res.StatusCode, string(b))
+	}
+
+	if s.metadata.apiType == hazelcastAPITypeManagementCenter {
+		var queue hazelcastManagementCenterQueue
+		if err := json.Unmarshal(b, &queue); err != nil {
+			return -1, fmt.Errorf("error parsing management center response: %s", err)
+		}
+		return queue.OwnedItemCount, nil
+	}
+
+	queueSize, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("error parsing queue size: %s", err)
+	}
+	return queueSize, nil
+}
@@ -0,0 +1,269 @@
+package scalers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+type parseHazelcastMetadataTestData struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}
+
+type hazelcastMetricIdentifier struct {
+	metadataTestData *parseHazelcastMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testHazelcastMetadata = []parseHazelcastMetadataTestData{
+	// nothing passed
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"endpoint": "http://hazelcast-0:5701", "queueName": "jobs", "targetQueueSize": "10", "activationTargetQueueSize": "2"}, map[string]string{}, false},
+	// multiple endpoints
+	{map[string]string{"endpoint": "http://hazelcast-0:5701, http://hazelcast-1:5701/", "queueName": "jobs"}, map[string]string{}, false},
+	// management center
+	{map[string]string{"endpoint": "http://mancenter:8080/mancenter", "apiType": "managementCenter", "clusterName": "dev", "queueName": "jobs"}, map[string]string{}, false},
+	// management center without clusterName
+	{map[string]string{"endpoint": "http://mancenter:8080/mancenter", "apiType": "managementCenter", "queueName": "jobs"}, map[string]string{}, true},
+	// invalid apiType
+	{map[string]string{"endpoint": "http://hazelcast-0:5701", "apiType": "jmx", "queueName": "jobs"}, map[string]string{}, true},
+	// missing queueName
+	{map[string]string{"endpoint": "http://hazelcast-0:5701"}, map[string]string{}, true},
+	// invalid endpoint
+	{map[string]string{"endpoint": "hazelcast-0", "queueName": "jobs"}, map[string]string{}, true},
+	// malformed targetQueueSize
+	{map[string]string{"endpoint": "http://hazelcast-0:5701", "queueName": "jobs", "targetQueueSize": "a"}, map[string]string{}, true},
+	// negative activationTargetQueueSize
+	{map[string]string{"endpoint": "http://hazelcast-0:5701", "queueName": "jobs", "activationTargetQueueSize": "-1"}, map[string]string{}, true},
+	// malformed unsafeSsl
+	{map[string]string{"endpoint": "https://hazelcast-0:5701", "queueName": "jobs", "unsafeSsl": "a"}, map[string]string{}, true},
+	// basic auth
+	{map[string]string{"endpoint": "http://hazelcast-0:5701", "queueName": "jobs", "authModes": "basic"}, map[string]string{"username": "user", "password": "pass"}, false},
+	// basic auth without username
+	{map[string]string{"endpoint": "http://hazelcast-0:5701", "queueName": "jobs", "authModes": "basic"}, map[string]string{}, true},
+	// bearer auth
+	{map[string]string{"endpoint": "http://hazelcast-0:5701", "queueName": "jobs", "authModes": "bearer"}, map[string]string{"bearerToken": "token"}, false},
+}
+
+var hazelcastMetricIdentifiers = []hazelcastMetricIdentifier{
+	{&testHazelcastMetadata[1], 0, "s0-hazelcast-jobs"},
+	{&testHazelcastMetadata[1], 1, "s1-hazelcast-jobs"},
+}
+
+func TestHazelcastParseMetadata(t *testing.T) {
+	for _, testData := range testHazelcastMetadata {
+		_, err := parseHazelcastMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+	}
+}
+
+func TestHazelcastGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range hazelcastMetricIdentifiers {
+		meta, err := parseHazelcastMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockHazelcastScaler := hazelcastScaler{metadata: meta}
+
+		metricSpec := mockHazelcastScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+func newTestHazelcastScaler(t *testing.T, metadata map[string]string, authParams map[string]string) *hazelcastScaler {
+	meta, err := parseHazelcastMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: authParams})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	return &hazelcastScaler{metadata: meta, httpClient: http.DefaultClient}
+}
+
+func TestHazelcastGetQueueSize(t *testing.T) {
+	member := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hazelcast/rest/queues/jobs/size" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("42\n"))
+	}))
+	defer member.Close()
+
+	managementCenter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mancenter/rest/clusters/dev/queues/jobs" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"cluster": "dev", "name": "jobs", "ownedItemCount": 17, "backupItemCount": 17, "minAge": 0, "maxAge": 0, "aveAge": 0}`))
+	}))
+	defer managementCenter.Close()
+
+	tests := []struct {
+		name       string
+		metadata   map[string]string
+		authParams map[string]string
+		queueSize  int64
+	}{
+		{
+			"member REST endpoint",
+			map[string]string{"endpoint": member.URL, "queueName": "jobs", "authModes": "basic"},
+			map[string]string{"username": "user", "password": "pass"},
+			42,
+		},
+		{
+			"management center",
+			map[string]string{"endpoint": managementCenter.URL + "/mancenter", "apiType": "managementCenter", "clusterName": "dev", "queueName": "jobs", "authModes": "bearer"},
+			map[string]string{"bearerToken": "token"},
+			17,
+		},
+	}
+
+	for _, test := range tests {
+		scaler := newTestHazelcastScaler(t, test.metadata, test.authParams)
+		queueSize, err := scaler.getQueueSize(context.Background())
+		if err != nil {
+			t.Errorf("%s: expected success but got error %s", test.name, err)
+			continue
+		}
+		if queueSize != test.queueSize {
+			t.Errorf("%s: expected queue size %d, got %d", test.name, test.queueSize, queueSize)
+		}
+	}
+}
+
+func TestHazelcastEndpointFailover(t *testing.T) {
+	var downRequests, upRequests int
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downRequests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upRequests++
+		_, _ = w.Write([]byte("3"))
+	}))
+	defer up.Close()
+
+	// the first member was removed from the cluster, its address no longer answers
+	removed := httptest.NewServer(http.NotFoundHandler())
+	removedURL := removed.URL
+	removed.Close()
+
+	scaler := newTestHazelcastScaler(t, map[string]string{"endpoint": removedURL + "," + down.URL + "," + up.URL, "queueName": "jobs", "activationTargetQueueSize": "2"}, map[string]string{})
+
+	active, err := scaler.IsActive(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if !active {
+		t.Error("Expected scaler to be active")
+	}
+	if downRequests != 1 || upRequests != 1 {
+		t.Errorf("Expected one request per endpoint, got %d and %d", downRequests, upRequests)
+	}
+
+	// the endpoint that answered is asked first from now on
+	if _, err := scaler.getQueueSize(context.Background()); err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if downRequests != 1 || upRequests != 2 {
+		t.Errorf("Expected the healthy endpoint to be reused, got %d and %d requests", downRequests, upRequests)
+	}
+}
+
+func TestHazelcastAllEndpointsFail(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	malformed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not a number"))
+	}))
+	defer malformed.Close()
+
+	scaler := newTestHazelcastScaler(t, map[string]string{"endpoint": down.URL + "," + malformed.URL, "queueName": "jobs"}, map[string]string{})
+	if _, err := scaler.GetMetrics(context.Background(), "s0-hazelcast-jobs", nil); err == nil {
+		t.Error("Expected error but got success")
+	}
+}
+
+func TestHazelcastUserAgent(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		_, _ = w.Write([]byte("1"))
+	}))
+	defer server.Close()
+
+	scaler, err := NewHazelcastScaler(&ScalerConfig{
+		TriggerMetadata: map[string]string{"endpoint": server.URL, "queueName": "jobs"},
+		TriggerType:     "hazelcast",
+	})
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if _, err := scaler.IsActive(context.Background()); err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if userAgent != kedautil.UserAgent("hazelcast") {
+		t.Errorf("Unexpected User-Agent %q", userAgent)
+	}
+}
+
+func TestHazelcastHTTPVersion(t *testing.T) {
+	var proto string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.Proto
+		_, _ = w.Write([]byte("1"))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	tests := []struct {
+		httpVersion   string
+		expectedProto string
+	}{
+		{"", "HTTP/1.1"},
+		{"1.1", "HTTP/1.1"},
+		{"2", "HTTP/2.0"},
+	}
+
+	for _, test := range tests {
+		scaler, err := NewHazelcastScaler(&ScalerConfig{
+			TriggerMetadata: map[string]string{"endpoint": server.URL, "queueName": "jobs", "unsafeSsl": "true", "httpVersion": test.httpVersion},
+			TriggerType:     "hazelcast",
+		})
+		if err != nil {
+			t.Fatal("Expected success but got error", err)
+		}
+		if _, err := scaler.IsActive(context.Background()); err != nil {
+			t.Fatal("Expected success but got error", err)
+		}
+		if proto != test.expectedProto {
+			t.Errorf("httpVersion %q: expected %s, got %s", test.httpVersion, test.expectedProto, proto)
+		}
+	}
+}
@@ -33,6 +33,7 @@ type IBMMQScaler struct {
 	metricType         v2beta2.MetricTargetType
 	metadata           *IBMMQMetadata
 	defaultHTTPTimeout time.Duration
+	userAgent          string
 }
 
 // IBMMQMetadata Metadata used by KEDA to query IBM MQ queue depth and scale
@@ -78,6 +79,7 @@ func NewIBMMQScaler(config *ScalerConfig) (Scaler, error) {
 		metricType:         metricType,
 		metadata:           meta,
 		defaultHTTPTimeout: config.GlobalHTTPTimeout,
+		userAgent:          kedautil.UserAgent(config.TriggerType),
 	}, nil
 }
 
@@ -182,7 +184,7 @@ func (s *IBMMQScaler) getQueueDepthViaHTTP(ctx context.Context) (int64, error) {
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: s.metadata.tlsDisabled},
 	}
 	client := kedautil.CreateHTTPClient(s.defaultHTTPTimeout, false)
-	client.Transport = tr
+	client.Transport = kedautil.NewUserAgentRoundTripper(s.userAgent, tr)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -0,0 +1,447 @@
+package scalers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/authentication"
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	ingressStatusEndpointTypeNginx   = "nginx"
+	ingressStatusEndpointTypeTraefik = "traefik"
+	ingressStatusEndpointTypeKong    = "kong"
+
+	ingressStatusMetricActiveConnections = "activeConnections"
+	ingressStatusMetricRequestsPerSecond = "requestsPerSecond"
+
+	ingressStatusAggregationSum = "sum"
+	ingressStatusAggregationMax = "max"
+
+	defaultIngressStatusTargetValue = 100
+
+	// ingressStatusMinRateInterval is the shortest interval a request rate is computed over, samples taken
+	// sooner than that after the previous one, e.g. by IsActive and GetMetrics in the same loop, reuse its rate
+	ingressStatusMinRateInterval = time.Second
+
+	traefikOpenConnectionsMetric = "traefik_entrypoint_open_connections"
+	traefikRequestsTotalMetric   = "traefik_entrypoint_requests_total"
+)
+
+type ingressStatusScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *ingressStatusMetadata
+	httpClient *http.Client
+
+	// samples holds the previous request counter of every endpoint to compute the request rates from
+	samples map[string]ingressStatusSample
+	mutex   sync.Mutex
+}
+
+type ingressStatusMetadata struct {
+	endpointType          string
+	endpoints             []string
+	metric                string
+	aggregation           string
+	targetValue           int64
+	activationTargetValue float64
+	unsafeSsl             bool
+	ingressAuth           *authentication.AuthMeta
+	scalerIndex           int
+}
+
+// ingressStatusSample is the request counter of an endpoint at a point in time and the rate computed with it
+type ingressStatusSample struct {
+	requests  float64
+	timestamp time.Time
+	rate      float64
+}
+
+// ingressStatus is what an endpoint reports, whatever its type
+type ingressStatus struct {
+	activeConnections float64
+	totalRequests     float64
+}
+
+var ingressStatusLog = logf.Log.WithName("ingress_status_scaler")
+
+// NewIngressStatusScaler creates a new ingressStatusScaler
+func NewIngressStatusScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseIngressStatusMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ingress status metadata: %s", err)
+	}
+
+	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, meta.unsafeSsl)
+
+	if meta.ingressAuth != nil && (meta.ingressAuth.CA != "" || meta.ingressAuth.EnableTLS || meta.ingressAuth.HTTPVersion != "") {
+		// create http.RoundTripper with auth settings from ScalerConfig, keeping unsafeSsl of the default transport
+		meta.ingressAuth.UnsafeSsl = meta.unsafeSsl
+		if httpClient.Transport, err = authentication.CreateHTTPRoundTripper(
+			authentication.NetHTTP,
+			meta.ingressAuth,
+		); err != nil {
+			ingressStatusLog.V(1).Error(err, "init ingress status client http transport")
+			return nil, err
+		}
+	}
+
+	return &ingressStatusScaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(httpClient, config),
+		samples:    map[string]ingressStatusSample{},
+	}, nil
+}
+
+func parseIngressStatusMetadata(config *ScalerConfig) (*ingressStatusMetadata, error) {
+	meta := ingressStatusMetadata{}
+	meta.aggregation = ingressStatusAggregationSum
+	meta.targetValue = defaultIngressStatusTargetValue
+
+	if val, ok := config.TriggerMetadata["endpointType"]; ok && val != "" {
+		switch val {
+		case ingressStatusEndpointTypeNginx, ingressStatusEndpointTypeTraefik, ingressStatusEndpointTypeKong:
+			meta.endpointType = val
+		default:
+			return nil, fmt.Errorf("endpointType must be %s, %s or %s, got %s", ingressStatusEndpointTypeNginx, ingressStatusEndpointTypeTraefik, ingressStatusEndpointTypeKong, val)
+		}
+	} else {
+		return nil, fmt.Errorf("no endpointType given")
+	}
+
+	if val, ok := config.TriggerMetadata["endpoints"]; ok && val != "" {
+		for _, endpoint := range splitAndTrimBySep(val, ",") {
+			if endpoint == "" {
+				continue
+			}
+			if _, err := url.ParseRequestURI(endpoint); err != nil {
+				return nil, fmt.Errorf("error parsing endpoints %s: %s", endpoint, err)
+			}
+			meta.endpoints = append(meta.endpoints, endpoint)
+		}
+	}
+	if len(meta.endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints given")
+	}
+
+	if val, ok := config.TriggerMetadata["metric"]; ok && val != "" {
+		switch val {
+		case ingressStatusMetricActiveConnections, ingressStatusMetricRequestsPerSecond:
+			meta.metric = val
+		default:
+			return nil, fmt.Errorf("metric must be %s or %s, got %s", ingressStatusMetricActiveConnections, ingressStatusMetricRequestsPerSecond, val)
+		}
+	} else {
+		return nil, fmt.Errorf("no metric given")
+	}
+
+	if val, ok := config.TriggerMetadata["aggregation"]; ok && val != "" {
+		switch val {
+		case ingressStatusAggregationSum, ingressStatusAggregationMax:
+			meta.aggregation = val
+		default:
+			return nil, fmt.Errorf("aggregation must be %s or %s, got %s", ingressStatusAggregationSum, ingressStatusAggregationMax, val)
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["targetValue"]; ok {
+		targetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetValue: %s", err)
+		}
+		if targetValue <= 0 {
+			return nil, fmt.Errorf("targetValue must be greater than 0")
+		}
+		meta.targetValue = targetValue
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetValue"]; ok {
+		activationTargetValue, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetValue: %s", err)
+		}
+		if activationTargetValue < 0 {
+			return nil, fmt.Errorf("activationTargetValue must not be negative")
+		}
+		meta.activationTargetValue = activationTargetValue
+	}
+
+	if val, ok := config.TriggerMetadata["unsafeSsl"]; ok {
+		unsafeSsl, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing unsafeSsl: %s", err)
+		}
+		meta.unsafeSsl = unsafeSsl
+	}
+
+	auth, err := authentication.GetAuthConfigs(config.TriggerMetadata, config.AuthParams)
+	if err != nil {
+		return nil, err
+	}
+	meta.ingressAuth = auth
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// IsActive checks if the aggregated value is above the activation target
+func (s *ingressStatusScaler) IsActive(ctx context.Context) (bool, error) {
+	value, err := s.getValue(ctx)
+	if err != nil {
+		ingressStatusLog.Error(err, "error getting the ingress controller status")
+		return false, err
+	}
+
+	return value > s.metadata.activationTargetValue, nil
+}
+
+func (s *ingressStatusScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *ingressStatusScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("ingress-status-%s-%s", s.metadata.endpointType, s.metadata.metric))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the value of the metric aggregated over the endpoints
+func (s *ingressStatusScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	value, err := s.getValue(ctx)
+	if err != nil {
+		ingressStatusLog.Error(err, "error getting the ingress controller status")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getValue aggregates the value of the metric over the endpoints that answer, the endpoints that don't
+// are skipped with a warning as long as one of them answers
+func (s *ingressStatusScaler) getValue(ctx context.Context) (float64, error) {
+	var value float64
+	var errs []string
+	for _, endpoint := range s.metadata.endpoints {
+		status, err := s.getEndpointStatus(ctx, endpoint)
+		if err != nil {
+			ingressStatusLog.Info("ingress controller endpoint failed, skipping it", "endpoint", endpoint, "error", err.Error())
+			errs = append(errs, fmt.Sprintf("%s: %s", endpoint, err))
+			continue
+		}
+
+		endpointValue := status.activeConnections
+		if s.metadata.metric == ingressStatusMetricRequestsPerSecond {
+			endpointValue = s.requestRate(endpoint, status.totalRequests, time.Now())
+		}
+
+		if s.metadata.aggregation == ingressStatusAggregationMax {
+			value = math.Max(value, endpointValue)
+		} else {
+			value += endpointValue
+		}
+	}
+
+	if len(errs) == len(s.metadata.endpoints) {
+		return -1, fmt.Errorf("no ingress controller endpoint returned its status: %s", strings.Join(errs, "; "))
+	}
+	return value, nil
+}
+
+// requestRate computes the request rate of the endpoint since its previous sample. The first sample of
+// an endpoint has no rate yet, and a counter lower than the previous one means the ingress controller
+// restarted, the requests since the restart are all counted then
+func (s *ingressStatusScaler) requestRate(endpoint string, totalRequests float64, now time.Time) float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous, ok := s.samples[endpoint]
+	if !ok {
+		s.samples[endpoint] = ingressStatusSample{requests: totalRequests, timestamp: now}
+		return 0
+	}
+
+	elapsed := now.Sub(previous.timestamp)
+	if elapsed < ingressStatusMinRateInterval {
+		return previous.rate
+	}
+
+	delta := totalRequests - previous.requests
+	if delta < 0 {
+		delta = totalRequests
+	}
+	rate := delta / elapsed.Seconds()
+	s.samples[endpoint] = ingressStatusSample{requests: totalRequests, timestamp: now, rate: rate}
+	return rate
+}
+
+func (s *ingressStatusScaler) getEndpointStatus(ctx context.Context, endpoint string) (*ingressStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.metadata.ingressAuth != nil && s.metadata.ingressAuth.EnableBearerAuth {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.metadata.ingressAuth.BearerToken))
+	} else if s.metadata.ingressAuth != nil && s.metadata.ingressAuth.EnableBasicAuth {
+		req.SetBasicAuth(s.metadata.ingressAuth.Username, s.metadata.ingressAuth.Password)
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ingress controller returned %d: %s", res.StatusCode, string(b))
+	}
+
+	return parseIngressStatus(s.metadata.endpointType, b)
+}
+
+// parseIngressStatus parses the status reported by an endpoint of the type
+func parseIngressStatus(endpointType string, body []byte) (*ingressStatus, error) {
+	switch endpointType {
+	case ingressStatusEndpointTypeNginx:
+		return parseNginxStubStatus(body)
+	case ingressStatusEndpointTypeTraefik:
+		return parseTraefikMetrics(body)
+	case ingressStatusEndpointTypeKong:
+		return parseKongStatus(body)
+	default:
+		return nil, fmt.Errorf("unknown endpointType %s", endpointType)
+	}
+}
+
+// parseNginxStubStatus parses the output of the NGINX stub_status module:
+//
+//	Active connections: 291
+//	server accepts handled requests
+//	 16630948 16630948 31070465
+//	Reading: 6 Writing: 179 Waiting: 106
+func parseNginxStubStatus(body []byte) (*ingressStatus, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if len(lines) < 3 {
+		return nil, fmt.Errorf("unexpected stub_status output: %q", string(body))
+	}
+
+	status := ingressStatus{}
+	active := strings.TrimPrefix(lines[0], "Active connections:")
+	if active == lines[0] {
+		return nil, fmt.Errorf("no active connections in stub_status output: %q", lines[0])
+	}
+	activeConnections, err := strconv.ParseFloat(strings.TrimSpace(active), 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing active connections: %s", err)
+	}
+	status.activeConnections = activeConnections
+
+	counters := strings.Fields(lines[2])
+	if len(counters) != 3 {
+		return nil, fmt.Errorf("unexpected stub_status counters: %q", lines[2])
+	}
+	totalRequests, err := strconv.ParseFloat(counters[2], 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing requests: %s", err)
+	}
+	status.totalRequests = totalRequests
+
+	return &status, nil
+}
+
+// parseTraefikMetrics parses the Prometheus metrics of Traefik, summing the open connections and the
+// requests of every entrypoint
+func parseTraefikMetrics(body []byte) (*ingressStatus, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing traefik metrics: %s", err)
+	}
+
+	connections, ok := families[traefikOpenConnectionsMetric]
+	if !ok {
+		return nil, fmt.Errorf("no %s in traefik metrics", traefikOpenConnectionsMetric)
+	}
+	requests, ok := families[traefikRequestsTotalMetric]
+	if !ok {
+		return nil, fmt.Errorf("no %s in traefik metrics", traefikRequestsTotalMetric)
+	}
+
+	status := ingressStatus{}
+	for _, metric := range connections.GetMetric() {
+		status.activeConnections += metric.GetGauge().GetValue()
+	}
+	for _, metric := range requests.GetMetric() {
+		status.totalRequests += metric.GetCounter().GetValue()
+	}
+	return &status, nil
+}
+
+// parseKongStatus parses the response of the /status endpoint of the Kong Admin or Status API
+func parseKongStatus(body []byte) (*ingressStatus, error) {
+	var response struct {
+		Server *struct {
+			ConnectionsActive float64 `json:"connections_active"`
+			TotalRequests     float64 `json:"total_requests"`
+		} `json:"server"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error parsing kong status: %s", err)
+	}
+	if response.Server == nil {
+		return nil, fmt.Errorf("no server in kong status")
+	}
+
+	return &ingressStatus{
+		activeConnections: response.Server.ConnectionsActive,
+		totalRequests:     response.Server.TotalRequests,
+	}, nil
+}
@@ -0,0 +1,236 @@
+package scalers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type parseIngressStatusMetadataTestData struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}
+
+type ingressStatusMetricIdentifier struct {
+	metadataTestData *parseIngressStatusMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testIngressStatusMetadata = []parseIngressStatusMetadataTestData{
+	// nothing passed
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"endpointType": "nginx", "endpoints": "http://nginx-0/nginx_status, http://nginx-1/nginx_status", "metric": "activeConnections", "aggregation": "max", "targetValue": "200", "activationTargetValue": "0.5"}, map[string]string{}, false},
+	// using defaults
+	{map[string]string{"endpointType": "kong", "endpoints": "http://kong:8100/status", "metric": "requestsPerSecond"}, map[string]string{}, false},
+	// missing endpointType
+	{map[string]string{"endpoints": "http://nginx-0/nginx_status", "metric": "activeConnections"}, map[string]string{}, true},
+	// invalid endpointType
+	{map[string]string{"endpointType": "haproxy", "endpoints": "http://haproxy/stats", "metric": "activeConnections"}, map[string]string{}, true},
+	// missing endpoints
+	{map[string]string{"endpointType": "traefik", "metric": "activeConnections"}, map[string]string{}, true},
+	// invalid endpoints
+	{map[string]string{"endpointType": "traefik", "endpoints": "traefik/metrics", "metric": "activeConnections"}, map[string]string{}, true},
+	// missing metric
+	{map[string]string{"endpointType": "traefik", "endpoints": "http://traefik:8080/metrics"}, map[string]string{}, true},
+	// invalid metric
+	{map[string]string{"endpointType": "traefik", "endpoints": "http://traefik:8080/metrics", "metric": "waitingConnections"}, map[string]string{}, true},
+	// invalid aggregation
+	{map[string]string{"endpointType": "traefik", "endpoints": "http://traefik:8080/metrics", "metric": "activeConnections", "aggregation": "avg"}, map[string]string{}, true},
+	// malformed targetValue
+	{map[string]string{"endpointType": "traefik", "endpoints": "http://traefik:8080/metrics", "metric": "activeConnections", "targetValue": "a"}, map[string]string{}, true},
+	// negative activationTargetValue
+	{map[string]string{"endpointType": "traefik", "endpoints": "http://traefik:8080/metrics", "metric": "activeConnections", "activationTargetValue": "-1"}, map[string]string{}, true},
+	// basic auth
+	{map[string]string{"endpointType": "kong", "endpoints": "http://kong:8001/status", "metric": "activeConnections", "authModes": "basic"}, map[string]string{"username": "user", "password": "pass"}, false},
+	// basic auth without username
+	{map[string]string{"endpointType": "kong", "endpoints": "http://kong:8001/status", "metric": "activeConnections", "authModes": "basic"}, map[string]string{}, true},
+}
+
+var ingressStatusMetricIdentifiers = []ingressStatusMetricIdentifier{
+	{&testIngressStatusMetadata[1], 0, "s0-ingress-status-nginx-activeConnections"},
+	{&testIngressStatusMetadata[2], 1, "s1-ingress-status-kong-requestsPerSecond"},
+}
+
+const testNginxStubStatus = `Active connections: 291
+server accepts handled requests
+ 16630948 16630948 31070465
+Reading: 6 Writing: 179 Waiting: 106
+`
+
+const testTraefikMetrics = `# HELP traefik_entrypoint_open_connections How many open connections exist on an entrypoint, partitioned by method and protocol.
+# TYPE traefik_entrypoint_open_connections gauge
+traefik_entrypoint_open_connections{entrypoint="traefik",method="GET",protocol="http"} 1
+traefik_entrypoint_open_connections{entrypoint="web",method="GET",protocol="http"} 12
+traefik_entrypoint_open_connections{entrypoint="web",method="GET",protocol="websocket"} 3
+# HELP traefik_entrypoint_requests_total How many HTTP requests processed on an entrypoint, partitioned by status code, protocol, and method.
+# TYPE traefik_entrypoint_requests_total counter
+traefik_entrypoint_requests_total{code="200",entrypoint="web",method="GET",protocol="http"} 1500
+traefik_entrypoint_requests_total{code="404",entrypoint="web",method="GET",protocol="http"} 25
+# HELP traefik_config_reloads_total Config reloads
+# TYPE traefik_config_reloads_total counter
+traefik_config_reloads_total 4
+`
+
+const testKongStatus = `{"database":{"reachable":true},"memory":{"workers_lua_vms":[{"http_allocated_gc":"0.02 MiB","pid":18477}]},"server":{"connections_writing":1,"total_requests":3,"connections_handled":4,"connections_accepted":4,"connections_reading":0,"connections_active":2,"connections_waiting":1}}`
+
+func TestIngressStatusParseMetadata(t *testing.T) {
+	for _, testData := range testIngressStatusMetadata {
+		_, err := parseIngressStatusMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+	}
+}
+
+func TestIngressStatusGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range ingressStatusMetricIdentifiers {
+		meta, err := parseIngressStatusMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockIngressStatusScaler := ingressStatusScaler{metadata: meta}
+
+		metricSpec := mockIngressStatusScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+func TestParseIngressStatus(t *testing.T) {
+	tests := []struct {
+		endpointType      string
+		body              string
+		activeConnections float64
+		totalRequests     float64
+		isError           bool
+	}{
+		{"nginx", testNginxStubStatus, 291, 31070465, false},
+		{"nginx", "Active connections: 1\n", 0, 0, true},
+		{"nginx", "404 Not Found\n\n\n", 0, 0, true},
+		{"traefik", testTraefikMetrics, 16, 1525, false},
+		{"traefik", "traefik_config_reloads_total 4\n", 0, 0, true},
+		{"kong", testKongStatus, 2, 3, false},
+		{"kong", `{"database":{"reachable":true}}`, 0, 0, true},
+		{"kong", "<html></html>", 0, 0, true},
+	}
+
+	for _, test := range tests {
+		status, err := parseIngressStatus(test.endpointType, []byte(test.body))
+		if test.isError {
+			if err == nil {
+				t.Errorf("Expected an error parsing %s status %q", test.endpointType, test.body)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error parsing %s status: %s", test.endpointType, err)
+		}
+		if status.activeConnections != test.activeConnections {
+			t.Errorf("Expected %v active connections from %s, got %v", test.activeConnections, test.endpointType, status.activeConnections)
+		}
+		if status.totalRequests != test.totalRequests {
+			t.Errorf("Expected %v requests from %s, got %v", test.totalRequests, test.endpointType, status.totalRequests)
+		}
+	}
+}
+
+func TestIngressStatusRequestRate(t *testing.T) {
+	s := ingressStatusScaler{samples: map[string]ingressStatusSample{}}
+	start := time.Now()
+
+	tests := []struct {
+		elapsed       time.Duration
+		totalRequests float64
+		rate          float64
+	}{
+		// no rate without a previous sample
+		{0, 1000, 0},
+		{10 * time.Second, 1500, 50},
+		// samples taken too soon reuse the previous rate
+		{10*time.Second + 100*time.Millisecond, 1510, 50},
+		{20 * time.Second, 1600, 10},
+		// the counter was reset by a restart
+		{30 * time.Second, 200, 20},
+	}
+
+	for _, test := range tests {
+		rate := s.requestRate("http://nginx-0/nginx_status", test.totalRequests, start.Add(test.elapsed))
+		if rate != test.rate {
+			t.Errorf("Expected the rate %v after %s, got %v", test.rate, test.elapsed, rate)
+		}
+	}
+}
+
+func TestIngressStatusGetValue(t *testing.T) {
+	nginx := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testNginxStubStatus))
+	}))
+	defer nginx.Close()
+	kong := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(testKongStatus))
+	}))
+	defer kong.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	tests := []struct {
+		metadata map[string]string
+		value    float64
+		isActive bool
+		isError  bool
+	}{
+		// sum over the endpoints
+		{map[string]string{"endpointType": "nginx", "endpoints": nginx.URL + "," + nginx.URL, "metric": "activeConnections"}, 582, true, false},
+		// max over the endpoints
+		{map[string]string{"endpointType": "nginx", "endpoints": nginx.URL + "," + nginx.URL, "metric": "activeConnections", "aggregation": "max"}, 291, true, false},
+		// failing endpoints are skipped
+		{map[string]string{"endpointType": "kong", "endpoints": failing.URL + "," + kong.URL, "metric": "activeConnections", "authModes": "basic", "activationTargetValue": "2"}, 2, false, false},
+		// no rate from the first sample
+		{map[string]string{"endpointType": "kong", "endpoints": kong.URL, "metric": "requestsPerSecond", "authModes": "basic"}, 0, false, false},
+		// every endpoint failing
+		{map[string]string{"endpointType": "nginx", "endpoints": failing.URL, "metric": "activeConnections"}, -1, false, true},
+	}
+
+	for _, test := range tests {
+		meta, err := parseIngressStatusMetadata(&ScalerConfig{TriggerMetadata: test.metadata, AuthParams: map[string]string{"username": "user", "password": "pass"}})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		s := ingressStatusScaler{metadata: meta, httpClient: http.DefaultClient, samples: map[string]ingressStatusSample{}}
+
+		value, err := s.getValue(context.Background())
+		if test.isError {
+			if err == nil {
+				t.Errorf("Expected an error with %v", test.metadata)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if value != test.value {
+			t.Errorf("Expected %v with %v, got %v", test.value, test.metadata, value)
+		}
+
+		active, err := s.IsActive(context.Background())
+		if err != nil || active != test.isActive {
+			t.Errorf("Expected active %t with %v, got %t, %v", test.isActive, test.metadata, active, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package testutil provides fault-injecting fake backends and lifecycle stress helpers for the tests of the scalers,
+// to find the hangs on slow backends, goroutine leaks and panics that happy-path mocks hide
+package testutil
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Fault is a fault injected by a fake backend in place of its response to a request
+type Fault int
+
+const (
+	// NoFault leaves the response of the backend untouched
+	NoFault Fault = iota
+	// Reset closes the connection of the request without completing the response
+	Reset
+	// Malformed responds with a payload that can't be decoded
+	Malformed
+	// ServerError responds with an internal server error
+	ServerError
+)
+
+func (f Fault) String() string {
+	switch f {
+	case Reset:
+		return "Reset"
+	case Malformed:
+		return "Malformed"
+	case ServerError:
+		return "ServerError"
+	default:
+		return "NoFault"
+	}
+}
+
+// Faults are the faults a fake backend injects. Counting the requests from 1, a request whose number is a multiple
+// of ResetEvery, MalformedEvery or ErrorEvery gets the fault, in that order of precedence, a zero setting never
+// injects it. Latency delays every response, faulty or not
+type Faults struct {
+	// Latency delays the responses, until the request is canceled or the backend closed
+	Latency time.Duration
+	// ResetEvery resets the connection of every ResetEvery-th request
+	ResetEvery int
+	// MalformedEvery responds with a malformed payload to every MalformedEvery-th request
+	MalformedEvery int
+	// ErrorEvery responds with an internal server error to every ErrorEvery-th request
+	ErrorEvery int
+}
+
+// fault returns the fault of the n-th request
+func (f Faults) fault(n int) Fault {
+	switch {
+	case f.ResetEvery > 0 && n%f.ResetEvery == 0:
+		return Reset
+	case f.MalformedEvery > 0 && n%f.MalformedEvery == 0:
+		return Malformed
+	case f.ErrorEvery > 0 && n%f.ErrorEvery == 0:
+		return ServerError
+	default:
+		return NoFault
+	}
+}
+
+// injector counts the requests of a fake backend and picks their faults
+type injector struct {
+	lock     sync.Mutex
+	faults   Faults
+	requests int
+	injected map[Fault]int
+	closed   chan struct{}
+	close    sync.Once
+}
+
+func newInjector(faults Faults) *injector {
+	return &injector{faults: faults, injected: map[Fault]int{}, closed: make(chan struct{})}
+}
+
+// SetFaults changes the faults injected in the next requests
+func (i *injector) SetFaults(faults Faults) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.faults = faults
+}
+
+// Requests returns how many requests the backend received
+func (i *injector) Requests() int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.requests
+}
+
+// Injected returns how many times the fault was injected
+func (i *injector) Injected(fault Fault) int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.injected[fault]
+}
+
+// next counts a request and waits for the latency before returning its fault. The wait ends early, with the context
+// error, when ctx is done or the backend closed
+func (i *injector) next(ctx context.Context) (Fault, error) {
+	i.lock.Lock()
+	i.requests++
+	fault := i.faults.fault(i.requests)
+	latency := i.faults.Latency
+	if fault != NoFault {
+		i.injected[fault]++
+	}
+	i.lock.Unlock()
+
+	if latency <= 0 {
+		return fault, nil
+	}
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return fault, nil
+	case <-ctx.Done():
+		return fault, ctx.Err()
+	case <-i.closed:
+		return fault, context.Canceled
+	}
+}
+
+// shutdown ends the pending latencies
+func (i *injector) shutdown() {
+	i.close.Do(func() { close(i.closed) })
+}
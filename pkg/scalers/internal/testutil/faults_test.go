@@ -0,0 +1,50 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFaultsFault(t *testing.T) {
+	faults := Faults{ResetEvery: 6, MalformedEvery: 3, ErrorEvery: 2}
+	expected := []Fault{NoFault, ServerError, Malformed, ServerError, NoFault, Reset, NoFault, ServerError, Malformed}
+	for i, fault := range expected {
+		if got := faults.fault(i + 1); got != fault {
+			t.Errorf("request %d: expected %s, got %s", i+1, fault, got)
+		}
+	}
+
+	if got := (Faults{}).fault(1); got != NoFault {
+		t.Errorf("expected no fault without settings, got %s", got)
+	}
+}
+
+func TestInjectorLatency(t *testing.T) {
+	injector := newInjector(Faults{Latency: time.Hour, ErrorEvery: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := injector.next(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline to end the latency, got %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := injector.next(context.Background())
+		done <- err
+	}()
+	injector.shutdown()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("expected the shutdown to end the latency, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the shutdown didn't end the latency")
+	}
+
+	if injector.Requests() != 2 || injector.Injected(ServerError) != 2 {
+		t.Errorf("expected 2 requests with a server error, got %d and %d", injector.Requests(), injector.Injected(ServerError))
+	}
+}
@@ -0,0 +1,48 @@
+package testutil
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// goroutineSettleTimeout is how long the goroutines still exiting, e.g. after a connection was closed, are waited for
+var goroutineSettleTimeout = 5 * time.Second
+
+// VerifyNoGoroutineLeak returns a function failing t when more than tolerance goroutines were started, and are still
+// running, since VerifyNoGoroutineLeak was called. The stacks of all the goroutines are logged to find the leaked ones.
+// The tests running in parallel start goroutines too, the check is only meaningful in the tests that don't
+//
+//	defer testutil.VerifyNoGoroutineLeak(t, 0)()
+func VerifyNoGoroutineLeak(t testing.TB, tolerance int) func() {
+	return verifyNoGoroutineLeak(t, tolerance, goroutineSettleTimeout)
+}
+
+// verifyNoGoroutineLeak is VerifyNoGoroutineLeak waiting up to settleTimeout for the goroutines still exiting
+func verifyNoGoroutineLeak(t testing.TB, tolerance int, settleTimeout time.Duration) func() {
+	baseline := runtime.NumGoroutine()
+	return func() {
+		t.Helper()
+		if count, ok := waitForGoroutines(baseline+tolerance, settleTimeout); !ok {
+			stacks := make([]byte, 1<<20)
+			stacks = stacks[:runtime.Stack(stacks, true)]
+			t.Errorf("%d goroutines running, %d before with a tolerance of %d:\n%s", count, baseline, tolerance, stacks)
+		}
+	}
+}
+
+// waitForGoroutines waits up to timeout for at most limit goroutines to run, it returns how many run and whether
+// they are few enough
+func waitForGoroutines(limit int, timeout time.Duration) (int, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		count := runtime.NumGoroutine()
+		if count <= limit {
+			return count, true
+		}
+		if time.Now().After(deadline) {
+			return count, false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,170 @@
+package testutil
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/proto"
+	"google.golang.org/grpc/status"
+)
+
+// malformedProtobuf is the payload of the Malformed responses of a FaultyGRPCServer, a truncated varint
+var malformedProtobuf = []byte{0xff, 0xff, 0xff, 0xff}
+
+// FaultyGRPCServer is a gRPC server on a local port injecting faults in the responses of its services
+type FaultyGRPCServer struct {
+	*injector
+	// Server is the gRPC server the services are registered on
+	Server *grpc.Server
+	// Addr is the host:port the server listens on
+	Addr string
+
+	listener *trackingListener
+}
+
+// NewFaultyGRPCServer starts a FaultyGRPCServer on a local port with the services register registers, it is stopped
+// at the end of the test. The options are passed to the server, e.g. grpc.Creds to serve TLS
+func NewFaultyGRPCServer(t testing.TB, register func(*grpc.Server), faults Faults, opts ...grpc.ServerOption) *FaultyGRPCServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening for the gRPC server: %s", err)
+	}
+
+	s := &FaultyGRPCServer{
+		injector: newInjector(faults),
+		Addr:     listener.Addr().String(),
+		listener: &trackingListener{Listener: listener, conns: map[net.Conn]struct{}{}},
+	}
+	opts = append(opts,
+		grpc.ForceServerCodec(faultyCodec{encoding.GetCodec(proto.Name)}),
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	s.Server = grpc.NewServer(opts...)
+	register(s.Server)
+
+	go func() {
+		_ = s.Server.Serve(s.listener)
+	}()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close ends the pending latencies and stops the server, closing its connections. It can be called more than once
+func (s *FaultyGRPCServer) Close() {
+	s.shutdown()
+	s.Server.Stop()
+}
+
+// ResetConnections closes the connections of the clients, they have to reconnect
+func (s *FaultyGRPCServer) ResetConnections() {
+	s.listener.closeConnections()
+}
+
+func (s *FaultyGRPCServer) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	fault, err := s.next(ctx)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	switch fault {
+	case Reset:
+		s.ResetConnections()
+		return nil, status.Error(codes.Unavailable, "injected connection reset")
+	case Malformed:
+		return malformedMessage{}, nil
+	case ServerError:
+		return nil, status.Error(codes.Internal, "injected server error")
+	default:
+		return handler(ctx, req)
+	}
+}
+
+func (s *FaultyGRPCServer) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	fault, err := s.next(stream.Context())
+	if err != nil {
+		return status.FromContextError(err).Err()
+	}
+	switch fault {
+	case Reset:
+		s.ResetConnections()
+		return status.Error(codes.Unavailable, "injected connection reset")
+	case Malformed:
+		return handler(srv, malformedStream{stream})
+	case ServerError:
+		return status.Error(codes.Internal, "injected server error")
+	default:
+		return handler(srv, stream)
+	}
+}
+
+// malformedMessage is encoded by faultyCodec as malformedProtobuf
+type malformedMessage struct{}
+
+// malformedStream sends malformedMessage in place of the messages of the stream
+type malformedStream struct {
+	grpc.ServerStream
+}
+
+func (s malformedStream) SendMsg(interface{}) error {
+	return s.ServerStream.SendMsg(malformedMessage{})
+}
+
+// faultyCodec is the proto codec, except for malformedMessage
+type faultyCodec struct {
+	encoding.Codec
+}
+
+func (c faultyCodec) Marshal(v interface{}) ([]byte, error) {
+	if _, ok := v.(malformedMessage); ok {
+		return malformedProtobuf, nil
+	}
+	return c.Codec.Marshal(v)
+}
+
+// trackingListener tracks the connections it accepted to close them on demand
+type trackingListener struct {
+	net.Listener
+	lock  sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tracked := &trackedConn{Conn: conn, listener: l}
+	l.lock.Lock()
+	l.conns[tracked] = struct{}{}
+	l.lock.Unlock()
+	return tracked, nil
+}
+
+func (l *trackingListener) closeConnections() {
+	l.lock.Lock()
+	conns := make([]net.Conn, 0, len(l.conns))
+	for conn := range l.conns {
+		conns = append(conns, conn)
+	}
+	l.lock.Unlock()
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+}
+
+type trackedConn struct {
+	net.Conn
+	listener *trackingListener
+}
+
+func (c *trackedConn) Close() error {
+	c.listener.lock.Lock()
+	delete(c.listener.conns, c)
+	c.listener.lock.Unlock()
+	return c.Conn.Close()
+}
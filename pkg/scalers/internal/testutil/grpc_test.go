@@ -0,0 +1,87 @@
+package testutil
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	health_v1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func newHealthClient(t *testing.T, addr string, creds credentials.TransportCredentials) health_v1.HealthClient {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatal("dialing the server:", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return health_v1.NewHealthClient(conn)
+}
+
+func TestFaultyGRPCServer(t *testing.T) {
+	server := NewFaultyGRPCServer(t, func(s *grpc.Server) {
+		health_v1.RegisterHealthServer(s, health.NewServer())
+	}, Faults{ResetEvery: 4, MalformedEvery: 3, ErrorEvery: 2})
+	client := newHealthClient(t, server.Addr, insecure.NewCredentials())
+
+	expected := []codes.Code{codes.OK, codes.Internal, codes.Internal, codes.Unavailable, codes.OK}
+	for i, code := range expected {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		resp, err := client.Check(ctx, &health_v1.HealthCheckRequest{})
+		cancel()
+		if status.Code(err) != code {
+			t.Errorf("request %d: expected %s, got %v", i+1, code, err)
+		}
+		if code == codes.OK && resp.GetStatus() != health_v1.HealthCheckResponse_SERVING {
+			t.Errorf("request %d: expected the response of the service, got %v", i+1, resp)
+		}
+	}
+
+	if server.Injected(Reset) != 1 || server.Injected(Malformed) != 1 || server.Injected(ServerError) != 1 {
+		t.Errorf("expected a fault of each kind, got %d requests", server.Requests())
+	}
+}
+
+func TestFaultyGRPCServerStream(t *testing.T) {
+	server := NewFaultyGRPCServer(t, func(s *grpc.Server) {
+		health_v1.RegisterHealthServer(s, health.NewServer())
+	}, Faults{MalformedEvery: 1})
+	client := newHealthClient(t, server.Addr, insecure.NewCredentials())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Watch(ctx, &health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal("watching:", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Internal {
+		t.Errorf("expected the malformed message to fail decoding, got %v", err)
+	}
+}
+
+func TestFaultyGRPCServerLatencyAndTLS(t *testing.T) {
+	server := NewFaultyGRPCServer(t, func(s *grpc.Server) {
+		health_v1.RegisterHealthServer(s, health.NewServer())
+	}, Faults{Latency: time.Hour}, grpc.Creds(credentials.NewTLS(SelfSignedTLSConfig(t))))
+	// #nosec G402
+	client := newHealthClient(t, server.Addr, credentials.NewTLS(&tls.Config{InsecureSkipVerify: true}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := client.Check(ctx, &health_v1.HealthCheckRequest{}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+
+	server.SetFaults(Faults{})
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Check(ctx, &health_v1.HealthCheckRequest{}); err != nil {
+		t.Errorf("expected the response of the service over TLS, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package testutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// MalformedJSON is the payload of the Malformed responses of a FaultyHTTPServer, JSON cut short by garbage bytes
+const MalformedJSON = "{\"status\":\"success\",\"data\":{\"result\":[\x00\xff"
+
+// FaultyHTTPServer is an httptest.Server injecting faults in the responses of its handler
+type FaultyHTTPServer struct {
+	*httptest.Server
+	*injector
+}
+
+// NewFaultyHTTPServer starts a FaultyHTTPServer serving handler, it is closed at the end of the test
+func NewFaultyHTTPServer(t testing.TB, handler http.Handler, faults Faults) *FaultyHTTPServer {
+	s := &FaultyHTTPServer{injector: newInjector(faults)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fault, err := s.next(r.Context())
+		if err != nil {
+			return
+		}
+		switch fault {
+		case Reset:
+			resetHTTPConnection(t, w)
+		case Malformed:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(MalformedJSON))
+		case ServerError:
+			http.Error(w, "injected server error", http.StatusInternalServerError)
+		default:
+			handler.ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close ends the pending latencies and closes the server, it can be called more than once
+func (s *FaultyHTTPServer) Close() {
+	s.shutdown()
+	s.Server.Close()
+}
+
+// resetHTTPConnection closes the connection in the middle of the response. Closing it before responding wouldn't do,
+// the clients retry the idempotent requests on a reused connection closed before any response
+func resetHTTPConnection(t testing.TB, w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		t.Errorf("the response writer %T can't be hijacked to reset the connection", w)
+		return
+	}
+	conn, buffer, err := hijacker.Hijack()
+	if err != nil {
+		t.Errorf("hijacking the connection: %s", err)
+		return
+	}
+	defer conn.Close()
+	_, _ = buffer.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 1024\r\n\r\n{")
+	_ = buffer.Flush()
+}
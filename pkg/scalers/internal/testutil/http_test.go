@@ -0,0 +1,80 @@
+package testutil
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFaultyHTTPServer(t *testing.T) {
+	server := NewFaultyHTTPServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success"}`))
+	}), Faults{ResetEvery: 4, MalformedEvery: 3, ErrorEvery: 2})
+
+	get := func() (int, []byte, error) {
+		resp, err := server.Client().Get(server.URL)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, body, err
+	}
+
+	status, body, err := get()
+	if err != nil || status != http.StatusOK || string(body) != `{"status":"success"}` {
+		t.Errorf("request 1: expected the response of the handler, got %d %q %v", status, body, err)
+	}
+
+	status, _, err = get()
+	if err != nil || status != http.StatusInternalServerError {
+		t.Errorf("request 2: expected a server error, got %d %v", status, err)
+	}
+
+	status, body, err = get()
+	var decoded interface{}
+	if err != nil || status != http.StatusOK || json.Unmarshal(body, &decoded) == nil {
+		t.Errorf("request 3: expected a malformed payload, got %d %q %v", status, body, err)
+	}
+
+	if _, _, err = get(); err == nil {
+		t.Error("request 4: expected the connection to be reset")
+	}
+
+	if server.Requests() != 4 || server.Injected(Reset) != 1 || server.Injected(Malformed) != 1 || server.Injected(ServerError) != 1 {
+		t.Errorf("expected 4 requests with a fault of each kind, got %d requests", server.Requests())
+	}
+}
+
+func TestFaultyHTTPServerLatency(t *testing.T) {
+	server := NewFaultyHTTPServer(t, http.NotFoundHandler(), Faults{Latency: time.Hour})
+
+	client := server.Client()
+	client.Timeout = 50 * time.Millisecond
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("expected the client to time out")
+	}
+
+	// the server doesn't wait for the latency of the pending requests to close
+	go func() {
+		if resp, err := server.Client().Get(server.URL); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	for server.Requests() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	closed := make(chan struct{})
+	go func() {
+		server.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing the server waited for the latency")
+	}
+}
@@ -0,0 +1,139 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+const (
+	// defaultLifecycleIterations is how many scalers StressLifecycle builds, polls and closes, 100 with -short
+	defaultLifecycleIterations = 1000
+	// defaultLifecyclePollTimeout is the deadline of the context of a poll
+	defaultLifecyclePollTimeout = 5 * time.Second
+	// lifecyclePollGrace is how long a poll may run past the deadline of its context before it is a hang
+	lifecyclePollGrace = time.Second
+)
+
+// LifecycleScaler is the part of a scaler StressLifecycle calls itself, scalers.Scaler implements it
+type LifecycleScaler interface {
+	IsActive(ctx context.Context) (bool, error)
+	Close(ctx context.Context) error
+}
+
+// Lifecycle is how StressLifecycle builds, polls and closes the scalers
+type Lifecycle struct {
+	// Build creates a new scaler, it is called once per iteration
+	Build func() (LifecycleScaler, error)
+	// Poll polls the scaler, IsActive by default. Its errors are expected from faulty backends and only counted, but it
+	// must return within lifecyclePollGrace of the deadline of its context
+	Poll func(ctx context.Context, scaler LifecycleScaler) error
+	// Iterations is how many scalers are built, polled and closed, defaultLifecycleIterations by default
+	Iterations int
+	// PollTimeout is the deadline of the context of a poll, defaultLifecyclePollTimeout by default
+	PollTimeout time.Duration
+	// Settle is called before the goroutines are counted, e.g. to close the connections the backends keep alive
+	Settle func()
+	// GoroutineTolerance is how many more goroutines may run after the iterations than after the first one
+	GoroutineTolerance int
+	// SettleTimeout is how long the goroutines still exiting after the iterations are waited for, e.g. the cleaners
+	// of connection pools sleeping between their runs. 5s by default
+	SettleTimeout time.Duration
+}
+
+// LifecycleStats are the outcomes of the polls of StressLifecycle
+type LifecycleStats struct {
+	Polls      int
+	PollErrors int
+}
+
+// StressLifecycle builds, polls and closes a scaler Iterations times, closing each one twice. It fails t when a build
+// fails, a poll hangs, a Close panics or the goroutines grew over the iterations. The goroutines the first iteration
+// starts for good, e.g. for the connection pools shared by the scalers, aren't counted as grown
+func StressLifecycle(t testing.TB, lifecycle Lifecycle) LifecycleStats {
+	t.Helper()
+	if lifecycle.Poll == nil {
+		lifecycle.Poll = func(ctx context.Context, scaler LifecycleScaler) error {
+			_, err := scaler.IsActive(ctx)
+			return err
+		}
+	}
+	if lifecycle.Iterations <= 0 {
+		lifecycle.Iterations = defaultLifecycleIterations
+		if testing.Short() {
+			lifecycle.Iterations /= 10
+		}
+	}
+	if lifecycle.PollTimeout <= 0 {
+		lifecycle.PollTimeout = defaultLifecyclePollTimeout
+	}
+	if lifecycle.SettleTimeout <= 0 {
+		lifecycle.SettleTimeout = goroutineSettleTimeout
+	}
+	settle := func() {
+		if lifecycle.Settle != nil {
+			lifecycle.Settle()
+		}
+	}
+
+	var stats LifecycleStats
+	if err := runLifecycle(lifecycle, &stats); err != nil {
+		t.Fatalf("iteration 1: %s", err)
+	}
+	settle()
+	verify := verifyNoGoroutineLeak(t, lifecycle.GoroutineTolerance, lifecycle.SettleTimeout)
+	for i := 2; i <= lifecycle.Iterations; i++ {
+		if err := runLifecycle(lifecycle, &stats); err != nil {
+			t.Fatalf("iteration %d: %s", i, err)
+		}
+	}
+	settle()
+	verify()
+
+	t.Logf("%d iterations, %d of %d polls failed", lifecycle.Iterations, stats.PollErrors, stats.Polls)
+	return stats
+}
+
+// runLifecycle builds, polls and closes twice a scaler
+func runLifecycle(lifecycle Lifecycle, stats *LifecycleStats) error {
+	scaler, err := lifecycle.Build()
+	if err != nil {
+		return fmt.Errorf("building the scaler: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lifecycle.PollTimeout)
+	defer cancel()
+	polled := make(chan error, 1)
+	go func() {
+		polled <- lifecycle.Poll(ctx, scaler)
+	}()
+	select {
+	case err := <-polled:
+		stats.Polls++
+		if err != nil {
+			stats.PollErrors++
+		}
+	case <-time.After(lifecycle.PollTimeout + lifecyclePollGrace):
+		return fmt.Errorf("the poll is still running %s after the deadline of its context", lifecyclePollGrace)
+	}
+
+	for call := 1; call <= 2; call++ {
+		if err := closeScaler(scaler); err != nil {
+			return fmt.Errorf("close %d: %s", call, err)
+		}
+	}
+	return nil
+}
+
+// closeScaler closes the scaler, turning a panic into an error. The errors of Close are ignored, the backend may be
+// unavailable, only the panics are
+func closeScaler(scaler LifecycleScaler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panicked: %v", r)
+		}
+	}()
+	_ = scaler.Close(context.Background())
+	return nil
+}
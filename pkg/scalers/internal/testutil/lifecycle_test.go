@@ -0,0 +1,196 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingT records the failures of a helper instead of failing the test
+type recordingT struct {
+	testing.TB
+	lock     sync.Mutex
+	failures []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Logf(string, ...interface{}) {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// run runs the helper like a test, Fatalf stopping it
+func (r *recordingT) run(helper func(t testing.TB)) []string {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		helper(r)
+	}()
+	<-done
+	return r.failures
+}
+
+// settleGoroutines waits for the goroutines of the previous tests, e.g. of closed connections, to exit so they don't
+// hide the goroutines leaked on purpose
+func settleGoroutines() {
+	count := runtime.NumGoroutine()
+	for {
+		time.Sleep(50 * time.Millisecond)
+		previous := count
+		if count = runtime.NumGoroutine(); count == previous {
+			return
+		}
+	}
+}
+
+type fakeLifecycleScaler struct {
+	isActive func(ctx context.Context) (bool, error)
+	close    func() error
+}
+
+func (s *fakeLifecycleScaler) IsActive(ctx context.Context) (bool, error) {
+	if s.isActive == nil {
+		return true, nil
+	}
+	return s.isActive(ctx)
+}
+
+func (s *fakeLifecycleScaler) Close(context.Context) error {
+	if s.close == nil {
+		return nil
+	}
+	return s.close()
+}
+
+func TestStressLifecycle(t *testing.T) {
+	var builds int
+	stats := StressLifecycle(t, Lifecycle{
+		Build: func() (LifecycleScaler, error) {
+			builds++
+			n := builds
+			return &fakeLifecycleScaler{isActive: func(context.Context) (bool, error) {
+				if n%2 == 0 {
+					return false, errors.New("backend unavailable")
+				}
+				return true, nil
+			}}, nil
+		},
+		Iterations: 10,
+	})
+
+	if builds != 10 || stats.Polls != 10 || stats.PollErrors != 5 {
+		t.Errorf("expected 10 builds and polls with 5 errors, got %d builds and %+v", builds, stats)
+	}
+}
+
+func TestStressLifecycleFailures(t *testing.T) {
+	settleTimeout := goroutineSettleTimeout
+	goroutineSettleTimeout = 100 * time.Millisecond
+	defer func() { goroutineSettleTimeout = settleTimeout }()
+
+	leaked := make(chan struct{})
+	defer close(leaked)
+	settleGoroutines()
+
+	for _, testData := range []struct {
+		name      string
+		lifecycle Lifecycle
+		failure   string
+	}{
+		{
+			name: "build error",
+			lifecycle: Lifecycle{Build: func() (LifecycleScaler, error) {
+				return nil, errors.New("invalid metadata")
+			}},
+			failure: "iteration 1: building the scaler: invalid metadata",
+		},
+		{
+			name: "hanging poll",
+			lifecycle: Lifecycle{
+				Build: func() (LifecycleScaler, error) {
+					return &fakeLifecycleScaler{isActive: func(context.Context) (bool, error) {
+						<-leaked
+						return false, nil
+					}}, nil
+				},
+				PollTimeout: 10 * time.Millisecond,
+			},
+			failure: "iteration 1: the poll is still running",
+		},
+		{
+			name: "panic on the second close",
+			lifecycle: Lifecycle{
+				Build: func() (LifecycleScaler, error) {
+					closed := make(chan struct{})
+					return &fakeLifecycleScaler{close: func() error {
+						close(closed)
+						return nil
+					}}, nil
+				},
+			},
+			failure: "iteration 1: close 2: panicked: close of closed channel",
+		},
+		{
+			name: "goroutine leak",
+			lifecycle: Lifecycle{
+				Build: func() (LifecycleScaler, error) {
+					go func() { <-leaked }()
+					return &fakeLifecycleScaler{}, nil
+				},
+				Iterations:         10,
+				GoroutineTolerance: 5,
+			},
+			failure: "goroutines running",
+		},
+	} {
+		failures := (&recordingT{}).run(func(t testing.TB) {
+			StressLifecycle(t, testData.lifecycle)
+		})
+		if len(failures) != 1 || !strings.Contains(failures[0], testData.failure) {
+			t.Errorf("%s: expected a failure containing %q, got %q", testData.name, testData.failure, failures)
+		}
+	}
+}
+
+func TestVerifyNoGoroutineLeak(t *testing.T) {
+	settleTimeout := goroutineSettleTimeout
+	goroutineSettleTimeout = 100 * time.Millisecond
+	defer func() { goroutineSettleTimeout = settleTimeout }()
+
+	// the goroutines exiting within the settle timeout aren't leaked
+	failures := (&recordingT{}).run(func(t testing.TB) {
+		verify := VerifyNoGoroutineLeak(t, 0)
+		go time.Sleep(20 * time.Millisecond)
+		verify()
+	})
+	if len(failures) != 0 {
+		t.Errorf("expected no leak, got %q", failures)
+	}
+
+	stop := make(chan struct{})
+	settleGoroutines()
+	failures = (&recordingT{}).run(func(t testing.TB) {
+		verify := VerifyNoGoroutineLeak(t, 1)
+		go func() { <-stop }()
+		go func() { <-stop }()
+		verify()
+	})
+	close(stop)
+	if len(failures) != 1 || !strings.Contains(failures[0], "tolerance of 1") {
+		t.Errorf("expected a leak, got %q", failures)
+	}
+}
@@ -0,0 +1,242 @@
+package scalers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultConfluentCloudMetricsAPIURL = "https://api.telemetry.confluent.cloud"
+	confluentCloudConsumerLagMetric    = "io.confluent.kafka.server/consumer_lag_offsets"
+
+	// the metrics are published with a delay of a couple of minutes, the latest point of each partition within
+	// the interval is used
+	confluentCloudLagInterval    = "now-5m|m/now-1m|m"
+	confluentCloudLagGranularity = "PT1M"
+	confluentCloudQueryPageSize  = 1000
+	// confluentCloudMaxQueryPages bounds the pages read for a consumer group, 1000 partitions each
+	confluentCloudMaxQueryPages = 10
+)
+
+// confluentCloudMetricsClient queries the lag of a consumer group from the Confluent Cloud Metrics API
+// rather than from the brokers
+type confluentCloudMetricsClient struct {
+	httpClient *http.Client
+	url        string
+	apiKey     string
+	apiSecret  string
+	clusterID  string
+	group      string
+	topic      string
+
+	// the descriptor of the lag metric is checked once, to fail with a clear error when it isn't available
+	descriptorLock    sync.Mutex
+	descriptorChecked bool
+}
+
+// confluentCloudDescriptorsResponse is the response of /v2/metrics/cloud/descriptors/metrics
+type confluentCloudDescriptorsResponse struct {
+	Data []struct {
+		Name   string `json:"name"`
+		Labels []struct {
+			Key string `json:"key"`
+		} `json:"labels"`
+	} `json:"data"`
+	Meta struct {
+		Pagination struct {
+			NextPageToken string `json:"next_page_token"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+type confluentCloudFilter struct {
+	Field   string                 `json:"field,omitempty"`
+	Op      string                 `json:"op"`
+	Value   string                 `json:"value,omitempty"`
+	Filters []confluentCloudFilter `json:"filters,omitempty"`
+}
+
+type confluentCloudAggregation struct {
+	Metric string `json:"metric"`
+}
+
+// confluentCloudQueryRequest is the body of /v2/metrics/cloud/query
+type confluentCloudQueryRequest struct {
+	Aggregations []confluentCloudAggregation `json:"aggregations"`
+	Filter       confluentCloudFilter        `json:"filter"`
+	GroupBy      []string                    `json:"group_by"`
+	Granularity  string                      `json:"granularity"`
+	Intervals    []string                    `json:"intervals"`
+	Limit        int                         `json:"limit"`
+}
+
+// confluentCloudQueryResponse is the response of /v2/metrics/cloud/query, grouped by topic and partition
+type confluentCloudQueryResponse struct {
+	Data []struct {
+		Timestamp string  `json:"timestamp"`
+		Value     float64 `json:"value"`
+		Topic     string  `json:"metric.topic"`
+		Partition string  `json:"metric.partition"`
+	} `json:"data"`
+	Meta struct {
+		Pagination struct {
+			NextPageToken string `json:"next_page_token"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+// getLag returns the total lag of the consumer group and the number of partitions it is computed over
+func (c *confluentCloudMetricsClient) getLag(ctx context.Context) (int64, int64, error) {
+	if err := c.checkDescriptor(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	request := confluentCloudQueryRequest{
+		Aggregations: []confluentCloudAggregation{{Metric: confluentCloudConsumerLagMetric}},
+		Filter:       c.lagFilter(),
+		GroupBy:      []string{"metric.topic", "metric.partition"},
+		Granularity:  confluentCloudLagGranularity,
+		Intervals:    []string{confluentCloudLagInterval},
+		Limit:        confluentCloudQueryPageSize,
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the latest point of each partition, the timestamps are RFC 3339 in UTC so they compare as strings
+	type point struct {
+		timestamp string
+		lag       int64
+	}
+	partitions := map[string]point{}
+	pageToken := ""
+	for page := 0; page < confluentCloudMaxQueryPages; page++ {
+		queryURL := c.url + "/v2/metrics/cloud/query"
+		if pageToken != "" {
+			queryURL += "?page_token=" + url.QueryEscape(pageToken)
+		}
+		responseBody, err := c.do(ctx, "POST", queryURL, body)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		var response confluentCloudQueryResponse
+		if err := json.Unmarshal(responseBody, &response); err != nil {
+			return 0, 0, fmt.Errorf("error parsing the confluent cloud metrics: %s", err)
+		}
+		for _, data := range response.Data {
+			key := data.Topic + "/" + data.Partition
+			if latest, ok := partitions[key]; !ok || data.Timestamp > latest.timestamp {
+				partitions[key] = point{data.Timestamp, int64(data.Value)}
+			}
+		}
+
+		pageToken = response.Meta.Pagination.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+	if pageToken != "" {
+		kafkaLog.Info("The lag of the consumer group spans more pages than read, the lag may be too low", "group", c.group, "pages", confluentCloudMaxQueryPages)
+	}
+
+	totalLag := int64(0)
+	for _, point := range partitions {
+		totalLag += point.lag
+	}
+	kafkaLog.V(1).Info(fmt.Sprintf("Group %s has a lag of %d over %d partitions in the confluent cloud metrics", c.group, totalLag, len(partitions)))
+	return totalLag, int64(len(partitions)), nil
+}
+
+func (c *confluentCloudMetricsClient) lagFilter() confluentCloudFilter {
+	filters := []confluentCloudFilter{
+		{Field: "resource.kafka.id", Op: "EQ", Value: c.clusterID},
+		{Field: "metric.consumer_group_id", Op: "EQ", Value: c.group},
+	}
+	if c.topic != "" {
+		filters = append(filters, confluentCloudFilter{Field: "metric.topic", Op: "EQ", Value: c.topic})
+	}
+	return confluentCloudFilter{Op: "AND", Filters: filters}
+}
+
+// checkDescriptor checks the lag metric is described for the kafka resources, with the labels it is grouped by
+func (c *confluentCloudMetricsClient) checkDescriptor(ctx context.Context) error {
+	c.descriptorLock.Lock()
+	defer c.descriptorLock.Unlock()
+
+	if c.descriptorChecked {
+		return nil
+	}
+
+	pageToken := ""
+	for {
+		descriptorsURL := c.url + "/v2/metrics/cloud/descriptors/metrics?resource_type=kafka"
+		if pageToken != "" {
+			descriptorsURL += "&page_token=" + url.QueryEscape(pageToken)
+		}
+		body, err := c.do(ctx, "GET", descriptorsURL, nil)
+		if err != nil {
+			return err
+		}
+
+		var response confluentCloudDescriptorsResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return fmt.Errorf("error parsing the confluent cloud metric descriptors: %s", err)
+		}
+		for _, descriptor := range response.Data {
+			if descriptor.Name != confluentCloudConsumerLagMetric {
+				continue
+			}
+			labels := map[string]bool{}
+			for _, label := range descriptor.Labels {
+				labels[label.Key] = true
+			}
+			for _, label := range []string{"consumer_group_id", "topic", "partition"} {
+				if !labels[label] {
+					return fmt.Errorf("the confluent cloud metric %s has no %s label", confluentCloudConsumerLagMetric, label)
+				}
+			}
+			c.descriptorChecked = true
+			return nil
+		}
+
+		pageToken = response.Meta.Pagination.NextPageToken
+		if pageToken == "" {
+			return fmt.Errorf("the confluent cloud metric %s isn't available", confluentCloudConsumerLagMetric)
+		}
+	}
+}
+
+func (c *confluentCloudMetricsClient) do(ctx context.Context, method, requestURL string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.apiKey, c.apiSecret)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("confluent cloud metrics API returned %d: %s", res.StatusCode, strings.TrimSpace(string(responseBody)))
+	}
+	return responseBody, nil
+}
@@ -0,0 +1,214 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testConfluentCloudDescriptors = `{"data":[
+{"name":"io.confluent.kafka.server/received_bytes","description":"The delta count of bytes of the customer's data received from the network.","type":"COUNTER_INT64","unit":"By","lifecycle_stage":"GENERAL_AVAILABILITY","labels":[{"key":"topic","description":"Name of the Kafka topic."}],"resources":["kafka"]},
+{"name":"io.confluent.kafka.server/consumer_lag_offsets","description":"The lag between a group member's committed offset and the partition's high watermark.","type":"GAUGE_INT64","unit":"1","lifecycle_stage":"PREVIEW","labels":[{"key":"consumer_group_id","description":"Consumer group ID."},{"key":"topic","description":"Name of the Kafka topic."},{"key":"partition","description":"Partition ID."}],"resources":["kafka"]}
+],"meta":{"pagination":{"page_size":100,"total_size":2}},"links":{}}`
+
+const testConfluentCloudDescriptorsWithoutLag = `{"data":[
+{"name":"io.confluent.kafka.server/received_bytes","description":"The delta count of bytes of the customer's data received from the network.","type":"COUNTER_INT64","unit":"By","lifecycle_stage":"GENERAL_AVAILABILITY","labels":[{"key":"topic","description":"Name of the Kafka topic."}],"resources":["kafka"]}
+],"meta":{"pagination":{"page_size":100,"total_size":1}},"links":{}}`
+
+// the first page of the lag of my-group, partition 0 of my-topic has two points, the latest one is used
+const testConfluentCloudLagPage1 = `{"data":[
+{"timestamp":"2022-06-01T10:01:00Z","value":30.0,"metric.topic":"my-topic","metric.partition":"0"},
+{"timestamp":"2022-06-01T10:02:00Z","value":25.0,"metric.topic":"my-topic","metric.partition":"0"},
+{"timestamp":"2022-06-01T10:02:00Z","value":10.0,"metric.topic":"my-topic","metric.partition":"1"}
+],"meta":{"pagination":{"page_size":1000,"next_page_token":"eyJ2ZXJzaW9uIjoiMSJ9"}}}`
+
+const testConfluentCloudLagPage2 = `{"data":[
+{"timestamp":"2022-06-01T10:02:00Z","value":0.0,"metric.topic":"my-topic","metric.partition":"2"},
+{"timestamp":"2022-06-01T10:02:00Z","value":100.0,"metric.topic":"other-topic","metric.partition":"0"}
+],"meta":{"pagination":{"page_size":1000}}}`
+
+const testConfluentCloudLagPage2WithTopic = `{"data":[
+{"timestamp":"2022-06-01T10:02:00Z","value":0.0,"metric.topic":"my-topic","metric.partition":"2"}
+],"meta":{"pagination":{"page_size":1000}}}`
+
+const testConfluentCloudNoLag = `{"data":[],"meta":{"pagination":{"page_size":1000}}}`
+
+type confluentCloudTestServer struct {
+	*httptest.Server
+	descriptors         string
+	descriptorsRequests int
+	queries             []confluentCloudQueryRequest
+}
+
+// newConfluentCloudTestServer serves the descriptors and the lag of my-group on two pages, the lag of
+// other-topic is only served without a topic filter
+func newConfluentCloudTestServer(t *testing.T, descriptors string, lag bool) *confluentCloudTestServer {
+	s := &confluentCloudTestServer{descriptors: descriptors}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, secret, ok := r.BasicAuth(); !ok || key != "key" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"errors":[{"status":"401","detail":"Invalid API key"}]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/metrics/cloud/descriptors/metrics":
+			s.descriptorsRequests++
+			_, _ = w.Write([]byte(s.descriptors))
+		case "/v2/metrics/cloud/query":
+			var query confluentCloudQueryRequest
+			if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+				t.Error(err)
+			}
+			s.queries = append(s.queries, query)
+			filtered := len(query.Filter.Filters) == 3
+			switch {
+			case !lag:
+				_, _ = w.Write([]byte(testConfluentCloudNoLag))
+			case r.URL.Query().Get("page_token") == "":
+				_, _ = w.Write([]byte(testConfluentCloudLagPage1))
+			case filtered:
+				_, _ = w.Write([]byte(testConfluentCloudLagPage2WithTopic))
+			default:
+				_, _ = w.Write([]byte(testConfluentCloudLagPage2))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return s
+}
+
+func newConfluentCloudTestScaler(t *testing.T, server *confluentCloudTestServer, metadata map[string]string) *kafkaScaler {
+	triggerMetadata := map[string]string{"lagSource": "confluentCloudMetricsAPI", "confluentClusterID": "lkc-12345", "consumerGroup": "my-group", "metricsAPIURL": server.URL}
+	for key, value := range metadata {
+		triggerMetadata[key] = value
+	}
+	meta, err := parseKafkaMetadata(&ScalerConfig{TriggerMetadata: triggerMetadata, AuthParams: validConfluentCloudAuthParams})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	return &kafkaScaler{metadata: meta, confluentCloudClient: newConfluentCloudMetricsClient(meta, http.DefaultClient)}
+}
+
+func TestKafkaConfluentCloudGetLag(t *testing.T) {
+	for _, testData := range []struct {
+		name       string
+		metadata   map[string]string
+		lag        int64
+		partitions int64
+	}{
+		{"all the topics of the group", map[string]string{}, 135, 4},
+		{"a single topic", map[string]string{"topic": "my-topic"}, 35, 3},
+	} {
+		server := newConfluentCloudTestServer(t, testConfluentCloudDescriptors, true)
+		s := newConfluentCloudTestScaler(t, server, testData.metadata)
+
+		lag, partitions, err := s.confluentCloudClient.getLag(context.Background())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", testData.name, err)
+		}
+		if lag != testData.lag || partitions != testData.partitions {
+			t.Errorf("%s: expected a lag of %d over %d partitions, got %d over %d", testData.name, testData.lag, testData.partitions, lag, partitions)
+		}
+
+		query := server.queries[0]
+		if query.Aggregations[0].Metric != confluentCloudConsumerLagMetric {
+			t.Errorf("%s: unexpected metric %s", testData.name, query.Aggregations[0].Metric)
+		}
+		if query.Filter.Filters[0].Value != "lkc-12345" || query.Filter.Filters[1].Value != "my-group" {
+			t.Errorf("%s: unexpected filter %+v", testData.name, query.Filter)
+		}
+		server.Close()
+	}
+}
+
+func TestKafkaConfluentCloudDescriptor(t *testing.T) {
+	server := newConfluentCloudTestServer(t, testConfluentCloudDescriptorsWithoutLag, true)
+	defer server.Close()
+	s := newConfluentCloudTestScaler(t, server, nil)
+
+	if _, _, err := s.confluentCloudClient.getLag(context.Background()); err == nil {
+		t.Error("Expected an error without the lag metric")
+	}
+
+	// the descriptors are only read until the lag metric is found
+	server.descriptors = testConfluentCloudDescriptors
+	for i := 0; i < 2; i++ {
+		if _, _, err := s.confluentCloudClient.getLag(context.Background()); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+	}
+	if server.descriptorsRequests != 2 {
+		t.Errorf("Expected the descriptors to be read twice, got %d", server.descriptorsRequests)
+	}
+}
+
+func TestKafkaConfluentCloudGetMetrics(t *testing.T) {
+	for _, testData := range []struct {
+		name     string
+		metadata map[string]string
+		lag      bool
+		value    int64
+		isActive bool
+	}{
+		{"the lag", map[string]string{"lagThreshold": "10", "allowIdleConsumers": "true"}, true, 135, true},
+		// like with the brokers, the lag is capped to lagThreshold per partition
+		{"the lag capped by the partitions", map[string]string{"lagThreshold": "10"}, true, 40, true},
+		{"no lag", map[string]string{}, false, 0, false},
+	} {
+		server := newConfluentCloudTestServer(t, testConfluentCloudDescriptors, testData.lag)
+		s := newConfluentCloudTestScaler(t, server, testData.metadata)
+
+		metrics, err := s.GetMetrics(context.Background(), "s0-kafka-my-group-topics", nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", testData.name, err)
+		}
+		if value := metrics[0].Value.Value(); value != testData.value {
+			t.Errorf("%s: expected %d, got %d", testData.name, testData.value, value)
+		}
+
+		isActive, err := s.IsActive(context.Background())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", testData.name, err)
+		}
+		if isActive != testData.isActive {
+			t.Errorf("%s: expected active %t, got %t", testData.name, testData.isActive, isActive)
+		}
+
+		if err := s.Close(context.Background()); err != nil {
+			t.Errorf("%s: unexpected error closing the scaler: %s", testData.name, err)
+		}
+		server.Close()
+	}
+}
+
+func TestKafkaConfluentCloudMetricName(t *testing.T) {
+	for _, metadata := range []map[string]string{
+		{"consumerGroup": "my-group", "topic": "my-topic"},
+		{"consumerGroup": "my-group"},
+	} {
+		brokerMetadata := map[string]string{"bootstrapServers": "foobar:9092"}
+		confluentCloudMetadata := map[string]string{"lagSource": "confluentCloudMetricsAPI", "confluentClusterID": "lkc-12345"}
+		for key, value := range metadata {
+			brokerMetadata[key] = value
+			confluentCloudMetadata[key] = value
+		}
+
+		brokerMeta, err := parseKafkaMetadata(&ScalerConfig{TriggerMetadata: brokerMetadata, AuthParams: validWithoutAuthParams})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		confluentCloudMeta, err := parseKafkaMetadata(&ScalerConfig{TriggerMetadata: confluentCloudMetadata, AuthParams: validConfluentCloudAuthParams})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+
+		brokerName := (&kafkaScaler{metadata: brokerMeta}).GetMetricSpecForScaling(context.Background())[0].External.Metric.Name
+		confluentCloudName := (&kafkaScaler{metadata: confluentCloudMeta}).GetMetricSpecForScaling(context.Background())[0].External.Metric.Name
+		if brokerName != confluentCloudName {
+			t.Errorf("Expected the same metric name with both lag sources, got %s and %s", brokerName, confluentCloudName)
+		}
+	}
+}
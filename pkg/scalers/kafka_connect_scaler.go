@@ -0,0 +1,397 @@
+package scalers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/authentication"
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	kafkaConnectMetricRunningTasks = "runningTasks"
+	kafkaConnectMetricFailedTasks  = "failedTasks"
+	kafkaConnectMetricLag          = "lag"
+
+	kafkaConnectStateRunning = "RUNNING"
+	kafkaConnectStateFailed  = "FAILED"
+
+	// defaultKafkaConnectLagMetricName is the lag of a Debezium connector as exported by the JMX exporter
+	// with the configuration of the Debezium images
+	defaultKafkaConnectLagMetricName = "debezium_metrics_MilliSecondsBehindSource"
+
+	// kafkaConnectMaxAttempts is how many times a request answered with 409 is sent, Kafka Connect answers
+	// 409 while the workers rebalance or the config of the connector changes
+	kafkaConnectMaxAttempts      = 3
+	defaultKafkaConnectRetryWait = time.Second
+)
+
+type kafkaConnectScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *kafkaConnectMetadata
+	httpClient *http.Client
+	retryWait  time.Duration
+}
+
+type kafkaConnectMetadata struct {
+	connectURL            string
+	connectorName         string
+	metric                string
+	metricsURL            string
+	lagMetricName         string
+	targetValue           int64
+	activationTargetValue float64
+	unsafeSsl             bool
+	connectAuth           *authentication.AuthMeta
+	scalerIndex           int
+}
+
+// kafkaConnectStatus is the response of /connectors/{name}/status
+type kafkaConnectStatus struct {
+	Name      string `json:"name"`
+	Connector struct {
+		State    string `json:"state"`
+		WorkerID string `json:"worker_id"`
+	} `json:"connector"`
+	Tasks []struct {
+		ID       int    `json:"id"`
+		State    string `json:"state"`
+		WorkerID string `json:"worker_id"`
+	} `json:"tasks"`
+}
+
+// kafkaConnectTask is an item of the response of /connectors/{name}/tasks
+type kafkaConnectTask struct {
+	ID struct {
+		Connector string `json:"connector"`
+		Task      int    `json:"task"`
+	} `json:"id"`
+}
+
+var kafkaConnectLog = logf.Log.WithName("kafka_connect_scaler")
+
+// NewKafkaConnectScaler creates a new kafkaConnectScaler
+func NewKafkaConnectScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseKafkaConnectMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing kafka connect metadata: %s", err)
+	}
+
+	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, meta.unsafeSsl)
+
+	if meta.connectAuth != nil && (meta.connectAuth.CA != "" || meta.connectAuth.EnableTLS || meta.connectAuth.HTTPVersion != "") {
+		// create http.RoundTripper with auth settings from ScalerConfig, keeping unsafeSsl of the default transport
+		meta.connectAuth.UnsafeSsl = meta.unsafeSsl
+		if httpClient.Transport, err = authentication.CreateHTTPRoundTripper(
+			authentication.NetHTTP,
+			meta.connectAuth,
+		); err != nil {
+			kafkaConnectLog.V(1).Error(err, "init kafka connect client http transport")
+			return nil, err
+		}
+	}
+
+	return &kafkaConnectScaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(httpClient, config),
+		retryWait:  defaultKafkaConnectRetryWait,
+	}, nil
+}
+
+func parseKafkaConnectMetadata(config *ScalerConfig) (*kafkaConnectMetadata, error) {
+	meta := kafkaConnectMetadata{}
+	meta.lagMetricName = defaultKafkaConnectLagMetricName
+
+	if val, ok := config.TriggerMetadata["connectURL"]; ok && val != "" {
+		if _, err := url.ParseRequestURI(val); err != nil {
+			return nil, fmt.Errorf("error parsing connectURL: %s", err)
+		}
+		meta.connectURL = strings.TrimSuffix(val, "/")
+	} else {
+		return nil, fmt.Errorf("no connectURL given")
+	}
+
+	if val, ok := config.TriggerMetadata["connectorName"]; ok && val != "" {
+		meta.connectorName = val
+	} else {
+		return nil, fmt.Errorf("no connectorName given")
+	}
+
+	if val, ok := config.TriggerMetadata["metric"]; ok && val != "" {
+		switch val {
+		case kafkaConnectMetricRunningTasks, kafkaConnectMetricFailedTasks, kafkaConnectMetricLag:
+			meta.metric = val
+		default:
+			return nil, fmt.Errorf("metric must be %s, %s or %s, got %s", kafkaConnectMetricRunningTasks, kafkaConnectMetricFailedTasks, kafkaConnectMetricLag, val)
+		}
+	} else {
+		return nil, fmt.Errorf("no metric given")
+	}
+
+	if val, ok := config.TriggerMetadata["metricsURL"]; ok && val != "" {
+		if _, err := url.ParseRequestURI(val); err != nil {
+			return nil, fmt.Errorf("error parsing metricsURL: %s", err)
+		}
+		meta.metricsURL = val
+	} else if meta.metric == kafkaConnectMetricLag {
+		return nil, fmt.Errorf("no metricsURL given, it's required by the %s metric", kafkaConnectMetricLag)
+	}
+
+	if val, ok := config.TriggerMetadata["lagMetricName"]; ok && val != "" {
+		meta.lagMetricName = val
+	}
+
+	if val, ok := config.TriggerMetadata["targetValue"]; ok {
+		targetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetValue: %s", err)
+		}
+		if targetValue <= 0 {
+			return nil, fmt.Errorf("targetValue must be greater than 0")
+		}
+		meta.targetValue = targetValue
+	} else {
+		return nil, fmt.Errorf("no targetValue given")
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetValue"]; ok {
+		activationTargetValue, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetValue: %s", err)
+		}
+		if activationTargetValue < 0 {
+			return nil, fmt.Errorf("activationTargetValue must not be negative")
+		}
+		meta.activationTargetValue = activationTargetValue
+	}
+
+	if val, ok := config.TriggerMetadata["unsafeSsl"]; ok {
+		unsafeSsl, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing unsafeSsl: %s", err)
+		}
+		meta.unsafeSsl = unsafeSsl
+	}
+
+	auth, err := authentication.GetAuthConfigs(config.TriggerMetadata, config.AuthParams)
+	if err != nil {
+		return nil, err
+	}
+	meta.connectAuth = auth
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// IsActive checks if the value of the metric is above the activation target
+func (s *kafkaConnectScaler) IsActive(ctx context.Context) (bool, error) {
+	value, err := s.getValue(ctx)
+	if err != nil {
+		kafkaConnectLog.Error(err, "error getting the kafka connect metric")
+		return false, err
+	}
+
+	return value > s.metadata.activationTargetValue, nil
+}
+
+func (s *kafkaConnectScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *kafkaConnectScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("kafka-connect-%s-%s", s.metadata.connectorName, s.metadata.metric))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the value of the metric for the connector
+func (s *kafkaConnectScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	value, err := s.getValue(ctx)
+	if err != nil {
+		kafkaConnectLog.Error(err, "error getting the kafka connect metric")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+func (s *kafkaConnectScaler) getValue(ctx context.Context) (float64, error) {
+	switch s.metadata.metric {
+	case kafkaConnectMetricRunningTasks:
+		status, err := s.getStatus(ctx)
+		if err != nil {
+			return -1, err
+		}
+		return float64(countKafkaConnectTasks(status, kafkaConnectStateRunning)), nil
+	case kafkaConnectMetricFailedTasks:
+		status, err := s.getStatus(ctx)
+		if err != nil {
+			return -1, err
+		}
+		// a failed connector has no task running, none of its tasks is reported then
+		if status.Connector.State == kafkaConnectStateFailed {
+			tasks, err := s.getTasks(ctx)
+			if err != nil {
+				return -1, err
+			}
+			if len(tasks) == 0 {
+				return 1, nil
+			}
+			return float64(len(tasks)), nil
+		}
+		return float64(countKafkaConnectTasks(status, kafkaConnectStateFailed)), nil
+	default:
+		return s.getLag(ctx)
+	}
+}
+
+func countKafkaConnectTasks(status *kafkaConnectStatus, state string) int {
+	count := 0
+	for _, task := range status.Tasks {
+		if task.State == state {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *kafkaConnectScaler) getStatus(ctx context.Context) (*kafkaConnectStatus, error) {
+	body, err := s.get(ctx, fmt.Sprintf("%s/connectors/%s/status", s.metadata.connectURL, url.PathEscape(s.metadata.connectorName)))
+	if err != nil {
+		return nil, err
+	}
+
+	var status kafkaConnectStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("error parsing the status of connector %s: %s", s.metadata.connectorName, err)
+	}
+	if status.Connector.State == "" {
+		return nil, fmt.Errorf("no state in the status of connector %s", s.metadata.connectorName)
+	}
+	return &status, nil
+}
+
+func (s *kafkaConnectScaler) getTasks(ctx context.Context) ([]kafkaConnectTask, error) {
+	body, err := s.get(ctx, fmt.Sprintf("%s/connectors/%s/tasks", s.metadata.connectURL, url.PathEscape(s.metadata.connectorName)))
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []kafkaConnectTask
+	if err := json.Unmarshal(body, &tasks); err != nil {
+		return nil, fmt.Errorf("error parsing the tasks of connector %s: %s", s.metadata.connectorName, err)
+	}
+	return tasks, nil
+}
+
+// getLag returns the highest value of the lag metric exposed at metricsURL, several samples are exposed
+// when the worker runs several tasks
+func (s *kafkaConnectScaler) getLag(ctx context.Context) (float64, error) {
+	body, err := s.get(ctx, s.metadata.metricsURL)
+	if err != nil {
+		return -1, err
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		return -1, fmt.Errorf("error parsing the connect metrics: %s", err)
+	}
+	family, ok := families[s.metadata.lagMetricName]
+	if !ok {
+		return -1, fmt.Errorf("no %s in the connect metrics", s.metadata.lagMetricName)
+	}
+
+	lag := 0.0
+	for _, metric := range family.GetMetric() {
+		value := metric.GetGauge().GetValue()
+		if metric.Untyped != nil {
+			value = metric.GetUntyped().GetValue()
+		}
+		lag = math.Max(lag, value)
+	}
+	return lag, nil
+}
+
+// get sends a GET request to Kafka Connect, retrying it while Kafka Connect answers with 409
+func (s *kafkaConnectScaler) get(ctx context.Context, url string) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		body, statusCode, err := s.doGet(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case statusCode == http.StatusOK:
+			return body, nil
+		case statusCode == http.StatusConflict && attempt < kafkaConnectMaxAttempts:
+			kafkaConnectLog.V(1).Info("kafka connect is rebalancing, retrying", "url", url, "attempt", attempt)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(s.retryWait):
+			}
+		default:
+			return nil, fmt.Errorf("kafka connect returned %d: %s", statusCode, string(body))
+		}
+	}
+}
+
+func (s *kafkaConnectScaler) doGet(ctx context.Context, url string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if s.metadata.connectAuth != nil && s.metadata.connectAuth.EnableBearerAuth {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.metadata.connectAuth.BearerToken))
+	} else if s.metadata.connectAuth != nil && s.metadata.connectAuth.EnableBasicAuth {
+		req.SetBasicAuth(s.metadata.connectAuth.Username, s.metadata.connectAuth.Password)
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, res.StatusCode, nil
+}
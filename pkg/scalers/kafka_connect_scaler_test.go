@@ -0,0 +1,193 @@
+package scalers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type parseKafkaConnectMetadataTestData struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}
+
+type kafkaConnectMetricIdentifier struct {
+	metadataTestData *parseKafkaConnectMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testKafkaConnectMetadata = []parseKafkaConnectMetadataTestData{
+	// nothing passed
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"connectURL": "http://connect:8083", "connectorName": "inventory", "metric": "failedTasks", "targetValue": "1", "activationTargetValue": "0.5"}, map[string]string{}, false},
+	// lag
+	{map[string]string{"connectURL": "http://connect:8083/", "connectorName": "inventory", "metric": "lag", "metricsURL": "http://connect:9404/metrics", "lagMetricName": "debezium_metrics_QueueTotalCapacity", "targetValue": "5000"}, map[string]string{}, false},
+	// missing connectURL
+	{map[string]string{"connectorName": "inventory", "metric": "runningTasks", "targetValue": "1"}, map[string]string{}, true},
+	// invalid connectURL
+	{map[string]string{"connectURL": "connect/8083", "connectorName": "inventory", "metric": "runningTasks", "targetValue": "1"}, map[string]string{}, true},
+	// missing connectorName
+	{map[string]string{"connectURL": "http://connect:8083", "metric": "runningTasks", "targetValue": "1"}, map[string]string{}, true},
+	// missing metric
+	{map[string]string{"connectURL": "http://connect:8083", "connectorName": "inventory", "targetValue": "1"}, map[string]string{}, true},
+	// invalid metric
+	{map[string]string{"connectURL": "http://connect:8083", "connectorName": "inventory", "metric": "pausedTasks", "targetValue": "1"}, map[string]string{}, true},
+	// lag without metricsURL
+	{map[string]string{"connectURL": "http://connect:8083", "connectorName": "inventory", "metric": "lag", "targetValue": "5000"}, map[string]string{}, true},
+	// missing targetValue
+	{map[string]string{"connectURL": "http://connect:8083", "connectorName": "inventory", "metric": "runningTasks"}, map[string]string{}, true},
+	// malformed targetValue
+	{map[string]string{"connectURL": "http://connect:8083", "connectorName": "inventory", "metric": "runningTasks", "targetValue": "a"}, map[string]string{}, true},
+	// negative activationTargetValue
+	{map[string]string{"connectURL": "http://connect:8083", "connectorName": "inventory", "metric": "runningTasks", "targetValue": "1", "activationTargetValue": "-1"}, map[string]string{}, true},
+	// basic auth
+	{map[string]string{"connectURL": "http://connect:8083", "connectorName": "inventory", "metric": "runningTasks", "targetValue": "1", "authModes": "basic"}, map[string]string{"username": "user", "password": "pass"}, false},
+	// basic auth without username
+	{map[string]string{"connectURL": "http://connect:8083", "connectorName": "inventory", "metric": "runningTasks", "targetValue": "1", "authModes": "basic"}, map[string]string{}, true},
+}
+
+var kafkaConnectMetricIdentifiers = []kafkaConnectMetricIdentifier{
+	{&testKafkaConnectMetadata[1], 0, "s0-kafka-connect-inventory-failedTasks"},
+	{&testKafkaConnectMetadata[2], 1, "s1-kafka-connect-inventory-lag"},
+}
+
+const testKafkaConnectStatus = `{"name":"inventory","connector":{"state":"RUNNING","worker_id":"10.0.0.1:8083"},"tasks":[
+{"id":0,"state":"RUNNING","worker_id":"10.0.0.1:8083"},
+{"id":1,"state":"FAILED","worker_id":"10.0.0.2:8083","trace":"org.apache.kafka.connect.errors.ConnectException: ..."},
+{"id":2,"state":"RUNNING","worker_id":"10.0.0.2:8083"},
+{"id":3,"state":"UNASSIGNED","worker_id":"10.0.0.1:8083"}
+],"type":"source"}`
+
+const testKafkaConnectFailedStatus = `{"name":"inventory","connector":{"state":"FAILED","worker_id":"10.0.0.1:8083","trace":"..."},"tasks":[],"type":"source"}`
+
+const testKafkaConnectTasks = `[{"id":{"connector":"inventory","task":0},"config":{}},{"id":{"connector":"inventory","task":1},"config":{}}]`
+
+const testKafkaConnectMetrics = `# HELP debezium_metrics_MilliSecondsBehindSource The number of milliseconds between the last change event's timestamp and the connector processing it.
+# TYPE debezium_metrics_MilliSecondsBehindSource gauge
+debezium_metrics_MilliSecondsBehindSource{context="streaming",name="dbserver1",plugin="mysql"} 1250
+debezium_metrics_MilliSecondsBehindSource{context="streaming",name="dbserver2",plugin="mysql"} 4200
+# HELP debezium_metrics_QueueTotalCapacity The length of the queue
+# TYPE debezium_metrics_QueueTotalCapacity untyped
+debezium_metrics_QueueTotalCapacity{context="streaming",name="dbserver1",plugin="mysql"} 8192
+`
+
+func TestKafkaConnectParseMetadata(t *testing.T) {
+	for _, testData := range testKafkaConnectMetadata {
+		_, err := parseKafkaConnectMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+	}
+}
+
+func TestKafkaConnectGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range kafkaConnectMetricIdentifiers {
+		meta, err := parseKafkaConnectMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockKafkaConnectScaler := kafkaConnectScaler{metadata: meta}
+
+		metricSpec := mockKafkaConnectScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+// newKafkaConnectTestServer serves the connector status, its tasks and the metrics, the first conflicts
+// requests are answered with 409 like during a rebalance
+func newKafkaConnectTestServer(status string, conflicts int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if conflicts > 0 {
+			conflicts--
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error_code":409,"message":"Cannot complete request momentarily due to stale configuration (typically caused by a concurrent config change)"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/connectors/inventory/status":
+			_, _ = w.Write([]byte(status))
+		case "/connectors/inventory/tasks":
+			_, _ = w.Write([]byte(testKafkaConnectTasks))
+		case "/metrics":
+			_, _ = w.Write([]byte(testKafkaConnectMetrics))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":404,"message":"Connector unknown not found"}`))
+		}
+	}))
+}
+
+func TestKafkaConnectGetValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		conflicts int
+		metadata  map[string]string
+		value     float64
+		isActive  bool
+		isError   bool
+	}{
+		{"running tasks", testKafkaConnectStatus, 0, map[string]string{"metric": "runningTasks"}, 2, true, false},
+		{"failed tasks", testKafkaConnectStatus, 0, map[string]string{"metric": "failedTasks"}, 1, true, false},
+		{"every configured task of a failed connector", testKafkaConnectFailedStatus, 0, map[string]string{"metric": "failedTasks"}, 2, true, false},
+		{"activation", testKafkaConnectStatus, 0, map[string]string{"metric": "runningTasks", "activationTargetValue": "2"}, 2, false, false},
+		{"highest lag", testKafkaConnectStatus, 0, map[string]string{"metric": "lag", "metricsURL": "/metrics"}, 4200, true, false},
+		{"untyped lag metric", testKafkaConnectStatus, 0, map[string]string{"metric": "lag", "metricsURL": "/metrics", "lagMetricName": "debezium_metrics_QueueTotalCapacity"}, 8192, true, false},
+		{"missing lag metric", testKafkaConnectStatus, 0, map[string]string{"metric": "lag", "metricsURL": "/metrics", "lagMetricName": "debezium_metrics_unknown"}, -1, false, true},
+		{"unknown connector", testKafkaConnectStatus, 0, map[string]string{"metric": "runningTasks", "connectorName": "unknown"}, -1, false, true},
+		{"retried while rebalancing", testKafkaConnectStatus, kafkaConnectMaxAttempts - 1, map[string]string{"metric": "runningTasks"}, 2, true, false},
+		{"rebalancing for too long", testKafkaConnectStatus, kafkaConnectMaxAttempts, map[string]string{"metric": "runningTasks"}, -1, false, true},
+	}
+
+	for _, test := range tests {
+		server := newKafkaConnectTestServer(test.status, test.conflicts)
+
+		metadata := map[string]string{"connectURL": server.URL, "connectorName": "inventory", "targetValue": "1", "authModes": "basic"}
+		for key, value := range test.metadata {
+			metadata[key] = value
+		}
+		if metadata["metricsURL"] != "" {
+			metadata["metricsURL"] = server.URL + metadata["metricsURL"]
+		}
+		meta, err := parseKafkaConnectMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: map[string]string{"username": "user", "password": "pass"}})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		s := kafkaConnectScaler{metadata: meta, httpClient: http.DefaultClient}
+
+		value, err := s.getValue(context.Background())
+		if test.isError {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			server.Close()
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+		if value != test.value {
+			t.Errorf("%s: expected %v, got %v", test.name, test.value, value)
+		}
+
+		active, err := s.IsActive(context.Background())
+		if err != nil || active != test.isActive {
+			t.Errorf("%s: expected active %t, got %t, %v", test.name, test.isActive, active, err)
+		}
+		server.Close()
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,9 +26,13 @@ type kafkaScaler struct {
 	metadata   kafkaMetadata
 	client     sarama.Client
 	admin      sarama.ClusterAdmin
+
+	// confluentCloudClient replaces the client and admin with the confluentCloudMetricsAPI lagSource
+	confluentCloudClient *confluentCloudMetricsClient
 }
 
 type kafkaMetadata struct {
+	lagSource          kafkaLagSource
 	bootstrapServers   []string
 	group              string
 	topic              string
@@ -50,9 +56,25 @@ type kafkaMetadata struct {
 	key       string
 	ca        string
 
+	// Confluent Cloud Metrics API
+	confluentClusterID string
+	confluentAPIKey    string
+	confluentAPISecret string
+	metricsAPIURL      string
+
 	scalerIndex int
 }
 
+type kafkaLagSource string
+
+// supported lag sources
+const (
+	// the lag is computed from the offsets fetched from the brokers
+	kafkaLagSourceBroker kafkaLagSource = "broker"
+	// the lag is read from the consumer_lag_offsets metric of the Confluent Cloud Metrics API
+	kafkaLagSourceConfluentCloudMetricsAPI kafkaLagSource = "confluentCloudMetricsAPI"
+)
+
 type offsetResetPolicy string
 
 const (
@@ -92,6 +114,14 @@ func NewKafkaScaler(config *ScalerConfig) (Scaler, error) {
 		return nil, fmt.Errorf("error parsing kafka metadata: %s", err)
 	}
 
+	if kafkaMetadata.lagSource == kafkaLagSourceConfluentCloudMetricsAPI {
+		return &kafkaScaler{
+			metricType:           metricType,
+			metadata:             kafkaMetadata,
+			confluentCloudClient: newConfluentCloudMetricsClient(kafkaMetadata, WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config)),
+		}, nil
+	}
+
 	client, admin, err := getKafkaClients(kafkaMetadata)
 	if err != nil {
 		return nil, err
@@ -154,11 +184,22 @@ func parseKafkaAuthParams(config *ScalerConfig, meta *kafkaMetadata) error {
 
 func parseKafkaMetadata(config *ScalerConfig) (kafkaMetadata, error) {
 	meta := kafkaMetadata{}
+	meta.lagSource = kafkaLagSourceBroker
+	if val, ok := config.TriggerMetadata["lagSource"]; ok && val != "" {
+		lagSource := kafkaLagSource(val)
+		if lagSource != kafkaLagSourceBroker && lagSource != kafkaLagSourceConfluentCloudMetricsAPI {
+			return meta, fmt.Errorf("err lagSource %s given", lagSource)
+		}
+		meta.lagSource = lagSource
+	}
+
 	switch {
 	case config.TriggerMetadata["bootstrapServersFromEnv"] != "":
 		meta.bootstrapServers = strings.Split(config.ResolvedEnv[config.TriggerMetadata["bootstrapServersFromEnv"]], ",")
 	case config.TriggerMetadata["bootstrapServers"] != "":
 		meta.bootstrapServers = strings.Split(config.TriggerMetadata["bootstrapServers"], ",")
+	case meta.lagSource == kafkaLagSourceConfluentCloudMetricsAPI:
+		// the brokers aren't connected to
 	default:
 		return meta, errors.New("no bootstrapServers given")
 	}
@@ -207,6 +248,12 @@ func parseKafkaMetadata(config *ScalerConfig) (kafkaMetadata, error) {
 		return meta, err
 	}
 
+	if meta.lagSource == kafkaLagSourceConfluentCloudMetricsAPI {
+		if err := parseKafkaConfluentCloudMetadata(config, &meta); err != nil {
+			return meta, err
+		}
+	}
+
 	meta.allowIdleConsumers = false
 	if val, ok := config.TriggerMetadata["allowIdleConsumers"]; ok {
 		t, err := strconv.ParseBool(val)
@@ -238,8 +285,58 @@ func parseKafkaMetadata(config *ScalerConfig) (kafkaMetadata, error) {
 	return meta, nil
 }
 
+// parseKafkaConfluentCloudMetadata parses the cluster and the Cloud API key used to read the lag from the
+// Confluent Cloud Metrics API
+func parseKafkaConfluentCloudMetadata(config *ScalerConfig, meta *kafkaMetadata) error {
+	if val, ok := config.TriggerMetadata["confluentClusterID"]; ok && val != "" {
+		meta.confluentClusterID = val
+	} else {
+		return errors.New("no confluentClusterID given")
+	}
+
+	if config.AuthParams["apiKey"] == "" {
+		return errors.New("no apiKey given")
+	}
+	meta.confluentAPIKey = strings.TrimSpace(config.AuthParams["apiKey"])
+
+	if config.AuthParams["apiSecret"] == "" {
+		return errors.New("no apiSecret given")
+	}
+	meta.confluentAPISecret = strings.TrimSpace(config.AuthParams["apiSecret"])
+
+	meta.metricsAPIURL = defaultConfluentCloudMetricsAPIURL
+	if val, ok := config.TriggerMetadata["metricsAPIURL"]; ok && val != "" {
+		if _, err := url.ParseRequestURI(val); err != nil {
+			return fmt.Errorf("error parsing metricsAPIURL: %s", err)
+		}
+		meta.metricsAPIURL = strings.TrimSuffix(val, "/")
+	}
+
+	return nil
+}
+
+func newConfluentCloudMetricsClient(meta kafkaMetadata, httpClient *http.Client) *confluentCloudMetricsClient {
+	return &confluentCloudMetricsClient{
+		httpClient: httpClient,
+		url:        meta.metricsAPIURL,
+		apiKey:     meta.confluentAPIKey,
+		apiSecret:  meta.confluentAPISecret,
+		clusterID:  meta.confluentClusterID,
+		group:      meta.group,
+		topic:      meta.topic,
+	}
+}
+
 // IsActive determines if we need to scale from zero
 func (s *kafkaScaler) IsActive(ctx context.Context) (bool, error) {
+	if s.confluentCloudClient != nil {
+		totalLag, _, err := s.confluentCloudClient.getLag(ctx)
+		if err != nil {
+			return false, err
+		}
+		return totalLag > 0, nil
+	}
+
 	topicPartitions, err := s.getTopicPartitions()
 	if err != nil {
 		return false, err
@@ -393,7 +490,11 @@ func (s *kafkaScaler) getLagForPartition(topic string, partitionID int32, offset
 
 // Close closes the kafka admin and client
 func (s *kafkaScaler) Close(context.Context) error {
-	// underlying client will also be closed on admin's Close() call
+	if s.admin == nil {
+		return nil
+	}
+	// underlying client will also be closed on admin's Close() call.
+	// sarama's Close doesn't take a context, the scalers cache bounds how long it may block
 	err := s.admin.Close()
 	if err != nil {
 		return err
@@ -458,27 +559,17 @@ func (s *kafkaScaler) getConsumerAndProducerOffsets(topicPartitions map[string][
 
 // GetMetrics returns value for a supported metric and an error if there is a problem getting the metric
 func (s *kafkaScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
-	topicPartitions, err := s.getTopicPartitions()
-	if err != nil {
-		return []external_metrics.ExternalMetricValue{}, err
+	var totalLag, totalTopicPartitions int64
+	var err error
+	if s.confluentCloudClient != nil {
+		totalLag, totalTopicPartitions, err = s.confluentCloudClient.getLag(ctx)
+	} else {
+		totalLag, totalTopicPartitions, err = s.getBrokerLag()
 	}
-
-	consumerOffsets, producerOffsets, err := s.getConsumerAndProducerOffsets(topicPartitions)
 	if err != nil {
 		return []external_metrics.ExternalMetricValue{}, err
 	}
-
-	totalLag := int64(0)
-	totalTopicPartitions := int64(0)
-
-	for topic, partitionsOffsets := range producerOffsets {
-		for partition := range partitionsOffsets {
-			lag, _ := s.getLagForPartition(topic, partition, consumerOffsets, producerOffsets)
-			totalLag += lag
-		}
-		totalTopicPartitions += (int64)(len(partitionsOffsets))
-	}
-	kafkaLog.V(1).Info(fmt.Sprintf("Kafka scaler: Providing metrics based on totalLag %v, topicPartitions %v, threshold %v", totalLag, len(topicPartitions), s.metadata.lagThreshold))
+	kafkaLog.V(1).Info(fmt.Sprintf("Kafka scaler: Providing metrics based on totalLag %v, topicPartitions %v, threshold %v", totalLag, totalTopicPartitions, s.metadata.lagThreshold))
 
 	if !s.metadata.allowIdleConsumers {
 		// don't scale out beyond the number of topicPartitions
@@ -496,6 +587,32 @@ func (s *kafkaScaler) GetMetrics(ctx context.Context, metricName string, metricS
 	return append([]external_metrics.ExternalMetricValue{}, metric), nil
 }
 
+// getBrokerLag returns the total lag of the consumer group computed from the offsets fetched from the brokers,
+// and the number of partitions it is computed over
+func (s *kafkaScaler) getBrokerLag() (int64, int64, error) {
+	topicPartitions, err := s.getTopicPartitions()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	consumerOffsets, producerOffsets, err := s.getConsumerAndProducerOffsets(topicPartitions)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	totalLag := int64(0)
+	totalTopicPartitions := int64(0)
+
+	for topic, partitionsOffsets := range producerOffsets {
+		for partition := range partitionsOffsets {
+			lag, _ := s.getLagForPartition(topic, partition, consumerOffsets, producerOffsets)
+			totalLag += lag
+		}
+		totalTopicPartitions += (int64)(len(partitionsOffsets))
+	}
+	return totalLag, totalTopicPartitions, nil
+}
+
 type brokerOffsetResult struct {
 	offsetResp *sarama.OffsetResponse
 	err        error
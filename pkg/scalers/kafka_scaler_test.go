@@ -4,6 +4,11 @@ import (
 	"context"
 	"reflect"
 	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/internal/testutil"
 )
 
 type parseKafkaMetadataTestData struct {
@@ -123,6 +128,33 @@ var kafkaMetricIdentifiers = []kafkaMetricIdentifier{
 	{&parseKafkaMetadataTestDataset[2], 1, "s1-kafka-my-group-topics"},
 }
 
+var validConfluentCloudAuthParams = map[string]string{"apiKey": "key", "apiSecret": "secret"}
+
+var parseKafkaConfluentCloudMetadataTestDataset = []struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}{
+	// failure, no confluentClusterID
+	{map[string]string{"lagSource": "confluentCloudMetricsAPI", "consumerGroup": "my-group", "topic": "my-topic"}, validConfluentCloudAuthParams, true},
+	// success, no bootstrapServers
+	{map[string]string{"lagSource": "confluentCloudMetricsAPI", "confluentClusterID": "lkc-12345", "consumerGroup": "my-group", "topic": "my-topic"}, validConfluentCloudAuthParams, false},
+	// success, no topic
+	{map[string]string{"lagSource": "confluentCloudMetricsAPI", "confluentClusterID": "lkc-12345", "consumerGroup": "my-group"}, validConfluentCloudAuthParams, false},
+	// failure, no consumer group
+	{map[string]string{"lagSource": "confluentCloudMetricsAPI", "confluentClusterID": "lkc-12345", "topic": "my-topic"}, validConfluentCloudAuthParams, true},
+	// failure, no apiKey
+	{map[string]string{"lagSource": "confluentCloudMetricsAPI", "confluentClusterID": "lkc-12345", "consumerGroup": "my-group"}, map[string]string{"apiSecret": "secret"}, true},
+	// failure, no apiSecret
+	{map[string]string{"lagSource": "confluentCloudMetricsAPI", "confluentClusterID": "lkc-12345", "consumerGroup": "my-group"}, map[string]string{"apiKey": "key"}, true},
+	// failure, malformed metricsAPIURL
+	{map[string]string{"lagSource": "confluentCloudMetricsAPI", "confluentClusterID": "lkc-12345", "consumerGroup": "my-group", "metricsAPIURL": "telemetry"}, validConfluentCloudAuthParams, true},
+	// failure, unknown lagSource
+	{map[string]string{"lagSource": "prometheus", "bootstrapServers": "foobar:9092", "consumerGroup": "my-group"}, validConfluentCloudAuthParams, true},
+	// success, broker lagSource
+	{map[string]string{"lagSource": "broker", "bootstrapServers": "foobar:9092", "consumerGroup": "my-group"}, validConfluentCloudAuthParams, false},
+}
+
 func TestGetBrokers(t *testing.T) {
 	for _, testData := range parseKafkaMetadataTestDataset {
 		meta, err := parseKafkaMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: validWithAuthParams})
@@ -205,13 +237,29 @@ func TestKafkaAuthParams(t *testing.T) {
 	}
 }
 
+func TestKafkaConfluentCloudMetadata(t *testing.T) {
+	for _, testData := range parseKafkaConfluentCloudMetadataTestDataset {
+		meta, err := parseKafkaMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams})
+
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+		if err == nil && meta.lagSource == kafkaLagSourceConfluentCloudMetricsAPI && meta.metricsAPIURL != defaultConfluentCloudMetricsAPIURL {
+			t.Errorf("Expected the default metricsAPIURL but got %s\n", meta.metricsAPIURL)
+		}
+	}
+}
+
 func TestKafkaGetMetricSpecForScaling(t *testing.T) {
 	for _, testData := range kafkaMetricIdentifiers {
 		meta, err := parseKafkaMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: validWithAuthParams, ScalerIndex: testData.scalerIndex})
 		if err != nil {
 			t.Fatal("Could not parse metadata:", err)
 		}
-		mockKafkaScaler := kafkaScaler{"", meta, nil, nil}
+		mockKafkaScaler := kafkaScaler{metadata: meta}
 
 		metricSpec := mockKafkaScaler.GetMetricSpecForScaling(context.Background())
 		metricName := metricSpec[0].External.Metric.Name
@@ -220,3 +268,45 @@ func TestKafkaGetMetricSpecForScaling(t *testing.T) {
 		}
 	}
 }
+
+// newKafkaSoakBroker starts a broker with a lag of 5 for the partition 0 of topic in group, it is closed at the end of the test
+func newKafkaSoakBroker(t *testing.T) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()).
+			SetLeader("topic", 0, broker.BrokerID()),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "group", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("group", "topic", 0, 5, "", sarama.ErrNoError),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetVersion(1).
+			SetOffset("topic", 0, sarama.OffsetNewest, 10),
+	})
+	t.Cleanup(broker.Close)
+	return broker
+}
+
+// TestKafkaScalerLifecycle only stresses the lifecycle, sarama doesn't take the contexts of the polls so a slow
+// broker would hang them until the network timeouts
+func TestKafkaScalerLifecycle(t *testing.T) {
+	broker := newKafkaSoakBroker(t)
+
+	var active int
+	stats := testutil.StressLifecycle(t, testutil.Lifecycle{
+		Build: func() (testutil.LifecycleScaler, error) {
+			return NewKafkaScaler(&ScalerConfig{TriggerMetadata: map[string]string{"bootstrapServers": broker.Addr(), "consumerGroup": "group", "topic": "topic", "lagThreshold": "10"}})
+		},
+		Poll: func(ctx context.Context, scaler testutil.LifecycleScaler) error {
+			isActive, err := scaler.IsActive(ctx)
+			if isActive {
+				active++
+			}
+			return err
+		},
+	})
+	assert.Zero(t, stats.PollErrors)
+	assert.Equal(t, stats.Polls, active)
+}
@@ -56,7 +56,7 @@ func NewLiiklusScaler(config *ScalerConfig) (Scaler, error) {
 		return nil, err
 	}
 
-	conn, err := grpc.Dial(lm.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(lm.address, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithUserAgent(kedautil.UserAgent(config.TriggerType)))
 	if err != nil {
 		return nil, err
 	}
@@ -92,7 +92,7 @@ func NewMetricsAPIScaler(config *ScalerConfig) (Scaler, error) {
 	return &metricsAPIScaler{
 		metricType: metricType,
 		metadata:   meta,
-		client:     httpClient,
+		client:     WithUserAgent(httpClient, config),
 	}, nil
 }
 
@@ -110,11 +110,14 @@ func parseMetricsAPIMetadata(config *ScalerConfig) (*metricsAPIScalerMetadata, e
 		return nil, fmt.Errorf("no targetValue given in metadata")
 	}
 
-	if val, ok := config.TriggerMetadata["url"]; ok {
-		meta.url = val
-	} else {
+	url, err := getFromAuthOrEnvOrMeta(config, "url")
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
 		return nil, fmt.Errorf("no url given in metadata")
 	}
+	meta.url = url
 
 	if val, ok := config.TriggerMetadata["valueLocation"]; ok {
 		meta.valueLocation = val
@@ -243,3 +243,14 @@ func TestGetMetricValueErrorMessage(t *testing.T) {
 
 	assert.Equal(t, err.Error(), "/api/v1/: api returned 418")
 }
+
+func TestParseMetricsAPIMetadataURLSources(t *testing.T) {
+	metadata := map[string]string{"valueLocation": "metric.test", "targetValue": "42"}
+	testParseFromEnvSources(t, "url", metadata, nil, [3]string{"http://inline:1230/api/v1/", "http://env:1230/api/v1/", "http://auth:1230/api/v1/"}, func(config *ScalerConfig) (string, error) {
+		meta, err := parseMetricsAPIMetadata(config)
+		if err != nil {
+			return "", err
+		}
+		return meta.url, nil
+	})
+}
@@ -0,0 +1,396 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/authentication"
+	"github.com/kedacore/keda/v2/pkg/scalers/azure"
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	defaultMSGraphEndpoint              = "https://graph.microsoft.com"
+	defaultMSGraphFolderName            = "inbox"
+	defaultMSGraphTargetUnreadCount     = 10
+	defaultMSGraphActivationUnreadCount = 0
+
+	// msGraphMaxAttempts is how many times a request throttled by Graph with 429 is sent
+	msGraphMaxAttempts = 3
+	// msGraphMaxRetryAfter is the longest Retry-After waited for, a longer one fails the poll rather
+	// than blocking the scale loop
+	msGraphMaxRetryAfter     = 30 * time.Second
+	defaultMSGraphRetryAfter = time.Second
+
+	// msGraphTokenRefreshMargin is how long before its expiry a client credentials token is refreshed
+	msGraphTokenRefreshMargin  = 5 * time.Minute
+	msGraphTokenRequestTimeout = 30 * time.Second
+)
+
+var msGraphEndpointInCloud = map[string]string{
+	"AZUREPUBLICCLOUD":       "https://graph.microsoft.com",
+	"AZUREUSGOVERNMENTCLOUD": "https://graph.microsoft.us",
+	"AZURECHINACLOUD":        "https://microsoftgraph.chinacloudapi.cn",
+}
+
+type msGraphMailScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *msGraphMailMetadata
+	httpClient *http.Client
+	retryAfter time.Duration
+
+	// the client credentials token, unused with azure workload identity
+	tokenLock      sync.Mutex
+	token          string
+	tokenExpiresOn time.Time
+}
+
+type msGraphMailMetadata struct {
+	userPrincipalName           string
+	folderName                  string
+	targetUnreadCount           int64
+	activationTargetUnreadCount int64
+	graphEndpoint               string
+	activeDirectoryEndpoint     string
+	tenantID                    string
+	clientID                    string
+	clientSecret                string
+	workloadIdentity            bool
+	scalerIndex                 int
+}
+
+// msGraphMessagesResponse is the response of /users/{id}/mailFolders/{folder}/messages, Count is only
+// set when $count is requested with the ConsistencyLevel: eventual header
+type msGraphMessagesResponse struct {
+	Count *int64 `json:"@odata.count"`
+}
+
+// msGraphTokenResponse is the response of the v2 token endpoint of Azure AD
+type msGraphTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+var msGraphMailLog = logf.Log.WithName("msgraph_mail_scaler")
+
+// NewMSGraphMailScaler creates a new msGraphMailScaler
+func NewMSGraphMailScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseMSGraphMailMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing msgraph mail metadata: %s", err)
+	}
+
+	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false)
+	if meta.workloadIdentity {
+		httpClient.Transport = azure.NewADWorkloadIdentityRoundTripper(meta.graphEndpoint, kedautil.CreateHTTPClient(msGraphTokenRequestTimeout, false), httpClient.Transport)
+	}
+
+	return &msGraphMailScaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(httpClient, config),
+		retryAfter: defaultMSGraphRetryAfter,
+	}, nil
+}
+
+func parseMSGraphMailMetadata(config *ScalerConfig) (*msGraphMailMetadata, error) {
+	meta := msGraphMailMetadata{}
+	meta.folderName = defaultMSGraphFolderName
+	meta.targetUnreadCount = defaultMSGraphTargetUnreadCount
+	meta.activationTargetUnreadCount = defaultMSGraphActivationUnreadCount
+
+	if val, ok := config.TriggerMetadata["userPrincipalName"]; ok && val != "" {
+		meta.userPrincipalName = val
+	} else {
+		return nil, fmt.Errorf("no userPrincipalName given")
+	}
+
+	// a well-known folder name such as inbox or the id of the folder
+	if val, ok := config.TriggerMetadata["folderName"]; ok && val != "" {
+		meta.folderName = val
+	}
+
+	if val, ok := config.TriggerMetadata["targetUnreadCount"]; ok {
+		targetUnreadCount, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetUnreadCount: %s", err)
+		}
+		if targetUnreadCount <= 0 {
+			return nil, fmt.Errorf("targetUnreadCount must be greater than 0")
+		}
+		meta.targetUnreadCount = targetUnreadCount
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetUnreadCount"]; ok {
+		activationTargetUnreadCount, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetUnreadCount: %s", err)
+		}
+		if activationTargetUnreadCount < 0 {
+			return nil, fmt.Errorf("activationTargetUnreadCount must not be negative")
+		}
+		meta.activationTargetUnreadCount = activationTargetUnreadCount
+	}
+
+	meta.graphEndpoint = defaultMSGraphEndpoint
+	if cloud, ok := config.TriggerMetadata["cloud"]; ok && cloud != "" {
+		if strings.EqualFold(cloud, azure.PrivateCloud) {
+			if val, ok := config.TriggerMetadata["graphEndpoint"]; ok && val != "" {
+				meta.graphEndpoint = val
+			} else {
+				return nil, fmt.Errorf("graphEndpoint must be provided for %s cloud type", azure.PrivateCloud)
+			}
+		} else if val, ok := msGraphEndpointInCloud[strings.ToUpper(cloud)]; ok {
+			meta.graphEndpoint = val
+		} else {
+			return nil, fmt.Errorf("there is no cloud environment matching the name %s", cloud)
+		}
+	}
+	if _, err := url.ParseRequestURI(meta.graphEndpoint); err != nil {
+		return nil, fmt.Errorf("error parsing graphEndpoint: %s", err)
+	}
+	meta.graphEndpoint = strings.TrimSuffix(meta.graphEndpoint, "/")
+
+	auth, err := authentication.GetAuthConfigs(config.TriggerMetadata, config.AuthParams)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		if !auth.EnableAzureWorkloadIdentity || auth.EnableBearerAuth || auth.EnableBasicAuth || auth.EnableTLS {
+			return nil, fmt.Errorf("only the %s auth mode is supported", authentication.AzureWorkloadIdentityAuthType)
+		}
+		meta.workloadIdentity = true
+	} else {
+		// the application needs the Mail.Read application permission
+		if meta.tenantID, err = getParameterFromConfig(config, "tenantId", true); err != nil {
+			return nil, err
+		}
+		if meta.clientID, err = getParameterFromConfig(config, "clientId", true); err != nil {
+			return nil, err
+		}
+		if meta.clientSecret, err = getParameterFromConfig(config, "clientSecret", true); err != nil {
+			return nil, err
+		}
+
+		activeDirectoryEndpoint, err := azure.ParseActiveDirectoryEndpoint(config.TriggerMetadata)
+		if err != nil {
+			return nil, err
+		}
+		meta.activeDirectoryEndpoint = strings.TrimSuffix(activeDirectoryEndpoint, "/")
+	}
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// IsActive checks if the unread count is above the activation target
+func (s *msGraphMailScaler) IsActive(ctx context.Context) (bool, error) {
+	count, err := s.getUnreadCount(ctx)
+	if err != nil {
+		msGraphMailLog.Error(err, "error getting the unread count")
+		return false, err
+	}
+
+	return count > s.metadata.activationTargetUnreadCount, nil
+}
+
+func (s *msGraphMailScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *msGraphMailScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("msgraph-mail-%s-%s", s.metadata.userPrincipalName, s.metadata.folderName))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetUnreadCount),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the unread count of the folder
+func (s *msGraphMailScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	count, err := s.getUnreadCount(ctx)
+	if err != nil {
+		msGraphMailLog.Error(err, "error getting the unread count")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(count, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+func (s *msGraphMailScaler) getUnreadCount(ctx context.Context) (int64, error) {
+	query := url.Values{}
+	query.Set("$filter", "isRead eq false")
+	query.Set("$count", "true")
+	// only the count is needed
+	query.Set("$top", "1")
+	query.Set("$select", "id")
+	messagesURL := fmt.Sprintf("%s/v1.0/users/%s/mailFolders/%s/messages?%s", s.metadata.graphEndpoint,
+		url.PathEscape(s.metadata.userPrincipalName), url.PathEscape(s.metadata.folderName), query.Encode())
+
+	body, err := s.get(ctx, messagesURL)
+	if err != nil {
+		return -1, err
+	}
+
+	count, err := parseMSGraphUnreadCount(body)
+	if err != nil {
+		return -1, fmt.Errorf("error parsing the messages of %s: %s", s.metadata.userPrincipalName, err)
+	}
+	return count, nil
+}
+
+func parseMSGraphUnreadCount(body []byte) (int64, error) {
+	var response msGraphMessagesResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return -1, err
+	}
+	if response.Count == nil {
+		return -1, fmt.Errorf("no @odata.count in the response")
+	}
+	return *response.Count, nil
+}
+
+// get sends a GET request to Graph, retrying it after the Retry-After given by Graph while the request
+// is throttled
+func (s *msGraphMailScaler) get(ctx context.Context, url string) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		body, res, err := s.doGet(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case res.StatusCode == http.StatusOK:
+			return body, nil
+		case res.StatusCode == http.StatusTooManyRequests && attempt < msGraphMaxAttempts:
+			retryAfter := s.retryAfter
+			if val := res.Header.Get("Retry-After"); val != "" {
+				seconds, err := strconv.Atoi(val)
+				if err != nil || seconds < 0 {
+					return nil, fmt.Errorf("error parsing Retry-After %q", val)
+				}
+				retryAfter = time.Duration(seconds) * time.Second
+			}
+			if retryAfter > msGraphMaxRetryAfter {
+				return nil, fmt.Errorf("graph is throttling the requests, retry after %s", retryAfter)
+			}
+			msGraphMailLog.V(1).Info("graph is throttling the requests, retrying", "retryAfter", retryAfter, "attempt", attempt)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(retryAfter):
+			}
+		default:
+			return nil, fmt.Errorf("graph returned %d: %s", res.StatusCode, string(body))
+		}
+	}
+}
+
+func (s *msGraphMailScaler) doGet(ctx context.Context, url string) ([]byte, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	// $count is ignored on messages without it
+	req.Header.Set("ConsistencyLevel", "eventual")
+	if !s.metadata.workloadIdentity {
+		token, err := s.getToken(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, res, nil
+}
+
+// getToken returns the client credentials token, it is acquired on the first request and refreshed
+// shortly before it expires
+func (s *msGraphMailScaler) getToken(ctx context.Context) (string, error) {
+	s.tokenLock.Lock()
+	defer s.tokenLock.Unlock()
+
+	if s.token != "" && time.Until(s.tokenExpiresOn) > msGraphTokenRefreshMargin {
+		return s.token, nil
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", "client_credentials")
+	data.Set("client_id", s.metadata.clientID)
+	data.Set("client_secret", s.metadata.clientSecret)
+	data.Set("scope", s.metadata.graphEndpoint+"/.default")
+
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", s.metadata.activeDirectoryEndpoint, url.PathEscape(s.metadata.tenantID))
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("azure AD returned %d: %s", res.StatusCode, string(body))
+	}
+
+	var token msGraphTokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("error parsing azure AD token response: %s", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("no access_token in azure AD token response")
+	}
+
+	s.token = token.AccessToken
+	s.tokenExpiresOn = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return s.token, nil
+}
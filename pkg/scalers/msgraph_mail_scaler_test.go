@@ -0,0 +1,237 @@
+package scalers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type parseMSGraphMailMetadataTestData struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}
+
+type msGraphMailMetricIdentifier struct {
+	metadataTestData *parseMSGraphMailMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testMSGraphMailAuthParams = map[string]string{"tenantId": "tenant", "clientId": "client", "clientSecret": "secret"}
+
+var testMSGraphMailMetadata = []parseMSGraphMailMetadataTestData{
+	// nothing passed
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"userPrincipalName": "orders@contoso.com", "folderName": "inbox", "targetUnreadCount": "20", "activationTargetUnreadCount": "2"}, testMSGraphMailAuthParams, false},
+	// default folder and targets
+	{map[string]string{"userPrincipalName": "orders@contoso.com"}, testMSGraphMailAuthParams, false},
+	// missing userPrincipalName
+	{map[string]string{"folderName": "inbox"}, testMSGraphMailAuthParams, true},
+	// malformed targetUnreadCount
+	{map[string]string{"userPrincipalName": "orders@contoso.com", "targetUnreadCount": "a"}, testMSGraphMailAuthParams, true},
+	// zero targetUnreadCount
+	{map[string]string{"userPrincipalName": "orders@contoso.com", "targetUnreadCount": "0"}, testMSGraphMailAuthParams, true},
+	// negative activationTargetUnreadCount
+	{map[string]string{"userPrincipalName": "orders@contoso.com", "activationTargetUnreadCount": "-1"}, testMSGraphMailAuthParams, true},
+	// missing clientSecret
+	{map[string]string{"userPrincipalName": "orders@contoso.com"}, map[string]string{"tenantId": "tenant", "clientId": "client"}, true},
+	// client credentials in the metadata
+	{map[string]string{"userPrincipalName": "orders@contoso.com", "tenantId": "tenant", "clientId": "client", "clientSecretFromEnv": "CLIENT_SECRET"}, map[string]string{}, false},
+	// azure workload identity
+	{map[string]string{"userPrincipalName": "orders@contoso.com", "authModes": "azureWorkloadIdentity"}, map[string]string{}, false},
+	// other auth modes
+	{map[string]string{"userPrincipalName": "orders@contoso.com", "authModes": "bearer"}, map[string]string{"bearerToken": "token"}, true},
+	// national cloud
+	{map[string]string{"userPrincipalName": "orders@contoso.com", "cloud": "AzureUSGovernmentCloud"}, testMSGraphMailAuthParams, false},
+	// unknown cloud
+	{map[string]string{"userPrincipalName": "orders@contoso.com", "cloud": "Unknown"}, testMSGraphMailAuthParams, true},
+	// private cloud without graphEndpoint
+	{map[string]string{"userPrincipalName": "orders@contoso.com", "cloud": "Private", "activeDirectoryEndpoint": "https://login.contoso.com/"}, testMSGraphMailAuthParams, true},
+	// private cloud
+	{map[string]string{"userPrincipalName": "orders@contoso.com", "cloud": "Private", "activeDirectoryEndpoint": "https://login.contoso.com/", "graphEndpoint": "https://graph.contoso.com/"}, testMSGraphMailAuthParams, false},
+}
+
+var msGraphMailMetricIdentifiers = []msGraphMailMetricIdentifier{
+	{&testMSGraphMailMetadata[1], 0, "s0-msgraph-mail-orders@contoso-com-inbox"},
+	{&testMSGraphMailMetadata[2], 1, "s1-msgraph-mail-orders@contoso-com-inbox"},
+}
+
+const testMSGraphMailToken = `{"token_type":"Bearer","expires_in":3599,"ext_expires_in":3599,"access_token":"eyJ0eXAiOiJKV1Qi"}`
+
+const testMSGraphMailMessages = `{"@odata.context":"https://graph.microsoft.com/v1.0/$metadata#users('orders%40contoso.com')/mailFolders('inbox')/messages(id)","@odata.count":42,"value":[{"@odata.etag":"W/\"CQAAABYAAAB\"","id":"AAMkAGVmMDEzMTM4LTZmYWUtNDdkNC1hMDZiLTU1OGY5OTZhYmY4OABGAAAAAAAiQ8W967B7TKBjgx9rVEURBwAiIsqMbYjsT5e"}],"@odata.nextLink":"https://graph.microsoft.com/v1.0/users/orders@contoso.com/mailFolders/inbox/messages?%24filter=isRead+eq+false&%24count=true&%24top=1&%24select=id&%24skip=1"}`
+
+// testMSGraphMailMessagesWithoutCount is what Graph answers when the ConsistencyLevel header is missing
+const testMSGraphMailMessagesWithoutCount = `{"@odata.context":"https://graph.microsoft.com/v1.0/$metadata#users('orders%40contoso.com')/mailFolders('inbox')/messages(id)","value":[]}`
+
+const testMSGraphMailThrottled = `{"error":{"code":"ApplicationThrottled","message":"Application is over its MailboxConcurrency limit."}}`
+
+func TestMSGraphMailParseMetadata(t *testing.T) {
+	for _, testData := range testMSGraphMailMetadata {
+		_, err := parseMSGraphMailMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams, ResolvedEnv: map[string]string{"CLIENT_SECRET": "secret"}})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+	}
+}
+
+func TestMSGraphMailParseMetadataDefaults(t *testing.T) {
+	meta, err := parseMSGraphMailMetadata(&ScalerConfig{TriggerMetadata: testMSGraphMailMetadata[2].metadata, AuthParams: testMSGraphMailMetadata[2].authParams})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	if meta.folderName != "inbox" || meta.targetUnreadCount != 10 || meta.activationTargetUnreadCount != 0 {
+		t.Errorf("unexpected defaults %+v", meta)
+	}
+	if meta.graphEndpoint != "https://graph.microsoft.com" || meta.activeDirectoryEndpoint != "https://login.microsoftonline.com" {
+		t.Errorf("unexpected endpoints %s, %s", meta.graphEndpoint, meta.activeDirectoryEndpoint)
+	}
+}
+
+func TestMSGraphMailGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range msGraphMailMetricIdentifiers {
+		meta, err := parseMSGraphMailMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockMSGraphMailScaler := msGraphMailScaler{metadata: meta}
+
+		metricSpec := mockMSGraphMailScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+type msGraphMailTestServer struct {
+	*httptest.Server
+	throttled     int
+	retryAfter    string
+	tokenRequests int
+}
+
+// newMSGraphMailTestServer serves the token endpoint and the messages of the inbox of orders@contoso.com,
+// the first throttled requests for the messages are answered with 429
+func newMSGraphMailTestServer(throttled int, retryAfter string) *msGraphMailTestServer {
+	s := &msGraphMailTestServer{throttled: throttled, retryAfter: retryAfter}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tenant/oauth2/v2.0/token":
+			if r.Method != http.MethodPost || r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "client" ||
+				r.FormValue("client_secret") != "secret" || r.FormValue("scope") != s.URL+"/.default" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+				return
+			}
+			s.tokenRequests++
+			_, _ = w.Write([]byte(testMSGraphMailToken))
+		case "/v1.0/users/orders@contoso.com/mailFolders/inbox/messages":
+			if r.Header.Get("Authorization") != "Bearer eyJ0eXAiOiJKV1Qi" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("$filter") != "isRead eq false" || r.URL.Query().Get("$count") != "true" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if s.throttled > 0 {
+				s.throttled--
+				if s.retryAfter != "" {
+					w.Header().Set("Retry-After", s.retryAfter)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte(testMSGraphMailThrottled))
+				return
+			}
+			if r.Header.Get("ConsistencyLevel") != "eventual" {
+				_, _ = w.Write([]byte(testMSGraphMailMessagesWithoutCount))
+				return
+			}
+			_, _ = w.Write([]byte(testMSGraphMailMessages))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":"ErrorInvalidUser","message":"The requested user is invalid."}}`))
+		}
+	}))
+	return s
+}
+
+func TestMSGraphMailGetUnreadCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		throttled  int
+		retryAfter string
+		metadata   map[string]string
+		count      int64
+		isActive   bool
+		isError    bool
+	}{
+		{"unread count", 0, "", map[string]string{}, 42, true, false},
+		{"activation", 0, "", map[string]string{"activationTargetUnreadCount": "42"}, 42, false, false},
+		{"unknown user", 0, "", map[string]string{"userPrincipalName": "unknown@contoso.com"}, -1, false, true},
+		{"retried after Retry-After", msGraphMaxAttempts - 1, "0", map[string]string{}, 42, true, false},
+		{"retried without Retry-After", 1, "", map[string]string{}, 42, true, false},
+		{"throttled for too long", msGraphMaxAttempts, "0", map[string]string{}, -1, false, true},
+		{"Retry-After too long", 1, "120", map[string]string{}, -1, false, true},
+	}
+
+	for _, test := range tests {
+		server := newMSGraphMailTestServer(test.throttled, test.retryAfter)
+
+		metadata := map[string]string{"userPrincipalName": "orders@contoso.com", "cloud": "Private", "activeDirectoryEndpoint": server.URL + "/", "graphEndpoint": server.URL}
+		for key, value := range test.metadata {
+			metadata[key] = value
+		}
+		meta, err := parseMSGraphMailMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: testMSGraphMailAuthParams})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		s := msGraphMailScaler{metadata: meta, httpClient: http.DefaultClient, retryAfter: time.Millisecond}
+
+		count, err := s.getUnreadCount(context.Background())
+		if test.isError {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			server.Close()
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+		if count != test.count {
+			t.Errorf("%s: expected %d, got %d", test.name, test.count, count)
+		}
+
+		active, err := s.IsActive(context.Background())
+		if err != nil || active != test.isActive {
+			t.Errorf("%s: expected active %t, got %t, %v", test.name, test.isActive, active, err)
+		}
+		if server.tokenRequests != 1 {
+			t.Errorf("%s: expected the token to be reused, got %d token requests", test.name, server.tokenRequests)
+		}
+		server.Close()
+	}
+}
+
+func TestParseMSGraphUnreadCount(t *testing.T) {
+	count, err := parseMSGraphUnreadCount([]byte(testMSGraphMailMessages))
+	if err != nil || count != 42 {
+		t.Errorf("expected 42, got %d, %v", count, err)
+	}
+
+	if _, err := parseMSGraphUnreadCount([]byte(testMSGraphMailMessagesWithoutCount)); err == nil {
+		t.Error("expected an error without @odata.count")
+	}
+
+	if _, err := parseMSGraphUnreadCount([]byte(testMSGraphMailThrottled[:20])); err == nil {
+		t.Error("expected an error for a truncated body")
+	}
+}
@@ -0,0 +1,388 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	defaultNsqTargetDepth = 10
+
+	// nsqLookupdCacheTTL is how long the nsqd instances producing the topic are cached before nsqlookupd is asked again
+	nsqLookupdCacheTTL = time.Minute
+)
+
+type nsqScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *nsqMetadata
+	httpClient *http.Client
+
+	// nsqdAddresses caches the HTTP addresses of the nsqd instances producing the topic until nsqdAddressesExpiry
+	nsqdAddresses       []string
+	nsqdAddressesExpiry time.Time
+	mutex               sync.Mutex
+}
+
+type nsqMetadata struct {
+	lookupdHTTPAddresses  []string
+	topic                 string
+	channel               string
+	targetDepth           int64
+	activationTargetDepth int64
+	includeInFlight       bool
+	allowPartialResults   bool
+	scalerIndex           int
+}
+
+// nsqLookupResponse is the response of the /lookup endpoint of nsqlookupd, versions before 1.0 wrap it in data
+type nsqLookupResponse struct {
+	Producers []nsqProducer `json:"producers"`
+	Data      *struct {
+		Producers []nsqProducer `json:"producers"`
+	} `json:"data"`
+}
+
+type nsqProducer struct {
+	BroadcastAddress string `json:"broadcast_address"`
+	HTTPPort         int    `json:"http_port"`
+}
+
+// nsqStatsResponse is the response of the /stats?format=json endpoint of nsqd, versions before 1.0 wrap it in data
+type nsqStatsResponse struct {
+	Topics []nsqTopicStats `json:"topics"`
+	Data   *struct {
+		Topics []nsqTopicStats `json:"topics"`
+	} `json:"data"`
+}
+
+type nsqTopicStats struct {
+	TopicName string            `json:"topic_name"`
+	Channels  []nsqChannelStats `json:"channels"`
+}
+
+type nsqChannelStats struct {
+	ChannelName   string `json:"channel_name"`
+	Depth         int64  `json:"depth"`
+	InFlightCount int64  `json:"in_flight_count"`
+}
+
+var nsqLog = logf.Log.WithName("nsq_scaler")
+
+// NewNSQScaler creates a new nsqScaler
+func NewNSQScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseNSQMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing nsq metadata: %s", err)
+	}
+
+	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false)
+
+	return &nsqScaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(httpClient, config),
+	}, nil
+}
+
+func parseNSQMetadata(config *ScalerConfig) (*nsqMetadata, error) {
+	meta := nsqMetadata{}
+	meta.targetDepth = defaultNsqTargetDepth
+	meta.includeInFlight = true
+
+	if val, ok := config.TriggerMetadata["lookupdHTTPAddresses"]; ok && val != "" {
+		for _, address := range splitAndTrimBySep(val, ",") {
+			if address == "" {
+				continue
+			}
+			// the addresses are given as host:port like to the NSQ tools, a scheme is optional
+			if !strings.Contains(address, "://") {
+				address = "http://" + address
+			}
+			address = strings.TrimSuffix(address, "/")
+			if _, err := url.ParseRequestURI(address); err != nil {
+				return nil, fmt.Errorf("error parsing lookupdHTTPAddresses %s: %s", address, err)
+			}
+			meta.lookupdHTTPAddresses = append(meta.lookupdHTTPAddresses, address)
+		}
+	}
+	if len(meta.lookupdHTTPAddresses) == 0 {
+		return nil, fmt.Errorf("no lookupdHTTPAddresses given")
+	}
+
+	if val, ok := config.TriggerMetadata["topic"]; ok && val != "" {
+		meta.topic = val
+	} else {
+		return nil, fmt.Errorf("no topic given")
+	}
+
+	if val, ok := config.TriggerMetadata["channel"]; ok && val != "" {
+		meta.channel = val
+	} else {
+		return nil, fmt.Errorf("no channel given")
+	}
+
+	if val, ok := config.TriggerMetadata["targetDepth"]; ok {
+		targetDepth, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetDepth: %s", err)
+		}
+		if targetDepth <= 0 {
+			return nil, fmt.Errorf("targetDepth must be greater than 0")
+		}
+		meta.targetDepth = targetDepth
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetDepth"]; ok {
+		activationTargetDepth, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetDepth: %s", err)
+		}
+		if activationTargetDepth < 0 {
+			return nil, fmt.Errorf("activationTargetDepth must not be negative")
+		}
+		meta.activationTargetDepth = activationTargetDepth
+	}
+
+	if val, ok := config.TriggerMetadata["includeInFlight"]; ok {
+		includeInFlight, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing includeInFlight: %s", err)
+		}
+		meta.includeInFlight = includeInFlight
+	}
+
+	if val, ok := config.TriggerMetadata["allowPartialResults"]; ok {
+		allowPartialResults, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing allowPartialResults: %s", err)
+		}
+		meta.allowPartialResults = allowPartialResults
+	}
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// IsActive checks if the depth of the channel is above the activation target
+func (s *nsqScaler) IsActive(ctx context.Context) (bool, error) {
+	depth, err := s.getDepth(ctx)
+	if err != nil {
+		nsqLog.Error(err, "error getting the nsq channel depth")
+		return false, err
+	}
+
+	return depth > s.metadata.activationTargetDepth, nil
+}
+
+func (s *nsqScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *nsqScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("nsq-%s-%s", s.metadata.topic, s.metadata.channel))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetDepth),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the depth of the channel summed over the nsqd instances producing the topic
+func (s *nsqScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	depth, err := s.getDepth(ctx)
+	if err != nil {
+		nsqLog.Error(err, "error getting the nsq channel depth")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(depth, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getDepth sums the depth of the channel, and the messages in flight, over the nsqd instances producing the topic.
+// An nsqd instance that doesn't answer fails the whole sum unless allowPartialResults is set
+func (s *nsqScaler) getDepth(ctx context.Context) (int64, error) {
+	nsqdAddresses, err := s.getNsqdAddresses(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	var depth int64
+	var errs []string
+	for _, address := range nsqdAddresses {
+		nsqdDepth, err := s.getNsqdDepth(ctx, address)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", address, err))
+			continue
+		}
+		depth += nsqdDepth
+	}
+
+	if len(errs) > 0 {
+		// the nsqd instances may have changed, they're looked up again on the next call
+		s.invalidateNsqdAddresses()
+
+		if !s.metadata.allowPartialResults || len(errs) == len(nsqdAddresses) {
+			return -1, fmt.Errorf("error getting the stats of nsqd: %s", strings.Join(errs, "; "))
+		}
+		nsqLog.Info("nsqd instances failed, the depth only includes the instances that answered", "topic", s.metadata.topic, "channel", s.metadata.channel, "errors", strings.Join(errs, "; "))
+	}
+	return depth, nil
+}
+
+// getNsqdAddresses returns the HTTP addresses of the nsqd instances producing the topic, looked up on every nsqlookupd
+// and cached for nsqLookupdCacheTTL. The lookup fails only when every nsqlookupd fails
+func (s *nsqScaler) getNsqdAddresses(ctx context.Context) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.nsqdAddresses != nil && time.Now().Before(s.nsqdAddressesExpiry) {
+		return s.nsqdAddresses, nil
+	}
+
+	addresses := []string{}
+	seen := map[string]bool{}
+	var errs []string
+	for _, lookupd := range s.metadata.lookupdHTTPAddresses {
+		producers, err := s.lookup(ctx, lookupd)
+		if err != nil {
+			nsqLog.Info("nsqlookupd failed, skipping it", "address", lookupd, "error", err.Error())
+			errs = append(errs, fmt.Sprintf("%s: %s", lookupd, err))
+			continue
+		}
+		for _, producer := range producers {
+			address := "http://" + net.JoinHostPort(producer.BroadcastAddress, strconv.Itoa(producer.HTTPPort))
+			if !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
+		}
+	}
+
+	if len(errs) == len(s.metadata.lookupdHTTPAddresses) {
+		return nil, fmt.Errorf("error looking up topic %s: %s", s.metadata.topic, strings.Join(errs, "; "))
+	}
+
+	s.nsqdAddresses = addresses
+	s.nsqdAddressesExpiry = time.Now().Add(nsqLookupdCacheTTL)
+	return addresses, nil
+}
+
+func (s *nsqScaler) invalidateNsqdAddresses() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nsqdAddresses = nil
+}
+
+// lookup returns the nsqd instances producing the topic known to the nsqlookupd, none if the topic doesn't exist yet
+func (s *nsqScaler) lookup(ctx context.Context, lookupd string) ([]nsqProducer, error) {
+	body, statusCode, err := s.get(ctx, fmt.Sprintf("%s/lookup?topic=%s", lookupd, url.QueryEscape(s.metadata.topic)))
+	if err != nil {
+		return nil, err
+	}
+	if statusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("nsqlookupd returned %d: %s", statusCode, string(body))
+	}
+
+	var response nsqLookupResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error parsing nsqlookupd response: %s", err)
+	}
+	if response.Data != nil {
+		return response.Data.Producers, nil
+	}
+	return response.Producers, nil
+}
+
+// getNsqdDepth returns the depth of the channel on the nsqd instance, and the messages in flight with includeInFlight
+func (s *nsqScaler) getNsqdDepth(ctx context.Context, nsqd string) (int64, error) {
+	body, statusCode, err := s.get(ctx, fmt.Sprintf("%s/stats?format=json&topic=%s&channel=%s", nsqd, url.QueryEscape(s.metadata.topic), url.QueryEscape(s.metadata.channel)))
+	if err != nil {
+		return -1, err
+	}
+	if statusCode != http.StatusOK {
+		return -1, fmt.Errorf("nsqd returned %d: %s", statusCode, string(body))
+	}
+
+	var response nsqStatsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return -1, fmt.Errorf("error parsing nsqd stats: %s", err)
+	}
+	topics := response.Topics
+	if response.Data != nil {
+		topics = response.Data.Topics
+	}
+
+	// nsqd versions before 1.1 ignore the topic and channel filters, so they're applied here too
+	var depth int64
+	for _, topic := range topics {
+		if topic.TopicName != s.metadata.topic {
+			continue
+		}
+		for _, channel := range topic.Channels {
+			if channel.ChannelName != s.metadata.channel {
+				continue
+			}
+			depth += channel.Depth
+			if s.metadata.includeInFlight {
+				depth += channel.InFlightCount
+			}
+		}
+	}
+	return depth, nil
+}
+
+func (s *nsqScaler) get(ctx context.Context, url string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, res.StatusCode, nil
+}
@@ -0,0 +1,289 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type parseNSQMetadataTestData struct {
+	metadata map[string]string
+	isError  bool
+}
+
+type nsqMetricIdentifier struct {
+	metadataTestData *parseNSQMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testNSQMetadata = []parseNSQMetadataTestData{
+	// nothing passed
+	{map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"lookupdHTTPAddresses": "nsqlookupd-0:4161, http://nsqlookupd-1:4161", "topic": "orders", "channel": "billing", "targetDepth": "100", "activationTargetDepth": "5", "includeInFlight": "false", "allowPartialResults": "true"}, false},
+	// using defaults
+	{map[string]string{"lookupdHTTPAddresses": "nsqlookupd:4161", "topic": "orders", "channel": "shipping"}, false},
+	// missing lookupdHTTPAddresses
+	{map[string]string{"topic": "orders", "channel": "billing"}, true},
+	// missing topic
+	{map[string]string{"lookupdHTTPAddresses": "nsqlookupd:4161", "channel": "billing"}, true},
+	// missing channel
+	{map[string]string{"lookupdHTTPAddresses": "nsqlookupd:4161", "topic": "orders"}, true},
+	// malformed targetDepth
+	{map[string]string{"lookupdHTTPAddresses": "nsqlookupd:4161", "topic": "orders", "channel": "billing", "targetDepth": "a"}, true},
+	// zero targetDepth
+	{map[string]string{"lookupdHTTPAddresses": "nsqlookupd:4161", "topic": "orders", "channel": "billing", "targetDepth": "0"}, true},
+	// negative activationTargetDepth
+	{map[string]string{"lookupdHTTPAddresses": "nsqlookupd:4161", "topic": "orders", "channel": "billing", "activationTargetDepth": "-1"}, true},
+	// malformed includeInFlight
+	{map[string]string{"lookupdHTTPAddresses": "nsqlookupd:4161", "topic": "orders", "channel": "billing", "includeInFlight": "sometimes"}, true},
+	// malformed allowPartialResults
+	{map[string]string{"lookupdHTTPAddresses": "nsqlookupd:4161", "topic": "orders", "channel": "billing", "allowPartialResults": "sometimes"}, true},
+}
+
+var nsqMetricIdentifiers = []nsqMetricIdentifier{
+	{&testNSQMetadata[1], 0, "s0-nsq-orders-billing"},
+	{&testNSQMetadata[2], 1, "s1-nsq-orders-shipping"},
+}
+
+// testNSQStats is the answer of nsqd 1.2 to /stats?format=json, the topic and channel filters are ignored
+// to check the scaler applies them too
+const testNSQStats = `{"version":"1.2.1","health":"OK","start_time":1665000000,"topics":[
+{"topic_name":"orders","channels":[
+	{"channel_name":"billing","depth":40,"backend_depth":30,"in_flight_count":3,"deferred_count":0,"message_count":1000,"requeue_count":0,"timeout_count":0,"client_count":2,"clients":[],"paused":false},
+	{"channel_name":"shipping","depth":7,"backend_depth":0,"in_flight_count":1,"deferred_count":0,"message_count":1000,"requeue_count":0,"timeout_count":0,"client_count":1,"clients":[],"paused":false}
+],"depth":0,"backend_depth":0,"message_count":1000,"paused":false},
+{"topic_name":"refunds","channels":[
+	{"channel_name":"billing","depth":500,"backend_depth":0,"in_flight_count":0,"deferred_count":0,"message_count":500,"requeue_count":0,"timeout_count":0,"client_count":0,"clients":[],"paused":false}
+],"depth":0,"backend_depth":0,"message_count":500,"paused":false}
+],"memory":{"heap_objects":1000}}`
+
+// testNSQLegacyStats is the answer of nsqd before 1.0, wrapped in data
+const testNSQLegacyStats = `{"status_code":200,"status_txt":"OK","data":{"version":"0.3.8","health":"OK","start_time":1665000000,"topics":[
+{"topic_name":"orders","channels":[{"channel_name":"billing","depth":10,"backend_depth":0,"in_flight_count":2}]}
+]}}`
+
+func TestNSQParseMetadata(t *testing.T) {
+	for _, testData := range testNSQMetadata {
+		_, err := parseNSQMetadata(&ScalerConfig{TriggerMetadata: testData.metadata})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+	}
+}
+
+func TestNSQParseMetadataDefaults(t *testing.T) {
+	meta, err := parseNSQMetadata(&ScalerConfig{TriggerMetadata: testNSQMetadata[2].metadata})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	if meta.targetDepth != defaultNsqTargetDepth || meta.activationTargetDepth != 0 || !meta.includeInFlight || meta.allowPartialResults {
+		t.Errorf("Unexpected defaults %+v", meta)
+	}
+	if len(meta.lookupdHTTPAddresses) != 1 || meta.lookupdHTTPAddresses[0] != "http://nsqlookupd:4161" {
+		t.Errorf("Expected the scheme to be added to the lookupd address, got %v", meta.lookupdHTTPAddresses)
+	}
+}
+
+func TestNSQGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range nsqMetricIdentifiers {
+		meta, err := parseNSQMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockNSQScaler := nsqScaler{metadata: meta}
+
+		metricSpec := mockNSQScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+type nsqTestCluster struct {
+	lookupd *httptest.Server
+	lookups int
+	nsqds   []*httptest.Server
+}
+
+// newNSQTestCluster starts a nsqlookupd knowing the given nsqd instances and an unreachable one when withUnreachable is set
+func newNSQTestCluster(t *testing.T, stats []string, withUnreachable bool) *nsqTestCluster {
+	cluster := &nsqTestCluster{}
+	var producers []string
+	for _, body := range stats {
+		body := body
+		nsqd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/stats" || r.URL.Query().Get("format") != "json" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(body))
+		}))
+		cluster.nsqds = append(cluster.nsqds, nsqd)
+		producers = append(producers, nsqTestProducer(t, nsqd.Listener.Addr().String()))
+	}
+	if withUnreachable {
+		unreachable := httptest.NewServer(http.NotFoundHandler())
+		address := unreachable.Listener.Addr().String()
+		unreachable.Close()
+		producers = append(producers, nsqTestProducer(t, address))
+	}
+
+	cluster.lookupd = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cluster.lookups++
+		switch r.URL.Query().Get("topic") {
+		case "orders":
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"channels":["billing","shipping"],"producers":[%s]}`, strings.Join(producers, ","))))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"TOPIC_NOT_FOUND"}`))
+		}
+	}))
+	return cluster
+}
+
+func (c *nsqTestCluster) Close() {
+	c.lookupd.Close()
+	for _, nsqd := range c.nsqds {
+		nsqd.Close()
+	}
+}
+
+func nsqTestProducer(t *testing.T, address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf(`{"remote_address":"%s","hostname":"nsqd","broadcast_address":"%s","tcp_port":4150,"http_port":%s,"version":"1.2.1"}`, address, host, port)
+}
+
+func TestNSQGetDepth(t *testing.T) {
+	tests := []struct {
+		name            string
+		stats           []string
+		withUnreachable bool
+		metadata        map[string]string
+		depth           int64
+		isActive        bool
+		isError         bool
+	}{
+		{"depth and in flight summed over the nsqd instances", []string{testNSQStats, testNSQLegacyStats}, false, map[string]string{}, 55, true, false},
+		{"without in flight", []string{testNSQStats, testNSQLegacyStats}, false, map[string]string{"includeInFlight": "false"}, 50, true, false},
+		{"activation", []string{testNSQStats}, false, map[string]string{"activationTargetDepth": "43"}, 43, false, false},
+		{"unknown topic", []string{testNSQStats}, false, map[string]string{"topic": "payments"}, 0, false, false},
+		{"unreachable nsqd", []string{testNSQStats}, true, map[string]string{}, -1, false, true},
+		{"unreachable nsqd with partial results", []string{testNSQStats}, true, map[string]string{"allowPartialResults": "true"}, 43, true, false},
+	}
+
+	for _, test := range tests {
+		cluster := newNSQTestCluster(t, test.stats, test.withUnreachable)
+
+		metadata := map[string]string{"lookupdHTTPAddresses": cluster.lookupd.URL, "topic": "orders", "channel": "billing"}
+		for key, value := range test.metadata {
+			metadata[key] = value
+		}
+		meta, err := parseNSQMetadata(&ScalerConfig{TriggerMetadata: metadata})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		s := nsqScaler{metadata: meta, httpClient: http.DefaultClient}
+
+		depth, err := s.getDepth(context.Background())
+		if test.isError {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			cluster.Close()
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+		if depth != test.depth {
+			t.Errorf("%s: expected the depth %d, got %d", test.name, test.depth, depth)
+		}
+
+		active, err := s.IsActive(context.Background())
+		if err != nil || active != test.isActive {
+			t.Errorf("%s: expected active %t, got %t, %v", test.name, test.isActive, active, err)
+		}
+		cluster.Close()
+	}
+}
+
+func TestNSQLookupdFailover(t *testing.T) {
+	cluster := newNSQTestCluster(t, []string{testNSQStats}, false)
+	defer cluster.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	meta, err := parseNSQMetadata(&ScalerConfig{TriggerMetadata: map[string]string{"lookupdHTTPAddresses": failing.URL + "," + cluster.lookupd.URL, "topic": "orders", "channel": "billing"}})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	s := nsqScaler{metadata: meta, httpClient: http.DefaultClient}
+
+	depth, err := s.getDepth(context.Background())
+	if err != nil || depth != 43 {
+		t.Errorf("Expected the depth 43 from the working nsqlookupd, got %d, %v", depth, err)
+	}
+
+	meta.lookupdHTTPAddresses = meta.lookupdHTTPAddresses[:1]
+	s = nsqScaler{metadata: meta, httpClient: http.DefaultClient}
+	if _, err := s.getDepth(context.Background()); err == nil {
+		t.Error("Expected an error when every nsqlookupd fails")
+	}
+}
+
+func TestNSQLookupdCache(t *testing.T) {
+	cluster := newNSQTestCluster(t, []string{testNSQStats}, false)
+	defer cluster.Close()
+
+	meta, err := parseNSQMetadata(&ScalerConfig{TriggerMetadata: map[string]string{"lookupdHTTPAddresses": cluster.lookupd.URL, "topic": "orders", "channel": "billing"}})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	s := nsqScaler{metadata: meta, httpClient: http.DefaultClient}
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.getDepth(context.Background()); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+	}
+	if cluster.lookups != 1 {
+		t.Errorf("Expected the nsqd instances to be looked up once, got %d lookups", cluster.lookups)
+	}
+
+	// the cache expired
+	s.nsqdAddressesExpiry = time.Now().Add(-time.Second)
+	if _, err := s.getDepth(context.Background()); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if cluster.lookups != 2 {
+		t.Errorf("Expected the nsqd instances to be looked up again once the cache expired, got %d lookups", cluster.lookups)
+	}
+
+	// a failing nsqd invalidates the cache, the next call looks the instances up again
+	cluster.nsqds[0].Close()
+	if _, err := s.getDepth(context.Background()); err == nil {
+		t.Fatal("Expected an error with the nsqd down")
+	}
+	if _, err := s.getDepth(context.Background()); err == nil {
+		t.Fatal("Expected an error with the nsqd down")
+	}
+	if cluster.lookups != 3 {
+		t.Errorf("Expected the nsqd instances to be looked up again after a failure, got %d lookups", cluster.lookups)
+	}
+}
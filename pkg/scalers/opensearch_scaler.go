@@ -0,0 +1,374 @@
+package scalers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/tidwall/gjson"
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	// defaultOpenSearchAwsService is the SigV4 service name of Amazon OpenSearch Service domains,
+	// serverless collections use "aoss"
+	defaultOpenSearchAwsService = "es"
+)
+
+type openSearchScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *openSearchMetadata
+	httpClient *http.Client
+	signer     *v4.Signer
+}
+
+type openSearchMetadata struct {
+	addresses             []string
+	indexes               []string
+	query                 string
+	searchTemplateName    string
+	parameters            []string
+	valueLocation         string
+	targetValue           int64
+	activationTargetValue float64
+	unsafeSsl             bool
+
+	username string
+	password string
+
+	awsRegion        string
+	awsService       string
+	awsAuthorization awsAuthorizationMetadata
+
+	scalerIndex int
+}
+
+var openSearchLog = logf.Log.WithName("opensearch_scaler")
+
+// NewOpenSearchScaler creates a new openSearchScaler
+func NewOpenSearchScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseOpenSearchMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing opensearch metadata: %s", err)
+	}
+
+	s := &openSearchScaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, meta.unsafeSsl), config),
+	}
+	if meta.awsRegion != "" {
+		s.signer = v4.NewSigner(createOpenSearchAwsCredentials(meta))
+	}
+	return s, nil
+}
+
+func parseOpenSearchMetadata(config *ScalerConfig) (*openSearchMetadata, error) {
+	meta := openSearchMetadata{}
+
+	addresses, err := GetFromAuthOrMeta(config, "addresses")
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range splitAndTrimBySep(addresses, ",") {
+		if address == "" {
+			continue
+		}
+		if _, err := url.ParseRequestURI(address); err != nil {
+			return nil, fmt.Errorf("error parsing address %s: %s", address, err)
+		}
+		meta.addresses = append(meta.addresses, strings.TrimSuffix(address, "/"))
+	}
+	if len(meta.addresses) == 0 {
+		return nil, fmt.Errorf("no addresses given")
+	}
+
+	index, err := GetFromAuthOrMeta(config, "index")
+	if err != nil {
+		return nil, err
+	}
+	meta.indexes = splitAndTrimBySep(index, ";")
+
+	meta.query = config.TriggerMetadata["query"]
+	meta.searchTemplateName = config.TriggerMetadata["searchTemplateName"]
+	switch {
+	case meta.query == "" && meta.searchTemplateName == "":
+		return nil, fmt.Errorf("either query or searchTemplateName must be given")
+	case meta.query != "" && meta.searchTemplateName != "":
+		return nil, fmt.Errorf("query and searchTemplateName can't be given both")
+	case meta.query != "" && !json.Valid([]byte(meta.query)):
+		return nil, fmt.Errorf("query must be a valid JSON query DSL")
+	}
+
+	if val, ok := config.TriggerMetadata["parameters"]; ok && val != "" {
+		if meta.searchTemplateName == "" {
+			return nil, fmt.Errorf("parameters can only be used with searchTemplateName")
+		}
+		for _, p := range splitAndTrimBySep(val, ";") {
+			if p != "" && len(splitAndTrimBySep(p, ":")) != 2 {
+				return nil, fmt.Errorf("parameters must be given as key:value pairs, got %s", p)
+			}
+		}
+		meta.parameters = splitAndTrimBySep(val, ";")
+	}
+
+	// without valueLocation the hit count is used
+	meta.valueLocation = config.TriggerMetadata["valueLocation"]
+
+	targetValue, err := GetFromAuthOrMeta(config, "targetValue")
+	if err != nil {
+		return nil, err
+	}
+	meta.targetValue, err = strconv.ParseInt(targetValue, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("targetValue parsing error %s", err.Error())
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetValue"]; ok {
+		meta.activationTargetValue, err = strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("activationTargetValue parsing error %s", err.Error())
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["unsafeSsl"]; ok {
+		meta.unsafeSsl, err = strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing unsafeSsl: %s", err)
+		}
+	}
+
+	if val, ok := config.AuthParams["username"]; ok {
+		meta.username = val
+	} else if val, ok := config.TriggerMetadata["username"]; ok {
+		meta.username = val
+	}
+
+	if config.AuthParams["password"] != "" {
+		meta.password = config.AuthParams["password"]
+	} else if config.TriggerMetadata["passwordFromEnv"] != "" {
+		meta.password = config.ResolvedEnv[config.TriggerMetadata["passwordFromEnv"]]
+	}
+
+	// requests are signed with SigV4 when the domain lives in AWS
+	if val, ok := config.TriggerMetadata["awsRegion"]; ok && val != "" {
+		if meta.username != "" {
+			return nil, fmt.Errorf("basic authentication and awsRegion can't be given both")
+		}
+
+		meta.awsRegion = val
+		meta.awsService = defaultOpenSearchAwsService
+		if val, ok := config.TriggerMetadata["awsService"]; ok && val != "" {
+			meta.awsService = val
+		}
+
+		meta.awsAuthorization, err = getAwsAuthorization(config.AuthParams, config.TriggerMetadata, config.ResolvedEnv)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+func createOpenSearchAwsCredentials(metadata *openSearchMetadata) *credentials.Credentials {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(metadata.awsRegion),
+	}))
+
+	if !metadata.awsAuthorization.podIdentityOwner {
+		return sess.Config.Credentials
+	}
+
+	if metadata.awsAuthorization.awsRoleArn != "" {
+		return stscreds.NewCredentials(sess, metadata.awsAuthorization.awsRoleArn)
+	}
+	return credentials.NewStaticCredentials(metadata.awsAuthorization.awsAccessKeyID, metadata.awsAuthorization.awsSecretAccessKey, metadata.awsAuthorization.awsSessionToken)
+}
+
+func (s *openSearchScaler) Close(context.Context) error {
+	return nil
+}
+
+// IsActive returns true if the query result is above the activation target
+func (s *openSearchScaler) IsActive(ctx context.Context) (bool, error) {
+	value, err := s.getQueryResult(ctx)
+	if err != nil {
+		openSearchLog.Error(err, "error inspecting opensearch")
+		return false, err
+	}
+	return value > s.metadata.activationTargetValue, nil
+}
+
+// GetMetricSpecForScaling returns the MetricSpec for the Horizontal Pod Autoscaler
+func (s *openSearchScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	name := s.metadata.searchTemplateName
+	if name == "" {
+		name = strings.Join(s.metadata.indexes, "-")
+	}
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("opensearch-%s", name))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns value for a supported metric and an error if there is a problem getting the metric
+func (s *openSearchScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	value, err := s.getQueryResult(ctx)
+	if err != nil {
+		return []external_metrics.ExternalMetricValue{}, fmt.Errorf("error inspecting opensearch: %s", err)
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getQueryResult runs the search against the addresses in turn until one of them answers
+func (s *openSearchScaler) getQueryResult(ctx context.Context) (float64, error) {
+	path, body, err := s.buildSearch()
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []string
+	for _, address := range s.metadata.addresses {
+		response, err := s.search(ctx, address+path, body)
+		if err != nil {
+			openSearchLog.V(1).Info("opensearch address failed, trying the next one", "address", address, "error", err.Error())
+			errs = append(errs, fmt.Sprintf("%s: %s", address, err))
+			continue
+		}
+		return getOpenSearchValue(response, s.metadata.valueLocation)
+	}
+
+	return 0, fmt.Errorf("no opensearch address answered: %s", strings.Join(errs, "; "))
+}
+
+// buildSearch returns the path and body of the search request. The distribution and version of the cluster aren't
+// probed, only the search APIs shared by OpenSearch and Elasticsearch are used
+func (s *openSearchScaler) buildSearch() (string, []byte, error) {
+	indexes := url.PathEscape(strings.Join(s.metadata.indexes, ","))
+
+	if s.metadata.searchTemplateName == "" {
+		// track_total_hits makes the hit count exact above 10000 hits
+		return fmt.Sprintf("/%s/_search?track_total_hits=true", indexes), []byte(s.metadata.query), nil
+	}
+
+	parameters := map[string]interface{}{}
+	for _, p := range s.metadata.parameters {
+		if p != "" {
+			kv := splitAndTrimBySep(p, ":")
+			parameters[kv[0]] = kv[1]
+		}
+	}
+	query := map[string]interface{}{
+		"id": s.metadata.searchTemplateName,
+	}
+	if len(parameters) > 0 {
+		query["params"] = parameters
+	}
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("/%s/_search/template", indexes), body, nil
+}
+
+func (s *openSearchScaler) search(ctx context.Context, searchURL string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", searchURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	switch {
+	case s.signer != nil:
+		if _, err := s.signer.Sign(req, bytes.NewReader(body), s.metadata.awsService, s.metadata.awsRegion, time.Now()); err != nil {
+			return nil, fmt.Errorf("error signing request: %s", err)
+		}
+	case s.metadata.username != "":
+		req.SetBasicAuth(s.metadata.username, s.metadata.password)
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("opensearch returned %d: %s", res.StatusCode, string(b))
+	}
+	return b, nil
+}
+
+// getOpenSearchValue returns the number at valueLocation, or the hit count without valueLocation.
+// The hit count is an object since OpenSearch 1.0 and Elasticsearch 7.0 but a number in older versions
+func getOpenSearchValue(body []byte, valueLocation string) (float64, error) {
+	if valueLocation == "" {
+		total := gjson.GetBytes(body, "hits.total")
+		if total.IsObject() {
+			total = total.Get("value")
+		}
+		if total.Type != gjson.Number {
+			return 0, fmt.Errorf("the response doesn't contain the hit count")
+		}
+		return total.Num, nil
+	}
+
+	r := gjson.GetBytes(body, valueLocation)
+	errorMsg := "valueLocation must point to value of type number but got: '%s'"
+	switch r.Type {
+	case gjson.Number:
+		return r.Num, nil
+	case gjson.String:
+		v, err := strconv.ParseFloat(r.String(), 64)
+		if err != nil {
+			return 0, fmt.Errorf(errorMsg, r.String())
+		}
+		return v, nil
+	default:
+		return 0, fmt.Errorf(errorMsg, r.Type.String())
+	}
+}
@@ -0,0 +1,303 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type parseOpenSearchMetadataTestData struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}
+
+type openSearchMetricIdentifier struct {
+	metadataTestData *parseOpenSearchMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testOpenSearchMetadata = []parseOpenSearchMetadataTestData{
+	// nothing passed
+	{map[string]string{}, map[string]string{}, true},
+	// raw query
+	{map[string]string{"addresses": "http://localhost:9200", "index": "logs", "query": `{"query": {"match_all": {}}}`, "targetValue": "10", "activationTargetValue": "1.5"}, map[string]string{}, false},
+	// search template with parameters
+	{map[string]string{"addresses": "http://localhost:9200", "index": "logs;audit", "searchTemplateName": "errors", "parameters": "level:error;service:api", "targetValue": "10"}, map[string]string{}, false},
+	// multiple addresses from authParams
+	{map[string]string{"index": "logs", "query": `{}`, "targetValue": "10"}, map[string]string{"addresses": "http://os-0:9200, http://os-1:9200/"}, false},
+	// missing addresses
+	{map[string]string{"index": "logs", "query": `{}`, "targetValue": "10"}, map[string]string{}, true},
+	// invalid address
+	{map[string]string{"addresses": "localhost", "index": "logs", "query": `{}`, "targetValue": "10"}, map[string]string{}, true},
+	// missing index
+	{map[string]string{"addresses": "http://localhost:9200", "query": `{}`, "targetValue": "10"}, map[string]string{}, true},
+	// neither query nor searchTemplateName
+	{map[string]string{"addresses": "http://localhost:9200", "index": "logs", "targetValue": "10"}, map[string]string{}, true},
+	// both query and searchTemplateName
+	{map[string]string{"addresses": "http://localhost:9200", "index": "logs", "query": `{}`, "searchTemplateName": "errors", "targetValue": "10"}, map[string]string{}, true},
+	// malformed query
+	{map[string]string{"addresses": "http://localhost:9200", "index": "logs", "query": `{"query":`, "targetValue": "10"}, map[string]string{}, true},
+	// parameters without searchTemplateName
+	{map[string]string{"addresses": "http://localhost:9200", "index": "logs", "query": `{}`, "parameters": "a:b", "targetValue": "10"}, map[string]string{}, true},
+	// malformed parameters
+	{map[string]string{"addresses": "http://localhost:9200", "index": "logs", "searchTemplateName": "errors", "parameters": "a", "targetValue": "10"}, map[string]string{}, true},
+	// missing targetValue
+	{map[string]string{"addresses": "http://localhost:9200", "index": "logs", "query": `{}`}, map[string]string{}, true},
+	// malformed activationTargetValue
+	{map[string]string{"addresses": "http://localhost:9200", "index": "logs", "query": `{}`, "targetValue": "10", "activationTargetValue": "a"}, map[string]string{}, true},
+	// malformed unsafeSsl
+	{map[string]string{"addresses": "http://localhost:9200", "index": "logs", "query": `{}`, "targetValue": "10", "unsafeSsl": "a"}, map[string]string{}, true},
+	// basic auth
+	{map[string]string{"addresses": "http://localhost:9200", "index": "logs", "query": `{}`, "targetValue": "10"}, map[string]string{"username": "admin", "password": "admin"}, false},
+	// SigV4 with static credentials
+	{map[string]string{"addresses": "https://search-logs.eu-west-1.es.amazonaws.com", "index": "logs", "query": `{}`, "targetValue": "10", "awsRegion": "eu-west-1"}, map[string]string{"awsAccessKeyID": "AKID", "awsSecretAccessKey": "SECRET"}, false},
+	// SigV4 with the operator identity
+	{map[string]string{"addresses": "https://search-logs.eu-west-1.es.amazonaws.com", "index": "logs", "query": `{}`, "targetValue": "10", "awsRegion": "eu-west-1", "identityOwner": "operator"}, map[string]string{}, false},
+	// SigV4 without credentials
+	{map[string]string{"addresses": "https://search-logs.eu-west-1.es.amazonaws.com", "index": "logs", "query": `{}`, "targetValue": "10", "awsRegion": "eu-west-1"}, map[string]string{}, true},
+	// SigV4 and basic auth
+	{map[string]string{"addresses": "https://search-logs.eu-west-1.es.amazonaws.com", "index": "logs", "query": `{}`, "targetValue": "10", "awsRegion": "eu-west-1"}, map[string]string{"username": "admin", "password": "admin", "awsAccessKeyID": "AKID", "awsSecretAccessKey": "SECRET"}, true},
+}
+
+var openSearchMetricIdentifiers = []openSearchMetricIdentifier{
+	{&testOpenSearchMetadata[1], 0, "s0-opensearch-logs"},
+	{&testOpenSearchMetadata[2], 1, "s1-opensearch-errors"},
+}
+
+func TestOpenSearchParseMetadata(t *testing.T) {
+	for i, testData := range testOpenSearchMetadata {
+		_, err := parseOpenSearchMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams})
+		if err != nil && !testData.isError {
+			t.Errorf("case %d: expected success but got error %s", i, err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("case %d: expected error but got success", i)
+		}
+	}
+}
+
+func TestOpenSearchGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range openSearchMetricIdentifiers {
+		meta, err := parseOpenSearchMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockOpenSearchScaler := openSearchScaler{metadata: meta}
+
+		metricSpec := mockOpenSearchScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+func newTestOpenSearchScaler(t *testing.T, metadata map[string]string, authParams map[string]string) *openSearchScaler {
+	scaler, err := NewOpenSearchScaler(&ScalerConfig{TriggerMetadata: metadata, AuthParams: authParams, TriggerType: "opensearch"})
+	if err != nil {
+		t.Fatal("Could not create scaler:", err)
+	}
+	return scaler.(*openSearchScaler)
+}
+
+func TestOpenSearchGetQueryResult(t *testing.T) {
+	tests := []struct {
+		name          string
+		response      string
+		valueLocation string
+		value         float64
+		isError       bool
+	}{
+		{
+			"hit count as an object",
+			`{"took": 3, "timed_out": false, "hits": {"total": {"value": 12345, "relation": "eq"}, "max_score": null, "hits": []}}`,
+			"",
+			12345,
+			false,
+		},
+		{
+			"hit count as a number",
+			`{"took": 3, "timed_out": false, "hits": {"total": 42, "max_score": null, "hits": []}}`,
+			"",
+			42,
+			false,
+		},
+		{
+			"aggregation",
+			`{"hits": {"total": {"value": 120, "relation": "eq"}, "hits": []}, "aggregations": {"avg_latency": {"value": 153.25}}}`,
+			"aggregations.avg_latency.value",
+			153.25,
+			false,
+		},
+		{
+			"bucket aggregation",
+			`{"hits": {"total": {"value": 120, "relation": "eq"}, "hits": []}, "aggregations": {"services": {"buckets": [{"key": "api", "doc_count": 80}, {"key": "worker", "doc_count": 40}]}}}`,
+			"aggregations.services.buckets.0.doc_count",
+			80,
+			false,
+		},
+		{
+			"missing hit count",
+			`{"took": 3}`,
+			"",
+			0,
+			true,
+		},
+		{
+			"valueLocation not a number",
+			`{"aggregations": {"services": {"buckets": []}}}`,
+			"aggregations.services.buckets",
+			0,
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" || r.URL.Path != "/logs,audit/_search" || r.URL.Query().Get("track_total_hits") != "true" {
+				t.Errorf("%s: unexpected request %s %s", test.name, r.Method, r.URL)
+			}
+			if body, _ := ioutil.ReadAll(r.Body); string(body) != `{"query": {"match_all": {}}}` {
+				t.Errorf("%s: unexpected query %s", test.name, string(body))
+			}
+			_, _ = w.Write([]byte(test.response))
+		}))
+
+		metadata := map[string]string{"addresses": server.URL, "index": "logs;audit", "query": `{"query": {"match_all": {}}}`, "targetValue": "10"}
+		if test.valueLocation != "" {
+			metadata["valueLocation"] = test.valueLocation
+		}
+		scaler := newTestOpenSearchScaler(t, metadata, map[string]string{})
+
+		value, err := scaler.getQueryResult(context.Background())
+		server.Close()
+
+		if test.isError {
+			if err == nil {
+				t.Errorf("%s: expected error but got success", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected success but got error %s", test.name, err)
+			continue
+		}
+		if value != test.value {
+			t.Errorf("%s: expected %v, got %v", test.name, test.value, value)
+		}
+	}
+}
+
+func TestOpenSearchSearchTemplate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/logs/_search/template" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body["id"] != "errors" {
+			t.Errorf("unexpected template %v", body["id"])
+		}
+		if params, _ := body["params"].(map[string]interface{}); params["level"] != "error" {
+			t.Errorf("unexpected params %v", body["params"])
+		}
+		_, _ = w.Write([]byte(`{"hits": {"total": {"value": 7, "relation": "eq"}, "hits": []}}`))
+	}))
+	defer server.Close()
+
+	scaler := newTestOpenSearchScaler(t, map[string]string{"addresses": server.URL, "index": "logs", "searchTemplateName": "errors", "parameters": "level:error", "targetValue": "5", "activationTargetValue": "6"}, map[string]string{})
+
+	active, err := scaler.IsActive(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if !active {
+		t.Error("Expected scaler to be active")
+	}
+
+	metrics, err := scaler.GetMetrics(context.Background(), "s0-opensearch-errors", nil)
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if metrics[0].Value.MilliValue() != 7000 {
+		t.Errorf("Expected 7, got %s", metrics[0].Value.String())
+	}
+}
+
+func TestOpenSearchBasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"hits": {"total": {"value": 3, "relation": "eq"}, "hits": []}}`))
+	}))
+	defer server.Close()
+
+	scaler := newTestOpenSearchScaler(t, map[string]string{"addresses": server.URL, "index": "logs", "query": `{}`, "targetValue": "5"}, map[string]string{"username": "admin", "password": "secret"})
+	value, err := scaler.getQueryResult(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if value != 3 {
+		t.Errorf("Expected 3, got %v", value)
+	}
+}
+
+func TestOpenSearchAwsSigV4(t *testing.T) {
+	var authorization, contentSha string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		contentSha = r.Header.Get("X-Amz-Content-Sha256")
+		_, _ = w.Write([]byte(`{"hits": {"total": {"value": 1, "relation": "eq"}, "hits": []}}`))
+	}))
+	defer server.Close()
+
+	scaler := newTestOpenSearchScaler(t,
+		map[string]string{"addresses": server.URL, "index": "logs", "query": `{}`, "targetValue": "5", "awsRegion": "eu-west-1", "awsService": "aoss"},
+		map[string]string{"awsAccessKeyID": "AKID", "awsSecretAccessKey": "SECRET"})
+	if _, err := scaler.getQueryResult(context.Background()); err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(authorization, "/eu-west-1/aoss/aws4_request") {
+		t.Errorf("Unexpected Authorization header %q", authorization)
+	}
+	if contentSha != "" {
+		t.Errorf("Unexpected X-Amz-Content-Sha256 header %q", contentSha)
+	}
+}
+
+func TestOpenSearchAddressFailover(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"hits": {"total": {"value": 9, "relation": "eq"}, "hits": []}}`))
+	}))
+	defer up.Close()
+
+	scaler := newTestOpenSearchScaler(t, map[string]string{"addresses": down.URL + "," + up.URL, "index": "logs", "query": `{}`, "targetValue": "5"}, map[string]string{})
+	value, err := scaler.getQueryResult(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if value != 9 {
+		t.Errorf("Expected 9, got %v", value)
+	}
+
+	scaler = newTestOpenSearchScaler(t, map[string]string{"addresses": down.URL, "index": "logs", "query": `{}`, "targetValue": "5"}, map[string]string{})
+	if _, err := scaler.GetMetrics(context.Background(), "s0-opensearch-logs", nil); err == nil {
+		t.Error("Expected error but got success")
+	}
+}
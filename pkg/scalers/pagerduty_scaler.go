@@ -0,0 +1,275 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	defaultPagerDutyURL                 = "https://api.pagerduty.com"
+	defaultPagerDutyStatuses            = "triggered,acknowledged"
+	defaultPagerDutyTargetIncidentCount = 1
+
+	// pagerDutyPageSize is the maximum number of incidents the API returns per page
+	pagerDutyPageSize = 100
+)
+
+type pagerDutyScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *pagerDutyMetadata
+	httpClient *http.Client
+}
+
+type pagerDutyMetadata struct {
+	pagerDutyURL                  string
+	serviceIDs                    []string
+	statuses                      []string
+	urgency                       string
+	targetIncidentCount           int64
+	activationTargetIncidentCount int64
+	apiToken                      string
+	scalerIndex                   int
+}
+
+type pagerDutyIncidentsResponse struct {
+	Incidents []struct {
+		ID string `json:"id"`
+	} `json:"incidents"`
+	More bool `json:"more"`
+}
+
+// pagerDutyError is returned when the PagerDuty API responds with an unexpected status
+type pagerDutyError struct {
+	status  int
+	message string
+}
+
+func (e *pagerDutyError) Error() string {
+	return fmt.Sprintf("pagerduty API returned %d: %s", e.status, e.message)
+}
+
+var pagerDutyLog = logf.Log.WithName("pagerduty_scaler")
+
+// NewPagerDutyScaler creates a new pagerDutyScaler
+func NewPagerDutyScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parsePagerDutyMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing pagerduty metadata: %s", err)
+	}
+
+	return &pagerDutyScaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config),
+	}, nil
+}
+
+func parsePagerDutyMetadata(config *ScalerConfig) (*pagerDutyMetadata, error) {
+	meta := pagerDutyMetadata{}
+	meta.pagerDutyURL = defaultPagerDutyURL
+	meta.targetIncidentCount = defaultPagerDutyTargetIncidentCount
+
+	if val, ok := config.TriggerMetadata["pagerDutyURL"]; ok && val != "" {
+		meta.pagerDutyURL = strings.TrimSuffix(val, "/")
+	}
+
+	if val, ok := config.TriggerMetadata["serviceIDs"]; ok && val != "" {
+		for _, serviceID := range splitAndTrimBySep(val, ",") {
+			if serviceID != "" {
+				meta.serviceIDs = append(meta.serviceIDs, serviceID)
+			}
+		}
+	}
+	if len(meta.serviceIDs) == 0 {
+		return nil, fmt.Errorf("no serviceIDs given")
+	}
+
+	statuses := defaultPagerDutyStatuses
+	if val, ok := config.TriggerMetadata["statuses"]; ok && val != "" {
+		statuses = val
+	}
+	for _, status := range splitAndTrimBySep(statuses, ",") {
+		switch status {
+		case "triggered", "acknowledged", "resolved":
+			meta.statuses = append(meta.statuses, status)
+		default:
+			return nil, fmt.Errorf("statuses must be triggered, acknowledged or resolved, got %s", status)
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["urgency"]; ok && val != "" {
+		switch val {
+		case "high", "low":
+			meta.urgency = val
+		default:
+			return nil, fmt.Errorf("urgency must be high or low, got %s", val)
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["targetIncidentCount"]; ok {
+		target, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetIncidentCount: %s", err)
+		}
+		if target <= 0 {
+			return nil, fmt.Errorf("targetIncidentCount must be greater than 0")
+		}
+		meta.targetIncidentCount = target
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetIncidentCount"]; ok {
+		activationTarget, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetIncidentCount: %s", err)
+		}
+		if activationTarget < 0 {
+			return nil, fmt.Errorf("activationTargetIncidentCount must not be negative")
+		}
+		meta.activationTargetIncidentCount = activationTarget
+	}
+
+	if val, ok := config.AuthParams["apiToken"]; ok && val != "" {
+		meta.apiToken = val
+	} else {
+		return nil, fmt.Errorf("no apiToken given")
+	}
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// IsActive checks if more incidents are open than the activation target
+func (s *pagerDutyScaler) IsActive(ctx context.Context) (bool, error) {
+	incidents, err := s.getIncidentCount(ctx)
+	if err != nil {
+		pagerDutyLog.Error(err, "error getting the pagerduty incident count")
+		return false, err
+	}
+
+	return incidents > s.metadata.activationTargetIncidentCount, nil
+}
+
+func (s *pagerDutyScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *pagerDutyScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("pagerduty-%s", strings.Join(s.metadata.serviceIDs, "-")))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetIncidentCount),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the number of incidents of the services with the statuses and urgency
+func (s *pagerDutyScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	incidents, err := s.getIncidentCount(ctx)
+	if err != nil {
+		pagerDutyLog.Error(err, "error getting the pagerduty incident count")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(incidents, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getIncidentCount counts the incidents of all the pages of the incident list
+func (s *pagerDutyScaler) getIncidentCount(ctx context.Context) (int64, error) {
+	query := url.Values{}
+	for _, serviceID := range s.metadata.serviceIDs {
+		query.Add("service_ids[]", serviceID)
+	}
+	for _, status := range s.metadata.statuses {
+		query.Add("statuses[]", status)
+	}
+	if s.metadata.urgency != "" {
+		query.Set("urgencies[]", s.metadata.urgency)
+	}
+	query.Set("limit", strconv.Itoa(pagerDutyPageSize))
+
+	var count int64
+	for {
+		query.Set("offset", strconv.FormatInt(count, 10))
+
+		var incidents pagerDutyIncidentsResponse
+		if err := s.get(ctx, fmt.Sprintf("%s/incidents?%s", s.metadata.pagerDutyURL, query.Encode()), &incidents); err != nil {
+			return -1, err
+		}
+
+		count += int64(len(incidents.Incidents))
+		if !incidents.More || len(incidents.Incidents) == 0 {
+			return count, nil
+		}
+	}
+}
+
+func (s *pagerDutyScaler) get(ctx context.Context, url string, response interface{}) error {
+	res, err := kedautil.DoWithRateLimitBackoff(ctx, s.httpClient, kedautil.DefaultRateLimitBackoff, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+		req.Header.Set("Authorization", fmt.Sprintf("Token token=%s", s.metadata.apiToken))
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		pagerDutyErr := &pagerDutyError{status: res.StatusCode}
+		var body struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(b, &body); err == nil && body.Error.Message != "" {
+			pagerDutyErr.message = body.Error.Message
+		} else {
+			pagerDutyErr.message = string(b)
+		}
+		return pagerDutyErr
+	}
+
+	if err := json.Unmarshal(b, response); err != nil {
+		return fmt.Errorf("error parsing pagerduty response: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type parsePagerDutyMetadataTestData struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}
+
+type pagerDutyMetricIdentifier struct {
+	metadataTestData *parsePagerDutyMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testPagerDutyAuthParams = map[string]string{"apiToken": "token"}
+
+var testPagerDutyMetadata = []parsePagerDutyMetadataTestData{
+	// nothing passed
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"serviceIDs": "PABC123, PDEF456", "statuses": "triggered", "urgency": "high", "targetIncidentCount": "2", "activationTargetIncidentCount": "1", "pagerDutyURL": "https://pagerduty.example.com/"}, testPagerDutyAuthParams, false},
+	// using defaults
+	{map[string]string{"serviceIDs": "PABC123"}, testPagerDutyAuthParams, false},
+	// missing serviceIDs
+	{map[string]string{"serviceIDs": " , "}, testPagerDutyAuthParams, true},
+	// missing apiToken
+	{map[string]string{"serviceIDs": "PABC123"}, map[string]string{}, true},
+	// invalid status
+	{map[string]string{"serviceIDs": "PABC123", "statuses": "triggered,snoozed"}, testPagerDutyAuthParams, true},
+	// invalid urgency
+	{map[string]string{"serviceIDs": "PABC123", "urgency": "critical"}, testPagerDutyAuthParams, true},
+	// malformed targetIncidentCount
+	{map[string]string{"serviceIDs": "PABC123", "targetIncidentCount": "a"}, testPagerDutyAuthParams, true},
+	// zero targetIncidentCount
+	{map[string]string{"serviceIDs": "PABC123", "targetIncidentCount": "0"}, testPagerDutyAuthParams, true},
+	// negative activationTargetIncidentCount
+	{map[string]string{"serviceIDs": "PABC123", "activationTargetIncidentCount": "-1"}, testPagerDutyAuthParams, true},
+}
+
+var pagerDutyMetricIdentifiers = []pagerDutyMetricIdentifier{
+	{&testPagerDutyMetadata[1], 0, "s0-pagerduty-PABC123-PDEF456"},
+	{&testPagerDutyMetadata[2], 1, "s1-pagerduty-PABC123"},
+}
+
+// pagerDutyIncidentsFixture returns a page of the /incidents list with the incidents of the ids
+func pagerDutyIncidentsFixture(offset int, more bool, ids ...string) string {
+	incidents := make([]string, 0, len(ids))
+	for _, id := range ids {
+		incidents = append(incidents, fmt.Sprintf(`{
+			"id": "%s",
+			"type": "incident",
+			"incident_number": 1234,
+			"title": "The server is on fire.",
+			"status": "triggered",
+			"urgency": "high",
+			"service": {"id": "PABC123", "type": "service_reference", "summary": "My Mail Service"}
+		}`, id))
+	}
+	return fmt.Sprintf(`{"incidents": [%s], "limit": 100, "offset": %d, "total": null, "more": %t}`, strings.Join(incidents, ","), offset, more)
+}
+
+func TestPagerDutyParseMetadata(t *testing.T) {
+	for _, testData := range testPagerDutyMetadata {
+		_, err := parsePagerDutyMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+	}
+}
+
+func TestPagerDutyGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range pagerDutyMetricIdentifiers {
+		meta, err := parsePagerDutyMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockPagerDutyScaler := pagerDutyScaler{metadata: meta}
+
+		metricSpec := mockPagerDutyScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+func newTestPagerDutyScaler(t *testing.T, server *httptest.Server, metadata map[string]string) *pagerDutyScaler {
+	metadata["pagerDutyURL"] = server.URL
+	meta, err := parsePagerDutyMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: testPagerDutyAuthParams})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	return &pagerDutyScaler{metadata: meta, httpClient: server.Client()}
+}
+
+func TestPagerDutyGetIncidentCount(t *testing.T) {
+	var offsets []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token token=token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		query := r.URL.Query()
+		if r.URL.Path != "/incidents" || strings.Join(query["service_ids[]"], ",") != "PABC123,PDEF456" ||
+			strings.Join(query["statuses[]"], ",") != "triggered,acknowledged" || query.Get("urgencies[]") != "high" {
+			t.Errorf("unexpected incidents query %s", r.URL.RawQuery)
+		}
+
+		offsets = append(offsets, query.Get("offset"))
+		switch query.Get("offset") {
+		case "0":
+			_, _ = w.Write([]byte(pagerDutyIncidentsFixture(0, true, "Q1", "Q2")))
+		case "2":
+			_, _ = w.Write([]byte(pagerDutyIncidentsFixture(2, false, "Q3")))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	s := newTestPagerDutyScaler(t, server, map[string]string{"serviceIDs": "PABC123,PDEF456", "urgency": "high", "activationTargetIncidentCount": "2"})
+
+	incidents, err := s.getIncidentCount(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if incidents != 3 {
+		t.Errorf("Expected 3 incidents, got %d", incidents)
+	}
+	if strings.Join(offsets, ",") != "0,2" {
+		t.Errorf("Expected the pages at offsets 0 and 2, got %v", offsets)
+	}
+
+	active, err := s.IsActive(context.Background())
+	if err != nil || !active {
+		t.Errorf("Expected the scaler to be active, got %t, %v", active, err)
+	}
+}
+
+func TestPagerDutyNoIncidents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pagerDutyIncidentsFixture(0, false)))
+	}))
+	defer server.Close()
+
+	s := newTestPagerDutyScaler(t, server, map[string]string{"serviceIDs": "PABC123"})
+
+	active, err := s.IsActive(context.Background())
+	if err != nil || active {
+		t.Errorf("Expected the scaler to be inactive, got %t, %v", active, err)
+	}
+}
+
+func TestPagerDutyAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": {"message": "Unauthorized", "code": 2006}}`))
+	}))
+	defer server.Close()
+
+	s := newTestPagerDutyScaler(t, server, map[string]string{"serviceIDs": "PABC123"})
+
+	_, err := s.getIncidentCount(context.Background())
+	if err == nil || err.Error() != "pagerduty API returned 401: Unauthorized" {
+		t.Error("Expected the API error, got", err)
+	}
+}
+
+func TestPagerDutyRetriesWhenRateLimited(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(pagerDutyIncidentsFixture(0, false, "Q1")))
+	}))
+	defer server.Close()
+
+	s := newTestPagerDutyScaler(t, server, map[string]string{"serviceIDs": "PABC123"})
+
+	incidents, err := s.getIncidentCount(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if incidents != 1 || calls != 2 {
+		t.Errorf("Expected 1 incident after a retry, got %d incidents in %d calls", incidents, calls)
+	}
+}
@@ -6,13 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	pConfig "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/xhit/go-str2duration/v2"
 	"google.golang.org/grpc"
@@ -42,10 +48,39 @@ const (
 	predictKubeMetricPrefix = "predictkube_metric"
 
 	invalidMetricTypeErr = "metric type is invalid"
+
+	// defaultMLEngineHost is the public PredictKube ML engine, its certificate is always verified
+	defaultMLEngineHost = "api.predictkube.com"
+
+	// predictKubeMaxResolution is the maximum number of points per series Prometheus returns for a range query
+	predictKubeMaxResolution = 11000
+
+	defaultPredictKubeScalingFactor = 1.0
+
+	// predictKubeHTTPTransportNetHTTP and predictKubeHTTPTransportFastHTTP select the transport of the Prometheus client
+	predictKubeHTTPTransportNetHTTP  = "nethttp"
+	predictKubeHTTPTransportFastHTTP = "fasthttp"
+
+	// predictKubeHorizonAggregationLast, Max and Avg reduce the forecast over the horizon to the scaling value.
+	// Only last applies while the response of the ML engine carries the forecast at the end of the horizon alone
+	predictKubeHorizonAggregationLast = "last"
+	predictKubeHorizonAggregationMax  = "max"
+	predictKubeHorizonAggregationAvg  = "avg"
+
+	// predictKubeConfidenceBoundUpper, Lower and Mean select the bound of the forecast confidence interval.
+	// Only mean applies while the response of the ML engine carries the point forecast alone
+	predictKubeConfidenceBoundUpper = "upper"
+	predictKubeConfidenceBoundLower = "lower"
+	predictKubeConfidenceBoundMean  = "mean"
+
+	// predictKubeAggregateSeriesSum, Avg and Max merge the series returned by a query per timestamp
+	predictKubeAggregateSeriesSum = "sum"
+	predictKubeAggregateSeriesAvg = "avg"
+	predictKubeAggregateSeriesMax = "max"
 )
 
 var (
-	mlEngineHost = "api.predictkube.com"
+	mlEngineHost = defaultMLEngineHost
 	mlEnginePort = 443
 
 	defaultStep = time.Minute * 5
@@ -57,13 +92,13 @@ var (
 			Enabled: false,
 		},
 		Conn: &libs.Connection{
-			Host:            mlEngineHost,
-			Port:            uint16(mlEnginePort),
-			ReadBufferSize:  50 << 20,
-			WriteBufferSize: 50 << 20,
-			MaxMessageSize:  50 << 20,
-			Insecure:        false,
-			Timeout:         time.Second * 15,
+			Host: mlEngineHost,
+			Port: uint16(mlEnginePort),
+			// the read and write buffers are allocated for every connection, they are left to the gRPC
+			// defaults, only the messages may be large
+			MaxMessageSize: 50 << 20,
+			Insecure:       false,
+			Timeout:        time.Second * 15,
 		},
 		Keepalive: &libs.Keepalive{
 			Time:    time.Minute * 5,
@@ -77,29 +112,74 @@ var (
 )
 
 type PredictKubeScaler struct {
-	metricType       v2beta2.MetricTargetType
-	metadata         *predictKubeMetadata
-	prometheusClient api.Client
-	grpcConn         *grpc.ClientConn
-	grpcClient       pb.MlEngineServiceClient
-	healthClient     health.HealthClient
-	api              v1.API
+	metricType   v2beta2.MetricTargetType
+	metadata     *predictKubeMetadata
+	grpcConn     *grpc.ClientConn
+	grpcClient   pb.MlEngineServiceClient
+	healthClient health.HealthClient
+	userAgent    string
+
+	// apis has a Prometheus API per address of prometheusAddress, activeAPI is the one
+	// queried first. It is only moved to the next address when the active one is down
+	apiLock   sync.Mutex
+	apis      []v1.API
+	activeAPI int
+	// prometheusTransport is the transport shared by the apis, its idle connections are closed with the scaler
+	prometheusTransport http.RoundTripper
+
+	// healthCheckFailures counts the consecutive failed health checks
+	healthCheckFailures int64
+
+	// horizonAggregationIgnored logs only once that the response has no forecast series to aggregate
+	horizonAggregationIgnored sync.Once
+	// confidenceBoundIgnored logs only once that the response has no confidence interval
+	confidenceBoundIgnored sync.Once
+
+	predictionLock       sync.Mutex
+	prediction           int64
+	predictionSampleTime time.Time
+	predictionTime       time.Time
+
+	// adjustedStep is the step of the history queries once Prometheus rejected the resolution of the queryStep of
+	// the metadata, which is left as parsed as the scaler is polled concurrently
+	stepLock     sync.Mutex
+	adjustedStep time.Duration
 }
 
 type predictKubeMetadata struct {
-	predictHorizon    time.Duration
-	historyTimeWindow time.Duration
-	stepDuration      time.Duration
-	apiKey            string
-	prometheusAddress string
-	prometheusAuth    *authentication.AuthMeta
-	query             string
-	threshold         int64
-	scalerIndex       int
+	predictHorizon      time.Duration
+	historyTimeWindow   time.Duration
+	stepDuration        time.Duration
+	maxQueryRange       time.Duration
+	apiKey              string
+	prometheusAddresses []string
+	prometheusAuth      *authentication.AuthMeta
+	httpTransport       authentication.TransportType
+	horizonAggregation  string
+	confidenceBound     string
+	aggregateSeries     string
+	query               string
+	threshold           int64
+	scalingFactor       float64
+	minValue            int64
+	maxValue            int64
+	scalerIndex         int
+
+	// grpcHost and grpcPort are the ML engine address from grpcAddress, the public one when empty
+	grpcHost       string
+	grpcPort       int
+	grpcServerName string
+	grpcUnsafeSsl  bool
+
+	disablePredictionCache bool
+	ignoreNullValues       bool
 }
 
 var predictKubeLog = logf.Log.WithName("predictkube_scaler")
 
+// errPredictKubeNoObservations is returned when the Prometheus query returned no series
+var errPredictKubeNoObservations = errors.New("empty response after predict request, the prometheus query returned no observations")
+
 func (s *PredictKubeScaler) setupClientConn() error {
 	clientOpt, err := pc.SetGrpcClientOptions(grpcConf,
 		&libs.Base{
@@ -116,11 +196,10 @@ func (s *PredictKubeScaler) setupClientConn() error {
 		pc.InjectPublicClientMetadataInterceptor(s.metadata.apiKey),
 	)
 
+	host, port := s.mlEngineAddress()
 	if !grpcConf.Conn.Insecure {
 		clientOpt = append(clientOpt, grpc.WithTransportCredentials(
-			credentials.NewTLS(&tls.Config{
-				ServerName: mlEngineHost,
-			}),
+			credentials.NewTLS(predictKubeTLSConfig(s.metadata, host)),
 		))
 	}
 
@@ -128,7 +207,9 @@ func (s *PredictKubeScaler) setupClientConn() error {
 		return err
 	}
 
-	s.grpcConn, err = grpc.Dial(fmt.Sprintf("%s:%d", mlEngineHost, mlEnginePort), clientOpt...)
+	clientOpt = append(clientOpt, grpc.WithUserAgent(s.userAgent))
+
+	s.grpcConn, err = grpc.Dial(net.JoinHostPort(host, strconv.Itoa(port)), clientOpt...)
 	if err != nil {
 		return err
 	}
@@ -139,6 +220,32 @@ func (s *PredictKubeScaler) setupClientConn() error {
 	return err
 }
 
+// mlEngineAddress returns the host and port of the ML engine, grpcAddress overrides the public one
+func (s *PredictKubeScaler) mlEngineAddress() (string, int) {
+	if s.metadata.grpcHost != "" {
+		return s.metadata.grpcHost, s.metadata.grpcPort
+	}
+	return mlEngineHost, mlEnginePort
+}
+
+// predictKubeTLSConfig returns the TLS config of the connection to the ML engine at host. The expected
+// server name can only be overridden, or the verification skipped, when the ML engine isn't the public one,
+// e.g. when it is reached through an internal load balancer with a certificate for another name
+func predictKubeTLSConfig(meta *predictKubeMetadata, host string) *tls.Config {
+	config := &tls.Config{
+		ServerName: host,
+	}
+	if host == defaultMLEngineHost {
+		return config
+	}
+
+	if meta.grpcServerName != "" {
+		config.ServerName = meta.grpcServerName
+	}
+	config.InsecureSkipVerify = meta.grpcUnsafeSsl
+	return config
+}
+
 // NewPredictKubeScaler creates a new PredictKube scaler
 func NewPredictKubeScaler(ctx context.Context, config *ScalerConfig) (*PredictKubeScaler, error) {
 	s := &PredictKubeScaler{}
@@ -158,6 +265,7 @@ func NewPredictKubeScaler(ctx context.Context, config *ScalerConfig) (*PredictKu
 	}
 
 	s.metadata = meta
+	s.userAgent = kedautil.UserAgent(config.TriggerType)
 
 	err = s.initPredictKubePrometheusConn(ctx)
 	if err != nil {
@@ -174,22 +282,15 @@ func NewPredictKubeScaler(ctx context.Context, config *ScalerConfig) (*PredictKu
 	return s, nil
 }
 
-// IsActive returns true if we are able to get metrics from PredictKube
+// IsActive returns true if the last observation shows traffic. The PredictKube health is only
+// checked to report problems, a PredictKube outage mustn't scale a busy workload to zero
 func (s *PredictKubeScaler) IsActive(ctx context.Context) (bool, error) {
-	results, err := s.doQuery(ctx)
+	results, _, err := s.doQuery(ctx)
 	if err != nil {
 		return false, err
 	}
 
-	resp, err := s.healthClient.Check(ctx, &health.HealthCheckRequest{})
-
-	if resp == nil {
-		return len(results) > 0, fmt.Errorf("can't connect grpc server: empty server response, code: %v", codes.Unknown)
-	}
-
-	if err != nil {
-		return len(results) > 0, fmt.Errorf("can't connect grpc server: %v, code: %v", err, status.Code(err))
-	}
+	s.checkHealth(ctx)
 
 	var y int64
 	if len(results) > 0 {
@@ -199,10 +300,41 @@ func (s *PredictKubeScaler) IsActive(ctx context.Context) (bool, error) {
 	return y > 0, nil
 }
 
+// checkHealth logs failed health checks of the PredictKube service along with the number of consecutive failures
+func (s *PredictKubeScaler) checkHealth(ctx context.Context) {
+	resp, err := s.healthClient.Check(ctx, &health.HealthCheckRequest{})
+	if err == nil && resp == nil {
+		err = fmt.Errorf("empty server response, code: %v", codes.Unknown)
+	} else if err != nil {
+		err = fmt.Errorf("%v, code: %v", err, status.Code(err))
+	} else if resp.GetStatus() != health.HealthCheckResponse_SERVING {
+		err = fmt.Errorf("server is %s", resp.GetStatus())
+	}
+
+	if err != nil {
+		failures := atomic.AddInt64(&s.healthCheckFailures, 1)
+		predictKubeLog.Error(err, "can't connect grpc server, deciding activity on the observations only", "consecutiveFailures", failures)
+		return
+	}
+	atomic.StoreInt64(&s.healthCheckFailures, 0)
+}
+
 func (s *PredictKubeScaler) Close(_ context.Context) error {
+	closeIdleConnections(s.prometheusTransport)
+	// closing the gRPC connection doesn't wait for the server, the pending RPCs are canceled
 	return s.grpcConn.Close()
 }
 
+// closeIdleConnections closes the idle connections of a Prometheus transport, the fasthttp one closes them with Close
+func closeIdleConnections(roundTripper http.RoundTripper) {
+	switch t := roundTripper.(type) {
+	case interface{ CloseIdleConnections() }:
+		t.CloseIdleConnections()
+	case interface{ Close() }:
+		t.Close()
+	}
+}
+
 func (s *PredictKubeScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
 	metricName := kedautil.NormalizeString(fmt.Sprintf("predictkube-%s", predictKubeMetricPrefix))
 	externalMetric := &v2beta2.ExternalMetricSource{
@@ -220,20 +352,40 @@ func (s *PredictKubeScaler) GetMetricSpecForScaling(context.Context) []v2beta2.M
 
 func (s *PredictKubeScaler) GetMetrics(ctx context.Context, metricName string, _ labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
 	value, err := s.doPredictRequest(ctx)
+	if errors.Is(err, errPredictKubeNoObservations) && s.metadata.ignoreNullValues {
+		// 0 still goes through the bounds, minValue keeps its floor when the series goes quiet
+		predictKubeLog.V(1).Info("query returned no observations, reporting 0 within the bounds")
+		value, err = 0, nil
+	}
 	if err != nil {
 		predictKubeLog.Error(err, "error executing query to predict controller service")
 		return []external_metrics.ExternalMetricValue{}, err
 	}
 
-	if value == 0 {
-		err = errors.New("empty response after predict request")
+	predictKubeLog.V(1).Info(fmt.Sprintf("predict value is: %d", value))
+
+	// the factor is applied to max(observation, prediction), so both are scaled the same way
+	scaled := float64(value) * s.metadata.scalingFactor
+
+	// forecasts can spike after gaps in the observations, keep what the HPA sees within the configured bounds
+	switch {
+	case scaled > float64(s.metadata.maxValue):
+		predictKubeLog.Info("scaled prediction is above maxValue, clamping it", "prediction", value, "scaled", scaled, "maxValue", s.metadata.maxValue)
+		scaled = float64(s.metadata.maxValue)
+	case scaled < float64(s.metadata.minValue):
+		predictKubeLog.V(1).Info("scaled prediction is below minValue, raising it", "prediction", value, "scaled", scaled, "minValue", s.metadata.minValue)
+		scaled = float64(s.metadata.minValue)
+	}
+
+	// a forecast of 0 is legitimate with ignoreNullValues, e.g. for an idle workload
+	if scaled == 0 && !s.metadata.ignoreNullValues {
+		err = errors.New("empty response after predict request, the ML engine returned 0")
 		predictKubeLog.Error(err, "")
 		return nil, err
 	}
 
-	predictKubeLog.V(1).Info(fmt.Sprintf("predict value is: %d", value))
-
-	val := *resource.NewQuantity(value, resource.DecimalSI)
+	// a milli quantity keeps the fraction instead of rounding the headroom away
+	val := *resource.NewMilliQuantity(int64(math.Round(scaled*1000)), resource.DecimalSI)
 
 	metric := external_metrics.ExternalMetricValue{
 		MetricName: metricName,
@@ -245,13 +397,28 @@ func (s *PredictKubeScaler) GetMetrics(ctx context.Context, metricName string, _
 }
 
 func (s *PredictKubeScaler) doPredictRequest(ctx context.Context) (int64, error) {
-	results, err := s.doQuery(ctx)
+	results, step, err := s.doQuery(ctx)
 	if err != nil {
 		return 0, err
 	}
 
+	// without observations the ML engine can only answer 0, don't ask it
+	if len(results) == 0 {
+		return 0, errPredictKubeNoObservations
+	}
+
+	// PredictKube only produces a new forecast once per step, so there is no need
+	// to pay for another prediction until a step has passed or newer data shows up
+	latestSample := latestObservationTime(results)
+	if !s.metadata.disablePredictionCache {
+		if value, ok := s.getCachedPrediction(latestSample, step); ok {
+			predictKubeLog.V(1).Info("reusing cached prediction", "value", value)
+			return value, nil
+		}
+	}
+
 	resp, err := s.grpcClient.GetPredictMetric(ctx, &pb.ReqGetPredictMetric{
-		ForecastHorizon: uint64(math.Round(float64(s.metadata.predictHorizon / s.metadata.stepDuration))),
+		ForecastHorizon: uint64(math.Round(float64(s.metadata.predictHorizon / step))),
 		Observations:    results,
 	})
 
@@ -264,77 +431,416 @@ func (s *PredictKubeScaler) doPredictRequest(ctx context.Context) (int64, error)
 		y = int64(results[len(results)-1].Value)
 	}
 
-	x := resp.GetResultMetric()
+	x := s.forecastValue(resp)
 
-	return func(x, y int64) int64 {
+	value := func(x, y int64) int64 {
 		if x < y {
 			return y
 		}
 		return x
-	}(x, y), nil
+	}(x, y)
+
+	if !s.metadata.disablePredictionCache {
+		s.setCachedPrediction(value, latestSample)
+	}
+
+	return value, nil
+}
+
+// predictKubeForecast is the response of the ML engine, the forecast at the end of the horizon
+type predictKubeForecast interface {
+	GetResultMetric() int64
+}
+
+// predictKubeForecastSeries is implemented by the responses also carrying the forecast of every step of the horizon
+type predictKubeForecastSeries interface {
+	GetForecastSeries() []int64
 }
 
-func (s *PredictKubeScaler) doQuery(ctx context.Context) ([]*commonproto.Item, error) {
-	currentTime := time.Now().UTC()
+// predictKubeConfidenceInterval is implemented by the responses also carrying the confidence interval of the forecast,
+// the bounds of every step of the horizon
+type predictKubeConfidenceInterval interface {
+	GetUpperBoundSeries() []int64
+	GetLowerBoundSeries() []int64
+}
+
+// forecastValue reduces the series of the response selected by confidenceBound to the scaling value according to
+// horizonAggregation. The point forecast at the end of the horizon is used when the response carries no series
+func (s *PredictKubeScaler) forecastValue(resp predictKubeForecast) int64 {
+	if s.metadata.horizonAggregation == predictKubeHorizonAggregationLast && s.metadata.confidenceBound == predictKubeConfidenceBoundMean {
+		return resp.GetResultMetric()
+	}
+
+	series := s.forecastSeries(resp)
+	if len(series) == 0 {
+		if s.metadata.horizonAggregation != predictKubeHorizonAggregationLast {
+			s.horizonAggregationIgnored.Do(func() {
+				predictKubeLog.Info("the ML engine response has no forecast series, horizonAggregation is ignored and the forecast at the end of the horizon is used",
+					"horizonAggregation", s.metadata.horizonAggregation)
+			})
+		}
+		return resp.GetResultMetric()
+	}
+
+	return aggregateForecastSeries(series, s.metadata.horizonAggregation)
+}
+
+// forecastSeries returns the bound of the confidence interval selected by confidenceBound, the point forecast for
+// the mean and when the response carries no confidence interval
+func (s *PredictKubeScaler) forecastSeries(resp predictKubeForecast) []int64 {
+	if s.metadata.confidenceBound != predictKubeConfidenceBoundMean {
+		var bound []int64
+		if interval, ok := resp.(predictKubeConfidenceInterval); ok {
+			if s.metadata.confidenceBound == predictKubeConfidenceBoundUpper {
+				bound = interval.GetUpperBoundSeries()
+			} else {
+				bound = interval.GetLowerBoundSeries()
+			}
+		}
+		if len(bound) > 0 {
+			return bound
+		}
+		s.confidenceBoundIgnored.Do(func() {
+			predictKubeLog.Info("the ML engine response has no confidence interval, confidenceBound is ignored and the point forecast is used",
+				"confidenceBound", s.metadata.confidenceBound)
+		})
+	}
+
+	if withSeries, ok := resp.(predictKubeForecastSeries); ok {
+		return withSeries.GetForecastSeries()
+	}
+	return nil
+}
 
+// aggregateForecastSeries returns the maximum, the average or the last value of the forecast series
+func aggregateForecastSeries(series []int64, aggregation string) int64 {
+	switch aggregation {
+	case predictKubeHorizonAggregationMax:
+		maxValue := series[0]
+		for _, v := range series[1:] {
+			if v > maxValue {
+				maxValue = v
+			}
+		}
+		return maxValue
+	case predictKubeHorizonAggregationAvg:
+		var sum float64
+		for _, v := range series {
+			sum += float64(v)
+		}
+		return int64(math.Round(sum / float64(len(series))))
+	default:
+		return series[len(series)-1]
+	}
+}
+
+// getCachedPrediction returns the cached prediction if it was made less than a step ago
+// and no observation newer than the cached step window has been recorded since
+func (s *PredictKubeScaler) getCachedPrediction(latestSample time.Time, step time.Duration) (int64, bool) {
+	s.predictionLock.Lock()
+	defer s.predictionLock.Unlock()
+
+	if s.predictionTime.IsZero() {
+		return 0, false
+	}
+
+	if time.Since(s.predictionTime) >= step {
+		return 0, false
+	}
+
+	if latestSample.Sub(s.predictionSampleTime) >= step {
+		return 0, false
+	}
+
+	return s.prediction, true
+}
+
+func (s *PredictKubeScaler) setCachedPrediction(value int64, latestSample time.Time) {
+	s.predictionLock.Lock()
+	defer s.predictionLock.Unlock()
+
+	s.prediction = value
+	s.predictionSampleTime = latestSample
+	s.predictionTime = time.Now()
+}
+
+// latestObservationTime returns the timestamp of the most recent observation
+func latestObservationTime(results []*commonproto.Item) time.Time {
+	var latest time.Time
+	for _, item := range results {
+		if t := item.GetTimestamp().AsTime(); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// queryStep returns the step of the history queries, the queryStep of the metadata unless Prometheus rejected its
+// resolution
+func (s *PredictKubeScaler) queryStep() time.Duration {
+	s.stepLock.Lock()
+	defer s.stepLock.Unlock()
+
+	if s.adjustedStep != 0 {
+		return s.adjustedStep
+	}
 	if s.metadata.stepDuration == 0 {
-		s.metadata.stepDuration = defaultStep
+		return defaultStep
 	}
+	return s.metadata.stepDuration
+}
+
+// raiseQueryStep increases the step of the history queries to step, it is never decreased by concurrent polls
+func (s *PredictKubeScaler) raiseQueryStep(step time.Duration) {
+	s.stepLock.Lock()
+	defer s.stepLock.Unlock()
 
-	r := v1.Range{
-		Start: currentTime.Add(-s.metadata.historyTimeWindow),
-		End:   currentTime,
-		Step:  s.metadata.stepDuration,
+	if step > s.adjustedStep {
+		s.adjustedStep = step
 	}
+}
 
-	val, warns, err := s.api.QueryRange(ctx, s.metadata.query, r)
+// doQuery returns the observations of the history window and the step they were queried with. The step is picked
+// once for the whole window, the observations sent to the ML engine must all have the same resolution
+func (s *PredictKubeScaler) doQuery(ctx context.Context) ([]*commonproto.Item, time.Duration, error) {
+	end := time.Now().UTC()
+	start := end.Add(-s.metadata.historyTimeWindow)
+
+	step := s.queryStep()
+	out, err := s.queryHistory(ctx, start, end, step)
+
+	// retrying with the same step would fail the same way on every poll, so query the whole window again, from its
+	// first chunk, with the smallest step that Prometheus accepts for the longest chunk
+	if err != nil && isMaxResolutionError(err) {
+		longest := end.Sub(start)
+		if s.metadata.maxQueryRange != 0 && s.metadata.maxQueryRange < longest {
+			longest = s.metadata.maxQueryRange
+		}
+		if adjusted := minQueryStep(longest); adjusted > step {
+			predictKubeLog.Info("Prometheus rejected the query resolution, increasing the query step",
+				"queryStep", step, "adjustedQueryStep", adjusted, "historyTimeWindow", s.metadata.historyTimeWindow)
+			s.raiseQueryStep(adjusted)
+			step = adjusted
+			out, err = s.queryHistory(ctx, start, end, step)
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, step, nil
+}
 
-	if len(warns) > 0 {
-		predictKubeLog.V(1).Info("warnings", warns)
+// queryHistory queries the [start, end] window with the step, in chunks of maxQueryRange
+func (s *PredictKubeScaler) queryHistory(ctx context.Context, start, end time.Time, step time.Duration) ([]*commonproto.Item, error) {
+	chunks := queryRangeChunks(start, end, step, s.metadata.maxQueryRange)
+	if len(chunks) == 1 {
+		return s.doRangeQuery(ctx, chunks[0])
+	}
+
+	var out []*commonproto.Item
+	for _, r := range chunks {
+		items, err := s.doRangeQuery(ctx, r)
+		if err != nil {
+			return nil, fmt.Errorf("error querying the history from %s to %s: %s", r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339), err)
+		}
+
+		// consecutive chunks share their boundary, keep the observations at it once
+		for _, item := range items {
+			if len(out) > 0 && !item.GetTimestamp().AsTime().After(out[len(out)-1].GetTimestamp().AsTime()) {
+				continue
+			}
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+// queryRangeChunks splits the [start, end] window in ranges no longer than maxQueryRange, which
+// start on the steps of the window so the chunks return the same points as a single query.
+// A maxQueryRange of 0 doesn't limit the range
+func queryRangeChunks(start, end time.Time, step, maxQueryRange time.Duration) []v1.Range {
+	chunkDuration := maxQueryRange.Truncate(step)
+	if maxQueryRange == 0 || end.Sub(start) <= chunkDuration {
+		return []v1.Range{{Start: start, End: end, Step: step}}
+	}
+
+	var chunks []v1.Range
+	for chunkStart := start; chunkStart.Before(end); chunkStart = chunkStart.Add(chunkDuration) {
+		chunkEnd := chunkStart.Add(chunkDuration)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+		chunks = append(chunks, v1.Range{Start: chunkStart, End: chunkEnd, Step: step})
+	}
+	return chunks
+}
+
+func (s *PredictKubeScaler) doRangeQuery(ctx context.Context, r v1.Range) ([]*commonproto.Item, error) {
+	val, warns, err := s.queryRange(ctx, r)
+
+	for _, warn := range warns {
+		predictKubeLog.Info("Prometheus query returned a warning", "query", s.metadata.query, "warning", warn)
 	}
 
 	if err != nil {
+		if len(warns) > 0 {
+			return nil, fmt.Errorf("%s (warnings: %s)", err, strings.Join(warns, "; "))
+		}
 		return nil, err
 	}
 
+	// a forecast based on incomplete observations is worse than no forecast at all,
+	// so don't silently pass partial results to the ML engine
+	if partial := partialDataWarnings(warns); len(partial) > 0 {
+		return nil, fmt.Errorf("prometheus returned partial data for query %s: %s", s.metadata.query, strings.Join(partial, "; "))
+	}
+
 	return s.parsePrometheusResult(val)
 }
 
+// parsePredictKubeDuration parses a duration of the trigger metadata, which must be positive.
+// str2duration accepts zero and negative durations such as "0s"
+func parsePredictKubeDuration(name, val string) (time.Duration, error) {
+	duration, err := str2duration.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("%s parsing error %s", name, err.Error())
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("%s must be greater than 0, got %s", name, val)
+	}
+	return duration, nil
+}
+
+// minHistoryStep returns the smallest whole-second step that queries the history window in at most maxSteps steps
+func minHistoryStep(window time.Duration, maxSteps int64) time.Duration {
+	step := time.Duration(math.Ceil(float64(window) / float64(maxSteps)))
+	if step%time.Second != 0 {
+		step = step.Truncate(time.Second) + time.Second
+	}
+	return step
+}
+
+// isMaxResolutionError checks whether Prometheus rejected a range query because
+// it would return too many points per series
+func isMaxResolutionError(err error) bool {
+	return strings.Contains(err.Error(), "exceeded maximum resolution")
+}
+
+// minQueryStep returns the smallest whole-second step that keeps a range query over
+// the given window under the Prometheus resolution limit
+func minQueryStep(window time.Duration) time.Duration {
+	step := time.Duration(math.Ceil(float64(window) / predictKubeMaxResolution))
+	return step.Truncate(time.Second) + time.Second
+}
+
+// predictKubePartialResponseWarnings are the prefixes of the warnings of a Thanos querier in front of Prometheus
+// when it answers with a partial response, the data of some of its stores is missing
+var predictKubePartialResponseWarnings = []string{
+	"receive series from ",
+	"fetch series for ",
+	"No StoreAPIs matched for this query",
+}
+
+// partialDataWarnings returns the warnings of a partial response, the other warnings don't affect the observations
+func partialDataWarnings(warns v1.Warnings) []string {
+	var out []string
+	for _, warn := range warns {
+		for _, prefix := range predictKubePartialResponseWarnings {
+			if strings.HasPrefix(warn, prefix) {
+				out = append(out, warn)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// seriesToItems converts the series returned by the query to the observations sent to the ML engine.
+// Several series are merged per timestamp according to aggregateSeries, without it the query
+// is rejected as the interleaved observations of unrelated series make the forecast meaningless
+func (s *PredictKubeScaler) seriesToItems(series [][]model.SamplePair, metricName string) ([]*commonproto.Item, error) {
+	var points []model.SamplePair
+	switch {
+	case len(series) == 0:
+		return nil, nil
+	case len(series) == 1:
+		points = series[0]
+	case s.metadata.aggregateSeries == "":
+		return nil, fmt.Errorf("query returned %d series, aggregate them in the query (e.g. sum(...)) or set aggregateSeries to %s, %s or %s",
+			len(series), predictKubeAggregateSeriesSum, predictKubeAggregateSeriesAvg, predictKubeAggregateSeriesMax)
+	default:
+		points = mergeSeries(series, s.metadata.aggregateSeries)
+	}
+
+	out := make([]*commonproto.Item, 0, len(points))
+	for _, point := range points {
+		t, err := tc.AdaptTimeToPbTimestamp(tc.TimeToTimePtr(point.Timestamp.Time()))
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, &commonproto.Item{
+			Timestamp:  t,
+			Value:      float64(point.Value),
+			MetricName: metricName,
+		})
+	}
+	return out, nil
+}
+
+// mergeSeries merges the series per timestamp with the sum, the average or the maximum of
+// the values present at each timestamp. The result is sorted by timestamp
+func mergeSeries(series [][]model.SamplePair, aggregation string) []model.SamplePair {
+	values := map[model.Time][]float64{}
+	for _, points := range series {
+		for _, point := range points {
+			values[point.Timestamp] = append(values[point.Timestamp], float64(point.Value))
+		}
+	}
+
+	merged := make([]model.SamplePair, 0, len(values))
+	for timestamp, vals := range values {
+		var result float64
+		switch aggregation {
+		case predictKubeAggregateSeriesMax:
+			result = vals[0]
+			for _, v := range vals[1:] {
+				result = math.Max(result, v)
+			}
+		default:
+			for _, v := range vals {
+				result += v
+			}
+			if aggregation == predictKubeAggregateSeriesAvg {
+				result /= float64(len(vals))
+			}
+		}
+		merged = append(merged, model.SamplePair{Timestamp: timestamp, Value: model.SampleValue(result)})
+	}
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Timestamp.Before(merged[j].Timestamp) })
+	return merged
+}
+
 // parsePrometheusResult parsing response from prometheus server.
 func (s *PredictKubeScaler) parsePrometheusResult(result model.Value) (out []*commonproto.Item, err error) {
 	metricName := GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("predictkube-%s", predictKubeMetricPrefix)))
 	switch result.Type() {
 	case model.ValVector:
 		if res, ok := result.(model.Vector); ok {
+			series := make([][]model.SamplePair, 0, len(res))
 			for _, val := range res {
-				t, err := tc.AdaptTimeToPbTimestamp(tc.TimeToTimePtr(val.Timestamp.Time()))
-				if err != nil {
-					return nil, err
-				}
-
-				out = append(out, &commonproto.Item{
-					Timestamp:  t,
-					Value:      float64(val.Value),
-					MetricName: metricName,
-				})
+				series = append(series, []model.SamplePair{{Timestamp: val.Timestamp, Value: val.Value}})
 			}
+			return s.seriesToItems(series, metricName)
 		}
 	case model.ValMatrix:
 		if res, ok := result.(model.Matrix); ok {
+			series := make([][]model.SamplePair, 0, len(res))
 			for _, val := range res {
-				for _, v := range val.Values {
-					t, err := tc.AdaptTimeToPbTimestamp(tc.TimeToTimePtr(v.Timestamp.Time()))
-					if err != nil {
-						return nil, err
-					}
-
-					out = append(out, &commonproto.Item{
-						Timestamp:  t,
-						Value:      float64(v.Value),
-						MetricName: metricName,
-					})
-				}
+				series = append(series, val.Values)
 			}
+			return s.seriesToItems(series, metricName)
 		}
 	case model.ValScalar:
 		if res, ok := result.(*model.Scalar); ok {
@@ -388,44 +894,82 @@ func parsePredictKubeMetadata(config *ScalerConfig) (result *predictKubeMetadata
 		return nil, fmt.Errorf("no query given")
 	}
 
-	if val, ok := config.TriggerMetadata["prometheusAddress"]; ok {
-		err = validate.Var(val, "url")
-		if err != nil {
-			return nil, fmt.Errorf("invalid prometheusAddress")
-		}
+	prometheusAddress, err := getFromAuthOrEnvOrMeta(config, "prometheusAddress")
+	if err != nil {
+		return nil, err
+	}
+	if prometheusAddress != "" {
+		// a comma separated list of replicas, queried in order when one is down
+		for _, address := range strings.Split(prometheusAddress, ",") {
+			address = strings.TrimSpace(address)
+			err = validate.Var(address, "url")
+			if err != nil {
+				return nil, fmt.Errorf("invalid prometheusAddress %q", address)
+			}
 
-		meta.prometheusAddress = val
+			meta.prometheusAddresses = append(meta.prometheusAddresses, address)
+		}
 	} else {
 		return nil, fmt.Errorf("no prometheusAddress given")
 	}
 
 	if val, ok := config.TriggerMetadata["predictHorizon"]; ok {
-		meta.predictHorizon, err = str2duration.ParseDuration(val)
+		meta.predictHorizon, err = parsePredictKubeDuration("predictHorizon", val)
 		if err != nil {
-			return nil, fmt.Errorf("predictHorizon parsing error %s", err.Error())
+			return nil, err
 		}
 	} else {
 		return nil, fmt.Errorf("no predictHorizon given")
 	}
 
 	if val, ok := config.TriggerMetadata["queryStep"]; ok {
-		meta.stepDuration, err = str2duration.ParseDuration(val)
+		meta.stepDuration, err = parsePredictKubeDuration("queryStep", val)
 		if err != nil {
-			return nil, fmt.Errorf("queryStep parsing error %s", err.Error())
+			return nil, err
 		}
 	} else {
 		return nil, fmt.Errorf("no queryStep given")
 	}
 
 	if val, ok := config.TriggerMetadata["historyTimeWindow"]; ok {
-		meta.historyTimeWindow, err = str2duration.ParseDuration(val)
+		meta.historyTimeWindow, err = parsePredictKubeDuration("historyTimeWindow", val)
 		if err != nil {
-			return nil, fmt.Errorf("historyTimeWindow parsing error %s", err.Error())
+			return nil, err
 		}
 	} else {
 		return nil, fmt.Errorf("no historyTimeWindow given")
 	}
 
+	if meta.predictHorizon < meta.stepDuration {
+		return nil, fmt.Errorf("predictHorizon %s must not be shorter than queryStep %s, the forecast would have no step", meta.predictHorizon, meta.stepDuration)
+	}
+
+	// Prometheus may limit the range of a query, longer history windows are queried in chunks
+	if val, ok := config.TriggerMetadata["maxQueryRange"]; ok && val != "" {
+		meta.maxQueryRange, err = parsePredictKubeDuration("maxQueryRange", val)
+		if err != nil {
+			return nil, err
+		}
+		if meta.maxQueryRange < meta.stepDuration {
+			return nil, fmt.Errorf("maxQueryRange %s must not be shorter than queryStep %s", meta.maxQueryRange, meta.stepDuration)
+		}
+	}
+
+	maxHistorySteps := int64(predictKubeMaxResolution)
+	if val, ok := config.TriggerMetadata["maxHistorySteps"]; ok && val != "" {
+		maxHistorySteps, err = strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("maxHistorySteps parsing error %s", err.Error())
+		}
+		if maxHistorySteps <= 0 {
+			return nil, fmt.Errorf("maxHistorySteps must be greater than 0")
+		}
+	}
+	if steps := int64(meta.historyTimeWindow / meta.stepDuration); steps > maxHistorySteps {
+		return nil, fmt.Errorf("historyTimeWindow %s with queryStep %s queries %d steps, more than the %d allowed by maxHistorySteps: increase queryStep to at least %s or shorten historyTimeWindow",
+			meta.historyTimeWindow, meta.stepDuration, steps, maxHistorySteps, minHistoryStep(meta.historyTimeWindow, maxHistorySteps))
+	}
+
 	if val, ok := config.TriggerMetadata["threshold"]; ok {
 		meta.threshold, err = strconv.ParseInt(val, 10, 64)
 		if err != nil {
@@ -435,6 +979,125 @@ func parsePredictKubeMetadata(config *ScalerConfig) (result *predictKubeMetadata
 		return nil, fmt.Errorf("no threshold given")
 	}
 
+	meta.scalingFactor = defaultPredictKubeScalingFactor
+	if val, ok := config.TriggerMetadata["scalingFactor"]; ok {
+		meta.scalingFactor, err = strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("scalingFactor parsing error %s", err.Error())
+		}
+		if meta.scalingFactor <= 0 {
+			return nil, fmt.Errorf("scalingFactor must be greater than 0")
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["minValue"]; ok {
+		meta.minValue, err = strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("minValue parsing error %s", err.Error())
+		}
+		if meta.minValue < 0 {
+			return nil, fmt.Errorf("minValue must not be negative")
+		}
+	}
+
+	meta.maxValue = math.MaxInt64
+	if val, ok := config.TriggerMetadata["maxValue"]; ok {
+		meta.maxValue, err = strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("maxValue parsing error %s", err.Error())
+		}
+		if meta.maxValue < 0 {
+			return nil, fmt.Errorf("maxValue must not be negative")
+		}
+	}
+
+	if meta.minValue > meta.maxValue {
+		return nil, fmt.Errorf("minValue must not be greater than maxValue")
+	}
+
+	if val, ok := config.TriggerMetadata["disablePredictionCache"]; ok {
+		meta.disablePredictionCache, err = strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("disablePredictionCache parsing error %s", err.Error())
+		}
+	}
+
+	meta.ignoreNullValues = true
+	if val, ok := config.TriggerMetadata["ignoreNullValues"]; ok {
+		meta.ignoreNullValues, err = strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("ignoreNullValues parsing error %s", err.Error())
+		}
+	}
+
+	meta.horizonAggregation = predictKubeHorizonAggregationLast
+	if val, ok := config.TriggerMetadata["horizonAggregation"]; ok && val != "" {
+		switch val {
+		case predictKubeHorizonAggregationLast, predictKubeHorizonAggregationMax, predictKubeHorizonAggregationAvg:
+			meta.horizonAggregation = val
+		default:
+			return nil, fmt.Errorf("horizonAggregation must be %s, %s or %s, got %s", predictKubeHorizonAggregationLast, predictKubeHorizonAggregationMax, predictKubeHorizonAggregationAvg, val)
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["aggregateSeries"]; ok && val != "" {
+		switch val {
+		case predictKubeAggregateSeriesSum, predictKubeAggregateSeriesAvg, predictKubeAggregateSeriesMax:
+			meta.aggregateSeries = val
+		default:
+			return nil, fmt.Errorf("aggregateSeries must be %s, %s or %s, got %s", predictKubeAggregateSeriesSum, predictKubeAggregateSeriesAvg, predictKubeAggregateSeriesMax, val)
+		}
+	}
+
+	meta.confidenceBound = predictKubeConfidenceBoundMean
+	if val, ok := config.TriggerMetadata["confidenceBound"]; ok && val != "" {
+		switch val {
+		case predictKubeConfidenceBoundUpper, predictKubeConfidenceBoundLower, predictKubeConfidenceBoundMean:
+			meta.confidenceBound = val
+		default:
+			return nil, fmt.Errorf("confidenceBound must be %s, %s or %s, got %s", predictKubeConfidenceBoundUpper, predictKubeConfidenceBoundLower, predictKubeConfidenceBoundMean, val)
+		}
+	}
+
+	meta.httpTransport = authentication.FastHTTP
+	if val, ok := config.TriggerMetadata["httpTransport"]; ok && val != "" {
+		switch val {
+		case predictKubeHTTPTransportNetHTTP:
+			meta.httpTransport = authentication.NetHTTP
+		case predictKubeHTTPTransportFastHTTP:
+			meta.httpTransport = authentication.FastHTTP
+		default:
+			return nil, fmt.Errorf("httpTransport must be %s or %s, got %s", predictKubeHTTPTransportNetHTTP, predictKubeHTTPTransportFastHTTP, val)
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["grpcAddress"]; ok && val != "" {
+		host, port, err := net.SplitHostPort(val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grpcAddress %s: %s", val, err)
+		}
+		meta.grpcPort, err = strconv.Atoi(port)
+		if err != nil || meta.grpcPort <= 0 || meta.grpcPort > math.MaxUint16 {
+			return nil, fmt.Errorf("invalid grpcAddress %s: invalid port %s", val, port)
+		}
+		meta.grpcHost = host
+	}
+
+	if val, ok := config.TriggerMetadata["grpcServerName"]; ok && val != "" {
+		meta.grpcServerName = val
+	}
+
+	if val, ok := config.TriggerMetadata["grpcUnsafeSsl"]; ok && val != "" {
+		meta.grpcUnsafeSsl, err = strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("grpcUnsafeSsl parsing error %s", err.Error())
+		}
+	}
+
+	if (meta.grpcServerName != "" || meta.grpcUnsafeSsl) && (meta.grpcHost == "" || meta.grpcHost == defaultMLEngineHost) {
+		return nil, fmt.Errorf("grpcServerName and grpcUnsafeSsl can only be set with the grpcAddress of a custom ML engine host")
+	}
+
 	meta.scalerIndex = config.ScalerIndex
 
 	if val, ok := config.AuthParams["apiKey"]; ok {
@@ -448,41 +1111,140 @@ func parsePredictKubeMetadata(config *ScalerConfig) (result *predictKubeMetadata
 		return nil, fmt.Errorf("no api key given")
 	}
 
+	if err = validatePredictKubeBasicAuth(config); err != nil {
+		return nil, err
+	}
+
 	// parse auth configs from ScalerConfig
 	meta.prometheusAuth, err = authentication.GetAuthConfigs(config.TriggerMetadata, config.AuthParams)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error parsing authModes of the prometheus address: %s", err)
 	}
 
 	return &meta, nil
 }
 
+// validatePredictKubeBasicAuth checks the username of the prometheus address is given with authModes basic.
+// Without basic, username and password are ignored like the prometheus scaler does, so both can share a TriggerAuthentication
+func validatePredictKubeBasicAuth(config *ScalerConfig) error {
+	for _, mode := range strings.Split(config.TriggerMetadata["authModes"], ",") {
+		if authentication.Type(strings.TrimSpace(mode)) == authentication.BasicAuthType && config.AuthParams["username"] == "" {
+			return fmt.Errorf("no username given, it's required by authModes basic")
+		}
+	}
+	return nil
+}
+
+// createPredictKubeRoundTripper creates the http.RoundTripper of the Prometheus client with the auth settings from ScalerConfig.
+// The net/http transport only handles the TLS and workload identity settings, so the credentials are added here
+// the same way the fasthttp transport adds them
+func createPredictKubeRoundTripper(meta *predictKubeMetadata) (http.RoundTripper, error) {
+	roundTripper, err := authentication.CreateHTTPRoundTripper(meta.httpTransport, meta.prometheusAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := meta.prometheusAuth
+	if meta.httpTransport != authentication.NetHTTP || auth == nil {
+		return roundTripper, nil
+	}
+
+	switch {
+	case auth.EnableBasicAuth:
+		return pConfig.NewBasicAuthRoundTripper(auth.Username, pConfig.Secret(auth.Password), "", roundTripper), nil
+	case auth.EnableBearerAuth:
+		return pConfig.NewAuthorizationCredentialsRoundTripper("Bearer", pConfig.Secret(auth.BearerToken), roundTripper), nil
+	}
+	return roundTripper, nil
+}
+
 func (s *PredictKubeScaler) ping(ctx context.Context) (err error) {
-	_, err = s.api.Runtimeinfo(ctx)
+	return s.withPrometheusFailover(func(api v1.API) error {
+		_, err := api.Runtimeinfo(ctx)
+		return err
+	})
+}
+
+func (s *PredictKubeScaler) queryRange(ctx context.Context, r v1.Range) (val model.Value, warns v1.Warnings, err error) {
+	err = s.withPrometheusFailover(func(api v1.API) error {
+		var queryErr error
+		val, warns, queryErr = api.QueryRange(ctx, s.metadata.query, r)
+		return queryErr
+	})
+	return val, warns, err
+}
+
+// withPrometheusFailover calls fn with the active Prometheus API and moves on to the next addresses
+// while fn fails because the Prometheus is unreachable. The first address that answers becomes the
+// active one, so the following queries don't wait for the failed one again
+func (s *PredictKubeScaler) withPrometheusFailover(fn func(api v1.API) error) (err error) {
+	s.apiLock.Lock()
+	active := s.activeAPI
+	s.apiLock.Unlock()
+
+	for i := range s.apis {
+		index := (active + i) % len(s.apis)
+		err = fn(s.apis[index])
+		if err != nil && isPrometheusConnectionError(err) && i < len(s.apis)-1 {
+			predictKubeLog.Info("Prometheus is unavailable, failing over to the next address",
+				"prometheusAddress", s.prometheusAddress(index), "nextPrometheusAddress", s.prometheusAddress((index+1)%len(s.apis)), "error", err.Error())
+			continue
+		}
+
+		if index != active && (err == nil || !isPrometheusConnectionError(err)) {
+			s.apiLock.Lock()
+			s.activeAPI = index
+			s.apiLock.Unlock()
+		}
+		return err
+	}
 	return err
 }
 
+func (s *PredictKubeScaler) prometheusAddress(index int) string {
+	if index < len(s.metadata.prometheusAddresses) {
+		return s.metadata.prometheusAddresses[index]
+	}
+	return ""
+}
+
+// isPrometheusConnectionError checks if the error means that the Prometheus is down or unreachable,
+// rather than that it rejected the query, which the other replicas would reject as well
+func isPrometheusConnectionError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *v1.Error
+	if errors.As(err, &apiErr) {
+		// server errors without a Prometheus error body come from a proxy in front of a failed replica
+		return apiErr.Type == v1.ErrServer || apiErr.Type == v1.ErrBadResponse
+	}
+	return true
+}
+
 // initPredictKubePrometheusConn init prometheus client and setup connection to API
 func (s *PredictKubeScaler) initPredictKubePrometheusConn(ctx context.Context) (err error) {
 	var roundTripper http.RoundTripper
-	// create http.RoundTripper with auth settings from ScalerConfig
-	if roundTripper, err = authentication.CreateHTTPRoundTripper(
-		authentication.FastHTTP,
-		s.metadata.prometheusAuth,
-	); err != nil {
+	if roundTripper, err = createPredictKubeRoundTripper(s.metadata); err != nil {
 		predictKubeLog.V(1).Error(err, "init Prometheus client http transport")
 		return err
 	}
 
-	if s.prometheusClient, err = api.NewClient(api.Config{
-		Address:      s.metadata.prometheusAddress,
-		RoundTripper: roundTripper,
-	}); err != nil {
-		predictKubeLog.V(1).Error(err, "init Prometheus client")
-		return err
-	}
+	s.prometheusTransport = roundTripper
+	s.apis = make([]v1.API, 0, len(s.metadata.prometheusAddresses))
+	for _, address := range s.metadata.prometheusAddresses {
+		var prometheusClient api.Client
+		if prometheusClient, err = api.NewClient(api.Config{
+			Address:      address,
+			RoundTripper: kedautil.NewUserAgentRoundTripper(s.userAgent, roundTripper),
+		}); err != nil {
+			predictKubeLog.V(1).Error(err, "init Prometheus client", "prometheusAddress", address)
+			return err
+		}
 
-	s.api = v1.NewAPI(s.prometheusClient)
+		s.apis = append(s.apis, v1.NewAPI(prometheusClient))
+	}
 
 	return s.ping(ctx)
 }
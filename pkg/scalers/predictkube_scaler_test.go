@@ -2,20 +2,32 @@ package scalers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/phayes/freeport"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	health "google.golang.org/grpc/health/grpc_health_v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 
 	libsSrv "github.com/dysnix/predictkube-libs/external/grpc/server"
 	pb "github.com/dysnix/predictkube-proto/external/proto/services"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/internal/testutil"
 )
 
 type server struct {
@@ -133,6 +145,219 @@ var testPredictKubeMetadata = []predictKubeMetadataTestData{
 		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "one", "query": ""},
 		map[string]string{"apiKey": testAPIKey}, true,
 	},
+	// malformed disablePredictionCache
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "disablePredictionCache": "maybe"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// scalingFactor
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "scalingFactor": "1.2"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// malformed scalingFactor
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "scalingFactor": "a"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// zero scalingFactor
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "scalingFactor": "0"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// minValue and maxValue
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "minValue": "10", "maxValue": "100"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// negative maxValue
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "maxValue": "-1"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// malformed minValue
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "minValue": "a"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// minValue greater than maxValue
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "minValue": "100", "maxValue": "10"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// azure workload identity
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "authModes": "azureWorkloadIdentity"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// azure workload identity together with bearer authentication
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "authModes": "azureWorkloadIdentity,bearer"},
+		map[string]string{"apiKey": testAPIKey, "bearerToken": "token"}, true,
+	},
+	// basic authentication
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "authModes": "basic"},
+		map[string]string{"apiKey": testAPIKey, "username": "user", "password": "pass"}, false,
+	},
+	// basic authentication without username
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "authModes": "basic"},
+		map[string]string{"apiKey": testAPIKey, "password": "pass"}, true,
+	},
+	// username and password without authModes are ignored
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up"},
+		map[string]string{"apiKey": testAPIKey, "username": "user", "password": "pass"}, false,
+	},
+	// ignoreNullValues
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "ignoreNullValues": "false"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// malformed ignoreNullValues
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "ignoreNullValues": "maybe"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// net/http transport
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "httpTransport": "nethttp"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// horizonAggregation
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "horizonAggregation": "last"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// horizonAggregation over the forecast series
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "horizonAggregation": "max"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "horizonAggregation": "avg"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// invalid horizonAggregation
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "horizonAggregation": "p99"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// confidenceBound
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "confidenceBound": "mean"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// confidenceBound of the confidence interval
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "confidenceBound": "upper"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "confidenceBound": "lower"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// invalid confidenceBound
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "confidenceBound": "p90"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// aggregateSeries
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "aggregateSeries": "sum"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// invalid aggregateSeries
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "aggregateSeries": "min"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// zero queryStep
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "0s", "threshold": "2000", "query": "up"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// negative historyTimeWindow
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "-7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// predictHorizon shorter than queryStep
+	{
+		map[string]string{"predictHorizon": "1m", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// too many steps in historyTimeWindow
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "30d", "prometheusAddress": "http://localhost:9090", "queryStep": "15s", "threshold": "2000", "query": "up"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// maxHistorySteps raised
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "30d", "prometheusAddress": "http://localhost:9090", "queryStep": "15s", "threshold": "2000", "query": "up", "maxHistorySteps": "200000"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// zero maxHistorySteps
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "maxHistorySteps": "0"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// invalid transport
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "httpTransport": "http3"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// prometheusAddress failover list
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://prometheus-0:9090, http://prometheus-1:9090", "queryStep": "2m", "threshold": "2000", "query": "up"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// invalid address in the prometheusAddress failover list
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://prometheus-0:9090,", "queryStep": "2m", "threshold": "2000", "query": "up"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// custom ML engine host with its own server name
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "grpcAddress": "predictkube.internal:8443", "grpcServerName": "ml-engine.example.com", "grpcUnsafeSsl": "true"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// grpcServerName with the public ML engine
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "grpcServerName": "ml-engine.example.com"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// grpcUnsafeSsl with the public ML engine
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "grpcAddress": "api.predictkube.com:443", "grpcUnsafeSsl": "true"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// invalid grpcUnsafeSsl
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "grpcAddress": "predictkube.internal:8443", "grpcUnsafeSsl": "maybe"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// grpcAddress without port
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "grpcAddress": "predictkube.internal"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// history window queried in chunks
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "30d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up", "maxQueryRange": "7d"},
+		map[string]string{"apiKey": testAPIKey}, false,
+	},
+	// maxQueryRange shorter than queryStep
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up", "maxQueryRange": "1m"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
+	// invalid maxQueryRange
+	{
+		map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up", "maxQueryRange": "0s"},
+		map[string]string{"apiKey": testAPIKey}, true,
+	},
 }
 
 func TestPredictKubeParseMetadata(t *testing.T) {
@@ -220,3 +445,939 @@ func TestPredictKubeGetMetrics(t *testing.T) {
 		t.Logf("get: %v, want: %v, predictMetric: %d", result[0].Value, *resource.NewQuantity(mockPredictServer.val, resource.DecimalSI), mockPredictServer.val)
 	}
 }
+
+type fakePrometheusAPI struct {
+	v1.API
+	value model.Value
+	warns v1.Warnings
+	err   error
+
+	// queryRange overrides the static response when set
+	queryRange func(r v1.Range) (model.Value, v1.Warnings, error)
+	lock       sync.Mutex
+	ranges     []v1.Range
+}
+
+func (f *fakePrometheusAPI) QueryRange(_ context.Context, _ string, r v1.Range) (model.Value, v1.Warnings, error) {
+	f.lock.Lock()
+	f.ranges = append(f.ranges, r)
+	f.lock.Unlock()
+	if f.queryRange != nil {
+		return f.queryRange(r)
+	}
+	return f.value, f.warns, f.err
+}
+
+func TestPredictKubeQueryWarnings(t *testing.T) {
+	meta, err := parsePredictKubeMetadata(&ScalerConfig{TriggerMetadata: testPredictKubeMetadata[0].metadata, AuthParams: testPredictKubeMetadata[0].authParams})
+	assert.NoError(t, err)
+
+	vector := model.Vector{&model.Sample{Value: 10, Timestamp: model.Now()}}
+
+	// harmless warnings are only logged, even when they mention partial or dropped data
+	s := &PredictKubeScaler{metadata: meta, apis: []v1.API{&fakePrometheusAPI{value: vector, warns: v1.Warnings{
+		"query was slow",
+		"PromQL info: ignored partial buckets of histograms",
+		"PromQL warning: dropped the name of the series",
+	}}}}
+	results, _, err := s.doQuery(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	// the warnings of a partial response of Thanos must not produce a forecast silently
+	for _, warn := range []string{
+		"receive series from Addr: 10.0.0.1:10901 LabelSets: {replica=\"a\"} Mint: 0 Maxt: 1: rpc error: code = Unavailable desc = connection refused",
+		"fetch series for {replica=\"a\"} Addr: 10.0.0.1:10901: context deadline exceeded",
+		"No StoreAPIs matched for this query",
+	} {
+		s.apis = []v1.API{&fakePrometheusAPI{value: vector, warns: v1.Warnings{"query was slow", warn}}}
+		_, _, err = s.doQuery(context.Background())
+		if assert.Error(t, err, warn) {
+			assert.Contains(t, err.Error(), warn)
+			assert.NotContains(t, err.Error(), "query was slow")
+		}
+	}
+
+	// warnings are attached to query errors
+	s.apis = []v1.API{&fakePrometheusAPI{warns: v1.Warnings{"too many samples"}, err: errors.New("query failed")}}
+	_, _, err = s.doQuery(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "query failed")
+	assert.Contains(t, err.Error(), "too many samples")
+}
+
+type fakeMlEngineClient struct {
+	result int64
+	err    error
+	calls  int
+}
+
+func (f *fakeMlEngineClient) GetPredictMetric(_ context.Context, _ *pb.ReqGetPredictMetric, _ ...grpc.CallOption) (*pb.ResGetPredictMetric, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &pb.ResGetPredictMetric{ResultMetric: f.result}, nil
+}
+
+func newFakePredictKubeScaler(t *testing.T, metadata map[string]string, value model.Value, mlEngine *fakeMlEngineClient) *PredictKubeScaler {
+	meta, err := parsePredictKubeMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: map[string]string{"apiKey": testAPIKey}})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	return &PredictKubeScaler{metadata: meta, apis: []v1.API{&fakePrometheusAPI{value: value}}, grpcClient: mlEngine}
+}
+
+func TestPredictKubePredictionCache(t *testing.T) {
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up"}
+	now := model.Now()
+	vector := model.Vector{&model.Sample{Value: 10, Timestamp: now}}
+
+	mlEngine := &fakeMlEngineClient{result: 100}
+	s := newFakePredictKubeScaler(t, metadata, vector, mlEngine)
+
+	for i := 0; i < 3; i++ {
+		value, err := s.doPredictRequest(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(100), value)
+	}
+	assert.Equal(t, 1, mlEngine.calls, "predictions within the same step should be cached")
+
+	// an observation newer than the cached step window invalidates the cache
+	s.apis = []v1.API{&fakePrometheusAPI{value: model.Vector{&model.Sample{Value: 10, Timestamp: now.Add(6 * time.Minute)}}}}
+	_, err := s.doPredictRequest(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, mlEngine.calls)
+
+	// the cache can be disabled
+	metadata["disablePredictionCache"] = "true"
+	mlEngine = &fakeMlEngineClient{result: 100}
+	s = newFakePredictKubeScaler(t, metadata, vector, mlEngine)
+	for i := 0; i < 3; i++ {
+		_, err := s.doPredictRequest(context.Background())
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, mlEngine.calls)
+}
+
+func TestPredictKubeAdjustsStepOnMaxResolutionError(t *testing.T) {
+	// a Prometheus with a lower resolution limit than allowed by maxHistorySteps
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "30d", "prometheusAddress": "http://localhost:9090", "queryStep": "15s", "threshold": "2000", "query": "up", "maxHistorySteps": "200000"}
+	vector := model.Vector{&model.Sample{Value: 10, Timestamp: model.Now()}}
+
+	api := &fakePrometheusAPI{
+		queryRange: func(r v1.Range) (model.Value, v1.Warnings, error) {
+			if r.End.Sub(r.Start)/r.Step > predictKubeMaxResolution {
+				return nil, nil, &v1.Error{Type: v1.ErrBadData, Msg: "exceeded maximum resolution of 11,000 points per timeseries. Try decreasing the query resolution (?step=XX)"}
+			}
+			return vector, nil, nil
+		},
+	}
+	s := newFakePredictKubeScaler(t, metadata, vector, &fakeMlEngineClient{})
+	s.apis = []v1.API{api}
+
+	results, _, err := s.doQuery(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Len(t, api.ranges, 2)
+	assert.Greater(t, s.queryStep(), 15*time.Second)
+	assert.Equal(t, 15*time.Second, s.metadata.stepDuration, "the parsed metadata must not be changed")
+
+	// the adjusted step is kept, so following polls don't hit the limit again
+	_, _, err = s.doQuery(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, api.ranges, 3)
+}
+
+func TestPredictKubeAdjustsStepConcurrently(t *testing.T) {
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "30d", "prometheusAddress": "http://localhost:9090", "queryStep": "15s", "threshold": "2000", "query": "up", "maxHistorySteps": "200000"}
+	vector := model.Vector{&model.Sample{Value: 10, Timestamp: model.Now()}}
+
+	api := &fakePrometheusAPI{
+		queryRange: func(r v1.Range) (model.Value, v1.Warnings, error) {
+			if r.End.Sub(r.Start)/r.Step > predictKubeMaxResolution {
+				return nil, nil, &v1.Error{Type: v1.ErrBadData, Msg: "exceeded maximum resolution of 11,000 points per timeseries. Try decreasing the query resolution (?step=XX)"}
+			}
+			return vector, nil, nil
+		},
+	}
+	mlEngine := &fakeMlEngineClient{result: 100}
+	s := newFakePredictKubeScaler(t, metadata, vector, mlEngine)
+	s.apis = []v1.API{api}
+	s.metadata.disablePredictionCache = true
+	s.grpcClient = &lockedMlEngineClient{client: mlEngine}
+
+	// the adapter polls the scaler concurrently, run with -race
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.doPredictRequest(context.Background())
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Greater(t, s.queryStep(), 15*time.Second)
+	assert.Equal(t, 15*time.Second, s.metadata.stepDuration)
+}
+
+// lockedMlEngineClient serializes the calls of the fake ML engine, which counts them
+type lockedMlEngineClient struct {
+	lock   sync.Mutex
+	client *fakeMlEngineClient
+}
+
+func (c *lockedMlEngineClient) GetPredictMetric(ctx context.Context, req *pb.ReqGetPredictMetric, opts ...grpc.CallOption) (*pb.ResGetPredictMetric, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.client.GetPredictMetric(ctx, req, opts...)
+}
+
+func TestPredictKubeScalingFactor(t *testing.T) {
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up", "scalingFactor": "1.2"}
+	vector := model.Vector{&model.Sample{Value: 10, Timestamp: model.Now()}}
+
+	// the prediction is higher than the last observation
+	s := newFakePredictKubeScaler(t, metadata, vector, &fakeMlEngineClient{result: 100})
+	metrics, err := s.GetMetrics(context.Background(), "predictkube", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(120000), metrics[0].Value.MilliValue())
+
+	// the last observation is higher than the prediction, it is scaled as well
+	s = newFakePredictKubeScaler(t, metadata, vector, &fakeMlEngineClient{result: 5})
+	metrics, err = s.GetMetrics(context.Background(), "predictkube", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12000), metrics[0].Value.MilliValue())
+}
+
+func TestPredictKubeClampsPrediction(t *testing.T) {
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up", "minValue": "20", "maxValue": "500"}
+	vector := model.Vector{&model.Sample{Value: 10, Timestamp: model.Now()}}
+
+	tests := []struct {
+		prediction int64
+		expected   int64
+	}{
+		{100000, 500},
+		{15, 20},
+		{100, 100},
+	}
+
+	for _, test := range tests {
+		s := newFakePredictKubeScaler(t, metadata, vector, &fakeMlEngineClient{result: test.prediction})
+		metrics, err := s.GetMetrics(context.Background(), "predictkube", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, metrics[0].Value.Value())
+	}
+}
+
+func TestPredictKubeClampsScaledPrediction(t *testing.T) {
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up", "scalingFactor": "1.5", "minValue": "20", "maxValue": "500"}
+	vector := model.Vector{&model.Sample{Value: 10, Timestamp: model.Now()}}
+
+	tests := []struct {
+		prediction int64
+		expected   int64
+	}{
+		// 400 * 1.5 is above maxValue, the bound applies to the value sent to the HPA
+		{400, 500000},
+		// 12 * 1.5 is below minValue
+		{12, 20000},
+		{100, 150000},
+	}
+
+	for _, test := range tests {
+		s := newFakePredictKubeScaler(t, metadata, vector, &fakeMlEngineClient{result: test.prediction})
+		metrics, err := s.GetMetrics(context.Background(), "predictkube", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, metrics[0].Value.MilliValue())
+	}
+}
+
+func TestPredictKubeEmptyResult(t *testing.T) {
+	results := []model.Value{model.Vector{}, model.Matrix{}}
+
+	for _, result := range results {
+		metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up"}
+
+		// by default an empty result is reported as 0
+		mlEngine := &fakeMlEngineClient{result: 100}
+		s := newFakePredictKubeScaler(t, metadata, result, mlEngine)
+		metrics, err := s.GetMetrics(context.Background(), "predictkube", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), metrics[0].Value.Value())
+		assert.Equal(t, 0, mlEngine.calls, "the ML engine must not be asked without observations")
+
+		metadata["ignoreNullValues"] = "false"
+		s = newFakePredictKubeScaler(t, metadata, result, mlEngine)
+		_, err = s.GetMetrics(context.Background(), "predictkube", nil)
+		assert.ErrorIs(t, err, errPredictKubeNoObservations)
+
+		// without observations, minValue is still the floor and scalingFactor still applies
+		metadata["ignoreNullValues"] = "true"
+		metadata["minValue"] = "5"
+		metadata["scalingFactor"] = "2"
+		s = newFakePredictKubeScaler(t, metadata, result, mlEngine)
+		metrics, err = s.GetMetrics(context.Background(), "predictkube", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), metrics[0].Value.Value())
+		assert.Equal(t, 0, mlEngine.calls, "the ML engine must not be asked without observations")
+	}
+
+	// a forecast of 0 is reported by default, and raised to minValue when it's set
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up"}
+	observations := model.Vector{&model.Sample{Value: 0, Timestamp: model.Now()}}
+	s := newFakePredictKubeScaler(t, metadata, observations, &fakeMlEngineClient{result: 0})
+	metrics, err := s.GetMetrics(context.Background(), "predictkube", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), metrics[0].Value.Value())
+
+	metadata["minValue"] = "5"
+	s = newFakePredictKubeScaler(t, metadata, observations, &fakeMlEngineClient{result: 0})
+	metrics, err = s.GetMetrics(context.Background(), "predictkube", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), metrics[0].Value.Value())
+
+	metadata["ignoreNullValues"] = "false"
+	s = newFakePredictKubeScaler(t, metadata, observations, &fakeMlEngineClient{result: 0})
+	metrics, err = s.GetMetrics(context.Background(), "predictkube", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), metrics[0].Value.Value())
+
+	// without ignoreNullValues, a 0 left after the bounds is an error telling it apart from no observations
+	delete(metadata, "minValue")
+	s = newFakePredictKubeScaler(t, metadata, observations, &fakeMlEngineClient{result: 0})
+	_, err = s.GetMetrics(context.Background(), "predictkube", nil)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errPredictKubeNoObservations)
+	assert.Contains(t, err.Error(), "ML engine returned 0")
+}
+
+type fakeHealthClient struct {
+	err error
+}
+
+func (f *fakeHealthClient) Check(_ context.Context, _ *health.HealthCheckRequest, _ ...grpc.CallOption) (*health.HealthCheckResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &health.HealthCheckResponse{Status: health.HealthCheckResponse_SERVING}, nil
+}
+
+func (f *fakeHealthClient) Watch(_ context.Context, _ *health.HealthCheckRequest, _ ...grpc.CallOption) (health.Health_WatchClient, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestPredictKubeIsActive(t *testing.T) {
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up"}
+	traffic := model.Vector{&model.Sample{Value: 10, Timestamp: model.Now()}}
+	noTraffic := model.Vector{&model.Sample{Value: 0, Timestamp: model.Now()}}
+
+	tests := []struct {
+		name           string
+		value          model.Value
+		healthErr      error
+		expected       bool
+		healthFailures int64
+	}{
+		{"healthy with traffic", traffic, nil, true, 0},
+		{"healthy without traffic", noTraffic, nil, false, 0},
+		{"unhealthy with traffic", traffic, errors.New("connection refused"), true, 1},
+		{"unhealthy without traffic", noTraffic, errors.New("connection refused"), false, 1},
+	}
+
+	for _, test := range tests {
+		s := newFakePredictKubeScaler(t, metadata, test.value, &fakeMlEngineClient{})
+		s.healthClient = &fakeHealthClient{err: test.healthErr}
+
+		active, err := s.IsActive(context.Background())
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, active, test.name)
+		assert.Equal(t, test.healthFailures, s.healthCheckFailures, test.name)
+	}
+
+	// prometheus failures are still reported
+	s := newFakePredictKubeScaler(t, metadata, traffic, &fakeMlEngineClient{})
+	s.healthClient = &fakeHealthClient{}
+	s.apis = []v1.API{&fakePrometheusAPI{err: errors.New("query failed")}}
+	_, err := s.IsActive(context.Background())
+	assert.Error(t, err)
+}
+
+func TestPredictKubeParseBasicAuthErrors(t *testing.T) {
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up", "authModes": "basic"}
+
+	_, err := parsePredictKubeMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: map[string]string{"apiKey": testAPIKey}})
+	assert.EqualError(t, err, "no username given, it's required by authModes basic")
+
+	// the credentials of a TriggerAuthentication shared with the prometheus scaler are ignored without basic
+	delete(metadata, "authModes")
+	meta, err := parsePredictKubeMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: map[string]string{"apiKey": testAPIKey, "username": "user", "password": "pass"}})
+	assert.NoError(t, err)
+	assert.False(t, meta.prometheusAuth != nil && meta.prometheusAuth.EnableBasicAuth)
+
+	metadata["authModes"] = "digest"
+	_, err = parsePredictKubeMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: map[string]string{"apiKey": testAPIKey}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "authModes")
+}
+
+func TestPredictKubeBasicAuthRoundTripper(t *testing.T) {
+	for _, transport := range []string{"", "fasthttp", "nethttp"} {
+		var authorized, requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			authorized++
+
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api/v1/status/runtimeinfo":
+				_, _ = w.Write([]byte(`{"status": "success", "data": {}}`))
+			case "/api/v1/query_range":
+				_, _ = fmt.Fprintf(w, `{"status": "success", "data": {"resultType": "matrix", "result": [{"metric": {}, "values": [[%d, "10"]]}]}}`, time.Now().Unix())
+			default:
+				t.Errorf("unexpected path %s", r.URL.Path)
+			}
+		}))
+
+		meta, err := parsePredictKubeMetadata(&ScalerConfig{
+			TriggerMetadata: map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": server.URL, "queryStep": "5m", "threshold": "2000", "query": "up", "authModes": "basic", "httpTransport": transport},
+			AuthParams:      map[string]string{"apiKey": testAPIKey, "username": "user", "password": "pass"},
+		})
+		assert.NoError(t, err)
+
+		s := &PredictKubeScaler{metadata: meta}
+		assert.NoError(t, s.initPredictKubePrometheusConn(context.Background()))
+
+		results, _, err := s.doQuery(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, 2, requests)
+		assert.Equal(t, requests, authorized, "every query must carry the basic auth credentials with transport %q", transport)
+
+		server.Close()
+	}
+}
+
+func TestPredictKubeHTTPTransport(t *testing.T) {
+	tests := []struct {
+		name         string
+		metadata     map[string]string
+		authParams   map[string]string
+		roundTripper string
+	}{
+		{"default", map[string]string{}, map[string]string{}, "*http_transport.transport"},
+		{"fasthttp", map[string]string{"httpTransport": "fasthttp"}, map[string]string{}, "*http_transport.transport"},
+		{"nethttp", map[string]string{"httpTransport": "nethttp"}, map[string]string{}, "*http.Transport"},
+		{"nethttp with basic auth", map[string]string{"httpTransport": "nethttp", "authModes": "basic"}, map[string]string{"username": "user", "password": "pass"}, "*config.basicAuthRoundTripper"},
+		{"nethttp with bearer auth", map[string]string{"httpTransport": "nethttp", "authModes": "bearer"}, map[string]string{"bearerToken": "token"}, "*config.authorizationCredentialsRoundTripper"},
+	}
+
+	for _, test := range tests {
+		metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up"}
+		for k, v := range test.metadata {
+			metadata[k] = v
+		}
+		authParams := map[string]string{"apiKey": testAPIKey}
+		for k, v := range test.authParams {
+			authParams[k] = v
+		}
+
+		meta, err := parsePredictKubeMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: authParams})
+		assert.NoError(t, err, test.name)
+
+		roundTripper, err := createPredictKubeRoundTripper(meta)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.roundTripper, fmt.Sprintf("%T", roundTripper), test.name)
+	}
+}
+
+func TestPredictKubeCloseClosesPrometheusConnections(t *testing.T) {
+	for _, transport := range []string{"fasthttp", "nethttp"} {
+		closed := make(chan struct{}, 1)
+		prometheus := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status": "success", "data": {}}`))
+		}))
+		prometheus.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateClosed {
+				closed <- struct{}{}
+			}
+		}
+		prometheus.Start()
+
+		metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": prometheus.URL, "queryStep": "2m", "threshold": "2000", "query": "up", "httpTransport": transport}
+		s := newFakePredictKubeScaler(t, metadata, nil, &fakeMlEngineClient{})
+		assert.NoError(t, s.initPredictKubePrometheusConn(context.Background()), transport)
+		grpcConn, err := grpc.Dial("localhost:0", grpc.WithTransportCredentials(insecure.NewCredentials()))
+		assert.NoError(t, err, transport)
+		s.grpcConn = grpcConn
+
+		// the keep-alive connection to Prometheus is closed with the scaler
+		assert.NoError(t, s.Close(context.Background()), transport)
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: the idle Prometheus connection of the scaler wasn't closed", transport)
+		}
+		prometheus.Close()
+	}
+}
+
+// fakeForecastSeriesResponse is a response of the ML engine carrying the forecast series
+type fakeForecastSeriesResponse struct {
+	result int64
+	series []int64
+}
+
+func (r *fakeForecastSeriesResponse) GetResultMetric() int64 {
+	return r.result
+}
+
+func (r *fakeForecastSeriesResponse) GetForecastSeries() []int64 {
+	return r.series
+}
+
+func TestPredictKubeHorizonAggregation(t *testing.T) {
+	resp := &fakeForecastSeriesResponse{result: 12, series: []int64{10, 40, 25, 12}}
+
+	tests := []struct {
+		aggregation string
+		expected    int64
+	}{
+		{"", 12},
+		{"last", 12},
+		{"max", 40},
+		{"avg", 22},
+	}
+
+	for _, test := range tests {
+		metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up", "horizonAggregation": test.aggregation}
+		s := newFakePredictKubeScaler(t, metadata, model.Vector{}, &fakeMlEngineClient{})
+		assert.Equal(t, test.expected, s.forecastValue(resp), "horizonAggregation %q", test.aggregation)
+
+		// without the series, the forecast at the end of the horizon is used
+		assert.Equal(t, int64(12), s.forecastValue(&fakeForecastSeriesResponse{result: 12}), "horizonAggregation %q", test.aggregation)
+	}
+}
+
+func TestPredictKubeHorizonAggregationScalarFallback(t *testing.T) {
+	vector := model.Vector{&model.Sample{Value: 10, Timestamp: model.Now()}}
+	for _, aggregation := range []string{"", "last", "max", "avg"} {
+		metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up", "horizonAggregation": aggregation}
+
+		// the current ML engine only returns the forecast at the end of the horizon
+		s := newFakePredictKubeScaler(t, metadata, vector, &fakeMlEngineClient{result: 100})
+		metrics, err := s.GetMetrics(context.Background(), "predictkube", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(100), metrics[0].Value.Value(), "horizonAggregation %q", aggregation)
+	}
+}
+
+// fakeConfidenceIntervalResponse is a response of the ML engine carrying the forecast series and its confidence interval
+type fakeConfidenceIntervalResponse struct {
+	fakeForecastSeriesResponse
+	upper []int64
+	lower []int64
+}
+
+func (r *fakeConfidenceIntervalResponse) GetUpperBoundSeries() []int64 {
+	return r.upper
+}
+
+func (r *fakeConfidenceIntervalResponse) GetLowerBoundSeries() []int64 {
+	return r.lower
+}
+
+func TestPredictKubeConfidenceBound(t *testing.T) {
+	resp := &fakeConfidenceIntervalResponse{
+		fakeForecastSeriesResponse: fakeForecastSeriesResponse{result: 12, series: []int64{10, 40, 25, 12}},
+		upper:                      []int64{14, 52, 31, 18},
+		lower:                      []int64{6, 28, 19, 8},
+	}
+
+	tests := []struct {
+		bound       string
+		aggregation string
+		expected    int64
+	}{
+		{"", "", 12},
+		{"mean", "last", 12},
+		{"mean", "max", 40},
+		{"upper", "last", 18},
+		{"upper", "max", 52},
+		{"upper", "avg", 29},
+		{"lower", "last", 8},
+		{"lower", "max", 28},
+	}
+
+	for _, test := range tests {
+		metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up", "confidenceBound": test.bound, "horizonAggregation": test.aggregation}
+		s := newFakePredictKubeScaler(t, metadata, model.Vector{}, &fakeMlEngineClient{})
+		assert.Equal(t, test.expected, s.forecastValue(resp), "confidenceBound %q horizonAggregation %q", test.bound, test.aggregation)
+
+		// without the confidence interval, the point forecast is used
+		assert.Equal(t, aggregateForecastSeries(resp.series, s.metadata.horizonAggregation), s.forecastValue(&resp.fakeForecastSeriesResponse),
+			"confidenceBound %q horizonAggregation %q", test.bound, test.aggregation)
+	}
+}
+
+func TestPredictKubeConfidenceBoundScalarFallback(t *testing.T) {
+	vector := model.Vector{&model.Sample{Value: 10, Timestamp: model.Now()}}
+	for _, bound := range []string{"", "mean", "upper", "lower"} {
+		metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "5m", "threshold": "2000", "query": "up", "confidenceBound": bound}
+
+		// the current ML engine only returns the point forecast
+		s := newFakePredictKubeScaler(t, metadata, vector, &fakeMlEngineClient{result: 100})
+		metrics, err := s.GetMetrics(context.Background(), "predictkube", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(100), metrics[0].Value.Value(), "confidenceBound %q", bound)
+	}
+}
+
+func TestPredictKubeMultipleSeries(t *testing.T) {
+	now := model.Now()
+	matrix := model.Matrix{
+		&model.SampleStream{
+			Metric: model.Metric{"pod": "a"},
+			Values: []model.SamplePair{{Timestamp: now, Value: 1}, {Timestamp: now.Add(time.Minute), Value: 2}},
+		},
+		&model.SampleStream{
+			Metric: model.Metric{"pod": "b"},
+			Values: []model.SamplePair{{Timestamp: now.Add(time.Minute), Value: 6}, {Timestamp: now.Add(2 * time.Minute), Value: 4}},
+		},
+	}
+
+	tests := []struct {
+		aggregateSeries string
+		values          []float64
+		isError         bool
+	}{
+		{"", nil, true},
+		{"sum", []float64{1, 8, 4}, false},
+		{"avg", []float64{1, 4, 4}, false},
+		{"max", []float64{1, 6, 4}, false},
+	}
+
+	for _, test := range tests {
+		metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "1m", "threshold": "2000", "query": "up", "aggregateSeries": test.aggregateSeries}
+		s := newFakePredictKubeScaler(t, metadata, matrix, &fakeMlEngineClient{result: 100})
+
+		items, err := s.parsePrometheusResult(matrix)
+		if test.isError {
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "query returned 2 series")
+			continue
+		}
+		assert.NoError(t, err, "aggregateSeries %q", test.aggregateSeries)
+
+		var values []float64
+		for i, item := range items {
+			values = append(values, item.Value)
+			assert.Equal(t, now.Add(time.Duration(i)*time.Minute).Unix(), item.Timestamp.AsTime().Unix())
+		}
+		assert.Equal(t, test.values, values, "aggregateSeries %q", test.aggregateSeries)
+	}
+
+	// a single series is passed through regardless of aggregateSeries
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "1m", "threshold": "2000", "query": "up"}
+	s := newFakePredictKubeScaler(t, metadata, matrix[:1], &fakeMlEngineClient{result: 100})
+	items, err := s.parsePrometheusResult(matrix[:1])
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	vector := model.Vector{&model.Sample{Metric: model.Metric{"pod": "a"}, Value: 1, Timestamp: now}, &model.Sample{Metric: model.Metric{"pod": "b"}, Value: 2, Timestamp: now}}
+	_, err = s.parsePrometheusResult(vector)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "query returned 2 series")
+}
+
+func TestPredictKubeHistoryStepsError(t *testing.T) {
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "30d", "prometheusAddress": "http://localhost:9090", "queryStep": "15s", "threshold": "2000", "query": "up"}
+	_, err := parsePredictKubeMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: map[string]string{"apiKey": testAPIKey}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "queries 172800 steps")
+	// 30d in at most 11000 steps
+	assert.Contains(t, err.Error(), "increase queryStep to at least 3m56s")
+}
+
+func TestPredictKubePrometheusFailover(t *testing.T) {
+	var primaryRequests, secondaryRequests int
+	primaryDown := true
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryRequests++
+		if primaryDown {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/status/runtimeinfo":
+			_, _ = w.Write([]byte(`{"status": "success", "data": {}}`))
+		case "/api/v1/query_range":
+			_, _ = w.Write([]byte(`{"status": "error", "errorType": "bad_data", "error": "parse error"}`))
+		}
+	}))
+	defer primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryRequests++
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/status/runtimeinfo":
+			_, _ = w.Write([]byte(`{"status": "success", "data": {}}`))
+		case "/api/v1/query_range":
+			_, _ = fmt.Fprintf(w, `{"status": "success", "data": {"resultType": "matrix", "result": [{"metric": {}, "values": [[%d, "10"]]}]}}`, time.Now().Unix())
+		}
+	}))
+	defer secondary.Close()
+
+	meta, err := parsePredictKubeMetadata(&ScalerConfig{
+		TriggerMetadata: map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": primary.URL + "," + secondary.URL, "queryStep": "5m", "threshold": "2000", "query": "up"},
+		AuthParams:      map[string]string{"apiKey": testAPIKey},
+	})
+	assert.NoError(t, err)
+
+	// the connection check fails over to the secondary
+	s := &PredictKubeScaler{metadata: meta}
+	assert.NoError(t, s.initPredictKubePrometheusConn(context.Background()))
+	assert.Equal(t, 1, primaryRequests)
+	assert.Equal(t, 1, secondaryRequests)
+
+	// the secondary stays active while it is healthy, even once the primary is back
+	primaryDown = false
+	for i := 0; i < 2; i++ {
+		results, _, err := s.doQuery(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+	}
+	assert.Equal(t, 1, primaryRequests)
+	assert.Equal(t, 3, secondaryRequests)
+
+	// a query rejected by Prometheus isn't retried on the other address
+	secondary.Close()
+	s.activeAPI = 0
+	_, _, err = s.doQuery(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "parse error")
+	assert.Equal(t, 2, primaryRequests)
+	assert.Equal(t, 3, secondaryRequests)
+
+	// all the addresses are down
+	primaryDown = true
+	_, _, err = s.doQuery(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 3, primaryRequests)
+}
+
+func TestPredictKubeChunkedQuery(t *testing.T) {
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "30d", "prometheusAddress": "http://localhost:9090", "queryStep": "1h", "threshold": "2000", "query": "up", "maxQueryRange": "7d"}
+
+	// a point per step of the queried range, including both ends
+	api := &fakePrometheusAPI{
+		queryRange: func(r v1.Range) (model.Value, v1.Warnings, error) {
+			var values []model.SamplePair
+			for ts := r.Start; !ts.After(r.End); ts = ts.Add(r.Step) {
+				values = append(values, model.SamplePair{Timestamp: model.TimeFromUnixNano(ts.UnixNano()), Value: 1})
+			}
+			return model.Matrix{&model.SampleStream{Values: values}}, nil, nil
+		},
+	}
+	s := newFakePredictKubeScaler(t, metadata, nil, &fakeMlEngineClient{})
+	s.apis = []v1.API{api}
+
+	results, _, err := s.doQuery(context.Background())
+	assert.NoError(t, err)
+
+	assert.Len(t, api.ranges, 5)
+	for i, r := range api.ranges {
+		assert.LessOrEqual(t, int64(r.End.Sub(r.Start)), int64(7*24*time.Hour))
+		if i > 0 {
+			assert.Equal(t, api.ranges[i-1].End, r.Start, "the chunks must cover the whole history window")
+		}
+	}
+
+	// the points at the chunk boundaries aren't duplicated
+	assert.Len(t, results, 30*24+1)
+	for i := 1; i < len(results); i++ {
+		assert.Equal(t, time.Hour, results[i].Timestamp.AsTime().Sub(results[i-1].Timestamp.AsTime()))
+	}
+
+	// a failed chunk fails the query
+	calls := 0
+	api.queryRange = func(r v1.Range) (model.Value, v1.Warnings, error) {
+		calls++
+		if calls == 3 {
+			return nil, nil, &v1.Error{Type: v1.ErrBadData, Msg: "query time range exceeds the limit"}
+		}
+		return model.Matrix{}, nil, nil
+	}
+	api.ranges = nil
+	_, _, err = s.doQuery(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("from %s to %s", api.ranges[2].Start.Format(time.RFC3339), api.ranges[2].End.Format(time.RFC3339)))
+	assert.Contains(t, err.Error(), "query time range exceeds the limit")
+	assert.Len(t, api.ranges, 3)
+}
+
+func TestPredictKubeChunkedQueryAdjustsStep(t *testing.T) {
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "30d", "prometheusAddress": "http://localhost:9090", "queryStep": "15s", "threshold": "2000", "query": "up",
+		"maxQueryRange": "7d", "maxHistorySteps": "200000"}
+
+	// a Prometheus rejecting the resolution of the third chunk only, e.g. a querier with a lower limit for older data
+	var rejectedStart time.Time
+	api := &fakePrometheusAPI{
+		queryRange: func(r v1.Range) (model.Value, v1.Warnings, error) {
+			if r.Step == 15*time.Second && !rejectedStart.IsZero() && !r.Start.Before(rejectedStart) {
+				return nil, nil, &v1.Error{Type: v1.ErrBadData, Msg: "exceeded maximum resolution of 11,000 points per timeseries. Try decreasing the query resolution (?step=XX)"}
+			}
+			var values []model.SamplePair
+			for ts := r.Start; !ts.After(r.End); ts = ts.Add(r.Step) {
+				values = append(values, model.SamplePair{Timestamp: model.TimeFromUnixNano(ts.UnixNano()), Value: 1})
+			}
+			return model.Matrix{&model.SampleStream{Values: values}}, nil, nil
+		},
+	}
+	s := newFakePredictKubeScaler(t, metadata, nil, &fakeMlEngineClient{})
+	s.apis = []v1.API{api}
+	rejectedStart = time.Now().Add(-16 * 24 * time.Hour)
+
+	results, step, err := s.doQuery(context.Background())
+	assert.NoError(t, err)
+	assert.Greater(t, step, 15*time.Second)
+	assert.Equal(t, step, s.queryStep())
+
+	// the window is queried again from its first chunk with the adjusted step
+	assert.Len(t, api.ranges, 3+5)
+	retried := api.ranges[3:]
+	assert.Equal(t, api.ranges[0].Start, retried[0].Start)
+	for i, r := range retried {
+		assert.Equal(t, step, r.Step)
+		if i > 0 {
+			assert.Equal(t, retried[i-1].End, r.Start, "the chunks must cover the whole history window")
+		}
+	}
+
+	// all the observations have the same resolution
+	for i := 1; i < len(results); i++ {
+		assert.Equal(t, step, results[i].Timestamp.AsTime().Sub(results[i-1].Timestamp.AsTime()))
+	}
+}
+
+func TestPredictKubeTLSConfig(t *testing.T) {
+	tests := []struct {
+		name               string
+		metadata           map[string]string
+		host               string
+		serverName         string
+		insecureSkipVerify bool
+	}{
+		{"public host", map[string]string{"grpcAddress": "api.predictkube.com:443"}, defaultMLEngineHost, defaultMLEngineHost, false},
+		{"custom host", map[string]string{"grpcAddress": "predictkube.internal:8443"}, "predictkube.internal", "predictkube.internal", false},
+		{"custom host with server name", map[string]string{"grpcAddress": "predictkube.internal:8443", "grpcServerName": "ml-engine.example.com"}, "predictkube.internal", "ml-engine.example.com", false},
+		{"custom host without verification", map[string]string{"grpcAddress": "10.0.0.1:8443", "grpcUnsafeSsl": "true"}, "10.0.0.1", "10.0.0.1", true},
+	}
+
+	for _, test := range tests {
+		metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "prometheusAddress": "http://localhost:9090", "queryStep": "2m", "threshold": "2000", "query": "up"}
+		for k, v := range test.metadata {
+			metadata[k] = v
+		}
+		s := newFakePredictKubeScaler(t, metadata, nil, &fakeMlEngineClient{})
+
+		host, _ := s.mlEngineAddress()
+		assert.Equal(t, test.host, host, test.name)
+
+		config := predictKubeTLSConfig(s.metadata, host)
+		assert.Equal(t, test.serverName, config.ServerName, test.name)
+		assert.Equal(t, test.insecureSkipVerify, config.InsecureSkipVerify, test.name)
+	}
+
+	// the overrides never apply to the public ML engine
+	meta := &predictKubeMetadata{grpcServerName: "ml-engine.example.com", grpcUnsafeSsl: true}
+	config := predictKubeTLSConfig(meta, defaultMLEngineHost)
+	assert.Equal(t, defaultMLEngineHost, config.ServerName)
+	assert.False(t, config.InsecureSkipVerify)
+}
+
+func TestPredictKubeGRPCBuffers(t *testing.T) {
+	// the buffers of every connection are left to the gRPC defaults, 0, rather than 50MiB each
+	assert.Zero(t, grpcConf.Conn.ReadBufferSize)
+	assert.Zero(t, grpcConf.Conn.WriteBufferSize)
+	// the predictions of long series are still received
+	assert.Equal(t, uint(50<<20), grpcConf.Conn.MaxMessageSize)
+}
+
+func TestPredictKubeParseMetadataPrometheusAddressSources(t *testing.T) {
+	metadata := map[string]string{"predictHorizon": "2h", "historyTimeWindow": "7d", "queryStep": "2m", "threshold": "2000", "query": "up"}
+	testParseFromEnvSources(t, "prometheusAddress", metadata, map[string]string{"apiKey": testAPIKey}, [3]string{"http://prometheus-inline:9090", "http://prometheus-env:9090", "http://prometheus-auth:9090"}, func(config *ScalerConfig) (string, error) {
+		meta, err := parsePredictKubeMetadata(config)
+		if err != nil {
+			return "", err
+		}
+		return meta.prometheusAddresses[0], nil
+	})
+}
+
+func newPredictKubeSoakLifecycle(t *testing.T, faults testutil.Faults) (*testutil.FaultyGRPCServer, testutil.Lifecycle) {
+	prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/status/runtimeinfo":
+			_, _ = w.Write([]byte(`{"status": "success", "data": {}}`))
+		case "/api/v1/query_range":
+			_, _ = fmt.Fprintf(w, `{"status": "success", "data": {"resultType": "matrix", "result": [{"metric": {}, "values": [[%d, "10"]]}]}}`, time.Now().Unix())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(prometheus.Close)
+
+	// the faults are injected by the ML engine, the Prometheus faults are covered by the Prometheus scaler
+	mlEngine := testutil.NewFaultyGRPCServer(t, func(s *grpc.Server) {
+		pb.RegisterMlEngineServiceServer(s, &server{})
+		health.RegisterHealthServer(s, grpchealth.NewServer())
+	}, faults, grpc.Creds(credentials.NewTLS(testutil.SelfSignedTLSConfig(t))))
+
+	return mlEngine, testutil.Lifecycle{
+		Build: func() (testutil.LifecycleScaler, error) {
+			return NewPredictKubeScaler(context.Background(), &ScalerConfig{
+				TriggerMetadata: map[string]string{"predictHorizon": "10m", "historyTimeWindow": "1h", "prometheusAddress": prometheus.URL, "queryStep": "1m", "threshold": "2000", "query": "up", "grpcAddress": mlEngine.Addr, "grpcUnsafeSsl": "true"},
+				AuthParams:      map[string]string{"apiKey": testAPIKey},
+			})
+		},
+		Poll: func(ctx context.Context, scaler testutil.LifecycleScaler) error {
+			if _, err := scaler.IsActive(ctx); err != nil {
+				return err
+			}
+			_, err := scaler.(Scaler).GetMetrics(ctx, "s0-predictkube-metric", nil)
+			return err
+		},
+		// the cleaners of the fasthttp connections only exit after sleeping for their max idle duration
+		SettleTimeout: 15 * time.Second,
+	}
+}
+
+func TestPredictKubeScalerSoak(t *testing.T) {
+	mlEngine, lifecycle := newPredictKubeSoakLifecycle(t, testutil.Faults{ResetEvery: 13, MalformedEvery: 11, ErrorEvery: 7})
+
+	stats := testutil.StressLifecycle(t, lifecycle)
+	assert.NotZero(t, stats.PollErrors)
+	assert.Less(t, stats.PollErrors, stats.Polls)
+	assert.NotZero(t, mlEngine.Injected(testutil.Reset))
+}
+
+func TestPredictKubeScalerSlowBackend(t *testing.T) {
+	_, lifecycle := newPredictKubeSoakLifecycle(t, testutil.Faults{Latency: time.Hour})
+	lifecycle.Iterations = 3
+	lifecycle.PollTimeout = 100 * time.Millisecond
+
+	stats := testutil.StressLifecycle(t, lifecycle)
+	assert.Equal(t, 3, stats.PollErrors)
+}
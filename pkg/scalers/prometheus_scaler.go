@@ -76,7 +76,7 @@ func NewPrometheusScaler(config *ScalerConfig) (Scaler, error) {
 
 	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false)
 
-	if meta.prometheusAuth != nil && (meta.prometheusAuth.CA != "" || meta.prometheusAuth.EnableTLS) {
+	if meta.prometheusAuth != nil && (meta.prometheusAuth.CA != "" || meta.prometheusAuth.EnableTLS || meta.prometheusAuth.HTTPVersion != "") {
 		// create http.RoundTripper with auth settings from ScalerConfig
 		if httpClient.Transport, err = authentication.CreateHTTPRoundTripper(
 			authentication.NetHTTP,
@@ -90,7 +90,7 @@ func NewPrometheusScaler(config *ScalerConfig) (Scaler, error) {
 	return &prometheusScaler{
 		metricType: metricType,
 		metadata:   meta,
-		httpClient: httpClient,
+		httpClient: WithUserAgent(httpClient, config),
 	}, nil
 }
 
@@ -156,6 +156,10 @@ func (s *prometheusScaler) IsActive(ctx context.Context) (bool, error) {
 }
 
 func (s *prometheusScaler) Close(context.Context) error {
+	// every scaler has its own transport, its keep-alive connections would outlive it
+	if s.httpClient != nil {
+		s.httpClient.CloseIdleConnections()
+	}
 	return nil
 }
 
@@ -202,12 +206,13 @@ func (s *prometheusScaler) ExecutePromQuery(ctx context.Context) (float64, error
 	if err != nil {
 		return -1, err
 	}
+	// closed on the read errors too, the connection would leak
+	defer r.Body.Close()
 
 	b, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		return -1, err
 	}
-	_ = r.Body.Close()
 
 	if !(r.StatusCode >= 200 && r.StatusCode <= 299) {
 		return -1, fmt.Errorf("prometheus query api returned error. status: %d response: %s", r.StatusCode, string(b))
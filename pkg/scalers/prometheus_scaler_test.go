@@ -2,12 +2,19 @@ package scalers
 
 import (
 	"context"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/internal/testutil"
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
 )
 
 type parsePrometheusMetadataTestData struct {
@@ -239,3 +246,120 @@ func TestPrometheusScalerCortexHeader(t *testing.T) {
 
 	assert.NoError(t, err)
 }
+
+func TestPrometheusScalerUserAgent(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		userAgent = request.Header.Get("User-Agent")
+		_, _ = writer.Write([]byte(`{"data":{"result":[]}}`))
+	}))
+	defer server.Close()
+
+	kedautil.SetClusterName("prod-eu")
+	defer kedautil.SetClusterName("")
+
+	scaler, err := NewPrometheusScaler(&ScalerConfig{
+		TriggerMetadata: map[string]string{"serverAddress": server.URL, "metricName": "http_requests_total", "threshold": "100", "query": "up"},
+		TriggerType:     "prometheus",
+	})
+	assert.NoError(t, err)
+
+	_, err = scaler.(*prometheusScaler).ExecutePromQuery(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, kedautil.UserAgent("prometheus"), userAgent)
+	assert.Contains(t, userAgent, "scaler/prometheus cluster/prod-eu")
+}
+
+// closeRecordingBody is a response body recording whether it was closed
+type closeRecordingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeRecordingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestPrometheusScalerClosesBodyOnReadError(t *testing.T) {
+	body := &closeRecordingBody{Reader: iotest.ErrReader(io.ErrUnexpectedEOF)}
+	scaler := prometheusScaler{
+		metadata: &prometheusMetadata{serverAddress: "http://prometheus:9090", query: "up"},
+		httpClient: &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: body}, nil
+		})},
+	}
+
+	_, err := scaler.ExecutePromQuery(context.Background())
+	assert.Error(t, err)
+	assert.True(t, body.closed, "the body failing to read must be closed")
+}
+
+func TestPrometheusScalerCloseClosesIdleConnections(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"10"]}]}}`))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	scaler, err := NewPrometheusScaler(&ScalerConfig{
+		TriggerMetadata: map[string]string{"serverAddress": server.URL, "metricName": "http_requests_total", "threshold": "100", "query": "up"},
+	})
+	assert.NoError(t, err)
+	_, err = scaler.(*prometheusScaler).ExecutePromQuery(context.Background())
+	assert.NoError(t, err)
+
+	// the keep-alive connection is closed with the scaler rather than left to the idle timeout of the transport
+	assert.NoError(t, scaler.Close(context.Background()))
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("the idle connection of the scaler wasn't closed")
+	}
+}
+
+func newPrometheusSoakLifecycle(t *testing.T, faults testutil.Faults) (*testutil.FaultyHTTPServer, testutil.Lifecycle) {
+	server := testutil.NewFaultyHTTPServer(t, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"10"]}]}}`))
+	}), faults)
+
+	return server, testutil.Lifecycle{
+		Build: func() (testutil.LifecycleScaler, error) {
+			return NewPrometheusScaler(&ScalerConfig{
+				TriggerMetadata:   map[string]string{"serverAddress": server.URL, "metricName": "http_requests_total", "threshold": "100", "query": "up"},
+				GlobalHTTPTimeout: time.Second,
+			})
+		},
+		Poll: func(ctx context.Context, scaler testutil.LifecycleScaler) error {
+			if _, err := scaler.IsActive(ctx); err != nil {
+				return err
+			}
+			_, err := scaler.(Scaler).GetMetrics(ctx, "s0-prometheus-http_requests_total", nil)
+			return err
+		},
+	}
+}
+
+func TestPrometheusScalerSoak(t *testing.T) {
+	server, lifecycle := newPrometheusSoakLifecycle(t, testutil.Faults{ResetEvery: 13, MalformedEvery: 11, ErrorEvery: 7})
+
+	stats := testutil.StressLifecycle(t, lifecycle)
+	assert.NotZero(t, stats.PollErrors)
+	assert.Less(t, stats.PollErrors, stats.Polls)
+	assert.NotZero(t, server.Injected(testutil.Reset))
+}
+
+func TestPrometheusScalerSlowBackend(t *testing.T) {
+	_, lifecycle := newPrometheusSoakLifecycle(t, testutil.Faults{Latency: time.Hour})
+	lifecycle.Iterations = 3
+	lifecycle.PollTimeout = 100 * time.Millisecond
+
+	stats := testutil.StressLifecycle(t, lifecycle)
+	assert.Equal(t, 3, stats.PollErrors)
+}
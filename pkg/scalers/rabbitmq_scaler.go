@@ -113,7 +113,7 @@ func NewRabbitMQScaler(config *ScalerConfig) (Scaler, error) {
 		return nil, fmt.Errorf("error parsing rabbitmq metadata: %s", err)
 	}
 	s.metadata = meta
-	s.httpClient = kedautil.CreateHTTPClient(meta.timeout, false)
+	s.httpClient = WithUserAgent(kedautil.CreateHTTPClient(meta.timeout, false), config)
 
 	if meta.protocol == amqpProtocol {
 		// Override vhost if requested.
@@ -154,16 +154,14 @@ func parseRabbitMQMetadata(config *ScalerConfig) (*rabbitMQMetadata, error) {
 	}
 
 	// Resolve host value
-	switch {
-	case config.AuthParams["host"] != "":
-		meta.host = config.AuthParams["host"]
-	case config.TriggerMetadata["host"] != "":
-		meta.host = config.TriggerMetadata["host"]
-	case config.TriggerMetadata["hostFromEnv"] != "":
-		meta.host = config.ResolvedEnv[config.TriggerMetadata["hostFromEnv"]]
-	default:
+	host, err := getFromAuthOrEnvOrMeta(config, "host")
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
 		return nil, fmt.Errorf("no host setting given")
 	}
+	meta.host = host
 
 	// If the protocol is auto, check the host scheme.
 	if meta.protocol == autoProtocol {
@@ -226,7 +224,7 @@ func parseRabbitMQMetadata(config *ScalerConfig) (*rabbitMQMetadata, error) {
 		return nil, fmt.Errorf("configure only useRegex with http protocol")
 	}
 
-	_, err := parseTrigger(&meta, config)
+	_, err = parseTrigger(&meta, config)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse trigger: %s", err)
 	}
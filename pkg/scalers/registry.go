@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Builder creates the scaler of a trigger from its config
+type Builder func(ctx context.Context, config *ScalerConfig) (Scaler, error)
+
+// builtinScalers are the trigger types built in pkg/scaling, they can't be registered again.
+// It must be kept in sync with the scalers of buildScaler, one name per line in alphabetical order.
+var builtinScalers = map[string]bool{}
+
+func init() {
+	for _, name := range []string{
+		"activemq",
+		"artemis-queue",
+		"aws-alb",
+		"aws-cloudwatch",
+		"aws-dynamodb",
+		"aws-kinesis-stream",
+		"aws-sqs-queue",
+		"azure-app-insights",
+		"azure-blob",
+		"azure-cosmosdb",
+		"azure-data-explorer",
+		"azure-eventhub",
+		"azure-log-analytics",
+		"azure-monitor",
+		"azure-pipelines",
+		"azure-queue",
+		"azure-servicebus",
+		"b2",
+		"bullmq",
+		"bullmq-cluster",
+		"bullmq-sentinel",
+		"cassandra",
+		"cpu",
+		"cron",
+		"custom-metrics-api",
+		"dagster",
+		"datadog",
+		"elasticsearch",
+		"external",
+		"external-push",
+		"gcp-bigquery",
+		"gcp-cloudlogging",
+		"gcp-cloudsql",
+		"gcp-cloudtasks",
+		"gcp-dataflow",
+		"gcp-firestore",
+		"gcp-pubsub",
+		"gcp-spanner",
+		"gcp-stackdriver",
+		"gcp-storage",
+		"graphite",
+		"hazelcast",
+		"huawei-cloudeye",
+		"ibmmq",
+		"influxdb",
+		"ingress-status",
+		"kafka",
+		"kafka-connect",
+		"kubernetes-workload",
+		"liiklus",
+		"memory",
+		"metrics-api",
+		"mongodb",
+		"msgraph-mail",
+		"mssql",
+		"mysql",
+		"new-relic",
+		"nsq",
+		"opensearch",
+		"openstack-metric",
+		"openstack-swift",
+		"pagerduty",
+		"postgresql",
+		"predictkube",
+		"prometheus",
+		"rabbitmq",
+		"redis",
+		"redis-cluster",
+		"redis-cluster-streams",
+		"redis-sentinel",
+		"redis-sentinel-streams",
+		"redis-streams",
+		"scylla-rest",
+		"selenium-grid",
+		"sentry",
+		"solace-event-queue",
+		"stan",
+		"webhdfs",
+	} {
+		builtinScalers[name] = true
+	}
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]Builder{}
+)
+
+// Register adds the builder of an out-of-tree scaler for the trigger type name, it is meant to be called from
+// the init function of the package of the scaler, which is then compiled in with a blank import.
+// The name of a built-in scaler or of a scaler already registered is rejected.
+func Register(name string, builder Builder) error {
+	if name == "" {
+		return fmt.Errorf("the name of a scaler can't be empty")
+	}
+	if builder == nil {
+		return fmt.Errorf("the builder of scaler %s can't be nil", name)
+	}
+	if builtinScalers[name] {
+		return fmt.Errorf("scaler %s is a built-in scaler", name)
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[name]; ok {
+		return fmt.Errorf("scaler %s is already registered", name)
+	}
+	registry[name] = builder
+	return nil
+}
+
+// MustRegister is like Register but panics when the scaler can't be registered
+func MustRegister(name string, builder Builder) {
+	if err := Register(name, builder); err != nil {
+		panic(err)
+	}
+}
+
+// GetRegisteredBuilder returns the builder registered for the trigger type name
+func GetRegisteredBuilder(name string) (Builder, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	builder, ok := registry[name]
+	return builder, ok
+}
+
+// IsBuiltinScaler tells whether name is the trigger type of a built-in scaler
+func IsBuiltinScaler(name string) bool {
+	return builtinScalers[name]
+}
@@ -0,0 +1,99 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func testRegistryBuilder(context.Context, *ScalerConfig) (Scaler, error) {
+	return nil, nil
+}
+
+func unregister(name string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	delete(registry, name)
+}
+
+func TestRegister(t *testing.T) {
+	defer unregister("registry-test")
+
+	for _, testData := range []struct {
+		name    string
+		builder Builder
+		isError bool
+	}{
+		{"registry-test", testRegistryBuilder, false},
+		// already registered
+		{"registry-test", testRegistryBuilder, true},
+		// built-in scalers
+		{"kafka", testRegistryBuilder, true},
+		{"redis-cluster-streams", testRegistryBuilder, true},
+		{"", testRegistryBuilder, true},
+		{"registry-test-nil", nil, true},
+	} {
+		err := Register(testData.name, testData.builder)
+		if err != nil && !testData.isError {
+			t.Errorf("Expected success registering %q but got error: %s", testData.name, err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("Expected error registering %q but got success", testData.name)
+		}
+	}
+
+	if _, ok := GetRegisteredBuilder("registry-test"); !ok {
+		t.Error("Expected registry-test to be registered")
+	}
+	for _, name := range []string{"kafka", "registry-test-nil"} {
+		if _, ok := GetRegisteredBuilder(name); ok {
+			t.Errorf("Expected %s not to be registered", name)
+		}
+	}
+}
+
+func TestMustRegister(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic registering a built-in scaler")
+		}
+	}()
+	MustRegister("cpu", testRegistryBuilder)
+}
+
+func TestRegisterConcurrently(t *testing.T) {
+	const count = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*count)
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("registry-test-%d", i)
+		defer unregister(name)
+		// every name is registered twice at the same time, only one of them must succeed
+		for j := 0; j < 2; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- Register(name, testRegistryBuilder)
+				GetRegisteredBuilder(name)
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	failed := 0
+	for err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed != count {
+		t.Errorf("Expected %d registrations to fail, got %d", count, failed)
+	}
+	for i := 0; i < count; i++ {
+		if _, ok := GetRegisteredBuilder(fmt.Sprintf("registry-test-%d", i)); !ok {
+			t.Errorf("Expected registry-test-%d to be registered", i)
+		}
+	}
+}
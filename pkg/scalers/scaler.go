@@ -19,6 +19,7 @@ package scalers
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -28,6 +29,7 @@ import (
 	"k8s.io/metrics/pkg/apis/external_metrics"
 
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
 	metrics "github.com/rcrowley/go-metrics"
 )
 
@@ -89,6 +91,9 @@ type ScalerConfig struct {
 
 	// MetricType
 	MetricType v2beta2.MetricTargetType
+
+	// TriggerType is the type of the trigger the scaler is created for, it's sent in the User-Agent of the scaler
+	TriggerType string
 }
 
 // GetFromAuthOrMeta helps getting a field from Auth or Meta sections
@@ -106,6 +111,38 @@ func GetFromAuthOrMeta(config *ScalerConfig, field string) (string, error) {
 	return result, err
 }
 
+// getFromAuthOrEnvOrMeta gets a field that may hold a secret from AuthParams first, then from the env var named by
+// its FromEnv variant, then inline from the metadata. It returns "" when the field isn't set at all, and an error
+// when the FromEnv variant names an env var that isn't set
+func getFromAuthOrEnvOrMeta(config *ScalerConfig, field string) (string, error) {
+	if config.AuthParams[field] != "" {
+		return config.AuthParams[field], nil
+	}
+	if envName := config.TriggerMetadata[field+"FromEnv"]; envName != "" {
+		if config.ResolvedEnv[envName] == "" {
+			return "", fmt.Errorf("%sFromEnv references the env var %s which isn't set", field, envName)
+		}
+		return config.ResolvedEnv[envName], nil
+	}
+	return config.TriggerMetadata[field], nil
+}
+
+// getFromAuthOrEnv gets a field holding a secret, e.g. a connection string, from AuthParams first, then from the env var
+// named by its FromEnv variant. Unlike getFromAuthOrEnvOrMeta it isn't read inline from the metadata, which is
+// readable by anyone who can read the ScaledObject
+func getFromAuthOrEnv(config *ScalerConfig, field string) (string, error) {
+	if config.AuthParams[field] != "" {
+		return config.AuthParams[field], nil
+	}
+	if envName := config.TriggerMetadata[field+"FromEnv"]; envName != "" {
+		if config.ResolvedEnv[envName] == "" {
+			return "", fmt.Errorf("%sFromEnv references the env var %s which isn't set", field, envName)
+		}
+		return config.ResolvedEnv[envName], nil
+	}
+	return "", nil
+}
+
 // GenerateMetricNameWithIndex helps adding the index prefix to the metric name
 func GenerateMetricNameWithIndex(scalerIndex int, metricName string) string {
 	return fmt.Sprintf("s%d-%s", scalerIndex, metricName)
@@ -155,3 +192,22 @@ func GetMetricTarget(metricType v2beta2.MetricTargetType, metricValue int64) v2b
 
 	return target
 }
+
+// UnwrapScaler returns the scaler of a trigger without the wrappers of the scale handler, e.g. the one applying its
+// valueExpression, so the scaler is named after its type in metrics and logs
+func UnwrapScaler(scaler Scaler) Scaler {
+	for {
+		wrapper, ok := scaler.(interface{ Unwrap() Scaler })
+		if !ok {
+			return scaler
+		}
+		scaler = wrapper.Unwrap()
+	}
+}
+
+// WithUserAgent wraps the transport of the client so its requests identify KEDA, the scaler type and the cluster
+// to the backend. It has to be called once the transport of the client is set up
+func WithUserAgent(httpClient *http.Client, config *ScalerConfig) *http.Client {
+	httpClient.Transport = kedautil.NewUserAgentRoundTripper(kedautil.UserAgent(config.TriggerType), httpClient.Transport)
+	return httpClient
+}
@@ -122,3 +122,117 @@ func TestRemoveIndexFromMetricName(t *testing.T) {
 		}
 	}
 }
+
+func TestGetFromAuthOrEnvOrMeta(t *testing.T) {
+	testParseFromEnvSources(t, "host", nil, nil, [3]string{"inline", "env", "auth"}, func(config *ScalerConfig) (string, error) {
+		return getFromAuthOrEnvOrMeta(config, "host")
+	})
+
+	value, err := getFromAuthOrEnvOrMeta(&ScalerConfig{TriggerMetadata: map[string]string{}}, "host")
+	assert.NoError(t, err)
+	assert.Equal(t, "", value)
+}
+
+func TestGetFromAuthOrEnv(t *testing.T) {
+	testParseSecretFromEnvSources(t, "connection", nil, nil, [3]string{"inline", "env", "auth"}, func(config *ScalerConfig) (string, error) {
+		value, err := getFromAuthOrEnv(config, "connection")
+		if err == nil && value == "" {
+			err = fmt.Errorf("no connection given")
+		}
+		return value, err
+	})
+}
+
+// testParseFromEnvSources checks a field that may hold a secret is parsed inline, from the env var named by its FromEnv
+// variant in preference to inline and from AuthParams in preference to both, and that a FromEnv variant naming
+// an env var that isn't set is an error. values are the inline, env and AuthParams values of the field, valid for
+// parse, which parses the metadata around the field and returns the parsed field
+func testParseFromEnvSources(t *testing.T, field string, metadata, authParams map[string]string, values [3]string, parse func(config *ScalerConfig) (string, error)) {
+	t.Helper()
+
+	for _, testCase := range []struct {
+		name     string
+		config   *ScalerConfig
+		expected string
+	}{
+		{"inline", newFromEnvSourcesConfig(field, metadata, authParams, values, true, false, false), values[0]},
+		{"from env over inline", newFromEnvSourcesConfig(field, metadata, authParams, values, true, true, false), values[1]},
+		{"from auth params over env and inline", newFromEnvSourcesConfig(field, metadata, authParams, values, true, true, true), values[2]},
+	} {
+		value, err := parse(testCase.config)
+		if err != nil {
+			t.Errorf("%s %s: unexpected error: %s", field, testCase.name, err)
+			continue
+		}
+		if value != testCase.expected {
+			t.Errorf("%s %s: expected %q, got %q", field, testCase.name, testCase.expected, value)
+		}
+	}
+
+	missingEnv := newFromEnvSourcesConfig(field, metadata, authParams, values, true, true, false)
+	missingEnv.ResolvedEnv = map[string]string{}
+	if _, err := parse(missingEnv); err == nil {
+		t.Errorf("%s: expected an error with %sFromEnv naming a missing env var", field, field)
+	}
+}
+
+// testParseSecretFromEnvSources checks a field that must not be given inline is parsed from the env var named by its
+// FromEnv variant and from AuthParams in preference to it, and that the field given inline only or with a FromEnv
+// variant naming an env var that isn't set is an error. values and parse are the same as for testParseFromEnvSources
+func testParseSecretFromEnvSources(t *testing.T, field string, metadata, authParams map[string]string, values [3]string, parse func(config *ScalerConfig) (string, error)) {
+	t.Helper()
+
+	for _, testCase := range []struct {
+		name     string
+		config   *ScalerConfig
+		expected string
+	}{
+		{"from env", newFromEnvSourcesConfig(field, metadata, authParams, values, false, true, false), values[1]},
+		{"from auth params over env", newFromEnvSourcesConfig(field, metadata, authParams, values, false, true, true), values[2]},
+	} {
+		value, err := parse(testCase.config)
+		if err != nil {
+			t.Errorf("%s %s: unexpected error: %s", field, testCase.name, err)
+			continue
+		}
+		if value != testCase.expected {
+			t.Errorf("%s %s: expected %q, got %q", field, testCase.name, testCase.expected, value)
+		}
+	}
+
+	if _, err := parse(newFromEnvSourcesConfig(field, metadata, authParams, values, true, false, false)); err == nil {
+		t.Errorf("%s: expected an error with the field given inline", field)
+	}
+
+	missingEnv := newFromEnvSourcesConfig(field, metadata, authParams, values, false, true, false)
+	missingEnv.ResolvedEnv = map[string]string{}
+	if _, err := parse(missingEnv); err == nil {
+		t.Errorf("%s: expected an error with %sFromEnv naming a missing env var", field, field)
+	}
+}
+
+// newFromEnvSourcesConfig returns the config of the metadata and auth params with the field given inline, from
+// the env and in the auth params as requested, values are the values of the field for each of them
+func newFromEnvSourcesConfig(field string, metadata, authParams map[string]string, values [3]string, inline, fromEnv, auth bool) *ScalerConfig {
+	config := &ScalerConfig{
+		TriggerMetadata: map[string]string{},
+		AuthParams:      map[string]string{},
+		ResolvedEnv:     map[string]string{"FIELD_VALUE": values[1]},
+	}
+	for key, value := range metadata {
+		config.TriggerMetadata[key] = value
+	}
+	for key, value := range authParams {
+		config.AuthParams[key] = value
+	}
+	if inline {
+		config.TriggerMetadata[field] = values[0]
+	}
+	if fromEnv {
+		config.TriggerMetadata[field+"FromEnv"] = "FIELD_VALUE"
+	}
+	if auth {
+		config.AuthParams[field] = values[2]
+	}
+	return config
+}
@@ -0,0 +1,277 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kedacore/keda/v2/pkg/scalers/authentication"
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	scyllaRESTMetricPendingCompactions = "pending_compactions"
+	scyllaRESTMetricHintsInProgress    = "hints_in_progress"
+
+	scyllaRESTAggregationSum = "sum"
+	scyllaRESTAggregationMax = "max"
+
+	defaultScyllaRESTTargetValue = 10
+)
+
+// scyllaRESTMetricPaths are the paths of the Scylla REST API returning the value of the metrics
+var scyllaRESTMetricPaths = map[string]string{
+	scyllaRESTMetricPendingCompactions: "/compaction_manager/metrics/pending_tasks",
+	scyllaRESTMetricHintsInProgress:    "/storage_proxy/hints_in_progress",
+}
+
+type scyllaRESTScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *scyllaRESTMetadata
+	httpClient *http.Client
+}
+
+type scyllaRESTMetadata struct {
+	apiEndpoints          []string
+	metric                string
+	aggregation           string
+	targetValue           int64
+	activationTargetValue int64
+	unsafeSsl             bool
+	scyllaAuth            *authentication.AuthMeta
+	scalerIndex           int
+}
+
+var scyllaRESTLog = logf.Log.WithName("scylla_rest_scaler")
+
+// NewScyllaRESTScaler creates a new scyllaRESTScaler
+func NewScyllaRESTScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseScyllaRESTMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing scylla rest metadata: %s", err)
+	}
+
+	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, meta.unsafeSsl)
+
+	if meta.scyllaAuth != nil && (meta.scyllaAuth.CA != "" || meta.scyllaAuth.EnableTLS || meta.scyllaAuth.HTTPVersion != "") {
+		// create http.RoundTripper with auth settings from ScalerConfig, keeping unsafeSsl of the default transport
+		meta.scyllaAuth.UnsafeSsl = meta.unsafeSsl
+		if httpClient.Transport, err = authentication.CreateHTTPRoundTripper(
+			authentication.NetHTTP,
+			meta.scyllaAuth,
+		); err != nil {
+			scyllaRESTLog.V(1).Error(err, "init Scylla REST client http transport")
+			return nil, err
+		}
+	}
+
+	return &scyllaRESTScaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(httpClient, config),
+	}, nil
+}
+
+func parseScyllaRESTMetadata(config *ScalerConfig) (*scyllaRESTMetadata, error) {
+	meta := scyllaRESTMetadata{}
+	meta.aggregation = scyllaRESTAggregationSum
+	meta.targetValue = defaultScyllaRESTTargetValue
+
+	if val, ok := config.TriggerMetadata["apiEndpoints"]; ok && val != "" {
+		for _, endpoint := range splitAndTrimBySep(val, ",") {
+			endpoint = strings.TrimSuffix(endpoint, "/")
+			if endpoint == "" {
+				continue
+			}
+			if _, err := url.ParseRequestURI(endpoint); err != nil {
+				return nil, fmt.Errorf("error parsing apiEndpoints %s: %s", endpoint, err)
+			}
+			meta.apiEndpoints = append(meta.apiEndpoints, endpoint)
+		}
+	}
+	if len(meta.apiEndpoints) == 0 {
+		return nil, fmt.Errorf("no apiEndpoints given")
+	}
+
+	if val, ok := config.TriggerMetadata["metric"]; ok && val != "" {
+		if _, ok := scyllaRESTMetricPaths[val]; !ok {
+			return nil, fmt.Errorf("metric must be %s or %s, got %s", scyllaRESTMetricPendingCompactions, scyllaRESTMetricHintsInProgress, val)
+		}
+		meta.metric = val
+	} else {
+		return nil, fmt.Errorf("no metric given")
+	}
+
+	if val, ok := config.TriggerMetadata["aggregation"]; ok && val != "" {
+		switch val {
+		case scyllaRESTAggregationSum, scyllaRESTAggregationMax:
+			meta.aggregation = val
+		default:
+			return nil, fmt.Errorf("aggregation must be %s or %s, got %s", scyllaRESTAggregationSum, scyllaRESTAggregationMax, val)
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["targetValue"]; ok {
+		targetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetValue: %s", err)
+		}
+		if targetValue <= 0 {
+			return nil, fmt.Errorf("targetValue must be greater than 0")
+		}
+		meta.targetValue = targetValue
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetValue"]; ok {
+		activationTargetValue, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetValue: %s", err)
+		}
+		if activationTargetValue < 0 {
+			return nil, fmt.Errorf("activationTargetValue must not be negative")
+		}
+		meta.activationTargetValue = activationTargetValue
+	}
+
+	if val, ok := config.TriggerMetadata["unsafeSsl"]; ok {
+		unsafeSsl, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing unsafeSsl: %s", err)
+		}
+		meta.unsafeSsl = unsafeSsl
+	}
+
+	auth, err := authentication.GetAuthConfigs(config.TriggerMetadata, config.AuthParams)
+	if err != nil {
+		return nil, err
+	}
+	meta.scyllaAuth = auth
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// IsActive checks if the aggregated value is above the activation target
+func (s *scyllaRESTScaler) IsActive(ctx context.Context) (bool, error) {
+	value, err := s.getValue(ctx)
+	if err != nil {
+		scyllaRESTLog.Error(err, "error getting the scylla metric")
+		return false, err
+	}
+
+	return value > s.metadata.activationTargetValue, nil
+}
+
+func (s *scyllaRESTScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *scyllaRESTScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("scylla-rest-%s", s.metadata.metric))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetValue),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the value of the metric aggregated over the nodes
+func (s *scyllaRESTScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	value, err := s.getValue(ctx)
+	if err != nil {
+		scyllaRESTLog.Error(err, "error getting the scylla metric")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(value, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getValue aggregates the value of the metric over the nodes that answer, the nodes that don't
+// are skipped with a warning as long as one of them answers
+func (s *scyllaRESTScaler) getValue(ctx context.Context) (int64, error) {
+	var value int64
+	var errs []string
+	for _, endpoint := range s.metadata.apiEndpoints {
+		nodeValue, err := s.getNodeValue(ctx, endpoint)
+		if err != nil {
+			scyllaRESTLog.Info("scylla node failed, skipping it", "endpoint", endpoint, "error", err.Error())
+			errs = append(errs, fmt.Sprintf("%s: %s", endpoint, err))
+			continue
+		}
+
+		if s.metadata.aggregation == scyllaRESTAggregationMax {
+			if nodeValue > value {
+				value = nodeValue
+			}
+		} else {
+			value += nodeValue
+		}
+	}
+
+	if len(errs) == len(s.metadata.apiEndpoints) {
+		return -1, fmt.Errorf("no scylla node returned %s: %s", s.metadata.metric, strings.Join(errs, "; "))
+	}
+	return value, nil
+}
+
+func (s *scyllaRESTScaler) getNodeValue(ctx context.Context, endpoint string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+scyllaRESTMetricPaths[s.metadata.metric], nil)
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if s.metadata.scyllaAuth != nil && s.metadata.scyllaAuth.EnableBearerAuth {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.metadata.scyllaAuth.BearerToken))
+	} else if s.metadata.scyllaAuth != nil && s.metadata.scyllaAuth.EnableBasicAuth {
+		req.SetBasicAuth(s.metadata.scyllaAuth.Username, s.metadata.scyllaAuth.Password)
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return -1, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return -1, fmt.Errorf("scylla returned %d: %s", res.StatusCode, string(b))
+	}
+
+	// the API answers with a bare JSON number
+	value, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("error parsing %s: %s", s.metadata.metric, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,158 @@
+package scalers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type parseScyllaRESTMetadataTestData struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}
+
+type scyllaRESTMetricIdentifier struct {
+	metadataTestData *parseScyllaRESTMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testScyllaRESTMetadata = []parseScyllaRESTMetadataTestData{
+	// nothing passed
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"apiEndpoints": "http://scylla-0:10000, http://scylla-1:10000/", "metric": "pending_compactions", "aggregation": "max", "targetValue": "20", "activationTargetValue": "5"}, map[string]string{}, false},
+	// using defaults
+	{map[string]string{"apiEndpoints": "http://scylla-0:10000", "metric": "hints_in_progress"}, map[string]string{}, false},
+	// missing apiEndpoints
+	{map[string]string{"metric": "pending_compactions"}, map[string]string{}, true},
+	// invalid apiEndpoints
+	{map[string]string{"apiEndpoints": "scylla-0", "metric": "pending_compactions"}, map[string]string{}, true},
+	// missing metric
+	{map[string]string{"apiEndpoints": "http://scylla-0:10000"}, map[string]string{}, true},
+	// invalid metric
+	{map[string]string{"apiEndpoints": "http://scylla-0:10000", "metric": "live_sstables"}, map[string]string{}, true},
+	// invalid aggregation
+	{map[string]string{"apiEndpoints": "http://scylla-0:10000", "metric": "pending_compactions", "aggregation": "avg"}, map[string]string{}, true},
+	// malformed targetValue
+	{map[string]string{"apiEndpoints": "http://scylla-0:10000", "metric": "pending_compactions", "targetValue": "a"}, map[string]string{}, true},
+	// negative activationTargetValue
+	{map[string]string{"apiEndpoints": "http://scylla-0:10000", "metric": "pending_compactions", "activationTargetValue": "-1"}, map[string]string{}, true},
+	// malformed unsafeSsl
+	{map[string]string{"apiEndpoints": "https://scylla-0:10000", "metric": "pending_compactions", "unsafeSsl": "a"}, map[string]string{}, true},
+	// basic auth
+	{map[string]string{"apiEndpoints": "http://scylla-0:10000", "metric": "pending_compactions", "authModes": "basic"}, map[string]string{"username": "user", "password": "pass"}, false},
+	// basic auth without username
+	{map[string]string{"apiEndpoints": "http://scylla-0:10000", "metric": "pending_compactions", "authModes": "basic"}, map[string]string{}, true},
+	// tls auth without key
+	{map[string]string{"apiEndpoints": "https://scylla-0:10000", "metric": "pending_compactions", "authModes": "tls"}, map[string]string{"cert": "cert"}, true},
+}
+
+var scyllaRESTMetricIdentifiers = []scyllaRESTMetricIdentifier{
+	{&testScyllaRESTMetadata[1], 0, "s0-scylla-rest-pending_compactions"},
+	{&testScyllaRESTMetadata[2], 1, "s1-scylla-rest-hints_in_progress"},
+}
+
+func TestScyllaRESTParseMetadata(t *testing.T) {
+	for _, testData := range testScyllaRESTMetadata {
+		_, err := parseScyllaRESTMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+	}
+}
+
+func TestScyllaRESTGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range scyllaRESTMetricIdentifiers {
+		meta, err := parseScyllaRESTMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockScyllaRESTScaler := scyllaRESTScaler{metadata: meta}
+
+		metricSpec := mockScyllaRESTScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+// newScyllaRESTNode serves the metric paths of the Scylla REST API of a node with the values
+func newScyllaRESTNode(pendingCompactions, hintsInProgress string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); ok && (user != "user" || pass != "pass") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/compaction_manager/metrics/pending_tasks":
+			_, _ = w.Write([]byte(pendingCompactions))
+		case "/storage_proxy/hints_in_progress":
+			_, _ = w.Write([]byte(hintsInProgress))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestScyllaRESTGetValue(t *testing.T) {
+	node0 := newScyllaRESTNode("12", "3")
+	defer node0.Close()
+	node1 := newScyllaRESTNode("30\n", "0")
+	defer node1.Close()
+	failingNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failingNode.Close()
+
+	tests := []struct {
+		metadata map[string]string
+		value    int64
+		isActive bool
+		isError  bool
+	}{
+		// sum over the nodes
+		{map[string]string{"apiEndpoints": node0.URL + "," + node1.URL, "metric": "pending_compactions"}, 42, true, false},
+		// max over the nodes
+		{map[string]string{"apiEndpoints": node0.URL + "," + node1.URL, "metric": "pending_compactions", "aggregation": "max"}, 30, true, false},
+		// failing nodes are skipped
+		{map[string]string{"apiEndpoints": failingNode.URL + "," + node0.URL, "metric": "hints_in_progress", "activationTargetValue": "3"}, 3, false, false},
+		// basic auth
+		{map[string]string{"apiEndpoints": node0.URL, "metric": "hints_in_progress", "authModes": "basic"}, 3, true, false},
+		// every node failing
+		{map[string]string{"apiEndpoints": failingNode.URL, "metric": "pending_compactions"}, -1, false, true},
+	}
+
+	for _, test := range tests {
+		meta, err := parseScyllaRESTMetadata(&ScalerConfig{TriggerMetadata: test.metadata, AuthParams: map[string]string{"username": "user", "password": "pass"}})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		s := scyllaRESTScaler{metadata: meta, httpClient: http.DefaultClient}
+
+		value, err := s.getValue(context.Background())
+		if test.isError {
+			if err == nil {
+				t.Errorf("Expected an error with %v", test.metadata)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if value != test.value {
+			t.Errorf("Expected %d with %v, got %d", test.value, test.metadata, value)
+		}
+
+		active, err := s.IsActive(context.Background())
+		if err != nil || active != test.isActive {
+			t.Errorf("Expected active %t with %v, got %t, %v", test.isActive, test.metadata, active, err)
+		}
+	}
+}
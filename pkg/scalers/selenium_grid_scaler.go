@@ -83,7 +83,7 @@ func NewSeleniumGridScaler(config *ScalerConfig) (Scaler, error) {
 		return nil, fmt.Errorf("error parsing selenium grid metadata: %s", err)
 	}
 
-	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, meta.unsafeSsl)
+	httpClient := WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, meta.unsafeSsl), config)
 
 	return &seleniumGridScaler{
 		metricType: metricType,
@@ -0,0 +1,304 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	defaultSentryURL                     = "https://sentry.io"
+	defaultSentryCategory                = "error"
+	defaultSentryInterval                = "1h"
+	defaultSentryTargetEventsPerInterval = 100
+
+	// sentryStatsField is the aggregation of the stats_v2 API summing the number of events
+	sentryStatsField = "sum(quantity)"
+)
+
+// sentryIntervalPattern matches the stats periods accepted by the Sentry API
+var sentryIntervalPattern = regexp.MustCompile(`^[1-9][0-9]*[mhd]$`)
+
+type sentryScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *sentryMetadata
+	httpClient *http.Client
+
+	// the project id doesn't change, it is looked up once from the project slug
+	projectLock sync.Mutex
+	projectID   string
+}
+
+type sentryMetadata struct {
+	sentryURL                         string
+	organization                      string
+	project                           string
+	category                          string
+	interval                          string
+	targetEventsPerInterval           int64
+	activationTargetEventsPerInterval int64
+	authToken                         string
+	scalerIndex                       int
+}
+
+type sentryProject struct {
+	ID   string `json:"id"`
+	Slug string `json:"slug"`
+}
+
+type sentryStatsResponse struct {
+	Groups []struct {
+		Totals map[string]float64 `json:"totals"`
+	} `json:"groups"`
+}
+
+// sentryError is returned when the Sentry API responds with an unexpected status
+type sentryError struct {
+	status int
+	detail string
+}
+
+func (e *sentryError) Error() string {
+	return fmt.Sprintf("sentry API returned %d: %s", e.status, e.detail)
+}
+
+var sentryLog = logf.Log.WithName("sentry_scaler")
+
+// NewSentryScaler creates a new sentryScaler
+func NewSentryScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseSentryMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing sentry metadata: %s", err)
+	}
+
+	return &sentryScaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config),
+	}, nil
+}
+
+func parseSentryMetadata(config *ScalerConfig) (*sentryMetadata, error) {
+	meta := sentryMetadata{}
+	meta.sentryURL = defaultSentryURL
+	meta.category = defaultSentryCategory
+	meta.interval = defaultSentryInterval
+	meta.targetEventsPerInterval = defaultSentryTargetEventsPerInterval
+
+	if val, ok := config.TriggerMetadata["sentryURL"]; ok && val != "" {
+		meta.sentryURL = strings.TrimSuffix(val, "/")
+	}
+
+	if val, ok := config.TriggerMetadata["organization"]; ok && val != "" {
+		meta.organization = val
+	} else {
+		return nil, fmt.Errorf("no organization given")
+	}
+
+	if val, ok := config.TriggerMetadata["project"]; ok && val != "" {
+		meta.project = val
+	} else {
+		return nil, fmt.Errorf("no project given")
+	}
+
+	if val, ok := config.TriggerMetadata["category"]; ok && val != "" {
+		switch val {
+		case "error", "transaction":
+			meta.category = val
+		default:
+			return nil, fmt.Errorf("category must be error or transaction, got %s", val)
+		}
+	}
+
+	if val, ok := config.TriggerMetadata["interval"]; ok && val != "" {
+		if !sentryIntervalPattern.MatchString(val) {
+			return nil, fmt.Errorf("interval must be a number of minutes, hours or days such as 5m, 1h or 1d, got %s", val)
+		}
+		meta.interval = val
+	}
+
+	if val, ok := config.TriggerMetadata["targetEventsPerInterval"]; ok {
+		target, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetEventsPerInterval: %s", err)
+		}
+		if target <= 0 {
+			return nil, fmt.Errorf("targetEventsPerInterval must be greater than 0")
+		}
+		meta.targetEventsPerInterval = target
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetEventsPerInterval"]; ok {
+		activationTarget, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetEventsPerInterval: %s", err)
+		}
+		if activationTarget < 0 {
+			return nil, fmt.Errorf("activationTargetEventsPerInterval must not be negative")
+		}
+		meta.activationTargetEventsPerInterval = activationTarget
+	}
+
+	if val, ok := config.AuthParams["authToken"]; ok && val != "" {
+		meta.authToken = val
+	} else {
+		return nil, fmt.Errorf("no authToken given")
+	}
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// IsActive checks if more events were ingested during the interval than the activation target
+func (s *sentryScaler) IsActive(ctx context.Context) (bool, error) {
+	events, err := s.getEventCount(ctx)
+	if err != nil {
+		sentryLog.Error(err, "error getting the sentry event count")
+		return false, err
+	}
+
+	return events > s.metadata.activationTargetEventsPerInterval, nil
+}
+
+func (s *sentryScaler) Close(context.Context) error {
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *sentryScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("sentry-%s-%s-%s", s.metadata.organization, s.metadata.project, s.metadata.category))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetEventsPerInterval),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the number of events of the category ingested for the project during the interval
+func (s *sentryScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	events, err := s.getEventCount(ctx)
+	if err != nil {
+		sentryLog.Error(err, "error getting the sentry event count")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(events, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getEventCount sums the accepted events of the category over the interval from the organization stats
+func (s *sentryScaler) getEventCount(ctx context.Context) (int64, error) {
+	projectID, err := s.getProjectID(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	query := url.Values{}
+	query.Set("field", sentryStatsField)
+	query.Set("category", s.metadata.category)
+	query.Set("outcome", "accepted")
+	query.Set("project", projectID)
+	query.Set("interval", s.metadata.interval)
+	query.Set("statsPeriod", s.metadata.interval)
+
+	var stats sentryStatsResponse
+	statsURL := fmt.Sprintf("%s/api/0/organizations/%s/stats_v2/?%s", s.metadata.sentryURL, url.PathEscape(s.metadata.organization), query.Encode())
+	if err := s.get(ctx, statsURL, &stats); err != nil {
+		return -1, err
+	}
+
+	var events float64
+	for _, group := range stats.Groups {
+		events += group.Totals[sentryStatsField]
+	}
+	return int64(events), nil
+}
+
+// getProjectID looks up the id of the project, the stats API doesn't accept project slugs
+func (s *sentryScaler) getProjectID(ctx context.Context) (string, error) {
+	s.projectLock.Lock()
+	defer s.projectLock.Unlock()
+
+	if s.projectID != "" {
+		return s.projectID, nil
+	}
+
+	var project sentryProject
+	projectURL := fmt.Sprintf("%s/api/0/projects/%s/%s/", s.metadata.sentryURL, url.PathEscape(s.metadata.organization), url.PathEscape(s.metadata.project))
+	if err := s.get(ctx, projectURL, &project); err != nil {
+		if sentryErr, ok := err.(*sentryError); ok && sentryErr.status == http.StatusNotFound {
+			return "", fmt.Errorf("project %s not found in organization %s, check the scaler configuration", s.metadata.project, s.metadata.organization)
+		}
+		return "", err
+	}
+
+	s.projectID = project.ID
+	return s.projectID, nil
+}
+
+func (s *sentryScaler) get(ctx context.Context, url string, response interface{}) error {
+	res, err := kedautil.DoWithRateLimitBackoff(ctx, s.httpClient, kedautil.DefaultRateLimitBackoff, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.metadata.authToken))
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		sentryErr := &sentryError{status: res.StatusCode}
+		var body struct {
+			Detail string `json:"detail"`
+		}
+		if err := json.Unmarshal(b, &body); err == nil && body.Detail != "" {
+			sentryErr.detail = body.Detail
+		} else {
+			sentryErr.detail = string(b)
+		}
+		return sentryErr
+	}
+
+	if err := json.Unmarshal(b, response); err != nil {
+		return fmt.Errorf("error parsing sentry response: %s", err)
+	}
+	return nil
+}
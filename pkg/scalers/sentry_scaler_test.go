@@ -0,0 +1,188 @@
+package scalers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type parseSentryMetadataTestData struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}
+
+type sentryMetricIdentifier struct {
+	metadataTestData *parseSentryMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+var testSentryAuthParams = map[string]string{"authToken": "token"}
+
+var testSentryMetadata = []parseSentryMetadataTestData{
+	// nothing passed
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"organization": "acme", "project": "backend", "category": "transaction", "interval": "5m", "targetEventsPerInterval": "1000", "activationTargetEventsPerInterval": "10", "sentryURL": "https://sentry.example.com/"}, testSentryAuthParams, false},
+	// using defaults
+	{map[string]string{"organization": "acme", "project": "backend"}, testSentryAuthParams, false},
+	// missing organization
+	{map[string]string{"project": "backend"}, testSentryAuthParams, true},
+	// missing project
+	{map[string]string{"organization": "acme"}, testSentryAuthParams, true},
+	// missing authToken
+	{map[string]string{"organization": "acme", "project": "backend"}, map[string]string{}, true},
+	// invalid category
+	{map[string]string{"organization": "acme", "project": "backend", "category": "attachment"}, testSentryAuthParams, true},
+	// invalid interval
+	{map[string]string{"organization": "acme", "project": "backend", "interval": "1h30m"}, testSentryAuthParams, true},
+	// malformed targetEventsPerInterval
+	{map[string]string{"organization": "acme", "project": "backend", "targetEventsPerInterval": "a"}, testSentryAuthParams, true},
+	// negative activationTargetEventsPerInterval
+	{map[string]string{"organization": "acme", "project": "backend", "activationTargetEventsPerInterval": "-1"}, testSentryAuthParams, true},
+}
+
+var sentryMetricIdentifiers = []sentryMetricIdentifier{
+	{&testSentryMetadata[1], 0, "s0-sentry-acme-backend-transaction"},
+	{&testSentryMetadata[2], 1, "s1-sentry-acme-backend-error"},
+}
+
+// sentryProjectFixture is the part of the project details returned by /api/0/projects/{org}/{project}/
+const sentryProjectFixture = `{"id": "4504", "slug": "backend", "name": "Backend", "platform": "go"}`
+
+// sentryStatsFixture is a stats_v2 response for the accepted events of a project
+const sentryStatsFixture = `{
+	"start": "2022-06-01T10:00:00Z",
+	"end": "2022-06-01T11:00:00Z",
+	"intervals": ["2022-06-01T10:00:00Z"],
+	"groups": [
+		{"by": {}, "totals": {"sum(quantity)": 1234}, "series": {"sum(quantity)": [1234]}}
+	]
+}`
+
+func TestSentryParseMetadata(t *testing.T) {
+	for _, testData := range testSentryMetadata {
+		_, err := parseSentryMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams})
+		if err != nil && !testData.isError {
+			t.Error("Expected success but got error", err)
+		}
+		if testData.isError && err == nil {
+			t.Error("Expected error but got success")
+		}
+	}
+}
+
+func TestSentryGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range sentryMetricIdentifiers {
+		meta, err := parseSentryMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockSentryScaler := sentryScaler{metadata: meta}
+
+		metricSpec := mockSentryScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+func newTestSentryScaler(t *testing.T, server *httptest.Server, metadata map[string]string) *sentryScaler {
+	metadata["sentryURL"] = server.URL
+	meta, err := parseSentryMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: testSentryAuthParams})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	return &sentryScaler{metadata: meta, httpClient: server.Client()}
+}
+
+func TestSentryGetEventCount(t *testing.T) {
+	projectLookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/0/projects/acme/backend/":
+			projectLookups++
+			_, _ = w.Write([]byte(sentryProjectFixture))
+		case "/api/0/organizations/acme/stats_v2/":
+			query := r.URL.Query()
+			if query.Get("project") != "4504" || query.Get("category") != "transaction" || query.Get("statsPeriod") != "5m" || query.Get("field") != "sum(quantity)" {
+				t.Errorf("unexpected stats query %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(sentryStatsFixture))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	s := newTestSentryScaler(t, server, map[string]string{"organization": "acme", "project": "backend", "category": "transaction", "interval": "5m", "activationTargetEventsPerInterval": "1000"})
+
+	for i := 0; i < 2; i++ {
+		events, err := s.getEventCount(context.Background())
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if events != 1234 {
+			t.Errorf("Expected 1234 events, got %d", events)
+		}
+	}
+	if projectLookups != 1 {
+		t.Errorf("Expected the project id to be looked up once, got %d lookups", projectLookups)
+	}
+
+	active, err := s.IsActive(context.Background())
+	if err != nil || !active {
+		t.Errorf("Expected the scaler to be active, got %t, %v", active, err)
+	}
+}
+
+func TestSentryMissingProject(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"detail": "The requested resource does not exist"}`))
+	}))
+	defer server.Close()
+
+	s := newTestSentryScaler(t, server, map[string]string{"organization": "acme", "project": "missing"})
+
+	_, err := s.getEventCount(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "project missing not found in organization acme") {
+		t.Error("Expected a configuration error for the missing project, got", err)
+	}
+}
+
+func TestSentryRetriesWhenRateLimited(t *testing.T) {
+	statsCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/0/projects/") {
+			_, _ = w.Write([]byte(sentryProjectFixture))
+			return
+		}
+		statsCalls++
+		if statsCalls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(sentryStatsFixture))
+	}))
+	defer server.Close()
+
+	s := newTestSentryScaler(t, server, map[string]string{"organization": "acme", "project": "backend"})
+
+	events, err := s.getEventCount(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if events != 1234 || statsCalls != 2 {
+		t.Errorf("Expected 1234 events after a retry, got %d events in %d calls", events, statsCalls)
+	}
+}
@@ -113,7 +113,7 @@ var solaceLog = logf.Log.WithName(solaceScalerID + "_scaler")
 //	Constructor for SolaceScaler
 func NewSolaceScaler(config *ScalerConfig) (Scaler, error) {
 	// Create HTTP Client
-	httpClient := kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false)
+	httpClient := WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, false), config)
 
 	metricType, err := GetMetricTargetType(config)
 	if err != nil {
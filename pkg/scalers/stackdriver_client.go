@@ -2,38 +2,81 @@ package scalers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/compute/metadata"
 	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
 	timestamp "github.com/golang/protobuf/ptypes/timestamp"
 	"google.golang.org/api/iterator"
-	option "google.golang.org/api/option"
 	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
 )
 
+// errStackDriverMetricNotFound is returned when a filter, or a query, yields no point of a time series
+var errStackDriverMetricNotFound = errors.New("could not find stackdriver metric")
+
 // StackDriverClient is a generic client to fetch metrics from Stackdriver. Can be used
 // for a stackdriver scaler in the future
 type StackDriverClient struct {
 	metricsClient *monitoring.MetricClient
 	credentials   GoogleApplicationCredentials
 	projectID     string
+
+	// cacheKey is the key of the client in the shared client cache, if it was acquired from it
+	cacheKey *stackDriverClientKey
+}
+
+// stackDriverClientKey identifies the clients that can be shared by the GCP scalers, the
+// fingerprint of the credentials changes when the credentials of a TriggerAuthentication are rotated
+type stackDriverClientKey struct {
+	projectID              string
+	credentialsFingerprint string
+	targetServiceAccount   string
+	quotaProject           string
+	apiEndpoint            string
+}
+
+type sharedStackDriverClient struct {
+	client *StackDriverClient
+	refs   int
 }
 
+// stackDriverClientCache shares the Cloud Monitoring clients, and so their gRPC connections and
+// OAuth tokens, between the GCP scalers using the same credentials
+type stackDriverClientCache struct {
+	lock    sync.Mutex
+	clients map[stackDriverClientKey]*sharedStackDriverClient
+
+	// newClient creates the client of a key missing in the cache
+	newClient func(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (*StackDriverClient, error)
+}
+
+var stackDriverClients = newStackDriverClientCache(newStackDriverClientForAuthorization)
+
 // NewStackDriverClient creates a new stackdriver client with the credentials that are passed
 func NewStackDriverClient(ctx context.Context, credentials string) (*StackDriverClient, error) {
+	return newStackDriverClientCredentials(ctx, &gcpAuthorizationMetadata{GoogleApplicationCredentials: credentials})
+}
+
+func newStackDriverClientCredentials(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (*StackDriverClient, error) {
 	var gcpCredentials GoogleApplicationCredentials
 
-	if err := json.Unmarshal([]byte(credentials), &gcpCredentials); err != nil {
+	if err := json.Unmarshal([]byte(gcpAuthorization.GoogleApplicationCredentials), &gcpCredentials); err != nil {
 		return nil, err
 	}
 
-	clientOption := option.WithCredentialsJSON([]byte(credentials))
+	tokenSource, err := gcpTokenSources.get(ctx, gcpAuthorization)
+	if err != nil {
+		return nil, err
+	}
 
-	client, err := monitoring.NewMetricClient(ctx, clientOption)
+	client, err := monitoring.NewMetricClient(ctx, gcpAuthorization.clientOptions(tokenSource, gcpMonitoringAPI)...)
 	if err != nil {
 		return nil, err
 	}
@@ -46,7 +89,15 @@ func NewStackDriverClient(ctx context.Context, credentials string) (*StackDriver
 
 // NewStackDriverClient creates a new stackdriver client with the credentials underlying
 func NewStackDriverClientPodIdentity(ctx context.Context) (*StackDriverClient, error) {
-	client, err := monitoring.NewMetricClient(ctx)
+	return newStackDriverClientPodIdentity(ctx, &gcpAuthorizationMetadata{podIdentityProviderEnabled: true})
+}
+
+func newStackDriverClientPodIdentity(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (*StackDriverClient, error) {
+	tokenSource, err := gcpTokenSources.get(ctx, gcpAuthorization)
+	if err != nil {
+		return nil, err
+	}
+	client, err := monitoring.NewMetricClient(ctx, gcpAuthorization.clientOptions(tokenSource, gcpMonitoringAPI)...)
 	if err != nil {
 		return nil, err
 	}
@@ -61,6 +112,205 @@ func NewStackDriverClientPodIdentity(ctx context.Context) (*StackDriverClient, e
 	}, nil
 }
 
+// newStackDriverClientImpersonated creates a stackdriver client with the tokens of the target service account of the
+// authorization, impersonated with its credentials or with the identity of the pod or of the KEDA operator
+func newStackDriverClientImpersonated(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (*StackDriverClient, error) {
+	var gcpCredentials GoogleApplicationCredentials
+	if !gcpAuthorization.podIdentityProviderEnabled && gcpAuthorization.GoogleApplicationCredentials != "" {
+		if err := json.Unmarshal([]byte(gcpAuthorization.GoogleApplicationCredentials), &gcpCredentials); err != nil {
+			return nil, err
+		}
+	}
+
+	tokenSource, err := gcpTokenSources.get(ctx, gcpAuthorization)
+	if err != nil {
+		return nil, err
+	}
+	client, err := monitoring.NewMetricClient(ctx, gcpAuthorization.clientOptions(tokenSource, gcpMonitoringAPI)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the metrics are read by default in the project of the impersonated service account
+	project := gcpServiceAccountProject(gcpAuthorization.targetServiceAccount)
+	if project == "" {
+		project = getGcpExternalAccountQuotaProject(gcpAuthorization.credentialsConfig)
+	}
+	if project == "" && gcpCredentials.ProjectID == "" {
+		project, err = metadata.NewClient(&http.Client{}).ProjectID()
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return &StackDriverClient{
+		metricsClient: client,
+		credentials:   gcpCredentials,
+		projectID:     project,
+	}, nil
+}
+
+// newStackDriverClientExternalAccount creates a stackdriver client with the token source of the credential
+// configuration of Workload Identity Federation. The metrics are read by default in its quota project, it has no
+// project of its own
+func newStackDriverClientExternalAccount(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (*StackDriverClient, error) {
+	tokenSource, err := gcpTokenSources.get(ctx, gcpAuthorization)
+	if err != nil {
+		return nil, err
+	}
+	client, err := monitoring.NewMetricClient(ctx, gcpAuthorization.clientOptions(tokenSource, gcpMonitoringAPI)...)
+	if err != nil {
+		return nil, err
+	}
+	return &StackDriverClient{
+		metricsClient: client,
+		projectID:     getGcpExternalAccountQuotaProject(gcpAuthorization.credentialsConfig),
+	}, nil
+}
+
+// newStackDriverClientCredentialsFile creates a stackdriver client with the credentials in a file mounted in the
+// operator, the file is read again by the token source when the credentials are rotated
+func newStackDriverClientCredentialsFile(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (*StackDriverClient, error) {
+	// the metrics are read by default in the project of the impersonated service account, or of the credentials
+	project := gcpServiceAccountProject(gcpAuthorization.targetServiceAccount)
+	if project == "" {
+		var err error
+		if project, err = getGcpCredentialsFileProject(gcpAuthorization.GoogleApplicationCredentialsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	tokenSource, err := gcpTokenSources.get(ctx, gcpAuthorization)
+	if err != nil {
+		return nil, err
+	}
+	client, err := monitoring.NewMetricClient(ctx, gcpAuthorization.clientOptions(tokenSource, gcpMonitoringAPI)...)
+	if err != nil {
+		return nil, err
+	}
+	return &StackDriverClient{
+		metricsClient: client,
+		projectID:     project,
+	}, nil
+}
+
+func newStackDriverClientForAuthorization(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (*StackDriverClient, error) {
+	switch {
+	case gcpAuthorization.GoogleApplicationCredentialsFile != "" && !gcpAuthorization.podIdentityProviderEnabled:
+		return newStackDriverClientCredentialsFile(ctx, gcpAuthorization)
+	case gcpAuthorization.targetServiceAccount != "":
+		return newStackDriverClientImpersonated(ctx, gcpAuthorization)
+	case gcpAuthorization.credentialsConfig != "":
+		return newStackDriverClientExternalAccount(ctx, gcpAuthorization)
+	}
+	if gcpAuthorization.podIdentityProviderEnabled {
+		return newStackDriverClientPodIdentity(ctx, gcpAuthorization)
+	}
+	return newStackDriverClientCredentials(ctx, gcpAuthorization)
+}
+
+func newStackDriverClientCache(newClient func(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (*StackDriverClient, error)) *stackDriverClientCache {
+	return &stackDriverClientCache{
+		clients:   map[stackDriverClientKey]*sharedStackDriverClient{},
+		newClient: newClient,
+	}
+}
+
+// getStackDriverClientKey returns the cache key of the credentials, all the scalers using
+// the identity of the pod, or impersonating the same service account with it, share the same client
+func getStackDriverClientKey(gcpAuthorization *gcpAuthorizationMetadata) (stackDriverClientKey, error) {
+	key, err := getStackDriverClientCredentialsKey(gcpAuthorization)
+	key.quotaProject = gcpAuthorization.quotaProject
+	key.apiEndpoint = gcpAuthorization.apiEndpoint
+	return key, err
+}
+
+func getStackDriverClientCredentialsKey(gcpAuthorization *gcpAuthorizationMetadata) (stackDriverClientKey, error) {
+	switch {
+	case gcpAuthorization.podIdentityProviderEnabled:
+		return stackDriverClientKey{credentialsFingerprint: "podIdentity", targetServiceAccount: gcpAuthorization.targetServiceAccount}, nil
+	case gcpAuthorization.GoogleApplicationCredentialsFile != "":
+		// the file is updated in place when the credentials are rotated, its token source follows them
+		return stackDriverClientKey{
+			credentialsFingerprint: "file:" + gcpAuthorization.GoogleApplicationCredentialsFile,
+			targetServiceAccount:   gcpAuthorization.targetServiceAccount,
+		}, nil
+	case gcpAuthorization.credentialsConfig != "":
+		fingerprint := sha256.Sum256([]byte(gcpAuthorization.credentialsConfig))
+		return stackDriverClientKey{
+			projectID:              getGcpExternalAccountQuotaProject(gcpAuthorization.credentialsConfig),
+			credentialsFingerprint: hex.EncodeToString(fingerprint[:]),
+			targetServiceAccount:   gcpAuthorization.targetServiceAccount,
+		}, nil
+	case gcpAuthorization.GoogleApplicationCredentials == "" && gcpAuthorization.targetServiceAccount != "":
+		return stackDriverClientKey{credentialsFingerprint: "operatorIdentity", targetServiceAccount: gcpAuthorization.targetServiceAccount}, nil
+	}
+
+	var gcpCredentials GoogleApplicationCredentials
+	if err := json.Unmarshal([]byte(gcpAuthorization.GoogleApplicationCredentials), &gcpCredentials); err != nil {
+		return stackDriverClientKey{}, err
+	}
+	fingerprint := sha256.Sum256([]byte(gcpAuthorization.GoogleApplicationCredentials))
+	return stackDriverClientKey{
+		projectID:              gcpCredentials.ProjectID,
+		credentialsFingerprint: hex.EncodeToString(fingerprint[:]),
+		targetServiceAccount:   gcpAuthorization.targetServiceAccount,
+	}, nil
+}
+
+// acquire returns the client shared for the credentials, creating it if needed.
+// Every acquired client must be released when the scaler is closed
+func (c *stackDriverClientCache) acquire(ctx context.Context, gcpAuthorization *gcpAuthorizationMetadata) (*StackDriverClient, error) {
+	key, err := getStackDriverClientKey(gcpAuthorization)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if shared, ok := c.clients[key]; ok {
+		shared.refs++
+		return shared.client, nil
+	}
+
+	client, err := c.newClient(ctx, gcpAuthorization)
+	if err != nil {
+		return nil, err
+	}
+	client.cacheKey = &key
+	c.clients[key] = &sharedStackDriverClient{client: client, refs: 1}
+	return client, nil
+}
+
+// release drops a reference to the client, the last one closes it
+func (c *stackDriverClientCache) release(client *StackDriverClient) error {
+	if client.cacheKey == nil {
+		return client.close()
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	shared, ok := c.clients[*client.cacheKey]
+	if !ok || shared.client != client {
+		return nil
+	}
+	shared.refs--
+	if shared.refs > 0 {
+		return nil
+	}
+	delete(c.clients, *client.cacheKey)
+	return client.close()
+}
+
+func (s *StackDriverClient) close() error {
+	if s.metricsClient == nil {
+		return nil
+	}
+	return s.metricsClient.Close()
+}
+
 // GetMetrics fetches metrics from stackdriver for a specific filter for the last minute
 func (s StackDriverClient) GetMetrics(ctx context.Context, filter string, projectID string) (int64, error) {
 	// Set the start time to 1 minute ago
@@ -78,16 +328,7 @@ func (s StackDriverClient) GetMetrics(ctx context.Context, filter string, projec
 		},
 	}
 
-	switch projectID {
-	case "":
-		if len(s.projectID) > 0 {
-			req.Name = "projects/" + s.projectID
-		} else {
-			req.Name = "projects/" + s.credentials.ProjectID
-		}
-	default:
-		req.Name = "projects/" + projectID
-	}
+	req.Name = s.projectName(projectID)
 
 	// Get an iterator with the list of time series
 	it := s.metricsClient.ListTimeSeries(ctx, req)
@@ -113,6 +354,129 @@ func (s StackDriverClient) GetMetrics(ctx context.Context, filter string, projec
 	return value, nil
 }
 
+// GetLatestMetricsSum fetches the time series of a filter within the lookback and sums the latest point of each of
+// them, e.g. of the time series per storage class of a bucket. Unlike GetMetrics, it tolerates the metrics published
+// with a delay, the lookback just has to be longer than it
+func (s StackDriverClient) GetLatestMetricsSum(ctx context.Context, filter string, projectID string, lookback time.Duration) (int64, error) {
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-lookback)
+
+	var req = &monitoringpb.ListTimeSeriesRequest{
+		Name:   s.projectName(projectID),
+		Filter: filter,
+		Interval: &monitoringpb.TimeInterval{
+			StartTime: &timestamp.Timestamp{Seconds: startTime.Unix()},
+			EndTime:   &timestamp.Timestamp{Seconds: endTime.Unix()},
+		},
+	}
+
+	it := s.metricsClient.ListTimeSeries(ctx, req)
+
+	var sum int64
+	var series int
+	for {
+		resp, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		// the points are returned the latest first
+		if len(resp.GetPoints()) > 0 {
+			sum += resp.GetPoints()[0].GetValue().GetInt64Value()
+			series++
+		}
+	}
+
+	if series == 0 {
+		// e.g. a missing resource, or a lookback shorter than the publication delay of the metric
+		return 0, fmt.Errorf("could not find stackdriver metric with filter %s in the last %s", filter, lookback)
+	}
+	return sum, nil
+}
+
+// GetMetricsWithAggregation fetches the first time series of a filter within the lookback, aligned, and optionally
+// reduced, with the aggregation if any, and returns its latest point. The interval spans at least two alignment
+// periods, so that it holds a complete one
+func (s StackDriverClient) GetMetricsWithAggregation(ctx context.Context, filter string, projectID string, aggregation *monitoringpb.Aggregation, lookback time.Duration) (float64, error) {
+	if period := aggregation.GetAlignmentPeriod().AsDuration(); 2*period > lookback {
+		lookback = 2 * period
+	}
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-lookback)
+
+	var req = &monitoringpb.ListTimeSeriesRequest{
+		Name:   s.projectName(projectID),
+		Filter: filter,
+		Interval: &monitoringpb.TimeInterval{
+			StartTime: &timestamp.Timestamp{Seconds: startTime.Unix()},
+			EndTime:   &timestamp.Timestamp{Seconds: endTime.Unix()},
+		},
+		Aggregation: aggregation,
+	}
+
+	resp, err := s.metricsClient.ListTimeSeries(ctx, req).Next()
+	if err == iterator.Done {
+		return 0, fmt.Errorf("%w with filter %s in the last %s", errStackDriverMetricNotFound, filter, lookback)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.GetPoints()) == 0 {
+		return 0, fmt.Errorf("%w with filter %s in the last %s", errStackDriverMetricNotFound, filter, lookback)
+	}
+	return getStackDriverTypedValue(resp.GetPoints()[0].GetValue())
+}
+
+// QueryMetrics runs the Monitoring Query Language query and returns the latest point of its first time series
+func (s StackDriverClient) QueryMetrics(ctx context.Context, query string, projectID string) (float64, error) {
+	// the query service is served on the connection of the metrics client, which owns it
+	queryClient := monitoringpb.NewQueryServiceClient(s.metricsClient.Connection())
+	resp, err := queryClient.QueryTimeSeries(ctx, &monitoringpb.QueryTimeSeriesRequest{
+		Name:  s.projectName(projectID),
+		Query: query,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	series := resp.GetTimeSeriesData()
+	if len(series) == 0 {
+		return 0, fmt.Errorf("%w with query %s", errStackDriverMetricNotFound, query)
+	}
+	// the points are returned the latest first, with a value per value column of the query
+	points := series[0].GetPointData()
+	if len(points) == 0 || len(points[0].GetValues()) == 0 {
+		return 0, fmt.Errorf("%w with query %s", errStackDriverMetricNotFound, query)
+	}
+	return getStackDriverTypedValue(points[0].GetValues()[0])
+}
+
+// getStackDriverTypedValue returns the value of an integer or a double point, e.g. of a rate or a percentile
+func getStackDriverTypedValue(value *monitoringpb.TypedValue) (float64, error) {
+	switch v := value.GetValue().(type) {
+	case *monitoringpb.TypedValue_Int64Value:
+		return float64(v.Int64Value), nil
+	case *monitoringpb.TypedValue_DoubleValue:
+		return v.DoubleValue, nil
+	default:
+		return 0, fmt.Errorf("the stackdriver metric must be an integer or a double, got %T", v)
+	}
+}
+
+// projectName returns the name of the project of the metrics, the one of the client without projectID
+func (s StackDriverClient) projectName(projectID string) string {
+	switch {
+	case projectID != "":
+		return "projects/" + projectID
+	case len(s.projectID) > 0:
+		return "projects/" + s.projectID
+	default:
+		return "projects/" + s.credentials.ProjectID
+	}
+}
+
 // GoogleApplicationCredentials is a struct representing the format of a service account
 // credentials file
 type GoogleApplicationCredentials struct {
@@ -0,0 +1,168 @@
+package scalers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testStackDriverCredentials        = `{"type": "service_account", "project_id": "project", "private_key_id": "key-1"}`
+	testStackDriverRotatedCredentials = `{"type": "service_account", "project_id": "project", "private_key_id": "key-2"}`
+)
+
+func newTestStackDriverClientCache() (*stackDriverClientCache, *int) {
+	created := 0
+	cache := newStackDriverClientCache(func(context.Context, *gcpAuthorizationMetadata) (*StackDriverClient, error) {
+		created++
+		return &StackDriverClient{}, nil
+	})
+	return cache, &created
+}
+
+func TestStackDriverClientCacheSharesClients(t *testing.T) {
+	cache, created := newTestStackDriverClientCache()
+	auth := &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverCredentials}
+
+	first, err := cache.acquire(context.Background(), auth)
+	assert.NoError(t, err)
+	second, err := cache.acquire(context.Background(), auth)
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, *created)
+
+	// rotated credentials don't reuse the client of the previous ones
+	rotated, err := cache.acquire(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverRotatedCredentials})
+	assert.NoError(t, err)
+	assert.NotSame(t, first, rotated)
+	assert.Equal(t, 2, *created)
+
+	// pod identity has a client of its own
+	podIdentity, err := cache.acquire(context.Background(), &gcpAuthorizationMetadata{podIdentityProviderEnabled: true})
+	assert.NoError(t, err)
+	assert.NotSame(t, first, podIdentity)
+	assert.Equal(t, 3, *created)
+
+	// the client is kept until the last scaler using it is closed
+	assert.NoError(t, cache.release(first))
+	assert.Len(t, cache.clients, 3)
+	assert.NoError(t, cache.release(second))
+	assert.Len(t, cache.clients, 2)
+
+	third, err := cache.acquire(context.Background(), auth)
+	assert.NoError(t, err)
+	assert.NotSame(t, first, third)
+	assert.Equal(t, 4, *created)
+}
+
+func TestStackDriverClientCacheImpersonation(t *testing.T) {
+	cache, created := newTestStackDriverClientCache()
+
+	auths := []*gcpAuthorizationMetadata{
+		{GoogleApplicationCredentials: testStackDriverCredentials},
+		{GoogleApplicationCredentials: testStackDriverCredentials, targetServiceAccount: testGcpTargetServiceAccount},
+		{GoogleApplicationCredentials: testStackDriverCredentials, targetServiceAccount: "other@team-b.iam.gserviceaccount.com"},
+		{podIdentityProviderEnabled: true, targetServiceAccount: testGcpTargetServiceAccount},
+		// the identity of the KEDA operator
+		{targetServiceAccount: testGcpTargetServiceAccount},
+	}
+	clients := map[*StackDriverClient]bool{}
+	for _, auth := range auths {
+		client, err := cache.acquire(context.Background(), auth)
+		assert.NoError(t, err)
+		clients[client] = true
+	}
+	assert.Len(t, clients, len(auths))
+	assert.Equal(t, len(auths), *created)
+
+	// the scalers impersonating the same service account share the client
+	_, err := cache.acquire(context.Background(), &gcpAuthorizationMetadata{targetServiceAccount: testGcpTargetServiceAccount})
+	assert.NoError(t, err)
+	assert.Equal(t, len(auths), *created)
+}
+
+func TestStackDriverClientCacheExternalAccount(t *testing.T) {
+	cache, created := newTestStackDriverClientCache()
+
+	auth := &gcpAuthorizationMetadata{credentialsConfig: testGcpExternalAccountConfig}
+	first, err := cache.acquire(context.Background(), auth)
+	assert.NoError(t, err)
+	second, err := cache.acquire(context.Background(), &gcpAuthorizationMetadata{credentialsConfig: testGcpExternalAccountConfig})
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+
+	key, err := getStackDriverClientKey(auth)
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", key.projectID)
+
+	// the configuration of another pool has a client of its own
+	other, err := cache.acquire(context.Background(), &gcpAuthorizationMetadata{credentialsConfig: strings.Replace(testGcpExternalAccountConfig, "eks", "aks", 1)})
+	assert.NoError(t, err)
+	assert.NotSame(t, first, other)
+	assert.Equal(t, 2, *created)
+}
+
+func TestStackDriverClientCacheCredentialsFile(t *testing.T) {
+	cache, created := newTestStackDriverClientCache()
+	file := filepath.Join(t.TempDir(), "credentials.json")
+	assert.NoError(t, os.WriteFile(file, []byte(testStackDriverCredentials), 0600))
+
+	first, err := cache.acquire(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentialsFile: file})
+	assert.NoError(t, err)
+
+	// the client of the file is kept when its credentials are rotated, its token source reads them again
+	assert.NoError(t, os.WriteFile(file, []byte(testStackDriverRotatedCredentials), 0600))
+	second, err := cache.acquire(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentialsFile: file})
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, *created)
+
+	// the inline credentials of the same content have a client of their own
+	inline, err := cache.acquire(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverRotatedCredentials})
+	assert.NoError(t, err)
+	assert.NotSame(t, first, inline)
+	assert.Equal(t, 2, *created)
+
+	project, err := getGcpCredentialsFileProject(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "project", project)
+}
+
+func TestStackDriverClientCacheErrors(t *testing.T) {
+	cache := newStackDriverClientCache(func(context.Context, *gcpAuthorizationMetadata) (*StackDriverClient, error) {
+		return nil, errors.New("dial failed")
+	})
+
+	_, err := cache.acquire(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentials: "{"})
+	assert.Error(t, err)
+
+	_, err = cache.acquire(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentials: testStackDriverCredentials})
+	assert.EqualError(t, err, "dial failed")
+	assert.Empty(t, cache.clients)
+}
+
+func TestStackDriverClientCacheConcurrency(t *testing.T) {
+	cache, created := newTestStackDriverClientCache()
+	credentials := []string{testStackDriverCredentials, testStackDriverRotatedCredentials}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client, err := cache.acquire(context.Background(), &gcpAuthorizationMetadata{GoogleApplicationCredentials: credentials[i%2]})
+			assert.NoError(t, err)
+			assert.NoError(t, cache.release(client))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Empty(t, cache.clients)
+	assert.GreaterOrEqual(t, *created, 2)
+}
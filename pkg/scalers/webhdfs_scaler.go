@@ -0,0 +1,479 @@
+package scalers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	krb5client "github.com/jcmturner/gokrb5/v8/client"
+	krb5config "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	v2beta2 "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedautil "github.com/kedacore/keda/v2/pkg/util"
+)
+
+const (
+	// webHDFSAuthModeSimple passes the user with the user.name query parameter
+	webHDFSAuthModeSimple = "simple"
+	// webHDFSAuthModeKerberos authenticates with SPNEGO
+	webHDFSAuthModeKerberos = "kerberos"
+
+	defaultWebHDFSTargetFileCount = 5
+
+	// webHDFSStandbyException is returned by a namenode in standby state of an HA pair
+	webHDFSStandbyException = "StandbyException"
+)
+
+type webHDFSScaler struct {
+	metricType v2beta2.MetricTargetType
+	metadata   *webHDFSMetadata
+	httpClient *http.Client
+	spnego     *spnego.Client
+
+	// index of the namenode that answered last, it's tried first on the next request
+	namenodeLock   sync.Mutex
+	activeNamenode int
+	// listStatusBatchUnsupported is set when the namenode doesn't know LISTSTATUS_BATCH, e.g. before Hadoop 2.8
+	listStatusBatchUnsupported bool
+}
+
+type webHDFSMetadata struct {
+	namenodeURLs              []string
+	path                      string
+	filePattern               string
+	targetFileCount           int64
+	activationTargetFileCount int64
+	unsafeSsl                 bool
+
+	authMode            string
+	username            string
+	kerberosRealm       string
+	kerberosPassword    string
+	kerberosKeytab      string
+	kerberosConfig      string
+	kerberosDisableFAST bool
+
+	scalerIndex int
+}
+
+type webHDFSFileStatus struct {
+	PathSuffix string `json:"pathSuffix"`
+	Type       string `json:"type"`
+}
+
+type webHDFSFileStatuses struct {
+	FileStatus []webHDFSFileStatus `json:"FileStatus"`
+}
+
+// webHDFSListing is the response of LISTSTATUS and LISTSTATUS_BATCH
+type webHDFSListing struct {
+	FileStatuses     *webHDFSFileStatuses `json:"FileStatuses"`
+	DirectoryListing *struct {
+		PartialListing struct {
+			FileStatuses webHDFSFileStatuses `json:"FileStatuses"`
+		} `json:"partialListing"`
+		RemainingEntries int64 `json:"remainingEntries"`
+	} `json:"DirectoryListing"`
+}
+
+type webHDFSRemoteException struct {
+	RemoteException struct {
+		Exception string `json:"exception"`
+		Message   string `json:"message"`
+	} `json:"RemoteException"`
+}
+
+// webHDFSError is an error returned by the WebHDFS API
+type webHDFSError struct {
+	statusCode int
+	exception  string
+	message    string
+}
+
+func (e *webHDFSError) Error() string {
+	if e.exception != "" {
+		return fmt.Sprintf("webhdfs returned %d: %s: %s", e.statusCode, e.exception, e.message)
+	}
+	return fmt.Sprintf("webhdfs returned %d: %s", e.statusCode, e.message)
+}
+
+var webHDFSLog = logf.Log.WithName("webhdfs_scaler")
+
+// NewWebHDFSScaler creates a new webHDFSScaler
+func NewWebHDFSScaler(config *ScalerConfig) (Scaler, error) {
+	metricType, err := GetMetricTargetType(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting scaler metric type: %s", err)
+	}
+
+	meta, err := parseWebHDFSMetadata(config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing webhdfs metadata: %s", err)
+	}
+
+	s := &webHDFSScaler{
+		metricType: metricType,
+		metadata:   meta,
+		httpClient: WithUserAgent(kedautil.CreateHTTPClient(config.GlobalHTTPTimeout, meta.unsafeSsl), config),
+	}
+
+	if meta.authMode == webHDFSAuthModeKerberos {
+		krb5Client, err := newWebHDFSKerberosClient(meta)
+		if err != nil {
+			return nil, err
+		}
+		// the SPN is derived from the host of each namenode
+		s.spnego = spnego.NewClient(krb5Client, s.httpClient, "")
+	}
+
+	return s, nil
+}
+
+func parseWebHDFSMetadata(config *ScalerConfig) (*webHDFSMetadata, error) {
+	meta := webHDFSMetadata{}
+	meta.targetFileCount = defaultWebHDFSTargetFileCount
+	meta.authMode = webHDFSAuthModeSimple
+
+	if val, ok := config.TriggerMetadata["namenodeURL"]; ok && val != "" {
+		// the namenodes of an HA pair are tried in order, the standby one refuses the request
+		for _, namenodeURL := range strings.Split(val, ",") {
+			namenodeURL = strings.TrimSuffix(strings.TrimSpace(namenodeURL), "/")
+			if namenodeURL == "" {
+				continue
+			}
+			if _, err := url.ParseRequestURI(namenodeURL); err != nil {
+				return nil, fmt.Errorf("error parsing namenodeURL %s: %s", namenodeURL, err)
+			}
+			meta.namenodeURLs = append(meta.namenodeURLs, namenodeURL)
+		}
+	}
+	if len(meta.namenodeURLs) == 0 {
+		return nil, fmt.Errorf("no namenodeURL given")
+	}
+
+	if val, ok := config.TriggerMetadata["path"]; ok && val != "" {
+		if !strings.HasPrefix(val, "/") {
+			return nil, fmt.Errorf("path must be absolute, got %s", val)
+		}
+		meta.path = path.Clean(val)
+	} else {
+		return nil, fmt.Errorf("no path given")
+	}
+
+	if val, ok := config.TriggerMetadata["filePattern"]; ok && val != "" {
+		if _, err := path.Match(val, ""); err != nil {
+			return nil, fmt.Errorf("error parsing filePattern: %s", err)
+		}
+		meta.filePattern = val
+	}
+
+	if val, ok := config.TriggerMetadata["targetFileCount"]; ok {
+		targetFileCount, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing targetFileCount: %s", err)
+		}
+		if targetFileCount <= 0 {
+			return nil, fmt.Errorf("targetFileCount must be greater than 0")
+		}
+		meta.targetFileCount = targetFileCount
+	}
+
+	if val, ok := config.TriggerMetadata["activationTargetFileCount"]; ok {
+		activationTargetFileCount, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing activationTargetFileCount: %s", err)
+		}
+		if activationTargetFileCount < 0 {
+			return nil, fmt.Errorf("activationTargetFileCount must not be negative")
+		}
+		meta.activationTargetFileCount = activationTargetFileCount
+	}
+
+	if val, ok := config.TriggerMetadata["unsafeSsl"]; ok {
+		unsafeSsl, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing unsafeSsl: %s", err)
+		}
+		meta.unsafeSsl = unsafeSsl
+	}
+
+	if val, ok := config.AuthParams["authMode"]; ok && val != "" {
+		switch val {
+		case webHDFSAuthModeSimple, webHDFSAuthModeKerberos:
+			meta.authMode = val
+		default:
+			return nil, fmt.Errorf("authMode must be %s or %s, got %s", webHDFSAuthModeSimple, webHDFSAuthModeKerberos, val)
+		}
+	}
+
+	meta.username = config.AuthParams["username"]
+
+	if meta.authMode == webHDFSAuthModeKerberos {
+		if meta.username == "" {
+			return nil, fmt.Errorf("no username given, it's required by authMode %s", webHDFSAuthModeKerberos)
+		}
+
+		meta.kerberosRealm = config.AuthParams["kerberosRealm"]
+		if meta.kerberosRealm == "" {
+			return nil, fmt.Errorf("no kerberosRealm given")
+		}
+
+		meta.kerberosConfig = config.AuthParams["kerberosConfig"]
+		if meta.kerberosConfig == "" {
+			return nil, fmt.Errorf("no kerberosConfig given")
+		}
+
+		meta.kerberosKeytab = config.AuthParams["kerberosKeytab"]
+		meta.kerberosPassword = config.AuthParams["kerberosPassword"]
+		if (meta.kerberosKeytab == "") == (meta.kerberosPassword == "") {
+			return nil, fmt.Errorf("either kerberosKeytab or kerberosPassword must be given")
+		}
+
+		if val, ok := config.AuthParams["kerberosDisableFAST"]; ok {
+			disableFAST, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing kerberosDisableFAST: %s", err)
+			}
+			meta.kerberosDisableFAST = disableFAST
+		}
+	}
+
+	meta.scalerIndex = config.ScalerIndex
+
+	return &meta, nil
+}
+
+// newWebHDFSKerberosClient creates the Kerberos client of the SPNEGO authentication,
+// it logs in on the first request so the KDC doesn't have to be reachable when the scaler is created
+func newWebHDFSKerberosClient(meta *webHDFSMetadata) (*krb5client.Client, error) {
+	cfg, err := krb5config.NewFromString(meta.kerberosConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing kerberosConfig: %s", err)
+	}
+
+	settings := krb5client.DisablePAFXFAST(meta.kerberosDisableFAST)
+	if meta.kerberosKeytab != "" {
+		kt := keytab.New()
+		if err := kt.Unmarshal([]byte(meta.kerberosKeytab)); err != nil {
+			return nil, fmt.Errorf("error parsing kerberosKeytab: %s", err)
+		}
+		return krb5client.NewWithKeytab(meta.username, meta.kerberosRealm, kt, cfg, settings), nil
+	}
+	return krb5client.NewWithPassword(meta.username, meta.kerberosRealm, meta.kerberosPassword, cfg, settings), nil
+}
+
+// IsActive checks if the directory holds more files than the activation target
+func (s *webHDFSScaler) IsActive(ctx context.Context) (bool, error) {
+	fileCount, err := s.getFileCount(ctx)
+	if err != nil {
+		webHDFSLog.Error(err, "error getting file count")
+		return false, err
+	}
+
+	return fileCount > s.metadata.activationTargetFileCount, nil
+}
+
+func (s *webHDFSScaler) Close(context.Context) error {
+	if s.spnego != nil {
+		s.spnego.Client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// GetMetricSpecForScaling returns the metric spec for the HPA
+func (s *webHDFSScaler) GetMetricSpecForScaling(context.Context) []v2beta2.MetricSpec {
+	externalMetric := &v2beta2.ExternalMetricSource{
+		Metric: v2beta2.MetricIdentifier{
+			Name: GenerateMetricNameWithIndex(s.metadata.scalerIndex, kedautil.NormalizeString(fmt.Sprintf("webhdfs-%s", strings.TrimPrefix(s.metadata.path, "/")))),
+		},
+		Target: GetMetricTarget(s.metricType, s.metadata.targetFileCount),
+	}
+	metricSpec := v2beta2.MetricSpec{External: externalMetric, Type: externalMetricType}
+	return []v2beta2.MetricSpec{metricSpec}
+}
+
+// GetMetrics returns the number of files in the directory
+func (s *webHDFSScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	fileCount, err := s.getFileCount(ctx)
+	if err != nil {
+		webHDFSLog.Error(err, "error getting file count")
+		return []external_metrics.ExternalMetricValue{}, err
+	}
+
+	metric := external_metrics.ExternalMetricValue{
+		MetricName: metricName,
+		Value:      *resource.NewQuantity(fileCount, resource.DecimalSI),
+		Timestamp:  metav1.Now(),
+	}
+
+	return append([]external_metrics.ExternalMetricValue{}, metric), nil
+}
+
+// getFileCount asks the namenodes in turn, starting with the one that answered last, until one of them lists the directory.
+// A namenode that can't be reached or is in standby is skipped, other errors are returned right away
+func (s *webHDFSScaler) getFileCount(ctx context.Context) (int64, error) {
+	s.namenodeLock.Lock()
+	start := s.activeNamenode
+	s.namenodeLock.Unlock()
+
+	var errs []string
+	for i := 0; i < len(s.metadata.namenodeURLs); i++ {
+		index := (start + i) % len(s.metadata.namenodeURLs)
+		namenodeURL := s.metadata.namenodeURLs[index]
+
+		fileCount, err := s.getFileCountFromNamenode(ctx, namenodeURL)
+		if err != nil {
+			if hdfsErr, ok := err.(*webHDFSError); ok && hdfsErr.exception != webHDFSStandbyException {
+				return -1, err
+			}
+			webHDFSLog.V(1).Info("namenode failed, trying the next one", "namenodeURL", namenodeURL, "error", err.Error())
+			errs = append(errs, fmt.Sprintf("%s: %s", namenodeURL, err))
+			continue
+		}
+
+		s.namenodeLock.Lock()
+		s.activeNamenode = index
+		s.namenodeLock.Unlock()
+		return fileCount, nil
+	}
+
+	return -1, fmt.Errorf("no namenode returned the file count: %s", strings.Join(errs, "; "))
+}
+
+// getFileCountFromNamenode counts the files of the directory matching filePattern, directories aren't counted.
+// The directory is listed in batches with LISTSTATUS_BATCH, or at once with LISTSTATUS when the namenode doesn't support it
+func (s *webHDFSScaler) getFileCountFromNamenode(ctx context.Context, namenodeURL string) (int64, error) {
+	s.namenodeLock.Lock()
+	listStatusBatchUnsupported := s.listStatusBatchUnsupported
+	s.namenodeLock.Unlock()
+
+	if listStatusBatchUnsupported {
+		listing, err := s.listStatus(ctx, namenodeURL, "LISTSTATUS", "")
+		if err != nil {
+			return -1, err
+		}
+		if listing.FileStatuses == nil {
+			return -1, fmt.Errorf("the LISTSTATUS response doesn't contain FileStatuses")
+		}
+		return s.countFiles(listing.FileStatuses.FileStatus), nil
+	}
+
+	var fileCount int64
+	startAfter := ""
+	for {
+		listing, err := s.listStatus(ctx, namenodeURL, "LISTSTATUS_BATCH", startAfter)
+		if err != nil {
+			if hdfsErr, ok := err.(*webHDFSError); ok && startAfter == "" && isWebHDFSUnsupportedOperation(hdfsErr) {
+				webHDFSLog.V(1).Info("namenode doesn't support LISTSTATUS_BATCH, using LISTSTATUS", "namenodeURL", namenodeURL)
+				s.namenodeLock.Lock()
+				s.listStatusBatchUnsupported = true
+				s.namenodeLock.Unlock()
+				return s.getFileCountFromNamenode(ctx, namenodeURL)
+			}
+			return -1, err
+		}
+		if listing.DirectoryListing == nil {
+			return -1, fmt.Errorf("the LISTSTATUS_BATCH response doesn't contain DirectoryListing")
+		}
+
+		statuses := listing.DirectoryListing.PartialListing.FileStatuses.FileStatus
+		fileCount += s.countFiles(statuses)
+
+		if listing.DirectoryListing.RemainingEntries <= 0 || len(statuses) == 0 {
+			return fileCount, nil
+		}
+		startAfter = statuses[len(statuses)-1].PathSuffix
+	}
+}
+
+func (s *webHDFSScaler) countFiles(statuses []webHDFSFileStatus) int64 {
+	var fileCount int64
+	for _, status := range statuses {
+		if status.Type != "FILE" {
+			continue
+		}
+		if s.metadata.filePattern != "" {
+			if matched, _ := path.Match(s.metadata.filePattern, status.PathSuffix); !matched {
+				continue
+			}
+		}
+		fileCount++
+	}
+	return fileCount
+}
+
+func (s *webHDFSScaler) listStatus(ctx context.Context, namenodeURL, op, startAfter string) (*webHDFSListing, error) {
+	u, err := url.Parse(namenodeURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = fmt.Sprintf("%s/webhdfs/v1%s", strings.TrimSuffix(u.Path, "/"), s.metadata.path)
+
+	query := url.Values{}
+	query.Set("op", op)
+	if startAfter != "" {
+		query.Set("startAfter", startAfter)
+	}
+	if s.metadata.authMode == webHDFSAuthModeSimple && s.metadata.username != "" {
+		query.Set("user.name", s.metadata.username)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *http.Response
+	if s.spnego != nil {
+		res, err = s.spnego.Do(req)
+	} else {
+		res, err = s.httpClient.Do(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		hdfsErr := &webHDFSError{statusCode: res.StatusCode, message: string(b)}
+		var remoteException webHDFSRemoteException
+		if err := json.Unmarshal(b, &remoteException); err == nil && remoteException.RemoteException.Exception != "" {
+			hdfsErr.exception = remoteException.RemoteException.Exception
+			hdfsErr.message = remoteException.RemoteException.Message
+		}
+		return nil, hdfsErr
+	}
+
+	var listing webHDFSListing
+	if err := json.Unmarshal(b, &listing); err != nil {
+		return nil, fmt.Errorf("error parsing %s response: %s", op, err)
+	}
+	return &listing, nil
+}
+
+// isWebHDFSUnsupportedOperation checks if the namenode rejected the operation as unknown
+func isWebHDFSUnsupportedOperation(err *webHDFSError) bool {
+	return err.statusCode == http.StatusBadRequest &&
+		(err.exception == "IllegalArgumentException" || err.exception == "UnsupportedOperationException") &&
+		strings.Contains(err.message, "op")
+}
@@ -0,0 +1,321 @@
+package scalers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type parseWebHDFSMetadataTestData struct {
+	metadata   map[string]string
+	authParams map[string]string
+	isError    bool
+}
+
+type webHDFSMetricIdentifier struct {
+	metadataTestData *parseWebHDFSMetadataTestData
+	scalerIndex      int
+	name             string
+}
+
+const testWebHDFSKrb5Conf = `[libdefaults]
+  default_realm = EXAMPLE.COM
+
+[realms]
+  EXAMPLE.COM = {
+    kdc = kdc.example.com:88
+  }
+`
+
+var testWebHDFSMetadata = []parseWebHDFSMetadataTestData{
+	// nothing passed
+	{map[string]string{}, map[string]string{}, true},
+	// all properly formed
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing", "filePattern": "*.parquet", "targetFileCount": "10", "activationTargetFileCount": "2"}, map[string]string{}, false},
+	// HA pair
+	{map[string]string{"namenodeURL": "http://nn1:9870, http://nn2:9870/", "path": "/ingest/landing"}, map[string]string{}, false},
+	// missing namenodeURL
+	{map[string]string{"path": "/ingest/landing"}, map[string]string{}, true},
+	// invalid namenodeURL
+	{map[string]string{"namenodeURL": "namenode", "path": "/ingest/landing"}, map[string]string{}, true},
+	// missing path
+	{map[string]string{"namenodeURL": "http://namenode:9870"}, map[string]string{}, true},
+	// relative path
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "ingest/landing"}, map[string]string{}, true},
+	// malformed filePattern
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing", "filePattern": "[a"}, map[string]string{}, true},
+	// malformed targetFileCount
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing", "targetFileCount": "a"}, map[string]string{}, true},
+	// zero targetFileCount
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing", "targetFileCount": "0"}, map[string]string{}, true},
+	// negative activationTargetFileCount
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing", "activationTargetFileCount": "-1"}, map[string]string{}, true},
+	// malformed unsafeSsl
+	{map[string]string{"namenodeURL": "https://namenode:9871", "path": "/ingest/landing", "unsafeSsl": "a"}, map[string]string{}, true},
+	// simple auth
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing"}, map[string]string{"authMode": "simple", "username": "spark"}, false},
+	// invalid authMode
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing"}, map[string]string{"authMode": "token"}, true},
+	// kerberos with password
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing"}, map[string]string{"authMode": "kerberos", "username": "spark", "kerberosRealm": "EXAMPLE.COM", "kerberosConfig": testWebHDFSKrb5Conf, "kerberosPassword": "secret"}, false},
+	// kerberos without username
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing"}, map[string]string{"authMode": "kerberos", "kerberosRealm": "EXAMPLE.COM", "kerberosConfig": testWebHDFSKrb5Conf, "kerberosPassword": "secret"}, true},
+	// kerberos without realm
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing"}, map[string]string{"authMode": "kerberos", "username": "spark", "kerberosConfig": testWebHDFSKrb5Conf, "kerberosPassword": "secret"}, true},
+	// kerberos without config
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing"}, map[string]string{"authMode": "kerberos", "username": "spark", "kerberosRealm": "EXAMPLE.COM", "kerberosPassword": "secret"}, true},
+	// kerberos without keytab or password
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing"}, map[string]string{"authMode": "kerberos", "username": "spark", "kerberosRealm": "EXAMPLE.COM", "kerberosConfig": testWebHDFSKrb5Conf}, true},
+	// kerberos with keytab and password
+	{map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing"}, map[string]string{"authMode": "kerberos", "username": "spark", "kerberosRealm": "EXAMPLE.COM", "kerberosConfig": testWebHDFSKrb5Conf, "kerberosPassword": "secret", "kerberosKeytab": "keytab"}, true},
+}
+
+var webHDFSMetricIdentifiers = []webHDFSMetricIdentifier{
+	{&testWebHDFSMetadata[1], 0, "s0-webhdfs-ingest-landing"},
+	{&testWebHDFSMetadata[1], 1, "s1-webhdfs-ingest-landing"},
+}
+
+func TestWebHDFSParseMetadata(t *testing.T) {
+	for i, testData := range testWebHDFSMetadata {
+		_, err := parseWebHDFSMetadata(&ScalerConfig{TriggerMetadata: testData.metadata, AuthParams: testData.authParams})
+		if err != nil && !testData.isError {
+			t.Errorf("case %d: expected success but got error %s", i, err)
+		}
+		if testData.isError && err == nil {
+			t.Errorf("case %d: expected error but got success", i)
+		}
+	}
+}
+
+func TestWebHDFSGetMetricSpecForScaling(t *testing.T) {
+	for _, testData := range webHDFSMetricIdentifiers {
+		meta, err := parseWebHDFSMetadata(&ScalerConfig{TriggerMetadata: testData.metadataTestData.metadata, AuthParams: testData.metadataTestData.authParams, ScalerIndex: testData.scalerIndex})
+		if err != nil {
+			t.Fatal("Could not parse metadata:", err)
+		}
+		mockWebHDFSScaler := webHDFSScaler{metadata: meta}
+
+		metricSpec := mockWebHDFSScaler.GetMetricSpecForScaling(context.Background())
+		metricName := metricSpec[0].External.Metric.Name
+		if metricName != testData.name {
+			t.Error("Wrong External metric source name:", metricName)
+		}
+	}
+}
+
+func TestWebHDFSKerberosScaler(t *testing.T) {
+	scaler, err := NewWebHDFSScaler(&ScalerConfig{
+		TriggerMetadata: map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing"},
+		AuthParams:      map[string]string{"authMode": "kerberos", "username": "spark", "kerberosRealm": "EXAMPLE.COM", "kerberosConfig": testWebHDFSKrb5Conf, "kerberosPassword": "secret"},
+	})
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if scaler.(*webHDFSScaler).spnego == nil {
+		t.Error("Expected a SPNEGO client")
+	}
+
+	_, err = NewWebHDFSScaler(&ScalerConfig{
+		TriggerMetadata: map[string]string{"namenodeURL": "http://namenode:9870", "path": "/ingest/landing"},
+		AuthParams:      map[string]string{"authMode": "kerberos", "username": "spark", "kerberosRealm": "EXAMPLE.COM", "kerberosConfig": testWebHDFSKrb5Conf, "kerberosKeytab": "not a keytab"},
+	})
+	if err == nil {
+		t.Error("Expected error for a malformed keytab but got success")
+	}
+}
+
+func newTestWebHDFSScaler(t *testing.T, metadata map[string]string, authParams map[string]string) *webHDFSScaler {
+	meta, err := parseWebHDFSMetadata(&ScalerConfig{TriggerMetadata: metadata, AuthParams: authParams})
+	if err != nil {
+		t.Fatal("Could not parse metadata:", err)
+	}
+	return &webHDFSScaler{metadata: meta, httpClient: http.DefaultClient}
+}
+
+func webHDFSFileStatusJSON(name, fileType string) string {
+	return fmt.Sprintf(`{"accessTime": 1650000000000, "blockSize": 134217728, "childrenNum": 0, "fileId": 16390, "group": "supergroup", "length": 1024, "modificationTime": 1650000000000, "owner": "spark", "pathSuffix": %q, "permission": "644", "replication": 3, "storagePolicy": 0, "type": %q}`, name, fileType)
+}
+
+// webHDFSBatchHandler serves the directory in batches of two entries like LISTSTATUS_BATCH with dfs.ls.limit=2
+func webHDFSBatchHandler(t *testing.T, entries [][2]string, requests *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.RawQuery)
+		if r.URL.Path != "/webhdfs/v1/ingest/landing" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("op") != "LISTSTATUS_BATCH" {
+			t.Errorf("unexpected op %s", r.URL.Query().Get("op"))
+		}
+
+		start := 0
+		if startAfter := r.URL.Query().Get("startAfter"); startAfter != "" {
+			for i, entry := range entries {
+				if entry[0] == startAfter {
+					start = i + 1
+				}
+			}
+		}
+		end := start + 2
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		statuses := make([]string, 0, end-start)
+		for _, entry := range entries[start:end] {
+			statuses = append(statuses, webHDFSFileStatusJSON(entry[0], entry[1]))
+		}
+		_, _ = fmt.Fprintf(w, `{"DirectoryListing": {"partialListing": {"FileStatuses": {"FileStatus": [%s]}}, "remainingEntries": %d}}`, strings.Join(statuses, ","), len(entries)-end)
+	}
+}
+
+func TestWebHDFSGetFileCountBatched(t *testing.T) {
+	entries := [][2]string{
+		{"_temporary", "DIRECTORY"},
+		{"part-0000.parquet", "FILE"},
+		{"part-0001.parquet", "FILE"},
+		{"part-0002.parquet", "FILE"},
+		{"_SUCCESS", "FILE"},
+	}
+
+	var requests []string
+	server := httptest.NewServer(webHDFSBatchHandler(t, entries, &requests))
+	defer server.Close()
+
+	scaler := newTestWebHDFSScaler(t, map[string]string{"namenodeURL": server.URL, "path": "/ingest/landing/", "filePattern": "*.parquet"}, map[string]string{"username": "spark"})
+	fileCount, err := scaler.getFileCount(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if fileCount != 3 {
+		t.Errorf("Expected 3 files, got %d", fileCount)
+	}
+	if len(requests) != 3 {
+		t.Errorf("Expected 3 batches, got %d", len(requests))
+	}
+	if !strings.Contains(requests[1], "startAfter=part-0000.parquet") {
+		t.Errorf("Expected the second batch to start after the first one, got %s", requests[1])
+	}
+	if !strings.Contains(requests[0], "user.name=spark") {
+		t.Errorf("Expected the user of simple auth, got %s", requests[0])
+	}
+
+	// without filePattern every file is counted, directories are not
+	scaler = newTestWebHDFSScaler(t, map[string]string{"namenodeURL": server.URL, "path": "/ingest/landing", "activationTargetFileCount": "3"}, map[string]string{})
+	active, err := scaler.IsActive(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if !active {
+		t.Error("Expected scaler to be active")
+	}
+}
+
+func TestWebHDFSListStatusFallback(t *testing.T) {
+	var ops []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := r.URL.Query().Get("op")
+		ops = append(ops, op)
+		if op == "LISTSTATUS_BATCH" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"RemoteException": {"exception": "IllegalArgumentException", "javaClassName": "java.lang.IllegalArgumentException", "message": "Invalid value for webhdfs parameter \"op\": No enum constant org.apache.hadoop.hdfs.web.resources.GetOpParam.Op.LISTSTATUS_BATCH"}}`))
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"FileStatuses": {"FileStatus": [%s, %s]}}`, webHDFSFileStatusJSON("a.csv", "FILE"), webHDFSFileStatusJSON("b.csv", "FILE"))
+	}))
+	defer server.Close()
+
+	scaler := newTestWebHDFSScaler(t, map[string]string{"namenodeURL": server.URL, "path": "/ingest/landing"}, map[string]string{})
+	for i := 0; i < 2; i++ {
+		fileCount, err := scaler.getFileCount(context.Background())
+		if err != nil {
+			t.Fatal("Expected success but got error", err)
+		}
+		if fileCount != 2 {
+			t.Errorf("Expected 2 files, got %d", fileCount)
+		}
+	}
+
+	// LISTSTATUS_BATCH isn't tried again once the namenode rejected it
+	if strings.Join(ops, ",") != "LISTSTATUS_BATCH,LISTSTATUS,LISTSTATUS" {
+		t.Errorf("Unexpected operations %v", ops)
+	}
+}
+
+func TestWebHDFSNamenodeFailover(t *testing.T) {
+	var standbyRequests, activeRequests int
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		standbyRequests++
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"RemoteException": {"exception": "StandbyException", "javaClassName": "org.apache.hadoop.ipc.StandbyException", "message": "Operation category READ is not supported in state standby"}}`))
+	}))
+	defer standby.Close()
+
+	var activeEntries [][2]string
+	for i := 0; i < 3; i++ {
+		activeEntries = append(activeEntries, [2]string{fmt.Sprintf("file-%d", i), "FILE"})
+	}
+	var requests []string
+	batchHandler := webHDFSBatchHandler(t, activeEntries, &requests)
+	active := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		activeRequests++
+		batchHandler(w, r)
+	}))
+	defer active.Close()
+
+	scaler := newTestWebHDFSScaler(t, map[string]string{"namenodeURL": standby.URL + "," + active.URL, "path": "/ingest/landing"}, map[string]string{})
+
+	fileCount, err := scaler.getFileCount(context.Background())
+	if err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if fileCount != 3 {
+		t.Errorf("Expected 3 files, got %d", fileCount)
+	}
+	if standbyRequests != 1 || activeRequests != 2 {
+		t.Errorf("Expected 1 request to the standby and 2 to the active namenode, got %d and %d", standbyRequests, activeRequests)
+	}
+
+	// the active namenode is asked first from now on
+	if _, err := scaler.getFileCount(context.Background()); err != nil {
+		t.Fatal("Expected success but got error", err)
+	}
+	if standbyRequests != 1 {
+		t.Errorf("Expected the active namenode to be reused, got %d requests to the standby", standbyRequests)
+	}
+}
+
+func TestWebHDFSErrors(t *testing.T) {
+	var secondRequests int
+	notFound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"RemoteException": {"exception": "FileNotFoundException", "javaClassName": "java.io.FileNotFoundException", "message": "File /ingest/landing does not exist."}}`))
+	}))
+	defer notFound.Close()
+
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondRequests++
+	}))
+	defer second.Close()
+
+	// an error of the active namenode isn't hidden by failing over
+	scaler := newTestWebHDFSScaler(t, map[string]string{"namenodeURL": notFound.URL + "," + second.URL, "path": "/ingest/landing"}, map[string]string{})
+	_, err := scaler.GetMetrics(context.Background(), "s0-webhdfs", nil)
+	if err == nil || !strings.Contains(err.Error(), "FileNotFoundException") {
+		t.Errorf("Expected FileNotFoundException but got %v", err)
+	}
+	if secondRequests != 0 {
+		t.Errorf("Expected no failover, got %d requests to the second namenode", secondRequests)
+	}
+
+	// all namenodes unreachable
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachableURL := unreachable.URL
+	unreachable.Close()
+	scaler = newTestWebHDFSScaler(t, map[string]string{"namenodeURL": unreachableURL, "path": "/ingest/landing"}, map[string]string{})
+	if _, err := scaler.IsActive(context.Background()); err == nil {
+		t.Error("Expected error but got success")
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"time"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+)
+
+const (
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitInitialBackoff   = 30 * time.Second
+	defaultCircuitMaxBackoff       = 10 * time.Minute
+)
+
+// circuitBreakerConfig is the CircuitBreaker of a ScaledObject with the defaults applied
+type circuitBreakerConfig struct {
+	failureThreshold int32
+	initialBackoff   time.Duration
+	maxBackoff       time.Duration
+}
+
+// circuitBreaker tracks the consecutive failures of a trigger. Once they reach the failure threshold, the circuit
+// opens and the trigger isn't evaluated until the backoff expires, then a single probe evaluation closes the
+// circuit on success or opens it again for twice as long on failure
+type circuitBreaker struct {
+	state     kedav1alpha1.CircuitState
+	failures  int32
+	backoff   time.Duration
+	openUntil time.Time
+}
+
+// newCircuitBreakerConfig returns the circuit breaker config of the ScaledObject, nil when it has none
+func newCircuitBreakerConfig(scaledObject *kedav1alpha1.ScaledObject) *circuitBreakerConfig {
+	if scaledObject.Spec.Advanced == nil || scaledObject.Spec.Advanced.CircuitBreaker == nil {
+		return nil
+	}
+	spec := scaledObject.Spec.Advanced.CircuitBreaker
+
+	config := &circuitBreakerConfig{
+		failureThreshold: defaultCircuitFailureThreshold,
+		initialBackoff:   defaultCircuitInitialBackoff,
+		maxBackoff:       defaultCircuitMaxBackoff,
+	}
+	if spec.FailureThreshold != nil && *spec.FailureThreshold > 0 {
+		config.failureThreshold = *spec.FailureThreshold
+	}
+	if spec.InitialBackoffSeconds != nil && *spec.InitialBackoffSeconds > 0 {
+		config.initialBackoff = time.Duration(*spec.InitialBackoffSeconds) * time.Second
+	}
+	if spec.MaxBackoffSeconds != nil && *spec.MaxBackoffSeconds > 0 {
+		config.maxBackoff = time.Duration(*spec.MaxBackoffSeconds) * time.Second
+	}
+	if config.initialBackoff > config.maxBackoff {
+		config.initialBackoff = config.maxBackoff
+	}
+	return config
+}
+
+// allow tells whether the trigger may be evaluated, an open circuit whose backoff expired becomes half-open
+// and lets the probe evaluation through
+func (b *circuitBreaker) allow(now time.Time) bool {
+	if b.state != kedav1alpha1.CircuitStateOpen {
+		return true
+	}
+	if now.Before(b.openUntil) {
+		return false
+	}
+	b.state = kedav1alpha1.CircuitStateHalfOpen
+	return true
+}
+
+// recordSuccess closes the circuit, it returns whether the circuit wasn't closed before
+func (b *circuitBreaker) recordSuccess() bool {
+	wasOpen := b.state == kedav1alpha1.CircuitStateOpen || b.state == kedav1alpha1.CircuitStateHalfOpen
+	*b = circuitBreaker{state: kedav1alpha1.CircuitStateClosed}
+	return wasOpen
+}
+
+// recordFailure counts a failed evaluation, it returns whether the circuit opened because of it
+func (b *circuitBreaker) recordFailure(now time.Time, config *circuitBreakerConfig) bool {
+	switch b.state {
+	case kedav1alpha1.CircuitStateHalfOpen:
+		// the probe failed, back off for longer
+		b.backoff *= 2
+		if b.backoff > config.maxBackoff {
+			b.backoff = config.maxBackoff
+		}
+	default:
+		b.failures++
+		if b.failures < config.failureThreshold {
+			b.state = kedav1alpha1.CircuitStateClosed
+			return false
+		}
+		b.backoff = config.initialBackoff
+	}
+
+	b.state = kedav1alpha1.CircuitStateOpen
+	b.openUntil = now.Add(b.backoff)
+	return true
+}
@@ -0,0 +1,267 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	mock_scalers "github.com/kedacore/keda/v2/pkg/mock/mock_scaler"
+	"github.com/kedacore/keda/v2/pkg/scalers"
+)
+
+func createCircuitBreakerScaledObject(failureThreshold, initialBackoffSeconds, maxBackoffSeconds int32) *kedav1alpha1.ScaledObject {
+	return &kedav1alpha1.ScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: kedav1alpha1.ScaledObjectSpec{
+			ScaleTargetRef: &kedav1alpha1.ScaleTarget{
+				Name: "test",
+			},
+			Advanced: &kedav1alpha1.AdvancedConfig{
+				CircuitBreaker: &kedav1alpha1.CircuitBreaker{
+					FailureThreshold:      &failureThreshold,
+					InitialBackoffSeconds: &initialBackoffSeconds,
+					MaxBackoffSeconds:     &maxBackoffSeconds,
+				},
+			},
+		},
+	}
+}
+
+func TestNewCircuitBreakerConfig(t *testing.T) {
+	scaledObject := createCircuitBreakerScaledObject(2, 60, 90)
+	config := newCircuitBreakerConfig(scaledObject)
+	assert.Equal(t, &circuitBreakerConfig{failureThreshold: 2, initialBackoff: time.Minute, maxBackoff: 90 * time.Second}, config)
+
+	scaledObject.Spec.Advanced.CircuitBreaker = &kedav1alpha1.CircuitBreaker{}
+	config = newCircuitBreakerConfig(scaledObject)
+	assert.Equal(t, &circuitBreakerConfig{failureThreshold: defaultCircuitFailureThreshold, initialBackoff: defaultCircuitInitialBackoff, maxBackoff: defaultCircuitMaxBackoff}, config)
+
+	// the initial backoff can't exceed the max one
+	config = newCircuitBreakerConfig(createCircuitBreakerScaledObject(2, 900, 300))
+	assert.Equal(t, 300*time.Second, config.initialBackoff)
+
+	scaledObject.Spec.Advanced.CircuitBreaker = nil
+	assert.Nil(t, newCircuitBreakerConfig(scaledObject))
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	recorder := record.NewFakeRecorder(20)
+
+	failing := true
+	calls := 0
+	scaler := mock_scalers.NewMockScaler(ctrl)
+	scaler.EXPECT().IsActive(gomock.Any()).AnyTimes().DoAndReturn(func(context.Context) (bool, error) {
+		calls++
+		if failing {
+			return false, errors.New("dial tcp: lookup dead-host: no such host")
+		}
+		return false, nil
+	})
+	scaler.EXPECT().Close(gomock.Any()).AnyTimes()
+	factory := func() (scalers.Scaler, error) {
+		return scaler, nil
+	}
+
+	now := time.Now()
+	cache := ScalersCache{
+		Scalers: []ScalerBuilder{{
+			Scaler:      scaler,
+			Factory:     factory,
+			TriggerName: "queue",
+			TriggerType: "rabbitmq",
+		}},
+		Logger:   logr.Discard(),
+		Recorder: recorder,
+		now:      func() time.Time { return now },
+	}
+	scaledObject := createCircuitBreakerScaledObject(2, 30, 60)
+
+	evaluate := func() (bool, TriggerEvaluation) {
+		calls = 0
+		_, isError, _ := cache.IsScaledObjectActive(context.Background(), scaledObject)
+		return isError, cache.GetTriggerEvaluations()[0]
+	}
+
+	// closed, the failures are counted until the threshold
+	isError, evaluation := evaluate()
+	assert.True(t, isError)
+	assert.Equal(t, 2, calls, "the scaler is refreshed and retried")
+	assert.Equal(t, kedav1alpha1.CircuitStateClosed, evaluation.CircuitState)
+
+	isError, evaluation = evaluate()
+	assert.True(t, isError)
+	assert.Equal(t, kedav1alpha1.CircuitStateOpen, evaluation.CircuitState)
+	assert.Equal(t, now.Add(30*time.Second), evaluation.CircuitOpenUntil)
+
+	// open, the scaler isn't called while the backoff lasts but the trigger is still failing for the fallback
+	now = now.Add(29 * time.Second)
+	isError, evaluation = evaluate()
+	assert.True(t, isError)
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, kedav1alpha1.CircuitStateOpen, evaluation.CircuitState)
+
+	// half-open, the probe fails and the backoff doubles
+	now = now.Add(time.Second)
+	isError, evaluation = evaluate()
+	assert.True(t, isError)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, kedav1alpha1.CircuitStateOpen, evaluation.CircuitState)
+	assert.Equal(t, now.Add(60*time.Second), evaluation.CircuitOpenUntil)
+
+	// the backoff is capped
+	now = now.Add(60 * time.Second)
+	_, evaluation = evaluate()
+	assert.Equal(t, now.Add(60*time.Second), evaluation.CircuitOpenUntil)
+
+	// half-open, the probe succeeds and the circuit closes immediately
+	failing = false
+	now = now.Add(60 * time.Second)
+	isError, evaluation = evaluate()
+	assert.False(t, isError)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, kedav1alpha1.CircuitStateClosed, evaluation.CircuitState)
+
+	// closed again, a single failure doesn't open it
+	failing = true
+	_, evaluation = evaluate()
+	assert.Equal(t, kedav1alpha1.CircuitStateClosed, evaluation.CircuitState)
+
+	cache.Close(context.Background())
+}
+
+func TestCircuitBreakerDisabledWithoutConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	scaler := mock_scalers.NewMockScaler(ctrl)
+	scaler.EXPECT().IsActive(gomock.Any()).Times(20).Return(false, errors.New("some error"))
+	scaler.EXPECT().Close(gomock.Any()).AnyTimes()
+
+	cache := ScalersCache{
+		Scalers: []ScalerBuilder{{
+			Scaler:  scaler,
+			Factory: func() (scalers.Scaler, error) { return scaler, nil },
+		}},
+		Logger:   logr.Discard(),
+		Recorder: record.NewFakeRecorder(20),
+	}
+	scaledObject := createCircuitBreakerScaledObject(1, 30, 60)
+	scaledObject.Spec.Advanced = nil
+
+	for i := 0; i < 10; i++ {
+		_, isError, _ := cache.IsScaledObjectActive(context.Background(), scaledObject)
+		assert.True(t, isError)
+		assert.Empty(t, cache.GetTriggerEvaluations()[0].CircuitState)
+	}
+}
+
+func TestCircuitBreakerGatesMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	calls := 0
+	scaler := mock_scalers.NewMockScaler(ctrl)
+	scaler.EXPECT().GetMetrics(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(context.Context, string, labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+		calls++
+		return nil, errors.New("dial tcp: lookup dead-host: no such host")
+	})
+	scaler.EXPECT().Close(gomock.Any()).AnyTimes()
+
+	now := time.Now()
+	cache := ScalersCache{
+		Scalers: []ScalerBuilder{{
+			Scaler:      scaler,
+			Factory:     func() (scalers.Scaler, error) { return scaler, nil },
+			TriggerName: "queue",
+			TriggerType: "rabbitmq",
+		}},
+		Logger:   logr.Discard(),
+		Recorder: record.NewFakeRecorder(20),
+		now:      func() time.Time { return now },
+	}
+	scaledObject := createCircuitBreakerScaledObject(2, 30, 60)
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetMetricsForScaler(context.Background(), scaledObject, 0, "metric", nil)
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, ErrCircuitOpen))
+	}
+	assert.Equal(t, 4, calls, "the scaler is refreshed and retried")
+
+	// the circuit opened on the metrics, the scaler isn't called anymore for the metrics nor for the activity
+	calls = 0
+	_, err := cache.GetMetricsForScaler(context.Background(), scaledObject, 0, "metric", nil)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	_, isError, _ := cache.IsScaledObjectActive(context.Background(), scaledObject)
+	assert.True(t, isError)
+	assert.Equal(t, kedav1alpha1.CircuitStateOpen, cache.GetTriggerEvaluations()[0].CircuitState)
+	assert.Equal(t, 0, calls)
+
+	// without a circuit breaker, the scaler is always called
+	scaledObject.Spec.Advanced = nil
+	_, err = cache.GetMetricsForScaler(context.Background(), scaledObject, 0, "metric", nil)
+	assert.False(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, 2, calls)
+}
+
+func TestCircuitBreakerNotLockedDuringEvaluation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	evaluating := make(chan struct{})
+	release := make(chan struct{})
+	slow := mock_scalers.NewMockScaler(ctrl)
+	slow.EXPECT().IsActive(gomock.Any()).DoAndReturn(func(context.Context) (bool, error) {
+		close(evaluating)
+		<-release
+		return false, nil
+	})
+	slow.EXPECT().GetMetricSpecForScaling(gomock.Any()).AnyTimes()
+	fast := mock_scalers.NewMockScaler(ctrl)
+	fast.EXPECT().IsActive(gomock.Any()).Return(false, nil)
+	fast.EXPECT().GetMetrics(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+
+	cache := ScalersCache{
+		Scalers: []ScalerBuilder{
+			{Scaler: slow, Factory: func() (scalers.Scaler, error) { return slow, nil }},
+			{Scaler: fast, Factory: func() (scalers.Scaler, error) { return fast, nil }},
+		},
+		Logger:   logr.Discard(),
+		Recorder: record.NewFakeRecorder(20),
+	}
+	scaledObject := createCircuitBreakerScaledObject(2, 30, 60)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.IsScaledObjectActive(context.Background(), scaledObject)
+	}()
+	<-evaluating
+
+	// the metrics of another trigger don't wait for the evaluation of the slow one
+	metricsDone := make(chan error)
+	go func() {
+		_, err := cache.GetMetricsForScaler(context.Background(), scaledObject, 1, "metric", nil)
+		metricsDone <- err
+	}()
+	select {
+	case err := <-metricsDone:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Error("the metrics waited for the evaluation of another trigger")
+	}
+
+	close(release)
+	<-done
+}
@@ -18,7 +18,10 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
@@ -32,16 +35,53 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// DefaultScalerCloseTimeout is how long closing a scaler may take when ScalersCache.CloseTimeout isn't set
+const DefaultScalerCloseTimeout = 5 * time.Second
+
+// ErrCircuitOpen is returned for the metrics of a trigger while its circuit is open
+var ErrCircuitOpen = errors.New("circuit of the trigger is open")
+
 type ScalersCache struct {
 	Generation int64
 	Scalers    []ScalerBuilder
 	Logger     logr.Logger
 	Recorder   record.EventRecorder
+	// CloseTimeout bounds how long closing a scaler may block, e.g. on a remote endpoint that doesn't answer
+	CloseTimeout time.Duration
+
+	evaluationsLock sync.Mutex
+	evaluations     []TriggerEvaluation
+
+	// breakers are the circuit breakers of the scalers by index, they're only used with a CircuitBreaker config
+	breakersLock sync.Mutex
+	breakers     map[int]*circuitBreaker
+	// now returns the current time of the circuit breakers, time.Now when not set
+	now func() time.Time
 }
 
 type ScalerBuilder struct {
-	Scaler  scalers.Scaler
-	Factory func() (scalers.Scaler, error)
+	Scaler      scalers.Scaler
+	Factory     func() (scalers.Scaler, error)
+	TriggerName string
+	TriggerType string
+}
+
+// TriggerEvaluation describes the last IsActive call made for a trigger
+type TriggerEvaluation struct {
+	TriggerName string
+	TriggerType string
+	Time        time.Time
+	Duration    time.Duration
+	// CircuitState is only set when the ScaledObject has a CircuitBreaker, no IsActive call was made while it's Open
+	CircuitState     kedav1alpha1.CircuitState
+	CircuitOpenUntil time.Time
+}
+
+// MetricTarget is the current value of an external metric of a trigger along with the target of the metric
+type MetricTarget struct {
+	MetricName string
+	Value      float64
+	Target     v2beta2.MetricTarget
 }
 
 func (c *ScalersCache) GetScalers() []scalers.Scaler {
@@ -62,10 +102,28 @@ func (c *ScalersCache) GetPushScalers() []scalers.PushScaler {
 	return result
 }
 
-func (c *ScalersCache) GetMetricsForScaler(ctx context.Context, id int, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+// GetMetricsForScaler returns the metrics of a scaler, while the circuit of the trigger is open the scaler isn't called
+// and ErrCircuitOpen is returned
+func (c *ScalersCache) GetMetricsForScaler(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject, id int, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
 	if id < 0 || id >= len(c.Scalers) {
 		return nil, fmt.Errorf("scaler with id %d not found. Len = %d", id, len(c.Scalers))
 	}
+
+	breakerConfig := newCircuitBreakerConfig(scaledObject)
+	if breakerConfig != nil {
+		if allowed, _, openUntil := c.allowCircuit(id); !allowed {
+			return nil, fmt.Errorf("%w until %s", ErrCircuitOpen, openUntil.Format(time.RFC3339))
+		}
+	}
+
+	m, err := c.getMetricsForScaler(ctx, id, metricName, metricSelector)
+	if breakerConfig != nil {
+		c.recordCircuitResult(scaledObject, id, breakerConfig, err)
+	}
+	return m, err
+}
+
+func (c *ScalersCache) getMetricsForScaler(ctx context.Context, id int, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
 	m, err := c.Scalers[id].Scaler.GetMetrics(ctx, metricName, metricSelector)
 	if err == nil {
 		return m, nil
@@ -82,19 +140,53 @@ func (c *ScalersCache) GetMetricsForScaler(ctx context.Context, id int, metricNa
 func (c *ScalersCache) IsScaledObjectActive(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject) (bool, bool, []external_metrics.ExternalMetricValue) {
 	isActive := false
 	isError := false
+	evaluations := make([]TriggerEvaluation, 0, len(c.Scalers))
+	breakerConfig := newCircuitBreakerConfig(scaledObject)
+
 	// Let's collect status of all scalers, no matter if any scaler raises error or is active
 	for i, s := range c.Scalers {
+		logger := c.Logger.WithValues("scaledobject.Name", scaledObject.Name, "scaledObject.Namespace", scaledObject.Namespace,
+			"scaleTarget.Name", scaledObject.Spec.ScaleTargetRef.Name)
+
+		if breakerConfig != nil {
+			if allowed, state, openUntil := c.allowCircuit(i); !allowed {
+				// the trigger is failing as far as the fallback is concerned, without waiting for it to fail again
+				isError = true
+				logger.V(1).Info("Circuit of the trigger is open, skipping its evaluation", "triggerName", s.TriggerName, "triggerType", s.TriggerType, "openUntil", openUntil)
+				evaluations = append(evaluations, TriggerEvaluation{
+					TriggerName:      s.TriggerName,
+					TriggerType:      s.TriggerType,
+					Time:             time.Now(),
+					CircuitState:     state,
+					CircuitOpenUntil: openUntil,
+				})
+				continue
+			}
+		}
+
+		// only the time spent in the scaler is measured, refreshing the scaler isn't part of the evaluation
+		evaluationTime := time.Now()
 		isTriggerActive, err := s.Scaler.IsActive(ctx)
+		duration := time.Since(evaluationTime)
 		if err != nil {
 			var ns scalers.Scaler
 			ns, err = c.refreshScaler(ctx, i)
 			if err == nil {
+				retryTime := time.Now()
 				isTriggerActive, err = ns.IsActive(ctx)
+				duration += time.Since(retryTime)
 			}
 		}
-
-		logger := c.Logger.WithValues("scaledobject.Name", scaledObject.Name, "scaledObject.Namespace", scaledObject.Namespace,
-			"scaleTarget.Name", scaledObject.Spec.ScaleTargetRef.Name)
+		evaluation := TriggerEvaluation{
+			TriggerName: s.TriggerName,
+			TriggerType: s.TriggerType,
+			Time:        evaluationTime,
+			Duration:    duration,
+		}
+		if breakerConfig != nil {
+			evaluation.CircuitState, evaluation.CircuitOpenUntil = c.recordCircuitResult(scaledObject, i, breakerConfig, err)
+		}
+		evaluations = append(evaluations, evaluation)
 
 		if err != nil {
 			isError = true
@@ -111,9 +203,78 @@ func (c *ScalersCache) IsScaledObjectActive(ctx context.Context, scaledObject *k
 		}
 	}
 
+	c.evaluationsLock.Lock()
+	c.evaluations = evaluations
+	c.evaluationsLock.Unlock()
+
 	return isActive, isError, []external_metrics.ExternalMetricValue{}
 }
 
+// getCircuitBreaker returns the circuit breaker of the scaler, c.breakersLock must be held
+func (c *ScalersCache) getCircuitBreaker(id int) *circuitBreaker {
+	if c.breakers == nil {
+		c.breakers = map[int]*circuitBreaker{}
+	}
+	breaker, ok := c.breakers[id]
+	if !ok {
+		breaker = &circuitBreaker{state: kedav1alpha1.CircuitStateClosed}
+		c.breakers[id] = breaker
+	}
+	return breaker
+}
+
+// allowCircuit tells whether the scaler may be called according to its circuit breaker, along with the state of the
+// circuit. The lock is only held for the breaker, not while the scaler is called
+func (c *ScalersCache) allowCircuit(id int) (bool, kedav1alpha1.CircuitState, time.Time) {
+	c.breakersLock.Lock()
+	defer c.breakersLock.Unlock()
+
+	breaker := c.getCircuitBreaker(id)
+	allowed := breaker.allow(c.currentTime())
+	return allowed, breaker.state, breaker.openUntil
+}
+
+// recordCircuitResult updates the circuit breaker of the scaler with the result of its call and records
+// an event when the circuit opens or closes, it returns the state of the circuit afterwards
+func (c *ScalersCache) recordCircuitResult(scaledObject *kedav1alpha1.ScaledObject, id int, config *circuitBreakerConfig, err error) (kedav1alpha1.CircuitState, time.Time) {
+	sb := c.Scalers[id]
+
+	c.breakersLock.Lock()
+	breaker := c.getCircuitBreaker(id)
+	var closed, opened bool
+	if err == nil {
+		closed = breaker.recordSuccess()
+	} else {
+		opened = breaker.recordFailure(c.currentTime(), config)
+	}
+	state, openUntil, failures, backoff := breaker.state, breaker.openUntil, breaker.failures, breaker.backoff
+	c.breakersLock.Unlock()
+
+	switch {
+	case closed:
+		c.Recorder.Eventf(scaledObject, corev1.EventTypeNormal, eventreason.KEDAScalerCircuitClosed,
+			"Circuit of trigger %s (%s) closed, the trigger is evaluated again", sb.TriggerName, sb.TriggerType)
+	case opened:
+		c.Recorder.Eventf(scaledObject, corev1.EventTypeWarning, eventreason.KEDAScalerCircuitOpened,
+			"Circuit of trigger %s (%s) opened after %d consecutive failures, probing it again in %s", sb.TriggerName, sb.TriggerType, failures, backoff)
+	}
+	return state, openUntil
+}
+
+func (c *ScalersCache) currentTime() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// GetTriggerEvaluations returns the evaluations made by the last IsScaledObjectActive call
+func (c *ScalersCache) GetTriggerEvaluations() []TriggerEvaluation {
+	c.evaluationsLock.Lock()
+	defer c.evaluationsLock.Unlock()
+	return append([]TriggerEvaluation{}, c.evaluations...)
+}
+
 func (c *ScalersCache) IsScaledJobActive(ctx context.Context, scaledJob *kedav1alpha1.ScaledJob) (bool, int64, int64) {
 	var queueLength int64
 	var maxValue int64
@@ -189,6 +350,33 @@ func (c *ScalersCache) GetMetrics(ctx context.Context, metricName string, metric
 	return metrics, nil
 }
 
+// GetExternalMetricTargets returns the current value of the external metrics of all the triggers with their targets,
+// the metrics that can't be read are left out
+func (c *ScalersCache) GetExternalMetricTargets(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject) []MetricTarget {
+	var targets []MetricTarget
+	for i := range c.Scalers {
+		for _, spec := range c.Scalers[i].Scaler.GetMetricSpecForScaling(ctx) {
+			if spec.External == nil {
+				continue
+			}
+
+			metricName := spec.External.Metric.Name
+			metrics, err := c.GetMetricsForScaler(ctx, scaledObject, i, metricName, nil)
+			if err != nil {
+				c.Logger.Error(err, "error getting metric", "metricName", metricName)
+				continue
+			}
+
+			target := MetricTarget{MetricName: metricName, Target: spec.External.Target}
+			for _, m := range metrics {
+				target.Value += m.Value.AsApproximateFloat64()
+			}
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
 func (c *ScalersCache) refreshScaler(ctx context.Context, id int) (scalers.Scaler, error) {
 	if id < 0 || id >= len(c.Scalers) {
 		return nil, fmt.Errorf("scaler with id %d not found. Len = %d", id, len(c.Scalers))
@@ -201,10 +389,12 @@ func (c *ScalersCache) refreshScaler(ctx context.Context, id int) (scalers.Scale
 	}
 
 	c.Scalers[id] = ScalerBuilder{
-		Scaler:  ns,
-		Factory: sb.Factory,
+		Scaler:      ns,
+		Factory:     sb.Factory,
+		TriggerName: sb.TriggerName,
+		TriggerType: sb.TriggerType,
 	}
-	sb.Scaler.Close(ctx)
+	c.closeScaler(sb)
 
 	return ns, nil
 }
@@ -217,14 +407,45 @@ func (c *ScalersCache) GetMetricSpecForScaling(ctx context.Context) []v2beta2.Me
 	return spec
 }
 
+// Close closes the scalers concurrently, it returns at the latest after CloseTimeout
 func (c *ScalersCache) Close(ctx context.Context) {
 	scalers := c.Scalers
 	c.Scalers = nil
+
+	wg := sync.WaitGroup{}
 	for _, s := range scalers {
-		err := s.Scaler.Close(ctx)
+		wg.Add(1)
+		go func(sb ScalerBuilder) {
+			defer wg.Done()
+			c.closeScaler(sb)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// closeScaler closes the scaler and gives up after CloseTimeout, a scaler still closing is left behind.
+// The context passed to Close isn't derived from the caller's one, which is usually canceled already when
+// the scalers are torn down, so the scalers whose client supports it get the whole timeout to close gracefully
+func (c *ScalersCache) closeScaler(sb ScalerBuilder) {
+	timeout := c.CloseTimeout
+	if timeout <= 0 {
+		timeout = DefaultScalerCloseTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- sb.Scaler.Close(ctx)
+	}()
+
+	select {
+	case err := <-done:
 		if err != nil {
-			c.Logger.Error(err, "error closing scaler", "scaler", s)
+			c.Logger.Error(err, "error closing scaler", "triggerName", sb.TriggerName, "triggerType", sb.TriggerType)
 		}
+	case <-ctx.Done():
+		c.Logger.Info("Scaler didn't close in time, continuing without waiting for it", "triggerName", sb.TriggerName, "triggerType", sb.TriggerType, "timeout", timeout)
 	}
 }
 
@@ -241,7 +462,7 @@ func (c *ScalersCache) getScaledJobMetrics(ctx context.Context, scaledJob *kedav
 		var targetAverageValue int64
 		isActive := false
 		maxValue := int64(0)
-		scalerType := fmt.Sprintf("%T:", s)
+		scalerType := fmt.Sprintf("%T:", scalers.UnwrapScaler(s.Scaler))
 
 		scalerLogger := c.Logger.WithValues("ScaledJob", scaledJob.Name, "Scaler", scalerType)
 
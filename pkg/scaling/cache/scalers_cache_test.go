@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/golang/mock/gomock"
@@ -247,3 +248,46 @@ func createScaler(ctrl *gomock.Controller, queueLength int64, averageValue int64
 	scaler.EXPECT().Close(gomock.Any())
 	return scaler
 }
+
+func TestCloseIsBoundedByCloseTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// the first scaler hangs until its context is canceled, like a client waiting on an unreachable endpoint,
+	// the second one never returns at all
+	hanging := mock_scalers.NewMockScaler(ctrl)
+	hanging.EXPECT().Close(gomock.Any()).DoAndReturn(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	blocked := make(chan struct{})
+	defer close(blocked)
+	stuck := mock_scalers.NewMockScaler(ctrl)
+	stuck.EXPECT().Close(gomock.Any()).DoAndReturn(func(ctx context.Context) error {
+		<-blocked
+		return nil
+	})
+	closed := mock_scalers.NewMockScaler(ctrl)
+	closed.EXPECT().Close(gomock.Any()).Return(nil)
+
+	cache := ScalersCache{
+		Scalers: []ScalerBuilder{
+			{Scaler: hanging, TriggerName: "hanging"},
+			{Scaler: stuck, TriggerName: "stuck"},
+			{Scaler: closed, TriggerName: "closed"},
+		},
+		Logger:       logr.Discard(),
+		CloseTimeout: 100 * time.Millisecond,
+	}
+
+	// the caller's context is canceled already on shutdown, it mustn't cut the close short
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	cache.Close(ctx)
+	elapsed := time.Since(start)
+
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+	assert.Less(t, elapsed, time.Second, "the scalers must be closed concurrently and given up after the timeout")
+	assert.Empty(t, cache.Scalers)
+}
@@ -29,17 +29,16 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	kedacontrollerutil "github.com/kedacore/keda/v2/controllers/keda/util"
+	"github.com/kedacore/keda/v2/pkg/scaling/cache"
 )
 
-const (
-	// Default cooldown period for a ScaleTarget if no cooldownPeriod is defined on the scaledObject
-	defaultCooldownPeriod = 5 * 60 // 5 minutes
-)
-
-// ScaleExecutor contains methods RequestJobScale and RequestScale
+// ScaleExecutor contains methods RequestJobScale, RequestScale and ReportScale. The trigger evaluations given to
+// RequestScale and ReportScale are written with their status updates of the ScaledObject, nil leaves them as they are
 type ScaleExecutor interface {
 	RequestJobScale(ctx context.Context, scaledJob *kedav1alpha1.ScaledJob, isActive bool, scaleTo int64, maxScale int64)
-	RequestScale(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject, isActive bool, isError bool)
+	RequestScale(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject, isActive bool, isError bool, triggerEvaluations map[string]kedav1alpha1.TriggerEvaluationStatus)
+	ReportScale(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject, isActive bool, isError bool, metrics []cache.MetricTarget, triggerEvaluations map[string]kedav1alpha1.TriggerEvaluationStatus)
 }
 
 type scaleExecutor struct {
@@ -130,3 +129,59 @@ func (e *scaleExecutor) setFallbackCondition(ctx context.Context, logger logr.Lo
 	}
 	return e.setCondition(ctx, logger, object, status, reason, message, fallback)
 }
+
+// withTriggerEvaluations returns the executor writing the trigger evaluations with the first status patch of the
+// ScaledObject, so a poll doesn't patch the status once more for them. The returned func patches them on their own
+// when nothing else of the status changed, it must be called once the ScaledObject is scaled
+func (e *scaleExecutor) withTriggerEvaluations(ctx context.Context, logger logr.Logger, scaledObject *kedav1alpha1.ScaledObject,
+	triggerEvaluations map[string]kedav1alpha1.TriggerEvaluationStatus) (*scaleExecutor, func()) {
+	if triggerEvaluations == nil {
+		return e, func() {}
+	}
+
+	client := &triggerEvaluationsClient{Client: e.client, triggerEvaluations: triggerEvaluations}
+	batched := *e
+	batched.client = client
+	return &batched, func() {
+		if client.written {
+			return
+		}
+		if err := kedacontrollerutil.UpdateScaledObjectStatus(ctx, client, logger, scaledObject, scaledObject.Status.DeepCopy()); err != nil {
+			logger.Error(err, "Error updating trigger evaluations")
+		}
+	}
+}
+
+// triggerEvaluationsClient adds the trigger evaluations to the first status patch of a ScaledObject
+type triggerEvaluationsClient struct {
+	runtimeclient.Client
+	triggerEvaluations map[string]kedav1alpha1.TriggerEvaluationStatus
+	written            bool
+}
+
+func (c *triggerEvaluationsClient) Status() runtimeclient.StatusWriter {
+	return &triggerEvaluationsStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type triggerEvaluationsStatusWriter struct {
+	runtimeclient.StatusWriter
+	client *triggerEvaluationsClient
+}
+
+// Patch sets the trigger evaluations before the merge patch is computed from the ScaledObject, they are reverted
+// if the patch fails so the next one carries them
+func (w *triggerEvaluationsStatusWriter) Patch(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+	scaledObject, ok := obj.(*kedav1alpha1.ScaledObject)
+	if !ok || w.client.written {
+		return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+	}
+
+	previous := scaledObject.Status.TriggerEvaluations
+	scaledObject.Status.TriggerEvaluations = w.client.triggerEvaluations
+	if err := w.StatusWriter.Patch(ctx, obj, patch, opts...); err != nil {
+		scaledObject.Status.TriggerEvaluations = previous
+		return err
+	}
+	w.client.written = true
+	return nil
+}
@@ -41,17 +41,6 @@ const (
 func (e *scaleExecutor) RequestJobScale(ctx context.Context, scaledJob *kedav1alpha1.ScaledJob, isActive bool, scaleTo int64, maxScale int64) {
 	logger := e.logger.WithValues("scaledJob.Name", scaledJob.Name, "scaledJob.Namespace", scaledJob.Namespace)
 
-	runningJobCount := e.getRunningJobCount(ctx, scaledJob)
-	pendingJobCount := e.getPendingJobCount(ctx, scaledJob)
-	logger.Info("Scaling Jobs", "Number of running Jobs", runningJobCount)
-	logger.Info("Scaling Jobs", "Number of pending Jobs ", pendingJobCount)
-
-	effectiveMaxScale := NewScalingStrategy(logger, scaledJob).GetEffectiveMaxScale(maxScale, runningJobCount, pendingJobCount, scaledJob.MaxReplicaCount())
-
-	if effectiveMaxScale < 0 {
-		effectiveMaxScale = 0
-	}
-
 	if isActive {
 		logger.V(1).Info("At least one scaler is active")
 		now := metav1.Now()
@@ -60,11 +49,21 @@ func (e *scaleExecutor) RequestJobScale(ctx context.Context, scaledJob *kedav1al
 		if err != nil {
 			logger.Error(err, "Failed to update last active time")
 		}
-		e.createJobs(ctx, logger, scaledJob, scaleTo, effectiveMaxScale)
 	} else {
 		logger.V(1).Info("No change in activity")
 	}
 
+	switch {
+	case scaledJob.IsReportOnly():
+		e.reportJobScale(ctx, logger, scaledJob, isActive, scaleTo, maxScale)
+	case scaledJob.Spec.ScalingStrategy.Strategy == parallelismScalingStrategy:
+		e.clearReportedJobScale(ctx, logger, scaledJob)
+		e.scaleParallelismJob(ctx, logger, scaledJob, isActive, scaleTo, maxScale)
+	default:
+		e.clearReportedJobScale(ctx, logger, scaledJob)
+		e.scaleJobs(ctx, logger, scaledJob, isActive, scaleTo, maxScale)
+	}
+
 	condition := scaledJob.Status.Conditions.GetActiveCondition()
 	if condition.IsUnknown() || condition.IsTrue() != isActive {
 		if isActive {
@@ -86,13 +85,28 @@ func (e *scaleExecutor) RequestJobScale(ctx context.Context, scaledJob *kedav1al
 	}
 }
 
-func (e *scaleExecutor) createJobs(ctx context.Context, logger logr.Logger, scaledJob *kedav1alpha1.ScaledJob, scaleTo int64, maxScale int64) {
-	scaledJob.Spec.JobTargetRef.Template.GenerateName = scaledJob.GetName() + "-"
-	if scaledJob.Spec.JobTargetRef.Template.Labels == nil {
-		scaledJob.Spec.JobTargetRef.Template.Labels = map[string]string{}
+// scaleJobs creates a new Job for each unit of work that isn't handled by the running Jobs yet
+func (e *scaleExecutor) scaleJobs(ctx context.Context, logger logr.Logger, scaledJob *kedav1alpha1.ScaledJob, isActive bool, scaleTo int64, maxScale int64) {
+	// a Job left over from the parallelism strategy would keep running next to the Jobs created from now on
+	e.deleteParallelismJobs(ctx, logger, scaledJob)
+
+	runningJobCount := e.getRunningJobCount(ctx, scaledJob)
+	pendingJobCount := e.getPendingJobCount(ctx, scaledJob)
+	logger.Info("Scaling Jobs", "Number of running Jobs", runningJobCount)
+	logger.Info("Scaling Jobs", "Number of pending Jobs ", pendingJobCount)
+
+	effectiveMaxScale := NewScalingStrategy(logger, scaledJob).GetEffectiveMaxScale(maxScale, runningJobCount, pendingJobCount, scaledJob.MaxReplicaCount())
+
+	if effectiveMaxScale < 0 {
+		effectiveMaxScale = 0
 	}
-	scaledJob.Spec.JobTargetRef.Template.Labels["scaledjob.keda.sh/name"] = scaledJob.GetName()
 
+	if isActive {
+		e.createJobs(ctx, logger, scaledJob, scaleTo, effectiveMaxScale)
+	}
+}
+
+func (e *scaleExecutor) createJobs(ctx context.Context, logger logr.Logger, scaledJob *kedav1alpha1.ScaledJob, scaleTo int64, maxScale int64) {
 	logger.Info("Creating jobs", "Effective number of max jobs", maxScale)
 
 	if scaleTo > maxScale {
@@ -100,6 +114,26 @@ func (e *scaleExecutor) createJobs(ctx context.Context, logger logr.Logger, scal
 	}
 	logger.Info("Creating jobs", "Number of jobs", scaleTo)
 
+	for i := 0; i < int(scaleTo); i++ {
+		job := e.newJob(logger, scaledJob)
+
+		err := e.client.Create(ctx, job)
+		if err != nil {
+			logger.Error(err, "Failed to create a new Job")
+		}
+	}
+	logger.Info("Created jobs", "Number of jobs", scaleTo)
+	e.recorder.Eventf(scaledJob, corev1.EventTypeNormal, eventreason.KEDAJobsCreated, "Created %d jobs", scaleTo)
+}
+
+// newJob returns a Job created from the job template of the ScaledJob
+func (e *scaleExecutor) newJob(logger logr.Logger, scaledJob *kedav1alpha1.ScaledJob) *batchv1.Job {
+	scaledJob.Spec.JobTargetRef.Template.GenerateName = scaledJob.GetName() + "-"
+	if scaledJob.Spec.JobTargetRef.Template.Labels == nil {
+		scaledJob.Spec.JobTargetRef.Template.Labels = map[string]string{}
+	}
+	scaledJob.Spec.JobTargetRef.Template.Labels["scaledjob.keda.sh/name"] = scaledJob.GetName()
+
 	labels := map[string]string{
 		"app.kubernetes.io/name":       scaledJob.GetName(),
 		"app.kubernetes.io/version":    version.Version,
@@ -111,36 +145,29 @@ func (e *scaleExecutor) createJobs(ctx context.Context, logger logr.Logger, scal
 		labels[key] = value
 	}
 
-	for i := 0; i < int(scaleTo); i++ {
-		job := &batchv1.Job{
-			ObjectMeta: metav1.ObjectMeta{
-				GenerateName: scaledJob.GetName() + "-",
-				Namespace:    scaledJob.GetNamespace(),
-				Labels:       labels,
-			},
-			Spec: *scaledJob.Spec.JobTargetRef.DeepCopy(),
-		}
-
-		// Job doesn't allow RestartPolicyAlways, it seems like this value is set by the client as a default one,
-		// we should set this property to allowed value in that case
-		if job.Spec.Template.Spec.RestartPolicy == "" {
-			logger.V(1).Info("Job RestartPolicy is not set, setting it to 'OnFailure', to avoid setting it to the client's default value 'Always'")
-			job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyOnFailure
-		}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: scaledJob.GetName() + "-",
+			Namespace:    scaledJob.GetNamespace(),
+			Labels:       labels,
+		},
+		Spec: *scaledJob.Spec.JobTargetRef.DeepCopy(),
+	}
 
-		// Set ScaledJob instance as the owner and controller
-		err := controllerutil.SetControllerReference(scaledJob, job, e.reconcilerScheme)
-		if err != nil {
-			logger.Error(err, "Failed to set ScaledJob as the owner of the new Job")
-		}
+	// Job doesn't allow RestartPolicyAlways, it seems like this value is set by the client as a default one,
+	// we should set this property to allowed value in that case
+	if job.Spec.Template.Spec.RestartPolicy == "" {
+		logger.V(1).Info("Job RestartPolicy is not set, setting it to 'OnFailure', to avoid setting it to the client's default value 'Always'")
+		job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyOnFailure
+	}
 
-		err = e.client.Create(ctx, job)
-		if err != nil {
-			logger.Error(err, "Failed to create a new Job")
-		}
+	// Set ScaledJob instance as the owner and controller
+	err := controllerutil.SetControllerReference(scaledJob, job, e.reconcilerScheme)
+	if err != nil {
+		logger.Error(err, "Failed to set ScaledJob as the owner of the new Job")
 	}
-	logger.Info("Created jobs", "Number of jobs", scaleTo)
-	e.recorder.Eventf(scaledJob, corev1.EventTypeNormal, eventreason.KEDAJobsCreated, "Created %d jobs", scaleTo)
+
+	return job
 }
 
 func (e *scaleExecutor) isJobFinished(j *batchv1.Job) bool {
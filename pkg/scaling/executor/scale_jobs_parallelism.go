@@ -0,0 +1,211 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"github.com/kedacore/keda/v2/pkg/eventreason"
+)
+
+const (
+	// parallelismScalingStrategy runs a single Job per ScaledJob and scales its parallelism instead of creating more Jobs
+	parallelismScalingStrategy = "parallelism"
+
+	// scaledJobStrategyLabel marks the Jobs managed by the parallelism scaling strategy
+	scaledJobStrategyLabel = "scaledjob.keda.sh/strategy"
+	// scaledJobTemplateHashAnnotation holds the hash of the job template a Job was created from
+	scaledJobTemplateHashAnnotation = "scaledjob.keda.sh/template-hash"
+)
+
+// scaleParallelismJob keeps a single unfinished Job for the ScaledJob and patches its parallelism to the desired count.
+// When the triggers aren't active the Job is suspended, and when the job template changes the Job is recreated.
+// Jobs created by another scaling strategy before switching to this one are left to run to completion.
+func (e *scaleExecutor) scaleParallelismJob(ctx context.Context, logger logr.Logger, scaledJob *kedav1alpha1.ScaledJob, isActive bool, scaleTo int64, maxScale int64) {
+	var desiredParallelism int64
+	if isActive {
+		desiredParallelism = scaleTo
+		if desiredParallelism > maxScale {
+			desiredParallelism = maxScale
+		}
+		if desiredParallelism > scaledJob.MaxReplicaCount() {
+			desiredParallelism = scaledJob.MaxReplicaCount()
+		}
+	}
+
+	templateHash, err := getJobTemplateHash(scaledJob)
+	if err != nil {
+		logger.Error(err, "Failed to hash the job template")
+		return
+	}
+
+	jobs, err := e.getParallelismJobs(ctx, scaledJob)
+	if err != nil {
+		logger.Error(err, "Failed to list the Jobs of the ScaledJob")
+		return
+	}
+
+	var current *batchv1.Job
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Annotations[scaledJobTemplateHashAnnotation] != templateHash || current != nil {
+			// the template changed or, as a precaution, there is more than one Job
+			logger.Info("Replacing the parallelism Job", "job.Name", job.Name)
+			if err := e.deleteJob(ctx, job); err != nil {
+				logger.Error(err, "Failed to delete the parallelism Job", "job.Name", job.Name)
+				return
+			}
+			continue
+		}
+		current = job
+	}
+
+	if current == nil {
+		if desiredParallelism == 0 {
+			return
+		}
+		e.createParallelismJob(ctx, logger, scaledJob, templateHash, int32(desiredParallelism))
+		return
+	}
+
+	e.updateParallelismJob(ctx, logger, current, int32(desiredParallelism))
+}
+
+func (e *scaleExecutor) createParallelismJob(ctx context.Context, logger logr.Logger, scaledJob *kedav1alpha1.ScaledJob, templateHash string, parallelism int32) {
+	job := e.newJob(logger, scaledJob)
+	job.Labels[scaledJobStrategyLabel] = parallelismScalingStrategy
+	if job.Annotations == nil {
+		job.Annotations = map[string]string{}
+	}
+	job.Annotations[scaledJobTemplateHashAnnotation] = templateHash
+	job.Spec.Parallelism = &parallelism
+
+	// completions can't be changed once the Job exists, so when the template doesn't set it
+	// the Job is allowed to run up to maxReplicaCount pods before a new one is created
+	if job.Spec.Completions == nil {
+		completions := int32(scaledJob.MaxReplicaCount())
+		job.Spec.Completions = &completions
+	}
+
+	if err := e.client.Create(ctx, job); err != nil {
+		logger.Error(err, "Failed to create the parallelism Job")
+		return
+	}
+	logger.Info("Created parallelism Job", "parallelism", parallelism)
+	e.recorder.Eventf(scaledJob, corev1.EventTypeNormal, eventreason.KEDAJobsCreated, "Created job with parallelism %d", parallelism)
+}
+
+// updateParallelismJob patches the parallelism of the Job, a desired parallelism of 0 suspends the Job
+func (e *scaleExecutor) updateParallelismJob(ctx context.Context, logger logr.Logger, job *batchv1.Job, parallelism int32) {
+	patch := client.MergeFrom(job.DeepCopy())
+	suspend := parallelism == 0
+	changed := false
+
+	if job.Spec.Suspend == nil || *job.Spec.Suspend != suspend {
+		job.Spec.Suspend = &suspend
+		changed = true
+	}
+	// the parallelism is kept while the Job is suspended, so it resumes with the last known count
+	if !suspend && (job.Spec.Parallelism == nil || *job.Spec.Parallelism != parallelism) {
+		job.Spec.Parallelism = &parallelism
+		changed = true
+	}
+
+	if !changed {
+		return
+	}
+
+	if err := e.client.Patch(ctx, job, patch); err != nil {
+		logger.Error(err, "Failed to patch the parallelism Job", "job.Name", job.Name)
+		return
+	}
+	logger.Info("Scaled parallelism Job", "job.Name", job.Name, "parallelism", parallelism, "suspended", suspend)
+}
+
+// deleteParallelismJobs removes the unfinished Jobs of the parallelism strategy, used when the ScaledJob switched to another strategy
+func (e *scaleExecutor) deleteParallelismJobs(ctx context.Context, logger logr.Logger, scaledJob *kedav1alpha1.ScaledJob) {
+	jobs, err := e.getParallelismJobs(ctx, scaledJob)
+	if err != nil {
+		logger.Error(err, "Failed to list the Jobs of the ScaledJob")
+		return
+	}
+
+	for i := range jobs {
+		logger.Info("Deleting Job of the parallelism scaling strategy", "job.Name", jobs[i].Name)
+		if err := e.deleteJob(ctx, &jobs[i]); err != nil {
+			logger.Error(err, "Failed to delete the parallelism Job", "job.Name", jobs[i].Name)
+		}
+	}
+}
+
+// getParallelismJobs returns the unfinished Jobs of the ScaledJob created by the parallelism strategy
+func (e *scaleExecutor) getParallelismJobs(ctx context.Context, scaledJob *kedav1alpha1.ScaledJob) ([]batchv1.Job, error) {
+	opts := []client.ListOption{
+		client.InNamespace(scaledJob.GetNamespace()),
+		client.MatchingLabels(map[string]string{
+			"scaledjob.keda.sh/name": scaledJob.GetName(),
+			scaledJobStrategyLabel:   parallelismScalingStrategy,
+		}),
+	}
+
+	jobs := &batchv1.JobList{}
+	if err := e.client.List(ctx, jobs, opts...); err != nil {
+		return nil, err
+	}
+
+	var result []batchv1.Job
+	for _, job := range jobs.Items {
+		job := job
+		if !e.isJobFinished(&job) && job.DeletionTimestamp == nil {
+			result = append(result, job)
+		}
+	}
+	return result, nil
+}
+
+func (e *scaleExecutor) deleteJob(ctx context.Context, job *batchv1.Job) error {
+	deletePolicy := metav1.DeletePropagationBackground
+	return e.client.Delete(ctx, job, &client.DeleteOptions{
+		PropagationPolicy: &deletePolicy,
+	})
+}
+
+// getJobTemplateHash returns a hash of the job template of the ScaledJob, ignoring the fields set by KEDA
+func getJobTemplateHash(scaledJob *kedav1alpha1.ScaledJob) (string, error) {
+	spec := scaledJob.Spec.JobTargetRef.DeepCopy()
+	spec.Template.GenerateName = ""
+	delete(spec.Template.Labels, "scaledjob.keda.sh/name")
+
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write(b)
+	return fmt.Sprintf("%x", hash.Sum32()), nil
+}
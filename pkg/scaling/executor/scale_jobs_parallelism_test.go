@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+)
+
+func getParallelismScaleExecutor(t *testing.T) *scaleExecutor {
+	scheme := runtime.NewScheme()
+	if err := kedav1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := batchv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return &scaleExecutor{
+		client:           fake.NewClientBuilder().WithScheme(scheme).Build(),
+		reconcilerScheme: scheme,
+		logger:           logf.Log.WithName("scaleexecutor"),
+		recorder:         record.NewFakeRecorder(10),
+	}
+}
+
+func getMockScaledJobWithParallelism(maxReplicaCount int32) *kedav1alpha1.ScaledJob {
+	scaledJob := &kedav1alpha1.ScaledJob{
+		Spec: kedav1alpha1.ScaledJobSpec{
+			MaxReplicaCount: &maxReplicaCount,
+			ScalingStrategy: kedav1alpha1.ScalingStrategy{
+				Strategy: parallelismScalingStrategy,
+			},
+			JobTargetRef: &batchv1.JobSpec{
+				Template: v1.PodTemplateSpec{
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{{Name: "worker", Image: "worker:1"}},
+					},
+				},
+			},
+		},
+	}
+	scaledJob.ObjectMeta.Name = "parallel-consumer"
+	scaledJob.ObjectMeta.Namespace = "default"
+	return scaledJob
+}
+
+func listJobs(t *testing.T, client runtimeclient.Client) []batchv1.Job {
+	jobs := &batchv1.JobList{}
+	if err := client.List(context.Background(), jobs); err != nil {
+		t.Fatal(err)
+	}
+	return jobs.Items
+}
+
+func TestParallelismScalingStrategyCreatesAndPatchesJob(t *testing.T) {
+	ctx := context.Background()
+	executor := getParallelismScaleExecutor(t)
+	scaledJob := getMockScaledJobWithParallelism(10)
+	logger := executor.logger
+
+	executor.scaleParallelismJob(ctx, logger, scaledJob, true, 3, 100)
+	jobs := listJobs(t, executor.client)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, int32(3), *jobs[0].Spec.Parallelism)
+	assert.Equal(t, int32(10), *jobs[0].Spec.Completions)
+	assert.Equal(t, parallelismScalingStrategy, jobs[0].Labels[scaledJobStrategyLabel])
+
+	// the same Job is scaled, bounded by maxReplicaCount
+	executor.scaleParallelismJob(ctx, logger, scaledJob, true, 25, 100)
+	jobs = listJobs(t, executor.client)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, int32(10), *jobs[0].Spec.Parallelism)
+	assert.False(t, *jobs[0].Spec.Suspend)
+
+	// scaling to zero suspends the Job and keeps its parallelism
+	executor.scaleParallelismJob(ctx, logger, scaledJob, false, 0, 100)
+	jobs = listJobs(t, executor.client)
+	assert.Len(t, jobs, 1)
+	assert.True(t, *jobs[0].Spec.Suspend)
+	assert.Equal(t, int32(10), *jobs[0].Spec.Parallelism)
+
+	// and activating again resumes it
+	executor.scaleParallelismJob(ctx, logger, scaledJob, true, 2, 100)
+	jobs = listJobs(t, executor.client)
+	assert.Len(t, jobs, 1)
+	assert.False(t, *jobs[0].Spec.Suspend)
+	assert.Equal(t, int32(2), *jobs[0].Spec.Parallelism)
+}
+
+func TestParallelismScalingStrategyDoesNotCreateJobWhenInactive(t *testing.T) {
+	executor := getParallelismScaleExecutor(t)
+	scaledJob := getMockScaledJobWithParallelism(10)
+
+	executor.scaleParallelismJob(context.Background(), executor.logger, scaledJob, false, 0, 100)
+	assert.Empty(t, listJobs(t, executor.client))
+}
+
+func TestParallelismScalingStrategyKeepsCompletionsFromTemplate(t *testing.T) {
+	executor := getParallelismScaleExecutor(t)
+	scaledJob := getMockScaledJobWithParallelism(10)
+	completions := int32(1)
+	scaledJob.Spec.JobTargetRef.Completions = &completions
+
+	executor.scaleParallelismJob(context.Background(), executor.logger, scaledJob, true, 4, 100)
+	jobs := listJobs(t, executor.client)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, int32(1), *jobs[0].Spec.Completions)
+}
+
+func TestParallelismScalingStrategyRecreatesJobOnTemplateChange(t *testing.T) {
+	ctx := context.Background()
+	executor := getParallelismScaleExecutor(t)
+	scaledJob := getMockScaledJobWithParallelism(10)
+
+	executor.scaleParallelismJob(ctx, executor.logger, scaledJob, true, 3, 100)
+	jobs := listJobs(t, executor.client)
+	assert.Len(t, jobs, 1)
+	oldName := jobs[0].Name
+
+	// reconciling the same template must not replace the Job
+	executor.scaleParallelismJob(ctx, executor.logger, getMockScaledJobWithParallelism(10), true, 3, 100)
+	jobs = listJobs(t, executor.client)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, oldName, jobs[0].Name)
+
+	updated := getMockScaledJobWithParallelism(10)
+	updated.Spec.JobTargetRef.Template.Spec.Containers[0].Image = "worker:2"
+	executor.scaleParallelismJob(ctx, executor.logger, updated, true, 3, 100)
+	jobs = listJobs(t, executor.client)
+	assert.Len(t, jobs, 1)
+	assert.NotEqual(t, oldName, jobs[0].Name)
+	assert.Equal(t, "worker:2", jobs[0].Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestParallelismScalingStrategyTransitionToDefault(t *testing.T) {
+	ctx := context.Background()
+	executor := getParallelismScaleExecutor(t)
+	scaledJob := getMockScaledJobWithParallelism(10)
+
+	executor.scaleParallelismJob(ctx, executor.logger, scaledJob, true, 3, 100)
+	assert.Len(t, listJobs(t, executor.client), 1)
+
+	// switching to the default strategy removes the parallelism Job
+	scaledJob.Spec.ScalingStrategy.Strategy = ""
+	executor.scaleJobs(ctx, executor.logger, scaledJob, false, 0, 100)
+	assert.Empty(t, listJobs(t, executor.client))
+}
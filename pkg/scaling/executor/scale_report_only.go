@@ -0,0 +1,219 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"math"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	kedacontrollerutil "github.com/kedacore/keda/v2/controllers/keda/util"
+	"github.com/kedacore/keda/v2/pkg/eventreason"
+	"github.com/kedacore/keda/v2/pkg/scaling/cache"
+)
+
+const (
+	// defaultMaxReplicaCount is the maxReplicas of the HPA created for a ScaledObject without maxReplicaCount
+	defaultMaxReplicaCount int32 = 100
+
+	// hpaTolerance is the default tolerance of the HPA, no scaling happens while the usage ratio is within it
+	hpaTolerance = 0.1
+)
+
+// ReportScale computes the replica count the scale target of a ScaledObject in ReportOnly mode would be scaled to.
+// The scale target is only read, the replica count is reported in the status of the ScaledObject and in the
+// desired replicas metric, and an event is recorded when it changes
+func (e *scaleExecutor) ReportScale(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject, isActive bool, isError bool, metrics []cache.MetricTarget,
+	triggerEvaluations map[string]kedav1alpha1.TriggerEvaluationStatus) {
+	logger := e.logger.WithValues("scaledobject.Name", scaledObject.Name,
+		"scaledObject.Namespace", scaledObject.Namespace,
+		"scaleTarget.Name", scaledObject.Spec.ScaleTargetRef.Name)
+
+	e, writeTriggerEvaluations := e.withTriggerEvaluations(ctx, logger, scaledObject, triggerEvaluations)
+	defer writeTriggerEvaluations()
+
+	_, currentReplicas, err := e.getCurrentReplicas(ctx, scaledObject)
+	if err != nil {
+		logger.Error(err, "Error getting information on the current Scale (ie. replicas count) on the scaleTarget")
+		return
+	}
+
+	wouldScaleTo, err := getWouldScaleToReplicaCount(scaledObject, isActive, isError, currentReplicas, metrics)
+	if err != nil {
+		logger.Error(err, "error getting the paused replica count on the current ScaledObject.")
+		return
+	}
+	scaledObjectDesiredReplicas.WithLabelValues(scaledObject.Namespace, scaledObject.Name).Set(float64(wouldScaleTo))
+	logger.V(1).Info("ScaledObject in ReportOnly mode, not scaling the ScaleTarget", "Current Replicas Count", currentReplicas, "Would Scale To", wouldScaleTo)
+
+	if previous := scaledObject.Status.WouldScaleTo; previous == nil || *previous != wouldScaleTo {
+		status := scaledObject.Status.DeepCopy()
+		status.WouldScaleTo = &wouldScaleTo
+		if err := kedacontrollerutil.UpdateScaledObjectStatus(ctx, e.client, logger, scaledObject, status); err != nil {
+			logger.Error(err, "Error updating the replica count the ScaleTarget would be scaled to")
+			return
+		}
+		e.recorder.Eventf(scaledObject, corev1.EventTypeNormal, eventreason.KEDAScaleTargetReportOnly,
+			"Would scale %s %s/%s from %d to %d", scaledObject.Status.ScaleTargetKind, scaledObject.Namespace, scaledObject.Spec.ScaleTargetRef.Name, currentReplicas, wouldScaleTo)
+	}
+
+	e.updateActiveCondition(ctx, logger, scaledObject, isActive)
+}
+
+// clearReportedScale removes the replica count reported while the ScaledObject was in ReportOnly mode
+func (e *scaleExecutor) clearReportedScale(ctx context.Context, logger logr.Logger, scaledObject *kedav1alpha1.ScaledObject) {
+	if scaledObject.Status.WouldScaleTo == nil {
+		return
+	}
+
+	DeleteReportedScale(scaledObject.Namespace, scaledObject.Name)
+	status := scaledObject.Status.DeepCopy()
+	status.WouldScaleTo = nil
+	if err := kedacontrollerutil.UpdateScaledObjectStatus(ctx, e.client, logger, scaledObject, status); err != nil {
+		logger.Error(err, "Error clearing the replica count reported in ReportOnly mode")
+	}
+}
+
+// getWouldScaleToReplicaCount returns the replica count KEDA and the HPA would scale the scale target to, the cooldown
+// period and the HPA scaling behavior aside. Only the external metrics are taken into account
+func getWouldScaleToReplicaCount(scaledObject *kedav1alpha1.ScaledObject, isActive bool, isError bool, currentReplicas int32, metrics []cache.MetricTarget) (int32, error) {
+	pausedCount, err := GetPausedReplicaCount(scaledObject)
+	if err != nil {
+		return 0, err
+	}
+	if pausedCount != nil {
+		return *pausedCount, nil
+	}
+
+	if !isActive {
+		if isError && scaledObject.Spec.Fallback != nil && scaledObject.Spec.Fallback.Replicas != 0 {
+			return scaledObject.Spec.Fallback.Replicas, nil
+		}
+		_, replicas := getIdleOrMinimumReplicaCount(scaledObject)
+		return replicas, nil
+	}
+
+	// the HPA keeps at least one replica
+	minReplicas := int32(1)
+	if scaledObject.Spec.MinReplicaCount != nil && *scaledObject.Spec.MinReplicaCount > minReplicas {
+		minReplicas = *scaledObject.Spec.MinReplicaCount
+	}
+	maxReplicas := defaultMaxReplicaCount
+	if scaledObject.Spec.MaxReplicaCount != nil {
+		maxReplicas = *scaledObject.Spec.MaxReplicaCount
+	}
+
+	desiredReplicas := getHPADesiredReplicaCount(metrics, currentReplicas)
+	switch {
+	case desiredReplicas < minReplicas:
+		return minReplicas, nil
+	case desiredReplicas > maxReplicas:
+		return maxReplicas, nil
+	default:
+		return desiredReplicas, nil
+	}
+}
+
+// getHPADesiredReplicaCount computes the replica count the way the HPA does for external metrics,
+// the metric asking for the most replicas wins
+func getHPADesiredReplicaCount(metrics []cache.MetricTarget, currentReplicas int32) int32 {
+	// the HPA doesn't run at zero replicas, the scale target is activated first
+	if currentReplicas < 1 {
+		currentReplicas = 1
+	}
+
+	desiredReplicas := float64(-1)
+	for _, metric := range metrics {
+		var replicas float64
+		switch {
+		case metric.Target.AverageValue != nil && metric.Target.AverageValue.AsApproximateFloat64() > 0:
+			target := metric.Target.AverageValue.AsApproximateFloat64()
+			replicas = math.Ceil(metric.Value / target)
+			if math.Abs(metric.Value/(target*float64(currentReplicas))-1) <= hpaTolerance {
+				replicas = float64(currentReplicas)
+			}
+		case metric.Target.Value != nil && metric.Target.Value.AsApproximateFloat64() > 0:
+			usageRatio := metric.Value / metric.Target.Value.AsApproximateFloat64()
+			replicas = math.Ceil(usageRatio * float64(currentReplicas))
+			if math.Abs(usageRatio-1) <= hpaTolerance {
+				replicas = float64(currentReplicas)
+			}
+		default:
+			continue
+		}
+		desiredReplicas = math.Max(desiredReplicas, replicas)
+	}
+
+	switch {
+	case desiredReplicas < 0:
+		// without metrics the HPA keeps the current replica count
+		return currentReplicas
+	case desiredReplicas > math.MaxInt32:
+		return math.MaxInt32
+	default:
+		return int32(desiredReplicas)
+	}
+}
+
+// reportJobScale computes the number of Jobs a ScaledJob in ReportOnly mode would create, without creating them
+func (e *scaleExecutor) reportJobScale(ctx context.Context, logger logr.Logger, scaledJob *kedav1alpha1.ScaledJob, isActive bool, scaleTo int64, maxScale int64) {
+	var wouldScaleTo int64
+	if isActive {
+		if scaledJob.Spec.ScalingStrategy.Strategy == parallelismScalingStrategy {
+			wouldScaleTo = min(scaleTo, maxScale)
+		} else {
+			runningJobCount := e.getRunningJobCount(ctx, scaledJob)
+			pendingJobCount := e.getPendingJobCount(ctx, scaledJob)
+			effectiveMaxScale := NewScalingStrategy(logger, scaledJob).GetEffectiveMaxScale(maxScale, runningJobCount, pendingJobCount, scaledJob.MaxReplicaCount())
+			if effectiveMaxScale < 0 {
+				effectiveMaxScale = 0
+			}
+			wouldScaleTo = min(scaleTo, effectiveMaxScale)
+		}
+	}
+	logger.V(1).Info("ScaledJob in ReportOnly mode, not creating Jobs", "Would Create Jobs", wouldScaleTo)
+
+	jobs := int32(min(wouldScaleTo, math.MaxInt32))
+	if previous := scaledJob.Status.WouldScaleTo; previous != nil && *previous == jobs {
+		return
+	}
+
+	patch := runtimeclient.MergeFrom(scaledJob.DeepCopy())
+	scaledJob.Status.WouldScaleTo = &jobs
+	if err := e.client.Status().Patch(ctx, scaledJob, patch); err != nil {
+		logger.Error(err, "Error updating the number of Jobs that would be created")
+		return
+	}
+	e.recorder.Eventf(scaledJob, corev1.EventTypeNormal, eventreason.KEDAJobsReportOnly, "Would create %d jobs", jobs)
+}
+
+// clearReportedJobScale removes the number of Jobs reported while the ScaledJob was in ReportOnly mode
+func (e *scaleExecutor) clearReportedJobScale(ctx context.Context, logger logr.Logger, scaledJob *kedav1alpha1.ScaledJob) {
+	if scaledJob.Status.WouldScaleTo == nil {
+		return
+	}
+
+	patch := runtimeclient.MergeFrom(scaledJob.DeepCopy())
+	scaledJob.Status.WouldScaleTo = nil
+	if err := e.client.Status().Patch(ctx, scaledJob, patch); err != nil {
+		logger.Error(err, "Error clearing the number of Jobs reported in ReportOnly mode")
+	}
+}
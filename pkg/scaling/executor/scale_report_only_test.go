@@ -0,0 +1,189 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	kedacontrollerutil "github.com/kedacore/keda/v2/controllers/keda/util"
+	"github.com/kedacore/keda/v2/pkg/mock/mock_client"
+	"github.com/kedacore/keda/v2/pkg/mock/mock_scale"
+	"github.com/kedacore/keda/v2/pkg/scaling/cache"
+)
+
+func newReportOnlyScaledObject(minReplicas, maxReplicas int32) *v1alpha1.ScaledObject {
+	scaledObject := &v1alpha1.ScaledObject{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+		Spec: v1alpha1.ScaledObjectSpec{
+			ScaleTargetRef: &v1alpha1.ScaleTarget{
+				Name: "name",
+			},
+			MinReplicaCount: &minReplicas,
+			MaxReplicaCount: &maxReplicas,
+			Advanced: &v1alpha1.AdvancedConfig{
+				ScalingMode: v1alpha1.ScalingModeReportOnly,
+			},
+		},
+		Status: v1alpha1.ScaledObjectStatus{
+			ScaleTargetGVKR: &v1alpha1.GroupVersionKindResource{
+				Group: "apps",
+				Kind:  "Deployment",
+			},
+		},
+	}
+	scaledObject.Status.Conditions = *v1alpha1.GetInitializedConditions()
+	return scaledObject
+}
+
+func averageValueMetric(value float64, target int64) cache.MetricTarget {
+	return cache.MetricTarget{
+		MetricName: "metric",
+		Value:      value,
+		Target: v2beta2.MetricTarget{
+			Type:         v2beta2.AverageValueMetricType,
+			AverageValue: resource.NewQuantity(target, resource.DecimalSI),
+		},
+	}
+}
+
+func valueMetric(value float64, target int64) cache.MetricTarget {
+	return cache.MetricTarget{
+		MetricName: "metric",
+		Value:      value,
+		Target: v2beta2.MetricTarget{
+			Type:  v2beta2.ValueMetricType,
+			Value: resource.NewQuantity(target, resource.DecimalSI),
+		},
+	}
+}
+
+func TestReportScaleDoesNotScaleTarget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mock_client.NewMockClient(ctrl)
+	recorder := record.NewFakeRecorder(1)
+	mockScaleClient := mock_scale.NewMockScalesGetter(ctrl)
+	statusWriter := mock_client.NewMockStatusWriter(ctrl)
+
+	scaleExecutor := NewScaleExecutor(client, mockScaleClient, nil, recorder)
+
+	scaledObject := newReportOnlyScaledObject(0, 10)
+	numberOfReplicas := int32(2)
+
+	client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &numberOfReplicas,
+		},
+	}).Times(2)
+
+	// the scale subresource is never touched
+	mockScaleClient.EXPECT().Scales(gomock.Any()).Times(0)
+
+	client.EXPECT().Status().Times(2).Return(statusWriter)
+	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+
+	scaleExecutor.ReportScale(context.TODO(), scaledObject, true, false, []cache.MetricTarget{averageValueMetric(30, 5)}, nil)
+
+	assert.NotNil(t, scaledObject.Status.WouldScaleTo)
+	assert.Equal(t, int32(6), *scaledObject.Status.WouldScaleTo)
+	condition := scaledObject.Status.Conditions.GetActiveCondition()
+	assert.Equal(t, true, condition.IsTrue())
+	assert.Len(t, recorder.Events, 1)
+
+	// nothing is patched while the reported replica count doesn't change
+	scaleExecutor.ReportScale(context.TODO(), scaledObject, true, false, []cache.MetricTarget{averageValueMetric(30, 5)}, nil)
+}
+
+func TestGetWouldScaleToReplicaCount(t *testing.T) {
+	fallback := newReportOnlyScaledObject(0, 10)
+	fallback.Spec.Fallback = &v1alpha1.Fallback{FailureThreshold: 3, Replicas: 4}
+
+	idle := newReportOnlyScaledObject(1, 10)
+	idleReplicas := int32(0)
+	idle.Spec.IdleReplicaCount = &idleReplicas
+
+	paused := newReportOnlyScaledObject(1, 10)
+	paused.Annotations = map[string]string{kedacontrollerutil.PausedReplicasAnnotation: "7"}
+
+	withoutMax := newReportOnlyScaledObject(0, 0)
+	withoutMax.Spec.MaxReplicaCount = nil
+
+	tests := []struct {
+		name            string
+		scaledObject    *v1alpha1.ScaledObject
+		isActive        bool
+		isError         bool
+		currentReplicas int32
+		metrics         []cache.MetricTarget
+		expected        int32
+	}{
+		{"average value", newReportOnlyScaledObject(0, 10), true, false, 2, []cache.MetricTarget{averageValueMetric(30, 5)}, 6},
+		{"average value within tolerance", newReportOnlyScaledObject(0, 10), true, false, 3, []cache.MetricTarget{averageValueMetric(16, 5)}, 3},
+		{"value", newReportOnlyScaledObject(0, 10), true, false, 2, []cache.MetricTarget{valueMetric(20, 5)}, 8},
+		{"highest metric wins", newReportOnlyScaledObject(0, 10), true, false, 2, []cache.MetricTarget{averageValueMetric(10, 5), averageValueMetric(15, 5)}, 3},
+		{"activation from zero", newReportOnlyScaledObject(0, 10), true, false, 0, []cache.MetricTarget{averageValueMetric(1, 5)}, 1},
+		{"no metrics keeps the current count", newReportOnlyScaledObject(0, 10), true, false, 4, nil, 4},
+		{"clamped to max", newReportOnlyScaledObject(0, 10), true, false, 2, []cache.MetricTarget{averageValueMetric(100, 5)}, 10},
+		{"clamped to min", newReportOnlyScaledObject(3, 10), true, false, 2, []cache.MetricTarget{averageValueMetric(1, 5)}, 3},
+		{"default max", withoutMax, true, false, 2, []cache.MetricTarget{averageValueMetric(1000, 5)}, defaultMaxReplicaCount},
+		{"inactive", newReportOnlyScaledObject(0, 10), false, false, 5, nil, 0},
+		{"inactive with idle", idle, false, false, 5, nil, 0},
+		{"inactive with fallback", fallback, false, true, 5, nil, 4},
+		{"paused", paused, true, false, 2, []cache.MetricTarget{averageValueMetric(30, 5)}, 7},
+	}
+
+	for _, test := range tests {
+		replicas, err := getWouldScaleToReplicaCount(test.scaledObject, test.isActive, test.isError, test.currentReplicas, test.metrics)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, replicas, test.name)
+	}
+}
+
+func TestReportJobScaleDoesNotCreateJobs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mock_client.NewMockClient(ctrl)
+	statusWriter := mock_client.NewMockStatusWriter(ctrl)
+
+	scaleExecutor := getMockScaleExecutor(client)
+	scaleExecutor.recorder = record.NewFakeRecorder(1)
+
+	scaledJob := getMockScaledJobWithDefaultStrategy("report-only")
+	scaledJob.Spec.ScalingMode = v1alpha1.ScalingModeReportOnly
+	scaledJob.Spec.ScalingStrategy.Strategy = parallelismScalingStrategy
+
+	client.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+	client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	client.EXPECT().Status().AnyTimes().Return(statusWriter)
+	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+	scaleExecutor.RequestJobScale(context.TODO(), scaledJob, true, 5, 3)
+
+	assert.NotNil(t, scaledJob.Status.WouldScaleTo)
+	assert.Equal(t, int32(3), *scaledJob.Status.WouldScaleTo)
+}
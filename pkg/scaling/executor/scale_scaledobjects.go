@@ -34,44 +34,23 @@ import (
 	"github.com/kedacore/keda/v2/pkg/eventreason"
 )
 
-func (e *scaleExecutor) RequestScale(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject, isActive bool, isError bool) {
+func (e *scaleExecutor) RequestScale(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject, isActive bool, isError bool, triggerEvaluations map[string]kedav1alpha1.TriggerEvaluationStatus) {
 	logger := e.logger.WithValues("scaledobject.Name", scaledObject.Name,
 		"scaledObject.Namespace", scaledObject.Namespace,
 		"scaleTarget.Name", scaledObject.Spec.ScaleTargetRef.Name)
 
-	// Get the current replica count. As a special case, Deployments and StatefulSets fetch directly from the object so they can use the informer cache
-	// to reduce API calls. Everything else uses the scale subresource.
-	var currentScale *autoscalingv1.Scale
-	var currentReplicas int32
-	targetName := scaledObject.Spec.ScaleTargetRef.Name
-	targetGVKR := scaledObject.Status.ScaleTargetGVKR
-	switch {
-	case targetGVKR.Group == "apps" && targetGVKR.Kind == "Deployment":
-		deployment := &appsv1.Deployment{}
-		err := e.client.Get(ctx, client.ObjectKey{Name: targetName, Namespace: scaledObject.Namespace}, deployment)
-		if err != nil {
-			logger.Error(err, "Error getting information on the current Scale (ie. replicas count) on the scaleTarget")
-			return
-		}
-		currentReplicas = *deployment.Spec.Replicas
-	case targetGVKR.Group == "apps" && targetGVKR.Kind == "StatefulSet":
-		statefulSet := &appsv1.StatefulSet{}
-		err := e.client.Get(ctx, client.ObjectKey{Name: targetName, Namespace: scaledObject.Namespace}, statefulSet)
-		if err != nil {
-			logger.Error(err, "Error getting information on the current Scale (ie. replicas count) on the scaleTarget")
-			return
-		}
-		currentReplicas = *statefulSet.Spec.Replicas
-	default:
-		var err error
-		currentScale, err = e.getScaleTargetScale(ctx, scaledObject)
-		if err != nil {
-			logger.Error(err, "Error getting information on the current Scale (ie. replicas count) on the scaleTarget")
-			return
-		}
-		currentReplicas = currentScale.Spec.Replicas
+	e, writeTriggerEvaluations := e.withTriggerEvaluations(ctx, logger, scaledObject, triggerEvaluations)
+	defer writeTriggerEvaluations()
+
+	currentScale, currentReplicas, err := e.getCurrentReplicas(ctx, scaledObject)
+	if err != nil {
+		logger.Error(err, "Error getting information on the current Scale (ie. replicas count) on the scaleTarget")
+		return
 	}
 
+	// the replica count reported in ReportOnly mode is stale once the ScaledObject scales the target again
+	e.clearReportedScale(ctx, logger, scaledObject)
+
 	// if the ScaledObject's triggers aren't in the error state,
 	// but ScaledObject.Status.ReadyCondition is set not set to 'true' -> set it back to 'true'
 	readyCondition := scaledObject.Status.Conditions.GetReadyCondition()
@@ -189,7 +168,7 @@ func (e *scaleExecutor) RequestScale(ctx context.Context, scaledObject *kedav1al
 			// there is no minimum configured or minimum is set to ZERO
 
 			// Try to scale the deployment down, HPA will handle other scale down operations
-			e.scaleToZeroOrIdle(ctx, logger, scaledObject, currentScale)
+			e.scaleToZeroOrIdle(ctx, logger, scaledObject, currentScale, currentReplicas)
 		case currentReplicas < minReplicas && scaledObject.Spec.IdleReplicaCount == nil:
 			// there are no active triggers
 			// AND
@@ -212,22 +191,53 @@ func (e *scaleExecutor) RequestScale(ctx context.Context, scaledObject *kedav1al
 		}
 	}
 
+	e.updateActiveCondition(ctx, logger, scaledObject, isActive)
+}
+
+// updateActiveCondition sets the active condition of the ScaledObject when the activity of the triggers changed
+func (e *scaleExecutor) updateActiveCondition(ctx context.Context, logger logr.Logger, scaledObject *kedav1alpha1.ScaledObject, isActive bool) {
 	condition := scaledObject.Status.Conditions.GetActiveCondition()
 	if condition.IsUnknown() || condition.IsTrue() != isActive {
 		if isActive {
 			if err := e.setActiveCondition(ctx, logger, scaledObject, metav1.ConditionTrue, "ScalerActive", "Scaling is performed because triggers are active"); err != nil {
 				logger.Error(err, "Error setting active condition when triggers are active")
-				return
 			}
 		} else {
 			if err := e.setActiveCondition(ctx, logger, scaledObject, metav1.ConditionFalse, "ScalerNotActive", "Scaling is not performed because triggers are not active"); err != nil {
 				logger.Error(err, "Error setting active condition when triggers are not active")
-				return
 			}
 		}
 	}
 }
 
+// getCurrentReplicas returns the current replica count of the scale target. As a special case, Deployments and StatefulSets fetch
+// directly from the object so they can use the informer cache to reduce API calls, the scale is only returned for everything else,
+// which uses the scale subresource.
+func (e *scaleExecutor) getCurrentReplicas(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject) (*autoscalingv1.Scale, int32, error) {
+	targetName := scaledObject.Spec.ScaleTargetRef.Name
+	targetGVKR := scaledObject.Status.ScaleTargetGVKR
+	switch {
+	case targetGVKR.Group == "apps" && targetGVKR.Kind == "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := e.client.Get(ctx, client.ObjectKey{Name: targetName, Namespace: scaledObject.Namespace}, deployment); err != nil {
+			return nil, 0, err
+		}
+		return nil, *deployment.Spec.Replicas, nil
+	case targetGVKR.Group == "apps" && targetGVKR.Kind == "StatefulSet":
+		statefulSet := &appsv1.StatefulSet{}
+		if err := e.client.Get(ctx, client.ObjectKey{Name: targetName, Namespace: scaledObject.Namespace}, statefulSet); err != nil {
+			return nil, 0, err
+		}
+		return nil, *statefulSet.Spec.Replicas, nil
+	default:
+		currentScale, err := e.getScaleTargetScale(ctx, scaledObject)
+		if err != nil {
+			return nil, 0, err
+		}
+		return currentScale, currentScale.Spec.Replicas, nil
+	}
+}
+
 func (e *scaleExecutor) doFallbackScaling(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject, currentScale *autoscalingv1.Scale, logger logr.Logger, currentReplicas int32) {
 	_, err := e.updateScaleOnScaleTarget(ctx, scaledObject, currentScale, scaledObject.Spec.Fallback.Replicas)
 	if err == nil {
@@ -241,15 +251,10 @@ func (e *scaleExecutor) doFallbackScaling(ctx context.Context, scaledObject *ked
 }
 
 // An object will be scaled down to 0 only if it's passed its cooldown period
-// or if LastActiveTime is nil
-func (e *scaleExecutor) scaleToZeroOrIdle(ctx context.Context, logger logr.Logger, scaledObject *kedav1alpha1.ScaledObject, scale *autoscalingv1.Scale) {
-	var cooldownPeriod time.Duration
-
-	if scaledObject.Spec.CooldownPeriod != nil {
-		cooldownPeriod = time.Second * time.Duration(*scaledObject.Spec.CooldownPeriod)
-	} else {
-		cooldownPeriod = time.Second * time.Duration(defaultCooldownPeriod)
-	}
+// or if LastActiveTime is nil. A StatefulSet isn't scaled down below the replicas with Bound PVCs
+// if minReplicasWhenPVCBound is set
+func (e *scaleExecutor) scaleToZeroOrIdle(ctx context.Context, logger logr.Logger, scaledObject *kedav1alpha1.ScaledObject, scale *autoscalingv1.Scale, currentReplicas int32) {
+	cooldownPeriod := scaledObject.GetCooldownPeriod()
 
 	// LastActiveTime can be nil if the ScaleTarget was scaled outside of KEDA.
 	// In this case we will ignore the cooldown period and scale it down
@@ -259,12 +264,33 @@ func (e *scaleExecutor) scaleToZeroOrIdle(ctx context.Context, logger logr.Logge
 
 		idleValue, scaleToReplicas := getIdleOrMinimumReplicaCount(scaledObject)
 
+		pvcFloor, err := e.getPVCBoundReplicaFloor(ctx, scaledObject)
+		if err != nil {
+			logger.Error(err, "Error getting the replicas with Bound PVCs, not scaling down the ScaleTarget")
+			return
+		}
+		heldByPVCs := pvcFloor > scaleToReplicas
+		if err := e.updatePVCBoundReplicaFloor(ctx, logger, scaledObject, heldByPVCs, pvcFloor); err != nil {
+			logger.Error(err, "Error updating the PVC bound replica floor")
+			return
+		}
+		if heldByPVCs {
+			if currentReplicas <= pvcFloor {
+				logger.V(1).Info("ScaleTarget held by Bound PVCs", "Replicas Count", currentReplicas, "PVC Bound Replica Floor", pvcFloor)
+				return
+			}
+			scaleToReplicas = pvcFloor
+		}
+
 		currentReplicas, err := e.updateScaleOnScaleTarget(ctx, scaledObject, scale, scaleToReplicas)
 		if err == nil {
 			msg := "Successfully set ScaleTarget replicas count to ScaledObject"
-			if idleValue {
+			switch {
+			case heldByPVCs:
+				msg += " minReplicasWhenPVCBound"
+			case idleValue:
 				msg += " idleReplicaCount"
-			} else {
+			default:
 				msg += " minReplicaCount"
 			}
 			logger.Info(msg, "Original Replicas Count", currentReplicas, "New Replicas Count", scaleToReplicas)
@@ -294,6 +320,23 @@ func (e *scaleExecutor) scaleToZeroOrIdle(ctx context.Context, logger logr.Logge
 	}
 }
 
+// updatePVCBoundReplicaFloor records in the status of the ScaledObject whether the scale down of the ScaleTarget is held by Bound PVCs
+func (e *scaleExecutor) updatePVCBoundReplicaFloor(ctx context.Context, logger logr.Logger, scaledObject *kedav1alpha1.ScaledObject, heldByPVCs bool, pvcFloor int32) error {
+	current := scaledObject.Status.PVCBoundReplicaFloor
+	if !heldByPVCs && current == nil || heldByPVCs && current != nil && *current == pvcFloor {
+		return nil
+	}
+
+	status := scaledObject.Status.DeepCopy()
+	status.PVCBoundReplicaFloor = nil
+	if heldByPVCs {
+		status.PVCBoundReplicaFloor = &pvcFloor
+		e.recorder.Eventf(scaledObject, corev1.EventTypeNormal, eventreason.KEDAScaleTargetHeldByPVCs,
+			"Keeping %s %s/%s at %d replicas with Bound PVCs", scaledObject.Status.ScaleTargetKind, scaledObject.Namespace, scaledObject.Spec.ScaleTargetRef.Name, pvcFloor)
+	}
+	return kedacontrollerutil.UpdateScaledObjectStatus(ctx, e.client, logger, scaledObject, status)
+}
+
 func (e *scaleExecutor) scaleFromZeroOrIdle(ctx context.Context, logger logr.Logger, scaledObject *kedav1alpha1.ScaledObject, scale *autoscalingv1.Scale) {
 	var replicas int32
 	if scaledObject.Spec.MinReplicaCount != nil && *scaledObject.Spec.MinReplicaCount > 0 {
@@ -18,6 +18,7 @@ package executor
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -26,6 +27,7 @@ import (
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kedacore/keda/v2/apis/keda/v1alpha1"
 	"github.com/kedacore/keda/v2/pkg/mock/mock_client"
@@ -87,7 +89,7 @@ func TestScaleToFallbackReplicasWhenNotActiveAndIsError(t *testing.T) {
 	client.EXPECT().Status().Times(2).Return(statusWriter)
 	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
 
-	scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, true)
+	scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, true, nil)
 
 	assert.Equal(t, int32(5), scale.Spec.Replicas)
 	condition := scaledObject.Status.Conditions.GetFallbackCondition()
@@ -148,7 +150,7 @@ func TestScaleToMinReplicasWhenNotActive(t *testing.T) {
 	client.EXPECT().Status().Return(statusWriter).Times(2)
 	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
 
-	scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, false)
+	scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, false, nil)
 
 	assert.Equal(t, minReplicas, scale.Spec.Replicas)
 	condition := scaledObject.Status.Conditions.GetActiveCondition()
@@ -209,7 +211,7 @@ func TestScaleToMinReplicasFromLowerInitialReplicaCount(t *testing.T) {
 	client.EXPECT().Status().Return(statusWriter).Times(2)
 	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
 
-	scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, false)
+	scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, false, nil)
 
 	assert.Equal(t, minReplicas, scale.Spec.Replicas)
 	condition := scaledObject.Status.Conditions.GetActiveCondition()
@@ -268,7 +270,7 @@ func TestScaleFromMinReplicasWhenActive(t *testing.T) {
 	client.EXPECT().Status().Times(2).Return(statusWriter).Times(3)
 	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).Times(3)
 
-	scaleExecutor.RequestScale(context.TODO(), &scaledObject, true, false)
+	scaleExecutor.RequestScale(context.TODO(), &scaledObject, true, false, nil)
 
 	assert.Equal(t, int32(1), scale.Spec.Replicas)
 	condition := scaledObject.Status.Conditions.GetActiveCondition()
@@ -331,7 +333,7 @@ func TestScaleToIdleReplicasWhenNotActive(t *testing.T) {
 	client.EXPECT().Status().Return(statusWriter).Times(2)
 	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
 
-	scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, false)
+	scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, false, nil)
 
 	assert.Equal(t, idleReplicas, scale.Spec.Replicas)
 	condition := scaledObject.Status.Conditions.GetActiveCondition()
@@ -392,7 +394,7 @@ func TestScaleFromIdleToMinReplicasWhenActive(t *testing.T) {
 	client.EXPECT().Status().Times(2).Return(statusWriter).Times(3)
 	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).Times(3)
 
-	scaleExecutor.RequestScale(context.TODO(), &scaledObject, true, false)
+	scaleExecutor.RequestScale(context.TODO(), &scaledObject, true, false, nil)
 
 	assert.Equal(t, minReplicas, scale.Spec.Replicas)
 	condition := scaledObject.Status.Conditions.GetActiveCondition()
@@ -454,9 +456,83 @@ func TestScaleToPausedReplicasCount(t *testing.T) {
 	client.EXPECT().Status().Return(statusWriter).Times(2)
 	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
 
-	scaleExecutor.RequestScale(context.TODO(), &scaledObject, true, false)
+	scaleExecutor.RequestScale(context.TODO(), &scaledObject, true, false, nil)
 
 	assert.Equal(t, pausedReplicaCount, scale.Spec.Replicas)
 	condition := scaledObject.Status.Conditions.GetActiveCondition()
 	assert.Equal(t, false, condition.IsTrue())
 }
+
+func TestRequestScaleWritesTriggerEvaluationsWithStatusUpdates(t *testing.T) {
+	for _, testData := range []struct {
+		name            string
+		activeCondition v1.ConditionStatus
+		conditions      bool
+	}{
+		{"with the active condition", v1.ConditionUnknown, true},
+		{"on their own", v1.ConditionFalse, false},
+	} {
+		ctrl := gomock.NewController(t)
+		client := mock_client.NewMockClient(ctrl)
+		mockScaleClient := mock_scale.NewMockScalesGetter(ctrl)
+		mockScaleInterface := mock_scale.NewMockScaleInterface(ctrl)
+		statusWriter := mock_client.NewMockStatusWriter(ctrl)
+
+		scaleExecutor := NewScaleExecutor(client, mockScaleClient, nil, record.NewFakeRecorder(1))
+
+		minReplicas := int32(0)
+		scaledObject := v1alpha1.ScaledObject{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "name",
+				Namespace: "namespace",
+			},
+			Spec: v1alpha1.ScaledObjectSpec{
+				ScaleTargetRef: &v1alpha1.ScaleTarget{
+					Name: "name",
+				},
+				MinReplicaCount: &minReplicas,
+			},
+			Status: v1alpha1.ScaledObjectStatus{
+				ScaleTargetGVKR: &v1alpha1.GroupVersionKindResource{
+					Group: "apps",
+					Kind:  "Deployment",
+				},
+			},
+		}
+		scaledObject.Status.Conditions = *v1alpha1.GetInitializedConditions()
+		scaledObject.Status.Conditions.SetReadyCondition(v1.ConditionTrue, "", "")
+		scaledObject.Status.Conditions.SetActiveCondition(testData.activeCondition, "", "")
+
+		numberOfReplicas := int32(0)
+		client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, appsv1.Deployment{
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &numberOfReplicas,
+			},
+		})
+		mockScaleClient.EXPECT().Scales(gomock.Any()).Return(mockScaleInterface).AnyTimes()
+		mockScaleInterface.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&autoscalingv1.Scale{}, nil).AnyTimes()
+
+		// a single status patch carries the trigger evaluations
+		var patches []string
+		client.EXPECT().Status().Return(statusWriter)
+		statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, _ ...runtimeclient.PatchOption) error {
+				data, err := patch.Data(obj)
+				assert.NoError(t, err)
+				patches = append(patches, string(data))
+				return nil
+			})
+
+		evaluationTime := v1.Now()
+		scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, false, map[string]v1alpha1.TriggerEvaluationStatus{
+			"queue": {LastEvaluationTime: &evaluationTime, LastEvaluationDurationMs: 12},
+		})
+
+		if assert.Len(t, patches, 1, testData.name) {
+			assert.Contains(t, patches[0], `"triggerEvaluations":{"queue":`, testData.name)
+			assert.Equal(t, testData.conditions, strings.Contains(patches[0], `"conditions"`), testData.name)
+		}
+		assert.Equal(t, int64(12), scaledObject.Status.TriggerEvaluations["queue"].LastEvaluationDurationMs, testData.name)
+		ctrl.Finish()
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	kedacontrollerutil "github.com/kedacore/keda/v2/controllers/keda/util"
+)
+
+// MinReplicasWhenPVCBoundAuto keeps every replica of the StatefulSet up to the highest ordinal with a Bound PVC
+const MinReplicasWhenPVCBoundAuto = "auto"
+
+// ValidateMinReplicasWhenPVCBound checks that minReplicasWhenPVCBound is either "auto" or a non negative number
+func ValidateMinReplicasWhenPVCBound(value *intstr.IntOrString) error {
+	if value == nil {
+		return nil
+	}
+	switch {
+	case value.Type == intstr.Int && value.IntVal < 0:
+		return fmt.Errorf("minReplicasWhenPVCBound=%d must not be negative", value.IntVal)
+	case value.Type == intstr.String && value.StrVal != MinReplicasWhenPVCBoundAuto:
+		return fmt.Errorf("minReplicasWhenPVCBound must be %s or a number, got %s", MinReplicasWhenPVCBoundAuto, value.StrVal)
+	}
+	return nil
+}
+
+// GetPVCBoundReplicaFloor returns the number of replicas the StatefulSet needs to keep its Bound PVCs attached.
+// StatefulSets scale down from the highest ordinal, so that is the highest ordinal with a Bound PVC of one of
+// its volumeClaimTemplates plus one. PVCs that are Pending or Lost don't hold the StatefulSet
+func GetPVCBoundReplicaFloor(ctx context.Context, client runtimeclient.Client, statefulSet *appsv1.StatefulSet) (int32, error) {
+	if len(statefulSet.Spec.VolumeClaimTemplates) == 0 {
+		return 0, nil
+	}
+
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := client.List(ctx, pvcs, runtimeclient.InNamespace(statefulSet.Namespace)); err != nil {
+		return 0, err
+	}
+
+	floor := int32(0)
+	for _, pvc := range pvcs.Items {
+		if pvc.Status.Phase != corev1.ClaimBound || pvc.DeletionTimestamp != nil {
+			continue
+		}
+		ordinal, ok := statefulSetPVCOrdinal(statefulSet, pvc.Name)
+		if ok && ordinal+1 > floor {
+			floor = ordinal + 1
+		}
+	}
+	return floor, nil
+}
+
+// statefulSetPVCOrdinal returns the ordinal of the pod the PVC was created for from a volumeClaimTemplate of the StatefulSet,
+// the PVCs are named <template>-<statefulset>-<ordinal>
+func statefulSetPVCOrdinal(statefulSet *appsv1.StatefulSet, pvcName string) (int32, bool) {
+	for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+		prefix := fmt.Sprintf("%s-%s-", template.Name, statefulSet.Name)
+		if !strings.HasPrefix(pvcName, prefix) {
+			continue
+		}
+		ordinal, err := strconv.ParseInt(strings.TrimPrefix(pvcName, prefix), 10, 32)
+		if err == nil && ordinal >= 0 {
+			return int32(ordinal), true
+		}
+	}
+	return 0, false
+}
+
+// getPVCBoundReplicaFloor returns the replica count the StatefulSet targeted by the ScaledObject isn't scaled down below
+// because of minReplicasWhenPVCBound, or 0 if it's not set, the target isn't a StatefulSet or the floor is forced away
+func (e *scaleExecutor) getPVCBoundReplicaFloor(ctx context.Context, scaledObject *kedav1alpha1.ScaledObject) (int32, error) {
+	minReplicasWhenPVCBound := scaledObject.Spec.MinReplicasWhenPVCBound
+	targetGVKR := scaledObject.Status.ScaleTargetGVKR
+	if minReplicasWhenPVCBound == nil || targetGVKR == nil || targetGVKR.Group != "apps" || targetGVKR.Kind != "StatefulSet" {
+		return 0, nil
+	}
+	if scaledObject.Annotations[kedacontrollerutil.ForceScaleBelowPVCFloorAnnotation] == "true" {
+		return 0, nil
+	}
+	if err := ValidateMinReplicasWhenPVCBound(minReplicasWhenPVCBound); err != nil {
+		return 0, err
+	}
+
+	statefulSet := &appsv1.StatefulSet{}
+	if err := e.client.Get(ctx, runtimeclient.ObjectKey{Name: scaledObject.Spec.ScaleTargetRef.Name, Namespace: scaledObject.Namespace}, statefulSet); err != nil {
+		return 0, err
+	}
+
+	floor, err := GetPVCBoundReplicaFloor(ctx, e.client, statefulSet)
+	if err != nil {
+		return 0, err
+	}
+	if minReplicasWhenPVCBound.Type == intstr.Int && minReplicasWhenPVCBound.IntVal < floor {
+		floor = minReplicasWhenPVCBound.IntVal
+	}
+	return floor, nil
+}
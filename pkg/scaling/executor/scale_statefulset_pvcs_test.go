@@ -0,0 +1,232 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	kedacontrollerutil "github.com/kedacore/keda/v2/controllers/keda/util"
+	"github.com/kedacore/keda/v2/pkg/mock/mock_client"
+	"github.com/kedacore/keda/v2/pkg/mock/mock_scale"
+)
+
+func pvcFloorScaledObject(minReplicasWhenPVCBound intstr.IntOrString) v1alpha1.ScaledObject {
+	scaledObject := v1alpha1.ScaledObject{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+		Spec: v1alpha1.ScaledObjectSpec{
+			ScaleTargetRef: &v1alpha1.ScaleTarget{
+				Name: "name",
+			},
+			MinReplicasWhenPVCBound: &minReplicasWhenPVCBound,
+		},
+		Status: v1alpha1.ScaledObjectStatus{
+			ScaleTargetGVKR: &v1alpha1.GroupVersionKindResource{
+				Group: "apps",
+				Kind:  "StatefulSet",
+			},
+		},
+	}
+	scaledObject.Status.Conditions = *v1alpha1.GetInitializedConditions()
+	return scaledObject
+}
+
+func pvcFloorStatefulSet(replicas int32) appsv1.StatefulSet {
+	return appsv1.StatefulSet{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{ObjectMeta: v1.ObjectMeta{Name: "data"}},
+			},
+		},
+	}
+}
+
+func pvc(name string, phase corev1.PersistentVolumeClaimPhase) corev1.PersistentVolumeClaim {
+	return corev1.PersistentVolumeClaim{
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "namespace"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+}
+
+func TestScaleToPVCBoundReplicaFloorWhenNotActive(t *testing.T) {
+	tests := []struct {
+		name                    string
+		minReplicasWhenPVCBound intstr.IntOrString
+		expectedReplicas        int32
+	}{
+		{"auto", intstr.FromString(MinReplicasWhenPVCBoundAuto), 2},
+		{"explicit floor", intstr.FromInt(1), 1},
+	}
+
+	for _, test := range tests {
+		ctrl := gomock.NewController(t)
+		client := mock_client.NewMockClient(ctrl)
+		recorder := record.NewFakeRecorder(2)
+		mockScaleClient := mock_scale.NewMockScalesGetter(ctrl)
+		mockScaleInterface := mock_scale.NewMockScaleInterface(ctrl)
+		statusWriter := mock_client.NewMockStatusWriter(ctrl)
+
+		scaleExecutor := NewScaleExecutor(client, mockScaleClient, nil, recorder)
+		scaledObject := pvcFloorScaledObject(test.minReplicasWhenPVCBound)
+
+		numberOfReplicas := int32(3)
+		client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, pvcFloorStatefulSet(numberOfReplicas)).Times(2)
+		client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(1, corev1.PersistentVolumeClaimList{
+			Items: []corev1.PersistentVolumeClaim{
+				pvc("data-name-0", corev1.ClaimBound),
+				pvc("data-name-1", corev1.ClaimBound),
+				pvc("data-name-2", corev1.ClaimLost),
+			},
+		})
+
+		scale := &autoscalingv1.Scale{
+			Spec: autoscalingv1.ScaleSpec{
+				Replicas: numberOfReplicas,
+			},
+		}
+
+		mockScaleClient.EXPECT().Scales(gomock.Any()).Return(mockScaleInterface).Times(2)
+		mockScaleInterface.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(scale, nil)
+		mockScaleInterface.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Eq(scale), gomock.Any())
+
+		client.EXPECT().Status().Return(statusWriter).Times(3)
+		statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).Times(3)
+
+		scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, false, nil)
+
+		assert.Equal(t, test.expectedReplicas, scale.Spec.Replicas, test.name)
+		assert.Equal(t, test.expectedReplicas, *scaledObject.Status.PVCBoundReplicaFloor, test.name)
+		ctrl.Finish()
+	}
+}
+
+func TestNoScaleWhenHeldByPVCBoundReplicaFloor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mock_client.NewMockClient(ctrl)
+	recorder := record.NewFakeRecorder(2)
+	mockScaleClient := mock_scale.NewMockScalesGetter(ctrl)
+	statusWriter := mock_client.NewMockStatusWriter(ctrl)
+
+	scaleExecutor := NewScaleExecutor(client, mockScaleClient, nil, recorder)
+	scaledObject := pvcFloorScaledObject(intstr.FromString(MinReplicasWhenPVCBoundAuto))
+	floor := int32(2)
+	scaledObject.Status.PVCBoundReplicaFloor = &floor
+
+	client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, pvcFloorStatefulSet(2)).Times(2)
+	client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(1, corev1.PersistentVolumeClaimList{
+		Items: []corev1.PersistentVolumeClaim{
+			pvc("data-name-0", corev1.ClaimBound),
+			pvc("data-name-1", corev1.ClaimBound),
+		},
+	})
+
+	// the scale target isn't updated, only the ready and active conditions are set
+	client.EXPECT().Status().Return(statusWriter).Times(2)
+	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+
+	scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, false, nil)
+
+	assert.Equal(t, floor, *scaledObject.Status.PVCBoundReplicaFloor)
+}
+
+func TestForceScaleBelowPVCBoundReplicaFloor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mock_client.NewMockClient(ctrl)
+	recorder := record.NewFakeRecorder(1)
+	mockScaleClient := mock_scale.NewMockScalesGetter(ctrl)
+	mockScaleInterface := mock_scale.NewMockScaleInterface(ctrl)
+	statusWriter := mock_client.NewMockStatusWriter(ctrl)
+
+	scaleExecutor := NewScaleExecutor(client, mockScaleClient, nil, recorder)
+	scaledObject := pvcFloorScaledObject(intstr.FromString(MinReplicasWhenPVCBoundAuto))
+	scaledObject.Annotations = map[string]string{kedacontrollerutil.ForceScaleBelowPVCFloorAnnotation: "true"}
+
+	numberOfReplicas := int32(3)
+	// the PVCs aren't looked up
+	client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, pvcFloorStatefulSet(numberOfReplicas))
+
+	scale := &autoscalingv1.Scale{
+		Spec: autoscalingv1.ScaleSpec{
+			Replicas: numberOfReplicas,
+		},
+	}
+
+	mockScaleClient.EXPECT().Scales(gomock.Any()).Return(mockScaleInterface).Times(2)
+	mockScaleInterface.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(scale, nil)
+	mockScaleInterface.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Eq(scale), gomock.Any())
+
+	client.EXPECT().Status().Return(statusWriter).Times(2)
+	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+
+	scaleExecutor.RequestScale(context.TODO(), &scaledObject, false, false, nil)
+
+	assert.Equal(t, int32(0), scale.Spec.Replicas)
+	assert.Nil(t, scaledObject.Status.PVCBoundReplicaFloor)
+}
+
+func TestStatefulSetPVCOrdinal(t *testing.T) {
+	statefulSet := pvcFloorStatefulSet(1)
+
+	tests := []struct {
+		pvcName string
+		ordinal int32
+		ok      bool
+	}{
+		{"data-name-0", 0, true},
+		{"data-name-12", 12, true},
+		{"logs-name-0", 0, false},
+		{"data-name-other-1", 0, false},
+		{"data-name-", 0, false},
+	}
+
+	for _, test := range tests {
+		ordinal, ok := statefulSetPVCOrdinal(&statefulSet, test.pvcName)
+		assert.Equal(t, test.ok, ok, test.pvcName)
+		assert.Equal(t, test.ordinal, ordinal, test.pvcName)
+	}
+}
+
+func TestValidateMinReplicasWhenPVCBound(t *testing.T) {
+	auto := intstr.FromString("auto")
+	explicit := intstr.FromInt(2)
+	negative := intstr.FromInt(-1)
+	invalid := intstr.FromString("all")
+
+	assert.NoError(t, ValidateMinReplicasWhenPVCBound(nil))
+	assert.NoError(t, ValidateMinReplicasWhenPVCBound(&auto))
+	assert.NoError(t, ValidateMinReplicasWhenPVCBound(&explicit))
+	assert.Error(t, ValidateMinReplicasWhenPVCBound(&negative))
+	assert.Error(t, ValidateMinReplicasWhenPVCBound(&invalid))
+}
@@ -0,0 +1,386 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package expression evaluates the arithmetic expressions that transform the value of a trigger,
+// e.g. "value / 1024 / 1024" or "min(value, 500)". Expressions only support numbers, the value
+// variable, the + - * / % operators, parentheses and a few math functions, so evaluating an
+// expression given by a user can't have side effects
+package expression
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// ValueVariable is the name of the value of the trigger in an expression
+	ValueVariable = "value"
+
+	// maxLength and maxDepth bound the work done to parse an expression
+	maxLength = 1024
+	maxDepth  = 32
+)
+
+// Expression is a parsed expression, safe for concurrent use
+type Expression struct {
+	source string
+	root   node
+}
+
+type node interface {
+	eval(value float64) (float64, error)
+}
+
+type number float64
+
+type variable struct{}
+
+type unary struct {
+	op      byte
+	operand node
+}
+
+type binary struct {
+	op          byte
+	left, right node
+}
+
+type call struct {
+	fn   function
+	args []node
+}
+
+// function is a math function callable from an expression, with a number of
+// arguments between minArgs and maxArgs, maxArgs being unlimited when negative
+type function struct {
+	minArgs, maxArgs int
+	apply            func(args []float64) float64
+}
+
+var functions = map[string]function{
+	"abs":   {1, 1, func(args []float64) float64 { return math.Abs(args[0]) }},
+	"ceil":  {1, 1, func(args []float64) float64 { return math.Ceil(args[0]) }},
+	"floor": {1, 1, func(args []float64) float64 { return math.Floor(args[0]) }},
+	"round": {1, 1, func(args []float64) float64 { return math.Round(args[0]) }},
+	"min": {2, -1, func(args []float64) float64 {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Min(result, arg)
+		}
+		return result
+	}},
+	"max": {2, -1, func(args []float64) float64 {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Max(result, arg)
+		}
+		return result
+	}},
+}
+
+// Parse parses the expression, the errors point at the position of the problem
+func Parse(source string) (*Expression, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, fmt.Errorf("empty expression")
+	}
+	if len(source) > maxLength {
+		return nil, fmt.Errorf("expression is longer than %d characters", maxLength)
+	}
+
+	p := &parser{source: source}
+	root, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.source) {
+		return nil, p.errorf("unexpected %q", p.source[p.pos])
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+// Evaluate returns the result of the expression for the value. Divisions by zero
+// and results that are not finite numbers are errors
+func (e *Expression) Evaluate(value float64) (float64, error) {
+	result, err := e.root.eval(value)
+	if err != nil {
+		return 0, fmt.Errorf("error evaluating %q with value %v: %s", e.source, value, err)
+	}
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, fmt.Errorf("error evaluating %q with value %v: the result is not a finite number", e.source, value)
+	}
+	return result, nil
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+func (n number) eval(float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (variable) eval(value float64) (float64, error) {
+	return value, nil
+}
+
+func (n *unary) eval(value float64) (float64, error) {
+	operand, err := n.operand.eval(value)
+	if err != nil {
+		return 0, err
+	}
+	if n.op == '-' {
+		return -operand, nil
+	}
+	return operand, nil
+}
+
+func (n *binary) eval(value float64) (float64, error) {
+	left, err := n.left.eval(value)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(value)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return left / right, nil
+	default:
+		if right == 0 {
+			return 0, fmt.Errorf("modulo by zero")
+		}
+		return math.Mod(left, right), nil
+	}
+}
+
+func (n *call) eval(value float64) (float64, error) {
+	args := make([]float64, 0, len(n.args))
+	for _, arg := range n.args {
+		result, err := arg.eval(value)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, result)
+	}
+	return n.fn.apply(args), nil
+}
+
+type parser struct {
+	source string
+	pos    int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid expression %q at position %d: %s", p.source, p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.source) && unicode.IsSpace(rune(p.source[p.pos])) {
+		p.pos++
+	}
+}
+
+// peek returns the next character that isn't a space, or 0 at the end of the expression
+func (p *parser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.source) {
+		return 0
+	}
+	return p.source[p.pos]
+}
+
+// parseExpression parses the additions and subtractions, the operators with the lowest precedence
+func (p *parser) parseExpression(depth int) (node, error) {
+	if depth > maxDepth {
+		return nil, p.errorf("expression is nested more than %d levels deep", maxDepth)
+	}
+
+	left, err := p.parseTerm(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseTerm(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, left: left, right: right}
+	}
+}
+
+// parseTerm parses the multiplications, divisions and modulos
+func (p *parser) parseTerm(depth int) (node, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary(depth int) (node, error) {
+	if op := p.peek(); op == '-' || op == '+' {
+		if depth > maxDepth {
+			return nil, p.errorf("expression is nested more than %d levels deep", maxDepth)
+		}
+		p.pos++
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: op, operand: operand}, nil
+	}
+	return p.parsePrimary(depth)
+}
+
+func (p *parser) parsePrimary(depth int) (node, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, p.errorf("unexpected end of expression")
+	case c == '(':
+		p.pos++
+		inner, err := p.parseExpression(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	case c == '_' || unicode.IsLetter(rune(c)):
+		return p.parseIdentifier(depth)
+	default:
+		return nil, p.errorf("unexpected %q", c)
+	}
+}
+
+func (p *parser) parseNumber() (node, error) {
+	start := p.pos
+	for p.pos < len(p.source) && (p.source[p.pos] == '.' || (p.source[p.pos] >= '0' && p.source[p.pos] <= '9')) {
+		p.pos++
+	}
+	// exponent, e.g. 1e6
+	if p.pos < len(p.source) && (p.source[p.pos] == 'e' || p.source[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.source) && (p.source[p.pos] == '+' || p.source[p.pos] == '-') {
+			p.pos++
+		}
+		for p.pos < len(p.source) && p.source[p.pos] >= '0' && p.source[p.pos] <= '9' {
+			p.pos++
+		}
+	}
+
+	n, err := strconv.ParseFloat(p.source[start:p.pos], 64)
+	if err != nil || math.IsInf(n, 0) {
+		p.pos = start
+		return nil, p.errorf("invalid number %s", p.source[start:])
+	}
+	return number(n), nil
+}
+
+func (p *parser) parseIdentifier(depth int) (node, error) {
+	start := p.pos
+	for p.pos < len(p.source) && (p.source[p.pos] == '_' || unicode.IsLetter(rune(p.source[p.pos])) || unicode.IsDigit(rune(p.source[p.pos]))) {
+		p.pos++
+	}
+	name := p.source[start:p.pos]
+
+	if p.peek() != '(' {
+		if name != ValueVariable {
+			p.pos = start
+			return nil, p.errorf("unknown variable %s, only %s is defined", name, ValueVariable)
+		}
+		return variable{}, nil
+	}
+
+	fn, ok := functions[name]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown function %s", name)
+	}
+	p.pos++
+
+	var args []node
+	if p.peek() != ')' {
+		for {
+			arg, err := p.parseExpression(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+	}
+	if p.peek() != ')' {
+		return nil, p.errorf("missing ) after the arguments of %s", name)
+	}
+	p.pos++
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		p.pos = start
+		return nil, p.errorf("%s takes %s, got %d", name, argumentCount(fn), len(args))
+	}
+	return &call{fn: fn, args: args}, nil
+}
+
+func argumentCount(fn function) string {
+	switch {
+	case fn.maxArgs < 0:
+		return fmt.Sprintf("at least %d arguments", fn.minArgs)
+	case fn.minArgs == 1 && fn.maxArgs == 1:
+		return "1 argument"
+	default:
+		return fmt.Sprintf("%d arguments", fn.minArgs)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package expression
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		value      float64
+		expected   float64
+	}{
+		{"value", 42, 42},
+		{"  value  ", 42, 42},
+		{"10", 42, 10},
+		{"1.5", 0, 1.5},
+		{".5", 0, 0.5},
+		{"1e3", 0, 1000},
+		{"2.5E-1", 0, 0.25},
+		{"value / 1024 / 1024", 3 * 1024 * 1024, 3},
+		{"value + 1", 1, 2},
+		{"value - 1", 1, 0},
+		{"value * 2", 3, 6},
+		{"value % 7", 23, 2},
+		{"-value", 3, -3},
+		{"+value", 3, 3},
+		{"--value", 3, 3},
+		{"-(value - 10)", 3, 7},
+		{"1 + 2 * 3", 0, 7},
+		{"(1 + 2) * 3", 0, 9},
+		{"10 - 4 - 3", 0, 3},
+		{"64 / 4 / 2", 0, 8},
+		{"2 * -3", 0, -6},
+		{"((((value))))", 5, 5},
+		{"min(value, 500)", 1000, 500},
+		{"min(value, 500)", 100, 100},
+		{"min(3, value, 2)", 10, 2},
+		{"max(value, 1)", 0, 1},
+		{"max(1, 2, value, 4)", 10, 10},
+		{"abs(value)", -4, 4},
+		{"ceil(value / 100)", 101, 2},
+		{"floor(value / 100)", 199, 1},
+		{"round(value)", 2.5, 3},
+		{"round(value)", 2.4, 2},
+		{"max(0, min(value - 10, 100))", 50, 40},
+		{"max(0, min(value - 10, 100))", 5, 0},
+		{"max(0, min(value - 10, 100))", 500, 100},
+		{"value*value", 4, 16},
+	}
+
+	for _, test := range tests {
+		expr, err := Parse(test.expression)
+		if !assert.NoError(t, err, test.expression) {
+			continue
+		}
+		result, err := expr.Evaluate(test.value)
+		assert.NoError(t, err, test.expression)
+		assert.InDelta(t, test.expected, result, 1e-9, "%s with value %v", test.expression, test.value)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        string
+	}{
+		{"", "empty expression"},
+		{"   ", "empty expression"},
+		{"value +", "unexpected end of expression"},
+		{"* value", `unexpected '*'`},
+		{"value value", `unexpected 'v'`},
+		{"(value", "missing )"},
+		{"value)", `unexpected ')'`},
+		{"()", `unexpected ')'`},
+		{"values", "unknown variable values"},
+		{"x + 1", "unknown variable x"},
+		{"sqrt(value)", "unknown function sqrt"},
+		{"min(value)", "min takes at least 2 arguments, got 1"},
+		{"abs(value, 1)", "abs takes 1 argument, got 2"},
+		{"abs()", "abs takes 1 argument, got 0"},
+		{"min(value, 1", "missing ) after the arguments of min"},
+		{"min(value,)", `unexpected ')'`},
+		{"1.2.3", "invalid number 1.2.3"},
+		{"1e", "invalid number 1e"},
+		{"1e999", "invalid number 1e999"},
+		{"value & 1", `unexpected '&'`},
+		{"value ^ 2", `unexpected '^'`},
+		{"value == 1", `unexpected '='`},
+		{"os.Exit(1)", `unknown variable os`},
+		{strings.Repeat("(", 40) + "value" + strings.Repeat(")", 40), "nested more than 32 levels deep"},
+		{strings.Repeat("-", 40) + "value", "nested more than 32 levels deep"},
+		{strings.Repeat("value+", 200) + "value", "longer than 1024 characters"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.expression)
+		if assert.Error(t, err, test.expression) {
+			assert.Contains(t, err.Error(), test.err, test.expression)
+		}
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	_, err := Parse("value + foo")
+	assert.EqualError(t, err, `invalid expression "value + foo" at position 9: unknown variable foo, only value is defined`)
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		value      float64
+		err        string
+	}{
+		{"1 / value", 0, "division by zero"},
+		{"value % 0", 5, "modulo by zero"},
+		{"min(1 / value, 1)", 0, "division by zero"},
+		{"-(1 / value)", 0, "division by zero"},
+		{"value * 1e308", 1e308, "not a finite number"},
+		{"value", math.Inf(1), "not a finite number"},
+		{"value - value", math.NaN(), "not a finite number"},
+	}
+
+	for _, test := range tests {
+		expr, err := Parse(test.expression)
+		if !assert.NoError(t, err, test.expression) {
+			continue
+		}
+		_, err = expr.Evaluate(test.value)
+		if assert.Error(t, err, test.expression) {
+			assert.Contains(t, err.Error(), test.err, test.expression)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	expr, err := Parse("min(value, 500)")
+	assert.NoError(t, err)
+	assert.Equal(t, "min(value, 500)", expr.String())
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaling
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/kedacore/keda/v2/pkg/scaling/cache"
+)
+
+var scalerEvaluationDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "keda",
+		Subsystem: "scaler",
+		Name:      "evaluation_duration_seconds",
+		Help:      "Time taken by a scaler to decide whether its trigger is active",
+		Buckets:   prometheus.DefBuckets,
+	},
+	[]string{"scaler"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(scalerEvaluationDuration)
+}
+
+// recordScalerEvaluations observes the duration of each trigger evaluation per scaler type
+func recordScalerEvaluations(evaluations []cache.TriggerEvaluation) {
+	for _, evaluation := range evaluations {
+		scalerEvaluationDuration.WithLabelValues(evaluation.TriggerType).Observe(evaluation.Duration.Seconds())
+	}
+}
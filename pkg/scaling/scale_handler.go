@@ -24,6 +24,7 @@ import (
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/scale"
@@ -32,6 +33,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	kedacontrollerutil "github.com/kedacore/keda/v2/controllers/keda/util"
 	"github.com/kedacore/keda/v2/pkg/eventreason"
 	"github.com/kedacore/keda/v2/pkg/scalers"
 	"github.com/kedacore/keda/v2/pkg/scaling/cache"
@@ -39,6 +41,9 @@ import (
 	"github.com/kedacore/keda/v2/pkg/scaling/resolver"
 )
 
+// triggerEvaluationsUpdateInterval is the minimum time between two updates of the trigger evaluations in the ScaledObject status
+const triggerEvaluationsUpdateInterval = 30 * time.Second
+
 // ScaleHandler encapsulates the logic of calling the right scalers for
 // each ScaledObject and making the final scale decision and operation
 type ScaleHandler interface {
@@ -54,22 +59,28 @@ type scaleHandler struct {
 	scaleLoopContexts *sync.Map
 	scaleExecutor     executor.ScaleExecutor
 	globalHTTPTimeout time.Duration
+	// scalerCloseTimeout bounds how long closing a scaler may block the teardown of its cache
+	scalerCloseTimeout time.Duration
+	// globalValueBounds clamp the metric values of the triggers without their own minValue or maxValue
+	globalValueBounds ValueBounds
 	recorder          record.EventRecorder
 	scalerCaches      map[string]*cache.ScalersCache
 	lock              *sync.RWMutex
 }
 
 // NewScaleHandler creates a ScaleHandler object
-func NewScaleHandler(client client.Client, scaleClient scale.ScalesGetter, reconcilerScheme *runtime.Scheme, globalHTTPTimeout time.Duration, recorder record.EventRecorder) ScaleHandler {
+func NewScaleHandler(client client.Client, scaleClient scale.ScalesGetter, reconcilerScheme *runtime.Scheme, globalHTTPTimeout time.Duration, scalerCloseTimeout time.Duration, globalValueBounds ValueBounds, recorder record.EventRecorder) ScaleHandler {
 	return &scaleHandler{
-		client:            client,
-		logger:            logf.Log.WithName("scalehandler"),
-		scaleLoopContexts: &sync.Map{},
-		scaleExecutor:     executor.NewScaleExecutor(client, scaleClient, reconcilerScheme, recorder),
-		globalHTTPTimeout: globalHTTPTimeout,
-		recorder:          recorder,
-		scalerCaches:      map[string]*cache.ScalersCache{},
-		lock:              &sync.RWMutex{},
+		client:             client,
+		logger:             logf.Log.WithName("scalehandler"),
+		scaleLoopContexts:  &sync.Map{},
+		scaleExecutor:      executor.NewScaleExecutor(client, scaleClient, reconcilerScheme, recorder),
+		globalHTTPTimeout:  globalHTTPTimeout,
+		scalerCloseTimeout: scalerCloseTimeout,
+		globalValueBounds:  globalValueBounds,
+		recorder:           recorder,
+		scalerCaches:       map[string]*cache.ScalersCache{},
+		lock:               &sync.RWMutex{},
 	}
 }
 
@@ -97,15 +108,17 @@ func (h *scaleHandler) HandleScalableObject(ctx context.Context, scalableObject
 
 	// a mutex is used to synchronize scale requests per scalableObject
 	scalingMutex := &sync.Mutex{}
+	// the push scalers wake up the scale loop when it's idle
+	pushed := make(chan struct{}, 1)
 
 	// passing deep copy of ScaledObject/ScaledJob to the scaleLoop go routines, it's a precaution to not have global objects shared between threads
 	switch obj := scalableObject.(type) {
 	case *kedav1alpha1.ScaledObject:
-		go h.startPushScalers(ctx, withTriggers, obj.DeepCopy(), scalingMutex)
-		go h.startScaleLoop(ctx, withTriggers, obj.DeepCopy(), scalingMutex)
+		go h.startPushScalers(ctx, withTriggers, obj.DeepCopy(), scalingMutex, pushed)
+		go h.startScaleLoop(ctx, withTriggers, obj.DeepCopy(), scalingMutex, pushed)
 	case *kedav1alpha1.ScaledJob:
-		go h.startPushScalers(ctx, withTriggers, obj.DeepCopy(), scalingMutex)
-		go h.startScaleLoop(ctx, withTriggers, obj.DeepCopy(), scalingMutex)
+		go h.startPushScalers(ctx, withTriggers, obj.DeepCopy(), scalingMutex, pushed)
+		go h.startScaleLoop(ctx, withTriggers, obj.DeepCopy(), scalingMutex, pushed)
 	}
 	return nil
 }
@@ -130,6 +143,9 @@ func (h *scaleHandler) DeleteScalableObject(ctx context.Context, scalableObject
 			h.logger.Error(err, "error clearing scalers cache")
 		}
 		h.recorder.Event(withTriggers, corev1.EventTypeNormal, eventreason.KEDAScalersStopped, "Stopped scalers watch")
+		if _, ok := scalableObject.(*kedav1alpha1.ScaledObject); ok {
+			executor.DeleteReportedScale(withTriggers.Namespace, withTriggers.Name)
+		}
 	} else {
 		h.logger.V(1).Info("ScaleObject was not found in controller cache", "key", key)
 	}
@@ -138,20 +154,30 @@ func (h *scaleHandler) DeleteScalableObject(ctx context.Context, scalableObject
 }
 
 // startScaleLoop blocks forever and checks the scaledObject based on its pollingInterval
-func (h *scaleHandler) startScaleLoop(ctx context.Context, withTriggers *kedav1alpha1.WithTriggers, scalableObject interface{}, scalingMutex sync.Locker) {
+func (h *scaleHandler) startScaleLoop(ctx context.Context, withTriggers *kedav1alpha1.WithTriggers, scalableObject interface{}, scalingMutex sync.Locker, pushed <-chan struct{}) {
 	logger := h.logger.WithValues("type", withTriggers.Kind, "namespace", withTriggers.Namespace, "name", withTriggers.Name)
 
 	pollingInterval := withTriggers.GetPollingInterval()
 	logger.V(1).Info("Watching with pollingInterval", "PollingInterval", pollingInterval)
 
+	currentInterval := pollingInterval
 	for {
-		tmr := time.NewTimer(pollingInterval)
-		h.checkScalers(ctx, scalableObject, scalingMutex)
+		start := time.Now()
+		isActive := h.checkScalers(ctx, scalableObject, scalingMutex)
+
+		interval := getEffectivePollingInterval(scalableObject, pollingInterval, isActive, time.Now())
+		idle := interval != pollingInterval
+		if interval != currentInterval {
+			logger.V(1).Info("Changing the pollingInterval", "PollingInterval", interval)
+			if idle && currentInterval == pollingInterval {
+				drainPushed(pushed)
+			}
+			currentInterval = interval
+		}
+		h.updateEffectivePollingIntervalStatus(ctx, scalableObject, interval)
 
-		select {
-		case <-tmr.C:
-			tmr.Stop()
-		case <-ctx.Done():
+		tmr := time.NewTimer(interval - time.Since(start))
+		if !waitForNextCheck(ctx, tmr.C, pushed, idle) {
 			logger.V(1).Info("Context canceled")
 			err := h.ClearScalersCache(ctx, scalableObject)
 			if err != nil {
@@ -160,6 +186,75 @@ func (h *scaleHandler) startScaleLoop(ctx context.Context, withTriggers *kedav1a
 			tmr.Stop()
 			return
 		}
+		tmr.Stop()
+	}
+}
+
+// waitForNextCheck waits for the polling timer, or for an event of a push scaler when the scale loop is idle.
+// It returns false when the context is canceled
+func waitForNextCheck(ctx context.Context, timer <-chan time.Time, pushed <-chan struct{}, idle bool) bool {
+	for {
+		select {
+		case <-timer:
+			return true
+		case <-pushed:
+			if idle {
+				return true
+			}
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// drainPushed drops the push event sent while the scale loop wasn't idle yet, e.g. during the check that made it idle.
+// It would otherwise end the first idle wait at once
+func drainPushed(pushed <-chan struct{}) {
+	select {
+	case <-pushed:
+	default:
+	}
+}
+
+// getEffectivePollingInterval returns the interval until the next check of the scalable object. A ScaledObject with an
+// idlePollingInterval is polled at that interval while its triggers are inactive and were last active longer than its
+// cooldownPeriod ago, like when it's scaled to zero
+func getEffectivePollingInterval(scalableObject interface{}, pollingInterval time.Duration, isActive bool, now time.Time) time.Duration {
+	scaledObject, ok := scalableObject.(*kedav1alpha1.ScaledObject)
+	if !ok || scaledObject.Spec.IdlePollingInterval == nil || isActive {
+		return pollingInterval
+	}
+
+	lastActiveTime := scaledObject.Status.LastActiveTime
+	if lastActiveTime != nil && now.Before(lastActiveTime.Add(scaledObject.GetCooldownPeriod())) {
+		return pollingInterval
+	}
+	return time.Second * time.Duration(*scaledObject.Spec.IdlePollingInterval)
+}
+
+// updateEffectivePollingIntervalStatus writes the polling interval of a ScaledObject with an idlePollingInterval to its status,
+// and removes it from the status once the ScaledObject has none
+func (h *scaleHandler) updateEffectivePollingIntervalStatus(ctx context.Context, scalableObject interface{}, interval time.Duration) {
+	scaledObject, ok := scalableObject.(*kedav1alpha1.ScaledObject)
+	if !ok {
+		return
+	}
+
+	var effectivePollingInterval *int32
+	if scaledObject.Spec.IdlePollingInterval != nil {
+		seconds := int32(interval / time.Second)
+		effectivePollingInterval = &seconds
+	}
+
+	current := scaledObject.Status.EffectivePollingInterval
+	if (current == nil && effectivePollingInterval == nil) || (current != nil && effectivePollingInterval != nil && *current == *effectivePollingInterval) {
+		return
+	}
+
+	status := scaledObject.Status.DeepCopy()
+	status.EffectivePollingInterval = effectivePollingInterval
+	if err := kedacontrollerutil.UpdateScaledObjectStatus(ctx, h.client, h.logger, scaledObject, status); err != nil {
+		h.logger.Error(err, "Error updating the effective pollingInterval", "object", scaledObject)
 	}
 }
 
@@ -191,16 +286,19 @@ func (h *scaleHandler) GetScalersCache(ctx context.Context, scalableObject inter
 		return nil, err
 	}
 
-	scalers, err := h.buildScalers(ctx, withTriggers, podTemplateSpec, containerName)
+	// only the triggers of a ScaledObject feed a single HPA, where identical triggers would count the same metric twice
+	_, isScaledObject := scalableObject.(*kedav1alpha1.ScaledObject)
+	scalers, err := h.buildScalers(ctx, withTriggers, podTemplateSpec, containerName, isScaledObject)
 	if err != nil {
 		return nil, err
 	}
 
 	h.scalerCaches[key] = &cache.ScalersCache{
-		Generation: withTriggers.Generation,
-		Scalers:    scalers,
-		Logger:     h.logger,
-		Recorder:   h.recorder,
+		Generation:   withTriggers.Generation,
+		Scalers:      scalers,
+		Logger:       h.logger,
+		Recorder:     h.recorder,
+		CloseTimeout: h.scalerCloseTimeout,
 	}
 
 	return h.scalerCaches[key], nil
@@ -225,7 +323,7 @@ func (h *scaleHandler) ClearScalersCache(ctx context.Context, scalableObject int
 	return nil
 }
 
-func (h *scaleHandler) startPushScalers(ctx context.Context, withTriggers *kedav1alpha1.WithTriggers, scalableObject interface{}, scalingMutex sync.Locker) {
+func (h *scaleHandler) startPushScalers(ctx context.Context, withTriggers *kedav1alpha1.WithTriggers, scalableObject interface{}, scalingMutex sync.Locker, pushed chan<- struct{}) {
 	logger := h.logger.WithValues("type", withTriggers.Kind, "namespace", withTriggers.Namespace, "name", withTriggers.Name)
 	cache, err := h.GetScalersCache(ctx, scalableObject)
 	if err != nil {
@@ -243,10 +341,16 @@ func (h *scaleHandler) startPushScalers(ctx context.Context, withTriggers *kedav
 				case <-ctx.Done():
 					return
 				case active := <-activeCh:
+					// wake up the scale loop if it's idle, unless it's already been woken up
+					select {
+					case pushed <- struct{}{}:
+					default:
+					}
+
 					scalingMutex.Lock()
 					switch obj := scalableObject.(type) {
 					case *kedav1alpha1.ScaledObject:
-						h.scaleExecutor.RequestScale(ctx, obj, active, false)
+						h.requestScale(ctx, cache, obj, active, false, nil)
 					case *kedav1alpha1.ScaledJob:
 						h.logger.Info("Warning: External Push Scaler does not support ScaledJob", "object", scalableObject)
 					}
@@ -258,12 +362,12 @@ func (h *scaleHandler) startPushScalers(ctx context.Context, withTriggers *kedav
 }
 
 // checkScalers contains the main logic for the ScaleHandler scaling logic.
-// It'll check each trigger active status then call RequestScale
-func (h *scaleHandler) checkScalers(ctx context.Context, scalableObject interface{}, scalingMutex sync.Locker) {
+// It'll check each trigger active status then call RequestScale, it returns whether a trigger is active
+func (h *scaleHandler) checkScalers(ctx context.Context, scalableObject interface{}, scalingMutex sync.Locker) bool {
 	cache, err := h.GetScalersCache(ctx, scalableObject)
 	if err != nil {
 		h.logger.Error(err, "Error getting scalers", "object", scalableObject)
-		return
+		return false
 	}
 
 	scalingMutex.Lock()
@@ -273,31 +377,105 @@ func (h *scaleHandler) checkScalers(ctx context.Context, scalableObject interfac
 		err = h.client.Get(ctx, types.NamespacedName{Name: obj.Name, Namespace: obj.Namespace}, obj)
 		if err != nil {
 			h.logger.Error(err, "Error getting scaledObject", "object", scalableObject)
-			return
+			return false
 		}
 		isActive, isError, _ := cache.IsScaledObjectActive(ctx, obj)
-		h.scaleExecutor.RequestScale(ctx, obj, isActive, isError)
+		evaluations := cache.GetTriggerEvaluations()
+		recordScalerEvaluations(evaluations)
+		h.requestScale(ctx, cache, obj, isActive, isError, getTriggerEvaluationsStatus(obj, evaluations))
+		return isActive
 	case *kedav1alpha1.ScaledJob:
 		err = h.client.Get(ctx, types.NamespacedName{Name: obj.Name, Namespace: obj.Namespace}, obj)
 		if err != nil {
 			h.logger.Error(err, "Error getting scaledJob", "object", scalableObject)
-			return
+			return false
 		}
 		isActive, scaleTo, maxScale := cache.IsScaledJobActive(ctx, obj)
 		h.scaleExecutor.RequestJobScale(ctx, obj, isActive, scaleTo, maxScale)
+		return isActive
+	}
+	return false
+}
+
+// requestScale scales the scale target of the ScaledObject, or only reports the replica count it would be scaled to in ReportOnly mode.
+// The trigger evaluations are written with the status updates of the executor
+func (h *scaleHandler) requestScale(ctx context.Context, cache *cache.ScalersCache, scaledObject *kedav1alpha1.ScaledObject, isActive bool, isError bool,
+	triggerEvaluations map[string]kedav1alpha1.TriggerEvaluationStatus) {
+	if scaledObject.IsReportOnly() {
+		h.scaleExecutor.ReportScale(ctx, scaledObject, isActive, isError, cache.GetExternalMetricTargets(ctx, scaledObject), triggerEvaluations)
+		return
+	}
+	h.scaleExecutor.RequestScale(ctx, scaledObject, isActive, isError, triggerEvaluations)
+}
+
+// getTriggerEvaluationsStatus returns the trigger evaluations to write to the ScaledObject status, or nil if they
+// shouldn't be written. To keep the load on the API server low, they are written at most once per
+// triggerEvaluationsUpdateInterval, unless the circuit of a trigger opened or closed
+func getTriggerEvaluationsStatus(scaledObject *kedav1alpha1.ScaledObject, evaluations []cache.TriggerEvaluation) map[string]kedav1alpha1.TriggerEvaluationStatus {
+	if len(evaluations) == 0 {
+		return nil
+	}
+
+	if !circuitStatesChanged(scaledObject.Status.TriggerEvaluations, evaluations) {
+		for _, evaluation := range scaledObject.Status.TriggerEvaluations {
+			if evaluation.LastEvaluationTime != nil && time.Since(evaluation.LastEvaluationTime.Time) < triggerEvaluationsUpdateInterval {
+				return nil
+			}
+		}
+	}
+
+	statuses := make(map[string]kedav1alpha1.TriggerEvaluationStatus, len(evaluations))
+	for _, evaluation := range evaluations {
+		evaluationTime := metav1.NewTime(evaluation.Time)
+		evaluationStatus := kedav1alpha1.TriggerEvaluationStatus{
+			LastEvaluationTime:       &evaluationTime,
+			LastEvaluationDurationMs: evaluation.Duration.Milliseconds(),
+			CircuitState:             evaluation.CircuitState,
+		}
+		if evaluation.CircuitState == kedav1alpha1.CircuitStateOpen {
+			openUntil := metav1.NewTime(evaluation.CircuitOpenUntil)
+			evaluationStatus.CircuitOpenUntil = &openUntil
+		}
+		statuses[evaluation.TriggerName] = evaluationStatus
+	}
+	return statuses
+}
+
+// circuitStatesChanged tells whether the circuit state of a trigger differs from the one in the status
+func circuitStatesChanged(statuses map[string]kedav1alpha1.TriggerEvaluationStatus, evaluations []cache.TriggerEvaluation) bool {
+	for _, evaluation := range evaluations {
+		if statuses[evaluation.TriggerName].CircuitState != evaluation.CircuitState {
+			return true
+		}
 	}
+	return false
 }
 
-// buildScalers returns list of Scalers for the specified triggers
-func (h *scaleHandler) buildScalers(ctx context.Context, withTriggers *kedav1alpha1.WithTriggers, podTemplateSpec *corev1.PodTemplateSpec, containerName string) ([]cache.ScalerBuilder, error) {
+// buildScalers returns list of Scalers for the specified triggers, skipping the duplicate triggers if skipDuplicates is set
+func (h *scaleHandler) buildScalers(ctx context.Context, withTriggers *kedav1alpha1.WithTriggers, podTemplateSpec *corev1.PodTemplateSpec, containerName string, skipDuplicates bool) ([]cache.ScalerBuilder, error) {
 	logger := h.logger.WithValues("type", withTriggers.Kind, "namespace", withTriggers.Namespace, "name", withTriggers.Name)
 	var err error
 	resolvedEnv := make(map[string]string)
 	result := make([]cache.ScalerBuilder, 0, len(withTriggers.Spec.Triggers))
+	var duplicates map[int]int
+	if skipDuplicates {
+		duplicates = FindDuplicateTriggers(withTriggers.Spec.Triggers)
+	}
 
 	for i, t := range withTriggers.Spec.Triggers {
 		triggerIndex, trigger := i, t
 
+		// identical triggers would count the same metric twice, the index of the others is kept so their metric names don't change
+		if first, ok := duplicates[triggerIndex]; ok {
+			logger.V(1).Info("Skipping trigger identical to an earlier one", "scalerIndex", triggerIndex, "duplicateOf", first)
+			continue
+		}
+
+		triggerName := trigger.Name
+		if triggerName == "" {
+			triggerName = fmt.Sprintf("s%d-%s", triggerIndex, trigger.Type)
+		}
+
 		factory := func() (scalers.Scaler, error) {
 			if podTemplateSpec != nil {
 				resolvedEnv, err = resolver.ResolveContainerEnv(ctx, h.client, logger, &podTemplateSpec.Spec, containerName, withTriggers.Namespace)
@@ -314,6 +492,7 @@ func (h *scaleHandler) buildScalers(ctx context.Context, withTriggers *kedav1alp
 				GlobalHTTPTimeout: h.globalHTTPTimeout,
 				ScalerIndex:       triggerIndex,
 				MetricType:        trigger.MetricType,
+				TriggerType:       trigger.Type,
 			}
 
 			config.AuthParams, config.PodIdentity, err = resolver.ResolveAuthRefAndPodIdentity(ctx, h.client, logger, trigger.AuthenticationRef, podTemplateSpec, withTriggers.Namespace)
@@ -321,7 +500,23 @@ func (h *scaleHandler) buildScalers(ctx context.Context, withTriggers *kedav1alp
 				return nil, err
 			}
 
-			return buildScaler(ctx, h.client, trigger.Type, config)
+			scaler, err := buildScaler(ctx, h.client, trigger.Type, config)
+			if err != nil {
+				return scaler, err
+			}
+
+			transformedScaler, err := withValueExpression(scaler, trigger)
+			if err != nil {
+				scaler.Close(ctx)
+				return nil, err
+			}
+			// the bounds apply to the value once transformed, the one reported to the HPA
+			boundedScaler, err := withValueBounds(transformedScaler, trigger, triggerName, h.globalValueBounds, h.recorder, withTriggers)
+			if err != nil {
+				scaler.Close(ctx)
+				return nil, err
+			}
+			return boundedScaler, nil
 		}
 
 		scaler, err := factory()
@@ -338,8 +533,10 @@ func (h *scaleHandler) buildScalers(ctx context.Context, withTriggers *kedav1alp
 		}
 
 		result = append(result, cache.ScalerBuilder{
-			Scaler:  scaler,
-			Factory: factory,
+			Scaler:      scaler,
+			Factory:     factory,
+			TriggerName: triggerName,
+			TriggerType: trigger.Type,
 		})
 	}
 
@@ -347,12 +544,20 @@ func (h *scaleHandler) buildScalers(ctx context.Context, withTriggers *kedav1alp
 }
 
 func buildScaler(ctx context.Context, client client.Client, triggerType string, config *scalers.ScalerConfig) (scalers.Scaler, error) {
+	// the out-of-tree scalers registered with scalers.Register
+	if builder, ok := scalers.GetRegisteredBuilder(triggerType); ok {
+		return builder(ctx, config)
+	}
+
+	// the built-in scalers, their trigger types must be listed in pkg/scalers/registry.go too
 	// TRIGGERS-START
 	switch triggerType {
 	case "activemq":
 		return scalers.NewActiveMQScaler(config)
 	case "artemis-queue":
 		return scalers.NewArtemisQueueScaler(config)
+	case "aws-alb":
+		return scalers.NewAwsAlbScaler(config)
 	case "aws-cloudwatch":
 		return scalers.NewAwsCloudwatchScaler(config)
 	case "aws-dynamodb":
@@ -365,6 +570,8 @@ func buildScaler(ctx context.Context, client client.Client, triggerType string,
 		return scalers.NewAzureAppInsightsScaler(config)
 	case "azure-blob":
 		return scalers.NewAzureBlobScaler(config)
+	case "azure-cosmosdb":
+		return scalers.NewAzureCosmosDBScaler(config)
 	case "azure-data-explorer":
 		return scalers.NewAzureDataExplorerScaler(config)
 	case "azure-eventhub":
@@ -379,12 +586,24 @@ func buildScaler(ctx context.Context, client client.Client, triggerType string,
 		return scalers.NewAzureQueueScaler(config)
 	case "azure-servicebus":
 		return scalers.NewAzureServiceBusScaler(ctx, config)
+	case "b2":
+		return scalers.NewB2Scaler(config)
+	case "bullmq":
+		return scalers.NewBullMQScaler(ctx, false, false, config)
+	case "bullmq-cluster":
+		return scalers.NewBullMQScaler(ctx, true, false, config)
+	case "bullmq-sentinel":
+		return scalers.NewBullMQScaler(ctx, false, true, config)
 	case "cassandra":
 		return scalers.NewCassandraScaler(config)
 	case "cpu":
 		return scalers.NewCPUMemoryScaler(corev1.ResourceCPU, config)
 	case "cron":
 		return scalers.NewCronScaler(config)
+	case "custom-metrics-api":
+		return scalers.NewCustomMetricsAPIScaler(config)
+	case "dagster":
+		return scalers.NewDagsterScaler(config)
 	case "datadog":
 		return scalers.NewDatadogScaler(ctx, config)
 	case "elasticsearch":
@@ -393,22 +612,42 @@ func buildScaler(ctx context.Context, client client.Client, triggerType string,
 		return scalers.NewExternalScaler(config)
 	case "external-push":
 		return scalers.NewExternalPushScaler(config)
+	case "gcp-bigquery":
+		return scalers.NewBigQueryScaler(ctx, config)
+	case "gcp-cloudlogging":
+		return scalers.NewCloudLoggingScaler(ctx, config)
+	case "gcp-cloudsql":
+		return scalers.NewCloudSQLScaler(ctx, config)
+	case "gcp-cloudtasks":
+		return scalers.NewCloudTasksScaler(ctx, config)
+	case "gcp-dataflow":
+		return scalers.NewDataflowScaler(ctx, config)
+	case "gcp-firestore":
+		return scalers.NewFirestoreScaler(ctx, config)
 	case "gcp-pubsub":
-		return scalers.NewPubSubScaler(config)
+		return scalers.NewPubSubScaler(ctx, config)
+	case "gcp-spanner":
+		return scalers.NewSpannerScaler(ctx, config)
 	case "gcp-stackdriver":
 		return scalers.NewStackdriverScaler(ctx, config)
 	case "gcp-storage":
-		return scalers.NewGcsScaler(config)
+		return scalers.NewGcsScaler(ctx, config)
 	case "graphite":
 		return scalers.NewGraphiteScaler(config)
+	case "hazelcast":
+		return scalers.NewHazelcastScaler(config)
 	case "huawei-cloudeye":
 		return scalers.NewHuaweiCloudeyeScaler(config)
 	case "ibmmq":
 		return scalers.NewIBMMQScaler(config)
 	case "influxdb":
 		return scalers.NewInfluxDBScaler(config)
+	case "ingress-status":
+		return scalers.NewIngressStatusScaler(config)
 	case "kafka":
 		return scalers.NewKafkaScaler(config)
+	case "kafka-connect":
+		return scalers.NewKafkaConnectScaler(config)
 	case "kubernetes-workload":
 		return scalers.NewKubernetesWorkloadScaler(client, config)
 	case "liiklus":
@@ -419,16 +658,24 @@ func buildScaler(ctx context.Context, client client.Client, triggerType string,
 		return scalers.NewMetricsAPIScaler(config)
 	case "mongodb":
 		return scalers.NewMongoDBScaler(ctx, config)
+	case "msgraph-mail":
+		return scalers.NewMSGraphMailScaler(config)
 	case "mssql":
 		return scalers.NewMSSQLScaler(config)
 	case "mysql":
 		return scalers.NewMySQLScaler(config)
 	case "new-relic":
 		return scalers.NewNewRelicScaler(config)
+	case "nsq":
+		return scalers.NewNSQScaler(config)
+	case "opensearch":
+		return scalers.NewOpenSearchScaler(config)
 	case "openstack-metric":
 		return scalers.NewOpenstackMetricScaler(ctx, config)
 	case "openstack-swift":
 		return scalers.NewOpenstackSwiftScaler(ctx, config)
+	case "pagerduty":
+		return scalers.NewPagerDutyScaler(config)
 	case "postgresql":
 		return scalers.NewPostgreSQLScaler(config)
 	case "predictkube":
@@ -449,12 +696,18 @@ func buildScaler(ctx context.Context, client client.Client, triggerType string,
 		return scalers.NewRedisStreamsScaler(ctx, false, true, config)
 	case "redis-streams":
 		return scalers.NewRedisStreamsScaler(ctx, false, false, config)
+	case "scylla-rest":
+		return scalers.NewScyllaRESTScaler(config)
 	case "selenium-grid":
 		return scalers.NewSeleniumGridScaler(config)
+	case "sentry":
+		return scalers.NewSentryScaler(config)
 	case "solace-event-queue":
 		return scalers.NewSolaceScaler(config)
 	case "stan":
 		return scalers.NewStanScaler(config)
+	case "webhdfs":
+		return scalers.NewWebHDFSScaler(config)
 	default:
 		return nil, fmt.Errorf("no scaler found for type: %s", triggerType)
 	}
@@ -19,17 +19,26 @@ package scaling
 import (
 	"context"
 	"errors"
+	"io/ioutil"
+	"regexp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"github.com/kedacore/keda/v2/pkg/mock/mock_client"
 	mock_scalers "github.com/kedacore/keda/v2/pkg/mock/mock_scaler"
 	"github.com/kedacore/keda/v2/pkg/scalers"
 	"github.com/kedacore/keda/v2/pkg/scaling/cache"
@@ -134,6 +143,422 @@ func TestCheckScaledObjectFindFirstActiveNotIgnoreOthers(t *testing.T) {
 	assert.Equal(t, true, isError)
 }
 
+func TestCheckScaledObjectRecordsTriggerEvaluations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	recorder := record.NewFakeRecorder(1)
+
+	slowScaler := mock_scalers.NewMockScaler(ctrl)
+	slowScaler.EXPECT().IsActive(gomock.Any()).DoAndReturn(func(context.Context) (bool, error) {
+		time.Sleep(50 * time.Millisecond)
+		return false, nil
+	})
+	slowScaler.EXPECT().Close(gomock.Any())
+
+	// the first scaler fails, the duration of the retry with the refreshed scaler must be included as well
+	failingScaler := mock_scalers.NewMockScaler(ctrl)
+	failingScaler.EXPECT().IsActive(gomock.Any()).DoAndReturn(func(context.Context) (bool, error) {
+		time.Sleep(20 * time.Millisecond)
+		return false, errors.New("some error")
+	})
+	failingScaler.EXPECT().Close(gomock.Any())
+	refreshedFactory := func() (scalers.Scaler, error) {
+		// refreshing the scaler is not part of the evaluation
+		time.Sleep(500 * time.Millisecond)
+		scaler := mock_scalers.NewMockScaler(ctrl)
+		scaler.EXPECT().IsActive(gomock.Any()).DoAndReturn(func(context.Context) (bool, error) {
+			time.Sleep(20 * time.Millisecond)
+			return false, nil
+		})
+		scaler.EXPECT().Close(gomock.Any())
+		return scaler, nil
+	}
+
+	scaledObject := &kedav1alpha1.ScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: kedav1alpha1.ScaledObjectSpec{
+			ScaleTargetRef: &kedav1alpha1.ScaleTarget{
+				Name: "test",
+			},
+		},
+	}
+
+	scalersCache := cache.ScalersCache{
+		Scalers: []cache.ScalerBuilder{{
+			Scaler:      slowScaler,
+			TriggerName: "s0-kafka",
+			TriggerType: "kafka",
+		}, {
+			Scaler:      failingScaler,
+			Factory:     refreshedFactory,
+			TriggerName: "queue",
+			TriggerType: "rabbitmq",
+		}},
+		Logger:   logf.Log.WithName("scalercache"),
+		Recorder: recorder,
+	}
+
+	start := time.Now()
+	_, _, _ = scalersCache.IsScaledObjectActive(context.TODO(), scaledObject)
+	evaluations := scalersCache.GetTriggerEvaluations()
+	scalersCache.Close(context.Background())
+
+	assert.Len(t, evaluations, 2)
+
+	assert.Equal(t, "s0-kafka", evaluations[0].TriggerName)
+	assert.Equal(t, "kafka", evaluations[0].TriggerType)
+	assert.False(t, evaluations[0].Time.Before(start))
+	assert.GreaterOrEqual(t, evaluations[0].Duration, 50*time.Millisecond)
+
+	assert.Equal(t, "queue", evaluations[1].TriggerName)
+	assert.Equal(t, "rabbitmq", evaluations[1].TriggerType)
+	assert.GreaterOrEqual(t, evaluations[1].Duration, 40*time.Millisecond)
+	assert.Less(t, evaluations[1].Duration, 500*time.Millisecond)
+}
+
+func TestCheckScaledObjectWithOpenCircuit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	recorder := record.NewFakeRecorder(10)
+
+	// the dead host is only called until the circuit opens
+	factory := func() (scalers.Scaler, error) {
+		scaler := mock_scalers.NewMockScaler(ctrl)
+		scaler.EXPECT().IsActive(gomock.Any()).Return(false, errors.New("dial tcp: i/o timeout"))
+		scaler.EXPECT().Close(gomock.Any())
+		return scaler, nil
+	}
+	scaler, err := factory()
+	assert.Nil(t, err)
+
+	failureThreshold := int32(1)
+	scaledObject := &kedav1alpha1.ScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: kedav1alpha1.ScaledObjectSpec{
+			ScaleTargetRef: &kedav1alpha1.ScaleTarget{
+				Name: "test",
+			},
+			Fallback: &kedav1alpha1.Fallback{
+				FailureThreshold: 3,
+				Replicas:         5,
+			},
+			Advanced: &kedav1alpha1.AdvancedConfig{
+				CircuitBreaker: &kedav1alpha1.CircuitBreaker{
+					FailureThreshold: &failureThreshold,
+				},
+			},
+		},
+	}
+
+	scalersCache := cache.ScalersCache{
+		Scalers: []cache.ScalerBuilder{{
+			Scaler:      scaler,
+			Factory:     factory,
+			TriggerName: "queue",
+			TriggerType: "rabbitmq",
+		}},
+		Logger:   logf.Log.WithName("scalercache"),
+		Recorder: recorder,
+	}
+
+	for i := 0; i < 3; i++ {
+		isActive, isError, _ := scalersCache.IsScaledObjectActive(context.TODO(), scaledObject)
+
+		// the trigger keeps failing while its circuit is open, so the executor scales to the fallback replicas
+		assert.False(t, isActive)
+		assert.True(t, isError)
+		evaluations := scalersCache.GetTriggerEvaluations()
+		assert.Equal(t, kedav1alpha1.CircuitStateOpen, evaluations[0].CircuitState)
+	}
+	scalersCache.Close(context.Background())
+}
+
+func TestGetTriggerEvaluationsStatusWithCircuitChange(t *testing.T) {
+	lastEvaluation := metav1.NewTime(time.Now().Add(-time.Second))
+	scaledObject := &kedav1alpha1.ScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Status: kedav1alpha1.ScaledObjectStatus{
+			TriggerEvaluations: map[string]kedav1alpha1.TriggerEvaluationStatus{
+				"queue": {
+					LastEvaluationTime: &lastEvaluation,
+					CircuitState:       kedav1alpha1.CircuitStateClosed,
+				},
+			},
+		},
+	}
+
+	// the circuit state didn't change, the status was updated too recently
+	assert.Nil(t, getTriggerEvaluationsStatus(scaledObject, []cache.TriggerEvaluation{{
+		TriggerName:  "queue",
+		Time:         time.Now(),
+		CircuitState: kedav1alpha1.CircuitStateClosed,
+	}}))
+
+	// the circuit opened, the status is updated right away
+	openUntil := time.Now().Add(time.Minute)
+	statuses := getTriggerEvaluationsStatus(scaledObject, []cache.TriggerEvaluation{{
+		TriggerName:      "queue",
+		Time:             time.Now(),
+		CircuitState:     kedav1alpha1.CircuitStateOpen,
+		CircuitOpenUntil: openUntil,
+	}})
+
+	status := statuses["queue"]
+	assert.Equal(t, kedav1alpha1.CircuitStateOpen, status.CircuitState)
+	assert.NotNil(t, status.CircuitOpenUntil)
+	assert.True(t, status.CircuitOpenUntil.Time.Equal(openUntil))
+}
+
+func TestGetEffectivePollingInterval(t *testing.T) {
+	pollingInterval := 30 * time.Second
+	idlePollingInterval := int32(600)
+	cooldownPeriod := int32(300)
+	now := time.Now()
+
+	scaledObject := &kedav1alpha1.ScaledObject{
+		Spec: kedav1alpha1.ScaledObjectSpec{
+			CooldownPeriod:      &cooldownPeriod,
+			IdlePollingInterval: &idlePollingInterval,
+		},
+	}
+	lastActiveAt := func(ago time.Duration) {
+		lastActiveTime := metav1.NewTime(now.Add(-ago))
+		scaledObject.Status.LastActiveTime = &lastActiveTime
+	}
+
+	// inactive within the cooldownPeriod
+	lastActiveAt(time.Minute)
+	assert.Equal(t, pollingInterval, getEffectivePollingInterval(scaledObject, pollingInterval, false, now))
+
+	// inactive for longer than the cooldownPeriod, the polling stretches
+	lastActiveAt(10 * time.Minute)
+	assert.Equal(t, 600*time.Second, getEffectivePollingInterval(scaledObject, pollingInterval, false, now))
+
+	// never active
+	scaledObject.Status.LastActiveTime = nil
+	assert.Equal(t, 600*time.Second, getEffectivePollingInterval(scaledObject, pollingInterval, false, now))
+
+	// the first active result snaps back
+	lastActiveAt(10 * time.Minute)
+	assert.Equal(t, pollingInterval, getEffectivePollingInterval(scaledObject, pollingInterval, true, now))
+
+	// without idlePollingInterval
+	scaledObject.Spec.IdlePollingInterval = nil
+	assert.Equal(t, pollingInterval, getEffectivePollingInterval(scaledObject, pollingInterval, false, now))
+
+	// not for ScaledJobs
+	assert.Equal(t, pollingInterval, getEffectivePollingInterval(&kedav1alpha1.ScaledJob{}, pollingInterval, false, now))
+}
+
+func TestWaitForNextCheck(t *testing.T) {
+	timer := make(chan time.Time, 1)
+	pushed := make(chan struct{}, 1)
+
+	// a push event wakes up an idle scale loop
+	pushed <- struct{}{}
+	assert.True(t, waitForNextCheck(context.TODO(), timer, pushed, true))
+
+	// a push event doesn't hurry a scale loop that isn't idle, it waits for the timer
+	pushed <- struct{}{}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		timer <- time.Now()
+	}()
+	assert.True(t, waitForNextCheck(context.TODO(), timer, pushed, false))
+	assert.Empty(t, pushed)
+
+	// a push event sent before going idle doesn't end the first idle wait, it waits for the timer
+	pushed <- struct{}{}
+	drainPushed(pushed)
+	start := time.Now()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		timer <- time.Now()
+	}()
+	assert.True(t, waitForNextCheck(context.TODO(), timer, pushed, true))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// draining without a push event doesn't block
+	drainPushed(pushed)
+	assert.Empty(t, pushed)
+
+	// canceled
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	assert.False(t, waitForNextCheck(ctx, timer, pushed, true))
+}
+
+func TestUpdateEffectivePollingIntervalStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mock_client.NewMockClient(ctrl)
+	statusWriter := mock_client.NewMockStatusWriter(ctrl)
+
+	handler := &scaleHandler{
+		client: client,
+		logger: logf.Log.WithName("scalehandler"),
+	}
+
+	idlePollingInterval := int32(600)
+	scaledObject := &kedav1alpha1.ScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: kedav1alpha1.ScaledObjectSpec{
+			IdlePollingInterval: &idlePollingInterval,
+		},
+	}
+
+	// the polling stretches
+	client.EXPECT().Status().Return(statusWriter)
+	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any())
+	handler.updateEffectivePollingIntervalStatus(context.TODO(), scaledObject, 600*time.Second)
+	assert.Equal(t, int32(600), *scaledObject.Status.EffectivePollingInterval)
+
+	// unchanged, the status isn't updated
+	handler.updateEffectivePollingIntervalStatus(context.TODO(), scaledObject, 600*time.Second)
+
+	// the polling snaps back
+	client.EXPECT().Status().Return(statusWriter)
+	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any())
+	handler.updateEffectivePollingIntervalStatus(context.TODO(), scaledObject, 30*time.Second)
+	assert.Equal(t, int32(30), *scaledObject.Status.EffectivePollingInterval)
+
+	// idlePollingInterval removed
+	scaledObject.Spec.IdlePollingInterval = nil
+	client.EXPECT().Status().Return(statusWriter)
+	statusWriter.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any())
+	handler.updateEffectivePollingIntervalStatus(context.TODO(), scaledObject, 30*time.Second)
+	assert.Nil(t, scaledObject.Status.EffectivePollingInterval)
+
+	handler.updateEffectivePollingIntervalStatus(context.TODO(), scaledObject, 30*time.Second)
+}
+
+func TestGetScalersCacheWithRegisteredScaler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mock_client.NewMockClient(ctrl)
+	recorder := record.NewFakeRecorder(1)
+
+	metricsSpecs := []v2beta2.MetricSpec{createMetricSpec(1)}
+	err := scalers.Register("scale-handler-test", func(ctx context.Context, config *scalers.ScalerConfig) (scalers.Scaler, error) {
+		assert.Equal(t, "bar", config.TriggerMetadata["foo"])
+		assert.Equal(t, "value", config.ResolvedEnv["ENV"])
+		scaler := mock_scalers.NewMockScaler(ctrl)
+		scaler.EXPECT().IsActive(gomock.Any()).Return(true, nil)
+		scaler.EXPECT().GetMetricSpecForScaling(gomock.Any()).AnyTimes().Return(metricsSpecs)
+		scaler.EXPECT().Close(gomock.Any())
+		return scaler, nil
+	})
+	assert.Nil(t, err)
+
+	client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, key types.NamespacedName, obj runtime.Object) error {
+		deployment := obj.(*appsv1.Deployment)
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: "test", Env: []corev1.EnvVar{{Name: "ENV", Value: "value"}}}}
+		return nil
+	})
+
+	handler := &scaleHandler{
+		client:       client,
+		logger:       logf.Log.WithName("scalehandler"),
+		recorder:     recorder,
+		scalerCaches: map[string]*cache.ScalersCache{},
+		lock:         &sync.RWMutex{},
+	}
+	scaledObject := &kedav1alpha1.ScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: kedav1alpha1.ScaledObjectSpec{
+			ScaleTargetRef: &kedav1alpha1.ScaleTarget{
+				Name: "test",
+			},
+			Triggers: []kedav1alpha1.ScaleTriggers{{
+				Type:     "scale-handler-test",
+				Metadata: map[string]string{"foo": "bar"},
+			}},
+		},
+		Status: kedav1alpha1.ScaledObjectStatus{
+			ScaleTargetGVKR: &kedav1alpha1.GroupVersionKindResource{Group: "apps", Version: "v1", Kind: "Deployment", Resource: "deployments"},
+		},
+	}
+
+	scalersCache, err := handler.GetScalersCache(context.TODO(), scaledObject)
+	assert.Nil(t, err)
+	assert.Equal(t, "scale-handler-test", scalersCache.Scalers[0].TriggerType)
+
+	isActive, isError, _ := scalersCache.IsScaledObjectActive(context.TODO(), scaledObject)
+	scalersCache.Close(context.Background())
+
+	assert.Equal(t, true, isActive)
+	assert.Equal(t, false, isError)
+}
+
+func TestGetScalersCacheRejectsValueExpressionOfResourceTriggers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mock_client.NewMockClient(ctrl)
+	recorder := record.NewFakeRecorder(1)
+
+	client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, key types.NamespacedName, obj runtime.Object) error {
+		deployment := obj.(*appsv1.Deployment)
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: "test"}}
+		return nil
+	})
+
+	handler := &scaleHandler{
+		client:       client,
+		logger:       logf.Log.WithName("scalehandler"),
+		recorder:     recorder,
+		scalerCaches: map[string]*cache.ScalersCache{},
+		lock:         &sync.RWMutex{},
+	}
+	scaledObject := &kedav1alpha1.ScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: kedav1alpha1.ScaledObjectSpec{
+			ScaleTargetRef: &kedav1alpha1.ScaleTarget{
+				Name: "test",
+			},
+			Triggers: []kedav1alpha1.ScaleTriggers{{
+				Type:            "cpu",
+				Metadata:        map[string]string{"type": "Utilization", "value": "50"},
+				ValueExpression: "value * 2",
+			}},
+		},
+		Status: kedav1alpha1.ScaledObjectStatus{
+			ScaleTargetGVKR: &kedav1alpha1.GroupVersionKindResource{Group: "apps", Version: "v1", Kind: "Deployment", Resource: "deployments"},
+		},
+	}
+
+	// the trigger could never be active, the HPA reads the cpu metric directly
+	_, err := handler.GetScalersCache(context.TODO(), scaledObject)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "valueExpression is not supported by the cpu trigger")
+}
+
+// TestBuiltinScalersAreReserved checks the trigger types of buildScaler can't be registered by an out-of-tree scaler
+func TestBuiltinScalersAreReserved(t *testing.T) {
+	source, err := ioutil.ReadFile("scale_handler.go")
+	assert.Nil(t, err)
+
+	triggers := regexp.MustCompile(`(?s)TRIGGERS-START(.*)TRIGGERS-END`).FindSubmatch(source)
+	assert.NotNil(t, triggers)
+	types := regexp.MustCompile(`case "([^"]+)":`).FindAllSubmatch(triggers[1], -1)
+	assert.NotEmpty(t, types)
+	for _, triggerType := range types {
+		assert.True(t, scalers.IsBuiltinScaler(string(triggerType[1])), "trigger type %s is missing from the built-in scalers of pkg/scalers/registry.go", triggerType[1])
+	}
+}
+
 func createMetricSpec(averageValue int64) v2beta2.MetricSpec {
 	qty := resource.NewQuantity(averageValue, resource.DecimalSI)
 	return v2beta2.MetricSpec{
@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaling
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/api/autoscaling/v2beta2"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+)
+
+// DuplicateTriggersPolicy is what the operator does with identical triggers defined in a ScaledObject
+type DuplicateTriggersPolicy string
+
+const (
+	// DuplicateTriggersDedupe keeps the first of identical triggers and records a warning event
+	DuplicateTriggersDedupe DuplicateTriggersPolicy = "dedupe"
+	// DuplicateTriggersReject fails the validation of a ScaledObject with identical triggers
+	DuplicateTriggersReject DuplicateTriggersPolicy = "reject"
+)
+
+// ParseDuplicateTriggersPolicy validates the policy given to the operator
+func ParseDuplicateTriggersPolicy(policy string) (DuplicateTriggersPolicy, error) {
+	switch DuplicateTriggersPolicy(policy) {
+	case DuplicateTriggersDedupe, DuplicateTriggersReject:
+		return DuplicateTriggersPolicy(policy), nil
+	default:
+		return "", fmt.Errorf("duplicate triggers policy must be %s or %s, got %s", DuplicateTriggersDedupe, DuplicateTriggersReject, policy)
+	}
+}
+
+// triggerFingerprint is the part of a trigger that determines the metric it produces
+type triggerFingerprint struct {
+	Type     string            `json:"type"`
+	Name     string            `json:"name"`
+	Metadata map[string]string `json:"metadata"`
+	AuthName string            `json:"authName"`
+	AuthKind string            `json:"authKind"`
+	// MetricType is part of the fingerprint, the same query targeted as Value and AverageValue isn't a duplicate
+	MetricType v2beta2.MetricTargetType `json:"metricType"`
+	// ValueExpression is omitted when empty, so the fingerprint of the triggers without one doesn't change
+	ValueExpression string `json:"valueExpression,omitempty"`
+}
+
+// TriggerFingerprint returns a hash identifying semantically identical triggers.
+// The metadata map is hashed with sorted keys, so the order of the keys doesn't make two triggers different.
+// Only two defaults are filled in: MetricType defaults to AverageValue and the kind of the AuthenticationRef
+// to TriggerAuthentication. The defaults of the scaler metadata aren't, a key set to its default value
+// makes the trigger different from the one omitting it
+func TriggerFingerprint(trigger kedav1alpha1.ScaleTriggers) string {
+	fingerprint := triggerFingerprint{
+		Type:            trigger.Type,
+		Name:            trigger.Name,
+		Metadata:        trigger.Metadata,
+		MetricType:      trigger.MetricType,
+		ValueExpression: trigger.ValueExpression,
+	}
+	if fingerprint.Metadata == nil {
+		fingerprint.Metadata = map[string]string{}
+	}
+	if fingerprint.MetricType == "" {
+		fingerprint.MetricType = v2beta2.AverageValueMetricType
+	}
+	if trigger.AuthenticationRef != nil {
+		fingerprint.AuthName = trigger.AuthenticationRef.Name
+		fingerprint.AuthKind = trigger.AuthenticationRef.Kind
+		if fingerprint.AuthKind == "" {
+			fingerprint.AuthKind = "TriggerAuthentication"
+		}
+	}
+
+	// encoding/json writes map keys in sorted order
+	b, _ := json.Marshal(fingerprint)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// FindDuplicateTriggers returns the index of every trigger identical to an earlier one, mapped to the index of the first one.
+// Triggers with different names are never duplicates, that is the way to define the same trigger twice on purpose
+func FindDuplicateTriggers(triggers []kedav1alpha1.ScaleTriggers) map[int]int {
+	duplicates := map[int]int{}
+	seen := make(map[string]int, len(triggers))
+	for i, trigger := range triggers {
+		fingerprint := TriggerFingerprint(trigger)
+		if first, ok := seen[fingerprint]; ok {
+			duplicates[i] = first
+			continue
+		}
+		seen[fingerprint] = i
+	}
+	return duplicates
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaling
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/autoscaling/v2beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+)
+
+func cronTrigger(start string) kedav1alpha1.ScaleTriggers {
+	return kedav1alpha1.ScaleTriggers{
+		Type: "cron",
+		Metadata: map[string]string{
+			"timezone":        "Etc/UTC",
+			"start":           start,
+			"end":             "0 18 * * *",
+			"desiredReplicas": "3",
+		},
+	}
+}
+
+func TestFindDuplicateTriggers(t *testing.T) {
+	named := func(trigger kedav1alpha1.ScaleTriggers, name string) kedav1alpha1.ScaleTriggers {
+		trigger.Name = name
+		return trigger
+	}
+	withAuth := func(trigger kedav1alpha1.ScaleTriggers, name, kind string) kedav1alpha1.ScaleTriggers {
+		trigger.AuthenticationRef = &kedav1alpha1.ScaledObjectAuthRef{Name: name, Kind: kind}
+		return trigger
+	}
+	withMetricType := func(trigger kedav1alpha1.ScaleTriggers, metricType v2beta2.MetricTargetType) kedav1alpha1.ScaleTriggers {
+		trigger.MetricType = metricType
+		return trigger
+	}
+
+	tests := []struct {
+		name       string
+		triggers   []kedav1alpha1.ScaleTriggers
+		duplicates map[int]int
+	}{
+		{
+			"identical",
+			[]kedav1alpha1.ScaleTriggers{cronTrigger("0 8 * * *"), cronTrigger("0 8 * * *")},
+			map[int]int{1: 0},
+		},
+		{
+			"one metadata key differs",
+			[]kedav1alpha1.ScaleTriggers{cronTrigger("0 8 * * *"), cronTrigger("0 9 * * *")},
+			map[int]int{},
+		},
+		{
+			"duplicated on purpose with different names",
+			[]kedav1alpha1.ScaleTriggers{named(cronTrigger("0 8 * * *"), "weekday"), named(cronTrigger("0 8 * * *"), "weekday-backup")},
+			map[int]int{},
+		},
+		{
+			"identical names",
+			[]kedav1alpha1.ScaleTriggers{named(cronTrigger("0 8 * * *"), "weekday"), named(cronTrigger("0 8 * * *"), "weekday")},
+			map[int]int{1: 0},
+		},
+		{
+			"different type",
+			[]kedav1alpha1.ScaleTriggers{cronTrigger("0 8 * * *"), {Type: "cpu", Metadata: cronTrigger("0 8 * * *").Metadata}},
+			map[int]int{},
+		},
+		{
+			"different authenticationRef",
+			[]kedav1alpha1.ScaleTriggers{withAuth(cronTrigger("0 8 * * *"), "a", ""), withAuth(cronTrigger("0 8 * * *"), "b", "")},
+			map[int]int{},
+		},
+		{
+			"default authenticationRef kind",
+			[]kedav1alpha1.ScaleTriggers{withAuth(cronTrigger("0 8 * * *"), "a", ""), withAuth(cronTrigger("0 8 * * *"), "a", "TriggerAuthentication")},
+			map[int]int{1: 0},
+		},
+		{
+			"ClusterTriggerAuthentication with the same name",
+			[]kedav1alpha1.ScaleTriggers{withAuth(cronTrigger("0 8 * * *"), "a", ""), withAuth(cronTrigger("0 8 * * *"), "a", "ClusterTriggerAuthentication")},
+			map[int]int{},
+		},
+		{
+			"default metricType",
+			[]kedav1alpha1.ScaleTriggers{cronTrigger("0 8 * * *"), withMetricType(cronTrigger("0 8 * * *"), v2beta2.AverageValueMetricType)},
+			map[int]int{1: 0},
+		},
+		{
+			"different metricType",
+			[]kedav1alpha1.ScaleTriggers{cronTrigger("0 8 * * *"), withMetricType(cronTrigger("0 8 * * *"), v2beta2.ValueMetricType)},
+			map[int]int{},
+		},
+		{
+			"several duplicates",
+			[]kedav1alpha1.ScaleTriggers{cronTrigger("0 8 * * *"), cronTrigger("0 9 * * *"), cronTrigger("0 8 * * *"), cronTrigger("0 9 * * *"), cronTrigger("0 8 * * *")},
+			map[int]int{2: 0, 3: 1, 4: 0},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.duplicates, FindDuplicateTriggers(test.triggers), test.name)
+	}
+}
+
+func TestTriggerFingerprintIgnoresMetadataOrder(t *testing.T) {
+	first := kedav1alpha1.ScaleTriggers{Type: "cron", Metadata: map[string]string{}}
+	second := kedav1alpha1.ScaleTriggers{Type: "cron", Metadata: map[string]string{}}
+	keys := []string{"timezone", "start", "end", "desiredReplicas"}
+	for i := range keys {
+		first.Metadata[keys[i]] = keys[i]
+		second.Metadata[keys[len(keys)-1-i]] = keys[len(keys)-1-i]
+	}
+
+	assert.Equal(t, TriggerFingerprint(first), TriggerFingerprint(second))
+	assert.Equal(t, TriggerFingerprint(kedav1alpha1.ScaleTriggers{Type: "cpu"}), TriggerFingerprint(kedav1alpha1.ScaleTriggers{Type: "cpu", Metadata: map[string]string{}}))
+}
+
+func TestParseDuplicateTriggersPolicy(t *testing.T) {
+	policy, err := ParseDuplicateTriggersPolicy("reject")
+	assert.NoError(t, err)
+	assert.Equal(t, DuplicateTriggersReject, policy)
+
+	_, err = ParseDuplicateTriggersPolicy("ignore")
+	assert.Error(t, err)
+}
+
+func TestBuildScalersSkipsDuplicateTriggers(t *testing.T) {
+	h := &scaleHandler{
+		logger:   logf.Log.WithName("scalehandler"),
+		recorder: record.NewFakeRecorder(10),
+	}
+	withTriggers := &kedav1alpha1.WithTriggers{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec: kedav1alpha1.WithTriggersSpec{
+			Triggers: []kedav1alpha1.ScaleTriggers{cronTrigger("0 8 * * *"), cronTrigger("0 8 * * *"), cronTrigger("0 9 * * *")},
+		},
+	}
+
+	builders, err := h.buildScalers(context.Background(), withTriggers, nil, "", true)
+	assert.NoError(t, err)
+	assert.Len(t, builders, 2)
+	// the trigger after the duplicate keeps its index
+	assert.Equal(t, "s0-cron", builders[0].TriggerName)
+	assert.Equal(t, "s2-cron", builders[1].TriggerName)
+
+	metricName := builders[1].Scaler.GetMetricSpecForScaling(context.Background())[0].External.Metric.Name
+	assert.Regexp(t, "^s2-", metricName)
+}
+
+func TestBuildScalersKeepsDuplicateTriggersOfScaledJobs(t *testing.T) {
+	h := &scaleHandler{
+		logger:   logf.Log.WithName("scalehandler"),
+		recorder: record.NewFakeRecorder(10),
+	}
+	withTriggers := &kedav1alpha1.WithTriggers{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec: kedav1alpha1.WithTriggersSpec{
+			Triggers: []kedav1alpha1.ScaleTriggers{cronTrigger("0 8 * * *"), cronTrigger("0 8 * * *")},
+		},
+	}
+
+	builders, err := h.buildScalers(context.Background(), withTriggers, nil, "", false)
+	assert.NoError(t, err)
+	assert.Len(t, builders, 2)
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaling
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"github.com/kedacore/keda/v2/pkg/eventreason"
+	"github.com/kedacore/keda/v2/pkg/scalers"
+)
+
+const (
+	// valueBoundsEventThreshold is how many times the values of a trigger can be clamped within valueBoundsEventWindow
+	// before a warning event is recorded, a single event is recorded per window
+	valueBoundsEventThreshold = 10
+	valueBoundsEventWindow    = time.Hour
+)
+
+// triggersWithOwnValueBounds are the triggers whose scaler reads the minValue and maxValue of its metadata and clamps
+// its values itself, the value bounds of the scale handler, the global ones included, don't apply to them
+var triggersWithOwnValueBounds = map[string]bool{
+	"predictkube": true,
+}
+
+// ValueBounds are the bounds the metric values of a trigger are clamped to, a nil bound doesn't clamp
+type ValueBounds struct {
+	Min *float64
+	Max *float64
+}
+
+// ParseValueBounds parses the minValue and maxValue of the bounds, an empty one doesn't clamp
+func ParseValueBounds(minValue, maxValue string) (ValueBounds, error) {
+	var bounds ValueBounds
+	if minValue != "" {
+		lower, err := strconv.ParseFloat(minValue, 64)
+		if err != nil || math.IsNaN(lower) || math.IsInf(lower, 0) {
+			return bounds, fmt.Errorf("invalid minValue %s", minValue)
+		}
+		bounds.Min = &lower
+	}
+	if maxValue != "" {
+		upper, err := strconv.ParseFloat(maxValue, 64)
+		if err != nil || math.IsNaN(upper) || math.IsInf(upper, 0) {
+			return bounds, fmt.Errorf("invalid maxValue %s", maxValue)
+		}
+		bounds.Max = &upper
+	}
+	if bounds.Min != nil && bounds.Max != nil && *bounds.Min > *bounds.Max {
+		return bounds, fmt.Errorf("minValue %s must not be greater than maxValue %s", minValue, maxValue)
+	}
+	return bounds, nil
+}
+
+// IsSet returns whether any of the bounds clamps
+func (b ValueBounds) IsSet() bool {
+	return b.Min != nil || b.Max != nil
+}
+
+// String returns the bounds as an interval, e.g. [0, 1000]
+func (b ValueBounds) String() string {
+	lower, upper := "-inf", "+inf"
+	if b.Min != nil {
+		lower = strconv.FormatFloat(*b.Min, 'g', -1, 64)
+	}
+	if b.Max != nil {
+		upper = strconv.FormatFloat(*b.Max, 'g', -1, 64)
+	}
+	return fmt.Sprintf("[%s, %s]", lower, upper)
+}
+
+// clamp returns the value within the bounds and whether it had to be changed
+func (b ValueBounds) clamp(value float64) (float64, bool) {
+	if b.Min != nil && value < *b.Min {
+		return *b.Min, true
+	}
+	if b.Max != nil && value > *b.Max {
+		return *b.Max, true
+	}
+	return value, false
+}
+
+// triggerValueBounds returns the bounds of the trigger, the minValue and maxValue of its metadata overriding
+// the global ones. The triggers clamping their values themselves have none
+func triggerValueBounds(trigger kedav1alpha1.ScaleTriggers, global ValueBounds) (ValueBounds, error) {
+	if triggersWithOwnValueBounds[trigger.Type] {
+		return ValueBounds{}, nil
+	}
+	bounds, err := ParseValueBounds(trigger.Metadata["minValue"], trigger.Metadata["maxValue"])
+	if err != nil {
+		return bounds, err
+	}
+	if bounds.Min == nil {
+		bounds.Min = global.Min
+	}
+	if bounds.Max == nil {
+		bounds.Max = global.Max
+	}
+	if bounds.Min != nil && bounds.Max != nil && *bounds.Min > *bounds.Max {
+		return bounds, fmt.Errorf("minValue %v must not be greater than maxValue %v, one of them being the global one", *bounds.Min, *bounds.Max)
+	}
+	return bounds, nil
+}
+
+// ValidateValueBounds checks that the minValue and maxValue of every trigger can be parsed
+func ValidateValueBounds(triggers []kedav1alpha1.ScaleTriggers, global ValueBounds) error {
+	for i, trigger := range triggers {
+		if _, err := triggerValueBounds(trigger, global); err != nil {
+			return fmt.Errorf("trigger %d (%s) has invalid value bounds: %s", i, trigger.Type, err)
+		}
+	}
+	return nil
+}
+
+// valueBoundsScaler clamps the metric values of a scaler, once transformed by its valueExpression, to the bounds
+// of its trigger, guarding the HPA against a backend reporting an absurd value
+type valueBoundsScaler struct {
+	scalers.Scaler
+	bounds      ValueBounds
+	triggerName string
+	recorder    record.EventRecorder
+	object      runtime.Object
+	// now returns the current time of the event rate limiting, time.Now when not set
+	now func() time.Time
+
+	lock        sync.Mutex
+	windowStart time.Time
+	clamped     int
+	recorded    bool
+}
+
+// withValueBounds wraps the scaler of the trigger when the trigger, or the global bounds, clamp its values.
+// The clamping is recorded on object
+func withValueBounds(scaler scalers.Scaler, trigger kedav1alpha1.ScaleTriggers, triggerName string, global ValueBounds, recorder record.EventRecorder, object runtime.Object) (scalers.Scaler, error) {
+	bounds, err := triggerValueBounds(trigger, global)
+	if err != nil {
+		return nil, err
+	}
+	if !bounds.IsSet() {
+		return scaler, nil
+	}
+	// push scalers report their activity themselves, there is no value to clamp
+	if _, ok := scaler.(scalers.PushScaler); ok {
+		if trigger.Metadata["minValue"] != "" || trigger.Metadata["maxValue"] != "" {
+			return nil, fmt.Errorf("minValue and maxValue are not supported by the %s trigger", trigger.Type)
+		}
+		return scaler, nil
+	}
+
+	return &valueBoundsScaler{Scaler: scaler, bounds: bounds, triggerName: triggerName, recorder: recorder, object: object}, nil
+}
+
+// Unwrap returns the wrapped scaler
+func (s *valueBoundsScaler) Unwrap() scalers.Scaler {
+	return s.Scaler
+}
+
+// GetMetrics returns the metric values of the scaler clamped to the bounds
+func (s *valueBoundsScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	metrics, err := s.Scaler.GetMetrics(ctx, metricName, metricSelector)
+	if err != nil {
+		return metrics, err
+	}
+
+	clamped := make([]external_metrics.ExternalMetricValue, 0, len(metrics))
+	for _, metric := range metrics {
+		value := metric.Value.AsApproximateFloat64()
+		if bounded, ok := s.bounds.clamp(value); ok {
+			metric.Value = *resource.NewMilliQuantity(int64(math.Round(bounded*1000)), resource.DecimalSI)
+			s.recordClamp(metric.MetricName, value, bounded)
+		}
+		clamped = append(clamped, metric)
+	}
+	return clamped, nil
+}
+
+// IsActive follows the clamped value: with a maxValue that isn't positive the value reported to the HPA never is,
+// so the trigger is never active. A minValue doesn't activate the trigger though, scaling from zero still follows
+// the activation of the scaler
+func (s *valueBoundsScaler) IsActive(ctx context.Context) (bool, error) {
+	active, err := s.Scaler.IsActive(ctx)
+	if err != nil || !active {
+		return active, err
+	}
+	if s.bounds.Max != nil && *s.bounds.Max <= 0 {
+		return false, nil
+	}
+	return true, nil
+}
+
+// recordClamp logs the clamped value and records a warning event once the values were clamped more than
+// valueBoundsEventThreshold times within the current valueBoundsEventWindow
+func (s *valueBoundsScaler) recordClamp(metricName string, value, bounded float64) {
+	logf.Log.WithName("scalehandler").V(1).Info("Clamped the metric value to the bounds of the trigger",
+		"trigger", s.triggerName, "metricName", metricName, "value", value, "clamped", bounded, "bounds", s.bounds.String())
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.currentTime()
+	if now.Sub(s.windowStart) >= valueBoundsEventWindow {
+		s.windowStart = now
+		s.clamped = 0
+		s.recorded = false
+	}
+	s.clamped++
+	if s.clamped <= valueBoundsEventThreshold || s.recorded || s.recorder == nil {
+		return
+	}
+	s.recorded = true
+	s.recorder.Eventf(s.object, corev1.EventTypeWarning, eventreason.KEDAScalerValueClamped,
+		"Value of trigger %s clamped %d times since %s, the last time from %v to %v within %s",
+		s.triggerName, s.clamped, s.windowStart.Format(time.RFC3339), value, bounded, s.bounds)
+}
+
+func (s *valueBoundsScaler) currentTime() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	mock_scalers "github.com/kedacore/keda/v2/pkg/mock/mock_scaler"
+	"github.com/kedacore/keda/v2/pkg/scalers"
+	"github.com/kedacore/keda/v2/pkg/scaling/cache"
+)
+
+func testValueBound(value float64) *float64 {
+	return &value
+}
+
+func TestParseValueBounds(t *testing.T) {
+	tests := []struct {
+		minValue string
+		maxValue string
+		isError  bool
+	}{
+		{"", "", false},
+		{"0", "", false},
+		{"", "1e6", false},
+		{"-10.5", "10.5", false},
+		{"10", "10", false},
+		{"10", "1", true},
+		{"a", "", true},
+		{"", "NaN", true},
+		{"", "+Inf", true},
+	}
+
+	for _, test := range tests {
+		_, err := ParseValueBounds(test.minValue, test.maxValue)
+		assert.Equal(t, test.isError, err != nil, "minValue %q maxValue %q: %v", test.minValue, test.maxValue, err)
+	}
+}
+
+func TestValueBoundsGetMetrics(t *testing.T) {
+	tests := []struct {
+		value    int64
+		expected int64
+	}{
+		// under the range
+		{value: 5, expected: 10000},
+		// in the range
+		{value: 10, expected: 10000},
+		{value: 500, expected: 500000},
+		{value: 1000, expected: 1000000},
+		// over the range
+		{value: 1 << 53, expected: 1000000},
+	}
+
+	for _, test := range tests {
+		ctrl := gomock.NewController(t)
+		trigger := kedav1alpha1.ScaleTriggers{Type: "test", Metadata: map[string]string{"minValue": "10", "maxValue": "1000"}}
+
+		scaler, err := withValueBounds(newValueExpressionTestScaler(ctrl, test.value), trigger, "s0-test", ValueBounds{}, record.NewFakeRecorder(1), &kedav1alpha1.ScaledObject{})
+		assert.NoError(t, err)
+
+		metrics, err := scaler.GetMetrics(context.Background(), "s0-queue", nil)
+		assert.NoError(t, err)
+		assert.Len(t, metrics, 1)
+		assert.Equal(t, "s0-queue", metrics[0].MetricName)
+		assert.Equal(t, test.expected, metrics[0].Value.MilliValue(), "value %d", test.value)
+	}
+}
+
+func TestValueBoundsAfterValueExpression(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	trigger := kedav1alpha1.ScaleTriggers{Type: "test", ValueExpression: "value / 1024", Metadata: map[string]string{"maxValue": "100"}}
+
+	// the bounds apply to the transformed value, 204800 / 1024 = 200 is clamped to 100
+	scaler, err := withValueExpression(newValueExpressionTestScaler(ctrl, 204800), trigger)
+	assert.NoError(t, err)
+	scaler, err = withValueBounds(scaler, trigger, "s0-test", ValueBounds{}, record.NewFakeRecorder(1), &kedav1alpha1.ScaledObject{})
+	assert.NoError(t, err)
+
+	metrics, err := scaler.GetMetrics(context.Background(), "s0-queue", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), metrics[0].Value.Value())
+}
+
+func TestValueBoundsGlobal(t *testing.T) {
+	global := ValueBounds{Min: testValueBound(1), Max: testValueBound(50)}
+
+	tests := []struct {
+		metadata map[string]string
+		value    int64
+		expected int64
+	}{
+		// the global bounds
+		{metadata: nil, value: 100, expected: 50},
+		{metadata: nil, value: 0, expected: 1},
+		// the maxValue of the trigger overrides the global one, the global minValue still applies
+		{metadata: map[string]string{"maxValue": "200"}, value: 100, expected: 100},
+		{metadata: map[string]string{"maxValue": "200"}, value: 0, expected: 1},
+	}
+
+	for _, test := range tests {
+		ctrl := gomock.NewController(t)
+		trigger := kedav1alpha1.ScaleTriggers{Type: "test", Metadata: test.metadata}
+
+		scaler, err := withValueBounds(newValueExpressionTestScaler(ctrl, test.value), trigger, "s0-test", global, record.NewFakeRecorder(1), &kedav1alpha1.ScaledObject{})
+		assert.NoError(t, err)
+
+		metrics, err := scaler.GetMetrics(context.Background(), "s0-queue", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, metrics[0].Value.Value(), "metadata %v value %d", test.metadata, test.value)
+	}
+
+	// the minValue of the trigger is above the global maxValue
+	err := ValidateValueBounds([]kedav1alpha1.ScaleTriggers{{Type: "test", Metadata: map[string]string{"minValue": "100"}}}, global)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "trigger 0 (test)")
+}
+
+func TestValueBoundsNotSet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := newValueExpressionTestScaler(ctrl, 1)
+
+	scaler, err := withValueBounds(inner, kedav1alpha1.ScaleTriggers{Type: "test"}, "s0-test", ValueBounds{}, record.NewFakeRecorder(1), &kedav1alpha1.ScaledObject{})
+	assert.NoError(t, err)
+	assert.Same(t, inner, scaler)
+
+	// the global bounds don't apply to the push scalers
+	push := mock_scalers.NewMockPushScaler(ctrl)
+	scaler, err = withValueBounds(push, kedav1alpha1.ScaleTriggers{Type: "test"}, "s0-test", ValueBounds{Max: testValueBound(10)}, record.NewFakeRecorder(1), &kedav1alpha1.ScaledObject{})
+	assert.NoError(t, err)
+	assert.Same(t, push, scaler)
+
+	_, err = withValueBounds(push, kedav1alpha1.ScaleTriggers{Type: "test", Metadata: map[string]string{"maxValue": "10"}}, "s0-test", ValueBounds{}, record.NewFakeRecorder(1), &kedav1alpha1.ScaledObject{})
+	assert.Error(t, err)
+}
+
+func TestValueBoundsOwnedByTheScaler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := newValueExpressionTestScaler(ctrl, 1<<53)
+	trigger := kedav1alpha1.ScaleTriggers{Type: "predictkube", Metadata: map[string]string{"minValue": "10", "maxValue": "1000"}}
+
+	// the predictkube scaler clamps with its minValue and maxValue itself, neither they nor the global bounds apply again
+	scaler, err := withValueBounds(inner, trigger, "s0-predictkube", ValueBounds{Max: testValueBound(100)}, record.NewFakeRecorder(1), &kedav1alpha1.ScaledObject{})
+	assert.NoError(t, err)
+	assert.Same(t, inner, scaler)
+
+	// and they are validated by the scaler
+	trigger.Metadata = map[string]string{"minValue": "a"}
+	assert.NoError(t, ValidateValueBounds([]kedav1alpha1.ScaleTriggers{trigger}, ValueBounds{}))
+}
+
+func TestValueBoundsUnwrap(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := newValueExpressionTestScaler(ctrl, 1)
+
+	// the scaler is still named after its own type in the metrics of the metrics server
+	scaler, err := withValueBounds(inner, kedav1alpha1.ScaleTriggers{Type: "test"}, "s0-test", ValueBounds{Max: testValueBound(10)}, record.NewFakeRecorder(1), &kedav1alpha1.ScaledObject{})
+	assert.NoError(t, err)
+	assert.NotSame(t, inner, scaler)
+	assert.Same(t, inner, scalers.UnwrapScaler(scaler))
+}
+
+func TestValueBoundsActivation(t *testing.T) {
+	tests := []struct {
+		metadata map[string]string
+		value    int64
+		isActive bool
+	}{
+		// over the range, still active
+		{metadata: map[string]string{"maxValue": "100"}, value: 1 << 53, isActive: true},
+		// in the range
+		{metadata: map[string]string{"minValue": "10", "maxValue": "100"}, value: 50, isActive: true},
+		// the value reported to the HPA is never positive
+		{metadata: map[string]string{"maxValue": "0"}, value: 50, isActive: false},
+	}
+
+	scaledObject := &kedav1alpha1.ScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: kedav1alpha1.ScaledObjectSpec{
+			ScaleTargetRef: &kedav1alpha1.ScaleTarget{
+				Name: "test",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		ctrl := gomock.NewController(t)
+
+		factory := func() (scalers.Scaler, error) {
+			scaler := newValueExpressionTestScaler(ctrl, test.value)
+			scaler.EXPECT().IsActive(gomock.Any()).Return(true, nil)
+			scaler.EXPECT().Close(gomock.Any())
+			return withValueBounds(scaler, kedav1alpha1.ScaleTriggers{Type: "test", Metadata: test.metadata}, "s0-test", ValueBounds{}, record.NewFakeRecorder(1), scaledObject)
+		}
+		scaler, err := factory()
+		assert.NoError(t, err)
+
+		scalersCache := cache.ScalersCache{
+			Scalers: []cache.ScalerBuilder{{
+				Scaler:  scaler,
+				Factory: factory,
+			}},
+			Logger:   logf.Log.WithName("scalercache"),
+			Recorder: record.NewFakeRecorder(1),
+		}
+
+		isActive, isError, _ := scalersCache.IsScaledObjectActive(context.TODO(), scaledObject)
+		scalersCache.Close(context.Background())
+
+		assert.Equal(t, test.isActive, isActive, "metadata %v value %d", test.metadata, test.value)
+		assert.False(t, isError)
+	}
+}
+
+func TestValueBoundsEventRateLimiting(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	recorder := record.NewFakeRecorder(10)
+	trigger := kedav1alpha1.ScaleTriggers{Type: "test", Metadata: map[string]string{"maxValue": "100"}}
+
+	scaler, err := withValueBounds(newValueExpressionTestScaler(ctrl, 1<<53), trigger, "s0-test", ValueBounds{}, recorder, &kedav1alpha1.ScaledObject{})
+	assert.NoError(t, err)
+	now := time.Now()
+	scaler.(*valueBoundsScaler).now = func() time.Time { return now }
+
+	getMetrics := func(times int) {
+		for i := 0; i < times; i++ {
+			_, err := scaler.GetMetrics(context.Background(), "s0-queue", nil)
+			assert.NoError(t, err)
+			now = now.Add(time.Minute)
+		}
+	}
+
+	// up to the threshold, no event
+	getMetrics(valueBoundsEventThreshold)
+	assert.Len(t, recorder.Events, 0)
+
+	// a single event once above it
+	getMetrics(1)
+	assert.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "KEDAScalerValueClamped")
+	getMetrics(20)
+	assert.Len(t, recorder.Events, 0)
+
+	// the count starts again in the next hour
+	now = now.Add(valueBoundsEventWindow)
+	getMetrics(valueBoundsEventThreshold)
+	assert.Len(t, recorder.Events, 0)
+	getMetrics(1)
+	assert.Len(t, recorder.Events, 1)
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaling
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"github.com/kedacore/keda/v2/pkg/scalers"
+	"github.com/kedacore/keda/v2/pkg/scaling/expression"
+)
+
+// valueExpressionScaler transforms the metric values of a scaler with the valueExpression of its trigger
+type valueExpressionScaler struct {
+	scalers.Scaler
+	expression *expression.Expression
+}
+
+// ValidateValueExpressions checks that the valueExpression of every trigger can be parsed
+func ValidateValueExpressions(triggers []kedav1alpha1.ScaleTriggers) error {
+	for i, trigger := range triggers {
+		if trigger.ValueExpression == "" {
+			continue
+		}
+		if isResourceTrigger(trigger.Type) {
+			return fmt.Errorf("trigger %d (%s) has a valueExpression, the HPA reads its metric directly", i, trigger.Type)
+		}
+		if _, err := expression.Parse(trigger.ValueExpression); err != nil {
+			return fmt.Errorf("trigger %d (%s) has an invalid valueExpression: %s", i, trigger.Type, err)
+		}
+	}
+	return nil
+}
+
+// withValueExpression wraps the scaler of the trigger when the trigger has a valueExpression
+func withValueExpression(scaler scalers.Scaler, trigger kedav1alpha1.ScaleTriggers) (scalers.Scaler, error) {
+	if trigger.ValueExpression == "" {
+		return scaler, nil
+	}
+	// the HPA reads the cpu and memory metrics directly, there is no value to transform
+	if isResourceTrigger(trigger.Type) {
+		return nil, fmt.Errorf("valueExpression is not supported by the %s trigger", trigger.Type)
+	}
+	// push scalers report their activity themselves, there is no value to transform
+	if _, ok := scaler.(scalers.PushScaler); ok {
+		return nil, fmt.Errorf("valueExpression is not supported by the %s trigger", trigger.Type)
+	}
+
+	expr, err := expression.Parse(trigger.ValueExpression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid valueExpression: %s", err)
+	}
+	return &valueExpressionScaler{Scaler: scaler, expression: expr}, nil
+}
+
+// Unwrap returns the wrapped scaler
+func (s *valueExpressionScaler) Unwrap() scalers.Scaler {
+	return s.Scaler
+}
+
+// isResourceTrigger checks if the trigger is a cpu or memory one, whose metric the HPA gets from the metrics server
+func isResourceTrigger(triggerType string) bool {
+	return triggerType == "cpu" || triggerType == "memory"
+}
+
+// GetMetrics returns the metric values of the scaler transformed by the expression
+func (s *valueExpressionScaler) GetMetrics(ctx context.Context, metricName string, metricSelector labels.Selector) ([]external_metrics.ExternalMetricValue, error) {
+	metrics, err := s.Scaler.GetMetrics(ctx, metricName, metricSelector)
+	if err != nil {
+		return metrics, err
+	}
+
+	transformed := make([]external_metrics.ExternalMetricValue, 0, len(metrics))
+	for _, metric := range metrics {
+		value, err := s.expression.Evaluate(metric.Value.AsApproximateFloat64())
+		if err != nil {
+			return nil, err
+		}
+		metric.Value = *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI)
+		transformed = append(transformed, metric)
+	}
+	return transformed, nil
+}
+
+// IsActive checks if the transformed value is above zero, the activation target of the triggers with a
+// valueExpression. The activation threshold of the scaler applies to the raw value, it isn't used: the value is
+// read once per poll, and an expression such as "value - 100" keeps the trigger inactive until the value the HPA
+// would get is positive
+func (s *valueExpressionScaler) IsActive(ctx context.Context) (bool, error) {
+	metrics, err := s.GetMetrics(ctx, s.metricName(ctx), nil)
+	if err != nil {
+		return false, err
+	}
+	for _, metric := range metrics {
+		if metric.Value.Sign() > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *valueExpressionScaler) metricName(ctx context.Context) string {
+	for _, spec := range s.Scaler.GetMetricSpecForScaling(ctx) {
+		if spec.Type == v2beta2.ExternalMetricSourceType && spec.External != nil {
+			return spec.External.Metric.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaling
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/metrics/pkg/apis/external_metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	mock_scalers "github.com/kedacore/keda/v2/pkg/mock/mock_scaler"
+	"github.com/kedacore/keda/v2/pkg/scalers"
+	"github.com/kedacore/keda/v2/pkg/scaling/cache"
+)
+
+func newValueExpressionTestScaler(ctrl *gomock.Controller, value int64) *mock_scalers.MockScaler {
+	metricSpec := createMetricSpec(1)
+	metricSpec.Type = v2beta2.ExternalMetricSourceType
+	metricSpec.External.Metric.Name = "s0-queue"
+
+	scaler := mock_scalers.NewMockScaler(ctrl)
+	scaler.EXPECT().GetMetricSpecForScaling(gomock.Any()).AnyTimes().Return([]v2beta2.MetricSpec{metricSpec})
+	scaler.EXPECT().GetMetrics(gomock.Any(), "s0-queue", gomock.Any()).AnyTimes().Return([]external_metrics.ExternalMetricValue{{
+		MetricName: "s0-queue",
+		Value:      *resource.NewQuantity(value, resource.DecimalSI),
+	}}, nil)
+	return scaler
+}
+
+func TestValueExpressionGetMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	scaler, err := withValueExpression(newValueExpressionTestScaler(ctrl, 2048), kedav1alpha1.ScaleTriggers{Type: "test", ValueExpression: "value / 1024 / 8"})
+	assert.NoError(t, err)
+
+	metrics, err := scaler.GetMetrics(context.Background(), "s0-queue", nil)
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "s0-queue", metrics[0].MetricName)
+	assert.Equal(t, int64(250), metrics[0].Value.MilliValue())
+}
+
+func TestValueExpressionEvaluationError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	scaler, err := withValueExpression(newValueExpressionTestScaler(ctrl, 0), kedav1alpha1.ScaleTriggers{Type: "test", ValueExpression: "100 / value"})
+	assert.NoError(t, err)
+
+	_, err = scaler.GetMetrics(context.Background(), "s0-queue", nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "division by zero")
+}
+
+func TestValueExpressionNotSet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := newValueExpressionTestScaler(ctrl, 1)
+
+	scaler, err := withValueExpression(inner, kedav1alpha1.ScaleTriggers{Type: "test"})
+	assert.NoError(t, err)
+	assert.Same(t, inner, scaler)
+}
+
+func TestValueExpressionUnwrap(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := newValueExpressionTestScaler(ctrl, 1)
+
+	// the scaler is still named after its own type in the metrics of the metrics server
+	scaler, err := withValueExpression(inner, kedav1alpha1.ScaleTriggers{Type: "test", ValueExpression: "value * 2"})
+	assert.NoError(t, err)
+	assert.NotSame(t, inner, scaler)
+	assert.Same(t, inner, scalers.UnwrapScaler(scaler))
+
+	// with the value bounds around the valueExpression
+	scaler, err = withValueBounds(scaler, kedav1alpha1.ScaleTriggers{Type: "test"}, "s0-test", ValueBounds{Max: testValueBound(10)}, record.NewFakeRecorder(1), &kedav1alpha1.ScaledObject{})
+	assert.NoError(t, err)
+	assert.Same(t, inner, scalers.UnwrapScaler(scaler))
+}
+
+func TestValueExpressionInvalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	_, err := withValueExpression(newValueExpressionTestScaler(ctrl, 1), kedav1alpha1.ScaleTriggers{Type: "test", ValueExpression: "value +"})
+	assert.Error(t, err)
+
+	_, err = withValueExpression(mock_scalers.NewMockPushScaler(ctrl), kedav1alpha1.ScaleTriggers{Type: "test", ValueExpression: "value * 2"})
+	assert.Error(t, err)
+}
+
+func TestValidateValueExpressions(t *testing.T) {
+	valid := []kedav1alpha1.ScaleTriggers{
+		{Type: "cpu"},
+		{Type: "prometheus", ValueExpression: "max(value - 100, 0)"},
+	}
+	assert.NoError(t, ValidateValueExpressions(valid))
+
+	invalid := append(valid, kedav1alpha1.ScaleTriggers{Type: "prometheus", ValueExpression: "sqrt(value)"})
+	err := ValidateValueExpressions(invalid)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "trigger 2 (prometheus)")
+
+	// the HPA reads the metrics of the resource triggers directly
+	for _, triggerType := range []string{"cpu", "memory"} {
+		err = ValidateValueExpressions([]kedav1alpha1.ScaleTriggers{{Type: triggerType, ValueExpression: "value * 2"}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), fmt.Sprintf("trigger 0 (%s)", triggerType))
+	}
+}
+
+func TestValueExpressionActivation(t *testing.T) {
+	tests := []struct {
+		value      int64
+		expression string
+		isActive   bool
+	}{
+		{value: 50, expression: "value - 100", isActive: false},
+		{value: 100, expression: "value - 100", isActive: false},
+		{value: 150, expression: "value - 100", isActive: true},
+		// the activation threshold of the scaler doesn't apply to the transformed value
+		{value: 0, expression: "value + 10", isActive: true},
+	}
+
+	scaledObject := &kedav1alpha1.ScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: kedav1alpha1.ScaledObjectSpec{
+			ScaleTargetRef: &kedav1alpha1.ScaleTarget{
+				Name: "test",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		ctrl := gomock.NewController(t)
+
+		// the activation is decided on a single query of the value, IsActive of the scaler isn't called
+		factory := func() (scalers.Scaler, error) {
+			metricSpec := createMetricSpec(1)
+			metricSpec.Type = v2beta2.ExternalMetricSourceType
+			metricSpec.External.Metric.Name = "s0-queue"
+
+			scaler := mock_scalers.NewMockScaler(ctrl)
+			scaler.EXPECT().GetMetricSpecForScaling(gomock.Any()).AnyTimes().Return([]v2beta2.MetricSpec{metricSpec})
+			scaler.EXPECT().GetMetrics(gomock.Any(), "s0-queue", gomock.Any()).Times(1).Return([]external_metrics.ExternalMetricValue{{
+				MetricName: "s0-queue",
+				Value:      *resource.NewQuantity(test.value, resource.DecimalSI),
+			}}, nil)
+			scaler.EXPECT().Close(gomock.Any())
+			return withValueExpression(scaler, kedav1alpha1.ScaleTriggers{Type: "test", ValueExpression: test.expression})
+		}
+		scaler, err := factory()
+		assert.NoError(t, err)
+
+		scalersCache := cache.ScalersCache{
+			Scalers: []cache.ScalerBuilder{{
+				Scaler:  scaler,
+				Factory: factory,
+			}},
+			Logger:   logf.Log.WithName("scalercache"),
+			Recorder: record.NewFakeRecorder(1),
+		}
+
+		isActive, isError, _ := scalersCache.IsScaledObjectActive(context.TODO(), scaledObject)
+		scalersCache.Close(context.Background())
+
+		assert.Equal(t, test.isActive, isActive, "value %d with %s", test.value, test.expression)
+		assert.False(t, isError)
+		ctrl.Finish()
+	}
+}
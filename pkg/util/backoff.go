@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// DefaultRateLimitBackoff is the backoff used to retry requests the server rejected with 429 Too Many Requests.
+// It is kept short, the scaler is polled again anyway
+var DefaultRateLimitBackoff = wait.Backoff{
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    3,
+	Cap:      5 * time.Second,
+}
+
+// DoWithRateLimitBackoff sends the request created by newRequest and retries it following backoff as long as
+// the server responds with 429 Too Many Requests. A Retry-After header in seconds is honored when it is within
+// the cap of the backoff, a longer one stops the retries. The last response is returned once the retries are
+// exhausted, the caller is responsible for closing its body
+func DoWithRateLimitBackoff(ctx context.Context, client HTTPDoer, backoff wait.Backoff, newRequest func(context.Context) (*http.Request, error)) (*http.Response, error) {
+	for {
+		req, err := newRequest(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := client.Do(req)
+		if err != nil || res.StatusCode != http.StatusTooManyRequests || backoff.Steps <= 1 {
+			return res, err
+		}
+
+		delay := backoff.Step()
+		if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+			if backoff.Cap > 0 && retryAfter > backoff.Cap {
+				return res, nil
+			}
+			if retryAfter > delay {
+				delay = retryAfter
+			}
+		}
+
+		// the body has to be drained for the connection to be reused
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("rate limited, giving up retrying: %s", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// parseRetryAfter parses the Retry-After header given as a number of seconds
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestDoWithRateLimitBackoff(t *testing.T) {
+	backoff := wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3, Cap: time.Second}
+
+	tests := []struct {
+		name       string
+		retryAfter string
+		limited    int
+		calls      int
+		statusCode int
+	}{
+		{"not rate limited", "", 0, 1, http.StatusOK},
+		{"rate limited once", "", 1, 2, http.StatusOK},
+		{"rate limited longer than the retries", "", 5, 3, http.StatusTooManyRequests},
+		{"retry after within the cap", "0", 1, 2, http.StatusOK},
+		{"retry after above the cap", "60", 1, 1, http.StatusTooManyRequests},
+	}
+
+	for _, test := range tests {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls <= test.limited {
+				if test.retryAfter != "" {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		res, err := DoWithRateLimitBackoff(context.Background(), server.Client(), backoff, func(ctx context.Context) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", test.name, err)
+		}
+		res.Body.Close()
+		server.Close()
+
+		if res.StatusCode != test.statusCode {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.statusCode, res.StatusCode)
+		}
+		if calls != test.calls {
+			t.Errorf("%s: expected %d calls, got %d", test.name, test.calls, calls)
+		}
+	}
+}
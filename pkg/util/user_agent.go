@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/kedacore/keda/v2/version"
+)
+
+var (
+	clusterNameLock sync.RWMutex
+	clusterName     string
+)
+
+// SetClusterName sets the cluster name added to the User-Agent of the requests sent by the scalers
+func SetClusterName(name string) {
+	clusterNameLock.Lock()
+	defer clusterNameLock.Unlock()
+	clusterName = name
+}
+
+// UserAgent returns the User-Agent identifying KEDA, the scaler type and the cluster to the scaler backends,
+// e.g. "keda/2.7.0 scaler/prometheus cluster/prod-eu"
+func UserAgent(scalerType string) string {
+	userAgent := fmt.Sprintf("keda/%s", version.Version)
+	if scalerType != "" {
+		userAgent = fmt.Sprintf("%s scaler/%s", userAgent, scalerType)
+	}
+
+	clusterNameLock.RLock()
+	defer clusterNameLock.RUnlock()
+	if clusterName != "" {
+		userAgent = fmt.Sprintf("%s cluster/%s", userAgent, clusterName)
+	}
+	return userAgent
+}
+
+type userAgentRoundTripper struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+// NewUserAgentRoundTripper returns a round tripper setting the User-Agent of the requests before passing them to next.
+// A User-Agent already set on the request, e.g. by a client SDK, is kept after the given one
+func NewUserAgentRoundTripper(userAgent string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &userAgentRoundTripper{userAgent: userAgent, next: next}
+}
+
+func (rt *userAgentRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	if existing := req.Header.Get("User-Agent"); existing != "" {
+		req.Header.Set("User-Agent", fmt.Sprintf("%s %s", rt.userAgent, existing))
+	} else {
+		req.Header.Set("User-Agent", rt.userAgent)
+	}
+	return rt.next.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of next, e.g. when the client of a scaler is closed
+func (rt *userAgentRoundTripper) CloseIdleConnections() {
+	if closer, ok := rt.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The KEDA Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kedacore/keda/v2/version"
+)
+
+func TestUserAgent(t *testing.T) {
+	defer SetClusterName("")
+
+	tests := []struct {
+		scalerType  string
+		clusterName string
+		expected    string
+	}{
+		{"", "", "keda/" + version.Version},
+		{"prometheus", "", "keda/" + version.Version + " scaler/prometheus"},
+		{"rabbitmq", "prod-eu", "keda/" + version.Version + " scaler/rabbitmq cluster/prod-eu"},
+	}
+
+	for _, test := range tests {
+		SetClusterName(test.clusterName)
+		if userAgent := UserAgent(test.scalerType); userAgent != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, userAgent)
+		}
+	}
+}
+
+func TestUserAgentRoundTripper(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewUserAgentRoundTripper("keda/test scaler/prometheus", nil)}
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if userAgent != "keda/test scaler/prometheus" {
+		t.Errorf("Unexpected User-Agent %q", userAgent)
+	}
+	if req.Header.Get("User-Agent") != "" {
+		t.Error("The original request must not be modified")
+	}
+
+	// the User-Agent of client SDKs is kept
+	req.Header.Set("User-Agent", "sdk/1.0")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if userAgent != "keda/test scaler/prometheus sdk/1.0" {
+		t.Errorf("Unexpected User-Agent %q", userAgent)
+	}
+}
+
+type idleConnectionsTransport struct {
+	http.RoundTripper
+	closed bool
+}
+
+func (t *idleConnectionsTransport) CloseIdleConnections() {
+	t.closed = true
+}
+
+func TestUserAgentRoundTripperCloseIdleConnections(t *testing.T) {
+	transport := &idleConnectionsTransport{}
+	client := &http.Client{Transport: NewUserAgentRoundTripper("keda/test scaler/prometheus", transport)}
+
+	client.CloseIdleConnections()
+	if !transport.closed {
+		t.Error("The idle connections of the wrapped transport must be closed")
+	}
+}